@@ -0,0 +1,79 @@
+package awsauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session-token")
+}
+
+func TestSigV4Sign(t *testing.T) {
+	setupEnv(t)
+
+	defer func(orig func() time.Time) { now = orig }(now)
+	now = func() time.Time { return time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	body := `{"hello": "world"}`
+	req, _ := http.NewRequest(http.MethodPost, "https://abc123.execute-api.us-west-2.amazonaws.com/prod/items", strings.NewReader(body))
+
+	h := &SigV4Handler{}
+	params := map[string]string{"region": "us-west-2", "service": "execute-api"}
+	require.NoError(t, h.OnRequest(req, "test:default", params))
+
+	sum := sha256.Sum256([]byte(body))
+	assert.Equal(t, hex.EncodeToString(sum[:]), req.Header.Get("X-Amz-Content-Sha256"))
+	assert.Equal(t, "20230102T030405Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "session-token", req.Header.Get("X-Amz-Security-Token"))
+
+	auth := req.Header.Get("Authorization")
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20230102/us-west-2/execute-api/aws4_request, SignedHeaders="), auth)
+	assert.Contains(t, auth, "x-amz-content-sha256;x-amz-date;x-amz-security-token")
+
+	// The body can still be sent after hashing it.
+	sent, _ := io.ReadAll(req.Body)
+	assert.Equal(t, body, string(sent))
+
+	// Retries get a fresh signature.
+	now = func() time.Time { return time.Date(2023, 1, 2, 3, 5, 0, 0, time.UTC) }
+	req.Body = io.NopCloser(strings.NewReader(body))
+	require.NoError(t, h.OnRetry(req, "test:default", params))
+	assert.Equal(t, "20230102T030500Z", req.Header.Get("X-Amz-Date"))
+	assert.NotEqual(t, auth, req.Header.Get("Authorization"))
+}
+
+func TestSigV4Existing(t *testing.T) {
+	setupEnv(t)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+
+	h := &SigV4Handler{}
+	params := map[string]string{"region": "us-west-2", "service": "execute-api"}
+	require.NoError(t, h.OnRequest(req, "test:default", params))
+	require.NoError(t, h.OnRetry(req, "test:default", params))
+	assert.Equal(t, "Bearer abc", req.Header.Get("Authorization"))
+}
+
+func TestSigV4InvalidProfile(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	err := (&SigV4Handler{}).OnRequest(req, "test:default", map[string]string{"region": "us-west-2"})
+	assert.ErrorIs(t, err, ErrInvalidProfile)
+}
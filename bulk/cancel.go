@@ -0,0 +1,38 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// errInterrupted is returned when a pull or push is cancelled, e.g. via
+// Ctrl-C, after saving the progress made so far.
+var errInterrupted = errors.New("interrupted, progress saved")
+
+// interruptContext returns a context which is cancelled on the first Ctrl-C,
+// which stops in-flight requests so that the work done so far can be saved
+// before exiting. A second Ctrl-C exits right away as usual.
+func interruptContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// cancelled saves the progress of a cancelled pull or push, reports how far
+// it got, and returns `errInterrupted`.
+func (m *Meta) cancelled(processed, total int) error {
+	fmt.Fprintln(cli.Stdout)
+	if err := m.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.Stdout, "Cancelled, %d of %d files processed\n", processed, total)
+	return errInterrupted
+}
@@ -0,0 +1,268 @@
+package bulk
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// checkoutMu serializes operations on checkouts, which share the package's
+// filesystem.
+var checkoutMu sync.Mutex
+
+// SetFs replaces the filesystem used for checkouts, e.g. with
+// `afero.NewMemMapFs()` to test programs embedding this package. It must be
+// called before `Open`.
+func SetFs(fs afero.Fs) {
+	checkoutMu.Lock()
+	defer checkoutMu.Unlock()
+	afs = fs
+}
+
+// Checkout is a bulk checkout opened for use as a library, providing the same
+// operations as the `bulk` commands with structured results. Requests are made
+// via the `cli` package, which must be set up with `cli.Init` and
+// `cli.Defaults` first. Progress and messages are written to `cli.Stdout`.
+// Operations on all checkouts are serialized.
+type Checkout struct {
+	meta *Meta
+
+	// fs is the filesystem rooted at the checkout's directory, or nil to use
+	// the current directory.
+	fs afero.Fs
+}
+
+// Change is a file which was added, modified, or removed locally or on the
+// remote.
+type Change struct {
+	Path string `json:"path"`
+	URL  string `json:"url"`
+	// Status is one of `added`, `modified`, or `removed`.
+	Status string `json:"status"`
+}
+
+// Status lists the local and remote changes of a checkout.
+type Status struct {
+	Local  []Change `json:"local"`
+	Remote []Change `json:"remote"`
+
+	// FailedDeletes holds the HTTP status code, or zero for a request error,
+	// of locally removed files whose remote deletion failed.
+	FailedDeletes map[string]int `json:"failed_deletes,omitempty"`
+
+	// CachedIndex is when the remote versions were last refreshed if the index
+	// was unavailable and they were used instead, see
+	// `StatusOptions.NoFallback`. Remote changes since then are missing.
+	CachedIndex *time.Time `json:"cached_index,omitempty"`
+}
+
+// Result summarizes a pull, push, or sync.
+type Result struct {
+	Succeeded int           `json:"succeeded"`
+	Skipped   int           `json:"skipped"`
+	Failed    int           `json:"failed"`
+	Failures  []FileFailure `json:"failures,omitempty"`
+	Duration  time.Duration `json:"duration"`
+
+	// Renamed lists the files which were moved because their resource's URL
+	// changed when pushed.
+	Renamed []Rename `json:"renamed,omitempty"`
+}
+
+// SyncResult summarizes a sync. Nothing is pulled or pushed if there are
+// conflicts which were not resolved via `SyncOptions.Prefer`.
+type SyncResult struct {
+	Result
+	UpToDate  bool     `json:"up_to_date"`
+	Conflicts []Change `json:"conflicts,omitempty"`
+}
+
+// StatusOptions configure how remote changes are found.
+type StatusOptions struct {
+	// Head checks each file with a `HEAD` request instead of the index, using
+	// up to Parallel requests at once.
+	Head     bool
+	Parallel int
+
+	// Treat404AsGone shows files which respond with `404 Not Found` to `HEAD`
+	// requests as removed.
+	Treat404AsGone bool
+
+	// NoFallback returns an error instead of using the remote versions from
+	// the last refresh when the index is rate limited or has a server error.
+	NoFallback bool
+}
+
+// PullOptions configure a pull, see `bulk pull`.
+type PullOptions struct {
+	// Rate is the maximum number of requests per second, overriding the
+	// pacing from rate limit headers.
+	Rate float64
+
+	// Treat404AsGone removes files which respond with `404 Not Found` like
+	// `410 Gone`.
+	Treat404AsGone bool
+
+	// PreserveComments keeps comments in local files which are updated, where
+	// possible.
+	PreserveComments bool
+}
+
+// PushOptions configure a push, see `bulk push`.
+type PushOptions struct {
+	// Message is the reason for the change, sent with each request and saved
+	// in the push record.
+	Message string
+
+	// Rate is the maximum number of requests per second, overriding the
+	// pacing from rate limit headers.
+	Rate float64
+
+	// Interactive asks which local changes to push on stdin, which must be a
+	// terminal, before uploading any.
+	Interactive bool
+}
+
+// SyncOptions configure a sync, see `bulk sync` and the pull and push
+// options.
+type SyncOptions struct {
+	// Prefer is `local` or `remote` to resolve all conflicts in favor of that
+	// side, or empty to sync nothing while there are conflicts.
+	Prefer string
+
+	Message          string
+	Rate             float64
+	Treat404AsGone   bool
+	PreserveComments bool
+}
+
+// Open loads the checkout in a directory, or the current directory if empty.
+func Open(dir string) (*Checkout, error) {
+	c := &Checkout{}
+	if dir != "" && dir != "." {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		c.fs = afero.NewBasePathFs(afs, abs)
+		dir = abs
+	} else {
+		dir = ""
+	}
+
+	defer c.use("")()
+	m := &Meta{}
+	if err := loadMeta(m); err != nil {
+		return nil, err
+	}
+	m.dir = dir
+	c.meta = m
+	return c, nil
+}
+
+// use locks the package's filesystem to the checkout's directory and
+// identifies requests as the given bulk command, if any, returning a function
+// to unlock it.
+func (c *Checkout) use(command string) func() {
+	checkoutMu.Lock()
+	previous := afs
+	previousAgent := cli.UserAgent
+	if c.fs != nil {
+		afs = c.fs
+	}
+	if command != "" {
+		cli.UserAgent = c.meta.userAgent(command)
+	}
+	return func() {
+		afs = previous
+		cli.UserAgent = previousAgent
+		checkoutMu.Unlock()
+	}
+}
+
+// URL returns the URL of the resource list the checkout was created from.
+func (c *Checkout) URL() string {
+	return c.meta.URL
+}
+
+// changes converts changed files into their exported form.
+func changes(files []changedFile) []Change {
+	converted := make([]Change, 0, len(files))
+	for _, changed := range files {
+		converted = append(converted, Change{
+			Path:   changed.File.Path,
+			URL:    changed.File.URL,
+			Status: changed.Status.label(),
+		})
+	}
+	return converted
+}
+
+// result converts the report of the last pull, push, or sync.
+func (c *Checkout) result() Result {
+	r := c.meta.report
+	if r == nil {
+		return Result{}
+	}
+	return Result{
+		Succeeded: r.Succeeded,
+		Skipped:   r.Skipped,
+		Failed:    r.Failed,
+		Failures:  r.Failures,
+		Duration:  time.Since(r.start),
+		Renamed:   r.Renamed,
+	}
+}
+
+// Status refreshes the remote versions and returns the local and remote
+// changes.
+func (c *Checkout) Status(ctx context.Context, opts StatusOptions) (*Status, error) {
+	defer c.use("status")()
+	local, remote, err := c.meta.status(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Local: changes(local), Remote: changes(remote), FailedDeletes: c.meta.FailedDeletes}
+	if !c.meta.cachedIndex.IsZero() {
+		cached := c.meta.cachedIndex
+		status.CachedIndex = &cached
+	}
+	return status, nil
+}
+
+// Pull fetches remote changes without overwriting local edits.
+func (c *Checkout) Pull(ctx context.Context, opts PullOptions) (*Result, error) {
+	defer c.use("pull")()
+	c.meta.rate = opts.Rate
+	c.meta.treat404AsGone = opts.Treat404AsGone
+	c.meta.preserveComments = opts.PreserveComments
+	err := c.meta.Pull(ctx)
+	result := c.result()
+	return &result, err
+}
+
+// Push uploads local changes using conditional requests where possible.
+func (c *Checkout) Push(ctx context.Context, opts PushOptions) (*Result, error) {
+	defer c.use("push")()
+	c.meta.rate = opts.Rate
+	c.meta.message = opts.Message
+	c.meta.interactive = opts.Interactive
+	err := c.meta.Push(ctx)
+	result := c.result()
+	return &result, err
+}
+
+// Sync pulls remote changes and then pushes local changes, see `bulk sync`.
+func (c *Checkout) Sync(ctx context.Context, opts SyncOptions) (*SyncResult, error) {
+	defer c.use("sync")()
+	c.meta.rate = opts.Rate
+	c.meta.treat404AsGone = opts.Treat404AsGone
+	c.meta.preserveComments = opts.PreserveComments
+	c.meta.message = opts.Message
+	outcome, conflicted, err := c.meta.Sync(ctx, opts.Prefer)
+	return &SyncResult{Result: c.result(), UpToDate: outcome == syncNothing, Conflicts: changes(conflicted)}, err
+}
@@ -0,0 +1,92 @@
+package bulk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"github.com/tarunKoyalwar/restish/cli"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestCheckout(t *testing.T) {
+	defer gock.Off()
+
+	fs := afero.NewMemMapFs()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	// Create the checkout in a directory other than the current one.
+	afs = afero.NewBasePathFs(fs, "/work")
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	_, err := run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	SetFs(fs)
+
+	_, err = Open("/missing")
+	require.Error(t, err)
+
+	checkout, err := Open("/work")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/all-items", checkout.URL())
+
+	ctx := context.Background()
+
+	afero.WriteFile(fs, "/work/b/items/b1.json", []byte(`{"id": "b1", "local": true}`), 0600)
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	status, err := checkout.Status(ctx, StatusOptions{})
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Equal(t, []Change{{Path: "b/items/b1.json", URL: "https://example.com/users/b/items/b1", Status: "modified"}}, status.Local)
+	require.Equal(t, []Change{{Path: "a/items/a1.json", URL: "https://example.com/users/a/items/a1", Status: "modified"}}, status.Remote)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12", fetch: true, body: `{"id": "a1", "remote": true}`},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	result, err := checkout.Pull(ctx, PullOptions{})
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Equal(t, 1, result.Succeeded)
+	b, _ := afero.ReadFile(fs, "/work/a/items/a1.json")
+	require.JSONEq(t, `{"id": "a1", "remote": true}`, string(b))
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/b/items/b1").
+		MatchHeader("X-Change-Reason", "library").
+		Reply(http.StatusForbidden)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	result, err = checkout.Push(ctx, PushOptions{Message: "library"})
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Equal(t, 1, result.Failed)
+	require.Equal(t, "b/items/b1.json", result.Failures[0].Path)
+	require.Equal(t, http.StatusForbidden, result.Failures[0].Status)
+}
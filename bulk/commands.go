@@ -1,14 +1,20 @@
 package bulk
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/danielgtaylor/mexpr"
 	"github.com/danielgtaylor/shorthand/v2"
@@ -36,86 +42,173 @@ func panicOnErr(err error) {
 	}
 }
 
-// isFalsey returns if a value is falsey, such as `0`, `""`, `[]any{}`, etc.
-// Empty slices and maps are considered falsey.
-func isFalsey(v any) bool {
-	switch t := v.(type) {
-	case bool:
-		return !t
-	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
-		return t == 0
-	case float32, float64:
-		return t == 0.0
-	case string:
-		return len(t) == 0
-	case []byte:
-		return len(t) == 0
-	case []any:
-		return len(t) == 0
-	case map[string]any:
-		return len(t) == 0
-	case map[any]any:
-		return len(t) == 0
-	}
-	return false
+// loadSchema downloads and parses a JSON Schema, returning nil if it isn't
+// available or can't be understood.
+func loadSchema(schemaURL string) *base.Schema {
+	if schemaURL == "" {
+		return nil
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, schemaURL, nil)
+	resp, err := cli.MakeRequest(req)
+	if err != nil || resp.StatusCode >= 300 {
+		return nil
+	}
+	cli.DecodeResponse(resp)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil
+	}
+
+	var rootNode yaml.Node
+	var ls lowbase.Schema
+
+	if err := yaml.Unmarshal(body, &rootNode); err != nil {
+		return nil
+	}
+	if err := low.BuildModel(rootNode.Content[0], &ls); err != nil {
+		return nil
+	}
+	if err := ls.Build(rootNode.Content[0], index.NewSpecIndex(&rootNode)); err != nil {
+		return nil
+	}
+	return base.NewSchema(&ls)
 }
 
 // newInterpreter creates a new mexpr interpreter, optionally with type
 // checking if a JSON Schema is available to describe the structure of the
 // input. Parse errors are logged as warnings since there could be false
 // positives - the idea is to provide help to the user for debugging.
-func newInterpreter(expression, schemaURL string) mexpr.Interpreter {
+func newInterpreter(expression, schemaURL string, ignoreCase bool) mexpr.Interpreter {
 	var example map[string]any
-
-	if schemaURL != "" {
-		// We have a schema which might be a JSON Schema we can understand. Let's
-		// try to download, parse, and generate an example for the type checker.
-		// Note: JSON Schema supports a superset of the mexpr types, for example
-		// one-ofs and if/then/else. Some schemas will result in warnings that
-		// may be false positives, but this is still a useful feature worth
-		// keeping in my opinion.
-		req, _ := http.NewRequest(http.MethodGet, schemaURL, nil)
-		if resp, err := cli.MakeRequest(req); err == nil && resp.StatusCode < 300 {
-			cli.DecodeResponse(resp)
-			defer resp.Body.Close()
-			if body, err := io.ReadAll(resp.Body); err == nil {
-
-				var rootNode yaml.Node
-				var ls lowbase.Schema
-
-				if err := yaml.Unmarshal(body, &rootNode); err == nil {
-					if err := low.BuildModel(rootNode.Content[0], &ls); err == nil {
-						if err := ls.Build(rootNode.Content[0], index.NewSpecIndex(&rootNode)); err == nil {
-							s := base.NewSchema(&ls)
-							result := openapi.GenExample(s, 0)
-							if asMap, ok := result.(map[string]any); ok {
-								example = asMap
-							}
-						}
-					}
-				}
-			}
+	var enums map[string][]any
+
+	// We have a schema which might be a JSON Schema we can understand. Let's
+	// try to download, parse, and generate an example for the type checker.
+	// Note: JSON Schema supports a superset of the mexpr types, for example
+	// one-ofs and if/then/else. Some schemas will result in warnings that
+	// may be false positives, but this is still a useful feature worth
+	// keeping in my opinion.
+	if s := loadSchema(schemaURL); s != nil {
+		result := openapi.GenExample(s, 0)
+		if asMap, ok := result.(map[string]any); ok {
+			example = asMap
 		}
+		enums = openapi.SchemaEnums(s)
 	}
 
-	ast, err := mexpr.Parse(expression, example, mexpr.UnquotedStrings)
+	i, err := cli.ParseMatch(expression, example, ignoreCase)
 	if err != nil {
 		cli.LogWarning(err.Pretty(expression))
 		// Just return a falsey value to filter these files out.
-		ast = &mexpr.Node{
+		return mexpr.NewInterpreter(&mexpr.Node{
 			Type:  mexpr.NodeLiteral,
 			Value: 0,
+		})
+	}
+
+	// Comparing against a value the schema doesn't allow is likely a typo which
+	// would otherwise silently match nothing.
+	for _, err := range cli.MatchEnumErrors(expression, example, enums, ignoreCase) {
+		cli.LogWarning("%s", err.Pretty(expression))
+	}
+
+	return i
+}
+
+// matchComparison matches a comparison being completed in a match expression,
+// like `status == ac` or `author.role != "ed`.
+var matchComparison = regexp.MustCompile(`([A-Za-z_][\w.\[\]]*)\s*(?:==|!=)\s*("?)([^"\s]*)$`)
+
+// matchIndex matches array indexes in a property path like `items[0].status`.
+var matchIndex = regexp.MustCompile(`\[[^\]]*\]`)
+
+// completeMatch completes the value in a comparison of a property with an
+// `enum` in the schemas of the checked out files, e.g. `status == ` to
+// `status == active`.
+func completeMatch(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	groups := matchComparison.FindStringSubmatchIndex(toComplete)
+	if groups == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	path := matchIndex.ReplaceAllString(toComplete[groups[2]:groups[3]], "")
+	quote := toComplete[groups[4]:groups[5]]
+	partial := toComplete[groups[6]:groups[7]]
+	prefix := toComplete[:groups[6]]
+
+	var meta Meta
+	if err := loadMeta(&meta); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	schemas := map[string]bool{}
+	for _, f := range meta.Files {
+		if f.Schema != "" {
+			schemas[f.Schema] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	values := []string{}
+	for schemaURL := range schemas {
+		s := loadSchema(schemaURL)
+		if s == nil {
+			continue
+		}
+		for enumPath, enum := range openapi.SchemaEnums(s) {
+			// Properties within `where` clauses are relative to the items.
+			if enumPath != path && !strings.HasSuffix(enumPath, "."+path) {
+				continue
+			}
+			for _, v := range enum {
+				value := fmt.Sprintf("%v", v)
+				if !seen[value] && strings.HasPrefix(value, partial) {
+					seen[value] = true
+					values = append(values, prefix+value+quote)
+				}
+			}
+		}
+	}
+	sort.Strings(values)
+
+	return values, cobra.ShellCompDirectiveNoFileComp
+}
+
+// fileDocument wraps the body of a file with its tracked metadata so that
+// filters can use e.g. `url` or `versions.remote`.
+func fileDocument(path string, f *File, body any) map[string]any {
+	doc := map[string]any{
+		"path": path,
+		"body": body,
+	}
+
+	if f != nil {
+		doc["url"] = f.URL
+		doc["etag"] = f.ETag
+		doc["last_modified"] = f.LastModified
+		doc["schema"] = f.Schema
+		doc["versions"] = map[string]any{
+			"local":  f.VersionLocal,
+			"remote": f.VersionRemote,
+		}
+		if d := f.Deprecation; d != nil {
+			doc["deprecation"] = map[string]any{
+				"deprecated": d.Deprecated,
+				"since":      d.Since,
+				"sunset":     d.Sunset,
+			}
 		}
 	}
 
-	return mexpr.NewInterpreter(ast, mexpr.UnquotedStrings)
+	return doc
 }
 
 // collectFiles gets a list of files to manipulate for a given command, taking
 // into account what was passed on the commandline, any filter matching options,
 // and whether to include files which have been deleted on disk but are still
 // present in the metadata index.
-func collectFiles(meta *Meta, args []string, match string, includeDeleted bool) []string {
+func collectFiles(meta *Meta, args []string, match string, ignoreCase, includeDeleted bool) []string {
 	if len(args) == 0 {
 		// No files passed in, so let's find them!
 		seen := map[string]bool{}
@@ -153,15 +246,15 @@ func collectFiles(meta *Meta, args []string, match string, includeDeleted bool)
 			// registry of one interpreter for each distinct type that has a schema.
 			i := interpreters[schema]
 			if i == nil {
-				interpreters[schema] = newInterpreter(match, schema)
+				interpreters[schema] = newInterpreter(match, schema, ignoreCase)
 				i = interpreters[schema]
 			}
 
 			var v any
-			b, _ := afero.ReadFile(afs, path)
+			b, _ := readLocal(path)
 			json.Unmarshal(b, &v)
 			result, err := i.Run(v)
-			if err != nil || result == nil || isFalsey(result) {
+			if err != nil || result == nil || cli.IsFalsey(result) {
 				// Skip!
 				continue
 			}
@@ -189,18 +282,61 @@ func loadMeta(meta *Meta) error {
 func mustLoadMeta() *Meta {
 	var m Meta
 	panicOnErr(loadMeta(&m))
+	m.applyUserAgent()
 	return &m
 }
 
+// status refreshes the remote versions, via the index or `HEAD` requests, and
+// returns the local and remote changes.
+func (m *Meta) status(ctx context.Context, opts StatusOptions) ([]changedFile, []changedFile, error) {
+	m.treat404AsGone = opts.Treat404AsGone
+	m.cachedIndex = time.Time{}
+	files := collectFiles(m, []string{}, "", false, false)
+	if opts.Head {
+		parallel := opts.Parallel
+		if parallel <= 0 {
+			parallel = defaultParallel
+		}
+		return m.GetChangedHead(ctx, files, parallel)
+	}
+
+	local, remote, err := m.GetChanged(ctx, files)
+	var indexErr *cli.ErrHTTP
+	if err != nil && !opts.NoFallback && errors.As(err, &indexErr) && indexErr.Temporary() {
+		// The remote versions from the last refresh are still in the metadata
+		// since the failed fetch didn't clear them.
+		refreshed := m.IndexRefreshed
+		if refreshed.IsZero() {
+			refreshed = m.LastPull
+		}
+		if !refreshed.IsZero() {
+			m.cachedIndex = refreshed
+			local, remote = m.changed(files)
+			return local, remote, nil
+		}
+	}
+	return local, remote, err
+}
+
 // getStatus displays the current status of the checkout, including both
 // remote and local changes.
-func getStatus() error {
+func getStatus(ctx context.Context, opts StatusOptions) error {
 	meta := mustLoadMeta()
-	local, remote, err := meta.GetChanged(collectFiles(meta, []string{}, "", false))
+
+	if meta.Initializing {
+		fmt.Fprintln(cli.Stdout, "Init was interrupted, run `bulk init --resume` to finish it.")
+	}
+	defer meta.noteDeprecations()
+
+	local, remote, err := meta.status(ctx, opts)
 	if err != nil {
 		return err
 	}
 
+	if !meta.cachedIndex.IsZero() {
+		fmt.Fprintf(cli.Stdout, "Index unavailable, using the cached index from %s (%s ago)\n  (remote changes since then are not shown, use --no-fallback to fail instead)\n", meta.cachedIndex.Local().Format("2006-01-02 15:04:05"), time.Since(meta.cachedIndex).Round(time.Second))
+	}
+
 	if len(remote) > 0 {
 		fmt.Fprintf(cli.Stdout, "Remote changes on %s\n  (use \"%s bulk pull\" to update)\n", meta.URL, os.Args[0])
 		for _, changed := range remote {
@@ -220,101 +356,160 @@ func getStatus() error {
 		fmt.Fprintln(cli.Stdout, changed)
 	}
 
+	if len(meta.FailedDeletes) > 0 {
+		meta.printFailedDeletes()
+	}
+
 	return nil
 }
 
-// diff a single file
-func diff(originalPath, modifiedPath string, original, modified []byte) {
-	var parsedOrig, parsedMod any
-	var err error
-
-	if len(original) > 0 {
-		if err := json.Unmarshal(original, &parsedOrig); err != nil {
-			cli.LogWarning("Unable to parse %s: %s", originalPath, err)
-			return
-		}
-		original, err = cli.MarshalShort("json", true, parsedOrig)
-		panicOnErr(err)
+// prettyJSON formats a JSON document for diffing, or returns it as-is if it
+// is empty.
+func prettyJSON(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return b, nil
+	}
+	var parsed any
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		return nil, err
 	}
+	return cli.MarshalShort("json", true, parsed)
+}
 
-	if len(modified) > 0 {
-		if err := json.Unmarshal(modified, &parsedMod); err != nil {
-			cli.LogWarning("Unable to parse %s: %s", modifiedPath, err)
-			return
-		}
-		modified, err = cli.MarshalShort("json", true, parsedMod)
-		panicOnErr(err)
+// diff a single file, colorized if enabled and writing to the terminal.
+func diff(w io.Writer, originalPath, modifiedPath string, original, modified []byte) {
+	var err error
+	if original, err = prettyJSON(original); err != nil {
+		cli.LogWarning("Unable to parse %s: %s", originalPath, err)
+		return
+	}
+	if modified, err = prettyJSON(modified); err != nil {
+		cli.LogWarning("Unable to parse %s: %s", modifiedPath, err)
+		return
 	}
 
 	edits := myers.ComputeEdits(span.URIFromPath("remote"), string(original), string(modified))
 
 	if len(edits) == 0 {
-		fmt.Fprintln(cli.Stdout, "No changes made.")
+		fmt.Fprintln(w, "No changes made.")
 		return
 	} else {
 		diff := fmt.Sprint(gotextdiff.ToUnified(originalPath, modifiedPath, string(original), edits))
-		if viper.GetBool("color") {
+		if viper.GetBool("color") && w == cli.Stdout {
 			d, _ := cli.Highlight("diff", []byte(diff))
 			diff = string(d)
 		}
-		fmt.Fprintln(cli.Stdout, diff)
+		fmt.Fprintln(w, diff)
 	}
 }
 
-// getLocalDiffs for the given set of file paths. Displays one diff per file
-// without any separators.
-func getLocalDiffs(meta *Meta, files []string) error {
-	changed := false
+// getLocalDiffs renders the diffs of the given set of file paths.
+func getLocalDiffs(ctx context.Context, meta *Meta, files []string, r diffRenderer) error {
 	for _, path := range files {
 		var orig []byte
+		change := "added"
+		url := meta.Base + strings.TrimSuffix(path, filepath.Ext(path))
 		if f, ok := meta.Files[path]; ok {
 			if !f.IsChangedLocal(false) {
 				continue
 			}
-			orig, _ = f.Fetch()
+			orig, _ = f.Fetch(ctx, meta)
+			change = "modified"
+			url = f.URL
+		}
+		modified, err := readLocal(path)
+		if err != nil {
+			change = "removed"
+		}
+		d := fileDiff{Path: path, URL: url, Change: change, from: "remote " + meta.Base + strings.TrimSuffix(path, ".json"), to: "local " + path, before: orig, after: modified}
+		if err := r.file(d); err != nil {
+			return err
 		}
-		changed = true
-		modified, _ := afero.ReadFile(afs, path)
-		diff("remote "+meta.Base+strings.TrimSuffix(path, ".json"), "local "+path, orig, modified)
 	}
 
-	if !changed {
-		fmt.Fprintln(cli.Stdout, "No local changes")
+	return r.done("No local changes")
+}
+
+// resetDryRun shows a diff of what resetting the given set of file paths
+// would throw away, without writing anything.
+func resetDryRun(meta *Meta, files []string) error {
+	count := 0
+	for _, path := range files {
+		f, ok := meta.Files[path]
+		if !ok || f.VersionLocal == "" || !f.IsChangedLocal(false) {
+			continue
+		}
+		count++
+		local, _ := readLocal(path)
+		cached, err := afero.ReadFile(afs, filepath.Join(metaDir, f.Path))
+		if err != nil {
+			return err
+		}
+		diff(cli.Stdout, "local "+path, "reset "+path, local, cached)
+	}
+
+	if count == 0 {
+		fmt.Fprintln(cli.Stdout, "No local changes to reset")
+		return nil
 	}
 
+	fmt.Fprintf(cli.Stdout, "%s would be reset\n", pluralize(count, "file"))
 	return nil
 }
 
-// getRemoteDiffs shows a diff for all the changed remote files.
-func getRemoteDiffs(meta *Meta) error {
-	_, remote, err := meta.GetChanged(collectFiles(meta, []string{}, "", true))
+// getRemoteDiffs renders the diffs of all the changed remote files.
+func getRemoteDiffs(ctx context.Context, meta *Meta, r diffRenderer) error {
+	_, remote, err := meta.GetChanged(ctx, collectFiles(meta, []string{}, "", false, true))
 	if err != nil {
 		return err
 	}
 
-	if len(remote) == 0 {
-		fmt.Fprintln(cli.Stdout, "No remote changes")
-		return nil
-	}
-
-	for _, f := range remote {
-		path := f.File.Path
-		modified, _ := f.File.Fetch()
-		orig, _ := afero.ReadFile(afs, path)
-		diff("local "+path, "remote "+meta.Base+strings.TrimSuffix(path, ".json"), orig, modified)
+	for _, changed := range remote {
+		path := changed.File.Path
+		var modified []byte
+		if changed.Status != statusRemoved {
+			modified, _ = changed.File.Fetch(ctx, meta)
+		}
+		orig, _ := readLocal(path)
+		d := fileDiff{Path: path, URL: changed.File.URL, Change: changed.Status.label(), from: "local " + path, to: "remote " + meta.Base + strings.TrimSuffix(path, ".json"), before: orig, after: modified}
+		if err := r.file(d); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return r.done("No remote changes")
 }
 
+// bulkCommand is the name of the running bulk command, used in the default
+// User-Agent. It is set for each run, while checkouts used via the Go API
+// pass their method name instead, see `Checkout.use`.
+var bulkCommand string
+
 // Init the bulk commands given a parent command.
 func Init(cmd *cobra.Command) {
 	bulk := cobra.Command{
 		GroupID: "generic",
 		Use:     "bulk",
 		Short:   "Client-side bulk resource management https://rest.sh/#/bulk",
-		Example: "  " + os.Args[0] + " bulk init api.rest.sh/books\n  " + os.Args[0] + " bulk list -m 'rating_average >= 4.8'\n  " + os.Args[0] + " bulk status",
+		Example: "  " + os.Args[0] + " bulk init api.rest.sh/books\n  " + os.Args[0] + " bulk list -m 'rating_average >= 4.8'\n  " + os.Args[0] + " bulk status\n  " + os.Args[0] + " bulk -C books status",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			if cli.Root.PersistentPreRun != nil {
+				cli.Root.PersistentPreRun(cmd, args)
+			}
+			if name, _ := cmd.Flags().GetString("workspace"); name != "" {
+				panicOnErr(changeWorkspace(name))
+			}
+
+			// Identify requests by the top-level bulk command, e.g. `config`
+			// for `bulk config set`.
+			for cmd.HasParent() && cmd.Parent().Name() != "bulk" {
+				cmd = cmd.Parent()
+			}
+			bulkCommand = cmd.Name()
+			cli.UserAgent = (&Meta{}).userAgent(bulkCommand)
+		},
 	}
+	bulk.PersistentFlags().StringP("workspace", "C", "", "Run in a registered checkout by name, see `bulk workspaces`")
 
 	bulk.AddGroup(
 		&cobra.Group{ID: "init", Title: "Start Here:"},
@@ -325,7 +520,7 @@ func Init(cmd *cobra.Command) {
 
 	init := cobra.Command{
 		GroupID:    "init",
-		Use:        "init URL [-f filter] [--url-template tmpl]",
+		Use:        "init [URL [-f filter] [--url-template tmpl] [--schema-field name] [--read-only] | --from-file file | --resume]",
 		Aliases:    []string{"i"},
 		SuggestFor: []string{"checkout", "co", "clone", "cl"},
 		Short:      "Initialize a new bulk checkout. Start here.",
@@ -340,34 +535,79 @@ func Init(cmd *cobra.Command) {
     "version": "..."
   }
 ]
-` + "```\n\nThe following fields will automatically be found and used:\n\n- Resource URL: `url`, `uri`, `self`, `link`\n- Resource version: `version`, `etag`, `last_modified`, `lastModified`, `modified`.\n\nFiltering (if used) runs *before* URL template rendering.\n\nRestish assumes resources have client-generated IDs and use HTTP `PUT`, but if that's not the case then you can still create new resources manually with `restish POST ...`.",
-		Args:    cobra.ExactArgs(1),
+` + "```\n\nThe following fields will automatically be found and used:\n\n- Resource URL: `url`, `uri`, `self`, `link`\n- Resource version: `version`, `etag`, `last_modified`, `lastModified`, `modified`.\n\nFiltering (if used) runs *before* URL template rendering.\n\nRestish assumes resources have client-generated IDs and use HTTP `PUT`, but if that's not the case then you can still create new resources manually with `restish POST ...`.\n\nResources which aren't listed by any index can be tracked explicitly with `--from-file`, which takes a file with one URL per line or a JSON array of `{url, path}` objects. Such checkouts find remote changes via conditional and `HEAD` requests for each file, and `bulk track URL [path]` adds more resources later.\n\nProgress is saved as files are fetched, so an init which was interrupted (e.g. via Ctrl-C) or had failures continues where it stopped when run again, or via `--resume`.",
+		Args:    cobra.MaximumNArgs(1),
 		Example: "  " + os.Args[0] + " bulk init api.example.com/users -f 'body.{url, version: last_login}'\n  " + os.Args[0] + " bulk init api.example.com/users -f 'body.{id, version: last_login}' --url-template='/users/{id}'",
 		Run: func(cmd *cobra.Command, args []string) {
 			var m Meta
 			loadMeta(&m)
 			template, _ := cmd.Flags().GetString("url-template")
-			panicOnErr(m.Init(args[0], template))
+			name, _ := cmd.Flags().GetString("name")
+			fromFile, _ := cmd.Flags().GetString("from-file")
+			resume, _ := cmd.Flags().GetBool("resume")
+			if m.Initializing && !resume {
+				// Re-running the same init picks up where it stopped.
+				resume = (fromFile != "" && m.NoIndex) || (len(args) > 0 && !m.NoIndex && cli.FixAddress(args[0]) == m.URL)
+			}
+			ctx, stop := interruptContext(cmd.Context())
+			defer stop()
+			if resume {
+				m.applyUserAgent()
+				panicOnErr(m.Resume(ctx))
+				return
+			}
+			m.ReadOnly, _ = cmd.Flags().GetBool("read-only")
+			if fromFile != "" {
+				if len(args) > 0 {
+					panic("a URL can't be used with --from-file")
+				}
+				entries, err := readURLList(fromFile)
+				panicOnErr(err)
+				if len(entries) == 0 {
+					panic("no URLs to track in " + fromFile)
+				}
+				panicOnErr(registerWorkspace(name, cli.FixAddress(entries[0].URL)))
+				panicOnErr(m.InitURLs(ctx, entries))
+				return
+			}
+			if len(args) == 0 {
+				panic("a URL or --from-file is required")
+			}
+			panicOnErr(registerWorkspace(name, cli.FixAddress(args[0])))
+			m.skipPreflight, _ = cmd.Flags().GetBool("skip-preflight")
+			m.SchemaField, _ = cmd.Flags().GetString("schema-field")
+			panicOnErr(m.Init(ctx, args[0], template))
 		},
 	}
 	init.Flags().String("url-template", "", "URL template to build links (e.g. from item IDs)")
+	init.Flags().String("schema-field", "", "Index entry field with the URL of each item's JSON Schema, relative to the index URL")
+	init.Flags().Bool("skip-preflight", false, "Don't check the URL template against a few index entries before fetching every file")
+	init.Flags().String("from-file", "", "Track the resource URLs listed in a file instead of using an index")
+	init.Flags().String("name", "", "Workspace name to register the checkout as, defaults to the directory name")
+	init.Flags().Bool("read-only", false, "Disable pushing from the checkout, see `bulk config`")
+	init.Flags().Bool("resume", false, "Continue an interrupted init, skipping files which were already fetched")
 
 	list := cobra.Command{
 		GroupID: "info",
-		Use:     "list [--match expr] [-f filter]",
+		Use:     "list [--match expr [-i]] [-f filter [--rsh-filter-full]]",
 		Aliases: []string{"ls"},
 		Short:   "List checked out files",
 		Args:    cobra.NoArgs,
-		Example: "  " + os.Args[0] + " bulk list -m 'id contains abc'\n  " + os.Args[0] + " bulk list -m 'reviews where rating > 4'",
+		Example: "  " + os.Args[0] + " bulk list -m 'id contains abc'\n  " + os.Args[0] + " bulk list -m 'reviews where rating > 4'\n  " + os.Args[0] + " bulk list -f '{url, remote: versions.remote, id: body.id}' --rsh-filter-full",
 		Run: func(cmd *cobra.Command, args []string) {
 			match, _ := cmd.Flags().GetString("match")
-			for _, path := range collectFiles(mustLoadMeta(), args, match, false) {
+			ignoreCase, _ := cmd.Flags().GetBool("ignore-case")
+			meta := mustLoadMeta()
+			for _, path := range collectFiles(meta, args, match, ignoreCase, false) {
 				if filter := viper.GetString("rsh-filter"); filter != "" {
 					var content any
-					b, err := afero.ReadFile(afs, path)
+					b, err := readLocal(path)
 					panicOnErr(err)
 					if err := json.Unmarshal(b, &content); err == nil {
-						if res, _, err := shorthand.GetPath(filter, content, shorthand.GetOptions{}); err == nil && !isFalsey(res) {
+						if viper.GetBool("rsh-filter-full") {
+							content = fileDocument(path, meta.Files[path], content)
+						}
+						if res, _, err := shorthand.GetPath(filter, content, shorthand.GetOptions{}); err == nil && !cli.IsFalsey(res) {
 							fmt.Fprintln(cli.Stdout, path)
 							b, _ := json.MarshalIndent(res, "", "  ")
 							if viper.GetBool("color") {
@@ -384,6 +624,8 @@ func Init(cmd *cobra.Command) {
 		},
 	}
 	list.Flags().StringP("match", "m", "", "Expression to match")
+	list.RegisterFlagCompletionFunc("match", completeMatch)
+	list.Flags().BoolP("ignore-case", "i", false, "Compare strings and property names case-insensitively when matching")
 
 	pull := cobra.Command{
 		GroupID: "remote",
@@ -392,9 +634,30 @@ func Init(cmd *cobra.Command) {
 		Short:   "Pull remote updates. Does not overwrite local changes.",
 		Args:    cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			panicOnErr(mustLoadMeta().Pull())
+			if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+				panicOnErr(mustLoadMeta().PullDryRun(cmd.Context()))
+				return
+			}
+			checkout, err := Open("")
+			panicOnErr(err)
+			var opts PullOptions
+			opts.Treat404AsGone, _ = cmd.Flags().GetBool("treat-404-as-gone")
+			opts.Rate, _ = cmd.Flags().GetFloat64("rate")
+			opts.PreserveComments, _ = cmd.Flags().GetBool("preserve-comments")
+			ctx, stop := interruptContext(cmd.Context())
+			defer stop()
+			_, err = checkout.Pull(ctx, opts)
+			writeMetrics(cmd, checkout.meta.report, err)
+			notify(cmd, checkout.meta.report, err)
+			panicOnErr(err)
 		},
 	}
+	pull.Flags().Bool("dry-run", false, "Show the requests that would be made without making them")
+	pull.Flags().Bool("treat-404-as-gone", false, "Remove files which respond with 404 Not Found like 410 Gone")
+	pull.Flags().Bool("preserve-comments", false, "Keep comments in local files which are updated, where possible")
+	pull.Flags().Float64("rate", 0, "Maximum requests per second, overriding the pacing from RateLimit headers")
+	addNotifyFlags(&pull)
+	addMetricsFlags(&pull)
 
 	status := cobra.Command{
 		GroupID: "info",
@@ -403,38 +666,69 @@ func Init(cmd *cobra.Command) {
 		Short:   "Show the local & remote added/changed/removed files",
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return getStatus()
+			opts := StatusOptions{}
+			opts.Head, _ = cmd.Flags().GetBool("head")
+			opts.Parallel, _ = cmd.Flags().GetInt("parallel")
+			opts.Treat404AsGone, _ = cmd.Flags().GetBool("treat-404-as-gone")
+			opts.NoFallback, _ = cmd.Flags().GetBool("no-fallback")
+			return getStatus(cmd.Context(), opts)
 		},
 	}
+	status.Flags().Bool("head", false, "Check each file with a HEAD request instead of fetching the index")
+	status.Flags().Int("parallel", defaultParallel, "Number of HEAD requests to send at once with --head")
+	status.Flags().Bool("treat-404-as-gone", false, "Show files which respond with 404 Not Found to --head as removed like 410 Gone")
+	status.Flags().Bool("no-fallback", false, "Fail instead of using the cached index when the index is rate limited or has a server error")
 
 	diff := cobra.Command{
 		GroupID: "info",
-		Use:     "diff [file... | --match expr | --remote]",
+		Use:     "diff [file... | --match expr | --remote] [--format json|html] [--output file]",
 		Aliases: []string{"di"},
 		Short:   "Show a diff of local or remote changed files",
 		Run: func(cmd *cobra.Command, args []string) {
 			match, _ := cmd.Flags().GetString("match")
 			remote, _ := cmd.Flags().GetBool("remote")
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+			var w io.Writer = cli.Stdout
+			buf := &bytes.Buffer{}
+			if output != "" {
+				w = buf
+			}
+			renderer, err := newDiffRenderer(format, w)
+			panicOnErr(err)
 			meta := mustLoadMeta()
 			if remote {
-				panicOnErr(getRemoteDiffs(meta))
+				panicOnErr(getRemoteDiffs(cmd.Context(), meta, renderer))
 			} else {
-				panicOnErr(getLocalDiffs(meta, collectFiles(meta, args, match, true)))
+				panicOnErr(getLocalDiffs(cmd.Context(), meta, collectFiles(meta, args, match, false, true), renderer))
+			}
+			if output != "" {
+				panicOnErr(writeDiffOutput(output, buf.Bytes()))
 			}
 		},
 	}
 	diff.Flags().StringP("match", "m", "", "Expression to match")
+	diff.RegisterFlagCompletionFunc("match", completeMatch)
 	diff.Flags().Bool("remote", false, "Show remote diffs instead of local")
+	diff.Flags().String("format", "text", "Output format, either text, json with the changes of each file by JSON pointer, or a self-contained html report")
+	diff.Flags().String("output", "", "Write the diff to this file instead of the terminal, e.g. review.html")
+	diff.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return diffFormats(), cobra.ShellCompDirectiveNoFileComp
+	})
 
 	reset := cobra.Command{
 		GroupID: "local",
-		Use:     "reset [file... | --match expr]",
+		Use:     "reset [file... | --match expr] [--dry-run]",
 		Aliases: []string{"re"},
 		Short:   "Undo local changes to files",
 		Run: func(cmd *cobra.Command, args []string) {
 			meta := mustLoadMeta()
 			match, _ := cmd.Flags().GetString("match")
-			for _, name := range collectFiles(meta, args, match, true) {
+			if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+				panicOnErr(resetDryRun(meta, collectFiles(meta, args, match, false, true)))
+				return
+			}
+			for _, name := range collectFiles(meta, args, match, false, true) {
 				if f, ok := meta.Files[name]; ok && f.VersionLocal != "" {
 					panicOnErr(f.Reset())
 				}
@@ -443,6 +737,8 @@ func Init(cmd *cobra.Command) {
 		},
 	}
 	reset.Flags().StringP("match", "m", "", "Expression to match")
+	reset.RegisterFlagCompletionFunc("match", completeMatch)
+	reset.Flags().Bool("dry-run", false, "Show a diff of the local changes that would be lost without resetting anything")
 
 	push := cobra.Command{
 		GroupID: "remote",
@@ -451,10 +747,60 @@ func Init(cmd *cobra.Command) {
 		Short:   "Upload local changes to the remote server",
 		Args:    cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
+			checkout, err := Open("")
+			panicOnErr(err)
+			meta := checkout.meta
+			if header, _ := cmd.Flags().GetString("message-header"); header != "" {
+				meta.MessageHeader = header
+			}
+			if method, _ := cmd.Flags().GetString("update-method"); method != "" {
+				meta.UpdateMethod = strings.ToUpper(method)
+			}
+			if method, _ := cmd.Flags().GetString("create-method"); method != "" {
+				meta.CreateMethod = strings.ToUpper(method)
+			}
+			if method, _ := cmd.Flags().GetString("delete-method"); method != "" {
+				meta.DeleteMethod = strings.ToUpper(method)
+			}
+			if header, _ := cmd.Flags().GetString("method-override-header"); header != "" {
+				meta.MethodOverrideHeader = header
+			}
+			var opts PushOptions
+			opts.Message, _ = cmd.Flags().GetString("message")
+			if opts.Message == "" {
+				opts.Message = os.Getenv(messageEnv)
+			}
+			if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+				meta.message = opts.Message
+				panicOnErr(meta.PushDryRun(cmd.Context()))
+				return
+			}
 			// TODO: limit, pause-every, wait-between, concurrent, etc to control uploads?
-			panicOnErr(mustLoadMeta().Push())
+			if key, _ := cmd.Flags().GetString("sign-key"); key != "" {
+				meta.SignKey = key
+			}
+			opts.Rate, _ = cmd.Flags().GetFloat64("rate")
+			opts.Interactive, _ = cmd.Flags().GetBool("interactive")
+			ctx, stop := interruptContext(cmd.Context())
+			defer stop()
+			_, err = checkout.Push(ctx, opts)
+			writeMetrics(cmd, meta.report, err)
+			notify(cmd, meta.report, err)
+			panicOnErr(err)
 		},
 	}
+	push.Flags().Bool("dry-run", false, "Show the requests that would be made without making them")
+	push.Flags().Float64("rate", 0, "Maximum requests per second, overriding the pacing from RateLimit headers")
+	push.Flags().BoolP("interactive", "i", false, "Choose which changed files to push, showing their diffs on request")
+	addNotifyFlags(&push)
+	addMetricsFlags(&push)
+	push.Flags().String("sign-key", "", "SSH private key to sign push records with, saved for later pushes")
+	push.Flags().StringP("message", "m", "", "Reason for the change, sent with each request and saved in the push record (env: "+messageEnv+")")
+	push.Flags().String("message-header", "", "Request header for the message, saved for later pushes (default "+defaultMessageHeader+")")
+	push.Flags().String("update-method", "", "HTTP method to upload modified files with, saved for later pushes (default PUT)")
+	push.Flags().String("create-method", "", "HTTP method to upload added files with, saved for later pushes (default PUT)")
+	push.Flags().String("delete-method", "", "HTTP method to delete removed files with, saved for later pushes (default DELETE)")
+	push.Flags().String("method-override-header", "", "Request header to send the default method in when another method is configured, e.g. X-HTTP-Method-Override, saved for later pushes")
 
 	bulk.AddCommand(&init)
 	bulk.AddCommand(&list)
@@ -463,6 +809,13 @@ func Init(cmd *cobra.Command) {
 	bulk.AddCommand(&diff)
 	bulk.AddCommand(&reset)
 	bulk.AddCommand(&push)
+	bulk.AddCommand(trackCommands()...)
+	bulk.AddCommand(verifyPushCommand())
+	bulk.AddCommand(verifyRemoteCommand())
+	bulk.AddCommand(statsCommand())
+	bulk.AddCommand(syncCommand())
+	bulk.AddCommand(workspacesCommand())
+	bulk.AddCommand(configCommand())
 
 	cmd.AddCommand(&bulk)
 }
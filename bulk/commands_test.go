@@ -1,27 +1,48 @@
 package bulk
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/http"
 	"os"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/afero"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 	"github.com/tarunKoyalwar/restish/cli"
+	"golang.org/x/crypto/ssh"
 	"gopkg.in/h2non/gock.v1"
 )
 
 func run(cmd ...string) (string, error) {
+	return runContext(context.Background(), cmd...)
+}
+
+// runContext runs a command which can be cancelled via the context, like
+// pressing Ctrl-C.
+func runContext(ctx context.Context, cmd ...string) (string, error) {
 	capture := &strings.Builder{}
 	cli.Stdout = capture
 	cli.Stderr = capture
 	cli.Root.SetOut(capture)
 	os.Args = append([]string{"restish"}, cmd...)
-	err := cli.Run()
+	err := cli.RunContext(ctx)
 
 	return capture.String(), err
 }
@@ -70,7 +91,11 @@ type remoteFile struct {
 	fetch   bool
 }
 
+// remoteIndex holds the files of the last expected index.
+var remoteIndex []remoteFile
+
 func expectRemote(files []remoteFile) {
+	remoteIndex = files
 	gock.New("https://example.com").
 		Get("/all-items").
 		Reply(http.StatusOK).
@@ -83,6 +108,23 @@ func expectRemote(files []remoteFile) {
 	}
 }
 
+// expectPreflight expects the URL template check of an init against the
+// given index entries, or the last expected index if none are given.
+func expectPreflight(files ...remoteFile) {
+	if len(files) == 0 {
+		files = remoteIndex
+	}
+
+	for i, f := range files {
+		if i == preflightSample {
+			break
+		}
+		gock.New("https://example.com").
+			Head("/users/" + f.User + "/items/" + f.ID).
+			Reply(http.StatusOK)
+	}
+}
+
 func expectRemoteFile(f remoteFile) {
 	body := f.body
 	if body == "" {
@@ -130,6 +172,7 @@ func TestWorkflow(t *testing.T) {
 
 	// Init
 	// ====
+	expectPreflight()
 	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
 
 	mustExist(t, ".rshbulk")
@@ -170,6 +213,15 @@ func TestWorkflow(t *testing.T) {
 	require.Contains(t, out, `"b1"`)
 	require.Contains(t, out, `"c1"`)
 
+	// List with filter including file metadata
+	// ----------------------------------------
+	gock.Flush()
+	out, err = run("bulk", "list", "-m", "", "-f", "{url, remote: versions.remote, id: body.id}", "--rsh-filter-full")
+	require.NoError(t, err)
+	require.Contains(t, out, `"https://example.com/users/a/items/a1"`)
+	require.Contains(t, out, `"a11"`)
+	require.Contains(t, out, `"a1"`)
+
 	// Remote files changed
 	// --------------------
 	gock.Flush()
@@ -225,8 +277,10 @@ func TestWorkflow(t *testing.T) {
 		{User: "d", ID: "d1", Version: "d11", fetch: true},
 	})
 
-	_, err = run("-v", "bulk", "pull")
+	out, err = run("-v", "bulk", "pull")
 	require.NoError(t, err)
+	require.Contains(t, out, "Pulled b/items/b1.json in ")
+	require.Contains(t, out, "Timings for GET https://example.com/users/b/items/b1:")
 	mustContain(t, ".rshbulk/meta", "a21")
 	mustContain(t, ".rshbulk/meta", "b12")
 	mustContain(t, ".rshbulk/meta", "d11")
@@ -285,8 +339,8 @@ func TestWorkflow(t *testing.T) {
 
 	expectRemoteFile(remoteFile{User: "a", ID: "a1", Version: "a11"})
 
-	// Ugh, flag state is saved between runs, so explicitly set to false here.
-	out, err = run("bulk", "diff", "--remote=false")
+	// Flags are reset between runs, so this doesn't inherit `--remote`.
+	out, err = run("bulk", "diff")
 	require.NoError(t, err)
 	require.Contains(t, out, "--- remote https://example.com/users/a/items/a1")
 	require.Contains(t, out, "+++ local a/items/a1.json")
@@ -380,6 +434,7 @@ func TestPullFailure(t *testing.T) {
 
 	// Init
 	// ====
+	expectPreflight()
 	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
 
 	mustExist(t, ".rshbulk")
@@ -409,6 +464,39 @@ func TestPullFailure(t *testing.T) {
 	mustHaveCalledAllHTTPMocks(t)
 }
 
+// TestPullHTML checks that an HTML page from e.g. a misconfigured gateway is
+// treated as a failed fetch rather than being written into a file.
+func TestPullHTML(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: false},
+	})
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a2").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "text/html").
+		BodyString("<html><head><title>Sign in</title></head><body><p>Please sign in</p></body></html>")
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	out, _ := run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	require.Contains(t, out, "Error fetching a2.json")
+	require.Contains(t, out, `HTML page "Sign in"`)
+
+	mustEqualJSON(t, "a1.json", `{"id": "a1"}`)
+	_, err := afs.Stat("a2.json")
+	require.Error(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+}
+
 func TestPushFailure(t *testing.T) {
 	defer gock.Off()
 
@@ -426,6 +514,7 @@ func TestPushFailure(t *testing.T) {
 
 	// Init
 	// ====
+	expectPreflight()
 	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
 
 	mustExist(t, ".rshbulk")
@@ -498,7 +587,7 @@ func TestPushFailure(t *testing.T) {
 func TestFalsey(t *testing.T) {
 	for _, item := range []any{false, 0, 0.0, "", []byte{}, []any{}, map[string]any{}, map[any]any{}} {
 		t.Run(fmt.Sprintf("%T-%+v", item, item), func(t *testing.T) {
-			require.True(t, isFalsey(item))
+			require.True(t, cli.IsFalsey(item))
 		})
 	}
 }
@@ -533,7 +622,7 @@ func TestInterpreterWithSchema(t *testing.T) {
 	capture := &strings.Builder{}
 	cli.Stdout = capture
 	cli.Stderr = capture
-	newInterpreter("trinkets where age > 5", "https://example.com/schemas/user.json")
+	newInterpreter("trinkets where age > 5", "https://example.com/schemas/user.json", false)
 	require.NotContains(t, capture.String(), "WARN")
 }
 
@@ -567,7 +656,7 @@ func TestInterpreterWithSchemaWarning(t *testing.T) {
 	capture := &strings.Builder{}
 	cli.Stdout = capture
 	cli.Stderr = capture
-	newInterpreter("name > 5", "https://example.com/schemas/user.json")
+	newInterpreter("name > 5", "https://example.com/schemas/user.json", false)
 	require.Contains(t, capture.String(), "WARN: cannot compare string with number")
 }
 
@@ -581,7 +670,7 @@ func TestInterpreterWithSchema404(t *testing.T) {
 	capture := &strings.Builder{}
 	cli.Stdout = capture
 	cli.Stderr = capture
-	newInterpreter("name contains foo", "https://example.com/schemas/user.json")
+	newInterpreter("name contains foo", "https://example.com/schemas/user.json", false)
 	require.NotContains(t, capture.String(), "WARN")
 }
 
@@ -597,6 +686,2944 @@ func TestInterpreterWithSchemaInvalid(t *testing.T) {
 	capture := &strings.Builder{}
 	cli.Stdout = capture
 	cli.Stderr = capture
-	newInterpreter("name contains foo", "https://example.com/schemas/user.json")
+	newInterpreter("name contains foo", "https://example.com/schemas/user.json", false)
+	require.NotContains(t, capture.String(), "WARN")
+}
+
+const enumSchema = `{
+	"type": "object",
+	"properties": {
+		"status": {
+			"type": "string",
+			"enum": ["active", "inactive", "pending"]
+		},
+		"reviews": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"state": {"type": "string", "enum": ["open", "closed"]}
+				}
+			}
+		}
+	}
+}`
+
+func TestInterpreterWithSchemaEnum(t *testing.T) {
+	defer gock.Off()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+
+	gock.New("https://example.com").
+		Get("/schemas/user.json").
+		Times(2).
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		BodyString(enumSchema)
+
+	capture := &strings.Builder{}
+	cli.Stdout = capture
+	cli.Stderr = capture
+	newInterpreter("status == activ or reviews where state != \"opn\"", "https://example.com/schemas/user.json", false)
+	require.Contains(t, capture.String(), "WARN: 'activ' is not one of [active, inactive, pending]")
+	require.Contains(t, capture.String(), "WARN: 'opn' is not one of [open, closed]")
+
+	capture.Reset()
+	newInterpreter("status == active and reviews where state == closed", "https://example.com/schemas/user.json", false)
 	require.NotContains(t, capture.String(), "WARN")
 }
+
+func TestCompleteMatch(t *testing.T) {
+	defer gock.Off()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+
+	gock.New("https://example.com").
+		Get("/schemas/user.json").
+		Persist().
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		BodyString(enumSchema)
+
+	afs = afero.NewMemMapFs()
+	meta := &Meta{Files: map[string]*File{
+		"a.json": {Path: "a.json", Schema: "https://example.com/schemas/user.json"},
+		"b.json": {Path: "b.json"},
+	}}
+	require.NoError(t, meta.Save())
+
+	values, _ := completeMatch(nil, nil, "status == ")
+	require.Equal(t, []string{"status == active", "status == inactive", "status == pending"}, values)
+
+	values, _ = completeMatch(nil, nil, "id > 1 and status != \"in")
+	require.Equal(t, []string{"id > 1 and status != \"inactive\""}, values)
+
+	values, _ = completeMatch(nil, nil, "reviews where state == c")
+	require.Equal(t, []string{"reviews where state == closed"}, values)
+
+	values, _ = completeMatch(nil, nil, "unknown == ")
+	require.Empty(t, values)
+
+	values, _ = completeMatch(nil, nil, "status contains ")
+	require.Empty(t, values)
+}
+
+func TestDryRun(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+
+	// Only the index should be fetched, nothing gets pushed.
+	gock.Flush()
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a22"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	out, err := run("bulk", "push", "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, out, "PUT https://example.com/users/a/items/a1")
+	require.NotContains(t, out, "PUT https://example.com/users/a/items/a2")
+	mustHaveCalledAllHTTPMocks(t)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a22"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	out, err = run("bulk", "pull", "--dry-run", "--rsh-curl")
+	require.NoError(t, err)
+	require.Contains(t, out, "curl 'https://example.com/users/a/items/a2'")
+	require.NotContains(t, out, "curl 'https://example.com/users/a/items/a1'")
+	mustHaveCalledAllHTTPMocks(t)
+	mustEqualJSON(t, "a/items/a2.json", `{"id": "a2"}`)
+}
+
+func TestContentNegotiation(t *testing.T) {
+	defer gock.Off()
+	defer func() {
+		cli.Root.PersistentFlags().Set("rsh-accept", "")
+		cli.Root.PersistentFlags().Set("rsh-content-type", "")
+		viper.Set("rsh-accept", "")
+		viper.Set("rsh-content-type", "")
+	}()
+
+	gock.New("https://example.com").
+		Get("/all-items").
+		MatchHeader("Accept", "^application/json$").
+		Reply(http.StatusOK).
+		JSON([]remoteFile{{User: "a", ID: "a1", Version: "a11"}, {User: "a", ID: "a2", Version: "a21"}})
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a1").
+		MatchHeader("Accept", "^application/json$").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"id": "a1"}`)
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a2").
+		MatchHeader("Accept", "^application/json$").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"id": "a2"}`)
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight(remoteFile{User: "a", ID: "a1"}, remoteFile{User: "a", ID: "a2"})
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}", "--rsh-accept=application/json", "--rsh-content-type=application/yaml")
+	mustContain(t, ".rshbulk/meta", `"accept": "application/json"`)
+	mustContain(t, ".rshbulk/meta", `"content_type": "application/yaml"`)
+	mustEqualJSON(t, "a1.json", `{"id": "a1"}`)
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Later commands use the saved overrides without passing them again.
+	cli.Root.PersistentFlags().Set("rsh-accept", "")
+	cli.Root.PersistentFlags().Set("rsh-content-type", "")
+	viper.Set("rsh-accept", "")
+	viper.Set("rsh-content-type", "")
+
+	afero.WriteFile(afs, "a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+
+	gock.New("https://example.com").
+		Get("/all-items").
+		MatchHeader("Accept", "^application/json$").
+		Reply(http.StatusOK).
+		JSON([]remoteFile{{User: "a", ID: "a1", Version: "a11"}, {User: "a", ID: "a2", Version: "a21"}})
+
+	gock.New("https://example.com").
+		Put("/users/a/items/a1").
+		MatchHeader("Content-Type", "^application/yaml$").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, err := io.ReadAll(req.Body)
+			return string(body) == "id: a1\nlabels:\n- one\n", err
+		}).
+		Reply(http.StatusOK)
+
+	gock.New("https://example.com").
+		Get("/all-items").
+		Reply(http.StatusOK).
+		JSON([]remoteFile{{User: "a", ID: "a1", Version: "a12"}, {User: "a", ID: "a2", Version: "a21"}})
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a1").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"id": "a1", "labels": ["one"]}`)
+
+	out, err := run("bulk", "push")
+
+	require.NoError(t, err)
+	require.Contains(t, out, "Push complete")
+	mustHaveCalledAllHTTPMocks(t)
+
+	// The overrides only apply to the checkout's requests, not later runs.
+	require.Empty(t, viper.GetString("rsh-accept"))
+	require.Empty(t, viper.GetString("rsh-content-type"))
+}
+
+func TestTrackUntrack(t *testing.T) {
+	defer gock.Off()
+
+	all := []remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "b", ID: "b1", Version: "b11"},
+		{User: "c", ID: "c1", Version: "c11"},
+	}
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+		{User: "c", ID: "c1", Version: "c11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Untracking asks for confirmation before deleting local files.
+	orig := confirm
+	defer func() { confirm = orig }()
+	confirm = func(message string) bool {
+		require.Equal(t, "Untrack and delete 2 files locally?", message)
+		return false
+	}
+
+	_, err := run("bulk", "untrack", "b", "c/items/c1")
+	require.Error(t, err)
+	mustExist(t, "b/items/b1.json")
+
+	confirm = func(message string) bool { return true }
+	out, err := run("bulk", "untrack", "b", "c/items/c1")
+	require.NoError(t, err)
+	require.Contains(t, out, "Untracked 2 files")
+	_, err = afs.Stat("b/items/b1.json")
+	require.Error(t, err)
+	mustContain(t, ".rshbulk/meta", `"b/items/b1.json"`)
+
+	// Untracked resources don't show up in the status, even when changed
+	// remotely, and a recreated local file is never pushed.
+	afero.WriteFile(afs, "b/items/b1.json", []byte(`{"id": "b1", "local": true}`), 0600)
+
+	gock.New("https://example.com").
+		Get("/all-items").
+		Times(3).
+		Reply(http.StatusOK).
+		JSON([]remoteFile{all[0], all[1], {User: "b", ID: "b1", Version: "b12"}, all[3]})
+
+	out, err = run("bulk", "status")
+	require.NoError(t, err)
+	require.Contains(t, out, "You are up to date")
+	require.Contains(t, out, "No local changes")
+
+	out, err = run("bulk", "push")
+	require.NoError(t, err)
+	require.NotContains(t, out, "PUT")
+	require.NotContains(t, out, "Error uploading")
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Track by match expression on the list items.
+	afs.Remove("b/items/b1.json")
+
+	gock.New("https://example.com").
+		Get("/all-items").
+		Times(2).
+		Reply(http.StatusOK).
+		JSON(all)
+	expectRemoteFile(all[2])
+
+	out, err = run("bulk", "track", "-m", "user == b")
+	require.NoError(t, err)
+	mustEqualJSON(t, "b/items/b1.json", `{"id": "b1"}`)
+	_, err = afs.Stat("c/items/c1.json")
+	require.Error(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Track by path prefix.
+	gock.New("https://example.com").
+		Get("/all-items").
+		Times(2).
+		Reply(http.StatusOK).
+		JSON(all)
+	expectRemoteFile(all[3])
+
+	_, err = run("bulk", "track", "c")
+	require.NoError(t, err)
+	mustEqualJSON(t, "c/items/c1.json", `{"id": "c1"}`)
+	mustHaveCalledAllHTTPMocks(t)
+
+	_, err = run("bulk", "track", "c")
+	require.Error(t, err)
+}
+
+func TestResetDryRun(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	out, err := run("bulk", "reset", "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, out, "No local changes to reset")
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+	afs.Remove("a/items/a2.json")
+	afero.WriteFile(afs, "a/items/a3.json", []byte(`{"id": "a3"}`), 0600)
+
+	out, err = run("bulk", "reset", "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, out, "--- local a/items/a1.json\n+++ reset a/items/a1.json")
+	require.Contains(t, out, "-  \"labels\": [")
+	require.Contains(t, out, "+++ reset a/items/a2.json")
+	require.NotContains(t, out, "b/items/b1.json")
+	require.NotContains(t, out, "a/items/a3.json")
+	require.Contains(t, out, "2 files would be reset")
+
+	// Nothing was written.
+	mustEqualJSON(t, "a/items/a1.json", `{"id": "a1", "labels": ["one"]}`)
+	_, err = afs.Stat("a/items/a2.json")
+	require.Error(t, err)
+
+	out, err = run("bulk", "reset", "--dry-run", "a/items/a1.json")
+	require.NoError(t, err)
+	require.Contains(t, out, "1 file would be reset")
+}
+
+// TestPushFailedDelete ensures a failed remote deletion stays staged and is
+// retried on the next push, with a 404 counting as already deleted.
+func TestPushFailedDelete(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+		{User: "c", ID: "c1", Version: "c11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	afs.Remove("a/items/a2.json")
+	afs.Remove("b/items/b1.json")
+
+	// Push with a locked resource
+	// ---------------------------
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "b", ID: "b1", Version: "b11"},
+		{User: "c", ID: "c1", Version: "c11"},
+	})
+
+	gock.New("https://example.com").
+		Delete("/users/a/items/a2").
+		Reply(http.StatusLocked)
+
+	gock.New("https://example.com").
+		Delete("/users/b/items/b1").
+		Reply(http.StatusNoContent)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "c", ID: "c1", Version: "c11"},
+	})
+
+	out, err := run("bulk", "push")
+	require.NoError(t, err)
+	require.Contains(t, out, "Failed deletes:")
+	require.Contains(t, out, "423")
+	require.Contains(t, out, "a/items/a2.json")
+	require.Contains(t, out, "Push complete")
+	mustHaveCalledAllHTTPMocks(t)
+
+	meta := mustLoadMeta()
+	require.Contains(t, meta.Files, "a/items/a2.json")
+	require.NotContains(t, meta.Files, "b/items/b1.json")
+	require.Equal(t, map[string]int{"a/items/a2.json": http.StatusLocked}, meta.FailedDeletes)
+
+	// Status shows the failure
+	// ------------------------
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "c", ID: "c1", Version: "c11"},
+	})
+
+	out, err = run("bulk", "status")
+	require.NoError(t, err)
+	require.Contains(t, out, "removed")
+	require.Contains(t, out, "Failed deletes:")
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Retry where the resource is already gone
+	// ----------------------------------------
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "c", ID: "c1", Version: "c11"},
+	})
+
+	gock.New("https://example.com").
+		Delete("/users/a/items/a2").
+		Reply(http.StatusNotFound)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "c", ID: "c1", Version: "c11"},
+	})
+
+	out, err = run("bulk", "push")
+	require.NoError(t, err)
+	require.NotContains(t, out, "Failed deletes:")
+	mustHaveCalledAllHTTPMocks(t)
+
+	meta = mustLoadMeta()
+	require.NotContains(t, meta.Files, "a/items/a2.json")
+	require.Empty(t, meta.FailedDeletes)
+}
+
+func TestListIgnoreCase(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true, body: `{"id": "a1", "name": "Straße"}`},
+		{User: "b", ID: "b1", Version: "b11", fetch: true, body: `{"id": "b1", "name": "ılık"}`},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	out, err := run("bulk", "list", "-m", "ID contains A1")
+	require.NoError(t, err)
+	require.NotContains(t, out, "a/items/a1.json")
+
+	out, err = run("bulk", "list", "-i", "-m", "ID contains A1")
+	require.NoError(t, err)
+	require.Contains(t, out, "a/items/a1.json")
+	require.NotContains(t, out, "b/items/b1.json")
+
+	out, err = run("bulk", "list", "-i", "-m", "name == STRASSE")
+	require.NoError(t, err)
+	require.Contains(t, out, "a/items/a1.json")
+	require.NotContains(t, out, "b/items/b1.json")
+
+	out, err = run("bulk", "list", "-i", "-m", "name == ILIK")
+	require.NoError(t, err)
+	require.NotContains(t, out, "b/items/b1.json")
+}
+
+func TestNotify(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b2", Version: "b21", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+	afero.WriteFile(afs, "b/items/b2.json", []byte(`{"id": "b2", "labels": ["two"]}`), 0600)
+
+	// Push with a failure, notifying a URL
+	// ------------------------------------
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b2", Version: "b21"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/a/items/a1").
+		Reply(http.StatusOK)
+
+	gock.New("https://example.com").
+		Put("/users/b/items/b2").
+		Reply(http.StatusBadRequest)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12", fetch: true},
+		{User: "b", ID: "b2", Version: "b22"},
+	})
+
+	var summary map[string]any
+	gock.New("https://hooks.example.com").
+		Post("/done").
+		MatchHeader("Content-Type", "application/json").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			b, _ := io.ReadAll(req.Body)
+			return true, json.Unmarshal(b, &summary)
+		}).
+		Reply(http.StatusNoContent)
+
+	out, err := run("bulk", "push", "--notify-url", "https://hooks.example.com/done")
+	require.NoError(t, err)
+	require.Contains(t, out, "Push complete")
+	mustHaveCalledAllHTTPMocks(t)
+
+	require.Equal(t, "push", summary["command"])
+	require.Equal(t, "https://example.com/all-items", summary["url"])
+	require.Equal(t, 1.0, summary["succeeded"])
+	require.Equal(t, 1.0, summary["failed"])
+	require.Contains(t, summary, "duration")
+	require.Equal(t, []any{map[string]any{
+		"path":   "b/items/b2.json",
+		"status": 400.0,
+		"kind":   "permanent",
+		"error":  "Error uploading b/items/b2.json to https://example.com/users/b/items/b2",
+	}}, summary["failures"])
+
+	// Pull with a failing notification only warns
+	// -------------------------------------------
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b2", Version: "b22", fetch: true},
+	})
+
+	gock.New("https://hooks.example.com").
+		Post("/done").
+		Reply(http.StatusInternalServerError)
+
+	dir := t.TempDir()
+	output := dir + "/summary.json"
+
+	out, err = run("bulk", "pull", "--notify-url", "https://hooks.example.com/done", "--notify-command", "cat > "+output)
+	require.NoError(t, err)
+	require.Contains(t, out, "Unable to notify https://hooks.example.com/done")
+	require.Contains(t, out, "Skipping due to local edits: b/items/b2.json")
+	mustHaveCalledAllHTTPMocks(t)
+
+	b, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &summary))
+	require.Equal(t, "pull", summary["command"])
+	require.Equal(t, 0.0, summary["succeeded"])
+	require.Equal(t, 1.0, summary["skipped"])
+	require.Equal(t, 0.0, summary["failed"])
+
+	// A failing notify command only warns
+	// -----------------------------------
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b2", Version: "b22", fetch: true},
+	})
+
+	out, err = run("bulk", "pull", "--notify-url", "", "--notify-command", "exit 3")
+	require.NoError(t, err)
+	require.Contains(t, out, "Unable to run notify command")
+}
+
+func TestMetrics(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b2", Version: "b21", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+	afero.WriteFile(afs, "b/items/b2.json", []byte(`{"id": "b2", "labels": ["two"]}`), 0600)
+
+	// Push with a failure still writes JSON metrics
+	// ---------------------------------------------
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b2", Version: "b21"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/a/items/a1").
+		Reply(http.StatusOK)
+
+	gock.New("https://example.com").
+		Put("/users/b/items/b2").
+		Reply(http.StatusBadRequest)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12", fetch: true},
+		{User: "b", ID: "b2", Version: "b22"},
+	})
+
+	_, err := run("bulk", "push", "--metrics-file", "metrics.json")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	b, err := afero.ReadFile(afs, "metrics.json")
+	require.NoError(t, err)
+
+	var metrics map[string]any
+	require.NoError(t, json.Unmarshal(b, &metrics))
+	require.Equal(t, "push", metrics["command"])
+	require.Equal(t, 1.0, metrics["succeeded"])
+	require.Equal(t, 1.0, metrics["failed"])
+
+	files := metrics["files"].([]any)
+	require.Len(t, files, 2)
+
+	a1 := files[0].(map[string]any)
+	require.Equal(t, "a/items/a1.json", a1["path"])
+	require.Equal(t, 200.0, a1["status"])
+	require.Equal(t, 2.0, a1["requests"])
+	require.Greater(t, a1["bytes"], 0.0)
+	require.NotContains(t, a1, "error")
+
+	b2 := files[1].(map[string]any)
+	require.Equal(t, "b/items/b2.json", b2["path"])
+	require.Equal(t, 400.0, b2["status"])
+	require.Equal(t, 1.0, b2["requests"])
+	require.Equal(t, "Error uploading b/items/b2.json to https://example.com/users/b/items/b2", b2["error"])
+
+	index := metrics["index"].(map[string]any)
+	require.Equal(t, 2.0, index["requests"])
+
+	aggregate := metrics["aggregate"].(map[string]any)
+	require.Equal(t, 2.0, aggregate["files"])
+	require.Equal(t, 3.0, aggregate["requests"])
+	require.Contains(t, aggregate["duration_ms"], "p95")
+
+	// Pull with Prometheus metrics
+	// ----------------------------
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b2", Version: "b22", fetch: true},
+	})
+
+	_, err = run("bulk", "pull", "--metrics-file", "metrics.prom", "--metrics-format", "prom")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	b, err = afero.ReadFile(afs, "metrics.prom")
+	require.NoError(t, err)
+	require.Contains(t, string(b), "# TYPE restish_bulk_file_status gauge")
+	require.Contains(t, string(b), `restish_bulk_file_status{command="pull",path="b/items/b2.json"} 200`)
+	require.Contains(t, string(b), `restish_bulk_files{command="pull",result="skipped"} 1`)
+	require.Contains(t, string(b), `restish_bulk_file_duration_quantile_seconds{command="pull",quantile="0.99"}`)
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	require.Equal(t, 0.0, percentile(nil, 50))
+	require.Equal(t, 5.0, percentile(values, 50))
+	require.Equal(t, 9.0, percentile(values, 90))
+	require.Equal(t, 10.0, percentile(values, 95))
+	require.Equal(t, 10.0, percentile(values, 100))
+}
+
+func TestStatusHead(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+		{User: "c", ID: "c1", Version: "c11", fetch: true},
+		{User: "c", ID: "c2", Version: "c21", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	metaFileContents, _ := afero.ReadFile(afs, ".rshbulk/meta")
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+
+	// No index request is made, only per-file checks.
+	gock.Flush()
+
+	gock.New("https://example.com").
+		Head("/users/a/items/a1").
+		Reply(http.StatusOK).
+		SetHeader("Etag", meta.Files["a/items/a1.json"].ETag)
+
+	gock.New("https://example.com").
+		Head("/users/a/items/a2").
+		Reply(http.StatusOK).
+		SetHeader("Etag", "changed")
+
+	gock.New("https://example.com").
+		Head("/users/b/items/b1").
+		Reply(http.StatusGone)
+
+	// HEAD isn't supported, so a conditional GET is used.
+	gock.New("https://example.com").
+		Head("/users/c/items/c1").
+		Reply(http.StatusMethodNotAllowed)
+
+	gock.New("https://example.com").
+		Get("/users/c/items/c1").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			return req.Header.Get("If-None-Match") == meta.Files["c/items/c1.json"].ETag, nil
+		}).
+		Reply(http.StatusNotModified)
+
+	gock.New("https://example.com").
+		Head("/users/c/items/c2").
+		Reply(http.StatusForbidden)
+
+	out, err := run("bulk", "status", "--head", "--parallel", "2")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "Remote changes")
+	require.NotContains(t, out, "a/items/a1.json")
+	require.Contains(t, out, "modified:  a/items/a2.json")
+	require.Contains(t, out, "removed:  b/items/b1.json")
+	require.NotContains(t, out, "c/items/c1.json")
+	require.Contains(t, out, "Unable to check c/items/c2.json")
+	require.Contains(t, out, "Unable to check 1 of 5 files")
+
+	// Handled probe responses must not become the exit code.
+	require.Less(t, cli.GetLastStatus(), http.StatusBadRequest)
+
+	// The status command should never change the metadata!
+	mfc2, _ := afero.ReadFile(afs, ".rshbulk/meta")
+	require.Equal(t, string(metaFileContents), string(mfc2))
+}
+
+func TestStatusCachedIndex(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+	require.False(t, meta.IndexRefreshed.IsZero())
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "changed": true}`), 0600)
+
+	// The index is still rate limited after retrying, so the remote versions
+	// from the init are used.
+	gock.New("https://example.com").
+		Get("/all-items").
+		Times(2).
+		Reply(http.StatusTooManyRequests).
+		SetHeader("X-Retry-In", "1ms")
+
+	out, err := run("bulk", "status", "--rsh-retry", "1")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "Index unavailable, using the cached index from")
+	require.Contains(t, out, "You are up to date with")
+	require.Contains(t, out, "modified:  a/items/a1.json")
+
+	// Server errors fail when live data is required.
+	gock.New("https://example.com").
+		Get("/all-items").
+		Times(2).
+		Reply(http.StatusServiceUnavailable).
+		SetHeader("X-Retry-In", "1ms")
+
+	out, err = run("bulk", "status", "--rsh-retry", "1", "--no-fallback")
+	require.ErrorContains(t, err, "error fetching https://example.com/all-items")
+	mustHaveCalledAllHTTPMocks(t)
+	require.NotContains(t, out, "Index unavailable")
+
+	// Other errors never fall back.
+	gock.New("https://example.com").
+		Get("/all-items").
+		Reply(http.StatusForbidden)
+
+	_, err = run("bulk", "status")
+	require.ErrorContains(t, err, "error fetching https://example.com/all-items")
+	mustHaveCalledAllHTTPMocks(t)
+}
+
+func TestNoIndex(t *testing.T) {
+	defer gock.Off()
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	afero.WriteFile(afs, "urls.txt", []byte("# Books\nexample.com/books/1\n\nexample.com/authors/2\n"), 0600)
+
+	// Init without an index
+	// ---------------------
+	gock.New("https://example.com").
+		Get("/books/1").
+		Reply(http.StatusOK).
+		SetHeader("Etag", "b1").
+		JSON(map[string]any{"id": "1"})
+
+	gock.New("https://example.com").
+		Get("/authors/2").
+		Reply(http.StatusOK).
+		SetHeader("Etag", "a2").
+		JSON(map[string]any{"id": "2"})
+
+	_, err := run("bulk", "init", "--from-file", "urls.txt")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	afs.Remove("urls.txt")
+	mustEqualJSON(t, "books/1.json", `{"id": "1"}`)
+	mustEqualJSON(t, "authors/2.json", `{"id": "2"}`)
+
+	// Status uses HEAD requests
+	// -------------------------
+	gock.New("https://example.com").
+		Head("/books/1").
+		Reply(http.StatusOK).
+		SetHeader("Etag", "b1-v2")
+
+	gock.New("https://example.com").
+		Head("/authors/2").
+		Reply(http.StatusGone)
+
+	out, err := run("bulk", "status")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "modified:  books/1.json")
+	require.Contains(t, out, "removed:  authors/2.json")
+
+	// Pull uses conditional requests
+	// ------------------------------
+	gock.New("https://example.com").
+		Get("/books/1").
+		MatchHeader("If-None-Match", "b1").
+		Reply(http.StatusOK).
+		SetHeader("Etag", "b1-v2").
+		JSON(map[string]any{"id": "1", "title": "updated"})
+
+	gock.New("https://example.com").
+		Get("/authors/2").
+		MatchHeader("If-None-Match", "a2").
+		Reply(http.StatusGone)
+
+	_, err = run("bulk", "pull")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	mustEqualJSON(t, "books/1.json", `{"id": "1", "title": "updated"}`)
+	exists, _ := afero.Exists(afs, "authors/2.json")
+	require.False(t, exists)
+
+	// Track a new resource by URL
+	// ---------------------------
+	gock.New("https://example.com").
+		Get("/books/1").
+		MatchHeader("If-None-Match", "b1-v2").
+		Reply(http.StatusNotModified)
+
+	gock.New("https://example.com").
+		Get("/books/3").
+		Reply(http.StatusOK).
+		SetHeader("Etag", "b3").
+		JSON(map[string]any{"id": "3"})
+
+	_, err = run("bulk", "track", "example.com/books/3", "third")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	mustEqualJSON(t, "third.json", `{"id": "3"}`)
+
+	_, err = run("bulk", "track", "example.com/books/4", "third.json")
+	require.ErrorContains(t, err, "third.json is already tracked")
+
+	// Everything is up to date
+	// ------------------------
+	gock.New("https://example.com").
+		Head("/books/1").
+		Reply(http.StatusOK).
+		SetHeader("Etag", "b1-v2")
+
+	gock.New("https://example.com").
+		Head("/books/3").
+		Reply(http.StatusOK).
+		SetHeader("Etag", "b3")
+
+	out, err = run("bulk", "status")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "You are up to date")
+	require.Contains(t, out, "No local changes")
+}
+
+func TestReadURLList(t *testing.T) {
+	afs = afero.NewMemMapFs()
+
+	afero.WriteFile(afs, "urls.json", []byte(`[{"url": "example.com/a", "path": "first.json"}, {"url": "example.com/b"}]`), 0600)
+	entries, err := readURLList("urls.json")
+	require.NoError(t, err)
+	require.Equal(t, []urlEntry{{URL: "example.com/a", Path: "first.json"}, {URL: "example.com/b"}}, entries)
+
+	m := &Meta{Files: map[string]*File{}}
+	f, err := m.newURLFile(urlEntry{URL: "example.com/books/1?x=y"})
+	require.NoError(t, err)
+	require.Equal(t, "books/1.json", f.Path)
+	require.Equal(t, "https://example.com/books/1?x=y", f.URL)
+
+	_, err = m.newURLFile(urlEntry{URL: "example.com/books/1", Path: "../outside"})
+	require.ErrorContains(t, err, "invalid path")
+
+	_, err = m.newURLFile(urlEntry{URL: "example.com/"})
+	require.ErrorContains(t, err, "invalid path")
+}
+
+func TestPullGone(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	afero.WriteFile(afs, "b/items/b1.json", []byte(`{"id": "b1", "edited": true}`), 0600)
+
+	// A 404 is reported without deleting anything, while a 410 removes the file
+	// unless it has local edits.
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "a", ID: "a2", Version: "a22"},
+		{User: "b", ID: "b1", Version: "b12"},
+	})
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a1").
+		Reply(http.StatusNotFound)
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a2").
+		Reply(http.StatusGone)
+
+	gock.New("https://example.com").
+		Get("/users/b/items/b1").
+		Reply(http.StatusGone)
+
+	out, err := run("bulk", "pull")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "Error fetching a/items/a1.json from https://example.com/users/a/items/a1: not found, keeping the local file")
+	require.Contains(t, out, "Removed a/items/a2.json which is gone from the remote")
+	require.Contains(t, out, "Untracked b/items/b1.json which is gone from the remote, keeping local edits")
+
+	mustEqualJSON(t, "a/items/a1.json", `{"id": "a1"}`)
+	exists, _ := afero.Exists(afs, "a/items/a2.json")
+	require.False(t, exists)
+	mustEqualJSON(t, "b/items/b1.json", `{"id": "b1", "edited": true}`)
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+	require.Contains(t, meta.Files, "a/items/a1.json")
+	require.NotContains(t, meta.Files, "a/items/a2.json")
+	require.NotContains(t, meta.Files, "b/items/b1.json")
+
+	// Some APIs never send a 410.
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b2", Version: "b21", fetch: true},
+	})
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a1").
+		Reply(http.StatusNotFound)
+
+	out, err = run("bulk", "pull", "--treat-404-as-gone")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "Removed a/items/a1.json which is gone from the remote")
+	exists, _ = afero.Exists(afs, "a/items/a1.json")
+	require.False(t, exists)
+}
+
+func TestPushRecord(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b2", Version: "b21", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	keyPath := dir + "/id_ed25519"
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600))
+	require.NoError(t, os.WriteFile(keyPath+".pub", ssh.MarshalAuthorizedKey(signer.PublicKey()), 0600))
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+	afero.WriteFile(afs, "b/items/b2.json", []byte(`{"id": "b2", "labels": ["two"]}`), 0600)
+
+	// A missing key doesn't block the push
+	// ------------------------------------
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b2", Version: "b21"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/a/items/a1").
+		Reply(http.StatusOK)
+
+	gock.New("https://example.com").
+		Put("/users/b/items/b2").
+		Reply(http.StatusBadRequest)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12", fetch: true, body: `{"id": "a1", "labels": ["one"]}`},
+		{User: "b", ID: "b2", Version: "b22"},
+	})
+
+	out, err := run("bulk", "push", "--sign-key", dir+"/missing")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "Not signing push record")
+	require.Contains(t, out, "Saved unsigned push record")
+
+	records, _ := afero.ReadDir(afs, pushesDir)
+	require.Len(t, records, 1)
+	unsigned := pushesDir + "/" + records[0].Name()
+
+	var record pushRecord
+	b, _ := afero.ReadFile(afs, unsigned)
+	require.NoError(t, json.Unmarshal(b, &record))
+	require.Nil(t, record.Signature)
+	require.Equal(t, "https://example.com/all-items", record.URL)
+	require.Len(t, record.Files, 2)
+	require.Equal(t, "a/items/a1.json", record.Files[0].Path)
+	require.Equal(t, http.MethodPut, record.Files[0].Method)
+	require.Equal(t, http.StatusOK, record.Files[0].Status)
+	require.NotEmpty(t, record.Files[0].SHA256)
+	require.Equal(t, http.StatusBadRequest, record.Files[1].Status)
+	require.Empty(t, record.Files[1].SHA256)
+	require.NotEmpty(t, record.Files[1].Error)
+
+	out, err = run("bulk", "verify-push", unsigned)
+	require.NoError(t, err)
+	require.Contains(t, out, "Signature: none")
+	require.Contains(t, out, "a/items/a1.json: hash ok")
+
+	// Signed with an SSH key
+	// ----------------------
+	time.Sleep(2 * time.Millisecond)
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b2", Version: "b22"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/b/items/b2").
+		Reply(http.StatusOK)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b2", Version: "b23", fetch: true, body: `{"id": "b2", "labels": ["two"]}`},
+	})
+
+	out, err = run("bulk", "push", "--sign-key", keyPath)
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "Saved signed push record")
+
+	records, _ = afero.ReadDir(afs, pushesDir)
+	require.Len(t, records, 2)
+	signed := pushesDir + "/" + records[1].Name()
+
+	out, err = run("bulk", "verify-push", signed, "--key", keyPath+".pub")
+	require.NoError(t, err)
+	require.Contains(t, out, "Signature: valid ssh-ed25519 "+ssh.FingerprintSHA256(signer.PublicKey()))
+	require.Contains(t, out, "b/items/b2.json: hash ok")
+
+	// The unsigned record fails when a key is required.
+	_, err = run("bulk", "verify-push", unsigned, "--key", keyPath+".pub")
+	require.Error(t, err)
+
+	// Tampering with the record breaks the signature.
+	b, _ = afero.ReadFile(afs, signed)
+	afero.WriteFile(afs, signed, bytes.Replace(b, []byte(`"status": 200`), []byte(`"status": 201`), 1), 0600)
+	out, err = run("bulk", "verify-push", signed)
+	require.Error(t, err)
+	require.Contains(t, out, "Signature: invalid")
+
+	// Changes to the cache no longer match the recorded hashes.
+	afero.WriteFile(afs, signed, b, 0600)
+	afero.WriteFile(afs, ".rshbulk/b/items/b2.json", []byte(`{"id": "b2", "labels": ["changed"]}`), 0600)
+	out, err = run("bulk", "verify-push", signed)
+	require.Error(t, err)
+	require.Contains(t, out, "b/items/b2.json: hash mismatch")
+}
+
+func TestPushResponseDocument(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b2", Version: "b21", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+	afero.WriteFile(afs, "b/items/b2.json", []byte(`{"id": "b2", "labels": ["two"]}`), 0600)
+
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b2", Version: "b21"},
+	})
+
+	// The updated document is returned, so no fetch is needed.
+	gock.New("https://example.com").
+		Put("/users/a/items/a1").
+		Reply(http.StatusOK).
+		SetHeader("Etag", "a12").
+		JSON(map[string]any{"id": "a1", "labels": []any{"one"}, "updated": "server"})
+
+	// No content means the document must be fetched.
+	gock.New("https://example.com").
+		Put("/users/b/items/b2").
+		Reply(http.StatusNoContent)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b2", Version: "b22", fetch: true, body: `{"id": "b2", "labels": ["two"], "updated": "fetched"}`},
+	})
+
+	_, err := run("bulk", "push")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	mustEqualJSON(t, "a/items/a1.json", `{"id": "a1", "labels": ["one"], "updated": "server"}`)
+	mustEqualJSON(t, ".rshbulk/a/items/a1.json", `{"id": "a1", "labels": ["one"], "updated": "server"}`)
+	mustEqualJSON(t, "b/items/b2.json", `{"id": "b2", "labels": ["two"], "updated": "fetched"}`)
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+	require.Equal(t, "a12", meta.Files["a/items/a1.json"].ETag)
+	require.Equal(t, "a12", meta.Files["a/items/a1.json"].VersionLocal)
+
+	// Nothing left to pull or push.
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b2", Version: "b22"},
+	})
+
+	out, err := run("bulk", "status")
+	require.NoError(t, err)
+	require.Contains(t, out, "You are up to date")
+	require.Contains(t, out, "No local changes")
+}
+
+func TestPushMessage(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Message via flag
+	// ----------------
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+	afs.Remove("b/items/b1.json")
+
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/a/items/a1").
+		MatchHeader("X-Change-Reason", "^Fix labels$").
+		Reply(http.StatusNoContent)
+
+	gock.New("https://example.com").
+		Delete("/users/b/items/b1").
+		MatchHeader("X-Change-Reason", "^Fix labels$").
+		Reply(http.StatusNoContent)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12", fetch: true, body: `{"id": "a1", "labels": ["one"]}`},
+		{User: "a", ID: "a2", Version: "a21"},
+	})
+
+	_, err := run("bulk", "push", "-m", "Fix labels")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	records, _ := afero.ReadDir(afs, pushesDir)
+	require.Len(t, records, 1)
+	b, _ := afero.ReadFile(afs, pushesDir+"/"+records[0].Name())
+	var record pushRecord
+	require.NoError(t, json.Unmarshal(b, &record))
+	require.Equal(t, "Fix labels", record.Message)
+
+	// Message via environment with a custom header
+	// --------------------------------------------
+	afero.WriteFile(afs, "a/items/a2.json", []byte(`{"id": "a2", "labels": ["two"]}`), 0600)
+
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "a", ID: "a2", Version: "a21"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/a/items/a2").
+		MatchHeader("X-Audit-Note", "^From CI$").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			return req.Header.Get("X-Change-Reason") == "", nil
+		}).
+		Reply(http.StatusNoContent)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "a", ID: "a2", Version: "a22", fetch: true, body: `{"id": "a2", "labels": ["two"]}`},
+	})
+
+	// Reset the flags from the previous push.
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	t.Setenv(messageEnv, "From CI")
+	_, err = run("bulk", "push", "--message-header", "X-Audit-Note")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+	require.Equal(t, "X-Audit-Note", meta.MessageHeader)
+
+	// No message omits the header
+	// ---------------------------
+	t.Setenv(messageEnv, "")
+	afero.WriteFile(afs, "a/items/a2.json", []byte(`{"id": "a2", "labels": ["three"]}`), 0600)
+
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "a", ID: "a2", Version: "a22"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/a/items/a2").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			return req.Header.Get("X-Audit-Note") == "", nil
+		}).
+		Reply(http.StatusNoContent)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "a", ID: "a2", Version: "a23", fetch: true, body: `{"id": "a2", "labels": ["three"]}`},
+	})
+
+	_, err = run("bulk", "push")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+}
+
+func TestInitResume(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "b", ID: "b1", Version: "b11"},
+		{User: "c", ID: "c1", Version: "c11"},
+	})
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a2").
+		Reply(http.StatusForbidden)
+
+	// Cancel while fetching b1, which stops the request.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gock.New("https://example.com").
+		Get("/users/b/items/b1").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			cancel()
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		JSON(map[string]any{"id": "b1"})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	out, err := runContext(ctx, "bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	require.ErrorIs(t, err, errInterrupted)
+	require.Contains(t, err.Error(), "bulk init --resume")
+	require.Contains(t, out, "Cancelled, 2 of 4 files processed")
+	mustHaveCalledAllHTTPMocks(t)
+
+	mustExist(t, "a/items/a1.json")
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+	require.True(t, meta.Initializing)
+	require.Equal(t, "a11", meta.Files["a/items/a1.json"].VersionLocal)
+	require.Equal(t, "", meta.Files["b/items/b1.json"].VersionLocal)
+
+	// Pretend a file was cached but never written out.
+	afs.Remove("a/items/a1.json")
+
+	// Running the same init again resumes it, only fetching the failed and
+	// remaining files. Commands keep the context of their last run, so start
+	// fresh.
+	gock.Flush()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+		{User: "c", ID: "c1", Version: "c11", fetch: true},
+	})
+
+	out, err = run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	require.NoError(t, err)
+	require.Contains(t, out, "Resuming init of https://example.com/all-items")
+	mustHaveCalledAllHTTPMocks(t)
+
+	mustEqualJSON(t, "a/items/a1.json", `{"id": "a1"}`)
+	mustEqualJSON(t, "a/items/a2.json", `{"id": "a2"}`)
+	mustEqualJSON(t, "b/items/b1.json", `{"id": "b1"}`)
+	mustEqualJSON(t, "c/items/c1.json", `{"id": "c1"}`)
+
+	meta = Meta{}
+	require.NoError(t, loadMeta(&meta))
+	require.False(t, meta.Initializing)
+
+	_, err = run("bulk", "init", "--resume")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no interrupted init to resume")
+}
+
+func TestPushCancel(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+	afero.WriteFile(afs, "a/items/a2.json", []byte(`{"id": "a2", "labels": ["two"]}`), 0600)
+	afs.Remove("b/items/b1.json")
+
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	// Cancel while fetching the first uploaded file, which still gets
+	// finished so its metadata is up to date.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gock.New("https://example.com").
+		Put("/users/a/items/a1").
+		Reply(http.StatusNoContent)
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a1").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			cancel()
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		JSON(map[string]any{"id": "a1", "labels": []any{"one"}, "updated": true})
+
+	out, err := runContext(ctx, "bulk", "push")
+	require.ErrorIs(t, err, errInterrupted)
+	require.Contains(t, out, "Cancelled, 1 of 3 files processed")
+	mustHaveCalledAllHTTPMocks(t)
+
+	// The pushed file was updated while the others are still changed locally.
+	mustEqualJSON(t, "a/items/a1.json", `{"id": "a1", "labels": ["one"], "updated": true}`)
+	meta := mustLoadMeta()
+	require.False(t, meta.Files["a/items/a1.json"].IsChangedLocal(false))
+	require.True(t, meta.Files["a/items/a2.json"].IsChangedLocal(false))
+	require.Contains(t, meta.Files, "b/items/b1.json")
+
+	records, _ := afero.ReadDir(afs, pushesDir)
+	require.Len(t, records, 1)
+	b, _ := afero.ReadFile(afs, pushesDir+"/"+records[0].Name())
+	var record pushRecord
+	require.NoError(t, json.Unmarshal(b, &record))
+	require.Len(t, record.Files, 1)
+	require.Equal(t, "a/items/a1.json", record.Files[0].Path)
+}
+
+func TestPullDigest(t *testing.T) {
+	defer gock.Off()
+
+	digest := func(alg string, body string) string {
+		sum := sha256.Sum256([]byte(body))
+		return alg + "=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+	}
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a1").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Content-Digest", digest("sha-256", `{"id": "a1"}`)+", unknown=:YWJj:").
+		BodyString(`{"id": "a1"}`)
+
+	gock.New("https://example.com").
+		Get("/users/b/items/b1").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Digest", "SHA-256="+strings.Trim(strings.TrimPrefix(digest("sha-256", `{"id": "b1"}`), "sha-256="), ":")).
+		BodyString(`{"id": "b1"}`)
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	_, err := run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+	require.Equal(t, digest("sha-256", `{"id": "a1"}`), meta.Files["a/items/a1.json"].Digest)
+	require.Equal(t, digest("sha-256", `{"id": "b1"}`), meta.Files["b/items/b1.json"].Digest)
+
+	// A corrupted download fails without touching the file.
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a1").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Content-Digest", digest("sha-256", `{"id": "a1", "changed": true}`)).
+		BodyString(`{"id": "a1", "changed": tru}`)
+
+	out, err := run("bulk", "pull")
+	require.NoError(t, err)
+	require.Contains(t, out, "content digest mismatch")
+	mustHaveCalledAllHTTPMocks(t)
+
+	mustEqualJSON(t, "a/items/a1.json", `{"id": "a1"}`)
+	mustEqualJSON(t, ".rshbulk/a/items/a1.json", `{"id": "a1"}`)
+
+	meta = Meta{}
+	require.NoError(t, loadMeta(&meta))
+	require.Equal(t, "a11", meta.Files["a/items/a1.json"].VersionLocal)
+}
+
+func TestPullMaxSize(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	_, err := run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	// A resource over the limit fails on its own without stopping the pull.
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b1", Version: "b12", fetch: true},
+	})
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a1").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"id": "a1", "export": "` + strings.Repeat("x", 1000) + `"}`)
+
+	out, err := run("bulk", "pull", "--rsh-max-size", "500B")
+	require.NoError(t, err)
+	require.Contains(t, out, "larger than the 500B limit")
+	mustHaveCalledAllHTTPMocks(t)
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+	require.Equal(t, "a11", meta.Files["a/items/a1.json"].VersionLocal)
+	require.Equal(t, "b12", meta.Files["b/items/b1.json"].VersionLocal)
+}
+
+func TestFailureKind(t *testing.T) {
+	require.Equal(t, failureRetry, failureKind(&cli.ErrHTTP{Status: http.StatusServiceUnavailable}))
+	require.Equal(t, failureRetry, failureKind(&cli.ErrNetwork{Err: errors.New("connection refused")}))
+	require.Equal(t, failureRetry, failureKind(fmt.Errorf("%w: sha-256", errDigestMismatch)))
+	require.Equal(t, failureConflict, failureKind(&cli.ErrHTTP{Status: http.StatusPreconditionFailed}))
+	require.Equal(t, failurePermanent, failureKind(&cli.ErrHTTP{Status: http.StatusBadRequest}))
+	require.Equal(t, failurePermanent, failureKind(errors.New("unable to write file")))
+	require.Equal(t, failurePermanent, failureKind(nil))
+
+	// A successful response doesn't hide the error which caused the failure.
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	cli.Stdout = io.Discard
+	bar := progressbar.NewOptions(1, progressbar.OptionSetWriter(io.Discard))
+	r := &report{}
+	r.fail(bar, &cli.Response{Status: http.StatusOK}, "a.json", "Error fetching %s: %s\n", "a.json", errDigestMismatch)
+	r.fail(bar, &cli.Response{Status: http.StatusConflict}, "b.json", "Error pushing %s\n", "b.json")
+	require.Equal(t, failureRetry, r.Failures[0].Kind)
+	require.Equal(t, http.StatusOK, r.Failures[0].Status)
+	require.Equal(t, failureConflict, r.Failures[1].Kind)
+}
+
+func TestVerifyDigest(t *testing.T) {
+	body := []byte("hello")
+	sum256 := sha256.Sum256(body)
+	sum512 := sha512.Sum512(body)
+	b64 := base64.StdEncoding.EncodeToString
+
+	for _, tc := range []struct {
+		name     string
+		header   http.Header
+		expected string
+		err      error
+	}{
+		{"none", http.Header{}, "", nil},
+		{"sha-256", http.Header{"Content-Digest": {"sha-256=:" + b64(sum256[:]) + ":"}}, "sha-256=:" + b64(sum256[:]) + ":", nil},
+		{"both", http.Header{"Content-Digest": {"sha-512=:" + b64(sum512[:]) + ":, sha-256=:" + b64(sum256[:]) + ":"}}, "sha-256=:" + b64(sum256[:]) + ":, sha-512=:" + b64(sum512[:]) + ":", nil},
+		{"unknown", http.Header{"Content-Digest": {"md5=:" + b64([]byte("abc")) + ":"}}, "", nil},
+		{"mismatch", http.Header{"Content-Digest": {"sha-256=:" + b64(sum512[:]) + ":"}}, "", errDigestMismatch},
+		{"one mismatch", http.Header{"Content-Digest": {"sha-256=:" + b64(sum256[:]) + ":, sha-512=:" + b64(sum256[:]) + ":"}}, "", errDigestMismatch},
+		{"legacy", http.Header{"Digest": {"SHA-512=" + b64(sum512[:])}}, "sha-512=:" + b64(sum512[:]) + ":", nil},
+		{"invalid", http.Header{"Content-Digest": {"sha-256=" + b64(sum512[:])}}, "", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			digest, err := verifyDigest(tc.header, body)
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.expected, digest)
+		})
+	}
+}
+
+func TestDeprecation(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	for _, id := range []string{"a1", "a2"} {
+		gock.New("https://example.com").
+			Get("/users/a/items/"+id).
+			Reply(http.StatusOK).
+			SetHeader("Deprecation", "true").
+			SetHeader("Sunset", "Sat, 01 Mar 2025 00:00:00 GMT").
+			JSON(map[string]any{"id": id})
+	}
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	out, err := run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	require.NoError(t, err)
+	require.Contains(t, out, "2 resources under https://example.com/users/a/items/ are marked deprecated; sunset 2025-03-01")
+	require.Equal(t, 1, strings.Count(out, "marked deprecated"))
+	mustHaveCalledAllHTTPMocks(t)
+
+	meta := mustLoadMeta()
+	require.Equal(t, &cli.Deprecation{Deprecated: true, Sunset: "2025-03-01"}, meta.Files["a/items/a1.json"].Deprecation)
+	require.Nil(t, meta.Files["b/items/b1.json"].Deprecation)
+
+	out, err = run("bulk", "list", "-f", "deprecation.sunset", "--rsh-filter-full")
+	require.NoError(t, err)
+	require.Contains(t, out, "a/items/a1.json\n\"2025-03-01\"")
+
+	// Status warns based on the last fetch.
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	out, err = run("bulk", "status")
+	require.NoError(t, err)
+	require.Contains(t, out, "2 resources under https://example.com/users/a/items/ are marked deprecated; sunset 2025-03-01")
+}
+
+func TestRateLimit(t *testing.T) {
+	defer gock.Off()
+
+	expectRateLimitedIndex := func(files []remoteFile) {
+		gock.New("https://example.com").
+			Get("/all-items").
+			Reply(http.StatusOK).
+			SetHeader("RateLimit-Limit", "100, 100;w=60").
+			SetHeader("RateLimit-Remaining", "4").
+			SetHeader("RateLimit-Reset", "1").
+			JSON(files)
+
+		for _, f := range files {
+			if f.fetch {
+				expectRemoteFile(f)
+			}
+		}
+	}
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	_, err := run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Few requests left, so the second file waits for its share of the window.
+	gock.Flush()
+
+	expectRateLimitedIndex([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12", fetch: true},
+		{User: "b", ID: "b1", Version: "b12", fetch: true},
+	})
+
+	start := time.Now()
+	_, err = run("bulk", "pull", "--metrics-file", "metrics.json")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	b, err := afero.ReadFile(afs, "metrics.json")
+	require.NoError(t, err)
+
+	var metrics map[string]any
+	require.NoError(t, json.Unmarshal(b, &metrics))
+	rateLimit := metrics["rate_limit"].(map[string]any)
+	require.Equal(t, 100.0, rateLimit["limit"])
+	require.Equal(t, 4.0, rateLimit["remaining"])
+	require.Equal(t, 1.0, rateLimit["reset_seconds"])
+	require.Equal(t, 1.0, rateLimit["waits"])
+	require.Greater(t, rateLimit["wait_ms"], 0.0)
+
+	// A manual rate wins over the adaptive pacing.
+	gock.Flush()
+
+	expectRateLimitedIndex([]remoteFile{
+		{User: "a", ID: "a1", Version: "a13", fetch: true},
+		{User: "b", ID: "b1", Version: "b13", fetch: true},
+	})
+
+	_, err = run("bulk", "pull", "--rate", "1000", "--metrics-file", "metrics.prom", "--metrics-format", "prom")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	b, err = afero.ReadFile(afs, "metrics.prom")
+	require.NoError(t, err)
+	require.Contains(t, string(b), `restish_bulk_rate_limit{command="pull"} 100`)
+	require.Contains(t, string(b), `restish_bulk_rate_limit_remaining{command="pull"} 4`)
+	require.Contains(t, string(b), `restish_bulk_rate_limit_wait_seconds{command="pull"} 0`+"\n")
+}
+
+func TestPacer(t *testing.T) {
+	p := &pacer{}
+
+	p.observe(http.Header{})
+	require.Nil(t, p.observed)
+	require.Zero(t, p.delay)
+
+	p.observe(http.Header{"Ratelimit-Limit": {"100"}, "Ratelimit-Remaining": {"50"}, "Ratelimit-Reset": {"30"}})
+	require.Zero(t, p.delay)
+
+	p.observe(http.Header{"Ratelimit-Limit": {"100"}, "Ratelimit-Remaining": {"5"}, "Ratelimit-Reset": {"30"}})
+	require.Equal(t, 5*time.Second, p.delay)
+
+	p.observe(http.Header{"Ratelimit-Limit": {"100"}, "Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"2"}})
+	require.Equal(t, 2*time.Second, p.delay)
+	require.Equal(t, 0, p.observed.MinRemaining)
+
+	p.observe(http.Header{"Ratelimit-Limit": {"100"}, "Ratelimit-Remaining": {"100"}, "Ratelimit-Reset": {"60"}})
+	require.Zero(t, p.delay)
+	require.Equal(t, 100, p.observed.Remaining)
+	require.Equal(t, 0, p.observed.MinRemaining)
+}
+
+func TestInitPreflight(t *testing.T) {
+	defer gock.Off()
+
+	files := []remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b11"},
+	}
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	// A typo in the template aborts before anything is saved.
+	expectRemote(files)
+	for _, f := range files {
+		gock.New("https://example.com").
+			Head("/user/" + f.User + "/items/" + f.ID).
+			Reply(http.StatusNotFound)
+	}
+
+	_, err := run("bulk", "init", "example.com/all-items", "--url-template=/user/{user}/items/{id}")
+	require.ErrorContains(t, err, "URL template check failed for 2 of 2 sampled resources, e.g. https://example.com/user/a/items/a1 responded with 404 Not Found")
+	mustHaveCalledAllHTTPMocks(t)
+	exists, _ := afero.Exists(afs, metaFile)
+	require.False(t, exists)
+
+	// Template variables must be set by the index entries.
+	gock.Flush()
+	expectRemote(files)
+
+	_, err = run("bulk", "init", "example.com/all-items", "--url-template=/users/{owner}/items/{id}")
+	require.ErrorContains(t, err, `URL template variable {owner} is not set by index entries like {"id":"a1","user":"a","version":"a11"}, available fields are id, user, version`)
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Servers without HEAD support get a single byte GET instead, and the index
+	// is only fetched once.
+	gock.Flush()
+	gock.New("https://example.com").
+		Head("/users/a/items/a1").
+		Reply(http.StatusOK)
+	gock.New("https://example.com").
+		Head("/users/b/items/b1").
+		Reply(http.StatusMethodNotAllowed)
+	gock.New("https://example.com").
+		Get("/users/b/items/b1").
+		MatchHeader("Range", "bytes=0-0").
+		Reply(http.StatusPartialContent).
+		BodyString("{")
+	files[0].fetch = true
+	files[1].fetch = true
+	expectRemote(files)
+
+	_, err = run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	mustExist(t, "a/items/a1.json")
+	mustExist(t, "b/items/b1.json")
+
+	// Skipping the check goes straight to fetching the files.
+	afs = afero.NewMemMapFs()
+	expectRemote(files)
+
+	_, err = run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}", "--skip-preflight")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	mustExist(t, "a/items/a1.json")
+}
+
+func TestPushMethods(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	// POST with a method override header
+	// ----------------------------------
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+	afs.Remove("b/items/b1.json")
+
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	gock.New("https://example.com").
+		Post("/users/a/items/a1").
+		MatchHeader("X-HTTP-Method-Override", "^PUT$").
+		Reply(http.StatusNoContent)
+
+	gock.New("https://example.com").
+		Post("/users/b/items/b1").
+		MatchHeader("X-HTTP-Method-Override", "^DELETE$").
+		Reply(http.StatusNoContent)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12", fetch: true, body: `{"id": "a1", "labels": ["one"]}`},
+		{User: "a", ID: "a2", Version: "a21"},
+	})
+
+	_, err := run("bulk", "push", "--update-method", "post", "--delete-method", "POST", "--method-override-header", "X-HTTP-Method-Override")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	meta := mustLoadMeta()
+	require.Equal(t, "POST", meta.UpdateMethod)
+	require.Equal(t, "", meta.CreateMethod)
+	require.Equal(t, "POST", meta.DeleteMethod)
+	require.Equal(t, "X-HTTP-Method-Override", meta.MethodOverrideHeader)
+
+	records, _ := afero.ReadDir(afs, pushesDir)
+	require.Len(t, records, 1)
+	b, _ := afero.ReadFile(afs, pushesDir+"/"+records[0].Name())
+	var record pushRecord
+	require.NoError(t, json.Unmarshal(b, &record))
+	require.Equal(t, "POST", record.Files[0].Method)
+
+	// A 405 suggests configuring the method
+	// -------------------------------------
+	afero.WriteFile(afs, "a/items/a2.json", []byte(`{"id": "a2", "labels": ["two"]}`), 0600)
+
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "a", ID: "a2", Version: "a21"},
+	})
+
+	gock.New("https://example.com").
+		Post("/users/a/items/a2").
+		Reply(http.StatusMethodNotAllowed)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "a", ID: "a2", Version: "a21"},
+	})
+
+	// Reset the flags from the previous push, the methods stay configured.
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	out, err := run("bulk", "push")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "Error uploading a/items/a2.json to https://example.com/users/a/items/a2: the server doesn't allow POST, configure another method with `bulk push --update-method`")
+}
+
+func TestStats(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: true, body: `{"id": "a2", "description": "a much longer body"}`},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Edit a file which fails to push since it changed on the remote too, so
+	// it conflicts.
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+	afero.WriteFile(afs, "c/items/c1.json", []byte(`{"id": "c1"}`), 0600)
+
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/a/items/a1").
+		Reply(http.StatusPreconditionFailed)
+
+	gock.New("https://example.com").
+		Put("/c/items/c1").
+		Reply(http.StatusForbidden)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "a", ID: "a2", Version: "a21"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	_, err := run("bulk", "push")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	afs.Remove("b/items/b1.json")
+
+	// Stats never make requests.
+	gock.Flush()
+	gock.CleanUnmatchedRequest()
+
+	out, err := run("bulk", "stats")
+	require.NoError(t, err)
+	require.False(t, gock.HasUnmatchedRequest())
+	require.Contains(t, out, "Files:       3 tracked, 87 bytes")
+	require.Contains(t, out, "Local:       1 modified, 1 added, 1 removed")
+	require.Contains(t, out, "Conflicted:  1")
+	require.Contains(t, out, "Largest files:\n    56 bytes  a/items/a2.json\n    31 bytes  a/items/a1.json")
+
+	out, err = run("bulk", "stats", "--format", "json")
+	require.NoError(t, err)
+	require.False(t, gock.HasUnmatchedRequest())
+
+	var stats checkoutStats
+	require.NoError(t, json.Unmarshal([]byte(out[strings.Index(out, "{"):]), &stats))
+	require.Equal(t, 3, stats.Files)
+	require.Equal(t, 1, stats.Conflicted)
+	require.NotEmpty(t, stats.LastPull)
+	require.Equal(t, []*dirStats{{Path: "a/items", Files: 2, Bytes: 87}}, stats.Directories)
+}
+
+func TestStripJSONC(t *testing.T) {
+	in := `{
+  // The name
+  "name": "a // b", /* inline */ "url": "https://example.com/*x*/",
+  "tags": ["one", "two",],
+  /* multi
+     line */
+  "escaped": "quote \" // not a comment",
+}`
+
+	var v map[string]any
+	require.NoError(t, json.Unmarshal(stripJSONC([]byte(in)), &v))
+	require.Equal(t, map[string]any{
+		"name":    "a // b",
+		"url":     "https://example.com/*x*/",
+		"tags":    []any{"one", "two"},
+		"escaped": `quote " // not a comment`,
+	}, v)
+
+	plain := []byte(`{"a": [1, 2]}`)
+	require.Equal(t, plain, stripJSONC(plain))
+}
+
+func TestMergeComments(t *testing.T) {
+	old := `// Owned by the books team
+{
+  // Shown in the catalog
+  "title": "Sapiens", // Keep in sync with print
+  "removed": true, // Gone remotely
+  "rating": 4.5,
+}
+// The end
+`
+	updated := "{\n  \"rating\": 4.6,\n  \"title\": \"Sapiens: A Brief History\"\n}\n"
+
+	merged, dropped := mergeComments([]byte(old), []byte(updated))
+	require.Equal(t, 1, dropped)
+	require.Equal(t, `// Owned by the books team
+{
+  "rating": 4.6,
+  // Shown in the catalog
+  "title": "Sapiens: A Brief History" // Keep in sync with print
+}
+// The end
+`, string(merged))
+
+	plain, dropped := mergeComments([]byte("{}\n"), []byte(updated))
+	require.Equal(t, 0, dropped)
+	require.Equal(t, updated, string(plain))
+}
+
+func TestJSONComments(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Comments and trailing commas alone are not a change.
+	afero.WriteFile(afs, "a/items/a1.json", []byte("{\n  // Primary item\n  \"id\": \"a1\",\n}\n"), 0600)
+	afero.WriteFile(afs, "b/items/b1.json", []byte("{\n  \"id\": \"b1\", // Do not rename\n  /* Added by hand */\n  \"labels\": [\"two\",],\n}\n"), 0600)
+
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	out, err := run("bulk", "status")
+	require.NoError(t, err)
+	require.Contains(t, out, "modified:  b/items/b1.json")
+	require.NotContains(t, out, "a/items/a1.json")
+
+	// Pushes send plain JSON.
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/b/items/b1").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			b, _ := io.ReadAll(req.Body)
+			var v map[string]any
+			err := json.Unmarshal(b, &v)
+			return err == nil && !strings.Contains(string(b), "//") && v["id"] == "b1", err
+		}).
+		Reply(http.StatusNoContent)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b12", fetch: true, body: `{"id": "b1", "labels": ["two"]}`},
+	})
+
+	_, err = run("bulk", "push")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Pulls keep comments only when asked to.
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12", fetch: true, body: `{"id": "a1", "name": "first"}`},
+		{User: "b", ID: "b1", Version: "b12"},
+	})
+
+	_, err = run("bulk", "pull", "--preserve-comments")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	b, _ := afero.ReadFile(afs, "a/items/a1.json")
+	require.Equal(t, "{\n  // Primary item\n  \"id\": \"a1\",\n  \"name\": \"first\"\n}\n", string(b))
+
+	gock.Flush()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b1", Version: "b12"},
+	})
+
+	out, err = run("bulk", "status")
+	require.NoError(t, err)
+	require.Contains(t, out, "No local changes")
+}
+
+func TestSync(t *testing.T) {
+	defer gock.Off()
+
+	exitCode := 0
+	orig := syncExit
+	defer func() { syncExit = orig }()
+	syncExit = func(code int) { exitCode = code }
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Nothing changed, so there is nothing to do.
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	out, err := run("bulk", "sync")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "Already in sync")
+	require.Equal(t, syncExitNothing, exitCode)
+
+	// A file changed on both sides blocks the sync without any other requests.
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "local": true}`), 0600)
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	exitCode = 0
+	gock.CleanUnmatchedRequest()
+	out, err = run("bulk", "sync")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.False(t, gock.HasUnmatchedRequest())
+	require.Contains(t, out, "Conflicts")
+	require.Contains(t, out, "a/items/a1.json (local changes to /local)")
+	require.Equal(t, syncExitConflicts, exitCode)
+	mustEqualJSON(t, "a/items/a1.json", `{"id": "a1", "local": true}`)
+
+	// Preferring the local file pushes it over the remote change without a
+	// precondition, after a single index fetch for both phases.
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a12"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/a/items/a1").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			return req.Header.Get("If-Match") == "" && req.Header.Get("If-Unmodified-Since") == "", nil
+		}).
+		Reply(http.StatusNoContent)
+
+	expectRemoteFile(remoteFile{User: "a", ID: "a1", body: `{"id": "a1", "local": true}`})
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a13"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	exitCode = 0
+	out, err = run("bulk", "sync", "--prefer-local", "--metrics-file", ".sync.json")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "Synced with")
+	require.Equal(t, 0, exitCode)
+
+	b, err := afero.ReadFile(afs, ".sync.json")
+	require.NoError(t, err)
+	var doc metricsDocument
+	require.NoError(t, json.Unmarshal(b, &doc))
+	require.Equal(t, "sync", doc.Command)
+	require.Equal(t, 1, doc.Succeeded)
+	// The index is fetched once for both phases and again after the push.
+	require.Equal(t, 2, doc.Index.Requests)
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	// Preferring the remote discards the local changes instead.
+	afero.WriteFile(afs, "b/items/b1.json", []byte(`{"id": "b1", "local": true}`), 0600)
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a13"},
+		{User: "b", ID: "b1", Version: "b12", fetch: true, body: `{"id": "b1", "remote": true}`},
+	})
+
+	_, err = run("bulk", "sync", "--prefer-remote")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Equal(t, 0, exitCode)
+	mustEqualJSON(t, "b/items/b1.json", `{"id": "b1", "remote": true}`)
+
+	// Running it again does nothing.
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a13"},
+		{User: "b", ID: "b1", Version: "b12"},
+	})
+
+	out, err = run("bulk", "sync")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "Already in sync")
+	require.Equal(t, syncExitNothing, exitCode)
+}
+
+func TestJSONChanges(t *testing.T) {
+	changes, err := jsonChanges(
+		[]byte(`{"id": "a1", "a/b": 1, "tags": ["x", "y"], "nested": {"old": true}}`),
+		[]byte(`{"nested": {"new": null}, "tags": ["x", "z", "w"], "a/b": 2, "id": "a1"}`),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []valueChange{
+		{Pointer: "/a~1b", Op: "replace", Before: 1.0, After: 2.0},
+		{Pointer: "/nested/new", Op: "add"},
+		{Pointer: "/nested/old", Op: "remove", Before: true},
+		{Pointer: "/tags/1", Op: "replace", Before: "y", After: "z"},
+		{Pointer: "/tags/2", Op: "add", After: "w"},
+	}, changes)
+
+	changes, err = jsonChanges(nil, []byte(`{"id": "a1"}`))
+	require.NoError(t, err)
+	require.Equal(t, []valueChange{{Pointer: "", Op: "add", After: map[string]any{"id": "a1"}}}, changes)
+}
+
+func TestDiffJSON(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+		{User: "b", ID: "b2", Version: "b21", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "labels": ["one"]}`), 0600)
+	expectRemoteFile(remoteFile{User: "a", ID: "a1"})
+
+	out, err := run("bulk", "diff", "--format", "json")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	// Skip any verbose logs left enabled by other tests.
+	out = out[strings.LastIndex(out, "\n[")+1:]
+	require.JSONEq(t, `[{
+		"path": "a/items/a1.json",
+		"url": "https://example.com/users/a/items/a1",
+		"change": "modified",
+		"changes": [{"pointer": "/labels", "op": "add", "after": ["one"]}]
+	}]`, out)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	out, err = run("bulk", "diff", "--remote", "--format", "json")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	out = out[strings.LastIndex(out, "\n[")+1:]
+	require.JSONEq(t, `[{
+		"path": "b/items/b2.json",
+		"url": "https://example.com/users/b/items/b2",
+		"change": "removed",
+		"changes": [{"pointer": "", "op": "remove", "before": {"id": "b2"}}]
+	}]`, out)
+
+	_, err = run("bulk", "diff", "--format", "xml")
+	require.ErrorContains(t, err, "unknown format xml, expected one of html, json, text")
+}
+
+func TestDiffHTML(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true, body: `{"id": "a1", "name": "one", "rank": 1}`},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "name": "<two>", "rank": 1}`), 0600)
+	afero.WriteFile(afs, "c/items/c1.json", []byte(`{"id": "c1"}`), 0600)
+	expectRemoteFile(remoteFile{User: "a", ID: "a1", body: `{"id": "a1", "name": "one", "rank": 1}`})
+
+	_, err := run("bulk", "diff", "--format", "html", "--output", "/tmp/review.html")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	b, err := afero.ReadFile(afs, "/tmp/review.html")
+	require.NoError(t, err)
+	report := string(b)
+	require.True(t, strings.HasPrefix(report, "<!DOCTYPE html>"))
+	require.Contains(t, report, "2 files changed: 1 added, 1 modified, 0 removed")
+	require.Contains(t, report, `<a href="#file-1"><span class="badge modified">modified</span><span>a/items/a1.json</span></a>`)
+	require.Contains(t, report, `<a href="#file-2"><span class="badge added">added</span><span>c/items/c1.json</span></a>`)
+	require.Contains(t, report, `<td class="num">3</td><td class="del">`)
+	require.Contains(t, report, `<td class="num">3</td><td class="add">`)
+	require.Contains(t, report, "&lt;two&gt;")
+	require.NotContains(t, report, "<two>")
+
+	// Everything is inlined so it renders offline.
+	require.NotContains(t, report, "<link")
+	require.NotContains(t, report, "<script src")
+}
+
+func TestPushRename(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+		{User: "b", ID: "b2", Version: "b21", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "slug": "renamed"}`), 0600)
+	afero.WriteFile(afs, "b/items/b1.json", []byte(`{"id": "b1", "changed": true}`), 0600)
+	afero.WriteFile(afs, "b/items/b2.json", []byte(`{"id": "b2", "changed": true}`), 0600)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b11"},
+		{User: "b", ID: "b2", Version: "b21"},
+	})
+
+	// The write is repeated at the new URL with the same method and body.
+	gock.New("https://example.com").
+		Put("/users/a/items/a1").
+		Reply(http.StatusPermanentRedirect).
+		SetHeader("Location", "/users/a/items/renamed")
+
+	gock.New("https://example.com").
+		Put("/users/a/items/renamed").
+		BodyString(`{"id": "a1", "slug": "renamed"}`).
+		Reply(http.StatusNoContent)
+
+	// Redirects to other hosts and loops fail safely.
+	gock.New("https://example.com").
+		Put("/users/b/items/b1").
+		Reply(http.StatusMovedPermanently).
+		SetHeader("Location", "https://other.example.org/users/b/items/b1")
+
+	gock.New("https://example.com").
+		Put("/users/b/items/b2").
+		Reply(http.StatusTemporaryRedirect).
+		SetHeader("Location", "/users/b/items/b2-moved")
+
+	gock.New("https://example.com").
+		Put("/users/b/items/b2-moved").
+		Reply(http.StatusTemporaryRedirect).
+		SetHeader("Location", "/users/b/items/b2")
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "renamed", Version: "a12", fetch: true, body: `{"id": "a1", "slug": "renamed"}`},
+		{User: "b", ID: "b1", Version: "b11"},
+		{User: "b", ID: "b2", Version: "b21"},
+	})
+
+	out, _ := run("bulk", "push")
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "refusing to follow redirect from https://example.com/users/b/items/b1 to another host https://other.example.org/users/b/items/b1")
+	require.Contains(t, out, "redirect loop from https://example.com/users/b/items/b2-moved to https://example.com/users/b/items/b2")
+	require.Contains(t, out, "Renamed on the remote:\n\ta/items/a1.json -> a/items/renamed.json")
+
+	exists, _ := afero.Exists(afs, "a/items/a1.json")
+	require.False(t, exists)
+	b, err := afero.ReadFile(afs, "a/items/renamed.json")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id": "a1", "slug": "renamed"}`, string(b))
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+	require.Nil(t, meta.Files["a/items/a1.json"])
+	require.Equal(t, "https://example.com/users/a/items/renamed", meta.Files["a/items/renamed.json"].URL)
+	require.False(t, meta.Files["a/items/renamed.json"].IsChangedLocal(true))
+	require.True(t, meta.Files["b/items/b1.json"].IsChangedLocal(true))
+}
+
+func TestIndexSchema(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://example.com").
+		Get("/all-items").
+		Reply(http.StatusOK).
+		JSON([]map[string]any{
+			{"user": "a", "id": "a1", "version": "a11", "schema": "schemas/a.json"},
+			{"user": "b", "id": "b1", "version": "b11"},
+		})
+
+	// The index entry's schema wins over the link on the item.
+	gock.New("https://example.com").
+		Get("/users/a/items/a1").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Link", `</schemas/linked.json>; rel="describedby"`).
+		BodyString(`{"id": "a1", "name": "one"}`)
+
+	gock.New("https://example.com").
+		Get("/users/b/items/b1").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Link", `</schemas/linked.json>; rel="describedby"`).
+		BodyString(`{"id": "b1", "name": "two"}`)
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight(remoteFile{User: "a", ID: "a1"}, remoteFile{User: "b", ID: "b1"})
+	_, err := run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}", "--schema-field", "schema")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+	require.Equal(t, "schema", meta.SchemaField)
+	require.Equal(t, "https://example.com/schemas/a.json", meta.Files["a/items/a1.json"].Schema)
+	require.Equal(t, "https://example.com/schemas/linked.json", meta.Files["b/items/b1.json"].Schema)
+
+	// Match expressions are checked against the per-item schema.
+	gock.New("https://example.com").
+		Get("/schemas/a.json").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"type": "object", "properties": {"name": {"type": "string"}}}`)
+
+	gock.New("https://example.com").
+		Get("/schemas/linked.json").
+		Reply(http.StatusNotFound)
+
+	out, err := run("bulk", "list", "--match", "name > 5")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "WARN: cannot compare string with number")
+}
+
+// fakeStdin is a stdin with the given mode, e.g. a terminal.
+type fakeStdin struct {
+	*strings.Reader
+	mode fs.FileMode
+}
+
+func (f fakeStdin) Stat() (fs.FileInfo, error) { return f, nil }
+func (f fakeStdin) Name() string               { return "stdin" }
+func (f fakeStdin) Size() int64                { return f.Reader.Size() }
+func (f fakeStdin) Mode() fs.FileMode          { return f.mode }
+func (f fakeStdin) ModTime() time.Time         { return time.Time{} }
+func (f fakeStdin) IsDir() bool                { return false }
+func (f fakeStdin) Sys() any                   { return nil }
+
+func TestPushInteractive(t *testing.T) {
+	defer gock.Off()
+	defer func() { cli.Stdin = os.Stdin }()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+		{User: "b", ID: "b2", Version: "b21", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "changed": true}`), 0600)
+	afero.WriteFile(afs, "b/items/b1.json", []byte(`{"id": "b1", "changed": true}`), 0600)
+	afs.Remove("b/items/b2.json")
+
+	// Without a terminal to ask on the push fails rather than waiting.
+	cli.Stdin = fakeStdin{strings.NewReader("y\n"), 0}
+	_, err := run("bulk", "push", "--interactive")
+	require.ErrorContains(t, err, "--interactive requires a terminal on stdin")
+
+	// Diffs are shown from the cached copy and quitting pushes nothing.
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b11"},
+		{User: "b", ID: "b2", Version: "b21"},
+	})
+
+	cli.Stdin = fakeStdin{strings.NewReader("?\nd\nq\n"), fs.ModeCharDevice}
+	out, err := run("bulk", "push", "-i")
+	require.ErrorContains(t, err, "push aborted, nothing was pushed")
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "(1/3) Push modified a/items/a1.json [y,n,a,d,q,?]? ")
+	require.Contains(t, out, "d - show the diff of this file")
+	require.Contains(t, out, `+  "changed": true`)
+
+	// Skipped files stay modified, and accepting all pushes the rest.
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b11"},
+		{User: "b", ID: "b2", Version: "b21"},
+	})
+
+	gock.New("https://example.com").
+		Put("/users/b/items/b1").
+		Reply(http.StatusNoContent)
+
+	gock.New("https://example.com").
+		Delete("/users/b/items/b2").
+		Reply(http.StatusNoContent)
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b12", fetch: true, body: `{"id": "b1", "changed": true}`},
+	})
+
+	cli.Stdin = fakeStdin{strings.NewReader("n\na\n"), fs.ModeCharDevice}
+	out, err = run("bulk", "push", "-i")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "(2/3) Push modified b/items/b1.json")
+	require.NotContains(t, out, "(3/3)")
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+	require.True(t, meta.Files["a/items/a1.json"].IsChangedLocal(true))
+	require.False(t, meta.Files["b/items/b1.json"].IsChangedLocal(true))
+	require.Nil(t, meta.Files["b/items/b2.json"])
+}
+
+func TestReadOnly(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}", "--read-only")
+	mustHaveCalledAllHTTPMocks(t)
+	mustContain(t, ".rshbulk/meta", `"read_only": true`)
+
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id": "a1", "changed": true}`), 0600)
+
+	// Nothing is requested when pushing, but the status still works.
+	_, err := run("bulk", "push")
+	require.ErrorContains(t, err, "this checkout is read-only")
+
+	_, err = run("bulk", "sync")
+	require.ErrorContains(t, err, "this checkout is read-only, use `bulk config set read_only false` to allow pushing, or `bulk pull` to only pull")
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "b", ID: "b1", Version: "b11"},
+	})
+
+	out, err := run("bulk", "status")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "a/items/a1.json")
+
+	// The option can be changed via the config.
+	out, err = run("bulk", "config", "get", "read_only")
+	require.NoError(t, err)
+	require.Contains(t, out, "true\n")
+
+	_, err = run("bulk", "config", "set", "read_only", "nope")
+	require.ErrorContains(t, err, `invalid value "nope" for read_only, expected true or false`)
+
+	_, err = run("bulk", "config", "set", "readonly", "false")
+	require.ErrorContains(t, err, "unknown setting readonly, expected one of accept, content_type")
+
+	_, err = run("bulk", "config", "set", "read_only", "false")
+	require.NoError(t, err)
+
+	out, err = run("bulk", "config")
+	require.NoError(t, err)
+	require.Contains(t, out, "read_only = false\n")
+
+	var meta Meta
+	require.NoError(t, loadMeta(&meta))
+	require.False(t, meta.ReadOnly)
+}
+
+func TestVerifyRemote(t *testing.T) {
+	defer gock.Off()
+
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "b", ID: "b1", Version: "b11", fetch: true},
+		{User: "b", ID: "b2", Version: "b21", fetch: true},
+	})
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectPreflight()
+	run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Formatting differences don't count.
+	afero.WriteFile(afs, "a/items/a1.json", []byte(`{"id":"a1"}`), 0600)
+	afero.WriteFile(afs, "b/items/b1.json", []byte(`{"id": "b1", "changed": true}`), 0600)
+
+	gock.New("https://example.com").
+		Get("/users/a/items/a1").
+		HeaderPresent("If-None-Match").
+		Reply(http.StatusNotModified)
+
+	gock.New("https://example.com").
+		Get("/users/b/items/b1").
+		Reply(http.StatusOK).
+		JSON(map[string]any{"id": "b1"})
+
+	gock.New("https://example.com").
+		Get("/users/b/items/b2").
+		Reply(http.StatusNotFound)
+
+	out, err := run("bulk", "verify-remote")
+	require.ErrorContains(t, err, "2 of 3 resources don't match the remote")
+	mustHaveCalledAllHTTPMocks(t)
+	require.Contains(t, out, "differing:  b/items/b1.json\n")
+	require.Contains(t, out, "missing:  b/items/b2.json (missing on the remote: not found)")
+	require.Contains(t, out, "1 identical, 1 differing, 1 missing, 0 errors")
+	require.NotContains(t, out, "a/items/a1.json")
+
+	// Nothing in the checkout is changed.
+	mustEqualJSON(t, ".rshbulk/b/items/b1.json", `{"id": "b1"}`)
+
+	// Paths and JSON output for pipelines.
+	gock.New("https://example.com").
+		Get("/users/a/items/a1").
+		Reply(http.StatusOK).
+		JSON(map[string]any{"id": "a1"})
+
+	out, err = run("bulk", "verify-remote", "a", "--format", "json")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.JSONEq(t, `{
+		"identical": 1,
+		"differing": 0,
+		"missing": 0,
+		"errors": 0,
+		"files": [{"path": "a/items/a1.json", "url": "https://example.com/users/a/items/a1", "result": "identical"}]
+	}`, out)
+}
+
+func TestUserAgent(t *testing.T) {
+	defer gock.Off()
+
+	afs = afero.NewMemMapFs()
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+
+	expectRemoteAs := func(userAgent, version string) {
+		matcher := "^" + regexp.QuoteMeta(userAgent) + "$"
+		gock.New("https://example.com").
+			Get("/all-items").
+			MatchHeader("User-Agent", matcher).
+			Reply(http.StatusOK).
+			JSON([]remoteFile{{User: "a", ID: "a1", Version: version}, {User: "b", ID: "b1", Version: "b11"}})
+
+		gock.New("https://example.com").
+			Get("/users/a/items/a1").
+			MatchHeader("User-Agent", matcher).
+			Reply(http.StatusOK).
+			SetHeader("Content-Type", "application/json").
+			BodyString(`{"id": "a1", "version": "` + version + `"}`)
+	}
+
+	expectRemoteAs("restish/1.0.0 bulk/init", "a11")
+	expectRemoteFile(remoteFile{User: "b", ID: "b1", Version: "b11"})
+	expectPreflight(remoteFile{User: "a", ID: "a1"}, remoteFile{User: "b", ID: "b1"})
+	_, err := run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	_, err = run("bulk", "config", "set", "user_agent", "mirror-bot/2.0")
+	require.NoError(t, err)
+
+	expectRemoteAs("mirror-bot/2.0", "a12")
+	_, err = run("bulk", "pull")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	// The commandline overrides the checkout.
+	expectRemoteAs("one-off", "a13")
+	_, err = run("bulk", "pull", "--rsh-user-agent", "one-off")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	// Checkouts used from Go identify the method being called.
+	_, err = run("bulk", "config", "set", "user_agent", "")
+	require.NoError(t, err)
+
+	checkout, err := Open("")
+	require.NoError(t, err)
+
+	previous := cli.UserAgent
+	expectRemoteAs("restish/1.0.0 bulk/pull", "a14")
+	_, err = checkout.Pull(context.Background(), PullOptions{})
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+	require.Equal(t, previous, cli.UserAgent)
+
+	// Ordinary requests keep their own User-Agent after a bulk command.
+	gock.New("https://example.com").
+		Get("/plain").
+		MatchHeader("User-Agent", "^restish-1\\.0\\.0$").
+		Reply(http.StatusNoContent)
+	_, err = run("https://example.com/plain")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+}
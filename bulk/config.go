@@ -0,0 +1,135 @@
+package bulk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// errReadOnly is returned when trying to push from a read-only checkout.
+var errReadOnly = errors.New("this checkout is read-only, use `bulk config set read_only false` to allow pushing")
+
+// configSetting is a checkout option which can be read and changed via
+// `bulk config`.
+type configSetting struct {
+	get func(m *Meta) string
+	set func(m *Meta, value string) error
+}
+
+// stringSetting returns a setting for a string field, optionally uppercased
+// like HTTP methods.
+func stringSetting(field func(m *Meta) *string, upper bool) configSetting {
+	return configSetting{
+		get: func(m *Meta) string { return *field(m) },
+		set: func(m *Meta, value string) error {
+			if upper {
+				value = strings.ToUpper(value)
+			}
+			*field(m) = value
+			return nil
+		},
+	}
+}
+
+// configSettings are the checkout options by their name in the metadata.
+var configSettings = map[string]configSetting{
+	"read_only": {
+		get: func(m *Meta) string { return strconv.FormatBool(m.ReadOnly) },
+		set: func(m *Meta, value string) error {
+			v, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value %q for read_only, expected true or false", value)
+			}
+			m.ReadOnly = v
+			return nil
+		},
+	},
+	"accept":                 stringSetting(func(m *Meta) *string { return &m.Accept }, false),
+	"content_type":           stringSetting(func(m *Meta) *string { return &m.ContentType }, false),
+	"message_header":         stringSetting(func(m *Meta) *string { return &m.MessageHeader }, false),
+	"update_method":          stringSetting(func(m *Meta) *string { return &m.UpdateMethod }, true),
+	"create_method":          stringSetting(func(m *Meta) *string { return &m.CreateMethod }, true),
+	"delete_method":          stringSetting(func(m *Meta) *string { return &m.DeleteMethod }, true),
+	"method_override_header": stringSetting(func(m *Meta) *string { return &m.MethodOverrideHeader }, false),
+	"sign_key":               stringSetting(func(m *Meta) *string { return &m.SignKey }, false),
+	"user_agent":             stringSetting(func(m *Meta) *string { return &m.UserAgent }, false),
+}
+
+// configKeys returns the sorted names of the checkout options.
+func configKeys() []string {
+	keys := make([]string, 0, len(configSettings))
+	for k := range configSettings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// getConfigSetting returns a checkout option by name.
+func getConfigSetting(key string) (configSetting, error) {
+	setting, ok := configSettings[key]
+	if !ok {
+		return setting, fmt.Errorf("unknown setting %s, expected one of %s", key, strings.Join(configKeys(), ", "))
+	}
+	return setting, nil
+}
+
+// configCommand returns the `bulk config` command and its subcommands.
+func configCommand() *cobra.Command {
+	completeKeys := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return configKeys(), cobra.ShellCompDirectiveNoFileComp
+	}
+
+	config := &cobra.Command{
+		GroupID: "local",
+		Use:     "config",
+		Short:   "Show or change the options saved in the checkout",
+		Long:    "Show the options saved in the checkout, such as whether it is read-only and the methods and headers used to push. Use `get` and `set` to read or change a single option. Empty options use their defaults.",
+		Example: "  " + os.Args[0] + " bulk config\n  " + os.Args[0] + " bulk config set read_only false",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			meta := mustLoadMeta()
+			for _, k := range configKeys() {
+				fmt.Fprintf(cli.Stdout, "%s = %s\n", k, configSettings[k].get(meta))
+			}
+		},
+	}
+
+	get := &cobra.Command{
+		Use:               "get key",
+		Short:             "Show a checkout option",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeKeys,
+		Run: func(cmd *cobra.Command, args []string) {
+			setting, err := getConfigSetting(args[0])
+			panicOnErr(err)
+			fmt.Fprintln(cli.Stdout, setting.get(mustLoadMeta()))
+		},
+	}
+
+	set := &cobra.Command{
+		Use:               "set key value",
+		Short:             "Change a checkout option",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeKeys,
+		Run: func(cmd *cobra.Command, args []string) {
+			setting, err := getConfigSetting(args[0])
+			panicOnErr(err)
+			meta := mustLoadMeta()
+			panicOnErr(setting.set(meta, args[1]))
+			panicOnErr(meta.Save())
+		},
+	}
+
+	config.AddCommand(get, set)
+	return config
+}
@@ -0,0 +1,241 @@
+package bulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// valueChange is a change of a single value in a JSON document, using the
+// operation names of JSON Patch.
+type valueChange struct {
+	Pointer string `json:"pointer"`
+	Op      string `json:"op"`
+	Before  any    `json:"before,omitempty"`
+	After   any    `json:"after,omitempty"`
+}
+
+// escapePointer escapes a key for use in a JSON pointer.
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// compareValues appends the changes between two parsed JSON values. Objects
+// and arrays are compared recursively, with array items by index.
+func compareValues(pointer string, before, after any, changes []valueChange) []valueChange {
+	switch b := before.(type) {
+	case map[string]any:
+		if a, ok := after.(map[string]any); ok {
+			keys := []string{}
+			for k := range b {
+				keys = append(keys, k)
+			}
+			for k := range a {
+				if _, ok := b[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				p := pointer + "/" + escapePointer(k)
+				bv, inBefore := b[k]
+				av, inAfter := a[k]
+				switch {
+				case !inAfter:
+					changes = append(changes, valueChange{Pointer: p, Op: "remove", Before: bv})
+				case !inBefore:
+					changes = append(changes, valueChange{Pointer: p, Op: "add", After: av})
+				default:
+					changes = compareValues(p, bv, av, changes)
+				}
+			}
+			return changes
+		}
+	case []any:
+		if a, ok := after.([]any); ok {
+			for i := 0; i < len(b) || i < len(a); i++ {
+				p := pointer + "/" + strconv.Itoa(i)
+				switch {
+				case i >= len(a):
+					changes = append(changes, valueChange{Pointer: p, Op: "remove", Before: b[i]})
+				case i >= len(b):
+					changes = append(changes, valueChange{Pointer: p, Op: "add", After: a[i]})
+				default:
+					changes = compareValues(p, b[i], a[i], changes)
+				}
+			}
+			return changes
+		}
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		changes = append(changes, valueChange{Pointer: pointer, Op: "replace", Before: before, After: after})
+	}
+	return changes
+}
+
+// jsonChanges computes the semantic changes between two JSON documents, where
+// a missing document is empty. Formatting and key order are ignored.
+func jsonChanges(before, after []byte) ([]valueChange, error) {
+	var b, a any
+	if len(before) > 0 {
+		if err := json.Unmarshal(before, &b); err != nil {
+			return nil, err
+		}
+	}
+	if len(after) > 0 {
+		if err := json.Unmarshal(after, &a); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case len(before) == 0 && len(after) == 0:
+		return []valueChange{}, nil
+	case len(before) == 0:
+		return []valueChange{{Pointer: "", Op: "add", After: a}}, nil
+	case len(after) == 0:
+		return []valueChange{{Pointer: "", Op: "remove", Before: b}}, nil
+	}
+	return compareValues("", b, a, []valueChange{}), nil
+}
+
+// localChanges returns the changes of a tracked file's local edits against
+// the last fetched remote version, without making any requests.
+func (f *File) localChanges() ([]valueChange, error) {
+	cached, err := afero.ReadFile(afs, filepath.Join(metaDir, f.Path))
+	if err != nil {
+		return nil, err
+	}
+	local, _ := readLocal(f.Path)
+	return jsonChanges(cached, local)
+}
+
+// changedPointers summarizes changes as a list of their JSON pointers.
+func changedPointers(changes []valueChange) string {
+	pointers := make([]string, 0, len(changes))
+	for _, c := range changes {
+		if c.Pointer == "" {
+			pointers = append(pointers, "/")
+			continue
+		}
+		pointers = append(pointers, c.Pointer)
+	}
+	return strings.Join(pointers, ", ")
+}
+
+// fileDiff is the difference between two versions of a file for `bulk diff`.
+type fileDiff struct {
+	Path   string `json:"path"`
+	URL    string `json:"url"`
+	Change string `json:"change"`
+
+	// from and to name the two versions, e.g. `local a/items/a1.json`.
+	from, to      string
+	before, after []byte
+}
+
+// diffRenderer outputs the file diffs of `bulk diff` in some format. The
+// `empty` message is used when no files changed.
+type diffRenderer interface {
+	file(d fileDiff) error
+	done(empty string) error
+}
+
+// diffRenderers creates renderers writing to w by `--format` name.
+var diffRenderers = map[string]func(w io.Writer) diffRenderer{
+	"text": func(w io.Writer) diffRenderer { return &textDiff{w: w} },
+	"json": func(w io.Writer) diffRenderer { return &jsonDiff{w: w, files: []jsonFileDiff{}} },
+	"html": func(w io.Writer) diffRenderer { return &htmlDiff{w: w, files: []*htmlFile{}} },
+}
+
+// diffFormats returns the sorted names of the diff formats.
+func diffFormats() []string {
+	names := []string{}
+	for name := range diffRenderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newDiffRenderer returns the renderer for a `--format` name.
+func newDiffRenderer(format string, w io.Writer) (diffRenderer, error) {
+	create := diffRenderers[format]
+	if create == nil {
+		return nil, fmt.Errorf("unknown format %s, expected one of %s", format, strings.Join(diffFormats(), ", "))
+	}
+	return create(w), nil
+}
+
+// textDiff renders unified diffs, colorized if enabled.
+type textDiff struct {
+	w     io.Writer
+	files int
+}
+
+func (r *textDiff) file(d fileDiff) error {
+	r.files++
+	diff(r.w, d.from, d.to, d.before, d.after)
+	return nil
+}
+
+func (r *textDiff) done(empty string) error {
+	if r.files == 0 {
+		fmt.Fprintln(r.w, empty)
+	}
+	return nil
+}
+
+// jsonFileDiff is a file in the JSON diff output.
+type jsonFileDiff struct {
+	fileDiff
+	Changes []valueChange `json:"changes"`
+}
+
+// jsonDiff renders a JSON array of changed files, each with its changes by
+// JSON pointer, for other tools to consume.
+type jsonDiff struct {
+	w     io.Writer
+	files []jsonFileDiff
+}
+
+func (r *jsonDiff) file(d fileDiff) error {
+	values, err := jsonChanges(d.before, d.after)
+	if err != nil {
+		return fmt.Errorf("unable to parse %s: %w", d.Path, err)
+	}
+	r.files = append(r.files, jsonFileDiff{d, values})
+	return nil
+}
+
+func (r *jsonDiff) done(empty string) error {
+	b, err := cli.MarshalShort("json", true, r.files)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.w, string(b))
+	return nil
+}
+
+// writeDiffOutput writes a rendered diff to a file. Files inside the checkout
+// show up as added, so this warns about them.
+func writeDiffOutput(path string, b []byte) error {
+	if rel, err := filepath.Rel(".", path); err == nil && !filepath.IsAbs(rel) && !strings.HasPrefix(rel, "..") && !strings.HasPrefix(rel, ".") {
+		cli.LogWarning("%s is inside the checkout and will show as an added file, consider writing it elsewhere", path)
+	}
+	if err := afero.WriteFile(afs, path, b, 0600); err != nil {
+		return err
+	}
+	cli.LogInfo("Wrote diff to %s", path)
+	return nil
+}
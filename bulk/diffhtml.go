@@ -0,0 +1,229 @@
+package bulk
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// htmlDiffStyle is the syntax highlighting style of HTML diff reports, which
+// use a light background regardless of the terminal theme.
+const htmlDiffStyle = "github"
+
+// htmlRow is a row of a side-by-side diff. Rows without line numbers on
+// either side are hunk headers.
+type htmlRow struct {
+	Hunk                string
+	LeftNum, RightNum   int
+	Left, Right         template.HTML
+	LeftKind, RightKind string
+}
+
+// htmlFile is a file in an HTML diff report.
+type htmlFile struct {
+	fileDiff
+	ID       string
+	From, To string
+	Added    int
+	Removed  int
+	Rows     []htmlRow
+	Error    string
+}
+
+// htmlDiff renders a self-contained HTML report with side-by-side diffs,
+// e.g. to attach to a change ticket for review. All styles and scripts are
+// inlined so it renders without network access.
+type htmlDiff struct {
+	w     io.Writer
+	files []*htmlFile
+}
+
+// highlightLine returns the syntax highlighted HTML of a line of JSON.
+func highlightLine(formatter *html.Formatter, style *chroma.Style, line string) template.HTML {
+	lexer := lexers.Get("json")
+	iterator, err := lexer.Tokenise(nil, line)
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(line))
+	}
+	sb := &strings.Builder{}
+	if err := formatter.Format(sb, style, iterator); err != nil {
+		return template.HTML(template.HTMLEscapeString(line))
+	}
+	return template.HTML(strings.TrimRight(sb.String(), "\n"))
+}
+
+// sideBySide converts a unified diff into side-by-side rows, pairing each run
+// of removed lines with the added lines which follow it.
+func sideBySide(u gotextdiff.Unified, highlight func(string) template.HTML) ([]htmlRow, int, int) {
+	rows := []htmlRow{}
+	added, removed := 0, 0
+
+	for _, hunk := range u.Hunks {
+		left, right := hunk.FromLine, hunk.ToLine
+		deleted := []htmlRow{}
+		inserted := []htmlRow{}
+
+		flush := func() {
+			for i := 0; i < len(deleted) || i < len(inserted); i++ {
+				row := htmlRow{LeftKind: "empty", RightKind: "empty"}
+				if i < len(deleted) {
+					row.LeftNum, row.Left, row.LeftKind = deleted[i].LeftNum, deleted[i].Left, "del"
+				}
+				if i < len(inserted) {
+					row.RightNum, row.Right, row.RightKind = inserted[i].RightNum, inserted[i].Right, "add"
+				}
+				rows = append(rows, row)
+			}
+			deleted, inserted = deleted[:0], inserted[:0]
+		}
+
+		rows = append(rows, htmlRow{Hunk: fmt.Sprintf("@@ -%d +%d @@", hunk.FromLine, hunk.ToLine)})
+		for _, line := range hunk.Lines {
+			content := highlight(strings.TrimSuffix(line.Content, "\n"))
+			switch line.Kind {
+			case gotextdiff.Delete:
+				deleted = append(deleted, htmlRow{LeftNum: left, Left: content})
+				left++
+				removed++
+			case gotextdiff.Insert:
+				inserted = append(inserted, htmlRow{RightNum: right, Right: content})
+				right++
+				added++
+			default:
+				flush()
+				rows = append(rows, htmlRow{LeftNum: left, RightNum: right, Left: content, Right: content})
+				left++
+				right++
+			}
+		}
+		flush()
+	}
+
+	return rows, added, removed
+}
+
+func (r *htmlDiff) file(d fileDiff) error {
+	f := &htmlFile{fileDiff: d, ID: fmt.Sprintf("file-%d", len(r.files)+1), From: d.from, To: d.to}
+	r.files = append(r.files, f)
+
+	before, err := prettyJSON(d.before)
+	if err == nil {
+		var after []byte
+		if after, err = prettyJSON(d.after); err == nil {
+			formatter := html.New(html.WithClasses(true), html.PreventSurroundingPre(true))
+			style := styles.Get(htmlDiffStyle)
+			edits := myers.ComputeEdits(span.URIFromPath("remote"), string(before), string(after))
+			u := gotextdiff.ToUnified(d.from, d.to, string(before), edits)
+			f.Rows, f.Added, f.Removed = sideBySide(u, func(line string) template.HTML {
+				return highlightLine(formatter, style, line)
+			})
+		}
+	}
+	if err != nil {
+		f.Error = fmt.Sprintf("Unable to parse %s: %s", d.Path, err)
+	}
+	return nil
+}
+
+func (r *htmlDiff) done(empty string) error {
+	css := &strings.Builder{}
+	formatter := html.New(html.WithClasses(true))
+	if err := formatter.WriteCSS(css, styles.Get(htmlDiffStyle)); err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	added, removed := 0, 0
+	for _, f := range r.files {
+		counts[f.Change]++
+		added += f.Added
+		removed += f.Removed
+	}
+
+	return htmlDiffTemplate.Execute(r.w, map[string]any{
+		"Generated": time.Now().UTC().Format(time.RFC3339),
+		"Files":     r.files,
+		"Changed":   pluralize(len(r.files), "file"),
+		"Counts":    counts,
+		"Added":     added,
+		"Removed":   removed,
+		"Empty":     empty,
+		"CSS":       template.CSS(css.String()),
+	})
+}
+
+var htmlDiffTemplate = template.Must(template.New("diff").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Bulk diff review</title>
+<style>
+body { margin: 0; font: 14px -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; color: #24292f; display: flex; height: 100vh; }
+nav { width: 300px; flex-shrink: 0; overflow-y: auto; border-right: 1px solid #d0d7de; background: #f6f8fa; padding: 12px; box-sizing: border-box; }
+nav input { width: 100%; box-sizing: border-box; padding: 4px 8px; margin-bottom: 8px; }
+nav ul { list-style: none; margin: 0; padding: 0; }
+nav li a { display: flex; gap: 6px; padding: 3px 4px; color: inherit; text-decoration: none; word-break: break-all; border-radius: 4px; }
+nav li a:hover { background: #eaeef2; }
+main { flex-grow: 1; overflow-y: auto; padding: 16px 24px; }
+.summary { margin-bottom: 16px; }
+.badge { font-size: 11px; font-weight: 600; padding: 0 6px; border-radius: 10px; color: #fff; height: 18px; line-height: 18px; flex-shrink: 0; }
+.added { background: #1a7f37; } .modified { background: #9a6700; } .removed { background: #cf222e; }
+.plus { color: #1a7f37; } .minus { color: #cf222e; }
+section { border: 1px solid #d0d7de; border-radius: 6px; margin-bottom: 24px; }
+section h2 { font-size: 14px; margin: 0; padding: 8px 12px; background: #f6f8fa; border-bottom: 1px solid #d0d7de; display: flex; gap: 8px; align-items: center; }
+section h2 small { font-weight: normal; color: #57606a; }
+table { width: 100%; border-collapse: collapse; table-layout: fixed; font: 12px ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+td { padding: 0 8px; vertical-align: top; white-space: pre-wrap; word-break: break-all; }
+td.num { width: 40px; text-align: right; color: #57606a; user-select: none; }
+td.del { background: #ffebe9; } td.add { background: #e6ffec; } td.empty { background: #f6f8fa; }
+tr.hunk td { background: #ddf4ff; color: #57606a; padding: 2px 8px; }
+.error { padding: 8px 12px; color: #cf222e; }
+{{.CSS}}
+.chroma { background: none; }
+</style>
+</head>
+<body>
+<nav>
+<input type="search" placeholder="Filter files" oninput="filterFiles(this.value)">
+<ul id="files">
+{{range .Files}}<li data-path="{{.Path}}"><a href="#{{.ID}}"><span class="badge {{.Change}}">{{.Change}}</span><span>{{.Path}}</span></a></li>
+{{end}}</ul>
+</nav>
+<main>
+<h1>Bulk diff review</h1>
+<div class="summary">
+{{.Changed}} changed: {{index .Counts "added"}} added, {{index .Counts "modified"}} modified, {{index .Counts "removed"}} removed, with <span class="plus">+{{.Added}}</span> <span class="minus">-{{.Removed}}</span> lines. Generated {{.Generated}}.
+</div>
+{{if not .Files}}<p>{{.Empty}}</p>{{end}}
+{{range .Files}}<section id="{{.ID}}">
+<h2><span class="badge {{.Change}}">{{.Change}}</span> {{.Path}} <small>{{.URL}}</small> <small><span class="plus">+{{.Added}}</span> <span class="minus">-{{.Removed}}</span></small></h2>
+{{if .Error}}<div class="error">{{.Error}}</div>{{else}}<table class="chroma">
+<tr class="hunk"><td class="num"></td><td>{{.From}}</td><td class="num"></td><td>{{.To}}</td></tr>
+{{range .Rows}}{{if .Hunk}}<tr class="hunk"><td colspan="4">{{.Hunk}}</td></tr>
+{{else}}<tr><td class="num">{{if .LeftNum}}{{.LeftNum}}{{end}}</td><td class="{{.LeftKind}}">{{.Left}}</td><td class="num">{{if .RightNum}}{{.RightNum}}{{end}}</td><td class="{{.RightKind}}">{{.Right}}</td></tr>
+{{end}}{{end}}</table>{{end}}
+</section>
+{{end}}
+</main>
+<script>
+function filterFiles(query) {
+  query = query.toLowerCase();
+  document.querySelectorAll("#files li").forEach(function (li) {
+    li.style.display = li.dataset.path.toLowerCase().indexOf(query) === -1 ? "none" : "";
+  });
+}
+</script>
+</body>
+</html>
+`))
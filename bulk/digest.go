@@ -0,0 +1,87 @@
+package bulk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// errDigestMismatch is returned when a fetched body doesn't match the digest
+// sent by the server.
+var errDigestMismatch = errors.New("content digest mismatch")
+
+// digestAlgorithms are the supported RFC 9530 digest algorithms.
+var digestAlgorithms = map[string]func([]byte) []byte{
+	"sha-256": func(b []byte) []byte {
+		sum := sha256.Sum256(b)
+		return sum[:]
+	},
+	"sha-512": func(b []byte) []byte {
+		sum := sha512.Sum512(b)
+		return sum[:]
+	},
+}
+
+// parseDigests parses the algorithms and values of a `Content-Digest` header,
+// which is a structured field dictionary like `sha-256=:base64:`. With
+// `legacy` set the older RFC 3230 `Digest` header is parsed instead, which
+// looks like `SHA-256=base64`. Invalid values are skipped.
+func parseDigests(header string, legacy bool) map[string][]byte {
+	digests := map[string][]byte{}
+	for _, member := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok {
+			continue
+		}
+		// Parameters aren't used by any registered algorithm, so drop them.
+		value, _, _ = strings.Cut(value, ";")
+		value = strings.TrimSpace(value)
+		if !legacy {
+			if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+				continue
+			}
+			value = value[1 : len(value)-1]
+		}
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		digests[strings.ToLower(name)] = b
+	}
+	return digests
+}
+
+// verifyDigest checks a response body against its `Content-Digest` header,
+// falling back to the legacy `Digest` header. Every supported algorithm must
+// match. The verified digests are returned in `Content-Digest` form, or an
+// empty string if the server sent none which could be checked. Unknown
+// algorithms are ignored.
+func verifyDigest(header http.Header, body []byte) (string, error) {
+	digests := parseDigests(header.Get("Content-Digest"), false)
+	if len(digests) == 0 {
+		digests = parseDigests(header.Get("Digest"), true)
+	}
+
+	verified := []string{}
+	for name, expected := range digests {
+		sum := digestAlgorithms[name]
+		if sum == nil {
+			cli.BulkLog.Debug("Ignoring unsupported digest algorithm %s", name)
+			continue
+		}
+		if !bytes.Equal(sum(body), expected) {
+			return "", errDigestMismatch
+		}
+		verified = append(verified, name+"=:"+base64.StdEncoding.EncodeToString(expected)+":")
+	}
+
+	sort.Strings(verified)
+	return strings.Join(verified, ", "), nil
+}
@@ -2,13 +2,14 @@ package bulk
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"net/url"
 	"path"
 	"path/filepath"
-	"reflect"
 
 	"github.com/spf13/afero"
 	"github.com/tarunKoyalwar/restish/cli"
@@ -21,8 +22,9 @@ func reformat(data []byte) ([]byte, error) {
 	// Round-trip to get consistent formatting. This is inefficient but a much
 	// nicer experience for people with auto-formatters set up in their editor
 	// or who may try to undo changes and get the formatting slightly off.
+	// Comments and trailing commas in local files don't count as changes.
 	var tmp any
-	json.Unmarshal(data, &tmp)
+	json.Unmarshal(stripJSONC(data), &tmp)
 	return cli.MarshalShort("json", true, tmp)
 }
 
@@ -32,6 +34,19 @@ func hash(b []byte) []byte {
 	return tmp[:]
 }
 
+var (
+	// errNotModified is returned by a conditional fetch of an unchanged file.
+	errNotModified = errors.New("not modified")
+
+	// errRemoved is returned when fetching a file which was deliberately
+	// deleted on the remote, i.e. the server responded with `410 Gone`.
+	errRemoved = errors.New("gone from the remote")
+
+	// errNotFound is returned when fetching a file gives a `404 Not Found`,
+	// which may just be a transient misconfiguration of the server.
+	errNotFound = errors.New("not found")
+)
+
 // File represents a checked out file with metadata about the remote and local
 // version(s) of the file.
 type File struct {
@@ -55,6 +70,18 @@ type File struct {
 
 	// Hash is used for detecting local changes
 	Hash []byte `json:"hash,omitempty"`
+
+	// Digest is the `Content-Digest` of the last fetch if the server sent one
+	// and the received body matched it.
+	Digest string `json:"digest,omitempty"`
+
+	// Deprecation is set if the server marked the resource as deprecated or
+	// scheduled for removal when it was last fetched.
+	Deprecation *cli.Deprecation `json:"deprecation,omitempty"`
+
+	// indexSchema is the schema from the index entry after it was pulled,
+	// which takes precedence over `describedby` links and `$schema` fields.
+	indexSchema string
 }
 
 // GetData returns the file contents.
@@ -88,20 +115,94 @@ func (f *File) IsChangedRemote() bool {
 	return f.VersionLocal != f.VersionRemote
 }
 
-// Fetch pulls the remote file and updates the metadata.
-func (f *File) Fetch() ([]byte, error) {
-	req, _ := http.NewRequest(http.MethodGet, f.URL, nil)
-	// TODO: conditional fetch?
-	resp, err := cli.GetParsedResponse(req)
+// Fetch pulls the remote file and updates the metadata. A `410 Gone` response
+// returns `errRemoved` while a `404 Not Found` returns `errNotFound`.
+func (f *File) Fetch(ctx context.Context, m *Meta) ([]byte, error) {
+	return f.fetch(ctx, m, false)
+}
+
+// FetchIfChanged pulls the remote file only if its ETag or Last-Modified
+// differs from the stored metadata, returning `errNotModified` if it doesn't.
+func (f *File) FetchIfChanged(ctx context.Context, m *Meta) ([]byte, error) {
+	return f.fetch(ctx, m, true)
+}
+
+// fetch pulls the remote file, optionally as a conditional request. If the
+// server sends a digest of the body it must match, otherwise the fetch fails
+// with `errDigestMismatch` and nothing is written.
+func (f *File) fetch(ctx context.Context, m *Meta, conditional bool) ([]byte, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if conditional {
+		if f.ETag != "" {
+			req.Header.Set("If-None-Match", f.ETag)
+		} else if f.LastModified != "" {
+			req.Header.Set("If-Modified-Since", f.LastModified)
+		}
+	}
+	httpResp, err := cli.MakeRequest(req, cli.WithMediaTypes(m.Accept, m.ContentType))
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the body as received to check it against the digest.
+	raw, err := cli.ReadBody(httpResp)
+	httpResp.Body.Close()
 	if err != nil {
 		return nil, err
 	}
+	httpResp.Body = io.NopCloser(bytes.NewReader(raw))
+
+	resp, err := cli.ParseResponseFor(req, httpResp)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Status {
+	case http.StatusNotModified:
+		if conditional {
+			return nil, errNotModified
+		}
+	case http.StatusNotFound:
+		return nil, errNotFound
+	case http.StatusGone:
+		return nil, errRemoved
+	}
 
 	if resp.Status >= http.StatusBadRequest {
 		cli.LogError("Error fetching %s from %s\n", f.Path, f.URL)
 		cli.Formatter.Format(resp)
-		return nil, fmt.Errorf("error fetching %s", f.URL)
+		return nil, &cli.ErrHTTP{Status: resp.Status, Body: resp.Body, URL: f.URL, Message: fmt.Sprintf("error fetching %s", f.URL)}
+	}
+
+	digest, err := verifyDigest(httpResp.Header, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := f.update(resp)
+	f.Digest = digest
+	return b, err
+}
+
+// hasDocument returns whether a response contains the full document for a
+// file along with an ETag, e.g. a `200 OK` in response to a `PUT` from servers
+// which return the canonical updated resource.
+func hasDocument(resp cli.Response) bool {
+	if resp.Status == http.StatusNoContent || resp.Headers["Etag"] == "" {
+		return false
 	}
+	switch resp.Body.(type) {
+	case map[string]any, []any:
+		return true
+	}
+	return false
+}
+
+// update sets the metadata and cached copy of the file from a response
+// containing its remote contents, returning the formatted contents.
+func (f *File) update(resp cli.Response) ([]byte, error) {
+	f.Digest = ""
+	f.Deprecation = cli.ParseDeprecation(resp.HeaderValues)
 
 	if etag := resp.Headers["Etag"]; etag != "" {
 		f.ETag = etag
@@ -111,17 +212,14 @@ func (f *File) Fetch() ([]byte, error) {
 		f.LastModified = lastModified
 	}
 
-	if db := resp.Links["describedby"]; len(db) > 0 {
-		baseURL, _ := url.Parse(f.URL)
-		u, _ := url.Parse(db[0].URI)
-		f.Schema = baseURL.ResolveReference(u).String()
-	} else {
-		v := reflect.ValueOf(resp.Body)
-		if v.Kind() == reflect.Map && !v.IsNil() {
-			if s := v.MapIndex(reflect.ValueOf("$schema")); s.Kind() == reflect.String {
-				// Assume this is not a relative URL as it lives within the doc.
-				f.Schema = v.String()
-			}
+	if f.indexSchema != "" {
+		f.Schema = f.indexSchema
+	} else if db, ok := resp.FirstLink("describedby"); ok {
+		f.Schema = db
+	} else if m, ok := resp.Body.(map[string]any); ok {
+		if s, ok := m["$schema"].(string); ok {
+			// Assume this is not a relative URL as it lives within the doc.
+			f.Schema = s
 		}
 	}
 
@@ -154,6 +252,24 @@ func (f *File) Write(b []byte) error {
 	return afero.WriteFile(afs, f.Path, b, 0600)
 }
 
+// writePreservingComments writes the file like `Write`, but keeps the comments
+// of the existing local file where possible, see `mergeComments`. The hash is
+// of the contents without comments so the file doesn't show as modified.
+func (f *File) writePreservingComments(b []byte) error {
+	old, err := afero.ReadFile(afs, f.Path)
+	if err != nil {
+		return f.Write(b)
+	}
+
+	merged, dropped := mergeComments(old, b)
+	if dropped > 0 {
+		cli.LogWarning("Dropped %d comments from %s whose lines were removed", dropped, f.Path)
+	}
+
+	f.Hash = hash(b)
+	return afero.WriteFile(afs, f.Path, merged, 0600)
+}
+
 // Reset overwrites the local file with the remote contents.
 func (f *File) Reset() error {
 	cached, err := afero.ReadFile(afs, path.Join(metaDir, f.Path))
@@ -0,0 +1,157 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// defaultParallel is the default number of `HEAD` requests to send at once.
+const defaultParallel = 4
+
+// headResult is the outcome of checking a single file's freshness.
+type headResult uint8
+
+const (
+	headUnchanged headResult = iota
+	headChanged
+	headRemoved
+	headUnknown
+)
+
+// sameETag compares two entity tags, ignoring whether they are weak since
+// only the representation needs to be compared.
+func sameETag(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// checkHead compares the remote ETag or Last-Modified of a file against the
+// stored metadata using a `HEAD` request. Servers which don't support `HEAD`
+// get a conditional `GET` instead, where a `304 Not Modified` means the file
+// is unchanged. Probe statuses are handled here rather than reported as the
+// command's exit code.
+func checkHead(ctx context.Context, m *Meta, f *File) (headResult, string, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodHead, f.URL, nil)
+	resp, err := cli.MakeRequest(req, cli.WithMediaTypes(m.Accept, m.ContentType), cli.IgnoreStatus())
+	if err != nil {
+		return headUnknown, "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		cli.BulkLog.Debug("HEAD not supported for %s, using a conditional GET", f.URL)
+		req, _ = http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+		if f.ETag != "" {
+			req.Header.Set("If-None-Match", f.ETag)
+		} else if f.LastModified != "" {
+			req.Header.Set("If-Modified-Since", f.LastModified)
+		}
+		resp, err = cli.MakeRequest(req, cli.WithMediaTypes(m.Accept, m.ContentType), cli.IgnoreStatus())
+		if err != nil {
+			return headUnknown, "", err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotModified {
+			return headUnchanged, "", nil
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusGone:
+		return headRemoved, "", nil
+	case resp.StatusCode == http.StatusNotFound:
+		return headUnknown, "", errNotFound
+	case resp.StatusCode >= http.StatusBadRequest:
+		return headUnknown, "", &cli.ErrHTTP{Status: resp.StatusCode, URL: f.URL, Message: fmt.Sprintf("server responded with %s", resp.Status)}
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" && f.ETag != "" {
+		if sameETag(etag, f.ETag) {
+			return headUnchanged, "", nil
+		}
+		return headChanged, etag, nil
+	}
+
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" && f.LastModified != "" {
+		if lastModified == f.LastModified {
+			return headUnchanged, "", nil
+		}
+		return headChanged, lastModified, nil
+	}
+
+	return headUnknown, "", nil
+}
+
+// PullHeads updates the remote versions of checked out files by checking each
+// one with a `HEAD` request instead of fetching the index, with up to
+// `parallel` requests at once. Remote additions can't be detected this way.
+// Files which can't be checked keep their last known remote version and are
+// reported as a warning.
+func (m *Meta) PullHeads(ctx context.Context, parallel int) error {
+	files := []*File{}
+	for _, f := range m.Files {
+		if f.VersionLocal == "" {
+			// Never fetched, so there is nothing to compare against.
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	results := make([]headResult, len(files))
+	versions := make([]string, len(files))
+	errs := make([]error, len(files))
+
+	cli.RunParallel(ctx, len(files), parallel, func(ctx context.Context, i int) error {
+		results[i], versions[i], errs[i] = checkHead(ctx, m, files[i])
+		if errors.Is(errs[i], errNotFound) && m.treat404AsGone {
+			results[i], errs[i] = headRemoved, nil
+		}
+		return nil
+	})
+
+	unknown := 0
+	for i, f := range files {
+		switch results[i] {
+		case headUnchanged:
+			f.VersionRemote = f.VersionLocal
+		case headChanged:
+			f.VersionRemote = versions[i]
+		case headRemoved:
+			f.VersionRemote = ""
+		case headUnknown:
+			if errs[i] != nil {
+				cli.LogWarning("Unable to check %s: %v", f.Path, errs[i])
+			} else {
+				cli.BulkLog.Debug("No ETag or Last-Modified to compare for %s", f.Path)
+			}
+			unknown++
+		}
+	}
+
+	if unknown > 0 {
+		cli.LogWarning("Unable to check %d of %d files, using their last known remote versions", unknown, len(files))
+	}
+
+	return nil
+}
+
+// GetChangedHead calculates the changed local and remote files like
+// `GetChanged`, but using `HEAD` requests rather than the index to find
+// remote changes.
+func (m *Meta) GetChangedHead(ctx context.Context, files []string, parallel int) ([]changedFile, []changedFile, error) {
+	if err := m.PullHeads(ctx, parallel); err != nil {
+		return nil, nil, err
+	}
+
+	local, remote := m.changed(files)
+	return local, remote, nil
+}
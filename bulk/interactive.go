@@ -0,0 +1,76 @@
+package bulk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// interactiveHelp explains the answers to the per-file push prompt.
+const interactiveHelp = `y - push this file
+n - do not push this file
+a - push this and all remaining files
+d - show the diff of this file
+q - quit, pushing nothing
+? - print help`
+
+// stdinIsTTY returns true if answers to prompts can be read from stdin.
+func stdinIsTTY() bool {
+	info, err := cli.Stdin.Stat()
+	return err == nil && (info.Mode()&os.ModeCharDevice) != 0
+}
+
+// selectChanges asks which of the local changes to push, one file at a time,
+// before anything is uploaded. The diff of a file is rendered from its cached
+// remote copy so no requests are made. A nil selection means the push was
+// aborted.
+func (m *Meta) selectChanges(local []changedFile) []changedFile {
+	in := bufio.NewReader(cli.Stdin)
+	selected := []changedFile{}
+	for i := 0; i < len(local); i++ {
+		changed := local[i]
+		fmt.Fprintf(cli.Stdout, "(%d/%d) Push %s %s [y,n,a,d,q,?]? ", i+1, len(local), changed.Status.label(), changed.File.Path)
+		line, err := in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			fmt.Fprintln(cli.Stdout)
+			return nil
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y":
+			selected = append(selected, changed)
+		case "n":
+		case "a":
+			return append(selected, local[i:]...)
+		case "d":
+			m.diffChange(changed)
+			i--
+		case "q":
+			return nil
+		default:
+			fmt.Fprintln(cli.Stdout, interactiveHelp)
+			i--
+		}
+	}
+
+	return selected
+}
+
+// diffChange shows the diff of a local change against the cached remote copy.
+func (m *Meta) diffChange(changed changedFile) {
+	f := changed.File
+	var remote, local []byte
+	if changed.Status != statusAdded {
+		remote, _ = afero.ReadFile(afs, filepath.Join(metaDir, f.Path))
+	}
+	if changed.Status != statusRemoved {
+		local, _ = readLocal(f.Path)
+	}
+	diff(cli.Stdout, "remote "+f.URL, "local "+f.Path, remote, local)
+}
@@ -0,0 +1,184 @@
+package bulk
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// stripJSONC converts JSONC, i.e. JSON with `//` and `/* */` comments and
+// trailing commas like JSON5 allows, into plain JSON. Comments are replaced by
+// spaces so that the offsets in parse errors still match the file. Plain JSON
+// is returned as-is.
+func stripJSONC(b []byte) []byte {
+	if bytes.IndexByte(b, '/') == -1 && bytes.IndexByte(b, ',') == -1 {
+		return b
+	}
+
+	out := make([]byte, len(b))
+	copy(out, b)
+
+	inString := false
+	lastComma := -1
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			lastComma = -1
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			out[i], out[i+1] = ' ', ' '
+			for i += 2; i < len(out); i++ {
+				if out[i] == '*' && i+1 < len(out) && out[i+1] == '/' {
+					out[i], out[i+1] = ' ', ' '
+					i++
+					break
+				}
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+		case c == ',':
+			lastComma = i
+		case c == '}' || c == ']':
+			if lastComma != -1 {
+				// Trailing comma before the end of an object or array.
+				out[lastComma] = ' '
+			}
+			lastComma = -1
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			// Whitespace between a comma and whatever follows it.
+		default:
+			lastComma = -1
+		}
+	}
+
+	return out
+}
+
+// readLocal reads a local file, removing any JSONC comments and trailing
+// commas so it can be parsed, diffed, and pushed as plain JSON.
+func readLocal(p string) ([]byte, error) {
+	b, err := afero.ReadFile(afs, p)
+	if err != nil {
+		return nil, err
+	}
+	return stripJSONC(b), nil
+}
+
+// jsonKey matches the key at the start of a line of formatted JSON.
+var jsonKey = regexp.MustCompile(`^\s*"((?:[^"\\]|\\.)*)"\s*:`)
+
+// commentAnchor returns the JSON content of a line without its comment and
+// trailing comma, used to find the same line in the new contents.
+func commentAnchor(line string) string {
+	return strings.TrimSuffix(strings.TrimSpace(line), ",")
+}
+
+// mergeComments is a best-effort attempt to carry the comments of a local
+// file over to its new contents after a pull. Each full-line comment is put
+// before, and each end of line comment after, the line it belonged to, which
+// is found by its content or otherwise by its key at the same indentation.
+// Comments whose line no longer exists are dropped and counted.
+func mergeComments(old, updated []byte) ([]byte, int) {
+	stripped := stripJSONC(old)
+	if bytes.Equal(stripped, old) {
+		return updated, 0
+	}
+
+	oldLines := strings.Split(string(old), "\n")
+	strippedLines := strings.Split(string(stripped), "\n")
+	newLines := strings.Split(string(updated), "\n")
+
+	before := map[int][]string{}
+	after := map[int]string{}
+	pending := []string{}
+	dropped := 0
+	pos := 0
+
+	find := func(line string) int {
+		anchor := commentAnchor(line)
+		for j := pos; j < len(newLines); j++ {
+			if commentAnchor(newLines[j]) == anchor {
+				return j
+			}
+		}
+		if m := jsonKey.FindStringSubmatch(line); m != nil {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			for j := pos; j < len(newLines); j++ {
+				if n := jsonKey.FindStringSubmatch(newLines[j]); n != nil && n[1] == m[1] && strings.HasPrefix(newLines[j], indent+"\"") {
+					return j
+				}
+			}
+		}
+		return -1
+	}
+
+	for i, line := range oldLines {
+		content := strings.TrimRight(strippedLines[i], " \t\r")
+		if strings.TrimSpace(content) == "" {
+			if strings.TrimSpace(line) != "" {
+				pending = append(pending, line)
+			}
+			continue
+		}
+
+		// Anything after the content apart from a removed trailing comma is an
+		// end of line comment.
+		trailing := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[len(content):]), ","))
+		if len(pending) == 0 && trailing == "" {
+			continue
+		}
+
+		j := find(content)
+		if j == -1 {
+			dropped += len(pending)
+			if trailing != "" {
+				dropped++
+			}
+			pending = nil
+			continue
+		}
+
+		before[j] = append(before[j], pending...)
+		if trailing != "" {
+			after[j] = trailing
+		}
+		pending = nil
+		pos = j + 1
+	}
+
+	out := make([]string, 0, len(newLines)+len(before))
+	for j, line := range newLines {
+		out = append(out, before[j]...)
+		if after[j] != "" {
+			line += " " + after[j]
+		}
+		out = append(out, line)
+	}
+	if len(pending) > 0 {
+		// Comments after the last line of JSON stay at the end.
+		end := len(out)
+		if out[end-1] == "" {
+			end--
+		}
+		out = append(out[:end], append(pending, out[end:]...)...)
+	}
+
+	return []byte(strings.Join(out, "\n")), dropped
+}
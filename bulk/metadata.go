@@ -2,6 +2,8 @@ package bulk
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -9,11 +11,11 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/danielgtaylor/shorthand/v2"
-	"github.com/logrusorgru/aurora"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/afero"
 	"github.com/spf13/viper"
@@ -85,15 +87,21 @@ func fileMsg(bar *progressbar.ProgressBar, resp *cli.Response, format string, ar
 type listEntry struct {
 	URL     string `json:"url"`
 	Version string `json:"version"`
+
+	// Schema is the item's JSON Schema URL from the index, see
+	// `Meta.SchemaField`.
+	Schema string `json:"schema,omitempty"`
+
+	// Item is the list response item the entry was built from.
+	Item any `json:"-"`
 }
 
 type fileStatus uint8
 
 const (
-	// Terminal color codes
-	statusAdded    = 150
-	statusModified = 172
-	statusRemoved  = 204
+	statusAdded fileStatus = iota + 1
+	statusModified
+	statusRemoved
 )
 
 // changedFile represents a file with a changed status (add/modify/remove)
@@ -102,14 +110,34 @@ type changedFile struct {
 	File   *File
 }
 
-func (c changedFile) String() string {
-	au := aurora.NewAurora(viper.GetBool("color"))
-	label := map[fileStatus]string{
+// label returns the name of a status like `modified`.
+func (s fileStatus) label() string {
+	return map[fileStatus]string{
 		statusAdded:    "added",
 		statusModified: "modified",
 		statusRemoved:  "removed",
+	}[s]
+}
+
+func (c changedFile) String() string {
+	label := c.Status.label()
+	element := map[fileStatus]string{
+		statusAdded:    "diff-add",
+		statusModified: "diff-change",
+		statusRemoved:  "diff-remove",
 	}[c.Status]
-	return fmt.Sprintf("\t%8s:  %s", au.Index(uint8(c.Status), label), c.File.Path)
+
+	// Pad before coloring so the escape codes don't count toward the width.
+	return fmt.Sprintf("\t%s:  %s", cli.Colorize(element, fmt.Sprintf("%8s", label)), c.File.Path)
+}
+
+// failedDelete formats a file whose remote deletion failed for display.
+func failedDelete(path string, status int) string {
+	label := "error"
+	if status != 0 {
+		label = strconv.Itoa(status)
+	}
+	return fmt.Sprintf("\t%s:  %s", cli.Colorize("diff-remove", fmt.Sprintf("%8s", label)), path)
 }
 
 // Meta represents metadata about the remote and local status of the checkout.
@@ -119,7 +147,137 @@ type Meta struct {
 	Base        string           `json:"base,omitempty"`
 	Schema      string           `json:"schema,omitempty"`
 	URLTemplate string           `json:"url_template,omitempty"`
+	SchemaField string           `json:"schema_field,omitempty"`
+	Accept      string           `json:"accept,omitempty"`
+	ContentType string           `json:"content_type,omitempty"`
 	Files       map[string]*File `json:"files,omitempty"`
+
+	// NoIndex is set for checkouts which track an explicit list of resources,
+	// see `bulk init --from-file`. Remote changes are found via conditional
+	// and `HEAD` requests for each file instead of an index.
+	NoIndex bool `json:"no_index,omitempty"`
+
+	// ReadOnly disables pushing from the checkout, e.g. for mirrors used by
+	// dashboards, see `bulk init --read-only` and `bulk config`.
+	ReadOnly bool `json:"read_only,omitempty"`
+
+	// UserAgent identifies the checkout's requests instead of the default
+	// `restish/<version> bulk/<command>`, see `bulk config`.
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// LastPull is when the checkout was last pulled successfully.
+	LastPull time.Time `json:"last_pull,omitempty"`
+
+	// IndexRefreshed is when the remote versions were last refreshed from the
+	// index, see `bulk status --no-fallback`.
+	IndexRefreshed time.Time `json:"index_refreshed,omitempty"`
+
+	// Initializing is set until the initial pull of a new checkout has fetched
+	// every file, see `bulk init --resume`.
+	Initializing bool `json:"initializing,omitempty"`
+
+	// FailedDeletes holds the HTTP status code, or zero for a request error, of
+	// locally removed files whose remote deletion failed. They stay staged and
+	// are retried on the next push.
+	FailedDeletes map[string]int `json:"failed_deletes,omitempty"`
+
+	// Untracked holds the paths of remote resources which are not checked out,
+	// see `bulk track` and `bulk untrack`.
+	Untracked []string `json:"untracked,omitempty"`
+
+	// MessageHeader is the request header used to send the push message, see
+	// `bulk push --message`. Defaults to `X-Change-Reason`.
+	MessageHeader string `json:"message_header,omitempty"`
+
+	// UpdateMethod, CreateMethod and DeleteMethod override the HTTP methods used
+	// to push modified, added and removed files, see `bulk push --update-method`.
+	// They default to `PUT`, `PUT` and `DELETE`.
+	UpdateMethod string `json:"update_method,omitempty"`
+	CreateMethod string `json:"create_method,omitempty"`
+	DeleteMethod string `json:"delete_method,omitempty"`
+
+	// MethodOverrideHeader is a request header used to send the default method
+	// when pushing with an overridden one, e.g. `X-HTTP-Method-Override: PUT`
+	// for APIs behind proxies which only allow `GET` and `POST`.
+	MethodOverrideHeader string `json:"method_override_header,omitempty"`
+
+	// SignKey is the path to an SSH private key used to sign push records, see
+	// `bulk push --sign-key`.
+	SignKey string `json:"sign_key,omitempty"`
+
+	// cachedIndex is when the remote versions were refreshed if `bulk status`
+	// fell back to them because the index was unavailable.
+	cachedIndex time.Time
+
+	// untracked holds the untracked remote resources by path after the index
+	// has been pulled.
+	untracked map[string]untrackedFile
+
+	// report summarizes the last pull or push for notifications.
+	report *report
+
+	// message describes the change being pushed, e.g. for server-side audit
+	// logs.
+	message string
+
+	// prefetched holds index entries which were already fetched, e.g. to check
+	// the URL template during init, for the next `PullIndex` to use.
+	prefetched []listEntry
+
+	// preserveComments keeps the comments of local files which are
+	// overwritten by a pull where possible, see `mergeComments`.
+	preserveComments bool
+
+	// skipPreflight disables checking the URL template before an init.
+	skipPreflight bool
+
+	// rate is the maximum number of requests per second for files during a
+	// pull or push, overriding the adaptive pacing from rate limit headers.
+	rate float64
+
+	// interactive asks which local changes to push before uploading any.
+	interactive bool
+
+	// treat404AsGone makes a `404 Not Found` for a file remove it like a
+	// `410 Gone`, for APIs which never send the latter.
+	treat404AsGone bool
+
+	// dir is the directory of a checkout opened via `Open`, or empty for the
+	// current directory.
+	dir string
+
+	// keepLocal holds the paths of conflicted files resolved in favor of the
+	// local version by `bulk sync --prefer-local`. They are skipped by the pull
+	// and pushed without preconditions.
+	keepLocal map[string]bool
+}
+
+// untrackedFile is a remote resource which is not checked out, along with the
+// list response item it came from.
+type untrackedFile struct {
+	File *File
+	Item any
+}
+
+// applyUserAgent identifies the requests of the running bulk command with the
+// checkout's User-Agent.
+func (m *Meta) applyUserAgent() {
+	if bulkCommand != "" {
+		cli.UserAgent = m.userAgent(bulkCommand)
+	}
+}
+
+// userAgent returns the User-Agent for requests made by a bulk command: the
+// one saved in the checkout, or the global `rsh-user-agent` config, or
+// `restish/<version> bulk/<command>`.
+func (m *Meta) userAgent(command string) string {
+	if m.UserAgent != "" {
+		return m.UserAgent
+	}
+	if ua := viper.GetString("rsh-user-agent"); ua != "" {
+		return ua
+	}
+	return "restish/" + cli.Root.Version + " bulk/" + command
 }
 
 // Save the metadata file to disk.
@@ -134,22 +292,130 @@ func (m *Meta) Save() error {
 
 // Init initializes the metadata file, saves it to disk, and then performs
 // the initial pull to fetch each file.
-func (m *Meta) Init(url, template string) error {
+func (m *Meta) Init(ctx context.Context, url, template string) error {
 	m.URL = cli.FixAddress(url)
 	m.Filter = viper.GetString("rsh-filter")
 	m.URLTemplate = template
+	m.Accept = viper.GetString("rsh-accept")
+	m.ContentType = viper.GetString("rsh-content-type")
 	m.Files = map[string]*File{}
+	m.Initializing = true
+
+	if m.URLTemplate != "" && !m.skipPreflight {
+		// Catch template typos before saving anything or fetching every file.
+		entries, err := m.fetchIndex(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.preflight(ctx, entries); err != nil {
+			return err
+		}
+		m.prefetched = entries
+	}
 
 	if err := m.Save(); err != nil {
 		return err
 	}
 
-	return m.Pull()
+	return m.initPull(ctx)
 }
 
 // PullIndex updates the index of remote files and their versions. It does not
-// save the metadata file.
-func (m *Meta) PullIndex() error {
+// save the metadata file. Checkouts without an index check each file with a
+// `HEAD` request instead.
+func (m *Meta) PullIndex(ctx context.Context) error {
+	if m.NoIndex {
+		return m.PullHeads(ctx, defaultParallel)
+	}
+
+	// The index may already have been fetched by the init preflight check.
+	entries := m.prefetched
+	m.prefetched = nil
+	if entries == nil {
+		var err error
+		if entries, err = m.fetchIndex(ctx); err != nil {
+			return err
+		}
+	}
+
+	m.IndexRefreshed = time.Now().UTC()
+	baseURL, _ := url.Parse(m.URL)
+	prefix, _ := url.Parse(commonPrefix(entries))
+	m.Base = baseURL.ResolveReference(prefix).String()
+
+	for _, f := range m.Files {
+		// Clear all the remote versions, we will set them for files that exist
+		// in the next step.
+		f.VersionRemote = ""
+	}
+
+	untracked := map[string]bool{}
+	for _, path := range m.Untracked {
+		untracked[path] = true
+	}
+	m.untracked = map[string]untrackedFile{}
+	m.Untracked = []string{}
+
+	for _, entry := range entries {
+		u, _ := url.Parse(entry.URL)
+		resolved := baseURL.ResolveReference(u).String()
+		path := resolved[len(m.Base):] + ".json"
+		if untracked[path] {
+			// Untracked resources removed from the remote are forgotten.
+			m.untracked[path] = untrackedFile{
+				File: &File{Path: path, URL: resolved, VersionRemote: entry.Version, Schema: entry.Schema, indexSchema: entry.Schema},
+				Item: entry.Item,
+			}
+			m.Untracked = append(m.Untracked, path)
+			continue
+		}
+		f := m.Files[path]
+		if f == nil {
+			// Remote file was added.
+			f = &File{
+				Path: path,
+				URL:  resolved,
+			}
+			m.Files[path] = f
+		}
+		f.VersionRemote = entry.Version
+		if entry.Schema != "" {
+			f.Schema = entry.Schema
+			f.indexSchema = entry.Schema
+		}
+	}
+
+	sort.Strings(m.Untracked)
+
+	return nil
+}
+
+// templateURL builds the URL of a list response item from the URL template,
+// also returning the names of any variables which are neither fields of the
+// item nor have a default from the API's path params.
+func (m *Meta) templateURL(item any) (string, []string) {
+	missing := []string{}
+	re := regexp.MustCompile(`\{[^}]+\}`)
+	u := re.ReplaceAllStringFunc(m.URLTemplate, func(match string) string {
+		match = strings.Trim(match, "{}")
+		if m, ok := item.(map[string]any); ok && m[match] != nil {
+			return fmt.Sprintf("%v", m[match])
+		}
+		if m, ok := item.(map[any]any); ok && m[match] != nil {
+			return fmt.Sprintf("%v", m[match])
+		}
+		if value, ok := cli.PathDefault(m.URL, match); ok {
+			return value
+		}
+		missing = append(missing, match)
+		return ""
+	})
+	return u, missing
+}
+
+// fetchIndex fetches the remote resource list and returns an entry with the
+// URL and version of each resource.
+func (m *Meta) fetchIndex(ctx context.Context) ([]listEntry, error) {
 	bar := progressbar.NewOptions(-1,
 		progressbar.OptionSetWriter(cli.Stdout),
 		progressbar.OptionEnableColorCodes(true),
@@ -180,16 +446,17 @@ func (m *Meta) PullIndex() error {
 		done <- true
 	}()
 
-	req, _ := http.NewRequest(http.MethodGet, m.URL, nil)
-	parsed, err := cli.GetParsedResponse(req)
-	if err != nil {
-		panic(err)
-	}
-
-	if parsed.Status >= http.StatusBadRequest {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, m.URL, nil)
+	parsed, err := cli.GetParsedResponse(req, cli.WithStatusError(), cli.WithMediaTypes(m.Accept, m.ContentType))
+	var httpErr *cli.ErrHTTP
+	if errors.As(err, &httpErr) {
 		cli.LogError("Error fetching resource list %s\n", m.URL)
 		cli.Formatter.Format(parsed)
-		return fmt.Errorf("error fetching %s", m.URL)
+		httpErr.Message = fmt.Sprintf("error fetching %s", m.URL)
+		return nil, httpErr
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	var data any
@@ -197,13 +464,13 @@ func (m *Meta) PullIndex() error {
 		data = parsed.Body
 	} else {
 		opts := shorthand.GetOptions{}
-		if viper.GetBool("rsh-verbose") {
-			opts.DebugLogger = cli.LogDebug
+		if cli.BulkLog.Enabled(cli.LevelInfo) {
+			opts.DebugLogger = cli.BulkLog.Debug
 		}
 
 		result, _, err := shorthand.GetPath(m.Filter, parsed.Map(), opts)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		data = result
@@ -214,83 +481,135 @@ func (m *Meta) PullIndex() error {
 	}
 
 	var entries []listEntry
+	indexURL, _ := url.Parse(m.URL)
 
 	for _, entry := range data.([]any) {
 		// Try to get a {url, version} tuple from various possible common key names.
 		url := getFirstKey(entry, "url", "uri", "self", "link")
 		if url == "" && m.URLTemplate != "" {
 			// We have a way to build the URL from other fields in the response.
-			re := regexp.MustCompile(`\{[^}]+\}`)
-			url = re.ReplaceAllStringFunc(m.URLTemplate, func(match string) string {
-				match = strings.Trim(match, "{}")
-				if m, ok := entry.(map[string]any); ok {
-					return fmt.Sprintf("%v", m[match])
-				}
-				if m, ok := entry.(map[any]any); ok {
-					return fmt.Sprintf("%v", m[match])
-				}
-				return ""
-			})
+			// Fields missing from the item use the API's path param defaults.
+			url, _ = m.templateURL(entry)
 		}
 
 		version := getFirstKey(entry, "version", "etag", "last_modified", "lastModified", "modified")
 
 		if (url == "") || (version == "") {
-			return fmt.Errorf("list response must contain a URL and version for each resource")
+			return nil, fmt.Errorf("list response must contain a URL and version for each resource")
 		}
-		entries = append(entries, listEntry{url, version})
+
+		schema := ""
+		if m.SchemaField != "" {
+			if s := getFirstKey(entry, m.SchemaField); s != "" {
+				if ref, err := indexURL.Parse(s); err == nil {
+					schema = ref.String()
+				}
+			}
+		}
+		entries = append(entries, listEntry{url, version, schema, entry})
 	}
 
-	baseURL, _ := url.Parse(m.URL)
-	prefix, _ := url.Parse(commonPrefix(entries))
-	m.Base = baseURL.ResolveReference(prefix).String()
+	return entries, nil
+}
 
+// pendingPulls returns the files which need to be fetched or removed to get
+// up to date with the remote, sorted by path.
+func (m *Meta) pendingPulls() []*File {
+	updates := []*File{}
 	for _, f := range m.Files {
-		// Clear all the remote versions, we will set them for files that exist
-		// in the next step.
-		f.VersionRemote = ""
+		if f.VersionLocal != "" && f.VersionLocal == f.VersionRemote {
+			// No need to redownload this.
+			continue
+		}
+
+		updates = append(updates, f)
 	}
 
-	for _, entry := range entries {
-		u, _ := url.Parse(entry.URL)
-		resolved := baseURL.ResolveReference(u).String()
-		path := resolved[len(m.Base):] + ".json"
-		f := m.Files[path]
-		if f == nil {
-			// Remote file was added.
-			f = &File{
-				Path: path,
-				URL:  resolved,
-			}
-			m.Files[path] = f
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Path < updates[j].Path
+	})
+
+	return updates
+}
+
+// printRequest writes out a request that would have been made during a dry
+// run, either as a curl command or as just the method and URL.
+func (m *Meta) printRequest(req *http.Request) error {
+	if viper.GetBool("rsh-curl") {
+		if err := cli.PrepareRequest(req, cli.WithMediaTypes(m.Accept, m.ContentType)); err != nil {
+			return err
 		}
-		f.VersionRemote = entry.Version
+		cmd, err := cli.CurlCommand(req, !viper.GetBool("rsh-show-secrets"))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cli.Stdout, cmd)
+		return nil
 	}
 
+	fmt.Fprintln(cli.Stdout, req.Method+" "+req.URL.String())
 	return nil
 }
 
-// Pull files from the remote. In the case of local changes this will update
-// the index but *not* overwrite the local file containing the edits. When
-// the pull completes, the metadata file is saved.
-func (m *Meta) Pull() error {
-	if err := m.PullIndex(); err != nil {
+// PullDryRun refreshes the index and prints the requests that a pull would
+// make without fetching or modifying any files.
+func (m *Meta) PullDryRun(ctx context.Context) error {
+	if err := m.PullIndex(ctx); err != nil {
 		return err
 	}
 
-	updates := []*File{}
-	for _, f := range m.Files {
-		if f.VersionLocal != "" && f.VersionLocal == f.VersionRemote {
-			// No need to redownload this.
+	updates := m.pendingPulls()
+	if len(updates) == 0 {
+		fmt.Fprintln(cli.Stdout, "Already up to date.")
+		return nil
+	}
+
+	for _, f := range updates {
+		if f.VersionRemote == "" {
+			fmt.Fprintf(cli.Stdout, "Would remove %s\n", f.Path)
 			continue
 		}
 
-		updates = append(updates, f)
+		req, _ := http.NewRequest(http.MethodGet, f.URL, nil)
+		if err := m.printRequest(req); err != nil {
+			return err
+		}
 	}
 
+	return nil
+}
+
+// Pull files from the remote. In the case of local changes this will update
+// the index but *not* overwrite the local file containing the edits. When
+// the pull completes, the metadata file is saved.
+func (m *Meta) Pull(ctx context.Context) error {
+	m.report = newReport("pull", m.URL)
+	defer m.report.observe()()
+	defer m.noteDeprecations()
+
+	var updates []*File
+	if m.NoIndex {
+		// Every file is checked with a conditional request instead.
+		updates = m.sortedFiles()
+	} else {
+		if err := m.PullIndex(ctx); err != nil {
+			return err
+		}
+		updates = m.pendingPulls()
+	}
+	if len(m.keepLocal) > 0 {
+		pending := []*File{}
+		for _, f := range updates {
+			if !m.keepLocal[f.Path] {
+				pending = append(pending, f)
+			}
+		}
+		updates = pending
+	}
 	if len(updates) == 0 {
 		fmt.Fprintln(cli.Stdout, "Already up to date.")
-		return nil
+		m.LastPull = time.Now().UTC()
+		return m.Save()
 	}
 
 	bar := progressbar.NewOptions(len(updates),
@@ -299,24 +618,63 @@ func (m *Meta) Pull() error {
 		progressbar.OptionSetDescription("Pulling resources..."),
 	)
 
-	for _, f := range updates {
+	for i, f := range updates {
+		if ctx.Err() != nil {
+			// Stop between files so no completed work is lost.
+			return m.cancelled(i, len(updates))
+		}
+
+		start := time.Now()
+		m.report.current = f.Path
+
+		var b []byte
+		gone := false
+		if f.VersionRemote != "" || m.NoIndex {
+			if err := m.report.pacer.wait(ctx, m.rate); err != nil {
+				return m.cancelled(i, len(updates))
+			}
+			var err error
+			b, err = m.fetch(ctx, f, m.NoIndex)
+			switch {
+			case err != nil && ctx.Err() != nil:
+				// The request was cancelled, so this file wasn't processed.
+				return m.cancelled(i, len(updates))
+			case errors.Is(err, errNotModified):
+				bar.Add(1)
+				continue
+			case errors.Is(err, errRemoved):
+				f.VersionRemote = ""
+				gone = true
+			case errors.Is(err, errNotFound):
+				// This may be transient, so don't delete anything.
+				m.report.fail(bar, nil, f.Path, "Error fetching %s from %s: %s, keeping the local file (use --treat-404-as-gone if it was deleted)\n", f.Path, f.URL, err)
+				continue
+			case err != nil:
+				m.report.fail(bar, nil, f.Path, "Error fetching %s from %s: %s\n", f.Path, f.URL, err)
+				continue
+			}
+		}
+
 		if f.VersionRemote == "" {
 			// This was removed on the remote!
 			delete(m.Files, f.Path)
 			m.Save()
-			if !f.IsChangedLocal(true) {
+			changedLocal := f.IsChangedLocal(true)
+			if exists, _ := afero.Exists(afs, f.Path); exists && !changedLocal {
 				if err := afs.Remove(f.Path); err != nil {
-					fileMsg(bar, nil, "Error removing file %s: %s\n", f.Path, err)
+					m.report.fail(bar, nil, f.Path, "Error removing file %s: %s\n", f.Path, err)
 					continue
 				}
 			}
-			bar.Add(1)
-			continue
-		}
-
-		b, err := f.Fetch()
-		if err != nil {
-			fileMsg(bar, nil, "Error fetching %s from %s: %s\n", f.Path, f.URL, err)
+			m.report.Succeeded++
+			switch {
+			case gone && changedLocal:
+				fileMsg(bar, nil, "Untracked %s which is gone from the remote, keeping local edits\n", f.Path)
+			case gone:
+				fileMsg(bar, nil, "Removed %s which is gone from the remote\n", f.Path)
+			default:
+				bar.Add(1)
+			}
 			continue
 		}
 
@@ -328,19 +686,34 @@ func (m *Meta) Pull() error {
 		// Don't overwrite local edits!
 		if f.IsChangedLocal(true) {
 			fileMsg(bar, nil, "Skipping due to local edits: %s\n", f.Path)
+			m.report.Skipped++
 			continue
 		}
 
-		if err := f.Write(b); err != nil {
+		write := f.Write
+		if m.preserveComments {
+			write = f.writePreservingComments
+		}
+		if err := write(b); err != nil {
 			return err
 		}
 
+		cli.BulkLog.Debug("Pulled %s in %s", f.Path, time.Since(start).Round(time.Millisecond))
+		m.report.Succeeded++
 		bar.Add(1)
 	}
 
 	fmt.Fprintln(cli.Stdout)
 
-	return m.Save()
+	m.LastPull = time.Now().UTC()
+	if err := m.Save(); err != nil {
+		return err
+	}
+
+	if err := touchWorkspace(m.dir); err != nil {
+		cli.LogWarning("Unable to update workspace: %v", err)
+	}
+	return nil
 }
 
 // GetChanged calculates all the changed local and remote files using the
@@ -353,22 +726,35 @@ func (m *Meta) Pull() error {
 // - Added: Local file with no metadata entry
 // - Changed: Local file hash != remote file hash
 // - Removed: Metadata entry without local file
-func (m *Meta) GetChanged(files []string) ([]changedFile, []changedFile, error) {
-	if err := m.PullIndex(); err != nil {
+func (m *Meta) GetChanged(ctx context.Context, files []string) ([]changedFile, []changedFile, error) {
+	if err := m.PullIndex(ctx); err != nil {
 		return nil, nil, err
 	}
 
+	local, remote := m.changed(files)
+	return local, remote, nil
+}
+
+// changed calculates the changed local and remote files against the current
+// remote versions, see `GetChanged` for the rules.
+func (m *Meta) changed(files []string) ([]changedFile, []changedFile) {
 	filesMap := map[string]bool{}
 	for _, path := range files {
 		filesMap[path] = true
 	}
 
+	untracked := map[string]bool{}
+	for _, path := range m.Untracked {
+		untracked[path] = true
+	}
+
 	local := []changedFile{}
 	remote := []changedFile{}
 
 	for _, path := range files {
-		if strings.HasPrefix(path, ".") {
-			// Skip hidden dotfiles.
+		if strings.HasPrefix(path, ".") || untracked[path] {
+			// Skip hidden dotfiles and untracked resources, which must never be
+			// pushed.
 			continue
 		}
 		if f, ok := m.Files[path]; ok {
@@ -380,7 +766,9 @@ func (m *Meta) GetChanged(files []string) ([]changedFile, []changedFile, error)
 			} else if f.VersionLocal != f.VersionRemote {
 				remote = append(remote, changedFile{statusModified, f})
 			}
-		} else {
+		} else if m.Base != "" {
+			// Checkouts without an index spanning several hosts have no base
+			// to build the URL of new files from.
 			local = append(local, changedFile{
 				statusAdded, &File{
 					Path: path,
@@ -400,6 +788,19 @@ func (m *Meta) GetChanged(files []string) ([]changedFile, []changedFile, error)
 		}
 	}
 
+	// Failed deletes only stay staged while the file is still removed locally.
+	removed := map[string]bool{}
+	for _, changed := range local {
+		if changed.Status == statusRemoved {
+			removed[changed.File.Path] = true
+		}
+	}
+	for path := range m.FailedDeletes {
+		if !removed[path] {
+			delete(m.FailedDeletes, path)
+		}
+	}
+
 	// Sort by path for consistent output.
 	sort.Slice(remote, func(i, j int) bool {
 		return remote[i].File.Path < remote[j].File.Path
@@ -410,17 +811,124 @@ func (m *Meta) GetChanged(files []string) ([]changedFile, []changedFile, error)
 		return local[i].File.Path < local[j].File.Path
 	})
 
-	return local, remote, nil
+	return local, remote
+}
+
+// pushMethod returns the HTTP method used to push a locally changed file,
+// along with the default method it replaces.
+func (m *Meta) pushMethod(status fileStatus) (string, string) {
+	method, original := m.UpdateMethod, http.MethodPut
+	switch status {
+	case statusAdded:
+		method = m.CreateMethod
+	case statusRemoved:
+		method, original = m.DeleteMethod, http.MethodDelete
+	}
+	if method == "" {
+		method = original
+	}
+	return method, original
+}
+
+// methodNotAllowed returns a hint for a `405 Method Not Allowed` response to
+// a push, pointing at the option to configure the method.
+func (m *Meta) methodNotAllowed(status fileStatus) string {
+	flag := "--update-method"
+	switch status {
+	case statusAdded:
+		flag = "--create-method"
+	case statusRemoved:
+		flag = "--delete-method"
+	}
+	method, _ := m.pushMethod(status)
+	return fmt.Sprintf(": the server doesn't allow %s, configure another method with `bulk push %s` (plus `--method-override-header` if the API expects one)", method, flag)
+}
+
+// pushRequest builds the conditional request used to upload or delete a
+// locally changed file with the configured method, including the push message
+// if one was given. The body read from disk, if any, is also returned.
+func (m *Meta) pushRequest(ctx context.Context, changed changedFile) (*http.Request, []byte) {
+	f := changed.File
+
+	var req *http.Request
+	var body []byte
+	method, original := m.pushMethod(changed.Status)
+	if changed.Status == statusModified || changed.Status == statusAdded {
+		body, _ = readLocal(f.Path)
+		req, _ = http.NewRequestWithContext(ctx, method, f.URL, bytes.NewReader(body))
+	} else {
+		req, _ = http.NewRequestWithContext(ctx, method, f.URL, nil)
+	}
+
+	if m.MethodOverrideHeader != "" && method != original {
+		req.Header.Set(m.MethodOverrideHeader, original)
+	}
+
+	switch {
+	case m.keepLocal[f.Path]:
+		// Overwrite whatever is on the remote.
+	case f.ETag != "":
+		req.Header.Set("If-Match", f.ETag)
+	case f.LastModified != "":
+		req.Header.Set("If-Unmodified-Since", f.LastModified)
+	}
+
+	if m.message != "" {
+		req.Header.Set(m.messageHeader(), m.message)
+	}
+
+	return req, body
+}
+
+// PushDryRun prints the requests that a push would make without sending them.
+func (m *Meta) PushDryRun(ctx context.Context) error {
+	local, _, err := m.GetChanged(ctx, collectFiles(m, []string{}, "", false, false))
+	if err != nil {
+		return err
+	}
+
+	if len(local) == 0 {
+		fmt.Fprintln(cli.Stdout, "No local changes")
+		return nil
+	}
+
+	for _, changed := range local {
+		req, _ := m.pushRequest(ctx, changed)
+		if err := m.printRequest(req); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Push uploads changed files to the server, using conditional updates when
 // possible.
-func (m *Meta) Push() error {
-	local, _, err := m.GetChanged(collectFiles(m, []string{}, "", false))
+func (m *Meta) Push(ctx context.Context) error {
+	m.report = newReport("push", m.URL)
+	defer m.report.observe()()
+	defer m.noteDeprecations()
+	if m.ReadOnly {
+		return errReadOnly
+	}
+	if m.interactive && !stdinIsTTY() {
+		return fmt.Errorf("--interactive requires a terminal on stdin")
+	}
+	local, _, err := m.GetChanged(ctx, collectFiles(m, []string{}, "", false, false))
 	if err != nil {
 		return err
 	}
 
+	if m.interactive && len(local) > 0 {
+		if local = m.selectChanges(local); local == nil {
+			return fmt.Errorf("push aborted, nothing was pushed")
+		}
+		if len(local) == 0 {
+			fmt.Fprintln(cli.Stdout, "No files selected to push")
+			return nil
+		}
+	}
+
 	bar := progressbar.NewOptions(len(local),
 		progressbar.OptionSetWriter(cli.Stdout),
 		progressbar.OptionEnableColorCodes(true),
@@ -431,25 +939,50 @@ func (m *Meta) Push() error {
 	// metadata for them.
 	success := []changedFile{}
 
-	for _, changed := range local {
+	// Response statuses by path for the push record.
+	statuses := map[string]int{}
+
+	// How many files were handled before the push was cancelled, if it was.
+	processed := len(local)
+
+	for i, changed := range local {
+		if err := m.report.pacer.wait(ctx, m.rate); err != nil {
+			processed = i
+			break
+		}
+		start := time.Now()
 		f := changed.File
+		m.report.current = f.Path
+		req, body := m.pushRequest(ctx, changed)
 		if changed.Status == statusModified || changed.Status == statusAdded {
-			body, _ := afero.ReadFile(afs, f.Path)
-			req, _ := http.NewRequest(http.MethodPut, f.URL, bytes.NewReader(body))
-
-			if f.ETag != "" {
-				req.Header.Set("If-Match", f.ETag)
-			} else if f.LastModified != "" {
-				req.Header.Set("If-Unmodified-Since", f.LastModified)
+			resp, location, err := m.upload(req, body)
+			if err != nil && ctx.Err() != nil {
+				// Cancelled mid-upload, so the file stays modified.
+				processed = i
+				break
 			}
-
-			resp, err := cli.GetParsedResponse(req)
 			if err != nil {
-				fileMsg(bar, nil, "Error uploading %s to %s: %s\n", f.Path, f.URL, err)
+				m.report.fail(bar, nil, f.Path, "Error uploading %s to %s: %s\n", f.Path, f.URL, err)
+				continue
+			}
+			if resp.Status < 400 && location != f.URL {
+				if changed.Status == statusAdded {
+					m.Files[f.Path] = f
+				}
+				renamed, err := m.rename(f, location)
+				if err != nil {
+					m.report.fail(bar, nil, f.Path, "Error moving %s to %s: %s\n", renamed.From, renamed.To, err)
+					continue
+				}
+				m.report.Renamed = append(m.report.Renamed, renamed)
+			}
+			statuses[f.Path] = resp.Status
+			if resp.Status == http.StatusMethodNotAllowed {
+				m.report.fail(bar, &resp, f.Path, "Error uploading %s to %s%s\n", f.Path, f.URL, m.methodNotAllowed(changed.Status))
 				continue
 			}
 			if resp.Status >= 400 {
-				fileMsg(bar, &resp, "Error uploading %s to %s\n", f.Path, f.URL)
+				m.report.fail(bar, &resp, f.Path, "Error uploading %s to %s\n", f.Path, f.URL)
 				continue
 			}
 
@@ -469,44 +1002,71 @@ func (m *Meta) Push() error {
 				m.Save()
 			}
 
-			// Fetch and write the updated metadata/file to disk.
-			b, err := f.Fetch()
+			// Fetch and write the updated metadata/file to disk, unless the server
+			// already returned the updated document. The upload succeeded, so this
+			// bookkeeping is finished even if the push is being cancelled.
+			var b []byte
+			if hasDocument(resp) {
+				b, err = m.update(f, resp)
+			} else {
+				b, err = m.fetch(context.Background(), f, false)
+			}
 			if err != nil {
-				fileMsg(bar, nil, "Error fetching %s from %s: %s\n", f.Path, f.URL, err)
+				m.report.fail(bar, nil, f.Path, "Error fetching %s from %s: %s\n", f.Path, f.URL, err)
 				continue
 			}
 			if err := f.Write(b); err != nil {
-				fileMsg(bar, nil, "Error writing file %s: %s\n", f.Path, err)
+				m.report.fail(bar, nil, f.Path, "Error writing file %s: %s\n", f.Path, err)
 				continue
 			}
 		} else {
-			req, _ := http.NewRequest(http.MethodDelete, f.URL, nil)
-
-			if f.ETag != "" {
-				req.Header.Set("If-Match", f.ETag)
-			} else if f.LastModified != "" {
-				req.Header.Set("If-Unmodified-Since", f.LastModified)
+			resp, err := cli.GetParsedResponse(req, cli.WithMediaTypes(m.Accept, m.ContentType))
+			if err != nil && ctx.Err() != nil {
+				// Cancelled mid-delete, so the deletion stays staged.
+				processed = i
+				break
 			}
-
-			resp, err := cli.GetParsedResponse(req)
 			if err != nil {
-				fileMsg(bar, nil, "Error deleting %s from %s: %s\n", f.Path, f.URL, err)
+				m.report.fail(bar, nil, f.Path, "Error deleting %s from %s: %s\n", f.Path, f.URL, err)
+				m.failDelete(f.Path, 0)
 				continue
 			}
-			if resp.Status >= 400 {
-				fileMsg(bar, &resp, "Error deleting %s from %s\n", f.Path, f.URL)
+			statuses[f.Path] = resp.Status
+			if resp.Status >= 400 && resp.Status != http.StatusNotFound {
+				// Keep the deletion staged so the checkout and the server don't
+				// silently diverge, e.g. on a 409 Conflict or 423 Locked.
+				hint := ""
+				if resp.Status == http.StatusMethodNotAllowed {
+					hint = m.methodNotAllowed(changed.Status)
+				}
+				m.report.fail(bar, &resp, f.Path, "Error deleting %s from %s%s\n", f.Path, f.URL, hint)
+				m.failDelete(f.Path, resp.Status)
 				continue
 			}
+			// A 404 means the resource is already gone, which is what we want.
 			delete(m.Files, f.Path)
+			delete(m.FailedDeletes, f.Path)
 			m.Save()
 		}
 		success = append(success, changed)
+		m.report.Succeeded++
+		cli.BulkLog.Debug("Pushed %s in %s", f.Path, time.Since(start).Round(time.Millisecond))
 		bar.Add(1)
 	}
 
+	m.report.current = ""
+	m.writePushRecord(local[:processed], statuses)
+
+	if processed < len(local) {
+		// Pushed files get their new remote versions on the next pull instead.
+		return m.cancelled(processed, len(local))
+	}
 	fmt.Fprintln(cli.Stdout)
 
-	if err := m.PullIndex(); err != nil {
+	if err := m.PullIndex(ctx); err != nil {
+		if ctx.Err() != nil {
+			return m.cancelled(processed, len(local))
+		}
 		return err
 	}
 
@@ -521,6 +1081,69 @@ func (m *Meta) Push() error {
 		return err
 	}
 
+	if len(m.FailedDeletes) > 0 {
+		m.printFailedDeletes()
+	}
+
+	if len(m.report.Renamed) > 0 {
+		fmt.Fprintln(cli.Stdout, "Renamed on the remote:")
+		for _, r := range m.report.Renamed {
+			if r.From == r.To {
+				fmt.Fprintf(cli.Stdout, "\t%s: %s -> %s\n", r.From, r.FromURL, r.ToURL)
+				continue
+			}
+			fmt.Fprintf(cli.Stdout, "\t%s -> %s\n", r.From, r.To)
+		}
+	}
+
 	fmt.Fprintln(cli.Stdout, "Push complete.")
 	return nil
 }
+
+// defaultMessageHeader is the request header used to send push messages if
+// the checkout doesn't configure one.
+const defaultMessageHeader = "X-Change-Reason"
+
+// messageEnv is the environment variable used for the push message if none is
+// passed via `--message`, e.g. to set it from CI.
+const messageEnv = "RSH_BULK_MESSAGE"
+
+// messageHeader returns the request header used to send the push message.
+func (m *Meta) messageHeader() string {
+	if m.MessageHeader != "" {
+		return m.MessageHeader
+	}
+	return defaultMessageHeader
+}
+
+// noteDeprecations warns about tracked resources which were marked as
+// deprecated when last fetched, grouped with those seen during this run.
+func (m *Meta) noteDeprecations() {
+	for _, f := range m.Files {
+		cli.NoteDeprecation(f.URL, f.Deprecation)
+	}
+}
+
+// failDelete records a failed remote deletion of a file.
+func (m *Meta) failDelete(path string, status int) {
+	if m.FailedDeletes == nil {
+		m.FailedDeletes = map[string]int{}
+	}
+	m.FailedDeletes[path] = status
+	m.Save()
+}
+
+// printFailedDeletes shows the files whose remote deletion failed along with
+// the status code of the failure.
+func (m *Meta) printFailedDeletes() {
+	paths := make([]string, 0, len(m.FailedDeletes))
+	for path := range m.FailedDeletes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fmt.Fprintf(cli.Stdout, "Failed deletes:\n  (use \"%s bulk push\" to retry)\n  (use \"%s bulk reset [file]...\" to restore)\n", os.Args[0], os.Args[0])
+	for _, path := range paths {
+		fmt.Fprintln(cli.Stdout, failedDelete(path, m.FailedDeletes[path]))
+	}
+}
@@ -0,0 +1,260 @@
+package bulk
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// requestMetrics aggregates the requests made for a single file, or for the
+// index when no file is being pulled or pushed.
+type requestMetrics struct {
+	Path       string  `json:"path,omitempty"`
+	Status     int     `json:"status,omitempty"`
+	Requests   int     `json:"requests"`
+	Retries    int     `json:"retries"`
+	Bytes      int64   `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// metricsAggregate summarizes the metrics of all files.
+type metricsAggregate struct {
+	Files      int                `json:"files"`
+	Requests   int                `json:"requests"`
+	Retries    int                `json:"retries"`
+	Bytes      int64              `json:"bytes"`
+	DurationMS map[string]float64 `json:"duration_ms"`
+}
+
+// metricsDocument is written via `--metrics-file`.
+type metricsDocument struct {
+	Command    string            `json:"command"`
+	URL        string            `json:"url"`
+	Started    string            `json:"started"`
+	DurationMS float64           `json:"duration_ms"`
+	Succeeded  int               `json:"succeeded"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	Error      string            `json:"error,omitempty"`
+	Index      *requestMetrics   `json:"index"`
+	Files      []*requestMetrics `json:"files"`
+	Aggregate  metricsAggregate  `json:"aggregate"`
+	RateLimit  *rateLimit        `json:"rate_limit,omitempty"`
+
+	started time.Time
+}
+
+// record adds a completed request attempt to the metrics of the current
+// file. The status is that of the last attempt of the file's first request,
+// e.g. the upload rather than the fetch of the updated file after a push.
+func (r *report) record(metric cli.RequestMetric) {
+	m := &r.index
+	if r.current != "" {
+		if r.files[r.current] == nil {
+			r.files[r.current] = &requestMetrics{Path: r.current}
+		}
+		m = r.files[r.current]
+	}
+
+	if metric.Retry == 0 {
+		m.Requests++
+	} else {
+		m.Retries++
+	}
+	if m.Requests == 1 {
+		m.Status = metric.Status
+	}
+	m.Bytes += metric.Bytes
+	m.DurationMS += float64(metric.Duration.Microseconds()) / 1000
+
+	if metric.Header != nil {
+		r.pacer.observe(metric.Header)
+	}
+}
+
+// observe starts recording request metrics, returning a function to stop.
+func (r *report) observe() func() {
+	previous := cli.OnRequestDone
+	cli.OnRequestDone = r.record
+	return func() {
+		cli.OnRequestDone = previous
+		r.current = ""
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// metrics builds the metrics document for the report.
+func (r *report) metrics() *metricsDocument {
+	doc := &metricsDocument{
+		Command:    r.Command,
+		URL:        r.URL,
+		Started:    r.start.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		DurationMS: r.Duration * 1000,
+		Succeeded:  r.Succeeded,
+		Skipped:    r.Skipped,
+		Failed:     r.Failed,
+		Error:      r.Error,
+		Index:      &r.index,
+		Files:      []*requestMetrics{},
+		RateLimit:  r.pacer.observed,
+		started:    r.start,
+	}
+
+	for _, failure := range r.Failures {
+		if r.files[failure.Path] == nil {
+			r.files[failure.Path] = &requestMetrics{Path: failure.Path}
+		}
+		r.files[failure.Path].Error = failure.Error
+	}
+
+	durations := []float64{}
+	for _, m := range r.files {
+		doc.Files = append(doc.Files, m)
+		durations = append(durations, m.DurationMS)
+		doc.Aggregate.Requests += m.Requests
+		doc.Aggregate.Retries += m.Retries
+		doc.Aggregate.Bytes += m.Bytes
+	}
+	sort.Slice(doc.Files, func(i, j int) bool {
+		return doc.Files[i].Path < doc.Files[j].Path
+	})
+	sort.Float64s(durations)
+
+	doc.Aggregate.Files = len(doc.Files)
+	doc.Aggregate.DurationMS = map[string]float64{
+		"p50": percentile(durations, 50),
+		"p90": percentile(durations, 90),
+		"p95": percentile(durations, 95),
+		"p99": percentile(durations, 99),
+		"max": percentile(durations, 100),
+	}
+
+	return doc
+}
+
+// promLabel escapes a Prometheus label value.
+func promLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// prometheus renders the metrics in the Prometheus text format, suitable for
+// the node exporter's textfile collector.
+func (doc *metricsDocument) prometheus() string {
+	sb := &strings.Builder{}
+	command := promLabel(doc.Command)
+
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(sb, "# HELP restish_bulk_%s %s\n# TYPE restish_bulk_%s %s\n", name, help, name, kind)
+	}
+	value := func(name, labels string, v any) {
+		fmt.Fprintf(sb, "restish_bulk_%s{command=\"%s\"%s} %v\n", name, command, labels, v)
+	}
+	perFile := func(name string, v func(m *requestMetrics) any) {
+		for _, m := range doc.Files {
+			value(name, fmt.Sprintf(",path=\"%s\"", promLabel(m.Path)), v(m))
+		}
+	}
+
+	metric("file_duration_seconds", "gauge", "Total time spent on requests for a file.")
+	perFile("file_duration_seconds", func(m *requestMetrics) any { return m.DurationMS / 1000 })
+	metric("file_bytes", "gauge", "Response bytes transferred for a file.")
+	perFile("file_bytes", func(m *requestMetrics) any { return m.Bytes })
+	metric("file_status", "gauge", "HTTP status code of the main request for a file.")
+	perFile("file_status", func(m *requestMetrics) any { return m.Status })
+	metric("file_retries", "gauge", "Number of retried requests for a file.")
+	perFile("file_retries", func(m *requestMetrics) any { return m.Retries })
+
+	metric("files", "gauge", "Number of files by result.")
+	value("files", `,result="succeeded"`, doc.Succeeded)
+	value("files", `,result="skipped"`, doc.Skipped)
+	value("files", `,result="failed"`, doc.Failed)
+
+	metric("requests", "gauge", "Number of requests made, excluding retries.")
+	value("requests", "", doc.Aggregate.Requests+doc.Index.Requests)
+	metric("retries", "gauge", "Number of retried requests.")
+	value("retries", "", doc.Aggregate.Retries+doc.Index.Retries)
+	metric("bytes", "gauge", "Response bytes transferred.")
+	value("bytes", "", doc.Aggregate.Bytes+doc.Index.Bytes)
+
+	metric("file_duration_quantile_seconds", "gauge", "Percentiles of the time spent on requests per file.")
+	for _, q := range []string{"p50", "p90", "p95", "p99", "max"} {
+		quantile := "1"
+		if q != "max" {
+			quantile = "0." + q[1:]
+		}
+		value("file_duration_quantile_seconds", fmt.Sprintf(",quantile=\"%s\"", quantile), doc.Aggregate.DurationMS[q]/1000)
+	}
+
+	if rl := doc.RateLimit; rl != nil {
+		metric("rate_limit", "gauge", "Last observed RateLimit-Limit of the server.")
+		value("rate_limit", "", rl.Limit)
+		metric("rate_limit_remaining", "gauge", "Last observed RateLimit-Remaining of the server.")
+		value("rate_limit_remaining", "", rl.Remaining)
+		metric("rate_limit_min_remaining", "gauge", "Lowest observed RateLimit-Remaining of the server.")
+		value("rate_limit_min_remaining", "", rl.MinRemaining)
+		metric("rate_limit_wait_seconds", "gauge", "Time spent slowing down requests to stay within the rate limit.")
+		value("rate_limit_wait_seconds", "", rl.WaitMS/1000)
+	}
+
+	metric("duration_seconds", "gauge", "Total duration of the command.")
+	value("duration_seconds", "", doc.DurationMS/1000)
+	metric("last_run_timestamp_seconds", "gauge", "When the command was started.")
+	value("last_run_timestamp_seconds", "", doc.started.Unix())
+
+	return sb.String()
+}
+
+// writeMetrics writes the metrics of a finished pull or push, if requested.
+// It runs even if the command failed part way, and failing to write the
+// metrics only logs a warning.
+func writeMetrics(cmd *cobra.Command, r *report, err error) {
+	path, _ := cmd.Flags().GetString("metrics-file")
+	if path == "" {
+		return
+	}
+	r.finish(err)
+
+	doc := r.metrics()
+
+	var b []byte
+	switch format, _ := cmd.Flags().GetString("metrics-format"); format {
+	case "prom", "prometheus":
+		b = []byte(doc.prometheus())
+	case "json":
+		b, _ = cli.MarshalShort("json", true, doc)
+	default:
+		cli.LogWarning("Unknown metrics format %s, expected json or prom", format)
+		return
+	}
+
+	if err := afero.WriteFile(afs, path, b, 0644); err != nil {
+		cli.LogWarning("Unable to write metrics to %s: %v", path, err)
+	}
+}
+
+// addMetricsFlags adds the metrics output flags to a command.
+func addMetricsFlags(cmd *cobra.Command) {
+	cmd.Flags().String("metrics-file", "", "Write request metrics to this file when finished")
+	cmd.Flags().String("metrics-format", "json", "Metrics file format, either json or prom")
+	cmd.RegisterFlagCompletionFunc("metrics-format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"json", "prom"}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
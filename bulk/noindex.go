@@ -0,0 +1,219 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// versionPending is the remote version of a resource tracked by URL which
+// hasn't been fetched yet. Checkouts without an index have no other source of
+// remote versions until the first fetch.
+const versionPending = "pending"
+
+// urlEntry is a resource to track in a checkout without an index.
+type urlEntry struct {
+	URL  string `json:"url"`
+	Path string `json:"path,omitempty"`
+}
+
+// readURLList reads the resources to track from a file, which is either a
+// JSON array of `{url, path}` objects or plain text with one URL per line.
+// Blank lines and lines starting with `#` are ignored.
+func readURLList(filename string) ([]urlEntry, error) {
+	b, err := afero.ReadFile(afs, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []urlEntry{}
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", filename, err)
+		}
+		return entries, nil
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, urlEntry{URL: line})
+	}
+	return entries, nil
+}
+
+// urlPath returns the checkout path for a resource URL, which is the URL path
+// with a `.json` extension, e.g. `api.example.com/books/1` becomes
+// `books/1.json`.
+func urlPath(u *url.URL) string {
+	return strings.Trim(u.Path, "/") + ".json"
+}
+
+// origin returns the scheme and host of a URL with a trailing slash.
+func origin(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + "/"
+}
+
+// newURLFile creates the metadata for a resource tracked by URL, validating
+// its path and making sure it isn't already tracked.
+func (m *Meta) newURLFile(entry urlEntry) (*File, error) {
+	if entry.URL == "" {
+		return nil, fmt.Errorf("a URL is required for each resource")
+	}
+	u, err := url.Parse(cli.FixAddress(entry.URL))
+	if err != nil {
+		return nil, err
+	}
+
+	p := entry.Path
+	if p == "" {
+		p = urlPath(u)
+	}
+	p = path.Clean(strings.ReplaceAll(p, "\\", "/"))
+	if !strings.HasSuffix(p, ".json") {
+		p += ".json"
+	}
+	if p == ".json" || path.IsAbs(p) || strings.HasPrefix(p, ".") {
+		return nil, fmt.Errorf("invalid path %s for %s", p, u)
+	}
+
+	if existing := m.Files[p]; existing != nil {
+		return nil, fmt.Errorf("%s is already tracked for %s", p, existing.URL)
+	}
+
+	return &File{Path: p, URL: u.String(), VersionRemote: versionPending}, nil
+}
+
+// InitURLs initializes a checkout which tracks an explicit list of resources
+// rather than those listed by an index, saves it to disk, and then performs
+// the initial pull to fetch each file.
+func (m *Meta) InitURLs(ctx context.Context, entries []urlEntry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("no URLs to track")
+	}
+
+	m.NoIndex = true
+	m.Initializing = true
+	m.Accept = viper.GetString("rsh-accept")
+	m.ContentType = viper.GetString("rsh-content-type")
+	m.Files = map[string]*File{}
+
+	origins := map[string]bool{}
+	for _, entry := range entries {
+		f, err := m.newURLFile(entry)
+		if err != nil {
+			return err
+		}
+		m.Files[f.Path] = f
+
+		u, _ := url.Parse(f.URL)
+		origins[origin(u)] = true
+	}
+
+	if len(origins) == 1 {
+		// New local files can be pushed relative to the shared origin.
+		for o := range origins {
+			m.Base = o
+			m.URL = o
+		}
+	}
+
+	if err := m.Save(); err != nil {
+		return err
+	}
+
+	return m.initPull(ctx)
+}
+
+// TrackURL adds a single resource by URL to a checkout without an index and
+// pulls it. If no path is given, one is created from the URL.
+func (m *Meta) TrackURL(ctx context.Context, resource, p string) error {
+	if !m.NoIndex {
+		return fmt.Errorf("resources can only be tracked by URL in checkouts created with `bulk init --from-file`")
+	}
+
+	f, err := m.newURLFile(urlEntry{URL: resource, Path: p})
+	if err != nil {
+		return err
+	}
+	m.Files[f.Path] = f
+
+	cli.LogInfo("Tracking %s", f.Path)
+	if err := m.Save(); err != nil {
+		return err
+	}
+
+	return m.Pull(ctx)
+}
+
+// fetchedVersion returns the version of a file in a checkout without an index
+// based on its last fetch, preferring the ETag, then the Last-Modified date,
+// and finally a hash of the contents.
+func (f *File) fetchedVersion(b []byte) string {
+	if f.ETag != "" {
+		return f.ETag
+	}
+	if f.LastModified != "" {
+		return f.LastModified
+	}
+	return hex.EncodeToString(hash(b))
+}
+
+// fetch pulls a file, using the response as its remote version in checkouts
+// without an index. When `conditional` is set the file is only fetched if it
+// has changed, see `File.FetchIfChanged`. A `404 Not Found` is treated as a
+// remote deletion if requested via `--treat-404-as-gone`.
+func (m *Meta) fetch(ctx context.Context, f *File, conditional bool) ([]byte, error) {
+	b, err := f.fetch(ctx, m, conditional)
+	if errors.Is(err, errNotFound) && m.treat404AsGone {
+		err = errRemoved
+	}
+	if err == nil {
+		m.fetched(f, b)
+	}
+	return b, err
+}
+
+// update sets a file from a response containing its remote contents, like
+// `fetch` but without making a request.
+func (m *Meta) update(f *File, resp cli.Response) ([]byte, error) {
+	b, err := f.update(resp)
+	if err == nil {
+		m.fetched(f, b)
+	}
+	return b, err
+}
+
+// fetched uses the remote contents of a file as its version in checkouts
+// without an index.
+func (m *Meta) fetched(f *File, b []byte) {
+	if m.NoIndex {
+		f.VersionRemote = f.fetchedVersion(b)
+		f.VersionLocal = f.VersionRemote
+	}
+}
+
+// sortedFiles returns all tracked files sorted by path.
+func (m *Meta) sortedFiles() []*File {
+	files := make([]*File, 0, len(m.Files))
+	for _, f := range m.Files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
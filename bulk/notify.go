@@ -0,0 +1,193 @@
+package bulk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// Kinds of file failures, which tell whether running the command again may
+// work without any changes.
+const (
+	// failureRetry is temporary, e.g. a network error, rate limit, or server
+	// error.
+	failureRetry = "retry"
+
+	// failureConflict means the resource was changed or locked by someone else
+	// on the remote.
+	failureConflict = "conflict"
+
+	// failurePermanent needs a fix, e.g. to the request or the checkout.
+	failurePermanent = "permanent"
+)
+
+// FileFailure describes a file which could not be pulled or pushed.
+type FileFailure struct {
+	Path   string `json:"path"`
+	Status int    `json:"status,omitempty"`
+	Kind   string `json:"kind"`
+	Error  string `json:"error"`
+}
+
+// failureKind classifies the cause of a file failure.
+func failureKind(err error) string {
+	var httpErr *cli.ErrHTTP
+	switch {
+	case errors.As(err, &httpErr) && httpErr.Temporary():
+		return failureRetry
+	case errors.As(err, &httpErr) && httpErr.Conflict():
+		return failureConflict
+	case errors.Is(err, &cli.ErrNetwork{}), errors.Is(err, errDigestMismatch), errors.Is(err, errNotFound):
+		// Fetching again may work, and a missing resource may be transient.
+		return failureRetry
+	}
+	return failurePermanent
+}
+
+// report is a summary of a pull or push which is sent via `--notify-url` or
+// `--notify-command` once the command finishes.
+type report struct {
+	Command   string        `json:"command"`
+	URL       string        `json:"url"`
+	Succeeded int           `json:"succeeded"`
+	Skipped   int           `json:"skipped"`
+	Failed    int           `json:"failed"`
+	Duration  float64       `json:"duration"`
+	Failures  []FileFailure `json:"failures,omitempty"`
+	Renamed   []Rename      `json:"renamed,omitempty"`
+	Error     string        `json:"error,omitempty"`
+
+	start time.Time
+
+	// current is the file being pulled or pushed which request metrics are
+	// attributed to. Requests made without a current file are for the index.
+	current string
+	files   map[string]*requestMetrics
+	index   requestMetrics
+
+	// pacer spaces out requests based on the server's rate limit.
+	pacer pacer
+}
+
+// newReport starts a report for a command.
+func newReport(command, url string) *report {
+	return &report{Command: command, URL: url, Failures: []FileFailure{}, start: time.Now(), files: map[string]*requestMetrics{}}
+}
+
+// finish records the duration and any error of the finished command.
+func (r *report) finish(err error) {
+	r.Duration = time.Since(r.start).Seconds()
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// fail prints an error message for a file like `fileMsg` and records it as
+// a failure in the report. The failure is classified by the status of the
+// response if it is an error, otherwise by the last error in the args.
+func (r *report) fail(bar *progressbar.ProgressBar, resp *cli.Response, path string, format string, args ...any) {
+	fileMsg(bar, resp, format, args...)
+
+	var cause error
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			cause = err
+		}
+	}
+
+	failure := FileFailure{Path: path, Error: strings.TrimSpace(fmt.Sprintf(format, args...))}
+	if resp != nil {
+		failure.Status = resp.Status
+		if resp.Status >= http.StatusBadRequest {
+			cause = cli.StatusError(*resp, "")
+		}
+	}
+	failure.Kind = failureKind(cause)
+	r.Failures = append(r.Failures, failure)
+	r.Failed++
+}
+
+// notify sends the report of a finished pull or push, if requested. The
+// report is POSTed as JSON to the notify URL using the normal request
+// pipeline so that any auth for that host applies, and is passed on stdin to
+// the notify command. Notification failures are only logged as warnings
+// since they must never fail the command itself.
+func notify(cmd *cobra.Command, r *report, err error) {
+	url, _ := cmd.Flags().GetString("notify-url")
+	command, _ := cmd.Flags().GetString("notify-command")
+	if url == "" && command == "" {
+		return
+	}
+
+	r.finish(err)
+
+	b, _ := json.Marshal(r)
+
+	if url != "" {
+		if err := notifyURL(url, b); err != nil {
+			cli.LogWarning("Unable to notify %s: %v", url, err)
+		}
+	}
+
+	if command != "" {
+		if err := notifyCommand(command, b); err != nil {
+			cli.LogWarning("Unable to run notify command: %v", err)
+		}
+	}
+}
+
+// notifyURL POSTs the JSON report to a URL.
+func notifyURL(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, cli.FixAddress(url), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := cli.MakeRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("server responded with %s", resp.Status)
+	}
+	cli.BulkLog.Debug("Notified %s", url)
+	return nil
+}
+
+// notifyCommand runs a shell command with the JSON report on stdin.
+func notifyCommand(command string, body []byte) error {
+	shell, shellPresent := os.LookupEnv("SHELL")
+	if !shellPresent {
+		shell = "/bin/sh"
+	}
+
+	c := exec.Command(shell, "-c", command)
+	c.Stdin = bytes.NewReader(body)
+	c.Stdout = cli.Stdout
+	c.Stderr = cli.Stderr
+
+	cli.BulkLog.Debug("Running notify command %s", command)
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("`%s` failed: %w", command, err)
+	}
+	return nil
+}
+
+// addNotifyFlags adds the completion notification flags to a command.
+func addNotifyFlags(cmd *cobra.Command) {
+	cmd.Flags().String("notify-url", "", "POST a JSON summary to this URL when finished")
+	cmd.Flags().String("notify-command", "", "Run this shell command with a JSON summary on stdin when finished")
+}
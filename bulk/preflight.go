@@ -0,0 +1,123 @@
+package bulk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// preflightSample is the number of index entries whose templated URLs are
+// checked before an init fetches every file.
+const preflightSample = 5
+
+// itemFields returns the sorted field names of a list response item.
+func itemFields(item any) []string {
+	fields := []string{}
+	switch m := item.(type) {
+	case map[string]any:
+		for k := range m {
+			fields = append(fields, k)
+		}
+	case map[any]any:
+		for k := range m {
+			fields = append(fields, fmt.Sprintf("%v", k))
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// checkURL requests a resource to see whether it exists, using a `HEAD`
+// request or a single byte `GET` for servers which don't support `HEAD`. It
+// returns a description of the problem if the resource can't be fetched.
+func (m *Meta) checkURL(ctx context.Context, u string) string {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	resp, err := cli.MakeRequest(req, cli.WithMediaTypes(m.Accept, m.ContentType), cli.IgnoreStatus())
+	if err != nil {
+		return err.Error()
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		req, _ = http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		req.Header.Set("Range", "bytes=0-0")
+		resp, err = cli.MakeRequest(req, cli.WithMediaTypes(m.Accept, m.ContentType), cli.IgnoreStatus())
+		if err != nil {
+			return err.Error()
+		}
+		resp.Body.Close()
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "responded with " + resp.Status
+	}
+	return ""
+}
+
+// preflight checks the URL template against the first few index entries
+// before an init fetches every file, so that a typo fails fast with a clear
+// message rather than as one error per file. Every template variable must be
+// set by the sampled entries, and most of the resulting URLs must exist.
+func (m *Meta) preflight(ctx context.Context, entries []listEntry) error {
+	sample := []listEntry{}
+	for _, entry := range entries {
+		if getFirstKey(entry.Item, "url", "uri", "self", "link") != "" {
+			// The entry has its own URL, so the template isn't used.
+			continue
+		}
+		sample = append(sample, entry)
+		if len(sample) == preflightSample {
+			break
+		}
+	}
+	if len(sample) == 0 {
+		return nil
+	}
+
+	for _, entry := range sample {
+		if _, missing := m.templateURL(entry.Item); len(missing) > 0 {
+			item, _ := json.Marshal(entry.Item)
+			return fmt.Errorf("URL template variable {%s} is not set by index entries like %s, available fields are %s (use --skip-preflight to skip this check)", missing[0], item, strings.Join(itemFields(entry.Item), ", "))
+		}
+	}
+
+	base, _ := url.Parse(m.URL)
+	urls := make([]string, len(sample))
+	problems := make([]string, len(sample))
+	for i, entry := range sample {
+		u, _ := url.Parse(entry.URL)
+		urls[i] = base.ResolveReference(u).String()
+	}
+
+	cli.RunParallel(ctx, len(sample), defaultParallel, func(ctx context.Context, i int) error {
+		problems[i] = m.checkURL(ctx, urls[i])
+		return nil
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	failed := 0
+	example := -1
+	for i, problem := range problems {
+		if problem != "" {
+			failed++
+			if example == -1 {
+				example = i
+			}
+		}
+	}
+
+	if failed*2 > len(sample) {
+		return fmt.Errorf("URL template check failed for %d of %d sampled resources, e.g. %s %s; check --url-template or use --skip-preflight to skip this check", failed, len(sample), urls[example], problems[example])
+	}
+
+	cli.BulkLog.Debug("URL template check passed for %d of %d sampled resources", len(sample)-failed, len(sample))
+	return nil
+}
@@ -0,0 +1,313 @@
+package bulk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/tarunKoyalwar/restish/cli"
+	"golang.org/x/crypto/ssh"
+)
+
+// pushesDir holds a provenance record for each push.
+const pushesDir = metaDir + "/pushes"
+
+// pushNamespace is prepended to signed push records so that the signatures
+// can't be reused for anything else.
+const pushNamespace = "restish-bulk-push\n"
+
+// pushedFile records the push of a single file.
+type pushedFile struct {
+	Path   string `json:"path"`
+	URL    string `json:"url"`
+	Method string `json:"method"`
+	Status int    `json:"status,omitempty"`
+
+	// SHA256 is the hash of the cached remote contents after the push.
+	SHA256 string `json:"sha256,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// pushSignature is an SSH signature of a push record.
+type pushSignature struct {
+	Format      string `json:"format"`
+	PublicKey   string `json:"public_key"`
+	Fingerprint string `json:"fingerprint"`
+	Signature   string `json:"signature"`
+}
+
+// pushRecord describes who pushed what for auditing, see `bulk verify-push`.
+type pushRecord struct {
+	Time      string         `json:"time"`
+	URL       string         `json:"url"`
+	Principal *cli.Principal `json:"principal,omitempty"`
+	Message   string         `json:"message,omitempty"`
+	Files     []pushedFile   `json:"files"`
+	Signature *pushSignature `json:"signature,omitempty"`
+}
+
+// signedBytes returns the data covered by the record's signature, which is
+// the record without its signature.
+func (r pushRecord) signedBytes() []byte {
+	r.Signature = nil
+	b, _ := json.Marshal(r)
+	return append([]byte(pushNamespace), b...)
+}
+
+// cacheHash returns the hex SHA-256 of the cached remote copy of a file.
+func cacheHash(p string) (string, error) {
+	b, err := afero.ReadFile(afs, path.Join(metaDir, p))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// expandHome replaces a leading `~` in a path with the user's home directory.
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
+
+// loadSigner loads an SSH private key to sign push records with.
+func loadSigner(keyPath string) (ssh.Signer, error) {
+	b, err := os.ReadFile(expandHome(keyPath))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("AGE-SECRET-KEY-")) {
+		return nil, fmt.Errorf("age keys can only encrypt, use an SSH key to sign")
+	}
+
+	signer, err := ssh.ParsePrivateKey(b)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("passphrase protected keys are not supported")
+	}
+	return signer, err
+}
+
+// sign adds an SSH signature to the record.
+func (r *pushRecord) sign(signer ssh.Signer) error {
+	sig, err := signer.Sign(rand.Reader, r.signedBytes())
+	if err != nil {
+		return err
+	}
+
+	r.Signature = &pushSignature{
+		Format:      "ssh",
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))),
+		Fingerprint: ssh.FingerprintSHA256(signer.PublicKey()),
+		Signature:   base64.StdEncoding.EncodeToString(ssh.Marshal(sig)),
+	}
+	return nil
+}
+
+// verify checks the record's signature, returning its public key.
+func (r *pushRecord) verify() (ssh.PublicKey, error) {
+	if r.Signature == nil {
+		return nil, fmt.Errorf("not signed")
+	}
+	if r.Signature.Format != "ssh" {
+		return nil, fmt.Errorf("unknown signature format %s", r.Signature.Format)
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(r.Signature.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := base64.StdEncoding.DecodeString(r.Signature.Signature)
+	if err != nil {
+		return nil, err
+	}
+	var sig ssh.Signature
+	if err := ssh.Unmarshal(b, &sig); err != nil {
+		return nil, err
+	}
+
+	return key, key.Verify(r.signedBytes(), &sig)
+}
+
+// writePushRecord saves a provenance record for pushed files, signing it if a
+// key is configured. The `statuses` hold the response status of each pushed
+// file by path. Problems are only logged since they must never block a push.
+func (m *Meta) writePushRecord(pushed []changedFile, statuses map[string]int) {
+	if len(pushed) == 0 {
+		return
+	}
+
+	failures := map[string]string{}
+	for _, failure := range m.report.Failures {
+		failures[failure.Path] = failure.Error
+	}
+
+	target := m.URL
+	if target == "" {
+		target = pushed[0].File.URL
+	}
+
+	record := pushRecord{
+		Time:      time.Now().UTC().Format(time.RFC3339),
+		URL:       m.URL,
+		Principal: cli.GetPrincipal(target),
+		Message:   m.message,
+		Files:     []pushedFile{},
+	}
+
+	for _, changed := range pushed {
+		f := changed.File
+		method, _ := m.pushMethod(changed.Status)
+		entry := pushedFile{
+			Path:   f.Path,
+			URL:    f.URL,
+			Method: method,
+			Status: statuses[f.Path],
+			Error:  failures[f.Path],
+		}
+		if changed.Status != statusRemoved && entry.Error == "" {
+			entry.SHA256, _ = cacheHash(f.Path)
+		}
+		record.Files = append(record.Files, entry)
+	}
+
+	if m.SignKey != "" {
+		if signer, err := loadSigner(m.SignKey); err != nil {
+			cli.LogWarning("Not signing push record with %s: %v", m.SignKey, err)
+		} else if err := record.sign(signer); err != nil {
+			cli.LogWarning("Unable to sign push record: %v", err)
+		}
+	}
+
+	b, _ := cli.MarshalShort("json", true, record)
+	filename := path.Join(pushesDir, time.Now().UTC().Format("20060102T150405.000Z")+".json")
+	afs.MkdirAll(pushesDir, 0700)
+	if err := afero.WriteFile(afs, filename, b, 0600); err != nil {
+		cli.LogWarning("Unable to write push record: %v", err)
+		return
+	}
+
+	if record.Signature != nil {
+		cli.LogInfo("Saved signed push record %s", filename)
+	} else {
+		cli.LogInfo("Saved unsigned push record %s", filename)
+	}
+}
+
+// verifyPush checks the signature of a push record and that the hashes of the
+// pushed files match the cache. If `keyPath` is set then the record must be
+// signed by that public key.
+func verifyPush(filename, keyPath string) error {
+	b, err := afero.ReadFile(afs, filename)
+	if err != nil {
+		return err
+	}
+
+	var record pushRecord
+	if err := json.Unmarshal(b, &record); err != nil {
+		return fmt.Errorf("unable to parse %s: %w", filename, err)
+	}
+
+	failed := false
+
+	key, err := record.verify()
+	switch {
+	case record.Signature == nil && keyPath == "":
+		fmt.Fprintln(cli.Stdout, "Signature: none")
+	case err != nil:
+		fmt.Fprintf(cli.Stdout, "Signature: invalid (%v)\n", err)
+		failed = true
+	default:
+		fmt.Fprintf(cli.Stdout, "Signature: valid %s %s\n", key.Type(), ssh.FingerprintSHA256(key))
+		if keyPath != "" {
+			kb, err := os.ReadFile(expandHome(keyPath))
+			if err != nil {
+				return err
+			}
+			expected, _, _, _, err := ssh.ParseAuthorizedKey(kb)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(expected.Marshal(), key.Marshal()) {
+				fmt.Fprintf(cli.Stdout, "Signature: signed by %s, expected %s\n", ssh.FingerprintSHA256(key), ssh.FingerprintSHA256(expected))
+				failed = true
+			}
+		}
+	}
+
+	if record.Principal != nil {
+		fmt.Fprintf(cli.Stdout, "Principal: %s/%s\n", record.Principal.API, record.Principal.Profile)
+	}
+	fmt.Fprintf(cli.Stdout, "Pushed at %s to %s\n", record.Time, record.URL)
+	if record.Message != "" {
+		fmt.Fprintf(cli.Stdout, "Message: %s\n", record.Message)
+	}
+
+	for _, f := range record.Files {
+		if f.SHA256 == "" {
+			fmt.Fprintf(cli.Stdout, "\t%s %s  %s\n", f.Method, pushStatusLabel(f), f.Path)
+			continue
+		}
+		actual, err := cacheHash(f.Path)
+		switch {
+		case err != nil:
+			fmt.Fprintf(cli.Stdout, "\t%s %s  %s: missing from cache\n", f.Method, pushStatusLabel(f), f.Path)
+			failed = true
+		case actual != f.SHA256:
+			fmt.Fprintf(cli.Stdout, "\t%s %s  %s: hash mismatch\n", f.Method, pushStatusLabel(f), f.Path)
+			failed = true
+		default:
+			fmt.Fprintf(cli.Stdout, "\t%s %s  %s: hash ok\n", f.Method, pushStatusLabel(f), f.Path)
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("verification of %s failed", filename)
+	}
+	return nil
+}
+
+// pushStatusLabel returns the response status of a pushed file for display.
+func pushStatusLabel(f pushedFile) string {
+	if f.Status == 0 {
+		return "error"
+	}
+	return fmt.Sprintf("%d", f.Status)
+}
+
+// verifyPushCommand returns the `bulk verify-push` command.
+func verifyPushCommand() *cobra.Command {
+	verify := &cobra.Command{
+		GroupID: "info",
+		Use:     "verify-push file [--key pubkey]",
+		Short:   "Verify a push provenance record",
+		Long:    "Validate the signature of a push record from `" + pushesDir + "` and check that the hashes of the pushed files still match the cached remote copies. Use `--key` to require a signature from a specific SSH public key.",
+		Example: "  " + os.Args[0] + " bulk verify-push " + pushesDir + "/20240501T120000.000Z.json --key ~/.ssh/id_ed25519.pub",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			key, _ := cmd.Flags().GetString("key")
+			panicOnErr(verifyPush(args[0], key))
+		},
+	}
+	verify.Flags().String("key", "", "SSH public key the record must be signed with")
+
+	return verify
+}
@@ -0,0 +1,121 @@
+package bulk
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// rateLimitThreshold is the fraction of the server's rate limit which may be
+// left before requests are slowed down to spread the remaining ones over the
+// rest of the window.
+const rateLimitThreshold = 0.1
+
+// rateLimit summarizes the `RateLimit-*` response headers observed during a
+// pull or push, along with how long requests were held back because of them.
+type rateLimit struct {
+	Limit int `json:"limit"`
+	// Remaining is the last observed number of requests left in the window.
+	Remaining    int     `json:"remaining"`
+	MinRemaining int     `json:"min_remaining"`
+	ResetSeconds float64 `json:"reset_seconds"`
+	Waits        int     `json:"waits"`
+	WaitMS       float64 `json:"wait_ms"`
+}
+
+// pacer spaces out the requests of a pull or push, either at a fixed rate
+// given via `--rate` or adaptively based on the server's rate limit headers,
+// so that the limit isn't hit and requests don't fail with `429 Too Many
+// Requests`.
+type pacer struct {
+	// delay is the adaptive time between requests, zero for full speed.
+	delay time.Duration
+	last  time.Time
+
+	observed *rateLimit
+}
+
+// headerInt parses the leading integer of a rate limit header, ignoring any
+// quota policy after it like `100, 100;w=60`.
+func headerInt(header http.Header, name string) (int, bool) {
+	v := header.Get(name)
+	if i := strings.IndexAny(v, ",;"); i != -1 {
+		v = v[:i]
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	return n, err == nil && n >= 0
+}
+
+// observe updates the pacing from the `RateLimit-Limit`, `RateLimit-Remaining`
+// and `RateLimit-Reset` headers of a response. Once fewer than
+// `rateLimitThreshold` of the limit remain, the remaining requests are spread
+// evenly until the window resets.
+func (p *pacer) observe(header http.Header) {
+	limit, ok := headerInt(header, "RateLimit-Limit")
+	if !ok || limit == 0 {
+		return
+	}
+	remaining, ok := headerInt(header, "RateLimit-Remaining")
+	if !ok {
+		return
+	}
+	reset, _ := headerInt(header, "RateLimit-Reset")
+
+	if p.observed == nil {
+		p.observed = &rateLimit{MinRemaining: remaining}
+	}
+	p.observed.Limit = limit
+	p.observed.Remaining = remaining
+	p.observed.ResetSeconds = float64(reset)
+	if remaining < p.observed.MinRemaining {
+		p.observed.MinRemaining = remaining
+	}
+
+	delay := time.Duration(0)
+	if float64(remaining) < float64(limit)*rateLimitThreshold {
+		delay = time.Duration(reset) * time.Second / time.Duration(remaining+1)
+	}
+
+	if delay != p.delay {
+		if delay > 0 {
+			cli.BulkLog.Debug("Rate limit has %d of %d requests left, resetting in %ds, slowing down to one request every %s", remaining, limit, reset, delay.Round(time.Millisecond))
+		} else {
+			cli.BulkLog.Debug("Rate limit has %d of %d requests left, resuming full speed", remaining, limit)
+		}
+	}
+	p.delay = delay
+}
+
+// wait blocks until the next request may be sent. A fixed `rate` in requests
+// per second takes precedence over the adaptive delay.
+func (p *pacer) wait(ctx context.Context, rate float64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	interval := p.delay
+	if rate > 0 {
+		interval = time.Duration(float64(time.Second) / rate)
+	}
+
+	if d := time.Until(p.last.Add(interval)); !p.last.IsZero() && d > 0 {
+		if p.observed != nil && rate <= 0 {
+			p.observed.Waits++
+			p.observed.WaitMS += float64(d.Microseconds()) / 1000
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	p.last = time.Now()
+	return nil
+}
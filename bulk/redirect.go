@@ -0,0 +1,114 @@
+package bulk
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// maxPushRedirects is how many redirects an upload follows before failing.
+const maxPushRedirects = 5
+
+// Rename is a resource whose canonical URL changed when it was pushed, e.g.
+// after a slug rename, and the local path it was moved to.
+type Rename struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	FromURL string `json:"from_url"`
+	ToURL   string `json:"to_url"`
+}
+
+// upload sends the request to upload a file, following redirects to the new
+// URL of a renamed resource on the same host. Methods are kept for `301`,
+// `302`, `307` and `308` redirects except for `POST`, which clients change to
+// `GET` on `301` and `302`, so those fail instead. A `303 See Other` means
+// the write is done and the resource is at the new URL. The last response and
+// the URL of the resource are returned.
+func (m *Meta) upload(req *http.Request, body []byte) (cli.Response, string, error) {
+	header := req.Header.Clone()
+	visited := map[string]bool{req.URL.String(): true}
+
+	for redirects := 0; ; redirects++ {
+		resp, err := cli.GetParsedResponse(req, cli.WithoutRedirects(), cli.WithMediaTypes(m.Accept, m.ContentType))
+		if err != nil {
+			return resp, "", err
+		}
+
+		switch resp.Status {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return resp, req.URL.String(), nil
+		}
+
+		location, err := req.URL.Parse(resp.Headers["Location"])
+		if err != nil || resp.Headers["Location"] == "" {
+			return resp, "", fmt.Errorf("redirect from %s without a valid Location", req.URL)
+		}
+		if location.Scheme != req.URL.Scheme || location.Host != req.URL.Host {
+			return resp, "", fmt.Errorf("refusing to follow redirect from %s to another host %s", req.URL, location)
+		}
+		if resp.Status == http.StatusSeeOther {
+			return resp, location.String(), nil
+		}
+		if req.Method == http.MethodPost && (resp.Status == http.StatusMovedPermanently || resp.Status == http.StatusFound) {
+			return resp, "", fmt.Errorf("refusing to follow redirect from %s to %s which would change POST to GET", req.URL, location)
+		}
+		if visited[location.String()] {
+			return resp, "", fmt.Errorf("redirect loop from %s to %s", req.URL, location)
+		}
+		if redirects == maxPushRedirects {
+			return resp, "", fmt.Errorf("stopped after %d redirects at %s", maxPushRedirects, req.URL)
+		}
+		visited[location.String()] = true
+
+		cli.LogInfo("Following %d redirect from %s to %s", resp.Status, req.URL, location)
+		next, _ := http.NewRequestWithContext(req.Context(), req.Method, location.String(), bytes.NewReader(body))
+		next.Header = header.Clone()
+		req = next
+	}
+}
+
+// rename moves a tracked file to the local path of its new URL, along with
+// its cached remote copy. If the new URL is outside the checkout's base or
+// its path is taken, the file keeps its path and only the URL is updated.
+func (m *Meta) rename(f *File, newURL string) (Rename, error) {
+	r := Rename{From: f.Path, To: f.Path, FromURL: f.URL, ToURL: newURL}
+	f.URL = newURL
+
+	if m.Base == "" || !strings.HasPrefix(newURL, m.Base) {
+		cli.LogWarning("%s was renamed to %s outside of %s, keeping it at %s", r.FromURL, newURL, m.Base, f.Path)
+		return r, nil
+	}
+
+	newPath := newURL[len(m.Base):] + ".json"
+	if newPath == f.Path {
+		return r, nil
+	}
+	if exists, _ := afero.Exists(afs, newPath); exists || m.Files[newPath] != nil {
+		cli.LogWarning("%s was renamed to %s, but %s already exists, keeping it at %s", r.FromURL, newURL, newPath, f.Path)
+		return r, nil
+	}
+
+	for _, dir := range []string{"", metaDir} {
+		from, to := path.Join(dir, f.Path), path.Join(dir, newPath)
+		if exists, _ := afero.Exists(afs, from); !exists {
+			continue
+		}
+		afs.MkdirAll(filepath.Dir(to), 0700)
+		if err := afs.Rename(from, to); err != nil {
+			return r, err
+		}
+	}
+
+	delete(m.Files, f.Path)
+	f.Path = newPath
+	m.Files[newPath] = f
+	r.To = newPath
+	return r, m.Save()
+}
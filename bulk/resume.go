@@ -0,0 +1,57 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/afero"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// initPull performs the initial pull of a new checkout. It stays marked as
+// initializing until every file has been fetched so that an interrupted or
+// failed init can be resumed.
+func (m *Meta) initPull(ctx context.Context) error {
+	if err := m.Pull(ctx); err != nil {
+		if errors.Is(err, errInterrupted) {
+			return fmt.Errorf("%w, run `bulk init --resume` to continue", err)
+		}
+		return err
+	}
+
+	if m.report.Failed > 0 {
+		cli.LogWarning("Unable to fetch %d files, run `bulk init --resume` to retry", m.report.Failed)
+		return nil
+	}
+
+	m.Initializing = false
+	return m.Save()
+}
+
+// Resume continues an interrupted init. Files which were already fetched at
+// their current remote version are skipped while failed or missing ones are
+// fetched again.
+func (m *Meta) Resume(ctx context.Context) error {
+	if !m.Initializing {
+		return fmt.Errorf("no interrupted init to resume")
+	}
+
+	fmt.Fprintf(cli.Stdout, "Resuming init of %s\n", m.URL)
+
+	for _, f := range m.Files {
+		if f.VersionLocal == "" {
+			continue
+		}
+		if exists, _ := afero.Exists(afs, f.Path); exists && len(f.Hash) > 0 {
+			continue
+		}
+		// The init stopped after caching the file but before writing it out,
+		// so restore it from the cache or fetch it again.
+		if err := f.Reset(); err != nil {
+			f.VersionLocal = ""
+		}
+	}
+
+	return m.initPull(ctx)
+}
@@ -0,0 +1,178 @@
+package bulk
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// statsLargest is the number of largest files listed by `bulk stats`.
+const statsLargest = 10
+
+// fileSize is the size of a single checked out file.
+type fileSize struct {
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+// dirStats summarizes the checked out files in a directory.
+type dirStats struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// checkoutStats describes a checkout for `bulk stats`. It is built from the
+// metadata and local files only, so remote counts are as of the last time
+// the index was refreshed.
+type checkoutStats struct {
+	URL         string      `json:"url"`
+	Files       int         `json:"files"`
+	Bytes       int64       `json:"bytes"`
+	Modified    int         `json:"modified"`
+	Added       int         `json:"added"`
+	Removed     int         `json:"removed"`
+	Untracked   int         `json:"untracked"`
+	Conflicted  int         `json:"conflicted"`
+	LastPull    string      `json:"last_pull,omitempty"`
+	LastPullAge float64     `json:"last_pull_age_seconds,omitempty"`
+	Directories []*dirStats `json:"directories"`
+	Largest     []fileSize  `json:"largest"`
+}
+
+// getStats collects the statistics of a checkout without making any
+// requests. Files which are modified locally and whose remote version changed
+// since they were fetched, e.g. after a rejected push, count as conflicted.
+func getStats(m *Meta) *checkoutStats {
+	stats := &checkoutStats{
+		URL:         m.URL,
+		Files:       len(m.Files),
+		Untracked:   len(m.Untracked),
+		Directories: []*dirStats{},
+		Largest:     []fileSize{},
+	}
+
+	if !m.LastPull.IsZero() {
+		stats.LastPull = m.LastPull.UTC().Format(time.RFC3339)
+		stats.LastPullAge = time.Since(m.LastPull).Round(time.Second).Seconds()
+	}
+
+	dirs := map[string]*dirStats{}
+	for _, f := range m.Files {
+		info, err := afs.Stat(f.Path)
+		if err != nil {
+			continue
+		}
+
+		size := info.Size()
+		stats.Bytes += size
+		stats.Largest = append(stats.Largest, fileSize{f.Path, size})
+
+		dir := path.Dir(f.Path)
+		if dirs[dir] == nil {
+			dirs[dir] = &dirStats{Path: dir}
+			stats.Directories = append(stats.Directories, dirs[dir])
+		}
+		dirs[dir].Files++
+		dirs[dir].Bytes += size
+	}
+
+	sort.Slice(stats.Directories, func(i, j int) bool {
+		return stats.Directories[i].Path < stats.Directories[j].Path
+	})
+	sort.Slice(stats.Largest, func(i, j int) bool {
+		if stats.Largest[i].Bytes != stats.Largest[j].Bytes {
+			return stats.Largest[i].Bytes > stats.Largest[j].Bytes
+		}
+		return stats.Largest[i].Path < stats.Largest[j].Path
+	})
+	if len(stats.Largest) > statsLargest {
+		stats.Largest = stats.Largest[:statsLargest]
+	}
+
+	local, remote := m.changed(collectFiles(m, []string{}, "", false, false))
+	changedRemote := map[string]bool{}
+	for _, changed := range remote {
+		changedRemote[changed.File.Path] = true
+	}
+	for _, changed := range local {
+		switch changed.Status {
+		case statusModified:
+			stats.Modified++
+			if changedRemote[changed.File.Path] {
+				stats.Conflicted++
+			}
+		case statusAdded:
+			stats.Added++
+		case statusRemoved:
+			stats.Removed++
+		}
+	}
+
+	return stats
+}
+
+// printStats writes the statistics of a checkout for humans.
+func printStats(stats *checkoutStats) {
+	fmt.Fprintf(cli.Stdout, "Checkout of %s\n", stats.URL)
+	fmt.Fprintf(cli.Stdout, "  Files:       %d tracked, %s\n", stats.Files, cli.FormatSize(int(stats.Bytes)))
+	fmt.Fprintf(cli.Stdout, "  Local:       %d modified, %d added, %d removed\n", stats.Modified, stats.Added, stats.Removed)
+	fmt.Fprintf(cli.Stdout, "  Untracked:   %d\n", stats.Untracked)
+	fmt.Fprintf(cli.Stdout, "  Conflicted:  %d\n", stats.Conflicted)
+	if stats.LastPull != "" {
+		age := time.Duration(stats.LastPullAge) * time.Second
+		fmt.Fprintf(cli.Stdout, "  Last pull:   %s (%s ago)\n", stats.LastPull, age)
+	} else {
+		fmt.Fprintln(cli.Stdout, "  Last pull:   never")
+	}
+
+	if len(stats.Directories) > 0 {
+		fmt.Fprintln(cli.Stdout, "Directories:")
+		for _, dir := range stats.Directories {
+			fmt.Fprintf(cli.Stdout, "  %10s  %5d files  %s\n", cli.FormatSize(int(dir.Bytes)), dir.Files, dir.Path)
+		}
+	}
+
+	if len(stats.Largest) > 0 {
+		fmt.Fprintln(cli.Stdout, "Largest files:")
+		for _, f := range stats.Largest {
+			fmt.Fprintf(cli.Stdout, "  %10s  %s\n", cli.FormatSize(int(f.Bytes)), f.Path)
+		}
+	}
+}
+
+// statsCommand returns the `bulk stats` command.
+func statsCommand() *cobra.Command {
+	stats := &cobra.Command{
+		GroupID: "info",
+		Use:     "stats [--format json]",
+		Short:   "Show size and change counts of the checkout",
+		Long:    "Show the number and size of tracked files, per directory and the largest ones, along with counts of local changes, untracked resources, and conflicts, and when the checkout was last pulled. This only reads the metadata and local files and never makes any requests, so remote changes are as of the last pull or status.",
+		Example: "  " + os.Args[0] + " bulk stats --format json",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			stats := getStats(mustLoadMeta())
+			switch format, _ := cmd.Flags().GetString("format"); format {
+			case "json":
+				b, err := cli.MarshalShort("json", true, stats)
+				panicOnErr(err)
+				fmt.Fprintln(cli.Stdout, string(b))
+			case "text":
+				printStats(stats)
+			default:
+				panic("unknown format " + format + ", expected text or json")
+			}
+		},
+	}
+	stats.Flags().String("format", "text", "Output format, either text or json")
+	stats.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return stats
+}
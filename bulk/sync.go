@@ -0,0 +1,238 @@
+package bulk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// Exit codes of `bulk sync` besides zero for a completed sync and one for
+// errors. They are above the codes used for HTTP response statuses.
+const (
+	syncExitNothing   = 10
+	syncExitConflicts = 11
+)
+
+// syncExit exits the process with a `bulk sync` status, replaced in tests.
+var syncExit = os.Exit
+
+// syncResult is the outcome of a sync.
+type syncResult int
+
+const (
+	syncDone syncResult = iota
+	syncNothing
+	syncConflicts
+)
+
+// conflicts returns the locally changed files whose remote version changed
+// as well.
+func conflicts(local, remote []changedFile) []changedFile {
+	changedRemote := map[string]bool{}
+	for _, changed := range remote {
+		changedRemote[changed.File.Path] = true
+	}
+
+	conflicted := []changedFile{}
+	for _, changed := range local {
+		if changedRemote[changed.File.Path] {
+			conflicted = append(conflicted, changed)
+		}
+	}
+	return conflicted
+}
+
+// merge adds the results and request metrics of a pull or push to the report
+// of a sync.
+func (r *report) merge(other *report) {
+	r.Succeeded += other.Succeeded
+	r.Skipped += other.Skipped
+	r.Failed += other.Failed
+	r.Failures = append(r.Failures, other.Failures...)
+	r.Renamed = append(r.Renamed, other.Renamed...)
+	r.index.add(&other.index)
+
+	for path, metrics := range other.files {
+		if r.files[path] == nil {
+			r.files[path] = &requestMetrics{Path: path}
+		}
+		r.files[path].add(metrics)
+	}
+
+	if other.pacer.observed != nil {
+		r.pacer.observed = other.pacer.observed
+	}
+}
+
+// add sums up the requests of another set of metrics for the same file.
+func (m *requestMetrics) add(other *requestMetrics) {
+	if m.Status == 0 {
+		m.Status = other.Status
+	}
+	m.Requests += other.Requests
+	m.Retries += other.Retries
+	m.Bytes += other.Bytes
+	m.DurationMS += other.DurationMS
+	if other.Error != "" {
+		m.Error = other.Error
+	}
+}
+
+// Sync pulls remote changes and then pushes local changes, using a single
+// index for the conflict check and both phases. Nothing is changed if a file
+// was modified both locally and on the remote, unless `prefer` is `local` or
+// `remote` to resolve all conflicts in favor of that side. The conflicts are
+// returned if they blocked the sync.
+func (m *Meta) Sync(ctx context.Context, prefer string) (syncResult, []changedFile, error) {
+	if m.ReadOnly {
+		return syncDone, nil, fmt.Errorf("%w, or `bulk pull` to only pull", errReadOnly)
+	}
+	m.report = newReport("sync", m.URL)
+	combined := m.report
+	defer func() { m.report = combined }()
+
+	stop := combined.observe()
+	var entries []listEntry
+	if !m.NoIndex {
+		var err error
+		if entries, err = m.fetchIndex(ctx); err != nil {
+			stop()
+			return syncDone, nil, err
+		}
+		m.prefetched = entries
+	}
+	local, remote, err := m.GetChanged(ctx, collectFiles(m, []string{}, "", false, false))
+	stop()
+	if err != nil {
+		return syncDone, nil, err
+	}
+
+	if len(local) == 0 && len(remote) == 0 {
+		fmt.Fprintf(cli.Stdout, "Already in sync with %s\n", m.URL)
+		return syncNothing, nil, nil
+	}
+
+	conflicted := conflicts(local, remote)
+	if len(conflicted) > 0 {
+		switch prefer {
+		case "local":
+			m.keepLocal = map[string]bool{}
+			for _, changed := range conflicted {
+				m.keepLocal[changed.File.Path] = true
+			}
+		case "remote":
+			reset := map[string]bool{}
+			for _, changed := range conflicted {
+				// Discard the local changes so the pull overwrites the file.
+				if err := changed.File.Reset(); err != nil {
+					return syncDone, nil, err
+				}
+				reset[changed.File.Path] = true
+			}
+			pending := []changedFile{}
+			for _, changed := range local {
+				if !reset[changed.File.Path] {
+					pending = append(pending, changed)
+				}
+			}
+			local = pending
+			if err := m.Save(); err != nil {
+				return syncDone, nil, err
+			}
+		default:
+			fmt.Fprintln(cli.Stdout, "Conflicts (use --prefer-local or --prefer-remote to resolve them):")
+			for _, changed := range conflicted {
+				fmt.Fprint(cli.Stdout, changed)
+				if local, err := changed.File.localChanges(); err == nil && len(local) > 0 {
+					fmt.Fprintf(cli.Stdout, " (local changes to %s)", changedPointers(local))
+				}
+				fmt.Fprintln(cli.Stdout)
+			}
+			return syncConflicts, conflicted, nil
+		}
+	}
+
+	if len(remote) > 0 {
+		m.prefetched = entries
+		err := m.Pull(ctx)
+		combined.merge(m.report)
+		if err != nil {
+			return syncDone, nil, err
+		}
+	}
+
+	if len(local) > 0 {
+		m.prefetched = entries
+		err := m.Push(ctx)
+		combined.merge(m.report)
+		if err != nil {
+			return syncDone, nil, err
+		}
+	}
+
+	if combined.Failed > 0 {
+		return syncDone, nil, fmt.Errorf("unable to sync %s", pluralize(combined.Failed, "file"))
+	}
+
+	fmt.Fprintf(cli.Stdout, "Synced with %s in %s.\n", m.URL, time.Since(combined.start).Round(time.Millisecond))
+	return syncDone, nil, nil
+}
+
+// syncCommand returns the `bulk sync` command.
+func syncCommand() *cobra.Command {
+	sync := &cobra.Command{
+		GroupID: "remote",
+		Use:     "sync [--prefer-local | --prefer-remote]",
+		Short:   "Pull remote changes, then push local changes",
+		Long:    fmt.Sprintf("Pull all remote changes, then push all local changes, e.g. to mirror a checkout from cron. Files changed both locally and on the remote are conflicts, and nothing is pulled or pushed while there are any unless --prefer-local or --prefer-remote is given to resolve all of them in favor of one side. Running it again without new changes does nothing.\n\nExits with 0 when synced, %d when there was nothing to do, %d when conflicts blocked the sync, and 1 on errors.", syncExitNothing, syncExitConflicts),
+		Example: "  " + os.Args[0] + " bulk sync --prefer-remote --metrics-file sync.json",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			opts := SyncOptions{}
+			if preferLocal, _ := cmd.Flags().GetBool("prefer-local"); preferLocal {
+				opts.Prefer = "local"
+			}
+			if preferRemote, _ := cmd.Flags().GetBool("prefer-remote"); preferRemote {
+				opts.Prefer = "remote"
+			}
+			opts.Treat404AsGone, _ = cmd.Flags().GetBool("treat-404-as-gone")
+			opts.Rate, _ = cmd.Flags().GetFloat64("rate")
+			opts.PreserveComments, _ = cmd.Flags().GetBool("preserve-comments")
+			opts.Message, _ = cmd.Flags().GetString("message")
+			if opts.Message == "" {
+				opts.Message = os.Getenv(messageEnv)
+			}
+
+			checkout, err := Open("")
+			panicOnErr(err)
+			ctx, stop := interruptContext(cmd.Context())
+			defer stop()
+			result, err := checkout.Sync(ctx, opts)
+			writeMetrics(cmd, checkout.meta.report, err)
+			notify(cmd, checkout.meta.report, err)
+			panicOnErr(err)
+
+			switch {
+			case result.UpToDate:
+				syncExit(syncExitNothing)
+			case len(result.Conflicts) > 0:
+				syncExit(syncExitConflicts)
+			}
+		},
+	}
+	sync.Flags().Bool("prefer-local", false, "Resolve conflicts by pushing the local files over the remote changes")
+	sync.Flags().Bool("prefer-remote", false, "Resolve conflicts by discarding the local changes")
+	sync.MarkFlagsMutuallyExclusive("prefer-local", "prefer-remote")
+	sync.Flags().Bool("treat-404-as-gone", false, "Remove files which respond with 404 Not Found like 410 Gone")
+	sync.Flags().Bool("preserve-comments", false, "Keep comments in local files which are updated, where possible")
+	sync.Flags().Float64("rate", 0, "Maximum requests per second, overriding the pacing from RateLimit headers")
+	sync.Flags().StringP("message", "m", "", "Reason for the change, sent with each request and saved in the push record (env: "+messageEnv+")")
+	addNotifyFlags(sync)
+	addMetricsFlags(sync)
+
+	return sync
+}
@@ -0,0 +1,192 @@
+package bulk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/danielgtaylor/mexpr"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// confirm asks the user a yes/no question, defaulting to no.
+var confirm = func(message string) bool {
+	resp := false
+	if err := survey.AskOne(&survey.Confirm{Message: message}, &resp); err != nil {
+		return false
+	}
+	return resp
+}
+
+// hasPathPrefix returns whether a checkout path like `a/items/a1.json` is
+// matched by any of the given paths or directory prefixes, e.g. `a/items`.
+func hasPathPrefix(p string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = path.Clean(strings.ReplaceAll(prefix, "\\", "/"))
+		if prefix == "." || p == prefix || p == prefix+".json" || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Track adds untracked remote resources to the checkout if their path matches
+// one of the prefixes or their list item matches the expression, then pulls.
+func (m *Meta) Track(ctx context.Context, prefixes []string, match string) error {
+	if err := m.PullIndex(ctx); err != nil {
+		return err
+	}
+
+	var i mexpr.Interpreter
+	if match != "" {
+		i = newInterpreter(match, "", false)
+	}
+
+	paths := []string{}
+	for p, u := range m.untracked {
+		if !hasPathPrefix(p, prefixes) {
+			if i == nil {
+				continue
+			}
+			if result, err := i.Run(u.Item); err != nil || result == nil || cli.IsFalsey(result) {
+				continue
+			}
+		}
+		paths = append(paths, p)
+	}
+
+	if len(paths) == 0 {
+		return fmt.Errorf("no untracked resources match")
+	}
+
+	sort.Strings(paths)
+	for _, p := range paths {
+		m.Files[p] = m.untracked[p].File
+		delete(m.untracked, p)
+	}
+	m.Untracked = []string{}
+	for p := range m.untracked {
+		m.Untracked = append(m.Untracked, p)
+	}
+	sort.Strings(m.Untracked)
+
+	cli.LogInfo("Tracking %s", pluralize(len(paths), "resource"))
+	if err := m.Save(); err != nil {
+		return err
+	}
+
+	return m.Pull(ctx)
+}
+
+// Untrack removes resources from the checkout, deleting their local files
+// after confirmation. Nothing is changed on the remote.
+func (m *Meta) Untrack(prefixes []string, yes bool) error {
+	paths := []string{}
+	changed := 0
+	for p, f := range m.Files {
+		if hasPathPrefix(p, prefixes) {
+			paths = append(paths, p)
+			if f.IsChangedLocal(true) {
+				changed++
+			}
+		}
+	}
+
+	if len(paths) == 0 {
+		return fmt.Errorf("no tracked files match")
+	}
+	sort.Strings(paths)
+
+	if !yes {
+		for _, p := range paths {
+			fmt.Fprintln(cli.Stdout, "\t"+p)
+		}
+		message := fmt.Sprintf("Untrack and delete %s locally?", pluralize(len(paths), "file"))
+		if changed > 0 {
+			message = fmt.Sprintf("Untrack and delete %s locally, losing local changes to %s?", pluralize(len(paths), "file"), pluralize(changed, "file"))
+		}
+		if !confirm(message) {
+			return fmt.Errorf("untrack canceled")
+		}
+	}
+
+	for _, p := range paths {
+		if exists, _ := afero.Exists(afs, p); exists {
+			if err := afs.Remove(p); err != nil {
+				return err
+			}
+		}
+		delete(m.Files, p)
+		if !m.NoIndex {
+			// Without an index untracked resources are simply forgotten.
+			m.Untracked = append(m.Untracked, p)
+		}
+	}
+	sort.Strings(m.Untracked)
+
+	fmt.Fprintf(cli.Stdout, "Untracked %s\n", pluralize(len(paths), "file"))
+	return m.Save()
+}
+
+// pluralize returns a count with a noun, e.g. `1 file` or `2 files`.
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// trackCommands returns the `bulk track` and `bulk untrack` commands.
+func trackCommands() []*cobra.Command {
+	track := &cobra.Command{
+		GroupID: "remote",
+		Use:     "track [path... | --match expr | URL [path]]",
+		Short:   "Check out untracked remote resources",
+		Long:    "Add untracked remote resources to the checkout and pull them. Resources are matched by path or directory prefix, or via a match expression which is run against each item of the list response. In checkouts created with `bulk init --from-file` a single resource is tracked by URL instead, optionally with a local path.",
+		Example: "  " + os.Args[0] + " bulk track a/items\n  " + os.Args[0] + " bulk track -m 'user == a'\n  " + os.Args[0] + " bulk track api.example.com/books/1 books/first.json",
+		Run: func(cmd *cobra.Command, args []string) {
+			meta := mustLoadMeta()
+			ctx, stop := interruptContext(cmd.Context())
+			defer stop()
+			if meta.NoIndex {
+				if len(args) < 1 || len(args) > 2 {
+					panic("a URL and optional path are required")
+				}
+				p := ""
+				if len(args) > 1 {
+					p = args[1]
+				}
+				panicOnErr(meta.TrackURL(ctx, args[0], p))
+				return
+			}
+			match, _ := cmd.Flags().GetString("match")
+			if len(args) == 0 && match == "" {
+				panic("a path or --match expression is required")
+			}
+			panicOnErr(meta.Track(ctx, args, match))
+		},
+	}
+	track.Flags().StringP("match", "m", "", "Expression to match list items")
+
+	untrack := &cobra.Command{
+		GroupID: "local",
+		Use:     "untrack path...",
+		Short:   "Stop tracking resources and delete their local files",
+		Long:    "Remove resources from the checkout by path or directory prefix, deleting their local files after confirmation. Untracked resources are left alone by status, pull, and push, and nothing is deleted on the remote. Use `bulk track` to check them out again.",
+		Example: "  " + os.Args[0] + " bulk untrack a/items/a1.json\n  " + os.Args[0] + " bulk untrack b --yes",
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			yes, _ := cmd.Flags().GetBool("yes")
+			panicOnErr(mustLoadMeta().Untrack(args, yes))
+		},
+	}
+	untrack.Flags().BoolP("yes", "y", false, "Delete local files without asking for confirmation")
+
+	return []*cobra.Command{track, untrack}
+}
@@ -0,0 +1,268 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// Outcomes of comparing a tracked resource against its local file.
+const (
+	verifyIdentical = "identical"
+	verifyDiffering = "differing"
+	verifyMissing   = "missing"
+	verifyError     = "error"
+)
+
+// VerifiedFile is the outcome of comparing one remote resource with its local
+// file, see `bulk verify-remote`.
+type VerifiedFile struct {
+	Path   string `json:"path"`
+	URL    string `json:"url"`
+	Result string `json:"result"`
+	// Detail explains a missing resource or error.
+	Detail string `json:"detail,omitempty"`
+}
+
+// verifyReport summarizes a `bulk verify-remote` run.
+type verifyReport struct {
+	Identical int            `json:"identical"`
+	Differing int            `json:"differing"`
+	Missing   int            `json:"missing"`
+	Errors    int            `json:"errors"`
+	Files     []VerifiedFile `json:"files"`
+}
+
+// mismatched returns the number of resources which don't match.
+func (r *verifyReport) mismatched() int {
+	return r.Differing + r.Missing + r.Errors
+}
+
+// fetchCanonical fetches the remote contents of a file formatted like the
+// cached copies. If the cached copy is still current, as confirmed by a
+// conditional request, it is used instead of downloading the resource again.
+// Unlike `File.fetch` nothing about the file is updated.
+func fetchCanonical(ctx context.Context, m *Meta, f *File) ([]byte, error) {
+	cached, cacheErr := afero.ReadFile(afs, path.Join(metaDir, f.Path))
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if cacheErr == nil {
+		if f.ETag != "" {
+			req.Header.Set("If-None-Match", f.ETag)
+		} else if f.LastModified != "" {
+			req.Header.Set("If-Modified-Since", f.LastModified)
+		}
+	}
+	httpResp, err := cli.MakeRequest(req, cli.WithMediaTypes(m.Accept, m.ContentType))
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := cli.ReadBody(httpResp)
+	httpResp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	httpResp.Body = io.NopCloser(bytes.NewReader(raw))
+
+	resp, err := cli.ParseResponseFor(req, httpResp)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.Status == http.StatusNotModified && cacheErr == nil:
+		return cached, nil
+	case resp.Status == http.StatusNotFound:
+		return nil, errNotFound
+	case resp.Status == http.StatusGone:
+		return nil, errRemoved
+	case resp.Status >= http.StatusBadRequest:
+		return nil, cli.StatusError(resp, f.URL)
+	}
+
+	if _, err := verifyDigest(httpResp.Header, raw); err != nil {
+		return nil, err
+	}
+
+	return cli.MarshalShort("json", true, resp.Body)
+}
+
+// verifyFile compares the canonical remote and local contents of a file.
+func verifyFile(ctx context.Context, m *Meta, f *File) VerifiedFile {
+	v := VerifiedFile{Path: f.Path, URL: f.URL}
+
+	remote, err := fetchCanonical(ctx, m, f)
+	switch {
+	case errors.Is(err, errNotFound), errors.Is(err, errRemoved):
+		v.Result, v.Detail = verifyMissing, "missing on the remote: "+err.Error()
+		return v
+	case err != nil:
+		v.Result, v.Detail = verifyError, err.Error()
+		return v
+	}
+
+	local, err := afero.ReadFile(afs, f.Path)
+	if err != nil {
+		v.Result, v.Detail = verifyMissing, "missing locally"
+		return v
+	}
+
+	local, err = reformat(local)
+	if err == nil {
+		remote, err = reformat(remote)
+	}
+	if err != nil {
+		v.Result, v.Detail = verifyError, err.Error()
+		return v
+	}
+
+	v.Result = verifyDiffering
+	if bytes.Equal(local, remote) {
+		v.Result = verifyIdentical
+	}
+	return v
+}
+
+// VerifyRemote fetches each of the given tracked files and compares it with
+// its local contents, with up to `parallel` requests at once. Requests are
+// paced like a pull. Untracked paths are ignored.
+func (m *Meta) VerifyRemote(ctx context.Context, files []string, parallel int) (*verifyReport, error) {
+	tracked := []*File{}
+	seen := map[string]bool{}
+	for _, p := range files {
+		if f := m.Files[p]; f != nil && !seen[p] {
+			seen[p] = true
+			tracked = append(tracked, f)
+		}
+	}
+	sort.Slice(tracked, func(i, j int) bool {
+		return tracked[i].Path < tracked[j].Path
+	})
+
+	// The pacer isn't safe for concurrent use, so both waiting for a turn and
+	// observing rate limit headers are serialized.
+	p := &pacer{}
+	var mu sync.Mutex
+	previous := cli.OnRequestDone
+	cli.OnRequestDone = func(metric cli.RequestMetric) {
+		if metric.Header != nil {
+			mu.Lock()
+			p.observe(metric.Header)
+			mu.Unlock()
+		}
+	}
+	defer func() { cli.OnRequestDone = previous }()
+
+	r := &verifyReport{Files: make([]VerifiedFile, len(tracked))}
+	err := cli.RunParallel(ctx, len(tracked), parallel, func(ctx context.Context, i int) error {
+		mu.Lock()
+		err := p.wait(ctx, m.rate)
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+		r.Files[i] = verifyFile(ctx, m, tracked[i])
+		cli.BulkLog.Debug("Verified %s: %s", tracked[i].Path, r.Files[i].Result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range r.Files {
+		switch v.Result {
+		case verifyIdentical:
+			r.Identical++
+		case verifyDiffering:
+			r.Differing++
+		case verifyMissing:
+			r.Missing++
+		default:
+			r.Errors++
+		}
+	}
+
+	return r, nil
+}
+
+// printVerifyReport lists the mismatched resources and a summary.
+func printVerifyReport(r *verifyReport) {
+	for _, v := range r.Files {
+		if v.Result == verifyIdentical {
+			continue
+		}
+		line := fmt.Sprintf("\t%s:  %s", cli.Colorize("diff-remove", fmt.Sprintf("%9s", v.Result)), v.Path)
+		if v.Detail != "" {
+			line += " (" + v.Detail + ")"
+		}
+		fmt.Fprintln(cli.Stdout, line)
+	}
+	fmt.Fprintf(cli.Stdout, "%d identical, %d differing, %d missing, %s\n", r.Identical, r.Differing, r.Missing, pluralize(r.Errors, "error"))
+}
+
+// verifyRemoteCommand returns the `bulk verify-remote` command.
+func verifyRemoteCommand() *cobra.Command {
+	verify := &cobra.Command{
+		GroupID: "remote",
+		Use:     "verify-remote [file... | --match expr] [--format json]",
+		Short:   "Check that remote resources match the local files",
+		Long:    "Fetch each tracked resource and compare it with the local file after formatting both the same way, e.g. to prove that a migration pushed everything. Conditional requests are used where possible so resources which still match the cached copy aren't downloaded again. Nothing in the checkout is changed. Exits with 1 if any resource is differing, missing, or can't be fetched.",
+		Example: "  " + os.Args[0] + " bulk verify-remote\n  " + os.Args[0] + " bulk verify-remote a/items --format json",
+		Run: func(cmd *cobra.Command, args []string) {
+			meta := mustLoadMeta()
+			match, _ := cmd.Flags().GetString("match")
+			format, _ := cmd.Flags().GetString("format")
+			if format != "text" && format != "json" {
+				panic("unknown format " + format + ", expected text or json")
+			}
+			parallel, _ := cmd.Flags().GetInt("parallel")
+			meta.rate, _ = cmd.Flags().GetFloat64("rate")
+
+			files := []string{}
+			for _, p := range collectFiles(meta, []string{}, match, false, true) {
+				if len(args) == 0 || hasPathPrefix(p, args) {
+					files = append(files, p)
+				}
+			}
+
+			ctx, stop := interruptContext(cmd.Context())
+			defer stop()
+			r, err := meta.VerifyRemote(ctx, files, parallel)
+			panicOnErr(err)
+
+			if format == "json" {
+				b, err := cli.MarshalShort("json", true, r)
+				panicOnErr(err)
+				fmt.Fprintln(cli.Stdout, string(b))
+			} else {
+				printVerifyReport(r)
+			}
+
+			if n := r.mismatched(); n > 0 {
+				panic(fmt.Errorf("%d of %d resources don't match the remote", n, len(r.Files)))
+			}
+		},
+	}
+	verify.Flags().StringP("match", "m", "", "Expression to match")
+	verify.RegisterFlagCompletionFunc("match", completeMatch)
+	verify.Flags().String("format", "text", "Output format, either text or json")
+	verify.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	verify.Flags().Int("parallel", defaultParallel, "Number of resources to fetch at once")
+	verify.Flags().Float64("rate", 0, "Maximum requests per second, overriding the pacing from RateLimit headers")
+
+	return verify
+}
@@ -0,0 +1,219 @@
+package bulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// Workspace is a registered bulk checkout, which can be targeted by name from
+// anywhere via `bulk -C name`.
+type Workspace struct {
+	Path     string    `json:"path"`
+	URL      string    `json:"url"`
+	LastPull time.Time `json:"last_pull,omitempty"`
+}
+
+// configFs is the filesystem holding the user-level registry of checkouts,
+// which lives in the config directory rather than in any checkout.
+var configFs afero.Fs = afero.NewOsFs()
+
+// workspacesPath returns the path of the user-level registry of checkouts.
+func workspacesPath() string {
+	return filepath.Join(viper.GetString("config-directory"), "workspaces.json")
+}
+
+// loadWorkspaces loads the registry of checkouts by name.
+func loadWorkspaces() (map[string]*Workspace, error) {
+	workspaces := map[string]*Workspace{}
+	b, err := afero.ReadFile(configFs, workspacesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return workspaces, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &workspaces); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", workspacesPath(), err)
+	}
+	return workspaces, nil
+}
+
+// saveWorkspaces saves the registry of checkouts.
+func saveWorkspaces(workspaces map[string]*Workspace) error {
+	b, err := cli.MarshalShort("json", true, workspaces)
+	if err != nil {
+		return err
+	}
+	configFs.MkdirAll(filepath.Dir(workspacesPath()), 0700)
+	return afero.WriteFile(configFs, workspacesPath(), b, 0600)
+}
+
+// findWorkspace returns the name of the workspace registered for a path.
+func findWorkspace(workspaces map[string]*Workspace, path string) string {
+	for name, ws := range workspaces {
+		if ws.Path == path {
+			return name
+		}
+	}
+	return ""
+}
+
+// registerWorkspace records the checkout in the current directory in the
+// registry, updating its entry if it is already registered. New checkouts are
+// named after their directory unless a name is given.
+func registerWorkspace(name, url string) error {
+	path, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	workspaces, err := loadWorkspaces()
+	if err != nil {
+		return err
+	}
+
+	if existing := findWorkspace(workspaces, path); existing != "" {
+		if name == "" {
+			name = existing
+		}
+		delete(workspaces, existing)
+	}
+
+	if name == "" {
+		// Pick a unique name based on the directory, e.g. `books-2`.
+		base := filepath.Base(path)
+		name = base
+		for i := 2; workspaces[name] != nil; i++ {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+	} else if ws := workspaces[name]; ws != nil {
+		return fmt.Errorf("workspace %s is already registered for %s", name, ws.Path)
+	}
+
+	workspaces[name] = &Workspace{Path: path, URL: url}
+	return saveWorkspaces(workspaces)
+}
+
+// touchWorkspace updates the last pull time of the checkout in a directory,
+// or the current one if empty, if it is registered.
+func touchWorkspace(path string) error {
+	if path == "" {
+		var err error
+		if path, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+
+	workspaces, err := loadWorkspaces()
+	if err != nil {
+		return err
+	}
+
+	if name := findWorkspace(workspaces, path); name != "" {
+		workspaces[name].LastPull = time.Now().UTC()
+		return saveWorkspaces(workspaces)
+	}
+	return nil
+}
+
+// pruneWorkspaces removes registered checkouts whose directories no longer
+// contain a checkout, returning their names.
+func pruneWorkspaces(workspaces map[string]*Workspace) []string {
+	pruned := []string{}
+	for name, ws := range workspaces {
+		if _, err := afs.Stat(filepath.Join(ws.Path, metaFile)); err != nil {
+			delete(workspaces, name)
+			pruned = append(pruned, name)
+		}
+	}
+	sort.Strings(pruned)
+	return pruned
+}
+
+// changeWorkspace changes the working directory to a registered checkout by
+// name, or to a checkout directory if no workspace has that name.
+func changeWorkspace(name string) error {
+	workspaces, err := loadWorkspaces()
+	if err != nil {
+		return err
+	}
+
+	path := name
+	if ws := workspaces[name]; ws != nil {
+		path = ws.Path
+	} else if info, err := os.Stat(name); err != nil || !info.IsDir() {
+		return fmt.Errorf("unknown workspace %s, see `%s bulk workspaces`", name, cli.Root.Name())
+	}
+
+	cli.BulkLog.Debug("Using bulk checkout in %s", path)
+	return os.Chdir(path)
+}
+
+// listWorkspaces prints the registered checkouts after pruning any which no
+// longer exist.
+func listWorkspaces() error {
+	workspaces, err := loadWorkspaces()
+	if err != nil {
+		return err
+	}
+
+	if pruned := pruneWorkspaces(workspaces); len(pruned) > 0 {
+		cli.LogInfo("Pruned missing workspaces: %s", strings.Join(pruned, ", "))
+		if err := saveWorkspaces(workspaces); err != nil {
+			return err
+		}
+	}
+
+	if len(workspaces) == 0 {
+		fmt.Fprintf(cli.Stdout, "No workspaces, use `%s bulk init` to create one\n", cli.Root.Name())
+		return nil
+	}
+
+	names := make([]string, 0, len(workspaces))
+	for name := range workspaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	for _, name := range names {
+		ws := workspaces[name]
+		pulled := "never pulled"
+		if !ws.LastPull.IsZero() {
+			pulled = ws.LastPull.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(cli.Stdout, "%-*s  %-19s  %s  %s\n", width, name, pulled, ws.Path, ws.URL)
+	}
+	return nil
+}
+
+// workspacesCommand returns the `bulk workspaces` command.
+func workspacesCommand() *cobra.Command {
+	return &cobra.Command{
+		GroupID: "info",
+		Use:     "workspaces",
+		Aliases: []string{"ws"},
+		Short:   "List registered bulk checkouts",
+		Long:    "List the bulk checkouts registered via `bulk init` along with their path, index URL, and last pull time. Checkouts whose directories no longer exist are pruned from the registry. Use `bulk -C name` to run a bulk command in a registered checkout from anywhere.",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			panicOnErr(listWorkspaces())
+		},
+	}
+}
@@ -0,0 +1,104 @@
+package bulk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/tarunKoyalwar/restish/cli"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestMain(m *testing.M) {
+	// Never touch the real registry of checkouts.
+	configFs = afero.NewMemMapFs()
+	os.Exit(m.Run())
+}
+
+func TestWorkspaces(t *testing.T) {
+	defer gock.Off()
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	origFs := afs
+	defer func() { afs = origFs }()
+	afs = afero.NewOsFs()
+
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	books := filepath.Join(dir, "books")
+	other := filepath.Join(dir, "other")
+	os.MkdirAll(books, 0700)
+	os.MkdirAll(other, 0700)
+
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+	Init(cli.Root)
+	viper.Set("config-directory", filepath.Join(dir, "config"))
+
+	// Init registers the checkout named after its directory.
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11", fetch: true},
+		{User: "a", ID: "a2", Version: "a21", fetch: true},
+	})
+	os.Chdir(books)
+	expectPreflight()
+	_, err := run("bulk", "init", "example.com/all-items", "--url-template=/users/{user}/items/{id}")
+	require.NoError(t, err)
+	mustHaveCalledAllHTTPMocks(t)
+
+	workspaces, err := loadWorkspaces()
+	require.NoError(t, err)
+	require.Contains(t, workspaces, "books")
+	require.Equal(t, books, workspaces["books"].Path)
+	require.Equal(t, "https://example.com/all-items", workspaces["books"].URL)
+	require.False(t, workspaces["books"].LastPull.IsZero())
+
+	// Target the checkout by name from another directory.
+	os.Chdir(other)
+	expectRemote([]remoteFile{
+		{User: "a", ID: "a1", Version: "a11"},
+		{User: "a", ID: "a2", Version: "a21"},
+	})
+	out, err := run("bulk", "-C", "books", "status")
+	require.NoError(t, err)
+	require.Contains(t, out, "You are up to date")
+	mustHaveCalledAllHTTPMocks(t)
+
+	cwd, _ := os.Getwd()
+	require.Equal(t, books, cwd)
+
+	out, err = run("bulk", "-C", "missing", "status")
+	require.Error(t, err)
+	require.Contains(t, out, "unknown workspace missing")
+
+	// Flag values persist between runs in tests.
+	bulk, _, _ := cli.Root.Find([]string{"bulk"})
+	bulk.PersistentFlags().Set("workspace", "")
+
+	// Names must be unique.
+	os.Chdir(other)
+	out, err = run("bulk", "init", "example.com/all-items", "--name", "books")
+	require.Error(t, err)
+	require.Contains(t, out, "workspace books is already registered")
+
+	out, err = run("bulk", "workspaces")
+	require.NoError(t, err)
+	require.Contains(t, out, "books")
+	require.Contains(t, out, books)
+
+	// Vanished checkouts get pruned.
+	os.Chdir(dir)
+	os.RemoveAll(books)
+	out, err = run("bulk", "workspaces")
+	require.NoError(t, err)
+	require.Contains(t, out, "Pruned missing workspaces: books")
+	require.Contains(t, out, "No workspaces")
+
+	workspaces, err = loadWorkspaces()
+	require.NoError(t, err)
+	require.Empty(t, workspaces)
+}
@@ -8,6 +8,9 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,6 +31,12 @@ type API struct {
 	Operations     []Operation `json:"operations,omitempty" yaml:"operations,omitempty"`
 	Auth           []APIAuth   `json:"auth,omitempty" yaml:"auth,omitempty"`
 	AutoConfig     AutoConfig  `json:"auto_config,omitempty" yaml:"auto_config,omitempty"`
+
+	// Servers the API is available on, which can be selected via
+	// `--rsh-server`. Base is the URL which operation URIs were built from and
+	// which is replaced by the selected server.
+	Servers []Server `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Base    string   `json:"base,omitempty" yaml:"base,omitempty"`
 }
 
 // Merge two APIs together. Takes the description if none is set and merges
@@ -53,18 +62,55 @@ type Loader interface {
 	Load(entrypoint, spec url.URL, resp *http.Response) (API, error)
 }
 
+// NamedLoader is a Loader which can be picked explicitly by name using the
+// `loader` API config setting when auto-detection is ambiguous.
+type NamedLoader interface {
+	Loader
+	Name() string
+}
+
 // AddLoader adds a new API spec loader to the CLI.
 func AddLoader(loader Loader) {
 	loaders = append(loaders, loader)
 }
 
+// LoaderNames returns the names of the registered loaders which can be set
+// in an API's configuration.
+func LoaderNames() []string {
+	names := []string{}
+	for _, l := range loaders {
+		if named, ok := l.(NamedLoader); ok {
+			names = append(names, named.Name())
+		}
+	}
+	return names
+}
+
+// apiLoaders returns the loaders to try for an API, which is either all of
+// the registered loaders or only the one set in its configuration.
+func apiLoaders(config *APIConfig) ([]Loader, error) {
+	if config == nil || config.Loader == "" {
+		return loaders, nil
+	}
+
+	for _, l := range loaders {
+		if named, ok := l.(NamedLoader); ok && named.Name() == config.Loader {
+			return []Loader{l}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown loader %s, expected one of %v", config.Loader, LoaderNames())
+}
+
 func setupRootFromAPI(root *cobra.Command, api *API) {
+	currentAPI = api
+
 	if root.Short == "" {
 		root.Short = api.Short
 	}
 
 	if root.Long == "" {
-		root.Long = api.Long
+		root.Long = api.Long + serversHelp(api.Servers)
 	}
 
 	for _, op := range api.Operations {
@@ -87,12 +133,54 @@ func load(root *cobra.Command, entrypoint, spec url.URL, resp *http.Response, na
 	return api, nil
 }
 
+// defaultSpecMaxAge is how long a cached API description is used before it
+// gets revalidated, unless the API config sets `spec_max_age`.
+const defaultSpecMaxAge = 24 * time.Hour
+
+// parseMaxAge parses a duration like `12h` or `7d`.
+func parseMaxAge(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid max age %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// specMaxAge returns how long the API description for an API is fresh.
+func specMaxAge(name string) time.Duration {
+	if config := configs[name]; config != nil && config.SpecMaxAge != "" {
+		maxAge, err := parseMaxAge(config.SpecMaxAge)
+		if err == nil {
+			return maxAge
+		}
+		LogWarning("Ignoring spec_max_age for %s: %v", name, err)
+	}
+	return defaultSpecMaxAge
+}
+
+// formatAge returns a human-friendly description of a duration, like
+// `12 days` or `3 hours`.
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d/(24*time.Hour)))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%d minutes", int(d/time.Minute))
+}
+
 func cacheAPI(name string, api *API) {
 	if name == "" {
 		return
 	}
 
-	Cache.Set(name+".expires", time.Now().Add(24*time.Hour))
+	now := time.Now()
+	Cache.Set(name+".fetched", now)
+	Cache.Set(name+".expires", now.Add(specMaxAge(name)))
 	Cache.WriteConfig()
 
 	b, err := cbor.Marshal(api)
@@ -105,6 +193,111 @@ func cacheAPI(name string, api *API) {
 	}
 }
 
+// diffOperations compares the operations of two versions of an API by name
+// and returns the sorted names of those which were added, removed, or
+// modified.
+func diffOperations(previous, current API) (added, removed, modified []string) {
+	// Round-trip through the cache encoding so values have the same types as
+	// the previously cached operations.
+	if b, err := cbor.Marshal(current); err == nil {
+		var decoded API
+		if err := cbor.Unmarshal(b, &decoded); err == nil {
+			current = decoded
+		}
+	}
+
+	before := map[string]Operation{}
+	for _, op := range previous.Operations {
+		before[op.Name] = op
+	}
+
+	after := map[string]bool{}
+	for _, op := range current.Operations {
+		after[op.Name] = true
+		if old, ok := before[op.Name]; !ok {
+			added = append(added, op.Name)
+		} else if !reflect.DeepEqual(old, op) {
+			modified = append(modified, op.Name)
+		}
+	}
+
+	for _, op := range previous.Operations {
+		if !after[op.Name] {
+			removed = append(removed, op.Name)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(modified)
+	return
+}
+
+// readCachedAPI loads the cached API description for an API, if one exists
+// and was created by the same version of the CLI.
+func readCachedAPI(name, version string) (API, bool) {
+	var cached API
+	if name == "" {
+		return cached, false
+	}
+
+	filename := filepath.Join(getCacheDir(), name+".cbor")
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return cached, false
+	}
+
+	if err := cbor.Unmarshal(data, &cached); err != nil || cached.RestishVersion != version {
+		return cached, false
+	}
+
+	return cached, true
+}
+
+// cachedSpecAge returns how old the cached API description is.
+func cachedSpecAge(name string) time.Duration {
+	fetched := Cache.GetTime(name + ".fetched")
+	if fetched.IsZero() {
+		// Caches written by older versions only have the expiration time.
+		fetched = Cache.GetTime(name + ".expires").Add(-defaultSpecMaxAge)
+	}
+	return time.Since(fetched)
+}
+
+// revalidateAPI checks whether the API description has changed since it was
+// cached using a conditional request with the stored ETag. Returns true if
+// the cached description is still current.
+func revalidateAPI(name string) bool {
+	etag := Cache.GetString(name + ".etag")
+	spec := Cache.GetString(name + ".spec")
+	if etag == "" || spec == "" {
+		return false
+	}
+
+	CacheLog.Debug("Revalidating API description %s", spec)
+	req, err := http.NewRequest(http.MethodGet, spec, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("If-None-Match", etag)
+
+	resp, err := MakeRequest(req, WithoutCache(), IgnoreCLIParams(), WithoutHistory())
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotModified {
+		return false
+	}
+
+	now := time.Now()
+	Cache.Set(name+".fetched", now)
+	Cache.Set(name+".expires", now.Add(specMaxAge(name)))
+	Cache.WriteConfig()
+	return true
+}
+
 // Load will hydrate the command tree for an API, possibly refreshing the
 // API spec if the cache is out of date.
 func Load(entrypoint string, root *cobra.Command) (API, error) {
@@ -112,7 +305,6 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 	defer func() {
 		LogDebug("API loading took %s", time.Since(start))
 	}()
-	uris := []string{}
 
 	if !strings.HasSuffix(entrypoint, "/") {
 		entrypoint += "/"
@@ -124,36 +316,68 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 	}
 
 	name, config := findAPI(entrypoint)
-	desc := API{}
-	found := false
 
-	// See if there is a cache we can quickly load.
-	expires := Cache.GetTime(name + ".expires")
-	if !viper.GetBool("rsh-no-cache") && !expires.IsZero() && expires.After(time.Now()) {
-		var cached API
-		filename := filepath.Join(getCacheDir(), name+".cbor")
-		if data, err := os.ReadFile(filename); err == nil {
-			if err := cbor.Unmarshal(data, &cached); err == nil {
-				if cached.RestishVersion == root.Version {
-					setupRootFromAPI(root, &cached)
-					return cached, nil
-				}
-			}
-		}
+	// See if there is a cache we can quickly load. Shell completion must never
+	// block on the network, so it uses the cache even when it is out of date.
+	completing := viper.GetBool("completing")
+	noCache := viper.GetBool("rsh-no-cache")
+	cached, hasCache := readCachedAPI(name, root.Version)
+	useCache := hasCache && completing
+	if hasCache && !completing && !noCache {
+		// Once expired, cheaply check whether the description has changed.
+		useCache = Cache.GetTime(name+".expires").After(time.Now()) || revalidateAPI(name)
+	}
+	if useCache {
+		CacheLog.Debug("Using cached API description, spec is %s old", formatAge(cachedSpecAge(name)))
+		setupRootFromAPI(root, &cached)
+		return cached, nil
 	}
 
-	fromFileOrUrl := func(uri string) ([]byte, error) {
-		uriLower := strings.ToLower(uri)
-		if strings.Index(uriLower, "http") == 0 {
-			resp, err := http.Get(uri)
-			if err != nil {
-				return []byte{}, err
-			}
-			return io.ReadAll(resp.Body)
-		} else {
-			return os.ReadFile(os.ExpandEnv(uri))
+	if completing {
+		CacheLog.Debug("No cached API description for %s, skipping completion", entrypoint)
+		return API{}, nil
+	}
+
+	api, err := fetchAPI(root, uri, name, config)
+	if err != nil && hasCache && !noCache {
+		// Keep working offline using the out of date cache.
+		CacheLog.Debug("Unable to refresh API description: %v", err)
+		CacheLog.Debug("Using cached API description, spec is %s old", formatAge(cachedSpecAge(name)))
+		setupRootFromAPI(root, &cached)
+		return cached, nil
+	}
+
+	return api, err
+}
+
+// fromFileOrUrl reads an API description from a local file or URL.
+func fromFileOrUrl(uri string) ([]byte, error) {
+	uriLower := strings.ToLower(uri)
+	if strings.Index(uriLower, "http") == 0 {
+		resp, err := http.Get(uri)
+		if err != nil {
+			return []byte{}, err
 		}
+		defer resp.Body.Close()
+		return io.ReadAll(resp.Body)
+	} else {
+		return os.ReadFile(os.ExpandEnv(uri))
+	}
+}
+
+// fetchAPI downloads and loads the API description from the configured spec
+// files or by discovering it from the API's base URI, updating the cache.
+func fetchAPI(root *cobra.Command, uri *url.URL, name string, config *APIConfig) (API, error) {
+	entrypoint := uri.String()
+	uris := []string{}
+	desc := API{}
+	found := false
+
+	candidates, err := apiLoaders(config)
+	if err != nil {
+		return API{}, err
 	}
+
 	if name != "" && len(config.SpecFiles) > 0 {
 		// Load the local files
 		for _, filename := range config.SpecFiles {
@@ -170,11 +394,12 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 			// No need to check error, it was checked above in `fromFileOrUrl`.
 			uriSpec, _ := url.Parse(filename)
 
-			for _, l := range loaders {
+			for _, l := range candidates {
 				// Reset the body
 				resp.Body = io.NopCloser(bytes.NewReader(body))
 
-				if l.Detect(resp) {
+				// Spec files are always loaded by an explicitly configured loader.
+				if config.Loader != "" || l.Detect(resp) {
 					found = true
 					resp.Body = io.NopCloser(bytes.NewReader(body))
 					tmp, err := load(root, *uri, *uriSpec, resp, name, l)
@@ -189,6 +414,8 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 
 		if found {
 			desc.RestishVersion = root.Version
+			Cache.Set(name+".etag", "")
+			Cache.Set(name+".spec", "")
 			cacheAPI(name, &desc)
 			return desc, nil
 		}
@@ -205,8 +432,7 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 	// We will almost never be in a situation where we don't want to use
 	// the parsed API cache, but do want to use a cached response from
 	// the server.
-	client := &http.Client{Transport: InvalidateCachedTransport()}
-	httpResp, err := MakeRequest(req, WithClient(client), IgnoreCLIParams())
+	httpResp, err := MakeRequest(req, WithoutCache(), IgnoreCLIParams(), WithoutHistory())
 	if err != nil {
 		return API{}, err
 	}
@@ -227,7 +453,7 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 
 	// Try hints from loaders next. These are likely places for API descriptions
 	// to be on the server, like e.g. `/openapi.json`.
-	for _, l := range loaders {
+	for _, l := range candidates {
 		uris = append(uris, l.LocationHints()...)
 	}
 
@@ -246,7 +472,7 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 			return API{}, err
 		}
 
-		resp, err := MakeRequest(req, WithClient(client), IgnoreCLIParams())
+		resp, err := MakeRequest(req, WithoutCache(), IgnoreCLIParams(), WithoutHistory())
 		if err != nil {
 			return API{}, err
 		}
@@ -259,7 +485,7 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 			return API{}, err
 		}
 
-		for _, l := range loaders {
+		for _, l := range candidates {
 			// Reset the body
 			resp.Body = io.NopCloser(bytes.NewReader(body))
 
@@ -274,6 +500,9 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 				}
 				api, err := load(root, *opsBase, *resolved, resp, name, l)
 				if err == nil {
+					api.RestishVersion = root.Version
+					Cache.Set(name+".etag", resp.Header.Get("ETag"))
+					Cache.Set(name+".spec", resolved.String())
 					cacheAPI(name, &api)
 				}
 				return api, err
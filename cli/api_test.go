@@ -1,13 +1,17 @@
 package cli
 
 import (
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type overrideLoader struct {
@@ -36,6 +40,15 @@ func (l *overrideLoader) LocationHints() []string {
 	return []string{}
 }
 
+type namedLoader struct {
+	overrideLoader
+	name string
+}
+
+func (l *namedLoader) Name() string {
+	return l.name
+}
+
 func TestLoadFromFile(t *testing.T) {
 	reset(false)
 	viper.Set("rsh-no-cache", true)
@@ -74,3 +87,137 @@ func TestBadSpecURL(t *testing.T) {
 	_, err := Load("https://api.example.com", &cobra.Command{})
 	assert.Error(t, err)
 }
+
+func TestExplicitLoader(t *testing.T) {
+	reset(false)
+	viper.Set("rsh-no-cache", true)
+
+	defer func(orig []Loader) { loaders = orig }(loaders)
+	loaders = []Loader{
+		&namedLoader{name: "first", overrideLoader: overrideLoader{
+			load: func(entrypoint, spec url.URL, resp *http.Response) (API, error) {
+				return API{Short: "first"}, nil
+			},
+		}},
+		&namedLoader{name: "second", overrideLoader: overrideLoader{
+			detect: func(resp *http.Response) bool { return false },
+			load: func(entrypoint, spec url.URL, resp *http.Response) (API, error) {
+				return API{Short: "second"}, nil
+			},
+		}},
+	}
+	assert.Equal(t, []string{"first", "second"}, LoaderNames())
+
+	configs["explicit-loader-test"] = &APIConfig{
+		Base:      "https://explicit.example.com",
+		SpecFiles: []string{"testdata/petstore.json"},
+	}
+
+	// Auto-detection picks the first matching loader.
+	api, err := Load("https://explicit.example.com", &cobra.Command{})
+	require.NoError(t, err)
+	assert.Equal(t, "first", api.Short)
+
+	// An explicitly configured loader skips detection.
+	configs["explicit-loader-test"].Loader = "second"
+	api, err = Load("https://explicit.example.com", &cobra.Command{})
+	require.NoError(t, err)
+	assert.Equal(t, "second", api.Short)
+
+	configs["explicit-loader-test"].Loader = "missing"
+	_, err = Load("https://explicit.example.com", &cobra.Command{})
+	assert.ErrorContains(t, err, "unknown loader missing")
+}
+
+func TestParseMaxAge(t *testing.T) {
+	d, err := parseMaxAge("7d")
+	assert.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, d)
+
+	d, err = parseMaxAge("12h")
+	assert.NoError(t, err)
+	assert.Equal(t, 12*time.Hour, d)
+
+	_, err = parseMaxAge("xd")
+	assert.Error(t, err)
+
+	assert.Equal(t, "12 days", formatAge(12*24*time.Hour+time.Hour))
+	assert.Equal(t, "3 hours", formatAge(3*time.Hour))
+	assert.Equal(t, "5 minutes", formatAge(5*time.Minute))
+}
+
+func TestAPISyncRevalidate(t *testing.T) {
+	t.Setenv("TEST_CACHE_DIR", t.TempDir())
+	reset(false)
+
+	version := "1"
+	conditional := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openapi.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"`+version+`"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"`+version+`"`)
+		w.Write([]byte(version))
+	}))
+	defer ts.Close()
+
+	defer func(orig []Loader) { loaders = orig }(loaders)
+	loaders = []Loader{&overrideLoader{
+		locationHints: func() []string { return []string{"/openapi.json"} },
+		load: func(entrypoint, spec url.URL, resp *http.Response) (API, error) {
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) == "1" {
+				return API{Operations: []Operation{
+					{Name: "get-item", Method: http.MethodGet},
+					{Name: "delete-item", Method: http.MethodDelete},
+				}}, nil
+			}
+			return API{Operations: []Operation{
+				{Name: "get-item", Method: http.MethodGet, Short: "Get an item"},
+				{Name: "put-item", Method: http.MethodPut},
+			}}, nil
+		},
+	}}
+
+	configs["sync-revalidate"] = &APIConfig{
+		name: "sync-revalidate",
+		Base: ts.URL,
+		Profiles: map[string]*APIProfile{
+			"default": {},
+		},
+	}
+
+	out := runNoReset("api sync sync-revalidate")
+	assert.Equal(t, "sync-revalidate: loaded 2 operations\n", out)
+
+	version = "2"
+	out = runNoReset("api sync sync-revalidate")
+	assert.Equal(t, "sync-revalidate: 1 added, 1 removed, 1 modified\n  + put-item\n  - delete-item\n  ~ get-item\n", out)
+
+	out = runNoReset("api sync sync-revalidate")
+	assert.Equal(t, "sync-revalidate: no changes\n", out)
+
+	// Syncing doesn't disable the cache for anything else.
+	assert.False(t, viper.GetBool("rsh-no-cache"))
+
+	// Once expired, the cache is revalidated using the ETag.
+	Cache.Set("sync-revalidate.expires", time.Now().Add(-time.Hour))
+	api, err := Load(ts.URL, &cobra.Command{})
+	require.NoError(t, err)
+	assert.Len(t, api.Operations, 2)
+	assert.Equal(t, 1, conditional)
+	assert.True(t, Cache.GetTime("sync-revalidate.expires").After(time.Now()))
+
+	// Offline use falls back to the out of date cache.
+	ts.Close()
+	Cache.Set("sync-revalidate.expires", time.Now().Add(-time.Hour))
+	api, err = Load(ts.URL, &cobra.Command{})
+	require.NoError(t, err)
+	assert.Len(t, api.Operations, 2)
+}
@@ -31,6 +31,8 @@ type TLSConfig struct {
 	Cert               string        `json:"cert,omitempty" yaml:"cert,omitempty"`
 	Key                string        `json:"key,omitempty" yaml:"key,omitempty"`
 	CACert             string        `json:"ca_cert,omitempty" yaml:"ca_cert,omitempty" mapstructure:"ca_cert"`
+	CABundle           string        `json:"ca_bundle,omitempty" yaml:"ca_bundle,omitempty" mapstructure:"ca_bundle"`
+	Pins               []string      `json:"pins,omitempty" yaml:"pins,omitempty"`
 	PKCS11             *PKCS11Config `json:"pkcs11,omitempty" yaml:"pkcs11,omitempty"`
 }
 
@@ -41,12 +43,26 @@ type PKCS11Config struct {
 	Label string `json:"label" yaml:"label"`
 }
 
+// ProxyConfig describes which proxies to use for an API. Each proxy is a
+// URL using the `http`, `https`, or `socks5` scheme, which may include
+// credentials and `$VAR` references to environment variables.
+type ProxyConfig struct {
+	HTTP    string   `json:"http,omitempty" yaml:"http,omitempty"`
+	HTTPS   string   `json:"https,omitempty" yaml:"https,omitempty"`
+	NoProxy []string `json:"no_proxy,omitempty" yaml:"no_proxy,omitempty" mapstructure:"no_proxy"`
+}
+
 // APIProfile contains account-specific API information
 type APIProfile struct {
 	Base    string            `json:"base,omitempty" yaml:"base,omitempty"`
 	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
 	Query   map[string]string `json:"query,omitempty" yaml:"query,omitempty"`
 	Auth    *APIAuth          `json:"auth,omitempty" yaml:"auth,omitempty"`
+
+	// Server selects one of the servers from the API description by name,
+	// with optional values for its variables, unless `--rsh-server` is given.
+	Server          string            `json:"server,omitempty" yaml:"server,omitempty"`
+	ServerVariables map[string]string `json:"server_variables,omitempty" yaml:"server_variables,omitempty" mapstructure:"server_variables,omitempty"`
 }
 
 // APIConfig describes per-API configuration options like the base URI and
@@ -56,8 +72,17 @@ type APIConfig struct {
 	Base          string                 `json:"base" yaml:"base"`
 	OperationBase string                 `json:"operation_base,omitempty" yaml:"operation_base,omitempty" mapstructure:"operation_base,omitempty"`
 	SpecFiles     []string               `json:"spec_files,omitempty" yaml:"spec_files,omitempty" mapstructure:"spec_files,omitempty"`
+	SpecMaxAge    string                 `json:"spec_max_age,omitempty" yaml:"spec_max_age,omitempty" mapstructure:"spec_max_age,omitempty"`
+	Loader        string                 `json:"loader,omitempty" yaml:"loader,omitempty" mapstructure:"loader,omitempty"`
 	Profiles      map[string]*APIProfile `json:"profiles,omitempty" yaml:"profiles,omitempty" mapstructure:",omitempty"`
 	TLS           *TLSConfig             `json:"tls,omitempty" yaml:"tls,omitempty" mapstructure:",omitempty"`
+	Socket        string                 `json:"socket,omitempty" yaml:"socket,omitempty" mapstructure:"socket,omitempty"`
+	HTTPVersion   string                 `json:"http_version,omitempty" yaml:"http_version,omitempty" mapstructure:"http_version,omitempty"`
+	Proxy         *ProxyConfig           `json:"proxy,omitempty" yaml:"proxy,omitempty" mapstructure:",omitempty"`
+	Cookies       bool                   `json:"cookies,omitempty" yaml:"cookies,omitempty" mapstructure:"cookies,omitempty"`
+	Accept        string                 `json:"accept,omitempty" yaml:"accept,omitempty" mapstructure:"accept,omitempty"`
+	ContentType   string                 `json:"content_type,omitempty" yaml:"content_type,omitempty" mapstructure:"content_type,omitempty"`
+	Defaults      *ParamDefaults         `json:"defaults,omitempty" yaml:"defaults,omitempty" mapstructure:",omitempty"`
 }
 
 // Save the API configuration to disk.
@@ -196,11 +221,8 @@ func initAPIConfig() {
 				panic("API " + apiName + " not found")
 			}
 
-			// Remove the cache entry.
-			Cache.Set(apiName+":"+viper.GetString("rsh-profile"), "")
-
-			if err := Cache.WriteConfig(); err != nil {
-				panic(fmt.Errorf("Unable to write cache file: %w", err))
+			if err := clearAuthCache(apiName, viper.GetString("rsh-profile")); err != nil {
+				panic(err)
 			}
 		},
 	})
@@ -225,19 +247,40 @@ func initAPIConfig() {
 		},
 	})
 
-	apiCommand.AddCommand(&cobra.Command{
-		Use:   "sync short-name",
+	var syncAll *bool
+	syncCmd := &cobra.Command{
+		Use:   "sync [short-name]",
 		Short: "Sync an API",
-		Long:  "Force-fetch the latest API description and update the local cache.",
-		Args:  cobra.ExactArgs(1),
+		Long:  "Force-fetch the latest API description, update the local cache, and summarize which operations were added, removed, or modified.",
+		Args:  cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			viper.Set("rsh-no-cache", true)
-			_, err := Load(fixAddress(args[0]), Root)
-			if err != nil {
-				panic(err)
+			names := args
+			if *syncAll {
+				names = maps.Keys(configs)
+				sort.Strings(names)
+			}
+
+			if len(names) == 0 {
+				panic("an API short name or --all is required")
+			}
+
+			failed := 0
+			for _, name := range names {
+				if err := syncAPI(name); err != nil {
+					LogError("Unable to sync %s: %v", name, err)
+					failed++
+				}
+			}
+
+			if failed > 0 {
+				panic(fmt.Errorf("unable to sync %d API(s)", failed))
 			}
 		},
-	})
+	}
+	syncAll = syncCmd.Flags().Bool("all", false, "Sync all registered APIs")
+	apiCommand.AddCommand(syncCmd)
+	apiCommand.AddCommand(lintCommand())
+	apiCommand.AddCommand(docsCommand())
 
 	// Register API sub-commands
 	configs = apiConfigs{}
@@ -276,6 +319,51 @@ func initAPIConfig() {
 	}
 }
 
+// syncAPI force-fetches the API description for a registered API, updating
+// the cache, and prints a summary of how its operations changed.
+func syncAPI(name string) error {
+	if configs[name] == nil {
+		return fmt.Errorf("API %s not found", name)
+	}
+
+	// Load into a separate command tree so the operation commands don't get
+	// registered on the root command.
+	root := &cobra.Command{}
+	previous, hadCache := readCachedAPI(name, root.Version)
+
+	// Only bypass the cache for this load, not for anything else which runs
+	// in the same process afterward.
+	defer viper.Set("rsh-no-cache", viper.GetBool("rsh-no-cache"))
+	viper.Set("rsh-no-cache", true)
+	api, err := Load(fixAddress(name), root)
+	if err != nil {
+		return err
+	}
+
+	if !hadCache {
+		fmt.Fprintf(Stdout, "%s: loaded %d operations\n", name, len(api.Operations))
+		return nil
+	}
+
+	added, removed, modified := diffOperations(previous, api)
+	if len(added)+len(removed)+len(modified) == 0 {
+		fmt.Fprintf(Stdout, "%s: no changes\n", name)
+		return nil
+	}
+
+	fmt.Fprintf(Stdout, "%s: %d added, %d removed, %d modified\n", name, len(added), len(removed), len(modified))
+	for _, change := range []struct {
+		prefix string
+		names  []string
+	}{{"+", added}, {"-", removed}, {"~", modified}} {
+		for _, opName := range change.names {
+			fmt.Fprintf(Stdout, "  %s %s\n", change.prefix, opName)
+		}
+	}
+
+	return nil
+}
+
 func findAPI(uri string) (string, *APIConfig) {
 	apiName := viper.GetString("api-name")
 
@@ -308,6 +396,46 @@ func findAPI(uri string) (string, *APIConfig) {
 	return "", nil
 }
 
+// Principal describes who requests to an API are made as.
+type Principal struct {
+	API     string `json:"api"`
+	Profile string `json:"profile"`
+	Auth    string `json:"auth,omitempty"`
+
+	// Identity holds the non-secret auth params which identify the caller,
+	// like `username` or `client_id`.
+	Identity map[string]string `json:"identity,omitempty"`
+}
+
+// principalParams are the auth params which identify a caller without
+// revealing any secrets.
+var principalParams = []string{"username", "client_id", "profile"}
+
+// GetPrincipal returns who requests to a URL are made as, based on the API
+// and profile it belongs to and the auth configured for them. Nil is returned
+// if the URL doesn't belong to a configured API.
+func GetPrincipal(uri string) *Principal {
+	name, config := findAPI(uri)
+	if config == nil {
+		return nil
+	}
+
+	principal := &Principal{API: name, Profile: viper.GetString("rsh-profile")}
+	if profile := config.Profiles[principal.Profile]; profile != nil && profile.Auth != nil {
+		principal.Auth = profile.Auth.Name
+		for _, param := range principalParams {
+			if v := profile.Auth.Params[param]; v != "" {
+				if principal.Identity == nil {
+					principal.Identity = map[string]string{}
+				}
+				principal.Identity[param] = v
+			}
+		}
+	}
+
+	return principal
+}
+
 func editAPIs(exitFunc func(int)) {
 	editor := getEditor()
 	if editor == "" {
@@ -78,3 +78,30 @@ func TestEditBadCommand(t *testing.T) {
 		editAPIs(func(code int) {})
 	})
 }
+
+func TestGetPrincipal(t *testing.T) {
+	reset(false)
+	configs["principal"] = &APIConfig{
+		name: "principal",
+		Base: "https://principal.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Auth: &APIAuth{
+					Name: "oauth-client-credentials",
+					Params: map[string]string{
+						"client_id":     "abc",
+						"client_secret": "secret",
+					},
+				},
+			},
+		},
+	}
+
+	assert.Nil(t, GetPrincipal("https://other.example.com/items"))
+	assert.Equal(t, &Principal{
+		API:      "principal",
+		Profile:  "default",
+		Auth:     "oauth-client-credentials",
+		Identity: map[string]string{"client_id": "abc"},
+	}, GetPrincipal("https://principal.example.com/items"))
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IsFalsey returns if a value is falsey, such as `0`, `""`, `[]any{}`, etc.
+// Empty slices and maps are considered falsey.
+func IsFalsey(v any) bool {
+	switch t := v.(type) {
+	case bool:
+		return !t
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return t == 0
+	case float32, float64:
+		return t == 0.0
+	case string:
+		return len(t) == 0
+	case []byte:
+		return len(t) == 0
+	case []any:
+		return len(t) == 0
+	case map[string]any:
+		return len(t) == 0
+	case map[any]any:
+		return len(t) == 0
+	}
+	return false
+}
+
+// checkAssertions evaluates each match expression against the response and
+// returns an error naming every assertion which failed. Assertions are
+// combined via AND, so a single falsey result fails the whole command.
+func checkAssertions(resp Response, assertions []string) error {
+	if len(assertions) == 0 {
+		return nil
+	}
+
+	doc := makeJSONSafe(resp.Map())
+	failed := []string{}
+	for _, assertion := range assertions {
+		i, err := ParseMatch(assertion, nil, false)
+		if err != nil {
+			return fmt.Errorf("invalid assertion:\n%s", err.Pretty(assertion))
+		}
+
+		result, err := i.Run(doc)
+		if err != nil {
+			LogDebug("Assertion %s errored: %v", assertion, err)
+		}
+		if err != nil || result == nil || IsFalsey(result) {
+			failed = append(failed, assertion)
+			continue
+		}
+		LogDebug("Assertion passed: %s", assertion)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s failed: %s", pluralize(len(failed), "assertion"), strings.Join(failed, ", "))
+	}
+	return nil
+}
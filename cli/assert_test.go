@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestAssertions(t *testing.T) {
+	resp := Response{
+		Status:  200,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body: map[string]any{
+			"items": []any{
+				map[string]any{"name": "a", "price": 1.5},
+				map[string]any{"name": "b", "price": 0},
+			},
+		},
+	}
+
+	assert.NoError(t, checkAssertions(resp, nil))
+	assert.NoError(t, checkAssertions(resp, []string{
+		"status == 200",
+		"body.items where price > 0",
+		"body.items[0].name startsWith a",
+		"body.items.length == 2 and body.items[0].name == a",
+	}))
+
+	assert.EqualError(t, checkAssertions(resp, []string{
+		"status == 201",
+		"body.items.length == 2",
+		"body.items where price > 10",
+		"body.missing.field",
+	}), "3 assertions failed: status == 201, body.items where price > 10, body.missing.field")
+
+	assert.ErrorContains(t, checkAssertions(resp, []string{"status =="}), "invalid assertion")
+}
+
+func TestAssertCommand(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").Times(2).Reply(200).JSON(map[string]any{
+		"items": []any{"a"},
+	})
+
+	out := run("http://example.com/items -o json -f body.items[0] --rsh-assert status==200 --rsh-assert body.items")
+	assert.Equal(t, "\"a\"\n", out)
+
+	out = run("http://example.com/items -o json -f body.items[0] --rsh-assert status==200 --rsh-assert status>=300")
+	assert.Equal(t, "\"a\"\nERROR: Caught error: 1 assertion failed: status>=300\n", out)
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"os/exec"
 	"strings"
 	"syscall"
+	"time"
 
 	"golang.org/x/term"
 )
@@ -32,6 +34,26 @@ type AuthHandler interface {
 	OnRequest(req *http.Request, key string, params map[string]string) error
 }
 
+// RetryAuthHandler is an AuthHandler which signs the whole request, e.g.
+// including a timestamp, so it needs to be applied again before each retry.
+type RetryAuthHandler interface {
+	AuthHandler
+
+	// OnRetry applies auth again to a request which is about to be retried.
+	OnRetry(req *http.Request, key string, params map[string]string) error
+}
+
+// RefreshAuthHandler is an AuthHandler whose credentials can be rejected by
+// the server before they are known to expire, e.g. API keys which rotate.
+type RefreshAuthHandler interface {
+	AuthHandler
+
+	// OnUnauthorized gets new credentials for a request which the server
+	// responded to with 401 Unauthorized and applies them, returning whether
+	// the request should be sent again.
+	OnUnauthorized(req *http.Request, key string, params map[string]string) (bool, error)
+}
+
 var authHandlers map[string]AuthHandler = map[string]AuthHandler{}
 
 // AddAuth registers a new named auth handler.
@@ -164,3 +186,147 @@ func (a *ExternalToolAuth) OnRequest(req *http.Request, key string, params map[s
 	}
 	return nil
 }
+
+// ExternalTokenAuth gets a token from a command, e.g. a company's auth CLI,
+// and sends it in a header. Commands can return the token as plain text or
+// as JSON with an expiration time, which enables caching it until then.
+type ExternalTokenAuth struct{}
+
+// externalToken is the optional JSON output of a token command.
+type externalToken struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+	ExpiresAt   any    `json:"expires_at"`
+}
+
+// Parameters defines the ExternalTokenAuth parameter names.
+func (a *ExternalTokenAuth) Parameters() []AuthParam {
+	return []AuthParam{
+		{Name: "command", Required: true, Help: "Command which prints a token, where {param} is replaced by other auth params, e.g. corp-auth token --aud {audience}"},
+		{Name: "header", Help: "Header to send the token in, defaults to Authorization"},
+		{Name: "prefix", Help: "Prefix for the token, defaults to Bearer for the Authorization header"},
+	}
+}
+
+// parseExpiresAt parses an RFC 3339 timestamp or Unix time in seconds.
+func parseExpiresAt(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case float64:
+		return time.Unix(int64(t), 0), nil
+	case string:
+		return time.Parse(time.RFC3339, t)
+	}
+	return time.Time{}, fmt.Errorf("invalid expires_at %v", v)
+}
+
+// runTokenCommand runs the command and returns the token it printed along
+// with the time it expires, if known.
+func runTokenCommand(command string) (string, time.Time, error) {
+	shell, shellPresent := os.LookupEnv("SHELL")
+	if !shellPresent {
+		shell = "/bin/sh"
+	}
+
+	// Let the command prompt the user, e.g. to log in, while keeping stdout
+	// for the token. Only pass stdin through when it's a terminal, otherwise
+	// it is likely the request body.
+	cmd := exec.Command(shell, "-c", command)
+	cmd.Stderr = Stderr
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		cmd.Stdin = os.Stdin
+	}
+
+	AuthLog.Debug("Running auth command %s", command)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", time.Time{}, fmt.Errorf("auth command `%s` failed with exit code %d", command, exitErr.ExitCode())
+		}
+		return "", time.Time{}, fmt.Errorf("auth command `%s` failed: %w", command, err)
+	}
+
+	output := strings.TrimSpace(string(out))
+	if !strings.HasPrefix(output, "{") {
+		return output, time.Time{}, nil
+	}
+
+	var decoded externalToken
+	if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+		return "", time.Time{}, fmt.Errorf("auth command `%s` returned invalid JSON: %w", command, err)
+	}
+
+	token := decoded.Token
+	if token == "" {
+		token = decoded.AccessToken
+	}
+	if token == "" {
+		return "", time.Time{}, fmt.Errorf("auth command `%s` did not return a token", command)
+	}
+
+	expires, err := parseExpiresAt(decoded.ExpiresAt)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("auth command `%s` returned %w", command, err)
+	}
+
+	return token, expires, nil
+}
+
+// OnRequest gets run before the request goes out on the wire.
+func (a *ExternalTokenAuth) OnRequest(req *http.Request, key string, params map[string]string) error {
+	header := params["header"]
+	if header == "" {
+		header = "Authorization"
+	}
+
+	if req.Header.Get(header) != "" {
+		return nil
+	}
+
+	command := params["command"]
+	if command == "" {
+		return errors.New("invalid profile, external-token requires a command")
+	}
+	for k, v := range params {
+		command = strings.ReplaceAll(command, "{"+k+"}", v)
+	}
+
+	// Tokens are re-used until shortly before they expire.
+	tokenKey := key + ".token"
+	expiresKey := key + ".expires"
+	token := ""
+	if Cache.GetTime(expiresKey).After(time.Now().Add(tokenRefreshWindow)) {
+		AuthLog.Debug("Loading auth token from cache.")
+		token = GetSecret(tokenKey)
+	}
+
+	if token == "" {
+		var expires time.Time
+		var err error
+		token, expires, err = runTokenCommand(command)
+		if err != nil {
+			return err
+		}
+
+		if !expires.IsZero() {
+			SetSecret(tokenKey, token)
+			Cache.Set(expiresKey, expires)
+			if err := Cache.WriteConfig(); err != nil {
+				return err
+			}
+		}
+	}
+
+	prefix, hasPrefix := params["prefix"]
+	if !hasPrefix && strings.EqualFold(header, "Authorization") {
+		prefix = "Bearer"
+	}
+	if prefix != "" {
+		token = prefix + " " + token
+	}
+
+	req.Header.Set(header, token)
+	return nil
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalTokenAuth(t *testing.T) {
+	reset(false)
+	t.Setenv("SHELL", "/bin/sh")
+
+	auth := &ExternalTokenAuth{}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	require.NoError(t, auth.OnRequest(req, "ext-plain:default", map[string]string{
+		"command":  "echo tok-{audience}",
+		"audience": "svc",
+	}))
+	assert.Equal(t, "Bearer tok-svc", req.Header.Get("Authorization"))
+
+	// Plain tokens have no expiration so are not cached.
+	assert.Empty(t, Cache.GetString("ext-plain:default.token"))
+
+	req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	require.NoError(t, auth.OnRequest(req, "ext-header:default", map[string]string{
+		"command": "echo abc123",
+		"header":  "X-Token",
+	}))
+	assert.Equal(t, "abc123", req.Header.Get("X-Token"))
+	assert.Empty(t, req.Header.Get("Authorization"))
+}
+
+func TestExternalTokenAuthCache(t *testing.T) {
+	reset(false)
+	t.Setenv("SHELL", "/bin/sh")
+
+	// Count how many times the command runs using a file.
+	counter := filepath.Join(t.TempDir(), "count")
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	params := map[string]string{
+		"command": `echo run >> ` + counter + ` && echo '{"token": "json-tok", "expires_at": "` + expires + `"}'`,
+	}
+
+	// Start without a token cached from previous runs.
+	Cache.Set("ext-cache:default", "")
+
+	auth := &ExternalTokenAuth{}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+		require.NoError(t, auth.OnRequest(req, "ext-cache:default", params))
+		assert.Equal(t, "Bearer json-tok", req.Header.Get("Authorization"))
+	}
+
+	runs, err := os.ReadFile(counter)
+	require.NoError(t, err)
+	assert.Equal(t, "run\n", string(runs))
+	assert.Equal(t, "json-tok", Cache.GetString("ext-cache:default.token"))
+
+	// Expired tokens cause the command to run again.
+	Cache.Set("ext-cache:default.expires", time.Now().Add(-time.Minute))
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	require.NoError(t, auth.OnRequest(req, "ext-cache:default", params))
+
+	runs, err = os.ReadFile(counter)
+	require.NoError(t, err)
+	assert.Equal(t, "run\nrun\n", string(runs))
+}
+
+func TestExternalTokenAuthFailure(t *testing.T) {
+	reset(false)
+	t.Setenv("SHELL", "/bin/sh")
+
+	auth := &ExternalTokenAuth{}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	err := auth.OnRequest(req, "ext-fail:default", map[string]string{
+		"command": "exit 3",
+	})
+	assert.EqualError(t, err, "auth command `exit 3` failed with exit code 3")
+
+	req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	err = auth.OnRequest(req, "ext-fail:default", map[string]string{
+		"command": `echo '{"expires_at": 0}'`,
+	})
+	assert.ErrorContains(t, err, "did not return a token")
+}
@@ -0,0 +1,364 @@
+package cli
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/maps"
+)
+
+// tokenRefreshWindow is how long before expiring a cached token is renewed.
+// It matches the expiry delta of the OAuth 2.0 token sources.
+const tokenRefreshWindow = 10 * time.Second
+
+// AuthStatus describes the cached auth state of an API profile.
+type AuthStatus struct {
+	API          string            `json:"api" yaml:"api"`
+	Profile      string            `json:"profile" yaml:"profile"`
+	Auth         string            `json:"auth" yaml:"auth"`
+	Storage      string            `json:"storage" yaml:"storage"`
+	Expires      *time.Time        `json:"expires,omitempty" yaml:"expires,omitempty"`
+	ExpiresIn    string            `json:"expires_in,omitempty" yaml:"expires_in,omitempty"`
+	Expired      bool              `json:"expired" yaml:"expired"`
+	RefreshIn    string            `json:"refresh_in,omitempty" yaml:"refresh_in,omitempty"`
+	RefreshToken bool              `json:"refresh_token" yaml:"refresh_token"`
+	IssuedAt     *time.Time        `json:"issued_at,omitempty" yaml:"issued_at,omitempty"`
+	Subject      string            `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Scopes       []string          `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	Secrets      map[string]string `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+}
+
+// decodeJWTClaims returns the claims of a JWT without verifying it, or nil if
+// the token is not a JWT.
+func decodeJWTClaims(token string) map[string]any {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil
+	}
+
+	claims := map[string]any{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil
+	}
+	return claims
+}
+
+// claimTime converts a numeric date claim like `exp` into a time.
+func claimTime(claims map[string]any, name string) *time.Time {
+	if v, ok := claims[name].(float64); ok {
+		t := time.Unix(int64(v), 0)
+		return &t
+	}
+	return nil
+}
+
+// claimScopes returns the scopes from a `scope` or `scp` claim, which may be
+// a space-separated string or a list.
+func claimScopes(claims map[string]any) []string {
+	for _, name := range []string{"scope", "scp"} {
+		switch v := claims[name].(type) {
+		case string:
+			return strings.Fields(v)
+		case []any:
+			scopes := []string{}
+			for _, s := range v {
+				scopes = append(scopes, fmt.Sprintf("%v", s))
+			}
+			return scopes
+		}
+	}
+	return nil
+}
+
+// secretStorage describes where a config or cache value is kept.
+func secretStorage(value string) string {
+	switch {
+	case value == "":
+		return "none"
+	case strings.HasPrefix(value, keychainPrefix):
+		return "keychain"
+	}
+	return "plaintext"
+}
+
+// getAuthStatus inspects the cached token for an API profile. JWT claims are
+// decoded locally to find the expiration and scopes.
+func getAuthStatus(apiName, profileName string, auth *APIAuth) AuthStatus {
+	key := apiName + ":" + profileName
+	status := AuthStatus{
+		API:          apiName,
+		Profile:      profileName,
+		Auth:         auth.Name,
+		Storage:      secretStorage(Cache.GetString(key + ".token")),
+		RefreshToken: Cache.GetString(key+".refresh") != "",
+	}
+
+	for _, param := range secretParams {
+		if value := auth.Params[param]; value != "" {
+			if status.Secrets == nil {
+				status.Secrets = map[string]string{}
+			}
+			status.Secrets[param] = secretStorage(value)
+		}
+	}
+
+	if status.Storage == "none" {
+		return status
+	}
+
+	if expires := Cache.GetTime(key + ".expires"); !expires.IsZero() {
+		status.Expires = &expires
+	}
+
+	if claims := decodeJWTClaims(GetSecret(key + ".token")); claims != nil {
+		if status.Expires == nil {
+			status.Expires = claimTime(claims, "exp")
+		}
+		status.IssuedAt = claimTime(claims, "iat")
+		status.Subject, _ = claims["sub"].(string)
+		status.Scopes = claimScopes(claims)
+	}
+
+	if status.Expires != nil {
+		remaining := time.Until(*status.Expires).Round(time.Second)
+		status.Expired = remaining <= 0
+		if !status.Expired {
+			status.ExpiresIn = remaining.String()
+			status.RefreshIn = "0s"
+			if remaining > tokenRefreshWindow {
+				status.RefreshIn = (remaining - tokenRefreshWindow).String()
+			}
+		}
+	}
+
+	return status
+}
+
+// authProfile returns the API config and profile name from `[api] [profile]`
+// arguments, defaulting to the current profile.
+func authProfile(args []string) (*APIConfig, string) {
+	config := configs[args[0]]
+	if config == nil {
+		panic("API " + args[0] + " not found")
+	}
+
+	profileName := viper.GetString("rsh-profile")
+	if len(args) > 1 {
+		profileName = args[1]
+	}
+
+	if config.Profiles[profileName] == nil {
+		panic("invalid profile " + profileName)
+	}
+
+	return config, profileName
+}
+
+// clearAuthCache removes cached tokens for an API profile, including any in
+// the keychain.
+func clearAuthCache(apiName, profileName string) error {
+	key := apiName + ":" + profileName
+	DeleteSecret(key + ".token")
+	DeleteSecret(key + ".refresh")
+	Cache.Set(key, "")
+
+	if err := Cache.WriteConfig(); err != nil {
+		return fmt.Errorf("Unable to write cache file: %w", err)
+	}
+	return nil
+}
+
+// refreshAuth drops the cached access token, keeping any refresh token, and
+// has the auth handler get a new one.
+func refreshAuth(config *APIConfig, profileName string) error {
+	profile := config.Profiles[profileName]
+	if profile.Auth == nil || profile.Auth.Name == "" {
+		return fmt.Errorf("no auth set up for API")
+	}
+
+	handler := authHandlers[profile.Auth.Name]
+	if handler == nil {
+		return fmt.Errorf("unknown auth type %s", profile.Auth.Name)
+	}
+
+	key := config.name + ":" + profileName
+	DeleteSecret(key + ".token")
+	Cache.Set(key+".expires", time.Time{})
+	if err := Cache.WriteConfig(); err != nil {
+		return err
+	}
+
+	params, err := resolveAuthParams(profile.Auth.Params)
+	if err != nil {
+		return err
+	}
+
+	base := config.Base
+	if profile.Base != "" {
+		base = profile.Base
+	}
+
+	req, err := http.NewRequest(http.MethodGet, base, nil)
+	if err != nil {
+		return err
+	}
+	return handler.OnRequest(req, key, params)
+}
+
+// printAuthStatus writes the status as text, or in the output format given
+// via `-o`, e.g. JSON for scripts.
+func printAuthStatus(statuses []AuthStatus) {
+	outFormat := viper.GetString("rsh-output-format")
+	if outFormat != "auto" {
+		encoded, err := MarshalShort(outFormat, true, statuses)
+		if err != nil {
+			panic(err)
+		}
+
+		if useColor {
+			encoded, err = Highlight(outFormat, encoded)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		Stdout.Write(encoded)
+		return
+	}
+
+	for i, s := range statuses {
+		if i > 0 {
+			fmt.Fprintln(Stdout)
+		}
+
+		fmt.Fprintf(Stdout, "%s (%s): %s\n", s.API, s.Profile, s.Auth)
+		if s.Storage == "none" {
+			fmt.Fprintln(Stdout, "  Token: none cached")
+		} else {
+			fmt.Fprintf(Stdout, "  Token: stored in %s\n", s.Storage)
+		}
+		if s.Expires != nil {
+			if s.Expired {
+				fmt.Fprintf(Stdout, "  Expires: %s (expired)\n", s.Expires.Format(time.RFC3339))
+			} else {
+				fmt.Fprintf(Stdout, "  Expires: %s (in %s, refreshes in %s)\n", s.Expires.Format(time.RFC3339), s.ExpiresIn, s.RefreshIn)
+			}
+		}
+		if s.IssuedAt != nil {
+			fmt.Fprintf(Stdout, "  Issued: %s\n", s.IssuedAt.Format(time.RFC3339))
+		}
+		if s.Subject != "" {
+			fmt.Fprintf(Stdout, "  Subject: %s\n", s.Subject)
+		}
+		if len(s.Scopes) > 0 {
+			fmt.Fprintf(Stdout, "  Scopes: %s\n", strings.Join(s.Scopes, " "))
+		}
+		if s.Storage != "none" {
+			fmt.Fprintf(Stdout, "  Refresh token: %t\n", s.RefreshToken)
+		}
+		secrets := maps.Keys(s.Secrets)
+		sort.Strings(secrets)
+		for _, param := range secrets {
+			fmt.Fprintf(Stdout, "  Param %s: stored in %s\n", param, s.Secrets[param])
+		}
+	}
+}
+
+// initAuthCommands registers the auth status & secret management commands.
+func initAuthCommands() {
+	auth := &cobra.Command{
+		GroupID: "generic",
+		Use:     "auth",
+		Short:   "Auth management commands",
+		Long:    "Inspect, refresh, and clear cached auth tokens and manage how auth secrets like client secrets, passwords, and tokens are stored.",
+	}
+	Root.AddCommand(auth)
+
+	auth.AddCommand(&cobra.Command{
+		Use:   "status [short-name] [profile]",
+		Short: "Show cached auth status",
+		Long:  "Show the auth type, cached token expiration, time until it gets refreshed, and where secrets are stored. JWT claims like the expiration and scopes are decoded locally without verifying the token. Shows all APIs with auth if no API is given. Use `-o json` for output to use in scripts.",
+		Args:  cobra.MaximumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			statuses := []AuthStatus{}
+			if len(args) > 0 {
+				config, profileName := authProfile(args)
+				profile := config.Profiles[profileName]
+				if profile.Auth == nil || profile.Auth.Name == "" {
+					panic("no auth set up for API")
+				}
+				statuses = append(statuses, getAuthStatus(args[0], profileName, profile.Auth))
+			} else {
+				names := maps.Keys(configs)
+				sort.Strings(names)
+				for _, name := range names {
+					profileNames := maps.Keys(configs[name].Profiles)
+					sort.Strings(profileNames)
+					for _, profileName := range profileNames {
+						profile := configs[name].Profiles[profileName]
+						if profile != nil && profile.Auth != nil && profile.Auth.Name != "" {
+							statuses = append(statuses, getAuthStatus(name, profileName, profile.Auth))
+						}
+					}
+				}
+			}
+
+			printAuthStatus(statuses)
+		},
+	})
+
+	auth.AddCommand(&cobra.Command{
+		Use:   "refresh short-name [profile]",
+		Short: "Force an auth token refresh",
+		Long:  "Drop the cached access token and get a new one, using a refresh token when available.",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			config, profileName := authProfile(args)
+			if err := refreshAuth(config, profileName); err != nil {
+				panic(err)
+			}
+
+			printAuthStatus([]AuthStatus{getAuthStatus(args[0], profileName, config.Profiles[profileName].Auth)})
+		},
+	})
+
+	auth.AddCommand(&cobra.Command{
+		Use:   "clear short-name [profile]",
+		Short: "Clear cached auth tokens",
+		Long:  "Remove the cached access & refresh tokens. This will force a re-authentication the next time you make a request.",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			_, profileName := authProfile(args)
+			if err := clearAuthCache(args[0], profileName); err != nil {
+				panic(err)
+			}
+		},
+	})
+
+	auth.AddCommand(&cobra.Command{
+		Use:   "migrate-secrets",
+		Short: "Move secrets into the OS keychain",
+		Long:  "Move auth passwords & secrets from the API configuration and cached tokens from the cache file into the OS keychain, replacing them with references to the keychain entries.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			moved, err := migrateSecrets()
+			if err != nil {
+				panic(fmt.Errorf("unable to migrate secrets: %w", err))
+			}
+
+			fmt.Fprintf(Stdout, "Moved %d secrets to the keychain\n", moved)
+		},
+	})
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testJWT creates an unsigned JWT with the given claims.
+func testJWT(claims map[string]any) string {
+	payload, _ := json.Marshal(claims)
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestDecodeJWTClaims(t *testing.T) {
+	claims := decodeJWTClaims(testJWT(map[string]any{"sub": "user", "scp": []string{"a", "b"}}))
+	assert.Equal(t, "user", claims["sub"])
+	assert.Equal(t, []string{"a", "b"}, claimScopes(claims))
+
+	assert.Nil(t, decodeJWTClaims("opaque-token"))
+	assert.Nil(t, decodeJWTClaims("a.!!!.c"))
+}
+
+func TestAuthStatus(t *testing.T) {
+	reset(false)
+
+	configs["auth-status"] = &APIConfig{
+		name: "auth-status",
+		Base: "https://auth-status.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Auth: &APIAuth{
+					Name:   "oauth-client-credentials",
+					Params: map[string]string{"client_secret": "keychain:auth-status:default.client_secret"},
+				},
+			},
+			"none": {},
+		},
+	}
+
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	Cache.Set("auth-status:default", "")
+	Cache.Set("auth-status:default.token", testJWT(map[string]any{
+		"exp":   expires.Unix(),
+		"iat":   expires.Add(-2 * time.Hour).Unix(),
+		"sub":   "user",
+		"scope": "read write",
+	}))
+	Cache.Set("auth-status:default.refresh", "refresh")
+
+	var statuses []AuthStatus
+	captured := runNoReset("auth status auth-status -o json")
+	require.NoError(t, json.Unmarshal([]byte(captured), &statuses), captured)
+	require.Len(t, statuses, 1)
+
+	s := statuses[0]
+	assert.Equal(t, "oauth-client-credentials", s.Auth)
+	assert.Equal(t, "plaintext", s.Storage)
+	assert.True(t, expires.Equal(*s.Expires))
+	assert.False(t, s.Expired)
+	assert.NotEmpty(t, s.ExpiresIn)
+	assert.NotEmpty(t, s.RefreshIn)
+	assert.True(t, s.RefreshToken)
+	assert.Equal(t, "user", s.Subject)
+	assert.Equal(t, []string{"read", "write"}, s.Scopes)
+	assert.Equal(t, map[string]string{"client_secret": "keychain"}, s.Secrets)
+
+	captured = runNoReset("auth status auth-status -o auto")
+	assert.Contains(t, captured, "auth-status (default): oauth-client-credentials")
+	assert.Contains(t, captured, "Token: stored in plaintext")
+	assert.Contains(t, captured, "Scopes: read write")
+
+	captured = runNoReset("auth status auth-status none")
+	assert.Contains(t, captured, "no auth set up")
+
+	runNoReset("auth clear auth-status")
+	assert.Equal(t, "", Cache.GetString("auth-status:default.token"))
+	assert.Equal(t, "", Cache.GetString("auth-status:default.refresh"))
+
+	captured = runNoReset("auth status auth-status -o auto")
+	assert.Contains(t, captured, "Token: none cached")
+}
+
+func TestAuthRefresh(t *testing.T) {
+	reset(false)
+	t.Setenv("SHELL", "/bin/sh")
+
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	configs["auth-refresh"] = &APIConfig{
+		name: "auth-refresh",
+		Base: "https://auth-refresh.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Auth: &APIAuth{
+					Name: "external-token",
+					Params: map[string]string{
+						"command": `echo '{"token": "new", "expires_at": "` + expires + `"}'`,
+					},
+				},
+			},
+		},
+	}
+
+	// The cached token is still valid but gets replaced anyway.
+	Cache.Set("auth-refresh:default.token", "old")
+	Cache.Set("auth-refresh:default.expires", time.Now().Add(time.Hour))
+
+	captured := runNoReset("auth refresh auth-refresh")
+	assert.Contains(t, captured, "auth-refresh (default): external-token")
+	assert.Equal(t, "new", GetSecret("auth-refresh:default.token"))
+}
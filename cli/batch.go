@@ -0,0 +1,333 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// BatchRequest describes a single request in a batch file. Either a URL or an
+// API operation like `my-api list-items` must be given.
+type BatchRequest struct {
+	ID        string            `json:"id,omitempty" yaml:"id,omitempty"`
+	Method    string            `json:"method,omitempty" yaml:"method,omitempty"`
+	URL       string            `json:"url,omitempty" yaml:"url,omitempty"`
+	Operation string            `json:"operation,omitempty" yaml:"operation,omitempty"`
+	Params    map[string]any    `json:"params,omitempty" yaml:"params,omitempty"`
+	Query     map[string]string `json:"query,omitempty" yaml:"query,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body      any               `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// BatchResult is the record written for each request in a batch.
+type BatchResult struct {
+	Index      int     `json:"index"`
+	ID         string  `json:"id,omitempty"`
+	Method     string  `json:"method"`
+	URL        string  `json:"url"`
+	Status     int     `json:"status,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+	Body       any     `json:"body,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// failed returns whether the request failed to send or got an error status.
+func (r BatchResult) failed() bool {
+	return r.Error != "" || r.Status >= http.StatusBadRequest
+}
+
+// loadBatchFile reads a list of requests from a YAML or JSON file, or stdin
+// if the filename is `-`.
+func loadBatchFile(filename string) ([]BatchRequest, error) {
+	var data []byte
+	var err error
+	if filename == "-" {
+		data, err = io.ReadAll(Stdin)
+	} else {
+		data, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	requests := []BatchRequest{}
+	if err := yaml.Unmarshal(data, &requests); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", filename, err)
+	}
+
+	for i, r := range requests {
+		if (r.URL == "") == (r.Operation == "") {
+			return nil, fmt.Errorf("request %d must have either a url or an operation", i)
+		}
+	}
+
+	return requests, nil
+}
+
+// batchOperations loads API descriptions once for all requests in a batch.
+type batchOperations struct {
+	mu   sync.Mutex
+	apis map[string]API
+}
+
+// find returns the operation for a name like `my-api list-items`.
+func (b *batchOperations) find(name string) (Operation, error) {
+	parts := strings.Fields(name)
+	if len(parts) != 2 {
+		return Operation{}, fmt.Errorf("operation %q must be an API short name and operation name like `my-api list-items`", name)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	api, ok := b.apis[parts[0]]
+	if !ok {
+		if configs[parts[0]] == nil {
+			return Operation{}, fmt.Errorf("API %s not found", parts[0])
+		}
+
+		// Load into a separate command tree so the operation commands don't
+		// get registered on the root command.
+		var err error
+		if api, err = Load(fixAddress(parts[0]), &cobra.Command{}); err != nil {
+			return Operation{}, err
+		}
+		b.apis[parts[0]] = api
+	}
+
+	op, ok := findOperation(api, parts[1])
+	if !ok {
+		return Operation{}, fmt.Errorf("operation %s not found in API %s", parts[1], parts[0])
+	}
+	return op, nil
+}
+
+// newBatchHTTPRequest builds the HTTP request for an entry in a batch file.
+func newBatchHTTPRequest(ctx context.Context, r BatchRequest, ops *batchOperations) (*http.Request, error) {
+	method := strings.ToUpper(r.Method)
+	uri := r.URL
+	mediaType := "application/json"
+	headers := http.Header{}
+	query := url.Values{}
+
+	if r.Operation != "" {
+		op, err := ops.find(r.Operation)
+		if err != nil {
+			return nil, err
+		}
+
+		if method == "" {
+			method = op.Method
+		}
+		if op.BodyMediaType != "" {
+			mediaType = op.BodyMediaType
+		}
+
+		uri = op.URITemplate
+		for _, p := range op.PathParams {
+			value, ok := r.Params[p.Name]
+			if !ok {
+				return nil, fmt.Errorf("missing path param %s", p.Name)
+			}
+			uri = strings.Replace(uri, "{"+p.Name+"}", url.PathEscape(fmt.Sprintf("%v", value)), 1)
+		}
+		for _, p := range op.QueryParams {
+			if value, ok := r.Params[p.Name]; ok {
+				for _, v := range p.Serialize(value) {
+					query.Add(p.Name, v)
+				}
+			}
+		}
+		for _, p := range op.HeaderParams {
+			if value, ok := r.Params[p.Name]; ok {
+				for _, v := range p.Serialize(value) {
+					headers.Add(p.Name, v)
+				}
+			}
+		}
+	} else {
+		uri = fixAddress(uri)
+	}
+
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	for k, v := range r.Query {
+		query.Add(k, os.ExpandEnv(v))
+	}
+	if encoded := query.Encode(); encoded != "" {
+		if strings.Contains(uri, "?") {
+			uri += "&" + encoded
+		} else {
+			uri += "?" + encoded
+		}
+	}
+
+	for k, v := range r.Headers {
+		headers.Add(k, os.ExpandEnv(v))
+	}
+
+	var body io.Reader
+	if r.Body != nil {
+		encoded, err := marshalBody(mediaType, makeJSONSafe(r.Body))
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(encoded)
+		if headers.Get("Content-Type") == "" {
+			headers.Set("Content-Type", mediaType)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = headers
+	return req, nil
+}
+
+// runBatchRequest sends a single request from a batch and records the result.
+func runBatchRequest(ctx context.Context, i int, r BatchRequest, ops *batchOperations) (result BatchResult) {
+	result = BatchResult{Index: i, ID: r.ID, Method: strings.ToUpper(r.Method), URL: r.URL}
+
+	start := time.Now()
+	defer func() {
+		result.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	req, err := newBatchHTTPRequest(ctx, r, ops)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	resp, err := MakeRequest(req, IgnoreStatus())
+	result.Method = req.Method
+	result.URL = req.URL.String()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	parsed, err := getParsedResponse(req, resp)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = parsed.Status
+	result.Body = makeJSONSafe(parsed.Body)
+	return result
+}
+
+// runBatch sends the requests with up to `parallel` in flight at once and
+// writes each result as a line of JSON as soon as it completes. The first
+// request is sent by itself so that e.g. auth tokens are fetched and cached
+// only once. Returns the number of failed requests.
+func runBatch(w io.Writer, requests []BatchRequest, parallel int, failFast bool) (int, error) {
+	ops := &batchOperations{apis: map[string]API{}}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	mu := sync.Mutex{}
+	failed := 0
+	send := func(ctx context.Context, i int) error {
+		result := runBatchRequest(ctx, i, requests[i], ops)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+
+		if result.failed() {
+			failed++
+			if failFast {
+				if result.Error != "" {
+					return fmt.Errorf("request %d failed: %s", i, result.Error)
+				}
+				return fmt.Errorf("request %d failed with status %d", i, result.Status)
+			}
+		}
+		return nil
+	}
+
+	if len(requests) == 0 {
+		return 0, nil
+	}
+
+	if err := send(context.Background(), 0); err != nil {
+		return failed, err
+	}
+
+	err := RunParallel(context.Background(), len(requests)-1, parallel, func(ctx context.Context, i int) error {
+		return send(ctx, i+1)
+	})
+	return failed, err
+}
+
+// initBatchCommand registers the `batch` command.
+func initBatchCommand() {
+	var parallel *int
+	var failFast *bool
+
+	batch := &cobra.Command{
+		GroupID: "generic",
+		Use:     "batch filename",
+		Short:   "Send many requests from a file",
+		Long:    "Send a list of requests from a YAML or JSON file (or `-` for stdin) with bounded concurrency. Each request has either a `url` or an `operation` like `my-api list-items` with `params` for its path, query & header parameters, along with an optional `method`, `query`, `headers`, and `body`. A JSON record with the status, duration, and parsed body of each request is written on its own line as it completes. Use `--rsh-output-file` to write the records to a file.",
+		Example: fmt.Sprintf(`  # requests.yaml
+  - url: api.rest.sh/types
+  - operation: my-api get-item
+    params:
+      item-id: 123
+  - method: POST
+    url: my-api/items
+    body:
+      name: foo
+
+  $ %s batch requests.yaml --parallel 8`, Root.CommandPath()),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			requests, err := loadBatchFile(args[0])
+			if err != nil {
+				panic(err)
+			}
+
+			var w io.Writer = Stdout
+			if filename := viper.GetString("rsh-output-file"); filename != "" {
+				f, err := os.Create(filename)
+				if err != nil {
+					panic(err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			failed, err := runBatch(w, requests, *parallel, *failFast)
+			if err != nil {
+				panic(err)
+			}
+			if failed > 0 {
+				LogWarning("%d of %d requests failed", failed, len(requests))
+			}
+		},
+	}
+	parallel = batch.Flags().Int("parallel", 4, "Number of requests to send at once")
+	failFast = batch.Flags().Bool("fail-fast", false, "Stop sending requests after the first failure")
+
+	Root.AddCommand(batch)
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseBatchOutput parses NDJSON batch results, sorted by request index.
+func parseBatchOutput(t *testing.T, out string) []BatchResult {
+	results := []BatchResult{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if !strings.HasPrefix(line, "{") {
+			// Skip log output.
+			continue
+		}
+		var r BatchResult
+		require.NoError(t, json.Unmarshal([]byte(line), &r), line)
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+	return results
+}
+
+func batchServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/items":
+			b, _ := io.ReadAll(r.Body)
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.Equal(t, "abc", r.Header.Get("X-Test"))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write(b)
+		case "/search":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"q": "` + r.URL.Query().Get("q") + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func writeBatchFile(t *testing.T, contents string) string {
+	filename := filepath.Join(t.TempDir(), "requests.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(contents), 0600))
+	return filename
+}
+
+func TestBatch(t *testing.T) {
+	ts := batchServer(t)
+	defer ts.Close()
+
+	filename := writeBatchFile(t, `
+- id: create
+  method: post
+  url: `+ts.URL+`/items
+  headers:
+    X-Test: abc
+  body:
+    name: foo
+- url: `+ts.URL+`/search
+  query:
+    q: hello
+- url: `+ts.URL+`/missing
+`)
+
+	results := parseBatchOutput(t, run("batch --parallel 2 "+filename))
+	require.Len(t, results, 3)
+
+	assert.Equal(t, "create", results[0].ID)
+	assert.Equal(t, "POST", results[0].Method)
+	assert.Equal(t, http.StatusCreated, results[0].Status)
+	assert.Equal(t, map[string]any{"name": "foo"}, results[0].Body)
+
+	assert.Equal(t, "GET", results[1].Method)
+	assert.Equal(t, ts.URL+"/search?q=hello", results[1].URL)
+	assert.Equal(t, map[string]any{"q": "hello"}, results[1].Body)
+
+	// Failures don't stop the batch.
+	assert.Equal(t, http.StatusNotFound, results[2].Status)
+	assert.True(t, results[2].failed())
+}
+
+func TestBatchFailFast(t *testing.T) {
+	ts := batchServer(t)
+	defer ts.Close()
+
+	filename := writeBatchFile(t, `
+- url: `+ts.URL+`/missing
+- url: `+ts.URL+`/search
+`)
+
+	out := run("batch --fail-fast " + filename)
+	assert.Contains(t, out, "request 0 failed with status 404")
+
+	// The second request is never sent.
+	results := parseBatchOutput(t, out)
+	require.Len(t, results, 1)
+}
+
+func TestBatchOutputFile(t *testing.T) {
+	ts := batchServer(t)
+	defer ts.Close()
+
+	filename := writeBatchFile(t, `- url: `+ts.URL+`/search`)
+	output := filepath.Join(t.TempDir(), "out.ndjson")
+
+	run("batch --rsh-output-file " + output + " " + filename)
+
+	b, err := os.ReadFile(output)
+	require.NoError(t, err)
+	results := parseBatchOutput(t, string(b))
+	require.Len(t, results, 1)
+	assert.Equal(t, http.StatusOK, results[0].Status)
+}
+
+func TestBatchInvalidFile(t *testing.T) {
+	_, err := loadBatchFile(writeBatchFile(t, `- method: get`))
+	assert.ErrorContains(t, err, "request 0 must have either a url or an operation")
+}
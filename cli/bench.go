@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/exp/maps"
+)
+
+// benchResult is the outcome of a single request made while benchmarking.
+type benchResult struct {
+	latency time.Duration
+	status  int
+	err     error
+}
+
+// benchmarkEnabled returns whether requests should be repeated as a benchmark
+// via `--rsh-repeat` or `--rsh-duration`.
+func benchmarkEnabled() bool {
+	return viper.GetInt("rsh-repeat") > 0 || viper.GetDuration("rsh-duration") > 0
+}
+
+// benchRequest makes a single request from a copy of the original, reading
+// the full response body so the latency includes the transfer.
+func benchRequest(req *http.Request, body []byte) benchResult {
+	r := req.Clone(req.Context())
+	if body != nil {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	start := time.Now()
+	resp, err := MakeRequest(r, IgnoreStatus(), WithoutHistory())
+	if err != nil {
+		return benchResult{latency: time.Since(start), err: err}
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return benchResult{latency: time.Since(start), status: resp.StatusCode, err: err}
+}
+
+// runBenchmark sends the request `count` times, or repeatedly until the
+// duration has passed, with up to `parallel` requests in flight. Each copy of
+// the request goes through the normal request pipeline, including auth. The
+// first request is made by itself so that e.g. auth tokens are fetched and
+// cached only once.
+func runBenchmark(req *http.Request, count, parallel int, duration time.Duration) ([]benchResult, time.Duration, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, 0, err
+		}
+		req.Body.Close()
+	}
+
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	start := time.Now()
+	results := []benchResult{benchRequest(req, body)}
+
+	sent := int64(1)
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if count > 0 && atomic.AddInt64(&sent, 1) > int64(count) {
+					return
+				}
+				if duration > 0 && time.Since(start) >= duration {
+					return
+				}
+
+				result := benchRequest(req, body)
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results, time.Since(start), nil
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// roundLatency rounds a latency for display.
+func roundLatency(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+// printBenchmark writes a summary of the benchmark results.
+func printBenchmark(results []benchResult, elapsed time.Duration, parallel int) {
+	latencies := []time.Duration{}
+	statuses := map[int]int{}
+	errors := map[string]int{}
+	for _, r := range results {
+		if r.err != nil {
+			errors[r.err.Error()]++
+			continue
+		}
+		latencies = append(latencies, r.latency)
+		statuses[r.status]++
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	rate := float64(len(results)) / elapsed.Seconds()
+	fmt.Fprintf(Stdout, "Requests: %d in %s (%.1f/s), %d parallel\n", len(results), roundLatency(elapsed), rate, parallel)
+
+	if len(latencies) > 0 {
+		fmt.Fprintf(Stdout, "Latency:  min %s, p50 %s, p90 %s, p99 %s, max %s\n",
+			roundLatency(latencies[0]),
+			roundLatency(percentile(latencies, 50)),
+			roundLatency(percentile(latencies, 90)),
+			roundLatency(percentile(latencies, 99)),
+			roundLatency(latencies[len(latencies)-1]),
+		)
+	}
+
+	codes := maps.Keys(statuses)
+	sort.Ints(codes)
+	counts := []string{}
+	for _, code := range codes {
+		counts = append(counts, fmt.Sprintf("%d: %d", code, statuses[code]))
+	}
+	if len(counts) > 0 {
+		fmt.Fprintf(Stdout, "Status:   %s\n", strings.Join(counts, ", "))
+	}
+
+	total := 0
+	for _, n := range errors {
+		total += n
+	}
+	fmt.Fprintf(Stdout, "Errors:   %d\n", total)
+
+	messages := maps.Keys(errors)
+	sort.Strings(messages)
+	for _, msg := range messages {
+		fmt.Fprintf(Stdout, "  %d × %s\n", errors[msg], msg)
+	}
+}
+
+// benchmark repeats the request as configured via `--rsh-repeat`,
+// `--rsh-parallel`, and `--rsh-duration` and prints latency & status stats
+// instead of the response bodies. The HTTP cache is disabled so that every
+// request goes to the server.
+func benchmark(req *http.Request) error {
+	count := viper.GetInt("rsh-repeat")
+	parallel := viper.GetInt("rsh-parallel")
+	duration := viper.GetDuration("rsh-duration")
+
+	viper.Set("rsh-no-cache", true)
+
+	results, elapsed, err := runBenchmark(req, count, parallel, duration)
+	if err != nil {
+		return err
+	}
+
+	if parallel < 1 {
+		parallel = 1
+	}
+	printBenchmark(results, elapsed, parallel)
+	return nil
+}
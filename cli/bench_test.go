@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{}
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, 50*time.Millisecond, percentile(sorted, 50))
+	assert.Equal(t, 90*time.Millisecond, percentile(sorted, 90))
+	assert.Equal(t, 99*time.Millisecond, percentile(sorted, 99))
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+	assert.Equal(t, 5*time.Millisecond, percentile([]time.Duration{5 * time.Millisecond}, 99))
+}
+
+func TestBenchmark(t *testing.T) {
+	var count int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"name":"foo"}`, string(b))
+		assert.Equal(t, "abc", r.Header.Get("X-Test"))
+
+		if atomic.AddInt64(&count, 1)%4 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer ts.Close()
+
+	out := run("post " + ts.URL + " -H X-Test:abc --rsh-repeat 20 --rsh-parallel 4 name: foo")
+	assert.Equal(t, int64(20), atomic.LoadInt64(&count))
+	assert.Contains(t, out, "Requests: 20 in ")
+	assert.Contains(t, out, ", 4 parallel\n")
+	assert.Contains(t, out, "Latency:  min ")
+	assert.Contains(t, out, "Status:   200: 15, 503: 5\n")
+	assert.Contains(t, out, "Errors:   0\n")
+	assert.NotContains(t, out, "ok")
+}
+
+func TestBenchmarkDuration(t *testing.T) {
+	reset(false)
+
+	var count int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	results, elapsed, err := runBenchmark(req, 0, 2, 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+	assert.Greater(t, len(results), 2)
+	assert.Equal(t, int(atomic.LoadInt64(&count)), len(results))
+}
+
+func TestBenchmarkErrors(t *testing.T) {
+	reset(false)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:1/", nil)
+	results, elapsed, err := runBenchmark(req, 3, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	capture := &strings.Builder{}
+	Stdout = capture
+	printBenchmark(results, elapsed, 1)
+	assert.Contains(t, capture.String(), "Errors:   3\n  3 × ")
+	assert.NotContains(t, capture.String(), "Latency")
+}
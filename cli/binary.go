@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // initialize decoder
+	_ "image/jpeg" // initialize decoder
+	"image/png"
+	"mime"
+	"os"
+	"strings"
+
+	"github.com/eliukblau/pixterm/pkg/ansimage"
+	"github.com/mattn/go-sixel"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// binaryDumpBytes is how much of a binary body is shown as a hex dump.
+const binaryDumpBytes = 256
+
+// kittyChunkSize is the maximum payload size of a single kitty graphics
+// protocol escape sequence.
+const kittyChunkSize = 4096
+
+// Inline image protocols supported by various terminals.
+const (
+	imageBlocks = "blocks"
+	imageITerm  = "iterm"
+	imageKitty  = "kitty"
+	imageSixel  = "sixel"
+)
+
+// imageProtocol detects the best inline image protocol supported by the
+// terminal, falling back to unicode half-blocks which work nearly everywhere.
+func imageProtocol() string {
+	termName := os.Getenv("TERM")
+	program := os.Getenv("TERM_PROGRAM")
+
+	switch {
+	case termName == "xterm-kitty" || os.Getenv("KITTY_WINDOW_ID") != "" || program == "ghostty":
+		return imageKitty
+	case program == "iTerm.app" || program == "WezTerm" || os.Getenv("LC_TERMINAL") == "iTerm2":
+		return imageITerm
+	case strings.Contains(termName, "sixel") || termName == "foot" || termName == "mlterm" || program == "mlterm":
+		return imageSixel
+	}
+	return imageBlocks
+}
+
+// FormatSize formats a number of bytes for humans, e.g. `1.2 KiB`.
+func FormatSize(n int) string {
+	if n < 1024 {
+		return pluralize(n, "byte")
+	}
+
+	size := float64(n)
+	unit := ""
+	for _, unit = range []string{"KiB", "MiB", "GiB"} {
+		size /= 1024
+		if size < 1024 {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f %s", size, unit)
+}
+
+// binarySummary describes a binary body which can't be shown as text, with a
+// hex dump of the start of the data.
+func binarySummary(contentType string, b []byte) []byte {
+	if contentType == "" {
+		contentType = "unknown content type"
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Binary body: %s, %s\n\n", contentType, FormatSize(len(b)))
+
+	dump := b
+	if len(dump) > binaryDumpBytes {
+		dump = dump[:binaryDumpBytes]
+	}
+	buf.WriteString(hex.Dump(dump))
+
+	if more := len(b) - len(dump); more > 0 {
+		buf.WriteString(elided(more, "byte") + "\n")
+	}
+
+	buf.WriteString("\nRedirect the output or use --rsh-output-file to save the body")
+	if strings.HasPrefix(contentType, "image/") {
+		buf.WriteString(", or use --rsh-image to preview it")
+	}
+	buf.WriteString(".\n")
+
+	return buf.Bytes()
+}
+
+// renderImage returns the terminal escape sequences or unicode half-blocks to
+// display an image inline.
+func renderImage(contentType string, b []byte) ([]byte, error) {
+	w, h, err := term.GetSize(0)
+	if err != nil {
+		// Default to standard terminal size
+		w, h = 80, 24
+	}
+
+	switch imageProtocol() {
+	case imageITerm:
+		// iTerm2 decodes the image itself, see
+		// https://iterm2.com/documentation-images.html
+		return []byte(fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;preserveAspectRatio=1:%s\a", len(b), base64.StdEncoding.EncodeToString(b))), nil
+	case imageKitty:
+		// Kitty only accepts PNG or raw pixel data, see
+		// https://sw.kovidgoyal.net/kitty/graphics-protocol/
+		if contentType != "image/png" {
+			img, _, err := image.Decode(bytes.NewReader(b))
+			if err != nil {
+				return nil, err
+			}
+			converted := &bytes.Buffer{}
+			if err := png.Encode(converted, img); err != nil {
+				return nil, err
+			}
+			b = converted.Bytes()
+		}
+		return kittyImage(b), nil
+	case imageSixel:
+		img, _, err := image.Decode(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		buf := &bytes.Buffer{}
+		enc := sixel.NewEncoder(buf)
+		// Assume a typical cell size of 10x20 pixels to fit the terminal.
+		enc.Width = w * 10
+		enc.Height = h * 20
+		if err := enc.Encode(img); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	img, err := ansimage.NewScaledFromReader(bytes.NewReader(b), h*2, w*1, color.Transparent, ansimage.ScaleModeFit, ansimage.NoDithering)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(img.Render()), nil
+}
+
+// kittyImage encodes a PNG using the kitty graphics protocol, which splits the
+// data into chunks.
+func kittyImage(b []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(b)
+	buf := &bytes.Buffer{}
+	for i := 0; i < len(encoded); i += kittyChunkSize {
+		end := i + kittyChunkSize
+		more := 1
+		if end >= len(encoded) {
+			end = len(encoded)
+			more = 0
+		}
+
+		if i == 0 {
+			fmt.Fprintf(buf, "\x1b_Gf=100,a=T,m=%d;%s\x1b\\", more, encoded[i:end])
+		} else {
+			fmt.Fprintf(buf, "\x1b_Gm=%d;%s\x1b\\", more, encoded[i:end])
+		}
+	}
+	return buf.Bytes()
+}
+
+// formatBinary displays a binary body as an inline image if requested via
+// `--rsh-image`, otherwise as a summary. It never writes the raw bytes, which
+// would garble the terminal.
+func (f *DefaultFormatter) formatBinary(contentType string, b []byte) []byte {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mt
+	}
+
+	if viper.GetBool("rsh-image") && strings.HasPrefix(contentType, "image/") {
+		rendered, err := renderImage(contentType, b)
+		if err == nil {
+			return rendered
+		}
+		LogWarning("Unable to display image: %v", err)
+	}
+
+	return binarySummary(contentType, b)
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "1 byte", FormatSize(1))
+	assert.Equal(t, "1,023 bytes", FormatSize(1023))
+	assert.Equal(t, "1.2 KiB", FormatSize(1234))
+	assert.Equal(t, "5.0 MiB", FormatSize(5*1024*1024))
+}
+
+func TestBinarySummary(t *testing.T) {
+	body := bytes.Repeat([]byte{0, 1, 2, 3}, 100)
+	summary := string(binarySummary("application/octet-stream", body))
+
+	assert.True(t, strings.HasPrefix(summary, "Binary body: application/octet-stream, 400 bytes\n\n00000000  00 01 02 03"))
+	assert.Contains(t, summary, "000000f0  00 01 02 03")
+	assert.NotContains(t, summary, "00000100")
+	assert.Contains(t, summary, "… 144 more bytes\n")
+	assert.NotContains(t, summary, "--rsh-image")
+
+	assert.Contains(t, string(binarySummary("image/png", img)), "--rsh-image")
+}
+
+func TestImageProtocol(t *testing.T) {
+	for _, env := range []string{"TERM", "TERM_PROGRAM", "KITTY_WINDOW_ID", "LC_TERMINAL"} {
+		t.Setenv(env, "")
+	}
+
+	assert.Equal(t, imageBlocks, imageProtocol())
+
+	t.Setenv("TERM", "xterm-kitty")
+	assert.Equal(t, imageKitty, imageProtocol())
+
+	t.Setenv("TERM", "foot")
+	assert.Equal(t, imageSixel, imageProtocol())
+
+	t.Setenv("TERM_PROGRAM", "iTerm.app")
+	assert.Equal(t, imageITerm, imageProtocol())
+}
+
+func TestImagePreview(t *testing.T) {
+	for _, env := range []string{"TERM", "TERM_PROGRAM", "KITTY_WINDOW_ID", "LC_TERMINAL"} {
+		t.Setenv(env, "")
+	}
+
+	t.Setenv("TERM_PROGRAM", "iTerm.app")
+	out, err := renderImage("image/png", img)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "\x1b]1337;File=inline=1;size=75;"))
+
+	t.Setenv("TERM_PROGRAM", "")
+	t.Setenv("TERM", "xterm-kitty")
+	out, err = renderImage("image/png", img)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "\x1b_Gf=100,a=T,m=0;iVBORw0KGgo"))
+
+	// Large images are sent in chunks.
+	chunked := string(kittyImage(make([]byte, kittyChunkSize)))
+	assert.Equal(t, 2, strings.Count(chunked, "\x1b_G"))
+	assert.Contains(t, chunked, "\x1b_Gm=0;")
+
+	t.Setenv("TERM", "xterm-sixel")
+	out, err = renderImage("image/png", img)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "\x1bP"))
+}
+
+func TestFormatterBinary(t *testing.T) {
+	reset(false)
+	defer viper.Set("rsh-image", false)
+
+	resp := Response{
+		Headers: map[string]string{"Content-Type": "image/png"},
+		Body:    img,
+	}
+	buf := &bytes.Buffer{}
+	Stdout = buf
+
+	// Redirected output always gets the raw bytes.
+	require.NoError(t, NewDefaultFormatter(false, false).Format(resp))
+	assert.Equal(t, img, buf.Bytes())
+
+	buf.Reset()
+	require.NoError(t, NewDefaultFormatter(true, false).Format(resp))
+	assert.Contains(t, buf.String(), "Binary body: image/png, 75 bytes")
+
+	buf.Reset()
+	viper.Set("rsh-image", true)
+	require.NoError(t, NewDefaultFormatter(true, false).Format(resp))
+	assert.NotContains(t, buf.String(), "Binary body")
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -15,7 +16,6 @@ import (
 	"time"
 
 	"github.com/charmbracelet/glamour"
-	"github.com/logrusorgru/aurora"
 	"github.com/mattn/go-colorable"
 	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
@@ -48,23 +48,50 @@ var Stdout io.Writer = os.Stdout
 var Stderr io.Writer = os.Stderr
 
 var useColor bool
-var au aurora.Aurora
 
 // Keeps track of currently selected API for shell completions
 var currentConfig *APIConfig
 
-func generic(method string, addr string, args []string) {
-	var body io.Reader
+// currentAPI is the loaded description of the current API, if any.
+var currentAPI *API
+
+// currentProfile returns the selected profile of the current API, if any.
+func currentProfile() *APIProfile {
+	if currentConfig == nil {
+		return nil
+	}
+	return currentConfig.Profiles[viper.GetString("rsh-profile")]
+}
 
-	d, err := GetBody("application/json", args)
+// bodyMediaType returns the media type to marshal shorthand input into for a
+// request to the given URL. In order, this is the `--rsh-content-type`, a YAML
+// or TOML `Content-Type` header, JSON for any other header, the API's
+// `content_type`, or JSON.
+func bodyMediaType(uri string) string {
+	if ct := viper.GetString("rsh-content-type"); ct != "" {
+		return ct
+	}
+
+	for _, h := range viper.GetStringSlice("rsh-header") {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "content-type") {
+			if ct := strings.TrimSpace(parts[1]); strings.Contains(ct, "yaml") || strings.Contains(ct, "toml") {
+				return ct
+			}
+			return "application/json"
+		}
+	}
+	return requestMediaType(uri, "application/json")
+}
+
+func generic(method string, addr string, args []string) {
+	uri := fixAddress(addr)
+	body, err := GetRequestBody(bodyMediaType(uri), args)
 	if err != nil {
 		panic(err)
 	}
-	if len(d) > 0 {
-		body = strings.NewReader(d)
-	}
 
-	req, _ := http.NewRequest(method, fixAddress(addr), body)
+	req, _ := http.NewRequest(method, uri, body)
 	MakeRequestAndFormat(req)
 }
 
@@ -186,6 +213,7 @@ func completeGenericCmd(method string, showAPIs bool) func(cmd *cobra.Command, a
 func Init(name string, version string) {
 	initConfig(name, "")
 	initCache(name)
+	Secrets = keyringStore{service: name}
 
 	// Reset registries.
 	authHandlers = map[string]AuthHandler{}
@@ -193,6 +221,7 @@ func Init(name string, version string) {
 	encodings = map[string]ContentEncoding{}
 	linkParsers = []LinkParser{}
 	loaders = []Loader{}
+	UserAgent = ""
 
 	// Determine if we are using a TTY or colored output is forced-on.
 	tty := false
@@ -201,8 +230,11 @@ func Init(name string, version string) {
 		viper.Set("tty", true)
 	}
 
+	// Color can be disabled via `NO_COLOR`, see https://no-color.org/.
+	noColor := viper.GetBool("nocolor") || os.Getenv("NO_COLOR") != ""
+
 	useColor = false
-	if viper.GetBool("color") || (tty && !viper.GetBool("nocolor")) {
+	if viper.GetBool("color") || (tty && !noColor) {
 		useColor = true
 	}
 
@@ -214,8 +246,6 @@ func Init(name string, version string) {
 		viper.Set("color", useColor)
 	}
 
-	au = aurora.NewAurora(useColor)
-
 	Formatter = NewDefaultFormatter(tty, useColor)
 
 	cobra.AddTemplateFunc("highlight", func(s string) string {
@@ -251,6 +281,9 @@ func Init(name string, version string) {
 		ValidArgsFunction: completeGenericCmd(http.MethodGet, false),
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			settings := viper.AllSettings()
+			if !showSecrets() {
+				redactSettings(settings)
+			}
 			LogDebug("Configuration: %v", settings)
 		},
 		Run: func(cmd *cobra.Command, args []string) {
@@ -366,29 +399,53 @@ func Init(name string, version string) {
 	var interactive *bool
 	var noPrompt *bool
 	var editFormat *string
+	var editPatch *bool
 	edit := &cobra.Command{
 		GroupID:           "generic",
 		Use:               "edit uri [-i] [body...]",
 		Short:             "Edit a resource by URI",
-		Long:              "Convenience function which combines a GET, edit, and PUT operation into one command",
+		Long:              "Convenience function which combines a GET, edit, and PUT operation into one command. Changes are sent with `If-Match` or `If-Unmodified-Since` when possible, and if someone else modified the resource in the meantime it is fetched again with your changes re-applied for you to review.",
 		Args:              cobra.MinimumNArgs(1),
 		ValidArgsFunction: completeGenericCmd(http.MethodGet, true),
 		Run: func(cmd *cobra.Command, args []string) {
 			switch *editFormat {
 			case "json":
-				edit(args[0], args[1:], *interactive, *noPrompt, os.Exit, func(v interface{}) ([]byte, error) {
+				edit(args[0], args[1:], *interactive, *noPrompt, *editPatch, os.Exit, func(v interface{}) ([]byte, error) {
 					return json.MarshalIndent(v, "", "  ")
 				}, json.Unmarshal, ".json")
 			case "yaml":
-				edit(args[0], args[1:], *interactive, *noPrompt, os.Exit, yaml.Marshal, yaml.Unmarshal, ".yaml")
+				edit(args[0], args[1:], *interactive, *noPrompt, *editPatch, os.Exit, yaml.Marshal, yaml.Unmarshal, ".yaml")
 			}
 		},
 	}
 	interactive = edit.Flags().BoolP("rsh-interactive", "i", false, "Open an interactive editor")
 	noPrompt = edit.Flags().BoolP("rsh-yes", "y", false, "Disable prompt (answer yes automatically)")
 	editFormat = edit.Flags().StringP("rsh-edit-format", "e", "json", "Format to edit (default: json) [json, yaml]")
+	editPatch = edit.Flags().Bool("rsh-patch", false, "Send only the changes as a JSON merge patch via PATCH instead of a PUT")
 	Root.AddCommand(edit)
 
+	diffOpts := diffOptions{}
+	diff := &cobra.Command{
+		GroupID: "generic",
+		Use:     "diff uri [uri2]",
+		Short:   "Compare two responses",
+		Long:    "Fetch two URIs and show the differences between their headers & bodies, or compare a response body with a local file via `--against`. Bodies are normalized to consistently formatted JSON so only real changes are shown. The `Age` & `Date` headers are ignored.",
+		Example: fmt.Sprintf(`  # Compare staging & production
+  $ %s diff staging-api/items/1 prod-api/items/1
+
+  # Compare with a saved body, ignoring a field
+  $ %s diff my-api/items/1 --against @item.json --ignore /updated_at`, name, name),
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeGenericCmd(http.MethodGet, true),
+		Run: func(cmd *cobra.Command, args []string) {
+			diffResponses(args, diffOpts, os.Exit)
+		},
+	}
+	diff.Flags().StringVar(&diffOpts.Against, "against", "", "Compare with a JSON file like @file.json instead of a second URI")
+	diff.Flags().StringSliceVar(&diffOpts.Ignore, "ignore", nil, "JSON pointer to ignore in both bodies, e.g. /updated_at")
+	diff.Flags().BoolVar(&diffOpts.ExitCode, "exit-code", false, "Exit with status 1 if there are differences")
+	Root.AddCommand(diff)
+
 	authHeader := &cobra.Command{
 		GroupID: "generic",
 		Use:     "auth-header uri",
@@ -422,8 +479,13 @@ func Init(name string, version string) {
 			}
 
 			if auth, ok := authHandlers[profile.Auth.Name]; ok {
+				params, err := resolveAuthParams(profile.Auth.Params)
+				if err != nil {
+					panic(err)
+				}
+
 				req, _ := http.NewRequest(http.MethodGet, addr, nil)
-				err := auth.OnRequest(req, name+":"+viper.GetString("rsh-profile"), profile.Auth.Params)
+				err = auth.OnRequest(req, name+":"+viper.GetString("rsh-profile"), params)
 				if err != nil {
 					panic(err)
 				}
@@ -528,6 +590,57 @@ Not after (expires): %s (%s)
 	}
 	Root.AddCommand(linkCmd)
 
+	var dumpBinary *bool
+	ws := &cobra.Command{
+		GroupID: "generic",
+		Use:     "ws uri",
+		Short:   "Open a WebSocket connection",
+		Long:    "Upgrade a connection to a WebSocket using the normal headers, auth, and TLS settings. Each line from stdin is sent as a text message and received messages are printed until the connection is closed.",
+		Example: fmt.Sprintf(`  # Interactive session
+  $ %s ws wss://example.com/chat
+
+  # Send a message and print the replies
+  $ echo '{"subscribe": "prices"}' | %s ws my-api/stream`, name, name),
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeGenericCmd(http.MethodGet, true),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := WebSocket(args[0], *dumpBinary); err != nil {
+				panic(err)
+			}
+		},
+	}
+	dumpBinary = ws.Flags().BoolP("rsh-binary", "b", false, "Hex dump received binary messages")
+	Root.AddCommand(ws)
+
+	var gqlQuery, gqlOperation *string
+	var gqlVars *[]string
+	var gqlEnvelope *bool
+	graphql := &cobra.Command{
+		GroupID: "generic",
+		Use:     "graphql uri",
+		Short:   "Send a GraphQL query",
+		Long:    "Send a GraphQL query with variables to an endpoint. Only the returned data is printed, while any errors are printed to stderr and result in a non-zero exit code.",
+		Example: fmt.Sprintf(`  # Query from a file with variables
+  $ %s graphql my-api/graphql --query @query.graphql --var id=123 --var 'filter={"x":1}'
+
+  # Query from stdin
+  $ echo '{ viewer { login } }' | %s graphql api.github.com/graphql`, name, name),
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeGenericCmd(http.MethodPost, true),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := GraphQL(args[0], *gqlQuery, *gqlOperation, *gqlVars, *gqlEnvelope); err != nil {
+				panic(err)
+			}
+		},
+	}
+	// Note: `rsh-query` is already used for query params, so the GraphQL
+	// specific flags are not prefixed.
+	gqlQuery = graphql.Flags().String("query", "", "GraphQL query, or @filename to load it from a file")
+	gqlOperation = graphql.Flags().String("operation-name", "", "Name of the operation to run if the query contains several")
+	gqlVars = graphql.Flags().StringArray("var", []string{}, "Query variable as name=value, where JSON values are decoded")
+	gqlEnvelope = graphql.Flags().Bool("envelope", false, "Print the full response including errors instead of just the data")
+	Root.AddCommand(graphql)
+
 	GlobalFlags = pflag.NewFlagSet("eager-flags", pflag.ContinueOnError)
 	GlobalFlags.ParseErrorsWhitelist.UnknownFlags = true
 	// GlobalFlags are 'hidden', don't print anything on error
@@ -536,26 +649,98 @@ Not after (expires): %s (%s)
 	// (help seems to be special cased from ParseErrorsWhitelist.UnknownFlags)
 	GlobalFlags.BoolP("help", "h", false, "")
 
-	AddGlobalFlag("rsh-verbose", "v", "Enable verbose log output", false, false)
+	viper.SetDefault("rsh-verbose", 0)
+	addVerbosityFlag(Root.PersistentFlags())
+	addVerbosityFlag(GlobalFlags)
+	AddGlobalFlag("rsh-debug", "", "Only log debug output of these subsystems [auth, bulk, cache, http]", []string{}, true)
+	AddGlobalFlag("rsh-timings", "", "Log a timing breakdown of each request: DNS, connect, TLS, first byte, and transfer", false, false)
+	AddGlobalFlag("rsh-theme", "", "Color theme for highlighted output [dark, light, mono]", "dark", false)
+	AddGlobalFlag("rsh-log-format", "", "Log output format [text, json]", "text", false)
 	AddGlobalFlag("rsh-output-format", "o", "Output format [auto, json, table, ...]", "auto", false)
 	AddGlobalFlag("rsh-filter", "f", "Filter / project results using shorthand query", "", false)
+	AddGlobalFlag("rsh-filter-full", "", "Filter bulk files along with their metadata like URL & versions instead of just the body", false, false)
 	AddGlobalFlag("rsh-raw", "r", "Output result of query as raw rather than an escaped JSON string or list", false, false)
-	AddGlobalFlag("rsh-server", "s", "Override scheme://server:port for an API", "", false)
+	AddGlobalFlag("rsh-columns", "", "Comma-separated columns to show, in order, for table & CSV output", "", false)
+	AddGlobalFlag("rsh-max-items", "", "Only show this many items of each array in terminal output", 0, false)
+	AddGlobalFlag("rsh-max-depth", "", "Only show objects & arrays nested this many levels deep in terminal output", 0, false)
+	AddGlobalFlag("rsh-full", "", "Show the full response, ignoring --rsh-max-items and --rsh-max-depth", false, false)
+	AddGlobalFlag("rsh-image", "", "Preview image responses inline in the terminal", false, false)
+	AddGlobalFlag("rsh-template", "", "Go template to render the response body with instead of formatting it", "", false)
+	AddGlobalFlag("rsh-template-file", "", "File containing a Go template to render the response body with", "", false)
+	AddGlobalFlag("rsh-server", "s", "Server for an API by name from its description or as scheme://server:port", "", false)
+	AddGlobalFlag("rsh-server-var", "", "Set a variable of the selected server, e.g. region=eu", []string{}, true)
 	AddGlobalFlag("rsh-header", "H", "Add custom header", []string{}, true)
 	AddGlobalFlag("rsh-query", "q", "Add custom query param", []string{}, true)
+	AddGlobalFlag("rsh-accept", "", "Send this Accept header instead of the default list of supported types", "", false)
+	AddGlobalFlag("rsh-content-type", "", "Send request bodies as this media type, however they were provided", "", false)
+	AddGlobalFlag("rsh-user-agent", "", "Send this User-Agent header instead of the default", "", false)
+	AddGlobalFlag("rsh-edit-stdin", "", "Apply shorthand arguments as a patch to a JSON or YAML document from stdin", false, false)
 	AddGlobalFlag("rsh-no-paginate", "", "Disable auto-pagination", false, false)
+	AddGlobalFlag("rsh-follow", "", "Follow these comma-separated link relations, e.g. item,author, and show the final resource", "", false)
+	AddGlobalFlag("rsh-max-hops", "", "Maximum number of links to follow with --rsh-follow", 10, false)
+	AddGlobalFlag("rsh-paginate", "", "Follow next links for responses that wrap a list of items in an object and merge the items", false, false)
+	AddGlobalFlag("rsh-items", "", "Dotted path to the list of items in each page, auto-detected by default", "", false)
+	AddGlobalFlag("rsh-max-pages", "", "Stop auto-pagination after this many pages", 0, false)
+	AddGlobalFlag("rsh-max-results", "", "Stop auto-pagination once this many items are fetched", 0, false)
 	AddGlobalFlag("rsh-profile", "p", "API auth profile", "default", false)
 	AddGlobalFlag("rsh-no-cache", "", "Disable HTTP cache", false, false)
 	AddGlobalFlag("rsh-insecure", "", "Disable SSL verification", false, false)
+	AddGlobalFlag("rsh-insecure-disable-pinning", "", "Disable certificate pinning, which --rsh-insecure does not do", false, false)
 	AddGlobalFlag("rsh-client-cert", "", "Path to a PEM encoded client certificate", "", false)
 	AddGlobalFlag("rsh-client-key", "", "Path to a PEM encoded private key", "", false)
 	AddGlobalFlag("rsh-ca-cert", "", "Path to a PEM encoded CA cert", "", false)
 	AddGlobalFlag("rsh-ignore-status-code", "", "Do not set exit code from HTTP status code", false, false)
+	AddGlobalFlag("rsh-fail", "", "Set exit code from HTTP status code, even if ignored via config", false, false)
+	AddGlobalFlag("rsh-assert", "", "Fail unless this expression matches the response, e.g. 'status == 200'", []string{}, true)
+	AddGlobalFlag("rsh-output-file", "", "Write the raw response body to a file instead of formatting it", "", false)
+	AddGlobalFlag("rsh-continue-at", "", "Resume a download to --rsh-output-file at a byte offset, or - to use the file size", "", false)
+	AddGlobalFlag("rsh-accept-any", "", "Show HTML pages even when structured data like JSON was expected", false, false)
+	AddGlobalFlag("rsh-max-size", "", "Largest response body to load into memory, e.g. 500MB, or 0 for no limit", defaultMaxSize, false)
+	AddGlobalFlag("rsh-raw-body", "", "Write the exact response body bytes to stdout without parsing or formatting", false, false)
+	AddGlobalFlag("rsh-status-only", "", "Only print the numeric HTTP status code of the response", false, false)
+	AddGlobalFlag("rsh-sse", "", "Stream the response as server-sent events", false, false)
+	AddGlobalFlag("rsh-sse-retry", "", "Reconnect dropped server-sent event streams with Last-Event-ID", false, false)
+	AddGlobalFlag("rsh-repeat", "", "Send the request this many times and print latency & status stats instead of the response", 0, false)
+	AddGlobalFlag("rsh-parallel", "", "Number of requests to send at once with --rsh-repeat or --rsh-duration", 1, false)
+	AddGlobalFlag("rsh-duration", "", "Send the request repeatedly for this long and print latency & status stats", time.Duration(0), false)
 	AddGlobalFlag("rsh-retry", "", "Number of times to retry on certain failures", 2, false)
+	AddGlobalFlag("rsh-retry-unsafe", "", "Also retry non-idempotent requests like POST", false, false)
 	AddGlobalFlag("rsh-timeout", "t", "Timeout for HTTP requests", time.Duration(0), false)
+	AddGlobalFlag("rsh-curl", "", "Print the request as a curl command instead of sending it", false, false)
+	AddGlobalFlag("rsh-show-secrets", "", "Show auth headers and other secrets in verbose, log, and curl output", false, false)
+	AddGlobalFlag("rsh-secret-headers", "", "Additional header or query param names to treat as secrets and redact", []string{}, true)
+	AddGlobalFlag("rsh-record", "", "Record requests & responses to numbered files in a directory", "", false)
+	AddGlobalFlag("rsh-replay", "", "Replay responses from a recorded directory instead of the network", "", false)
+	AddGlobalFlag("rsh-history", "", "Record sent requests in the local history, see the history command", false, false)
+	AddGlobalFlag("rsh-history-max", "", "Maximum number of requests to keep in the history", 1000, false)
+	AddGlobalFlag("rsh-http-version", "", "HTTP version to use [1.1, 2, 3]", "", false)
+	AddGlobalFlag("rsh-http-strict", "", "Fail rather than fall back if the HTTP version is not supported", false, false)
+	AddGlobalFlag("rsh-no-cookies", "", "Disable the API cookie jar for this request", false, false)
+	AddGlobalFlag("rsh-no-validate", "", "Send request bodies even if they do not match the operation schema", false, false)
+	AddGlobalFlag("rsh-proxy", "", "Proxy URL (http, https, or socks5) to use for all requests", "", false)
+	AddGlobalFlag("rsh-debug-auth", "", "Log auth details like the AWS SigV4 canonical request in verbose output", false, false)
+	AddGlobalFlag("rsh-no-keychain", "", "Store auth secrets & tokens in plaintext files instead of the OS keychain", false, false)
+
+	Root.RegisterFlagCompletionFunc("rsh-debug", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return logSubsystems, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	Root.RegisterFlagCompletionFunc("rsh-theme", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return themeNames(), cobra.ShellCompDirectiveNoFileComp
+	})
 
 	Root.RegisterFlagCompletionFunc("rsh-output-format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return []string{"auto", "json", "yaml"}, cobra.ShellCompDirectiveNoFileComp
+		return []string{"auto", "json", "yaml", "toml"}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	Root.RegisterFlagCompletionFunc("rsh-server", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		names := []string{}
+		if currentAPI != nil {
+			for _, s := range currentAPI.Servers {
+				names = append(names, s.Name+"\t"+s.URL)
+			}
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
 	})
 
 	Root.RegisterFlagCompletionFunc("rsh-profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -569,6 +754,12 @@ Not after (expires): %s (%s)
 	})
 
 	initAPIConfig()
+	initCookieCommands()
+	initAuthCommands()
+	initBatchCommand()
+	initHistoryCommands()
+	initMockCommand()
+	initExampleCommand()
 }
 
 func userHomeDir() string {
@@ -694,6 +885,7 @@ func Defaults() {
 	AddEncoding("deflate", &DeflateEncoding{})
 	AddEncoding("gzip", &GzipEncoding{})
 	AddEncoding("br", &BrotliEncoding{})
+	AddEncoding("zstd", &ZstdEncoding{})
 
 	// Register content type marshallers
 	AddContentType("cbor", "application/cbor", 0.9, &CBOR{})
@@ -701,8 +893,10 @@ func Defaults() {
 	AddContentType("ion", "application/ion", 0.6, &Ion{})
 	AddContentType("json", "application/json", 0.5, &JSON{})
 	AddContentType("yaml", "application/yaml", 0.5, &YAML{})
+	AddContentType("toml", "application/toml", 0.4, &TOML{})
 	AddContentType("text", "text/*", 0.2, &Text{})
 	AddContentType("table", "", -1, &Table{})
+	AddContentType("csv", "", -1, &CSV{})
 	AddContentType("readable", "", -1, &Readable{})
 	AddContentType("gron", "", -1, &Gron{})
 
@@ -711,14 +905,24 @@ func Defaults() {
 	AddLinkParser(&HALParser{})
 	AddLinkParser(&TerrificallySimpleJSONParser{})
 	AddLinkParser(&JSONAPIParser{})
+	AddLinkParser(&NextFieldParser{})
 
 	// Register auth schemes
 	AddAuth("http-basic", &BasicAuth{})
 	AddAuth("external-tool", &ExternalToolAuth{})
+	AddAuth("external-token", &ExternalTokenAuth{})
+	AddAuth("rotating-api-key", &RotatingKeyAuth{})
 }
 
 // Run the CLI! Parse arguments, make requests, print responses.
-func Run() (returnErr error) {
+func Run() error {
+	return RunContext(context.Background())
+}
+
+// RunContext runs the CLI like `Run`, making the context available to
+// commands via `cmd.Context()` so that long running operations can be
+// cancelled.
+func RunContext(ctx context.Context) (returnErr error) {
 	// We need to register new commands at runtime based on the selected API
 	// so that we don't have to potentially refresh and parse every single
 	// registered API just to run. So this is a little hacky, but we hijack
@@ -731,6 +935,9 @@ func Run() (returnErr error) {
 		}
 	}
 
+	// Shell completion runs on every tab press, so it only uses local data.
+	viper.Set("completing", len(os.Args) > 1 && strings.HasPrefix(os.Args[1], cobra.ShellCompRequestCmd))
+
 	if os.Getenv("COLOR") != "" {
 		viper.Set("color", true)
 	}
@@ -738,6 +945,15 @@ func Run() (returnErr error) {
 		viper.Set("nocolor", true)
 	}
 
+	// Flags keep their values from any previous run in this process, which
+	// are still needed after it returns, e.g. by `GetExitCode`, so reset them
+	// before parsing new ones.
+	resetFlags(Root)
+	GlobalFlags.VisitAll(resetFlag)
+
+	// Commands like bulk set their own User-Agent only while they run.
+	UserAgent = ""
+
 	// Because we may be doing HTTP calls before cobra has parsed the flags
 	// we parse the GlobalFlags here and already set some config values
 	// to ensure they are available
@@ -746,15 +962,19 @@ func Run() (returnErr error) {
 			panic(err)
 		}
 	}
+	GlobalFlags.VisitAll(restoreSliceDefault)
 	if noCache, _ := GlobalFlags.GetBool("rsh-no-cache"); noCache {
 		viper.Set("rsh-no-cache", true)
 	}
-	if verbose, _ := GlobalFlags.GetBool("rsh-verbose"); verbose {
-		viper.Set("rsh-verbose", true)
+	if noKeychain, _ := GlobalFlags.GetBool("rsh-no-keychain"); noKeychain {
+		viper.Set("rsh-no-keychain", true)
 	}
 	if insecure, _ := GlobalFlags.GetBool("rsh-insecure"); insecure {
 		viper.Set("rsh-insecure", true)
 	}
+	if disablePinning, _ := GlobalFlags.GetBool("rsh-insecure-disable-pinning"); disablePinning {
+		viper.Set("rsh-insecure-disable-pinning", true)
+	}
 	if cert, _ := GlobalFlags.GetString("rsh-client-cert"); cert != "" {
 		viper.Set("rsh-client-cert", cert)
 	}
@@ -778,10 +998,18 @@ func Run() (returnErr error) {
 	if timeout, _ := GlobalFlags.GetDuration("rsh-timeout"); timeout > 0 {
 		viper.Set("rsh-timeout", timeout)
 	}
+	if GlobalFlags.Changed("rsh-theme") {
+		theme, _ := GlobalFlags.GetString("rsh-theme")
+		viper.Set("rsh-theme", theme)
+	}
+
+	// Resolve the theme once so all highlighted output uses the same colors.
+	initTheme()
 
 	// Now that global flags are parsed we can enable verbose mode if requested.
-	if viper.GetBool("rsh-verbose") {
-		enableVerbose = true
+	if err := setLogLevel(GlobalFlags); err != nil {
+		LogError("Error: %v", err)
+		return err
 	}
 
 	// Load the API commands if we can.
@@ -795,7 +1023,7 @@ func Run() (returnErr error) {
 		}
 
 		loaded := false
-		if apiName != "help" && apiName != "head" && apiName != "options" && apiName != "get" && apiName != "post" && apiName != "put" && apiName != "patch" && apiName != "delete" && apiName != "api" && apiName != "links" && apiName != "edit" && apiName != "auth-header" {
+		if apiName != "help" && apiName != "head" && apiName != "options" && apiName != "get" && apiName != "post" && apiName != "put" && apiName != "patch" && apiName != "delete" && apiName != "api" && apiName != "links" && apiName != "edit" && apiName != "diff" && apiName != "batch" && apiName != "history" && apiName != "mock" && apiName != "auth" && apiName != "auth-header" && apiName != "ws" && apiName != "graphql" && apiName != "cookies" {
 			// Try to find the registered config for this API. If not found,
 			// there is no need to do anything since the normal flow will catch
 			// the command being missing and print help.
@@ -842,7 +1070,9 @@ func Run() (returnErr error) {
 	}
 
 	// Phew, we made it. Execute the command now that everything is loaded
-	// and all the relevant sub-commands are registered.
+	// and all the relevant sub-commands are registered. Deprecation warnings
+	// are shown after the output, even if the command failed.
+	defer WarnDeprecations()
 	defer func() {
 		if err := recover(); err != nil {
 			LogError("Caught error: %v", err)
@@ -854,7 +1084,7 @@ func Run() (returnErr error) {
 			}
 		}
 	}()
-	if err := Root.Execute(); err != nil {
+	if err := Root.ExecuteContext(ctx); err != nil {
 		LogError("Error: %v", err)
 		returnErr = err
 	}
@@ -863,8 +1093,11 @@ func Run() (returnErr error) {
 }
 
 // GetExitCode returns the exit code to use based on the last HTTP status code.
+// The `rsh-fail` option takes precedence over `rsh-ignore-status-code` so that
+// scripts can opt back in when the latter is set in the config file.
 func GetExitCode() int {
-	if s := GetLastStatus() / 100; s > 2 && !viper.GetBool("rsh-ignore-status-code") {
+	fail := viper.GetBool("rsh-fail") || !viper.GetBool("rsh-ignore-status-code")
+	if s := GetLastStatus() / 100; s > 2 && fail {
 		return s
 	}
 
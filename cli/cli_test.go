@@ -28,6 +28,9 @@ func reset(color bool) {
 
 	Init("test", "1.0.0'")
 	Defaults()
+
+	// Never touch the real OS keychain from tests.
+	viper.Set("rsh-no-keychain", true)
 }
 
 func run(cmd string, color ...bool) string {
@@ -113,6 +116,38 @@ func TestIgnoreStatusCodeExit(t *testing.T) {
 	expectExitCode(t, 0)
 }
 
+func TestFailOverridesIgnoreStatusCode(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/foo").Reply(503).JSON(map[string]interface{}{
+		"detail": "Unavailable",
+	})
+
+	expectJSON(t, "http://example.com/foo --rsh-ignore-status-code --rsh-fail", `{
+		"detail": "Unavailable"
+	}`)
+	expectExitCode(t, 5)
+}
+
+func TestStatusOnly(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/foo").Reply(404).JSON(map[string]interface{}{
+		"detail": "Not found",
+	})
+
+	setLastStatus(0)
+	out := run("http://example.com/foo --rsh-status-only")
+	assert.Equal(t, "404\n", out)
+	expectExitCode(t, 0)
+
+	gock.New("http://example.com").Get("/foo").Reply(404)
+
+	out = run("http://example.com/foo --rsh-status-only --rsh-fail")
+	assert.Equal(t, "404\n", out)
+	expectExitCode(t, 4)
+}
+
 func TestHeaderWithComma(t *testing.T) {
 	defer gock.Off()
 
@@ -378,3 +413,48 @@ func TestCompletion(t *testing.T) {
 		"api.example.com/items/my-item/tags/{tag-id}\tGet tag details",
 	}, possible)
 }
+
+func TestOperationCompletion(t *testing.T) {
+	reset(false)
+
+	// Nothing is listening on this port, so any network request would fail.
+	configs["enum-test"] = &APIConfig{
+		name: "enum-test",
+		Base: "http://127.0.0.1:1",
+		Profiles: map[string]*APIProfile{
+			"default": {},
+		},
+	}
+	Root.AddCommand(&cobra.Command{
+		Use: "enum-test",
+	})
+
+	// Completion should use the cache even when it has expired.
+	cacheAPI("enum-test", &API{
+		Operations: []Operation{
+			{
+				Name:        "get-item",
+				Method:      http.MethodGet,
+				URITemplate: "http://127.0.0.1:1/items/{kind}",
+				PathParams: []*Param{
+					{Type: "string", Name: "kind", Enum: []any{"book", "movie"}},
+				},
+				QueryParams: []*Param{
+					{Type: "string", Name: "sort", Description: "Sort order", Enum: []any{"asc", "desc"}},
+				},
+			},
+		},
+	})
+	Cache.Set("enum-test.expires", time.Now().Add(-24*time.Hour))
+	Cache.WriteConfig()
+
+	out := runNoReset("__complete enum-test get-item ")
+	assert.Contains(t, out, "book\nmovie\n")
+
+	out = runNoReset("__complete enum-test get-item book --s")
+	assert.Contains(t, out, "--sort\tSort order\n")
+
+	out = runNoReset("__complete enum-test get-item book --sort d")
+	assert.Contains(t, out, "desc\n")
+	assert.NotContains(t, out, "asc\n")
+}
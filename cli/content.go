@@ -5,15 +5,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/alexeyco/simpletable"
 	"github.com/amzn/ion-go/ion"
 	"github.com/fxamacker/cbor/v2"
+	"github.com/pelletier/go-toml/v2"
 	"github.com/shamaton/msgpack/v2"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
 	"gopkg.in/yaml.v2"
 )
 
@@ -31,6 +36,13 @@ type PrettyMarshaller interface {
 	MarshalPretty(value any) ([]byte, error)
 }
 
+// StreamMarshaller describes an optional method that ContentTypes can
+// implement to write large outputs as they are generated rather than building
+// the entire output in memory first.
+type StreamMarshaller interface {
+	MarshalStream(w io.Writer, value any) error
+}
+
 type contentTypeEntry struct {
 	name string
 	q    float32
@@ -65,9 +77,11 @@ func buildAcceptHeader() string {
 }
 
 // Marshal a value to the given content type, e.g. `application/json`.
+// Output-only formats like gron are skipped, since they may detect the same
+// content type as the format they are based on.
 func Marshal(contentType string, value interface{}) ([]byte, error) {
 	for _, entry := range contentTypes {
-		if entry.ct.Detect(contentType) {
+		if entry.q >= 0 && entry.ct.Detect(contentType) {
 			return entry.ct.Marshal(value)
 		}
 	}
@@ -101,10 +115,11 @@ func MarshalShort(name string, pretty bool, value any) ([]byte, error) {
 	return encoded, nil
 }
 
-// Unmarshal raw data from the given content type into a value.
+// Unmarshal raw data from the given content type into a value. Output-only
+// formats are skipped like in `Marshal`.
 func Unmarshal(contentType string, data []byte, value interface{}) error {
 	for _, entry := range contentTypes {
-		if entry.ct.Detect(contentType) {
+		if entry.q >= 0 && entry.ct.Detect(contentType) {
 			LogDebug("Unmarshalling from %s", entry.name)
 			return entry.ct.Unmarshal(data, value)
 		}
@@ -168,6 +183,23 @@ func (t Text) Unmarshal(data []byte, value interface{}) error {
 	return nil
 }
 
+// minTableColumnWidth is the narrowest a column gets when fitting a table to
+// the terminal width.
+const minTableColumnWidth = 5
+
+// tableWidth returns the terminal width to fit tables into, or zero to not
+// limit the width, e.g. when output is redirected. It can be replaced in tests.
+var tableWidth = func() int {
+	if !viper.GetBool("tty") {
+		return 0
+	}
+	w, _, err := term.GetSize(0)
+	if err != nil {
+		return 0
+	}
+	return w
+}
+
 // Table describes an output format for terminal tables.
 type Table struct{}
 
@@ -183,7 +215,18 @@ func (t Table) Marshal(value interface{}) ([]byte, error) {
 		return nil, fmt.Errorf("error building table. Must be array of objects")
 	}
 
-	return setTable(d)
+	return setTable(d, outputColumns(), tableWidth())
+}
+
+// outputColumns returns the columns selected via `--rsh-columns`, if any.
+func outputColumns() []string {
+	var columns []string
+	for _, c := range strings.Split(viper.GetString("rsh-columns"), ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			columns = append(columns, c)
+		}
+	}
+	return columns
 }
 
 // Unmarshal the value from a table string.
@@ -194,72 +237,124 @@ func (t Table) Unmarshal(data []byte, value interface{}) error {
 // Only applicable to collection of repeating objects.
 // Filter down to a collection of objects first then apply the table output.
 // Simpletable has much more styling that can be applied.
-func setTable(data []interface{}) ([]byte, error) {
+// Columns default to the sorted union of all keys. If a width is given, the
+// widest columns are truncated until the table fits.
+func setTable(data []interface{}, columns []string, width int) ([]byte, error) {
 	table := simpletable.New()
 
-	var headerCells []*simpletable.Cell
-
-	// dry run and collect all unique headers
-	headers := make(map[string]struct{})
-	for _, maps := range data {
-		if mapData, ok := maps.(map[string]interface{}); ok {
-			for k := range mapData {
-				headers[k] = struct{}{}
+	if len(columns) == 0 {
+		// dry run and collect all unique headers
+		headers := make(map[string]struct{})
+		for _, maps := range data {
+			if mapData, ok := maps.(map[string]interface{}); ok {
+				for k := range mapData {
+					headers[k] = struct{}{}
+				}
 			}
 		}
+		for k := range headers {
+			columns = append(columns, k)
+		}
+		sort.Strings(columns)
 	}
-	// sort them and put it in headerCells
-	for k := range headers {
-		headerCells = append(headerCells, &simpletable.Cell{Align: simpletable.AlignCenter, Text: k})
-	}
-	sort.Slice(headerCells, func(i, j int) bool {
-		return headerCells[i].Text < headerCells[j].Text
-	})
 
+	rows := [][]string{}
 	for _, maps := range data {
-		var bodyCells []*simpletable.Cell
-		if mapData, ok := maps.(map[string]interface{}); ok {
-			// Add body cells based on order of header cells
-			// Will get out of order otherwise
-			for _, cellKey := range headerCells {
-				if val, ok := mapData[cellKey.Text]; ok {
-					if s, ok := val.([]any); ok && len(s) == 1 {
-						converted := make([]string, len(s))
-						for i := 0; i < len(s); i++ {
-							converted[i] = fmt.Sprintf("%v", s[i])
-						}
-						val = strings.Join(converted, ", ")
-					}
-					bodyCells = append(bodyCells, &simpletable.Cell{Align: simpletable.AlignRight, Text: prettyTableValue(val)})
-				} else {
-					// Use a placeholder instead of returning an error
-					bodyCells = append(bodyCells, &simpletable.Cell{Align: simpletable.AlignRight, Text: "N/A"})
-				}
-			}
-			table.Body.Cells = append(table.Body.Cells, bodyCells)
-		} else {
+		mapData, ok := maps.(map[string]interface{})
+		if !ok {
 			// Defensive just in case
 			return nil, errors.New("error building table. Collection not supported")
 		}
+
+		// Add body cells based on order of header cells
+		// Will get out of order otherwise
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			if val, ok := mapData[column]; ok {
+				row[i] = prettyTableValue(val)
+			} else {
+				// Use a placeholder instead of returning an error
+				row[i] = "N/A"
+			}
+		}
+		rows = append(rows, row)
 	}
 
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = utf8.RuneCountInString(column)
+		for _, row := range rows {
+			if w := utf8.RuneCountInString(row[i]); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	if width > 0 {
+		fitTableColumns(widths, width)
+	}
+
+	headerCells := make([]*simpletable.Cell, len(columns))
+	for i, column := range columns {
+		headerCells[i] = &simpletable.Cell{Align: simpletable.AlignCenter, Text: truncateCell(column, widths[i])}
+	}
 	table.Header = &simpletable.Header{
 		Cells: headerCells,
 	}
 
+	for _, row := range rows {
+		bodyCells := make([]*simpletable.Cell, len(row))
+		for i, text := range row {
+			bodyCells[i] = &simpletable.Cell{Align: simpletable.AlignRight, Text: truncateCell(text, widths[i])}
+		}
+		table.Body.Cells = append(table.Body.Cells, bodyCells)
+	}
+
 	table.SetStyle(simpletable.StyleUnicode)
 
 	ret := []byte(table.String())
 	return ret, nil
 }
 
+// fitTableColumns shrinks the widest columns until the table fits in the
+// given width or no column can get any narrower.
+func fitTableColumns(widths []int, width int) {
+	for {
+		// Each column has a border & padding on either side, plus the table's
+		// closing border.
+		total := 1
+		widest := 0
+		for i, w := range widths {
+			total += w + 3
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+
+		if total <= width || widths[widest] <= minTableColumnWidth {
+			return
+		}
+		widths[widest]--
+	}
+}
+
+// truncateCell shortens text to the given width, ending with an ellipsis to
+// show that it was cut off.
+func truncateCell(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// prettyTableValue renders a cell value. Nested objects & arrays are shown
+// as compact JSON.
 func prettyTableValue(val any) string {
-	v := reflect.ValueOf(val)
-	if v.Kind() == reflect.Slice {
-		if v.Len() > 0 {
-			return fmt.Sprintf("%s (+%d)", prettyTableValue(v.Index(0).Interface()), v.Len()-1)
+	switch val.(type) {
+	case map[string]any, []any:
+		if encoded, err := json.Marshal(val); err == nil {
+			return string(encoded)
 		}
-		return "N/A"
 	}
 	if datetime, ok := val.(time.Time); ok {
 		return datetime.Format("2006-01-02 15:04:05 MST")
@@ -417,6 +512,58 @@ func (m MsgPack) Unmarshal(data []byte, value interface{}) error {
 	return msgpack.Unmarshal(data, value)
 }
 
+// TOML describes content types like `application/toml` or
+// `application/foo+toml`. https://toml.io/
+type TOML struct{}
+
+// Detect if the content type is TOML.
+func (t TOML) Detect(contentType string) bool {
+	first := strings.Split(contentType, ";")[0]
+	if first == "application/toml" || first == "text/toml" || first == "text/x-toml" || strings.HasSuffix(first, "+toml") {
+		return true
+	}
+
+	return false
+}
+
+// Marshal the value to encoded TOML. Since TOML documents are tables, the
+// value must be an object. Null values are dropped as TOML has no null.
+func (t TOML) Marshal(value interface{}) ([]byte, error) {
+	value = dropNulls(makeJSONSafe(value))
+	if _, ok := value.(map[string]any); !ok {
+		return nil, fmt.Errorf("TOML requires an object at the top level but got %s", jsonType(value))
+	}
+	return toml.Marshal(value)
+}
+
+// Unmarshal the value from encoded TOML.
+func (t TOML) Unmarshal(data []byte, value interface{}) error {
+	return toml.Unmarshal(data, value)
+}
+
+// dropNulls recursively removes null object fields and list items.
+func dropNulls(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			if item != nil {
+				m[k] = dropNulls(item)
+			}
+		}
+		return m
+	case []any:
+		l := make([]any, 0, len(v))
+		for _, item := range v {
+			if item != nil {
+				l = append(l, dropNulls(item))
+			}
+		}
+		return l
+	}
+	return value
+}
+
 // Ion describes content types like `application/ion`.
 type Ion struct{}
 
@@ -1,8 +1,12 @@
 package cli
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,6 +23,7 @@ var contentTests = []struct {
 	{"cbor", []string{"application/cbor", "foo+cbor"}, &CBOR{}, []byte("\xf6"), nil},
 	{"msgpack", []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack", "foo+msgpack"}, &MsgPack{}, []byte("\x81\xa5\x68\x65\x6c\x6c\x6f\xa5\x77\x6f\x72\x6c\x64"), nil},
 	{"ion", []string{"application/ion", "foo+ion"}, &Ion{}, []byte("\xe0\x01\x00\xea\x0f"), []byte("null")},
+	{"toml", []string{"application/toml", "text/toml", "foo+toml"}, &TOML{}, []byte("hello = 'world'\n"), nil},
 }
 
 func TestContentTypes(parent *testing.T) {
@@ -51,3 +56,69 @@ func TestContentTypes(parent *testing.T) {
 		})
 	}
 }
+
+func TestTableColumns(t *testing.T) {
+	reset(false)
+	viper.Set("rsh-columns", "name, id,missing")
+	defer viper.Set("rsh-columns", "")
+
+	out, err := Table{}.Marshal([]any{
+		map[string]any{"id": 1, "name": "a", "tags": []any{"x"}},
+		map[string]any{"id": 2, "name": "b", "meta": map[string]any{"k": "v"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `╔══════╤════╤═════════╗
+║ name │ id │ missing ║
+╟━━━━━━┼━━━━┼━━━━━━━━━╢
+║    a │  1 │     N/A ║
+║    b │  2 │     N/A ║
+╚══════╧════╧═════════╝`, string(out))
+}
+
+func TestTableNestedAndWidth(t *testing.T) {
+	data := []any{
+		map[string]any{"id": 1, "meta": map[string]any{"k": "v"}, "tags": []any{"a", "b"}},
+		map[string]any{"id": 2, "description": "a very long description which will not fit"},
+	}
+
+	out, err := setTable(data, nil, 0)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `{"k":"v"}`)
+	assert.Contains(t, string(out), `["a","b"]`)
+	assert.Contains(t, string(out), "a very long description which will not fit")
+
+	out, err = setTable(data, nil, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, `╔═══════════════════╤════╤═══════════╤═══════════╗
+║    description    │ id │   meta    │   tags    ║
+╟━━━━━━━━━━━━━━━━━━━┼━━━━┼━━━━━━━━━━━┼━━━━━━━━━━━╢
+║               N/A │  1 │ {"k":"v"} │ ["a","b"] ║
+║ a very long desc… │  2 │       N/A │       N/A ║
+╚═══════════════════╧════╧═══════════╧═══════════╝`, string(out))
+}
+
+func TestTOMLRequestResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			assert.Equal(t, "application/toml", r.Header.Get("Content-Type"))
+			b, _ := io.ReadAll(r.Body)
+			assert.Equal(t, "[config]\nport = 8080\n", string(b))
+		}
+		w.Header().Set("Content-Type", "application/toml")
+		w.Write([]byte("title = 'x'\n\n[db]\nhosts = ['a', 'b']\n"))
+	}))
+	defer ts.Close()
+	defer viper.Set("rsh-filter", "")
+	defer viper.Set("rsh-output-format", "auto")
+
+	out := run("-f body.db.hosts " + ts.URL)
+	assert.JSONEq(t, `["a", "b"]`, out)
+
+	out = run("-o toml -f body " + ts.URL)
+	assert.Equal(t, "title = 'x'\n\n[db]\nhosts = ['a', 'b']\n", out)
+
+	out = run("-o toml -f body.db.hosts " + ts.URL)
+	assert.Contains(t, out, "TOML requires an object at the top level but got array")
+
+	run("post -H Content-Type:application/toml " + ts.URL + " config.port: 8080")
+}
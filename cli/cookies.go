@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/net/publicsuffix"
+)
+
+// storedCookie is a cookie as persisted in an API's cookie jar file. A nil
+// `Expires` means it is a session cookie.
+type storedCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Domain   string     `json:"domain"`
+	Path     string     `json:"path"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+	HTTPOnly bool       `json:"http_only,omitempty"`
+	HostOnly bool       `json:"host_only,omitempty"`
+}
+
+// expired returns true if the cookie should no longer be sent.
+func (c storedCookie) expired(now time.Time) bool {
+	return c.Expires != nil && !c.Expires.After(now)
+}
+
+// cookieJar is an `http.CookieJar` which follows the storage model from
+// RFC 6265 section 5.3 and persists cookies to a file. Each restish run is
+// not a browser session, so session cookies are kept until the jar is
+// cleared rather than being discarded on exit.
+type cookieJar struct {
+	mu       sync.Mutex
+	filename string
+	profile  string
+
+	// cookies maps profile names to their cookies.
+	cookies map[string][]storedCookie
+}
+
+// cookieJarFilename returns where the cookies for an API are stored.
+func cookieJarFilename(apiName string) string {
+	return filepath.Join(viper.GetString("config-directory"), "cookies", apiName+".json")
+}
+
+// loadCookieJar loads the cookie jar for an API and profile. A missing file
+// results in an empty jar.
+func loadCookieJar(apiName, profile string) (*cookieJar, error) {
+	jar := &cookieJar{
+		filename: cookieJarFilename(apiName),
+		profile:  profile,
+		cookies:  map[string][]storedCookie{},
+	}
+
+	data, err := os.ReadFile(jar.filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return jar, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &jar.cookies); err != nil {
+		return nil, fmt.Errorf("unable to read cookie jar %s: %w", jar.filename, err)
+	}
+
+	return jar, nil
+}
+
+// save writes the jar to disk, dropping any expired cookies. The caller must
+// hold the lock.
+func (j *cookieJar) save() error {
+	now := time.Now()
+	for profile, cookies := range j.cookies {
+		kept := []storedCookie{}
+		for _, c := range cookies {
+			if !c.expired(now) {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) == 0 {
+			delete(j.cookies, profile)
+		} else {
+			j.cookies[profile] = kept
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.filename), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(j.cookies, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Cookies can contain session credentials, so only the user can read them.
+	if err := os.WriteFile(j.filename, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(j.filename, 0600)
+}
+
+// Clear removes all cookies for the jar's profile.
+func (j *cookieJar) Clear() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	delete(j.cookies, j.profile)
+	return j.save()
+}
+
+// List returns the unexpired cookies for the jar's profile.
+func (j *cookieJar) List() []storedCookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	list := []storedCookie{}
+	for _, c := range j.cookies[j.profile] {
+		if !c.expired(now) {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// domainMatch implements RFC 6265 section 5.1.3.
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil
+}
+
+// pathMatch implements RFC 6265 section 5.1.4.
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if strings.HasPrefix(requestPath, cookiePath) {
+		return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+	}
+	return false
+}
+
+// defaultPath implements RFC 6265 section 5.1.4.
+func defaultPath(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" || p[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(p, "/")
+	if i == 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+// SetCookies stores cookies received from `u` and saves the jar.
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	stored := j.cookies[j.profile]
+
+	for _, c := range cookies {
+		sc := storedCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HttpOnly,
+		}
+
+		// The max-age attribute has precedence over expires.
+		switch {
+		case c.MaxAge < 0:
+			sc.Expires = &now
+		case c.MaxAge > 0:
+			expires := now.Add(time.Duration(c.MaxAge) * time.Second)
+			sc.Expires = &expires
+		case !c.Expires.IsZero():
+			expires := c.Expires
+			sc.Expires = &expires
+		}
+
+		domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		if domain != "" {
+			if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain {
+				if domain != host {
+					// Ignore cookies which try to set a public suffix like `com`.
+					continue
+				}
+				domain = ""
+			}
+		}
+		if domain == "" || domain == host && net.ParseIP(host) != nil {
+			sc.Domain = host
+			sc.HostOnly = true
+		} else if domainMatch(host, domain) {
+			sc.Domain = domain
+		} else {
+			HTTPLog.Log(LevelRequest, "Ignoring cookie %s for domain %s from %s", c.Name, domain, host)
+			continue
+		}
+
+		if sc.Path == "" || sc.Path[0] != '/' {
+			sc.Path = defaultPath(u)
+		}
+
+		// Replace any existing cookie with the same name, domain, and path. An
+		// already expired cookie just removes the old one.
+		updated := []storedCookie{}
+		for _, existing := range stored {
+			if existing.Name != sc.Name || existing.Domain != sc.Domain || existing.Path != sc.Path {
+				updated = append(updated, existing)
+			}
+		}
+		if !sc.expired(now) {
+			updated = append(updated, sc)
+		}
+		stored = updated
+	}
+
+	j.cookies[j.profile] = stored
+	if err := j.save(); err != nil {
+		LogWarning("Unable to save cookies: %v", err)
+	}
+}
+
+// Cookies returns the cookies to send in a request to `u`, with longer paths
+// first as described in RFC 6265 section 5.4.
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	secure := u.Scheme == "https"
+	now := time.Now()
+
+	matched := []storedCookie{}
+	for _, c := range j.cookies[j.profile] {
+		if c.expired(now) || (c.Secure && !secure) || !pathMatch(path, c.Path) {
+			continue
+		}
+		if c.HostOnly && host != c.Domain || !c.HostOnly && !domainMatch(host, c.Domain) {
+			continue
+		}
+		matched = append(matched, c)
+	}
+
+	sort.SliceStable(matched, func(a, b int) bool {
+		return len(matched[a].Path) > len(matched[b].Path)
+	})
+
+	cookies := make([]*http.Cookie, 0, len(matched))
+	for _, c := range matched {
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return cookies
+}
+
+// initCookieCommands registers the cookie jar management commands.
+func initCookieCommands() {
+	cookies := &cobra.Command{
+		GroupID: "generic",
+		Use:     "cookies",
+		Short:   "Cookie jar management commands",
+		Long:    "Manage the stored cookies for APIs which have the cookie jar enabled via the `cookies` API config option.",
+	}
+	Root.AddCommand(cookies)
+
+	cookies.AddCommand(&cobra.Command{
+		Use:   "list short-name",
+		Short: "List stored cookies",
+		Long:  "List the stored cookies for an API and the current profile.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if configs[args[0]] == nil {
+				panic("API " + args[0] + " not found")
+			}
+
+			jar, err := loadCookieJar(args[0], viper.GetString("rsh-profile"))
+			if err != nil {
+				panic(err)
+			}
+
+			encoded, err := json.MarshalIndent(jar.List(), "", "  ")
+			if err != nil {
+				panic(err)
+			}
+
+			if useColor {
+				encoded, err = Highlight("json", encoded)
+				if err != nil {
+					panic(err)
+				}
+			}
+
+			fmt.Fprintln(Stdout, string(encoded))
+		},
+	})
+
+	cookies.AddCommand(&cobra.Command{
+		Use:   "clear short-name",
+		Short: "Clear stored cookies",
+		Long:  "Remove all stored cookies, including session cookies, for an API and the current profile.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if configs[args[0]] == nil {
+				panic("API " + args[0] + " not found")
+			}
+
+			jar, err := loadCookieJar(args[0], viper.GetString("rsh-profile"))
+			if err != nil {
+				panic(err)
+			}
+
+			if err := jar.Clear(); err != nil {
+				panic(err)
+			}
+		},
+	})
+}
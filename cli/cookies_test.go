@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cookieNames(cookies []*http.Cookie) []string {
+	names := []string{}
+	for _, c := range cookies {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestCookieJarRules(t *testing.T) {
+	viper.Set("config-directory", t.TempDir())
+	jar, err := loadCookieJar("test", "default")
+	require.NoError(t, err)
+
+	u, _ := url.Parse("https://api.example.com/v1/login")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "secure", Value: "3", Path: "/", Secure: true},
+		{Name: "expired", Value: "4", Expires: time.Now().Add(-time.Hour)},
+		{Name: "suffix", Value: "5", Domain: "com"},
+		{Name: "other", Value: "6", Domain: "other.com"},
+	})
+
+	// Host-only cookies default to the directory of the request path.
+	u, _ = url.Parse("https://api.example.com/v1/items")
+	assert.Equal(t, []string{"host", "domain", "secure"}, cookieNames(jar.Cookies(u)))
+
+	u, _ = url.Parse("http://www.example.com/")
+	assert.Equal(t, []string{"domain"}, cookieNames(jar.Cookies(u)))
+
+	// Max-age < 0 deletes the cookie.
+	u, _ = url.Parse("https://api.example.com/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "domain", Domain: "example.com", Path: "/", MaxAge: -1}})
+	assert.Equal(t, []string{"secure"}, cookieNames(jar.Cookies(u)))
+
+	// Changes are persisted, and profiles are kept separate.
+	loaded, err := loadCookieJar("test", "default")
+	require.NoError(t, err)
+	assert.Len(t, loaded.List(), 2)
+
+	other, err := loadCookieJar("test", "other")
+	require.NoError(t, err)
+	assert.Empty(t, other.List())
+}
+
+func TestCookieJarRequests(t *testing.T) {
+	t.Setenv("TEST_CONFIG_DIR", t.TempDir())
+
+	sent := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", HttpOnly: true})
+		}
+		if c, err := r.Cookie("session"); err == nil {
+			sent = append(sent, c.Value)
+		} else {
+			sent = append(sent, "")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	reset(false)
+	configs["cookie-test"] = &APIConfig{
+		name:    "cookie-test",
+		Base:    ts.URL,
+		Cookies: true,
+		Profiles: map[string]*APIProfile{
+			"default": {},
+		},
+	}
+
+	runNoReset("post " + ts.URL + "/login --rsh-no-cache")
+	runNoReset("get " + ts.URL + "/items --rsh-no-cache")
+	runNoReset("get " + ts.URL + "/items --rsh-no-cache --rsh-no-cookies")
+	viper.Set("rsh-no-cookies", false)
+	assert.Equal(t, []string{"", "abc123", ""}, sent)
+
+	info, err := os.Stat(cookieJarFilename("cookie-test"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	out := runNoReset("cookies list cookie-test")
+	assert.Contains(t, out, `"abc123"`)
+
+	runNoReset("cookies clear cookie-test")
+	out = runNoReset("cookies list cookie-test")
+	assert.Equal(t, "[]\n", out)
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// errCSVArray is returned when the data to write as CSV is not an array of
+// objects, which is usually fixed by filtering down to one.
+var errCSVArray = errors.New("CSV output requires an array of objects, use a filter like `-f body.items` to select one")
+
+// CSV describes an output format for spreadsheets and other tools. Objects
+// become rows following RFC 4180, with nested objects flattened into dotted
+// column names like `owner.name`.
+type CSV struct{}
+
+// Detect if the content type is CSV. Only used for output.
+func (c CSV) Detect(contentType string) bool {
+	return false
+}
+
+// Marshal the value to a CSV string.
+func (c CSV) Marshal(value interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := c.MarshalStream(buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalStream writes the value as CSV, one row at a time.
+func (c CSV) MarshalStream(w io.Writer, value any) error {
+	rows, ok := makeJSONSafe(value).([]any)
+	if !ok {
+		return errCSVArray
+	}
+
+	// Columns default to the sorted union of all flattened keys, which needs a
+	// pass over every row before writing the header.
+	columns := outputColumns()
+	if len(columns) == 0 {
+		seen := map[string]bool{}
+		for _, row := range rows {
+			obj, ok := row.(map[string]any)
+			if !ok {
+				return errCSVArray
+			}
+			for k, v := range obj {
+				if nested, ok := v.(map[string]any); ok && len(nested) > 0 {
+					for nk := range nested {
+						seen[k+"."+nk] = true
+					}
+					continue
+				}
+				seen[k] = true
+			}
+		}
+		for k := range seen {
+			columns = append(columns, k)
+		}
+		sort.Strings(columns)
+	}
+
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = true
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		obj, ok := row.(map[string]any)
+		if !ok {
+			return errCSVArray
+		}
+
+		cells := map[string]string{}
+		for k, v := range obj {
+			if nested, ok := v.(map[string]any); ok && len(nested) > 0 {
+				for nk, nv := range nested {
+					cells[k+"."+nk] = csvValue(nv)
+				}
+				continue
+			}
+			cells[k] = csvValue(v)
+		}
+
+		for i, column := range columns {
+			record[i] = cells[column]
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Unmarshal the value from a CSV string.
+func (c CSV) Unmarshal(data []byte, value interface{}) error {
+	return errors.New("unimplemented")
+}
+
+// csvValue renders a cell. Strings are written as-is, missing values are
+// empty, and everything else including deeper structures is encoded as JSON.
+func csvValue(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	case []byte:
+		return string(t)
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
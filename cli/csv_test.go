@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSV(t *testing.T) {
+	out, err := CSV{}.Marshal([]any{
+		map[string]any{
+			"id":    1,
+			"name":  `quote "me"`,
+			"notes": "line one\nline two",
+			"owner": map[string]any{"name": "a", "roles": []any{"admin"}, "address": map[string]any{"city": "x"}},
+			"tags":  []any{"a", "b"},
+		},
+		map[string]any{
+			"id":     2.5,
+			"active": true,
+			"none":   nil,
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "active,id,name,none,notes,owner.address,owner.name,owner.roles,tags\r\n"+
+		`,1,"quote ""me""",,"line one`+"\r\n"+`line two","{""city"":""x""}",a,"[""admin""]","[""a"",""b""]"`+"\r\n"+
+		"true,2.5,,,,,,,\r\n", string(out))
+}
+
+func TestCSVColumns(t *testing.T) {
+	viper.Set("rsh-columns", "owner.name,id")
+	defer viper.Set("rsh-columns", "")
+
+	out, err := CSV{}.Marshal([]any{
+		map[string]any{"id": 1, "owner": map[string]any{"name": "a"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "owner.name,id\r\na,1\r\n", string(out))
+}
+
+func TestCSVNotArray(t *testing.T) {
+	_, err := CSV{}.Marshal(map[string]any{"id": 1})
+	assert.ErrorIs(t, err, errCSVArray)
+
+	_, err = CSV{}.Marshal([]any{1, 2})
+	assert.ErrorIs(t, err, errCSVArray)
+}
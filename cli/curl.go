@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// shellQuote quotes a string for safe use in a POSIX shell. Single quotes
+// are used so that nothing within the string is expanded, which also makes
+// multi-line values safe to paste.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// CurlCommand returns an equivalent curl command for the given request. The
+// request body, if any, is read and then reset so the request can still be
+// sent afterward. If `redactSecrets` is true then auth headers and other
+// likely secrets are replaced with a hashed placeholder.
+func CurlCommand(req *http.Request, redactSecrets bool) (string, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	u := req.URL.String()
+	if redactSecrets {
+		u = redactURL(req)
+	}
+
+	// Each option gets its own line to make the output easy to read & edit.
+	first := "curl"
+	if req.Method == http.MethodHead {
+		first += " --head"
+	} else if req.Method != http.MethodGet || len(body) > 0 {
+		first += " -X " + req.Method
+	}
+	parts := []string{first + " " + shellQuote(u)}
+
+	names := []string{}
+	for k := range req.Header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if strings.EqualFold(name, "accept-encoding") {
+			// Curl won't transparently decode the response unless it knows about
+			// the encoding, so let it negotiate instead.
+			parts = append(parts, "--compressed")
+			continue
+		}
+
+		for _, v := range req.Header[name] {
+			if redactSecrets && isSecret(name) {
+				v = redact(v)
+			}
+			parts = append(parts, "-H "+shellQuote(name+": "+v))
+		}
+	}
+
+	if len(body) > 0 {
+		parts = append(parts, "--data-binary "+shellQuote(string(body)))
+	}
+
+	return strings.Join(parts, " \\\n  "), nil
+}
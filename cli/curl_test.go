@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurlCommand(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/items?access_token=abc&page=2", strings.NewReader("{\"name\": \"it's\",\n\"id\": 1}"))
+	req.Header.Set("Authorization", "Bearer abc123")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip, br")
+
+	cmd, err := CurlCommand(req, false)
+	assert.NoError(t, err)
+	assert.Equal(t, `curl -X POST 'https://example.com/items?access_token=abc&page=2' \
+  --compressed \
+  -H 'Authorization: Bearer abc123' \
+  -H 'Content-Type: application/json' \
+  --data-binary '{"name": "it'"'"'s",
+"id": 1}'`, cmd)
+
+	// The body should still be readable after generating the command.
+	cmd, err = CurlCommand(req, true)
+	assert.NoError(t, err)
+	assert.Contains(t, cmd, "'https://example.com/items?access_token="+redact("abc")+"&page=2'")
+	assert.Contains(t, cmd, "-H 'Authorization: "+redact("Bearer abc123")+"'")
+	assert.NotContains(t, cmd, "abc123")
+	assert.Contains(t, cmd, "--data-binary")
+}
+
+func TestCurlCommandGet(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	cmd, err := CurlCommand(req, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "curl 'https://example.com/'", cmd)
+
+	req, _ = http.NewRequest(http.MethodHead, "https://example.com/", nil)
+	cmd, err = CurlCommand(req, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "curl --head 'https://example.com/'", cmd)
+}
+
+func TestCurlFlag(t *testing.T) {
+	// No HTTP mocks are set up, so this would fail if the request were sent.
+	out := run("post http://example.com/foo -H X-API-Key:secret --rsh-curl value: 123")
+	assert.Contains(t, out, "curl -X POST 'http://example.com/foo'")
+	assert.Contains(t, out, "-H 'X-Api-Key: <redacted:2bb80d53>'")
+	assert.Contains(t, out, `--data-binary '{"value":123}'`)
+}
+
+func TestCurlShowSecrets(t *testing.T) {
+	defer viper.Set("rsh-show-secrets", false)
+	defer viper.Set("rsh-secret-headers", []string{})
+
+	out := run("get http://example.com/foo -H X-Session:abc -H X-Other:def --rsh-curl --rsh-secret-headers x-session")
+	assert.Contains(t, out, "-H 'X-Session: "+redact("abc")+"'")
+	assert.Contains(t, out, "-H 'X-Other: def'")
+
+	out = run("get http://example.com/foo -H X-API-Key:secret --rsh-curl --rsh-show-secrets")
+	assert.Contains(t, out, "-H 'X-Api-Key: secret'")
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// ParamDefaults holds per-API default values for operation parameters by
+// name, which are used whenever a parameter is not passed explicitly. Values
+// may reference environment variables like `${TENANT}`.
+type ParamDefaults struct {
+	Query map[string]string `json:"query,omitempty" yaml:"query,omitempty"`
+	Path  map[string]string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// query returns the default value of a query param, if any.
+func (d *ParamDefaults) query(name string) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	v, ok := d.Query[name]
+	return os.ExpandEnv(v), ok
+}
+
+// path returns the default value of a path param, if any.
+func (d *ParamDefaults) path(name string) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	v, ok := d.Path[name]
+	return os.ExpandEnv(v), ok
+}
+
+// defaultUsage returns the flag description for a parameter which defaults
+// to a value from the API config.
+func defaultUsage(p *Param, value string) string {
+	usage := fmt.Sprintf("(default %q from API config)", value)
+	if p.Description != "" {
+		usage = p.Description + " " + usage
+	}
+	return usage
+}
+
+// applyQueryDefaults adds the API's default query params which are not
+// already set and returns their names.
+func applyQueryDefaults(config *APIConfig, query url.Values) []string {
+	if config.Defaults == nil {
+		return nil
+	}
+
+	added := []string{}
+	for k := range config.Defaults.Query {
+		if query.Has(k) {
+			continue
+		}
+		value, _ := config.Defaults.query(k)
+		HTTPLog.Log(LevelRequest, "Query param %s=%s (from API defaults)", k, visibleValue(k, value))
+		query.Add(k, value)
+		added = append(added, k)
+	}
+	return added
+}
+
+// PathDefault returns the default value of a path param from the config of
+// the API matching the given URL, if any. This is useful to fill in URL
+// templates outside of operations.
+func PathDefault(uri, name string) (string, bool) {
+	_, config := findAPI(uri)
+	if config == nil {
+		return "", false
+	}
+	return config.Defaults.path(name)
+}
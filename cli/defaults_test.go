@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParamDefaultsLookup(t *testing.T) {
+	t.Setenv("TENANT", "acme")
+
+	var missing *ParamDefaults
+	_, ok := missing.query("version")
+	assert.False(t, ok)
+	_, ok = missing.path("org")
+	assert.False(t, ok)
+
+	d := &ParamDefaults{
+		Query: map[string]string{"version": "2", "tenant": "${TENANT}"},
+		Path:  map[string]string{"org": "${TENANT}-org", "empty": ""},
+	}
+
+	v, ok := d.query("version")
+	assert.True(t, ok)
+	assert.Equal(t, "2", v)
+
+	v, ok = d.query("tenant")
+	assert.True(t, ok)
+	assert.Equal(t, "acme", v)
+
+	v, ok = d.path("org")
+	assert.True(t, ok)
+	assert.Equal(t, "acme-org", v)
+
+	// An empty default is still a default.
+	v, ok = d.path("empty")
+	assert.True(t, ok)
+	assert.Equal(t, "", v)
+
+	_, ok = d.query("org")
+	assert.False(t, ok)
+}
+
+func TestDefaultUsage(t *testing.T) {
+	assert.Equal(t, `(default "2" from API config)`, defaultUsage(&Param{Name: "version"}, "2"))
+	assert.Equal(t, `Org name (default "acme" from API config)`, defaultUsage(&Param{Name: "org", Description: "Org name"}, "acme"))
+}
+
+func TestApplyQueryDefaults(t *testing.T) {
+	assert.Empty(t, applyQueryDefaults(&APIConfig{}, url.Values{}))
+
+	config := &APIConfig{Defaults: &ParamDefaults{
+		Query: map[string]string{"version": "2", "page": "1", "empty": ""},
+	}}
+
+	// Params which are already set, even to an empty value, are kept.
+	query := url.Values{"page": {"5"}, "empty": {""}}
+	added := applyQueryDefaults(config, query)
+	assert.Equal(t, []string{"version"}, added)
+	assert.Equal(t, url.Values{"version": {"2"}, "page": {"5"}, "empty": {""}}, query)
+}
+
+func TestDefaultsPrecedence(t *testing.T) {
+	reset(false)
+	defer viper.Set("rsh-profile", "default")
+	defer viper.Set("rsh-query", []string{})
+
+	configs["defaults-precedence"] = &APIConfig{
+		name: "defaults-precedence",
+		Base: "https://defaults-precedence.example.com",
+		Defaults: &ParamDefaults{
+			Query: map[string]string{"version": "1", "region": "us", "page": "1"},
+		},
+		Profiles: map[string]*APIProfile{
+			"default": {},
+			"stage": {
+				Query: map[string]string{"version": "2", "region": "eu"},
+			},
+		},
+	}
+	defer delete(configs, "defaults-precedence")
+
+	for _, tc := range []struct {
+		name     string
+		profile  string
+		url      string
+		flags    []string
+		expected string
+	}{
+		{"default", "default", "/items", nil, "page=1&region=us&version=1"},
+		{"profile over default", "stage", "/items", nil, "page=1&region=eu&version=2"},
+		{"flag over profile", "stage", "/items", []string{"version=3"}, "page=1&region=eu&version=3"},
+		{"flag over default", "default", "/items", []string{"page=4"}, "page=4&region=us&version=1"},
+		{"url over profile and default", "stage", "/items?version=5&page=6", nil, "page=6&region=eu&version=5"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Set("rsh-profile", tc.profile)
+			viper.Set("rsh-query", tc.flags)
+
+			req, _ := http.NewRequest(http.MethodGet, "https://defaults-precedence.example.com"+tc.url, nil)
+			PrepareRequest(req)
+
+			// Repeated params would mean a lower priority value was also sent.
+			for k, v := range req.URL.Query() {
+				assert.Len(t, v, 1, k)
+			}
+			assert.Equal(t, tc.expected, req.URL.RawQuery)
+		})
+	}
+}
+
+func TestDefaultsLogged(t *testing.T) {
+	reset(false)
+
+	configs["defaults-logged"] = &APIConfig{
+		name: "defaults-logged",
+		Base: "https://defaults-logged.example.com",
+		Defaults: &ParamDefaults{
+			Query: map[string]string{"version": "2", "api_key": "secret"},
+		},
+	}
+	defer delete(configs, "defaults-logged")
+
+	capture := &strings.Builder{}
+	Stderr = capture
+	verbosity = LevelRequest
+	defer func() { verbosity = 0 }()
+
+	req, _ := http.NewRequest(http.MethodGet, "https://defaults-logged.example.com/items", nil)
+	PrepareRequest(req)
+
+	lines := []string{}
+	for _, line := range strings.Split(capture.String(), "\n") {
+		if strings.Contains(line, "from API defaults") {
+			lines = append(lines, strings.TrimPrefix(line, "DEBUG: "))
+		}
+	}
+	sort.Strings(lines)
+	assert.Equal(t, []string{
+		"Query param api_key=" + redact("secret") + " (from API defaults)",
+		"Query param version=2 (from API defaults)",
+	}, lines)
+}
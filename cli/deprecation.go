@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Deprecation describes a resource which the server marked as deprecated via
+// the `Deprecation` header (RFC 9745) or scheduled for removal via the
+// `Sunset` header (RFC 8594).
+type Deprecation struct {
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	// Since is the date the resource was deprecated, if known.
+	Since string `json:"since,omitempty"`
+
+	// Sunset is the date the resource is expected to become unavailable.
+	Sunset string `json:"sunset,omitempty"`
+}
+
+// parseHeaderDate parses an HTTP date or a structured field date like
+// `@1688169599` into `YYYY-MM-DD`, returning false if it isn't a date.
+func parseHeaderDate(v string) (string, bool) {
+	if strings.HasPrefix(v, "@") {
+		secs, err := strconv.ParseInt(v[1:], 10, 64)
+		if err != nil {
+			return "", false
+		}
+		return time.Unix(secs, 0).UTC().Format("2006-01-02"), true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return "", false
+	}
+	return t.UTC().Format("2006-01-02"), true
+}
+
+// ParseDeprecation returns the deprecation info from response headers, or nil
+// if the resource is neither deprecated nor has a sunset date. Both the
+// structured date and older `true` forms of the `Deprecation` header are
+// supported.
+func ParseDeprecation(header http.Header) *Deprecation {
+	d := &Deprecation{}
+
+	if v := strings.TrimSpace(header.Get("Deprecation")); v != "" {
+		if date, ok := parseHeaderDate(v); ok {
+			d.Deprecated = true
+			d.Since = date
+		} else {
+			d.Deprecated = strings.EqualFold(v, "true") || strings.EqualFold(v, "?1")
+		}
+	}
+
+	if v := strings.TrimSpace(header.Get("Sunset")); v != "" {
+		if date, ok := parseHeaderDate(v); ok {
+			d.Sunset = date
+		} else {
+			d.Sunset = v
+		}
+	}
+
+	if !d.Deprecated && d.Sunset == "" {
+		return nil
+	}
+	return d
+}
+
+// deprecations collects the deprecated resources seen during a run by URL so
+// that each is only warned about once.
+var deprecations = struct {
+	sync.Mutex
+	urls map[string]*Deprecation
+}{urls: map[string]*Deprecation{}}
+
+// NoteDeprecation records a deprecated resource to warn about at the end of
+// the run. A nil deprecation is ignored. Query params are ignored so that
+// e.g. default params from the profile don't count as another resource.
+func NoteDeprecation(u string, d *Deprecation) {
+	if d == nil {
+		return
+	}
+	u, _, _ = strings.Cut(u, "?")
+	deprecations.Lock()
+	defer deprecations.Unlock()
+	deprecations.urls[u] = d
+}
+
+// deprecationGroup is a set of deprecated resources sharing a URL prefix.
+type deprecationGroup struct {
+	urls       []string
+	deprecated bool
+	sunset     string
+}
+
+// WarnDeprecations logs one warning per URL prefix for the deprecated
+// resources seen so far, e.g. `3 resources under https://api.example.com/items/
+// are marked deprecated; sunset 2025-03-01`, then forgets them.
+func WarnDeprecations() {
+	deprecations.Lock()
+	defer deprecations.Unlock()
+
+	groups := map[string]*deprecationGroup{}
+	for u, d := range deprecations.urls {
+		prefix := u
+		if parsed, err := url.Parse(u); err == nil {
+			parsed.Fragment = ""
+			parsed.Path = strings.TrimSuffix(path.Dir(parsed.Path), "/") + "/"
+			prefix = parsed.String()
+		}
+		g := groups[prefix]
+		if g == nil {
+			g = &deprecationGroup{}
+			groups[prefix] = g
+		}
+		g.urls = append(g.urls, u)
+		g.deprecated = g.deprecated || d.Deprecated
+		if d.Sunset != "" && (g.sunset == "" || d.Sunset < g.sunset) {
+			// Warn about the earliest sunset of the group.
+			g.sunset = d.Sunset
+		}
+	}
+	deprecations.urls = map[string]*Deprecation{}
+
+	prefixes := make([]string, 0, len(groups))
+	for prefix := range groups {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	for _, prefix := range prefixes {
+		g := groups[prefix]
+		subject := g.urls[0] + " is"
+		if len(g.urls) > 1 {
+			subject = fmt.Sprintf("%d resources under %s are", len(g.urls), prefix)
+		}
+		switch {
+		case g.deprecated && g.sunset != "":
+			LogWarning("%s marked deprecated; sunset %s", subject, g.sunset)
+		case g.deprecated:
+			LogWarning("%s marked deprecated", subject)
+		default:
+			LogWarning("%s scheduled for sunset on %s", subject, g.sunset)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestParseDeprecation(t *testing.T) {
+	assert.Nil(t, ParseDeprecation(http.Header{}))
+	assert.Nil(t, ParseDeprecation(http.Header{"Deprecation": {"false"}}))
+
+	assert.Equal(t, &Deprecation{Deprecated: true}, ParseDeprecation(http.Header{"Deprecation": {"true"}}))
+	assert.Equal(t, &Deprecation{Deprecated: true, Since: "2023-06-30"}, ParseDeprecation(http.Header{"Deprecation": {"@1688169599"}}))
+	assert.Equal(t, &Deprecation{Deprecated: true, Sunset: "2025-03-01"}, ParseDeprecation(http.Header{
+		"Deprecation": {"true"},
+		"Sunset":      {"Sat, 01 Mar 2025 00:00:00 GMT"},
+	}))
+	assert.Equal(t, &Deprecation{Sunset: "2025-03-01"}, ParseDeprecation(http.Header{"Sunset": {"Sat, 01 Mar 2025 00:00:00 GMT"}}))
+}
+
+func TestWarnDeprecations(t *testing.T) {
+	capture := &strings.Builder{}
+	Stderr = capture
+
+	NoteDeprecation("https://example.com/items/1", &Deprecation{Deprecated: true, Sunset: "2025-06-01"})
+	NoteDeprecation("https://example.com/items/2?page=1", &Deprecation{Deprecated: true, Sunset: "2025-03-01"})
+	NoteDeprecation("https://example.com/items/2", &Deprecation{Deprecated: true, Sunset: "2025-03-01"})
+	NoteDeprecation("https://example.com/items/3", &Deprecation{Deprecated: true})
+	NoteDeprecation("https://example.com/users/1", &Deprecation{Sunset: "2025-01-01"})
+	NoteDeprecation("https://example.com/users/2", nil)
+	WarnDeprecations()
+
+	assert.Equal(t, "WARN: 3 resources under https://example.com/items/ are marked deprecated; sunset 2025-03-01\nWARN: https://example.com/users/1 is scheduled for sunset on 2025-01-01\n", capture.String())
+
+	// Warnings are only shown once.
+	capture.Reset()
+	WarnDeprecations()
+	assert.Empty(t, capture.String())
+}
+
+func TestDeprecatedRequest(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").
+		Get("/foo").
+		Reply(http.StatusOK).
+		SetHeader("Deprecation", "true").
+		SetHeader("Sunset", "Sat, 01 Mar 2025 00:00:00 GMT").
+		JSON(map[string]any{"hello": "world"})
+
+	captured := run("http://example.com/foo")
+	assert.Contains(t, captured, "hello")
+	assert.Contains(t, captured, "WARN: http://example.com/foo is marked deprecated; sunset 2025-03-01")
+	assert.Less(t, strings.Index(captured, "hello"), strings.Index(captured, "WARN:"))
+}
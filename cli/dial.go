@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unixScheme is the prefix for URLs which should be sent over a unix domain
+// socket, e.g. `unix:///var/run/svc.sock:/api/items`.
+const unixScheme = "unix://"
+
+// unixHostSuffix ends the synthetic hosts which `unix://` URLs are rewritten
+// to, so that each socket gets its own host and never captures requests to a
+// real one like `localhost`.
+const unixHostSuffix = ".sock.localhost"
+
+// socketPaths maps `host:port` addresses to unix domain socket paths which
+// should be dialed instead of making a TCP connection.
+var socketPaths = map[string]string{}
+var socketMu sync.Mutex
+
+// baseDialer matches the dialer settings of Go's default HTTP transport.
+var baseDialer = &net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+}
+
+func init() {
+	// The assumption is that all Transport implementations eventually use the
+	// default HTTP transport, so a custom dialer here covers every request.
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.DialContext = dialContext
+	}
+}
+
+// hostPort returns the `host:port` address for a URL, using the default
+// port for the scheme if none is given.
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// registerSocket makes all connections to the URL's host go through the
+// given unix domain socket. TLS is still used for `https` URLs.
+func registerSocket(u *url.URL, path string) {
+	socketMu.Lock()
+	defer socketMu.Unlock()
+
+	addr := hostPort(u)
+	if existing, ok := socketPaths[addr]; ok && existing != path {
+		// Don't re-use a kept-alive connection to the previous socket.
+		closeIdleConnections()
+	}
+	socketPaths[addr] = path
+}
+
+// unixHost returns the synthetic host for a unix domain socket path.
+func unixHost(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return hex.EncodeToString(sum[:8]) + unixHostSuffix
+}
+
+// fixUnixAddress converts `unix:///path/to.sock:/api/items` into a regular
+// `http://<hash>.sock.localhost/api/items` URL and registers the socket for
+// it. As with curl, the `Host` header is set to `localhost`, see
+// `MakeRequest`.
+func fixUnixAddress(addr string) string {
+	socket := strings.TrimPrefix(addr, unixScheme)
+	path := "/"
+	if i := strings.Index(socket, ":"); i != -1 {
+		socket, path = socket[:i], socket[i+1:]
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+
+	fixed := "http://" + unixHost(socket) + path
+	if u, err := url.Parse(fixed); err == nil {
+		registerSocket(u, socket)
+	}
+	return fixed
+}
+
+// dialContext connects to a registered unix domain socket if one exists
+// for the address, otherwise it falls back to a normal network connection.
+func dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	socketMu.Lock()
+	path := socketPaths[addr]
+	socketMu.Unlock()
+
+	if path == "" {
+		return baseDialer.DialContext(ctx, network, addr)
+	}
+
+	HTTPLog.Log(LevelRequest, "Dialing unix socket %s for %s", path, addr)
+	conn, err := baseDialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to unix socket %s: %w", path, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveSocket(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "svc.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"host": "` + r.Host + `", "path": "` + r.URL.Path + `"}`))
+	}))
+
+	return path
+}
+
+func TestUnixSocketURL(t *testing.T) {
+	path := serveSocket(t)
+
+	expectJSON(t, "--rsh-no-cache unix://"+path+":/api/items", `{
+		"host": "localhost",
+		"path": "/api/items"
+	}`)
+}
+
+func TestUnixSocketURLHost(t *testing.T) {
+	first := fixAddress("unix:///tmp/first.sock:/api/items")
+	second := fixAddress("unix:///tmp/second.sock:/api/items")
+	assert.NotEqual(t, first, second)
+
+	// Plain localhost requests must not be sent to either socket.
+	socketMu.Lock()
+	defer socketMu.Unlock()
+	assert.NotContains(t, socketPaths, "localhost:80")
+}
+
+func TestUnixSocketConfig(t *testing.T) {
+	path := serveSocket(t)
+
+	reset(false)
+	configs["sock-api"] = &APIConfig{
+		name:   "sock-api",
+		Base:   "http://sock.example.com",
+		Socket: path,
+	}
+	defer delete(configs, "sock-api")
+
+	req, _ := http.NewRequest(http.MethodGet, "http://sock.example.com/items", nil)
+	resp, err := GetParsedResponse(req, WithoutLog())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"host": "sock.example.com",
+		"path": "/items",
+	}, resp.Body)
+}
+
+func TestUnixSocketError(t *testing.T) {
+	reset(false)
+	req, _ := http.NewRequest(http.MethodGet, fixAddress("unix:///does/not/exist.sock:/"), nil)
+	_, err := MakeRequest(req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to connect to unix socket /does/not/exist.sock")
+}
@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// volatileHeaders change on every request, so they are left out of response
+// diffs to avoid noise.
+var volatileHeaders = []string{"Age", "Date"}
+
+// diffOptions configure how two responses are compared.
+type diffOptions struct {
+	// Against is a file to compare the first response body with instead of
+	// fetching a second URI.
+	Against string
+
+	// Ignore lists JSON pointers to remove from both bodies before comparing.
+	Ignore []string
+
+	// ExitCode exits with status 1 if there are any differences.
+	ExitCode bool
+}
+
+// removePointer removes the value at a JSON pointer like `/items/0/id` from
+// the document, returning the updated document. Missing paths are ignored.
+func removePointer(doc any, pointer string) any {
+	if pointer == "" || pointer == "/" {
+		return nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, p := range parts {
+		// See https://www.rfc-editor.org/rfc/rfc6901#section-4
+		parts[i] = strings.ReplaceAll(strings.ReplaceAll(p, "~1", "/"), "~0", "~")
+	}
+
+	return removePath(doc, parts)
+}
+
+func removePath(doc any, parts []string) any {
+	switch v := doc.(type) {
+	case map[string]any:
+		if len(parts) == 1 {
+			delete(v, parts[0])
+		} else if child, ok := v[parts[0]]; ok {
+			v[parts[0]] = removePath(child, parts[1:])
+		}
+	case []any:
+		i, err := strconv.Atoi(parts[0])
+		if err != nil || i < 0 || i >= len(v) {
+			return doc
+		}
+		if len(parts) == 1 {
+			return append(v[:i], v[i+1:]...)
+		}
+		v[i] = removePath(v[i], parts[1:])
+	}
+	return doc
+}
+
+// normalizeBody converts a parsed body into consistently formatted JSON so
+// that equivalent documents, e.g. from different content types or with keys
+// in a different order, produce the same text.
+func normalizeBody(body any, ignore []string) ([]byte, error) {
+	if b, ok := body.([]byte); ok {
+		// Unparsed bodies are compared as-is.
+		return b, nil
+	}
+
+	// Round-trip through JSON so e.g. CBOR integers match JSON numbers.
+	b, err := json.Marshal(makeJSONSafe(body))
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	for _, pointer := range ignore {
+		doc = removePointer(doc, pointer)
+	}
+
+	return MarshalShort("json", true, doc)
+}
+
+// headerLines returns the status & headers of a response as sorted lines.
+func headerLines(resp Response) string {
+	names := []string{}
+	for name := range resp.Headers {
+		ignored := false
+		for _, volatile := range volatileHeaders {
+			if strings.EqualFold(name, volatile) {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	lines := fmt.Sprintf("%s %d %s\n", resp.Proto, resp.Status, http.StatusText(resp.Status))
+	for _, name := range names {
+		lines += name + ": " + resp.Headers[name] + "\n"
+	}
+	return lines
+}
+
+// unifiedDiff returns a unified diff of the two texts, or an empty string if
+// they are the same.
+func unifiedDiff(fromName, toName, from, to string) string {
+	edits := myers.ComputeEdits(span.URIFromPath(fromName), from, to)
+	if len(edits) == 0 {
+		return ""
+	}
+
+	diff := fmt.Sprint(gotextdiff.ToUnified(fromName, toName, from, edits))
+	if useColor {
+		if d, err := Highlight("diff", []byte(diff)); err == nil {
+			diff = string(d)
+		}
+	}
+	return diff
+}
+
+// loadDiffFile loads a body to compare against from a file like `@file.json`.
+func loadDiffFile(filename string) (any, error) {
+	b, err := os.ReadFile(strings.TrimPrefix(filename, "@"))
+	if err != nil {
+		return nil, err
+	}
+
+	var body any
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", filename, err)
+	}
+	return body, nil
+}
+
+// diffResponses fetches the given URIs and prints the differences between
+// their headers & bodies.
+func diffResponses(args []string, opts diffOptions, exitFunc func(int)) {
+	if len(args) == 2 && opts.Against != "" {
+		panic("use either two URIs or --against, not both")
+	}
+	if len(args) == 1 && opts.Against == "" {
+		panic("a second URI or --against @file.json is required")
+	}
+
+	fetch := func(addr string) Response {
+		req, err := http.NewRequest(http.MethodGet, fixAddress(addr), nil)
+		panicOnErr(err)
+		resp, err := GetParsedResponse(req)
+		panicOnErr(err)
+		return resp
+	}
+
+	from := fetch(args[0])
+	fromBody, err := normalizeBody(from.Body, opts.Ignore)
+	panicOnErr(err)
+
+	var toName, headersDiff string
+	var toBody []byte
+	if opts.Against != "" {
+		toName = strings.TrimPrefix(opts.Against, "@")
+		body, err := loadDiffFile(opts.Against)
+		panicOnErr(err)
+		toBody, err = normalizeBody(body, opts.Ignore)
+		panicOnErr(err)
+	} else {
+		toName = args[1]
+		to := fetch(args[1])
+		toBody, err = normalizeBody(to.Body, opts.Ignore)
+		panicOnErr(err)
+		headersDiff = unifiedDiff(args[0], args[1], headerLines(from), headerLines(to))
+	}
+
+	bodyDiff := unifiedDiff(args[0], toName, string(fromBody), string(toBody))
+
+	if headersDiff == "" && bodyDiff == "" {
+		fmt.Fprintln(Stdout, "No differences found.")
+		return
+	}
+
+	if headersDiff != "" {
+		fmt.Fprintln(Stdout, "Headers:")
+		fmt.Fprintln(Stdout, headersDiff)
+	}
+	if bodyDiff != "" {
+		fmt.Fprintln(Stdout, "Body:")
+		fmt.Fprintln(Stdout, bodyDiff)
+	}
+
+	if opts.ExitCode {
+		exitFunc(1)
+	}
+}
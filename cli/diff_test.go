@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestRemovePointer(t *testing.T) {
+	doc := map[string]any{
+		"id":      1,
+		"a/b":     true,
+		"items":   []any{map[string]any{"id": 1, "updated": 2}, "second"},
+		"nested":  map[string]any{"updated": 3},
+		"missing": nil,
+	}
+
+	doc = removePointer(doc, "/a~1b").(map[string]any)
+	doc = removePointer(doc, "/items/0/updated").(map[string]any)
+	doc = removePointer(doc, "/items/1").(map[string]any)
+	doc = removePointer(doc, "/nested/updated").(map[string]any)
+	doc = removePointer(doc, "/does/not/exist").(map[string]any)
+	doc = removePointer(doc, "/items/9").(map[string]any)
+
+	assert.Equal(t, map[string]any{
+		"id":      1,
+		"items":   []any{map[string]any{"id": 1}},
+		"nested":  map[string]any{},
+		"missing": nil,
+	}, doc)
+}
+
+func TestDiffResponses(t *testing.T) {
+	defer gock.Off()
+	reset(false)
+
+	gock.New("http://staging.example.com").
+		Get("/items/1").
+		Reply(http.StatusOK).
+		SetHeader("Date", "Mon, 01 Jan 2024 00:00:00 GMT").
+		SetHeader("X-Version", "2").
+		JSON(map[string]any{"id": 1, "name": "new", "updated_at": "now"})
+
+	gock.New("http://prod.example.com").
+		Get("/items/1").
+		Reply(http.StatusOK).
+		SetHeader("Date", "Tue, 02 Jan 2024 00:00:00 GMT").
+		SetHeader("X-Version", "1").
+		JSON(map[string]any{"updated_at": "before", "name": "old", "id": 1})
+
+	capture := &strings.Builder{}
+	Stdout = capture
+
+	code := 0
+	diffResponses([]string{"http://staging.example.com/items/1", "http://prod.example.com/items/1"}, diffOptions{
+		Ignore:   []string{"/updated_at"},
+		ExitCode: true,
+	}, func(c int) { code = c })
+
+	out := capture.String()
+	assert.Contains(t, out, "Headers:")
+	assert.Contains(t, out, "-X-Version: 2\n+X-Version: 1")
+	assert.NotContains(t, out, "Date")
+	assert.Contains(t, out, "Body:")
+	assert.Contains(t, out, "-  \"name\": \"new\"\n+  \"name\": \"old\"")
+	assert.NotContains(t, out, "updated_at")
+	assert.Equal(t, 1, code)
+}
+
+func TestDiffAgainstFile(t *testing.T) {
+	defer gock.Off()
+
+	filename := filepath.Join(t.TempDir(), "item.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{"name": "same", "id": 1}`), 0o600))
+
+	gock.New("http://example.com").
+		Get("/items/1").
+		Reply(http.StatusOK).
+		JSON(map[string]any{"id": 1, "name": "same"})
+
+	captured := run("diff http://example.com/items/1 --exit-code --against @" + filename)
+	assert.Contains(t, captured, "No differences found.")
+	assert.NotContains(t, captured, "Headers:")
+}
+
+func TestDiffArgs(t *testing.T) {
+	reset(false)
+
+	assert.PanicsWithValue(t, "a second URI or --against @file.json is required", func() {
+		diffResponses([]string{"http://example.com/"}, diffOptions{}, func(int) {})
+	})
+
+	assert.PanicsWithValue(t, "use either two URIs or --against, not both", func() {
+		diffResponses([]string{"http://example.com/", "http://example.com/"}, diffOptions{Against: "@file.json"}, func(int) {})
+	})
+}
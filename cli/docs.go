@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// docsPage is a single generated documentation page.
+type docsPage struct {
+	// Name is the full command path joined with dashes, e.g. `restish-my-api`.
+	Name  string
+	Title string
+	Short string
+	// Long is the Markdown description, the same as shown by `--help`.
+	Long string
+	// Usage is the plain text usage including flags and examples.
+	Usage string
+	// Operations are the child pages, used for the API index.
+	Operations []*docsPage
+}
+
+// docsPages builds the index page for an API and one page per visible
+// operation, sorted by name. Operation commands are created just like for
+// `--help` so that the docs can't drift from the interactive help.
+func docsPages(name string, api API) *docsPage {
+	root := &cobra.Command{Use: Root.Name()}
+	parent := &cobra.Command{Use: name, Short: api.Short, Long: api.Long}
+	root.AddCommand(parent)
+
+	index := &docsPage{
+		Name:  strings.ReplaceAll(parent.CommandPath(), " ", "-"),
+		Title: parent.CommandPath(),
+		Short: api.Short,
+		Long:  api.Long,
+	}
+
+	ops := append([]Operation{}, api.Operations...)
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].Name < ops[j].Name
+	})
+
+	for _, op := range ops {
+		if op.Hidden {
+			continue
+		}
+		cmd := op.command()
+		parent.AddCommand(cmd)
+
+		index.Operations = append(index.Operations, &docsPage{
+			Name:  strings.ReplaceAll(cmd.CommandPath(), " ", "-"),
+			Title: cmd.CommandPath(),
+			Short: cmd.Short,
+			Long:  strings.TrimSpace(cmd.Long),
+			Usage: strings.TrimSpace(cmd.UsageString()),
+		})
+	}
+
+	return index
+}
+
+// markdownDocs renders a page as Markdown.
+func markdownDocs(page *docsPage) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "# %s\n\n", page.Title)
+	if page.Short != "" {
+		fmt.Fprintf(sb, "%s\n\n", page.Short)
+	}
+	if page.Long != "" {
+		fmt.Fprintf(sb, "%s\n\n", page.Long)
+	}
+	if page.Usage != "" {
+		fmt.Fprintf(sb, "## Usage\n\n```\n%s\n```\n\n", page.Usage)
+	}
+	if len(page.Operations) > 0 {
+		sb.WriteString("## Operations\n\n")
+		for _, op := range page.Operations {
+			fmt.Fprintf(sb, "- [%s](%s.md)", op.Title, op.Name)
+			if op.Short != "" {
+				fmt.Fprintf(sb, ": %s", op.Short)
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// roffEscape escapes text for use in a man page.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// roffMarkdown converts the Markdown used in operation descriptions into
+// roff. Only headings, paragraphs, and code blocks are handled, with any
+// other formatting kept as plain text.
+func roffMarkdown(md string) string {
+	sb := &strings.Builder{}
+	code := false
+	for _, line := range strings.Split(md, "\n") {
+		switch {
+		case strings.HasPrefix(line, "```"):
+			code = !code
+			if code {
+				sb.WriteString(".PP\n.RS\n.nf\n")
+			} else {
+				sb.WriteString(".fi\n.RE\n")
+			}
+		case code:
+			sb.WriteString(roffEscape(line) + "\n")
+		case strings.HasPrefix(line, "#"):
+			sb.WriteString(".SS " + roffEscape(strings.TrimSpace(strings.TrimLeft(line, "#"))) + "\n")
+		case strings.TrimSpace(line) == "":
+			sb.WriteString(".PP\n")
+		default:
+			sb.WriteString(roffEscape(line) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// manDocs renders a page as a section 1 man page.
+func manDocs(page *docsPage) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, ".TH \"%s\" \"1\" \"\" \"%s\" \"\"\n", strings.ToUpper(page.Name), Root.Name())
+	fmt.Fprintf(sb, ".SH NAME\n%s", roffEscape(page.Name))
+	if page.Short != "" {
+		fmt.Fprintf(sb, " \\- %s", roffEscape(page.Short))
+	}
+	sb.WriteString("\n")
+	if page.Long != "" {
+		sb.WriteString(".SH DESCRIPTION\n" + roffMarkdown(page.Long))
+	}
+	if page.Usage != "" {
+		sb.WriteString(".SH USAGE\n" + roffMarkdown("```\n"+page.Usage+"\n```"))
+	}
+	if len(page.Operations) > 0 {
+		sb.WriteString(".SH OPERATIONS\n")
+		for _, op := range page.Operations {
+			fmt.Fprintf(sb, ".TP\n\\fB%s\\fR(1)\n%s\n", roffEscape(op.Name), roffEscape(op.Short))
+		}
+	}
+	return sb.String()
+}
+
+// writeDocs renders the API index and operation pages into a directory as
+// either `markdown` or `man` pages. Output is deterministic so that it can be
+// committed and diffed.
+func writeDocs(index *docsPage, format, dir string) error {
+	var render func(*docsPage) string
+	var ext string
+	switch format {
+	case "markdown", "md":
+		render, ext = markdownDocs, ".md"
+	case "man":
+		render, ext = manDocs, ".1"
+	default:
+		return fmt.Errorf("unknown docs format %s, expected markdown or man", format)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, page := range append([]*docsPage{index}, index.Operations...) {
+		if err := os.WriteFile(filepath.Join(dir, page.Name+ext), []byte(render(page)), 0644); err != nil {
+			return err
+		}
+	}
+
+	LogInfo("Wrote %s to %s", pluralize(len(index.Operations)+1, "page"), dir)
+	return nil
+}
+
+// docsCommand returns the `api docs` command.
+func docsCommand() *cobra.Command {
+	var format *string
+	cmd := &cobra.Command{
+		Use:   "docs short-name -o dir",
+		Short: "Generate API docs",
+		Long:  "Generate Markdown docs or man pages for every operation of an API from its cached description, with the same descriptions, parameters, schemas, and examples shown by `--help`. Output is deterministic so that it can be committed alongside the API. The output directory is set via `-o`.",
+		Example: fmt.Sprintf(`  $ %s api docs my-api -o docs/
+  $ %s api docs my-api --format man -o man/man1`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if configs[args[0]] == nil {
+				panic("API " + args[0] + " not found")
+			}
+			if !cmd.Flags().Changed("rsh-output-format") {
+				panic("an output directory is required via -o")
+			}
+
+			api, err := Load(fixAddress(args[0]), &cobra.Command{})
+			if err != nil {
+				panic(err)
+			}
+
+			dir, _ := cmd.Flags().GetString("rsh-output-format")
+			if err := writeDocs(docsPages(args[0], api), *format, dir); err != nil {
+				panic(err)
+			}
+		},
+	}
+	format = cmd.Flags().String("format", "markdown", "Docs format, either markdown or man")
+	cmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"markdown", "man"}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return cmd
+}
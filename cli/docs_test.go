@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestAPIDocs(t *testing.T) {
+	defer gock.Off()
+	defer viper.Set("rsh-no-cache", false)
+
+	gock.New("https://docs-test.example.com/").Persist().Reply(http.StatusOK)
+
+	reset(false)
+	viper.Set("rsh-no-cache", true)
+	configs["docs-test"] = &APIConfig{
+		name: "docs-test",
+		Base: "https://docs-test.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {},
+		},
+	}
+	AddLoader(&testLoader{
+		API: API{
+			Short: "Docs Test API",
+			Operations: []Operation{
+				{
+					Name:        "list-items",
+					Short:       "List items",
+					Long:        "Lists all the items.\n\n## Response Schema\n\n```schema\n{\n  id: (string)\n}\n```",
+					Method:      http.MethodGet,
+					URITemplate: "https://docs-test.example.com/items",
+					QueryParams: []*Param{
+						{Type: "string", Name: "search", Description: "Search term"},
+					},
+				},
+				{
+					Name:        "get-item",
+					Short:       "Get an item",
+					Method:      http.MethodGet,
+					URITemplate: "https://docs-test.example.com/items/{id}",
+					PathParams:  []*Param{{Type: "string", Name: "id"}},
+				},
+				{
+					Name:        "secret",
+					Method:      http.MethodGet,
+					URITemplate: "https://docs-test.example.com/secret",
+					Hidden:      true,
+				},
+			},
+		},
+	})
+
+	dir := t.TempDir()
+	runNoReset("api docs docs-test -o " + dir)
+	name := Root.Name()
+
+	index, err := os.ReadFile(filepath.Join(dir, name+"-docs-test.md"))
+	require.NoError(t, err)
+	assert.Equal(t, strings.ReplaceAll("# ROOT docs-test\n\nDocs Test API\n\n## Operations\n\n- [ROOT docs-test get-item](ROOT-docs-test-get-item.md): Get an item\n- [ROOT docs-test list-items](ROOT-docs-test-list-items.md): List items\n", "ROOT", name), string(index))
+
+	page, err := os.ReadFile(filepath.Join(dir, name+"-docs-test-list-items.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "# "+name+" docs-test list-items\n\nList items\n\nLists all the items.\n\n## Response Schema\n")
+	assert.Contains(t, string(page), "## Usage\n\n```\nUsage:\n  "+name+" docs-test list-items [flags]\n")
+	assert.Contains(t, string(page), "--search string   Search term")
+
+	_, err = os.Stat(filepath.Join(dir, name+"-docs-test-secret.md"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Regenerating gives the same output.
+	runNoReset("api docs docs-test -o " + dir)
+	again, err := os.ReadFile(filepath.Join(dir, name+"-docs-test-list-items.md"))
+	require.NoError(t, err)
+	assert.Equal(t, string(page), string(again))
+
+	runNoReset("api docs docs-test --format man -o " + dir)
+	man, err := os.ReadFile(filepath.Join(dir, name+"-docs-test-list-items.1"))
+	require.NoError(t, err)
+	assert.Contains(t, string(man), ".TH \""+strings.ToUpper(name)+"-DOCS-TEST-LIST-ITEMS\" \"1\" \"\" \""+name+"\" \"\"\n.SH NAME\n"+name+"-docs-test-list-items \\- List items\n.SH DESCRIPTION\nLists all the items.\n.PP\n.SS Response Schema\n")
+	assert.Contains(t, string(man), ".SH USAGE\n.PP\n.RS\n.nf\nUsage:\n")
+}
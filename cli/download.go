@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strconv"
+
+	"github.com/mattn/go-isatty"
+	"github.com/schollz/progressbar/v3"
+)
+
+// resumeOffset returns the byte offset to resume a download from. A value of
+// `-` means to use the size of the existing file, if any.
+func resumeOffset(filename, continueAt string) (int64, error) {
+	switch continueAt {
+	case "":
+		return 0, nil
+	case "-":
+		info, err := os.Stat(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+
+	offset, err := strconv.ParseInt(continueAt, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid offset %q to continue at, expected a number of bytes or -", continueAt)
+	}
+	return offset, nil
+}
+
+// stderrIsTTY returns true if progress output can be drawn on stderr.
+func stderrIsTTY() bool {
+	if f, ok := Stderr.(*os.File); ok {
+		return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	}
+	return false
+}
+
+// logHeaders logs the request & response without their bodies in verbose
+// mode, since the bodies could be huge or binary. Secrets are redacted.
+func logHeaders(req *http.Request, resp *http.Response) {
+	if !HTTPLog.Enabled(LevelRequest) {
+		return
+	}
+	if dumped, err := httputil.DumpRequest(visibleRequest(req), false); err == nil {
+		HTTPLog.Log(LevelRequest, "Made request:\n%s", dumped)
+	}
+	if dumped, err := httputil.DumpResponse(visibleResponse(resp), false); err == nil {
+		HTTPLog.Log(LevelRequest, "Got response:\n%s", dumped)
+	}
+}
+
+// DownloadToFile makes a request and streams the raw response body into a
+// file without any formatting. If `continueAt` is set then a range request is
+// used to resume a previous partial download.
+func DownloadToFile(req *http.Request, filename, continueAt string) error {
+	offset, err := resumeOffset(filename, continueAt)
+	if err != nil {
+		return err
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+
+		// Byte ranges of an encoded response would not match the file on disk.
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+
+	// The normal request logging includes the body, which could be huge, so
+	// only log the headers instead.
+	resp, err := MakeRequest(req, WithoutLog())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	logHeaders(req, resp)
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		HTTPLog.Debug("Resuming download of %s at byte %d", filename, offset)
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		LogInfo("Download of %s is already complete", filename)
+		setLastStatus(0)
+		return nil
+	case offset > 0 && resp.StatusCode >= 400:
+		// Writing the error page would destroy the partial download.
+		return &ErrHTTP{
+			Status:  resp.StatusCode,
+			URL:     req.URL.String(),
+			Message: fmt.Sprintf("server responded with %d, keeping the partial download %s", resp.StatusCode, filename),
+		}
+	case offset > 0 && resp.StatusCode < 300:
+		LogWarning("Server does not support resuming downloads, starting over")
+	}
+
+	// Decoding resets the content length if the body was compressed, since
+	// the progress bar needs the decoded size.
+	if err := DecodeResponse(resp); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filename, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if resp.ContentLength > 0 && stderrIsTTY() {
+		bar := progressbar.DefaultBytes(resp.ContentLength, "Downloading "+filename)
+		w = io.MultiWriter(f, bar)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// WriteRawBody makes a request and streams the exact response body to a
+// writer without parsing, formatting, or coloring it. Compressed responses
+// are still decoded.
+func WriteRawBody(req *http.Request, w io.Writer) error {
+	resp, err := MakeRequest(req, WithoutLog())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	logHeaders(req, resp)
+
+	if err := DecodeResponse(resp); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadToFile(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	ranges := []string{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "artifact.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "artifact.bin")
+	defer viper.Set("rsh-output-file", "")
+	defer viper.Set("rsh-continue-at", "")
+
+	out := run(ts.URL + "/artifact.bin --rsh-no-cache --rsh-output-file " + filename)
+	assert.Empty(t, out)
+
+	written, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, content, written)
+
+	// Simulate a partial download and resume it.
+	require.NoError(t, os.Truncate(filename, 1234))
+
+	run(ts.URL + "/artifact.bin --rsh-no-cache --rsh-output-file " + filename + " --rsh-continue-at -")
+
+	written, err = os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, content, written)
+	assert.Equal(t, []string{"", "bytes=1234-"}, ranges)
+
+	// Resuming a complete download is a no-op.
+	out = run(ts.URL + "/artifact.bin --rsh-no-cache --rsh-output-file " + filename + " --rsh-continue-at -")
+	assert.Contains(t, out, "already complete")
+	expectExitCode(t, 0)
+}
+
+func TestDownloadNoResumeSupport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("full content"))
+	}))
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "file.txt")
+	defer viper.Set("rsh-output-file", "")
+	defer viper.Set("rsh-continue-at", "")
+	require.NoError(t, os.WriteFile(filename, []byte("full"), 0600))
+
+	out := run(ts.URL + " --rsh-no-cache --rsh-output-file " + filename + " --rsh-continue-at -")
+	assert.Contains(t, out, "starting over")
+
+	written, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "full content", string(written))
+}
+
+func TestDownloadResumeError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("try again later"))
+	}))
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "file.txt")
+	defer viper.Set("rsh-output-file", "")
+	defer viper.Set("rsh-continue-at", "")
+	require.NoError(t, os.WriteFile(filename, []byte("partial"), 0600))
+
+	out := run(ts.URL + " --rsh-no-cache --rsh-output-file " + filename + " --rsh-continue-at -")
+	assert.Contains(t, out, "keeping the partial download")
+
+	written, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "partial", string(written))
+}
+
+func TestRawBody(t *testing.T) {
+	body := []byte(`{"b":2,  "a":[1,2]}` + "\n")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write(body)
+		gz.Close()
+	}))
+	defer ts.Close()
+	defer viper.Set("rsh-raw-body", false)
+	defer viper.Set("rsh-filter", "")
+
+	out := run(ts.URL + " --rsh-raw-body")
+	assert.Equal(t, string(body), out)
+
+	out = run(ts.URL + " --rsh-raw-body -f body.a")
+	assert.Contains(t, out, "cannot be combined with a filter")
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
 	"os"
 	"os/exec"
+	"reflect"
 	"strings"
 
 	"github.com/danielgtaylor/shorthand/v2"
@@ -34,23 +36,83 @@ func getEditor() string {
 	return editor
 }
 
-func edit(addr string, args []string, interactive, noPrompt bool, exitFunc func(int), editMarshal func(interface{}) ([]byte, error), editUnmarshal func([]byte, interface{}) error, ext string) {
-	if !interactive && len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "No arguments passed to modify the resource. Use `-i` to enable interactive mode.")
-		exitFunc(1)
-		return
+// editMaxConflicts is how many times an edit is re-applied to a freshly
+// fetched resource after the server reports a conflicting change.
+const editMaxConflicts = 3
+
+// mergePatch returns a JSON merge patch (RFC 7386) which turns `orig` into
+// `modified`. Removed keys are set to `nil`.
+func mergePatch(orig, modified any) any {
+	o, ok1 := orig.(map[string]any)
+	m, ok2 := modified.(map[string]any)
+	if !ok1 || !ok2 {
+		return modified
 	}
 
-	editor := getEditor()
-	if interactive && editor == "" {
-		fmt.Fprintln(os.Stderr, `Please set the VISUAL or EDITOR environment variable with your preferred editor. Examples:
+	patch := map[string]any{}
+	for k, v := range m {
+		ov, ok := o[k]
+		if !ok {
+			patch[k] = v
+			continue
+		}
+		if reflect.DeepEqual(ov, v) {
+			continue
+		}
+		patch[k] = mergePatch(ov, v)
+	}
+	for k := range o {
+		if _, ok := m[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	return patch
+}
 
-export VISUAL="code --wait"
-export EDITOR="vim"`)
-		exitFunc(1)
-		return
+// applyMergePatch applies a JSON merge patch (RFC 7386) to a document,
+// modifying it in place where possible.
+func applyMergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
 	}
 
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = applyMergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// editResource is a fetched resource ready for editing.
+type editResource struct {
+	// orig is the resource as indented JSON, used for comparisons. We use JSON
+	// here for consistency and to avoid things like YAML encoding e.g. dates
+	// and strings differently.
+	orig []byte
+
+	contentType  string
+	etag         string
+	lastModified string
+}
+
+// data returns a fresh copy of the original resource to modify.
+func (r editResource) data() any {
+	var data any
+	json.Unmarshal(r.orig, &data)
+	return data
+}
+
+// fetchEditable gets the resource to edit. Returns false if the resource can't
+// be edited, after reporting why.
+func fetchEditable(addr string, exitFunc func(int)) (editResource, bool) {
 	req, _ := http.NewRequest(http.MethodGet, fixAddress(addr), nil)
 	resp, err := GetParsedResponse(req)
 	panicOnErr(err)
@@ -58,7 +120,7 @@ export EDITOR="vim"`)
 	if resp.Status >= 400 {
 		panicOnErr(Formatter.Format(resp))
 		exitFunc(1)
-		return
+		return editResource{}, false
 	}
 
 	// Convert from CBOR or other formats which might allow map[any]any to the
@@ -72,7 +134,7 @@ export EDITOR="vim"`)
 	}
 
 	var logger func(format string, a ...interface{})
-	if enableVerbose {
+	if verbosity >= LevelInfo {
 		logger = LogDebug
 	}
 	filtered, _, err := shorthand.GetPath(filter, data, shorthand.GetOptions{
@@ -84,107 +146,180 @@ export EDITOR="vim"`)
 	if _, ok := data.(map[string]interface{}); !ok {
 		fmt.Fprintln(os.Stderr, "Resource didn't return an object.")
 		exitFunc(1)
-		return
+		return editResource{}, false
 	}
 
-	// Save original representation for comparison later. We use JSON here for
-	// consistency and to avoid things like YAML encoding e.g. dates and strings
-	// differently.
 	orig, _ := json.MarshalIndent(data, "", "  ")
 
 	// If available, grab any headers that can be used for conditional updates
 	// so we don't overwrite changes made by other people while we edit.
-	etag := resp.Headers["Etag"]
-	lastModified := resp.Headers["Last-Modified"]
+	return editResource{
+		orig:         orig,
+		contentType:  resp.Headers["Content-Type"],
+		etag:         resp.Headers["Etag"],
+		lastModified: resp.Headers["Last-Modified"],
+	}, true
+}
 
-	// TODO: remove read-only fields? This requires:
-	// 1. Figure out which operation the URL corresponds to.
-	// 2. Get and then analyse the response schema for that operation.
-	// 3. Remove corresponding fields from `data`.
+// openEditor writes the document to a temporary file, opens it in the
+// editor, and returns the edited document once the editor exits.
+func openEditor(editor string, doc any, editMarshal func(interface{}) ([]byte, error), editUnmarshal func([]byte, interface{}) error, ext string) any {
+	// Create temp file
+	tmp, err := os.CreateTemp("", "rsh-edit*"+ext)
+	panicOnErr(err)
+	defer os.Remove(tmp.Name())
 
-	var modified interface{} = data
+	// TODO: should we try and detect a `describedby` link relation and insert
+	// that as a `$schema` key into the document before editing? The schema
+	// itself may not allow the `$schema` key... hmm.
 
-	if len(args) > 0 {
-		modified, err = shorthand.Unmarshal(strings.Join(args, " "), shorthand.ParseOptions{EnableFileInput: true, EnableObjectDetection: true}, modified)
-		panicOnErr(err)
-	}
+	// Write the current body
+	marshalled, err := editMarshal(doc)
+	panicOnErr(err)
+	tmp.Write(marshalled)
+	tmp.Close()
 
-	if interactive {
-		// Create temp file
-		tmp, err := os.CreateTemp("", "rsh-edit*"+ext)
-		panicOnErr(err)
-		defer os.Remove(tmp.Name())
+	// Open editor and wait for exit
+	parts, err := shlex.Split(editor)
+	panicOnErr(err)
+	name := parts[0]
+	args := append(parts[1:], tmp.Name())
 
-		// TODO: should we try and detect a `describedby` link relation and insert
-		// that as a `$schema` key into the document before editing? The schema
-		// itself may not allow the `$schema` key... hmm.
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	panicOnErr(cmd.Run())
 
-		// Write the current body
-		marshalled, err := editMarshal(modified)
-		panicOnErr(err)
-		tmp.Write(marshalled)
-		tmp.Close()
+	// Read file contents
+	b, err := os.ReadFile(tmp.Name())
+	panicOnErr(err)
 
-		// Open editor and wait for exit
-		parts, err := shlex.Split(editor)
-		panicOnErr(err)
-		name := parts[0]
-		args := append(parts[1:], tmp.Name())
+	var edited any
+	panicOnErr(editUnmarshal(b, &edited))
+	return edited
+}
 
-		cmd := exec.Command(name, args...)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		panicOnErr(cmd.Run())
+// editRequest builds the request to save the modified resource. The body is
+// sent in the same content type the server returned if possible. In patch
+// mode, only the changes are sent as a JSON merge patch.
+func editRequest(addr string, res editResource, modified any, patch bool) *http.Request {
+	// TODO: content-encoding for large bodies?
+	method := http.MethodPut
+	contentType := "application/json"
+	var b []byte
+	if patch {
+		method = http.MethodPatch
+		contentType = "application/merge-patch+json"
+		b, _ = json.Marshal(mergePatch(res.data(), modified))
+	} else {
+		if mt, _, err := mime.ParseMediaType(res.contentType); err == nil && !strings.Contains(mt, "json") {
+			if encoded, err := Marshal(mt, modified); err == nil {
+				b = encoded
+				contentType = mt
+			}
+		}
+		if b == nil {
+			b, _ = json.Marshal(modified)
+		}
+	}
 
-		// Read file contents
-		b, err := os.ReadFile(tmp.Name())
-		panicOnErr(err)
+	req, _ := http.NewRequest(method, fixAddress(addr), bytes.NewReader(b))
+	req.Header.Set("Content-Type", contentType)
 
-		panicOnErr(editUnmarshal(b, &modified))
+	if res.etag != "" {
+		req.Header.Set("If-Match", res.etag)
+	} else if res.lastModified != "" {
+		req.Header.Set("If-Unmodified-Since", res.lastModified)
 	}
 
-	modified = makeJSONSafe(modified)
-	mod, err := json.MarshalIndent(modified, "", "  ")
-	panicOnErr(err)
-	edits := myers.ComputeEdits(span.URIFromPath("original"), string(orig), string(mod))
+	return req
+}
 
-	if len(edits) == 0 {
-		fmt.Fprintln(os.Stderr, "No changes made.")
-		exitFunc(0)
+func edit(addr string, args []string, interactive, noPrompt, patch bool, exitFunc func(int), editMarshal func(interface{}) ([]byte, error), editUnmarshal func([]byte, interface{}) error, ext string) {
+	if !interactive && len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "No arguments passed to modify the resource. Use `-i` to enable interactive mode.")
+		exitFunc(1)
 		return
-	} else {
-		diff := fmt.Sprint(gotextdiff.ToUnified("original", "modified", string(orig), edits))
-		if useColor {
-			d, _ := Highlight("diff", []byte(diff))
-			diff = string(d)
+	}
+
+	editor := getEditor()
+	if interactive && editor == "" {
+		fmt.Fprintln(os.Stderr, `Please set the VISUAL or EDITOR environment variable with your preferred editor. Examples:
+
+export VISUAL="code --wait"
+export EDITOR="vim"`)
+		exitFunc(1)
+		return
+	}
+
+	// TODO: remove read-only fields? This requires:
+	// 1. Figure out which operation the URL corresponds to.
+	// 2. Get and then analyse the response schema for that operation.
+	// 3. Remove corresponding fields from `data`.
+
+	// changes holds a merge patch of the user's edits so they can be re-applied
+	// if someone else modified the resource in the meantime.
+	var changes any
+
+	for attempt := 0; ; attempt++ {
+		res, ok := fetchEditable(addr, exitFunc)
+		if !ok {
+			return
 		}
-		fmt.Println(diff)
-
-		if !noPrompt && isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd()) {
-			fmt.Printf("Continue? [Y/n] ")
-			tmp := []byte{0}
-			os.Stdin.Read(tmp)
-			if tmp[0] == 'n' {
-				exitFunc(0)
-				return
+
+		var err error
+		modified := res.data()
+		if changes != nil {
+			modified = applyMergePatch(modified, changes)
+		} else if len(args) > 0 {
+			modified, err = parseShorthand(args, shorthand.ParseOptions{EnableFileInput: true, EnableObjectDetection: true}, modified)
+			panicOnErr(err)
+		}
+
+		if interactive {
+			modified = openEditor(editor, modified, editMarshal, editUnmarshal, ext)
+		}
+
+		modified = makeJSONSafe(modified)
+		mod, err := json.MarshalIndent(modified, "", "  ")
+		panicOnErr(err)
+		edits := myers.ComputeEdits(span.URIFromPath("original"), string(res.orig), string(mod))
+
+		if len(edits) == 0 {
+			fmt.Fprintln(os.Stderr, "No changes made.")
+			exitFunc(0)
+			return
+		} else {
+			diff := fmt.Sprint(gotextdiff.ToUnified("original", "modified", string(res.orig), edits))
+			if useColor {
+				d, _ := Highlight("diff", []byte(diff))
+				diff = string(d)
+			}
+			fmt.Println(diff)
+
+			if !noPrompt && isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+				fmt.Printf("Continue? [Y/n] ")
+				tmp := []byte{0}
+				os.Stdin.Read(tmp)
+				if tmp[0] == 'n' {
+					exitFunc(0)
+					return
+				}
 			}
 		}
-	}
 
-	// TODO: support different submission formats, e.g. based on any given
-	// `Content-Type` header?
-	// TODO: content-encoding for large bodies?
-	// TODO: determine if a PATCH could be used instead?
-	b, _ := json.Marshal(modified)
-	req, _ = http.NewRequest(http.MethodPut, fixAddress(addr), bytes.NewReader(b))
-	req.Header.Set("Content-Type", "application/json")
+		changes = mergePatch(res.data(), modified)
 
-	if etag != "" {
-		req.Header.Set("If-Match", etag)
-	} else if lastModified != "" {
-		req.Header.Set("If-Unmodified-Since", lastModified)
-	}
+		resp, err := GetParsedResponse(editRequest(addr, res, modified, patch))
+		panicOnErr(err)
 
-	MakeRequestAndFormat(req)
+		if (resp.Status == http.StatusPreconditionFailed || resp.Status == http.StatusConflict) && attempt < editMaxConflicts {
+			LogWarning("The resource was modified by someone else (%d %s), fetching it again and re-applying your changes", resp.Status, http.StatusText(resp.Status))
+			continue
+		}
+
+		panicOnErr(Formatter.Format(resp))
+		return
+	}
 }
@@ -2,7 +2,9 @@ package cli
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -31,12 +33,12 @@ func TestEditSuccess(t *testing.T) {
 
 	os.Setenv("VISUAL", "")
 	os.Setenv("EDITOR", "true") // dummy to just return
-	edit("http://example.com/items/foo", []string{"bar:456"}, true, true, func(int) {}, json.Marshal, json.Unmarshal, "json")
+	edit("http://example.com/items/foo", []string{"bar:456"}, true, true, false, func(int) {}, json.Marshal, json.Unmarshal, "json")
 }
 
 func TestEditNonInteractiveArgsRequired(t *testing.T) {
 	code := 999
-	edit("http://example.com/items/foo", []string{}, false, true, func(c int) {
+	edit("http://example.com/items/foo", []string{}, false, true, false, func(c int) {
 		code = c
 	}, json.Marshal, json.Unmarshal, "json")
 
@@ -47,7 +49,7 @@ func TestEditInteractiveMissingEditor(t *testing.T) {
 	os.Setenv("VISUAL", "")
 	os.Setenv("EDITOR", "")
 	code := 999
-	edit("http://example.com/items/foo", []string{}, true, true, func(c int) {
+	edit("http://example.com/items/foo", []string{}, true, true, false, func(c int) {
 		code = c
 	}, json.Marshal, json.Unmarshal, "json")
 
@@ -62,7 +64,7 @@ func TestEditBadGet(t *testing.T) {
 		Reply(http.StatusInternalServerError)
 
 	code := 999
-	edit("http://example.com/items/foo", []string{"foo:123"}, false, true, func(c int) {
+	edit("http://example.com/items/foo", []string{"foo:123"}, false, true, false, func(c int) {
 		code = c
 	}, json.Marshal, json.Unmarshal, "json")
 
@@ -81,7 +83,7 @@ func TestEditNoChange(t *testing.T) {
 		})
 
 	code := 999
-	edit("http://example.com/items/foo", []string{"foo:123"}, false, true, func(c int) {
+	edit("http://example.com/items/foo", []string{"foo:123"}, false, true, false, func(c int) {
 		code = c
 	}, json.Marshal, json.Unmarshal, "json")
 
@@ -100,9 +102,99 @@ func TestEditNotObject(t *testing.T) {
 		})
 
 	code := 999
-	edit("http://example.com/items/foo", []string{"foo:123"}, false, true, func(c int) {
+	edit("http://example.com/items/foo", []string{"foo:123"}, false, true, false, func(c int) {
 		code = c
 	}, json.Marshal, json.Unmarshal, "json")
 
 	assert.Equal(t, 1, code)
 }
+
+func TestMergePatch(t *testing.T) {
+	orig := map[string]any{"a": 1.0, "b": map[string]any{"c": true, "d": "x"}, "e": "gone"}
+	modified := map[string]any{"a": 1.0, "b": map[string]any{"c": false, "d": "x"}, "f": []any{1.0}}
+
+	patch := mergePatch(orig, modified)
+	assert.Equal(t, map[string]any{"b": map[string]any{"c": false}, "e": nil, "f": []any{1.0}}, patch)
+
+	// Applying the patch to a newer version of the original keeps other
+	// changes made in the meantime.
+	newer := map[string]any{"a": 2.0, "b": map[string]any{"c": true, "d": "y"}, "e": "gone"}
+	assert.Equal(t, map[string]any{"a": 2.0, "b": map[string]any{"c": false, "d": "y"}, "f": []any{1.0}}, applyMergePatch(newer, patch))
+}
+
+func TestEditPatch(t *testing.T) {
+	reset(false)
+
+	patched := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Etag", "abc123")
+			w.Write([]byte(`{"foo": 123, "baz": "unchanged"}`))
+			return
+		}
+
+		b, _ := io.ReadAll(r.Body)
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "abc123", r.Header.Get("If-Match"))
+		assert.Equal(t, "application/merge-patch+json", r.Header.Get("Content-Type"))
+		assert.JSONEq(t, `{"bar": 456, "foo": null}`, string(b))
+		patched = true
+	}))
+	defer ts.Close()
+
+	edit(ts.URL, []string{"bar:456, foo:undefined"}, false, true, true, func(int) {}, json.Marshal, json.Unmarshal, "json")
+	assert.True(t, patched)
+}
+
+func TestEditConflictRetry(t *testing.T) {
+	reset(false)
+	defer gock.Off()
+
+	gock.New("http://example.com").
+		Get("/items/foo").
+		Reply(http.StatusOK).
+		SetHeader("Etag", "v1").
+		JSON(map[string]interface{}{"foo": 123})
+
+	gock.New("http://example.com").
+		Put("/items/foo").
+		MatchHeader("If-Match", "v1").
+		Reply(http.StatusPreconditionFailed)
+
+	// Someone else added a field in the meantime, which must be kept.
+	gock.New("http://example.com").
+		Get("/items/foo").
+		Reply(http.StatusOK).
+		SetHeader("Etag", "v2").
+		JSON(map[string]interface{}{"foo": 123, "other": true})
+
+	gock.New("http://example.com").
+		Put("/items/foo").
+		MatchHeader("If-Match", "v2").
+		BodyString(`{"foo": 123, "other": true, "bar": 456}`).
+		Reply(http.StatusOK)
+
+	edit("http://example.com/items/foo", []string{"bar:456"}, false, true, false, func(int) {}, json.Marshal, json.Unmarshal, "json")
+	assert.True(t, gock.IsDone())
+}
+
+func TestEditServerContentType(t *testing.T) {
+	reset(false)
+	defer gock.Off()
+
+	gock.New("http://example.com").
+		Get("/items/foo").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/yaml").
+		BodyString("foo: 123\n")
+
+	gock.New("http://example.com").
+		Put("/items/foo").
+		MatchHeader("Content-Type", "application/yaml").
+		BodyString("bar: 456\nfoo: 123\n").
+		Reply(http.StatusOK)
+
+	edit("http://example.com/items/foo", []string{"bar:456"}, false, true, false, func(int) {}, json.Marshal, json.Unmarshal, "json")
+	assert.True(t, gock.IsDone())
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 )
 
 // ContentEncoding is used to encode/decode content for transfer over the wire,
@@ -38,9 +39,9 @@ func buildAcceptEncodingHeader() string {
 // DecodeResponse will replace the response body with a decoding reader if needed.
 // Assumes the original body will be closed outside of this function.
 func DecodeResponse(resp *http.Response) error {
-	contentEncoding := resp.Header.Get("content-encoding")
+	contentEncoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("content-encoding")))
 
-	if contentEncoding == "" {
+	if contentEncoding == "" || contentEncoding == "identity" {
 		// Nothing to do!
 		return nil
 	}
@@ -51,7 +52,7 @@ func DecodeResponse(resp *http.Response) error {
 		return fmt.Errorf("unsupported content-encoding %s", contentEncoding)
 	}
 
-	LogDebug("Decoding response from %s", contentEncoding)
+	HTTPLog.Log(LevelRequest, "Decoding response from %s", contentEncoding)
 
 	reader, err := encoding.Reader(resp.Body)
 	if err != nil {
@@ -60,6 +61,11 @@ func DecodeResponse(resp *http.Response) error {
 
 	resp.Body = io.NopCloser(reader)
 
+	// The content length is for the encoded bytes on the wire, so the decoded
+	// length is unknown until the body is read.
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
 	return nil
 }
 
@@ -86,3 +92,13 @@ type BrotliEncoding struct{}
 func (b BrotliEncoding) Reader(stream io.Reader) (io.Reader, error) {
 	return io.Reader(brotli.NewReader(stream)), nil
 }
+
+// ZstdEncoding supports RFC 8878 Zstandard content encoding.
+type ZstdEncoding struct{}
+
+// Reader returns a new reader for the stream that removes the zstd encoding.
+func (z ZstdEncoding) Reader(stream io.Reader) (io.Reader, error) {
+	// Decode synchronously so no goroutines are left running if the body is
+	// not fully read.
+	return zstd.NewReader(stream, zstd.WithDecoderConcurrency(1))
+}
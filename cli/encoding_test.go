@@ -6,10 +6,15 @@ import (
 	"compress/gzip"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func gzipEnc(data string) []byte {
@@ -28,6 +33,14 @@ func deflateEnc(data string) []byte {
 	return b.Bytes()
 }
 
+func zstdEnc(data string) []byte {
+	b := bytes.NewBuffer(nil)
+	w, _ := zstd.NewWriter(b)
+	w.Write([]byte(data))
+	w.Close()
+	return b.Bytes()
+}
+
 func brEnc(data string) []byte {
 	b := bytes.NewBuffer(nil)
 	w := brotli.NewWriter(b)
@@ -45,6 +58,8 @@ var encodingTests = []struct {
 	{"gzip", "gzip", gzipEnc("hello world")},
 	{"deflate", "deflate", deflateEnc("hello world")},
 	{"brotli", "br", brEnc("hello world")},
+	{"zstd", "zstd", zstdEnc("hello world")},
+	{"identity", "identity", []byte("hello world")},
 }
 
 func TestEncodings(parent *testing.T) {
@@ -66,3 +81,48 @@ func TestEncodings(parent *testing.T) {
 		})
 	}
 }
+
+func TestEncodedResponses(t *testing.T) {
+	fixtures := map[string]string{
+		"gzip": "body.json.gz",
+		"br":   "body.json.br",
+		"zstd": "body.json.zst",
+	}
+
+	for encoding, filename := range fixtures {
+		t.Run(encoding, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "encoding", filename))
+			require.NoError(t, err)
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Contains(t, r.Header.Get("Accept-Encoding"), encoding)
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", encoding)
+				w.Write(data)
+			}))
+			defer ts.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+			resp, err := GetParsedResponse(req)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{
+				"items": []any{
+					map[string]any{"id": 1.0, "name": "one"},
+					map[string]any{"id": 2.0, "name": "two"},
+				},
+				"encoded": true,
+			}, resp.Body)
+		})
+	}
+}
+
+func TestDecodedLength(t *testing.T) {
+	resp := &http.Response{
+		Header:        http.Header{"Content-Encoding": []string{"zstd"}},
+		ContentLength: 20,
+		Body:          io.NopCloser(bytes.NewReader(zstdEnc("hello world"))),
+	}
+
+	require.NoError(t, DecodeResponse(resp))
+	assert.Equal(t, int64(-1), resp.ContentLength)
+}
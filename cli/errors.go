@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ErrHTTP is returned when a server responds with an error status, e.g. by
+// `GetParsedResponse` when using `WithStatusError()`. Use `errors.As` to get
+// the status and parsed body, or `errors.Is` with an `ErrHTTP` target to
+// check for a specific status, where a zero status matches any.
+type ErrHTTP struct {
+	// Status is the HTTP status code, 400 or above.
+	Status int
+
+	// Body is the parsed response body.
+	Body any
+
+	// URL of the request which failed.
+	URL string
+
+	// Message replaces the default description, e.g. to say what was being
+	// fetched.
+	Message string
+}
+
+func (e *ErrHTTP) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("server responded with %d", e.Status)
+}
+
+// Is matches targets which are an `ErrHTTP` with the same or no status.
+func (e *ErrHTTP) Is(target error) bool {
+	t, ok := target.(*ErrHTTP)
+	return ok && (t.Status == 0 || t.Status == e.Status)
+}
+
+// Temporary reports whether sending the request again later may work, i.e.
+// for timeouts, rate limits, and server errors.
+func (e *ErrHTTP) Temporary() bool {
+	return e.Status == http.StatusRequestTimeout || e.Status == http.StatusTooManyRequests || e.Status >= http.StatusInternalServerError
+}
+
+// Conflict reports whether the request conflicts with the current state of
+// the resource, e.g. it was changed or locked by someone else.
+func (e *ErrHTTP) Conflict() bool {
+	return e.Status == http.StatusConflict || e.Status == http.StatusPreconditionFailed || e.Status == http.StatusLocked
+}
+
+// StatusError returns an `ErrHTTP` for responses with an error status, or
+// nil for any other response.
+func StatusError(resp Response, url string) error {
+	if resp.Status < http.StatusBadRequest {
+		return nil
+	}
+	return &ErrHTTP{Status: resp.Status, Body: resp.Body, URL: url}
+}
+
+// ErrNetwork is returned when a request could not be sent or its response
+// could not be received, e.g. due to DNS, connection, or timeout errors,
+// after any retries. The message is the one of the underlying error.
+type ErrNetwork struct {
+	Err error
+}
+
+func (e *ErrNetwork) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ErrNetwork) Unwrap() error {
+	return e.Err
+}
+
+// Is matches any `ErrNetwork` target.
+func (e *ErrNetwork) Is(target error) bool {
+	_, ok := target.(*ErrNetwork)
+	return ok
+}
+
+// ErrDecode is returned when a response body can't be decoded, e.g. due to
+// an unsupported or corrupt content encoding.
+type ErrDecode struct {
+	Err error
+}
+
+func (e *ErrDecode) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ErrDecode) Unwrap() error {
+	return e.Err
+}
+
+// Is matches any `ErrDecode` target.
+func (e *ErrDecode) Is(target error) bool {
+	_, ok := target.(*ErrDecode)
+	return ok
+}
+
+// ErrAuth is returned when auth can't be applied to a request, e.g. because
+// the profile is invalid or getting a token failed.
+type ErrAuth struct {
+	// Name of the auth type, like `oauth-client-credentials`.
+	Name string
+
+	Err error
+}
+
+func (e *ErrAuth) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ErrAuth) Unwrap() error {
+	return e.Err
+}
+
+// Is matches any `ErrAuth` target.
+func (e *ErrAuth) Is(target error) bool {
+	_, ok := target.(*ErrAuth)
+	return ok
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestErrHTTP(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &ErrHTTP{Status: http.StatusConflict})
+
+	assert.True(t, errors.Is(err, &ErrHTTP{}))
+	assert.True(t, errors.Is(err, &ErrHTTP{Status: http.StatusConflict}))
+	assert.False(t, errors.Is(err, &ErrHTTP{Status: http.StatusNotFound}))
+	assert.False(t, errors.Is(err, &ErrNetwork{}))
+
+	var httpErr *ErrHTTP
+	require.True(t, errors.As(err, &httpErr))
+	assert.True(t, httpErr.Conflict())
+	assert.False(t, httpErr.Temporary())
+	assert.Equal(t, "wrapped: server responded with 409", err.Error())
+
+	assert.True(t, (&ErrHTTP{Status: http.StatusTooManyRequests}).Temporary())
+	assert.True(t, (&ErrHTTP{Status: http.StatusBadGateway}).Temporary())
+	assert.Equal(t, "error fetching x", (&ErrHTTP{Status: 500, Message: "error fetching x"}).Error())
+
+	assert.Nil(t, StatusError(Response{Status: http.StatusOK}, ""))
+}
+
+func TestStatusErrorOption(t *testing.T) {
+	defer gock.Off()
+	reset(false)
+
+	gock.New("http://example.com").
+		Get("/missing").
+		Times(2).
+		Reply(http.StatusNotFound).
+		JSON(map[string]any{"detail": "no such item"})
+
+	// Without the option the status is only in the response.
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/missing", nil)
+	resp, err := GetParsedResponse(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.Status)
+
+	req, _ = http.NewRequest(http.MethodGet, "http://example.com/missing", nil)
+	resp, err = GetParsedResponse(req, WithStatusError())
+	assert.Equal(t, http.StatusNotFound, resp.Status)
+
+	var httpErr *ErrHTTP
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusNotFound, httpErr.Status)
+	assert.Equal(t, "http://example.com/missing", httpErr.URL)
+	assert.Equal(t, map[string]any{"detail": "no such item"}, httpErr.Body)
+}
+
+func TestErrNetwork(t *testing.T) {
+	reset(false)
+
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	_, err := GetParsedResponse(req)
+	assert.True(t, errors.Is(err, &ErrNetwork{}))
+	assert.Contains(t, err.Error(), "connection refused")
+
+	// The cause is still available, e.g. to check for cancellation.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	_, err = GetParsedResponse(req)
+	assert.True(t, errors.Is(err, &ErrNetwork{}))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestErrDecode(t *testing.T) {
+	defer gock.Off()
+	reset(false)
+
+	gock.New("http://example.com").
+		Get("/").
+		Reply(http.StatusOK).
+		SetHeader("Content-Encoding", "unknown").
+		BodyString("abc")
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	_, err := GetParsedResponse(req)
+	assert.True(t, errors.Is(err, &ErrDecode{}))
+	assert.EqualError(t, err, "unsupported content-encoding unknown")
+}
+
+func TestErrAuth(t *testing.T) {
+	reset(false)
+	t.Setenv("SHELL", "/bin/sh")
+
+	configs["auth-err"] = &APIConfig{
+		name: "auth-err",
+		Base: "http://auth-err.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {Auth: &APIAuth{Name: "external-token", Params: map[string]string{"command": "exit 3"}}},
+		},
+	}
+	defer delete(configs, "auth-err")
+
+	req, _ := http.NewRequest(http.MethodGet, "http://auth-err.example.com/", nil)
+	_, err := MakeRequest(req)
+
+	var authErr *ErrAuth
+	require.True(t, errors.As(err, &authErr), err)
+	assert.Equal(t, "external-token", authErr.Name)
+	assert.EqualError(t, err, "auth command `exit 3` failed with exit code 3")
+}
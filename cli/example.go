@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/danielgtaylor/shorthand/v2"
+	"github.com/gosimple/slug"
+	"github.com/spf13/cobra"
+)
+
+// shellSafe matches strings which can be pasted into a shell without quotes.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellArg returns a value as a single shell argument, only quoting it when
+// needed to keep examples readable.
+func shellArg(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return shellQuote(s)
+}
+
+// paramExample returns an example value for a parameter, falling back to a
+// placeholder for its type.
+func paramExample(p *Param) string {
+	value := p.Example
+	if value == nil {
+		value = p.Default
+	}
+
+	if value == nil {
+		typ := strings.TrimSuffix(strings.TrimPrefix(p.Type, "array["), "]")
+		value = (&Schema{Type: []string{typ}}).GenExample()
+		if value == nil {
+			value = "string"
+		}
+	}
+
+	if items, ok := value.([]any); ok {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = fmt.Sprintf("%v", item)
+		}
+		return strings.Join(parts, ",")
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+// findOperation returns the operation with the given command name, operation
+// name, or alias.
+func findOperation(api API, name string) (Operation, bool) {
+	for _, op := range api.Operations {
+		if slug.Make(op.Name) == name || op.Name == name {
+			return op, true
+		}
+		for _, alias := range op.Aliases {
+			if alias == name {
+				return op, true
+			}
+		}
+	}
+	return Operation{}, false
+}
+
+// OperationExample generates an example invocation of an operation in the
+// given format, one of `shorthand`, `json`, or `curl`. Required parameters
+// are filled in and the request body is generated from the body schema.
+func OperationExample(apiName string, op Operation, format string) (string, error) {
+	pathValues := []string{}
+	for _, p := range op.PathParams {
+		pathValues = append(pathValues, paramExample(p))
+	}
+
+	var body any
+	if op.BodyMediaType != "" {
+		body = op.BodySchema.GenExample()
+	}
+
+	switch format {
+	case "shorthand", "json":
+		args := []string{Root.CommandPath(), apiName, slug.Make(op.Name)}
+		for _, v := range pathValues {
+			args = append(args, shellArg(v))
+		}
+		for _, params := range [][]*Param{op.QueryParams, op.HeaderParams} {
+			for _, p := range params {
+				if p.Required {
+					args = append(args, "--"+p.OptionName(), shellArg(paramExample(p)))
+				}
+			}
+		}
+
+		if body == nil {
+			return strings.Join(args, " "), nil
+		}
+
+		if format == "json" {
+			encoded, err := json.MarshalIndent(body, "", "  ")
+			if err != nil {
+				return "", err
+			}
+			return strings.Join(args, " ") + " <<'EOF'\n" + string(encoded) + "\nEOF", nil
+		}
+
+		if m, ok := body.(map[string]any); ok {
+			args = append(args, shellArg(shorthand.MarshalCLI(m)))
+		} else {
+			encoded, err := json.Marshal(body)
+			if err != nil {
+				return "", err
+			}
+			args = append(args, shellArg(string(encoded)))
+		}
+		return strings.Join(args, " "), nil
+	case "curl":
+		uri := op.URITemplate
+		for i, p := range op.PathParams {
+			uri = strings.Replace(uri, "{"+p.Name+"}", url.PathEscape(pathValues[i]), 1)
+		}
+
+		query := url.Values{}
+		for _, p := range op.QueryParams {
+			if p.Required {
+				query.Add(p.Name, paramExample(p))
+			}
+		}
+		if encoded := query.Encode(); encoded != "" {
+			if strings.Contains(uri, "?") {
+				uri += "&" + encoded
+			} else {
+				uri += "?" + encoded
+			}
+		}
+
+		var reader io.Reader
+		if body != nil {
+			encoded, err := marshalBody(op.BodyMediaType, body)
+			if err != nil {
+				return "", err
+			}
+			reader = strings.NewReader(encoded)
+		}
+
+		req, err := http.NewRequest(op.Method, uri, reader)
+		if err != nil {
+			return "", err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", op.BodyMediaType)
+		}
+		for _, p := range op.HeaderParams {
+			if p.Required {
+				req.Header.Add(p.Name, paramExample(p))
+			}
+		}
+
+		return CurlCommand(req, false)
+	}
+
+	return "", fmt.Errorf("unknown example format %s, expected one of [shorthand, json, curl]", format)
+}
+
+// initExampleCommand registers the `api example` command.
+func initExampleCommand() {
+	var format *string
+
+	example := &cobra.Command{
+		Use:   "example short-name operation",
+		Short: "Generate an example request",
+		Long:  "Generate a ready-to-edit example invocation of an API operation. Required parameters are filled in with examples or placeholders and the request body is generated from the operation's schema.",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if configs[args[0]] == nil {
+				panic("API " + args[0] + " not found")
+			}
+
+			// Load into a separate command tree so the operation commands don't
+			// get registered on the root command.
+			api, err := Load(fixAddress(args[0]), &cobra.Command{})
+			if err != nil {
+				panic(err)
+			}
+
+			op, ok := findOperation(api, args[1])
+			if !ok {
+				panic("operation " + args[1] + " not found in API " + args[0])
+			}
+
+			out, err := OperationExample(args[0], op, *format)
+			if err != nil {
+				panic(err)
+			}
+
+			fmt.Fprintln(Stdout, out)
+		},
+	}
+	format = example.Flags().String("format", "shorthand", "Example format [shorthand, json, curl]")
+
+	apiCommand.AddCommand(example)
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var exampleOperation = Operation{
+	Name:        "create-item",
+	Method:      http.MethodPut,
+	URITemplate: "https://api.example.com/items/{item-id}",
+	PathParams: []*Param{
+		{Type: "string", Name: "item-id", Required: true},
+	},
+	QueryParams: []*Param{
+		{Type: "integer", Name: "version", Required: true},
+		{Type: "string", Name: "optional"},
+	},
+	HeaderParams: []*Param{
+		{Type: "string", Name: "X-Request-Id", Required: true, Example: "abc123"},
+	},
+	BodyMediaType: "application/json",
+	BodySchema: &Schema{
+		Type:     []string{"object"},
+		Required: []string{"id", "name", "kind", "tags"},
+		Properties: map[string]*Schema{
+			"id":    {Type: []string{"string"}, ReadOnly: true},
+			"name":  {Type: []string{"string"}, Example: "Fido"},
+			"kind":  {Type: []string{"string"}, Enum: []any{"dog", "cat"}},
+			"tags":  {Type: []string{"array"}, Items: &Schema{Type: []string{"string"}}},
+			"notes": {Type: []string{"string"}},
+		},
+	},
+}
+
+func TestOperationExample(t *testing.T) {
+	reset(false)
+
+	out, err := OperationExample("my-api", exampleOperation, "shorthand")
+	require.NoError(t, err)
+	assert.Equal(t, Root.CommandPath()+" my-api create-item string --version 1 --x-request-id abc123 'kind: dog, name: Fido, tags: [string]'", out)
+
+	out, err = OperationExample("my-api", exampleOperation, "json")
+	require.NoError(t, err)
+	assert.Equal(t, Root.CommandPath()+` my-api create-item string --version 1 --x-request-id abc123 <<'EOF'
+{
+  "kind": "dog",
+  "name": "Fido",
+  "tags": [
+    "string"
+  ]
+}
+EOF`, out)
+
+	out, err = OperationExample("my-api", exampleOperation, "curl")
+	require.NoError(t, err)
+	assert.Equal(t, `curl -X PUT 'https://api.example.com/items/string?version=1' \
+  -H 'Content-Type: application/json' \
+  -H 'X-Request-Id: abc123' \
+  --data-binary '{"kind":"dog","name":"Fido","tags":["string"]}'`, out)
+
+	_, err = OperationExample("my-api", exampleOperation, "bad")
+	assert.Error(t, err)
+}
+
+func TestFindOperation(t *testing.T) {
+	api := API{Operations: []Operation{exampleOperation}}
+
+	op, ok := findOperation(api, "create-item")
+	assert.True(t, ok)
+	assert.Equal(t, "create-item", op.Name)
+
+	_, ok = findOperation(api, "missing")
+	assert.False(t, ok)
+}
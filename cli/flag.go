@@ -1,10 +1,13 @@
 package cli
 
 import (
+	"encoding/csv"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -62,3 +65,65 @@ func AddGlobalFlag(name, short, description string, defaultValue interface{}, mu
 
 	viper.BindPFlag(name, flags.Lookup(name))
 }
+
+func init() {
+	// Runs for the executed command after its flags are parsed.
+	cobra.OnInitialize(func() {
+		if Root != nil {
+			visitFlags(Root, restoreSliceDefault)
+		}
+	})
+}
+
+// sliceDefaults parses the default value of a slice flag like `[a,b]`.
+func sliceDefaults(def string) []string {
+	values := []string{}
+	if def = strings.TrimSuffix(strings.TrimPrefix(def, "["), "]"); def != "" {
+		if parsed, err := csv.NewReader(strings.NewReader(def)).Read(); err == nil {
+			values = parsed
+		}
+	}
+	return values
+}
+
+// resetFlag restores a flag which was set on the commandline to its default
+// value. Once set, pflag appends any further values of a slice flag instead
+// of replacing its default, so slices are emptied instead and their defaults
+// restored after parsing by `restoreSliceDefault` unless they are set again.
+func resetFlag(f *pflag.Flag) {
+	if !f.Changed {
+		return
+	}
+
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		sv.Replace([]string{})
+	} else {
+		f.Value.Set(f.DefValue)
+	}
+	f.Changed = false
+}
+
+// restoreSliceDefault sets a slice flag which wasn't given on the commandline
+// back to its default after it was emptied by `resetFlag`.
+func restoreSliceDefault(f *pflag.Flag) {
+	if sv, ok := f.Value.(pflag.SliceValue); ok && !f.Changed {
+		sv.Replace(sliceDefaults(f.DefValue))
+	}
+}
+
+// visitFlags calls fn for every flag of a command and its subcommands.
+func visitFlags(cmd *cobra.Command, fn func(*pflag.Flag)) {
+	cmd.Flags().VisitAll(fn)
+	cmd.PersistentFlags().VisitAll(fn)
+	for _, sub := range cmd.Commands() {
+		visitFlags(sub, fn)
+	}
+}
+
+// resetFlags restores the flags of a command and all its subcommands to their
+// defaults. Cobra keeps parsed values in the commands, so without this a flag
+// given to one invocation would leak into the next one when the CLI is run
+// several times in the same process, e.g. when embedded or in tests.
+func resetFlags(cmd *cobra.Command) {
+	visitFlags(cmd, resetFlag)
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResetFlags(t *testing.T) {
+	parent := &cobra.Command{Use: "parent"}
+	parent.PersistentFlags().Bool("verbose", false, "")
+	cmd := &cobra.Command{Use: "cmd"}
+	cmd.Flags().Bool("remote", false, "")
+	cmd.Flags().String("name", "def", "")
+	cmd.Flags().StringSlice("tag", []string{"a", "b"}, "")
+	cmd.Flags().StringArray("header", nil, "")
+	parent.AddCommand(cmd)
+
+	assert.NoError(t, parent.PersistentFlags().Parse([]string{"--verbose"}))
+	assert.NoError(t, cmd.Flags().Parse([]string{"--remote", "--name", "x", "--tag", "c", "--header", "h"}))
+
+	resetFlags(parent)
+
+	verbose, _ := parent.PersistentFlags().GetBool("verbose")
+	remote, _ := cmd.Flags().GetBool("remote")
+	name, _ := cmd.Flags().GetString("name")
+	headers, _ := cmd.Flags().GetStringArray("header")
+	assert.False(t, verbose)
+	assert.False(t, remote)
+	assert.False(t, cmd.Flags().Changed("remote"))
+	assert.Equal(t, "def", name)
+	assert.Empty(t, headers)
+
+	var tags []string
+
+	// Slices are emptied so that setting them again replaces the default
+	// rather than appending to it, and the default is restored otherwise.
+	assert.NoError(t, cmd.Flags().Parse([]string{"--tag", "d"}))
+	visitFlags(parent, restoreSliceDefault)
+	tags, _ = cmd.Flags().GetStringSlice("tag")
+	assert.Equal(t, []string{"d"}, tags)
+
+	resetFlags(parent)
+	assert.NoError(t, cmd.Flags().Parse([]string{}))
+	visitFlags(parent, restoreSliceDefault)
+	tags, _ = cmd.Flags().GetStringSlice("tag")
+	assert.Equal(t, []string{"a", "b"}, tags)
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/maps"
+)
+
+// followLinks follows a chain of named link relations starting from a parsed
+// response, e.g. `item,author`, and returns the final response. A relation
+// ending in `*` like `up*` is followed for as long as it is present. Links
+// which lead back to an already visited URL result in an error, as does
+// going past `maxHops` requests. Error responses end the chain early so they
+// can be shown to the user.
+func followLinks(start *url.URL, parsed Response, rels []string, maxHops int) (Response, error) {
+	visited := map[string]bool{start.String(): true}
+	hops := 0
+
+	for _, rel := range rels {
+		rel = strings.TrimSpace(rel)
+		repeat := strings.HasSuffix(rel, "*")
+		rel = strings.TrimSuffix(rel, "*")
+		if rel == "" {
+			continue
+		}
+
+		for {
+			if parsed.Status >= http.StatusBadRequest {
+				return parsed, nil
+			}
+
+			uri, ok := parsed.FirstLink(rel)
+			if !ok {
+				if repeat {
+					break
+				}
+				available := maps.Keys(parsed.Links)
+				sort.Strings(available)
+				if len(available) == 0 {
+					return Response{}, fmt.Errorf("no %s link found, the response has no links", rel)
+				}
+				return Response{}, fmt.Errorf("no %s link found, available links: %s", rel, strings.Join(available, ", "))
+			}
+
+			if visited[uri] {
+				return Response{}, fmt.Errorf("link loop detected: %s link leads back to %s", rel, uri)
+			}
+			visited[uri] = true
+
+			hops++
+			if maxHops > 0 && hops > maxHops {
+				return Response{}, fmt.Errorf("stopped following links after %s", pluralize(maxHops, "hop"))
+			}
+
+			HTTPLog.Debug("Following %s link (hop %d): %s", rel, hops, uri)
+			req, err := http.NewRequest(http.MethodGet, uri, nil)
+			if err != nil {
+				return Response{}, err
+			}
+
+			if parsed, err = GetParsedResponse(req); err != nil {
+				return Response{}, err
+			}
+			HTTPLog.Debug("Followed %s link to %s: %d %s", rel, uri, parsed.Status, http.StatusText(parsed.Status))
+
+			if !repeat {
+				break
+			}
+		}
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func followServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/things/1":
+			// Relative links resolve against the response URL.
+			w.Header().Set("Link", `<items/2>; rel="item"`)
+			w.Write([]byte(`{"id": 1}`))
+		case "/things/items/2":
+			w.Write([]byte(`{"id": 2, "_links": {"author": {"href": "/people/3"}}}`))
+		case "/people/3":
+			w.Write([]byte(`{"name": "Kari", "_links": {"up": {"href": "/people"}}}`))
+		case "/people":
+			w.Write([]byte(`{"_links": {"up": {"href": "/"}}}`))
+		case "/":
+			w.Write([]byte(`{"root": true}`))
+		case "/loop":
+			w.Header().Set("Link", `</loop2>; rel="next-thing"`)
+			w.Write([]byte(`{}`))
+		case "/loop2":
+			w.Header().Set("Link", `</loop>; rel="next-thing"`)
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestFollowLinks(t *testing.T) {
+	ts := followServer()
+	defer ts.Close()
+
+	expectJSON(t, "get "+ts.URL+"/things/1 --rsh-follow item,author", `{
+		"name": "Kari",
+		"_links": {"up": {"href": "/people"}}
+	}`)
+
+	// Repeated relations are followed until they run out.
+	expectJSON(t, "get "+ts.URL+"/things/1 --rsh-follow item,author,up*", `{
+		"root": true
+	}`)
+}
+
+func TestFollowLinksErrors(t *testing.T) {
+	ts := followServer()
+	defer ts.Close()
+
+	out := run("get " + ts.URL + "/things/1 --rsh-follow item,missing")
+	assert.Contains(t, out, "no missing link found, available links: author")
+
+	out = run("get " + ts.URL + "/loop --rsh-follow next-thing*")
+	assert.Contains(t, out, "link loop detected: next-thing link leads back to "+ts.URL+"/loop")
+
+	out = run("get " + ts.URL + "/things/1 --rsh-follow item,author,up* --rsh-max-hops 3")
+	assert.Contains(t, out, "stopped following links after 3 hops")
+}
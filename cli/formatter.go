@@ -1,28 +1,22 @@
 package cli
 
 import (
-	"bytes"
 	"encoding/base64"
 	"fmt"
-	"image/color"
 	"net/http"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
 
-	"github.com/alecthomas/chroma"
 	"github.com/alecthomas/chroma/quick"
-	"github.com/alecthomas/chroma/styles"
 	"github.com/charmbracelet/glamour/ansi"
 	"github.com/danielgtaylor/shorthand/v2"
 	"github.com/spf13/viper"
 	"golang.org/x/exp/maps"
-	"golang.org/x/term"
-
-	"github.com/eliukblau/pixterm/pkg/ansimage"
 )
 
 // DisplayRanges includes all viewable Unicode characters along with white
@@ -31,41 +25,6 @@ var DisplayRanges = []*unicode.RangeTable{
 	unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.White_Space,
 }
 
-func init() {
-	// Simple 256-color theme for JSON/YAML output in a terminal.
-	styles.Register(chroma.MustNewStyle("cli-dark", chroma.StyleEntries{
-		// Used for JSON/YAML/Readable
-		chroma.Comment:      "#9e9e9e",
-		chroma.Keyword:      "#ff5f87",
-		chroma.Punctuation:  "#9e9e9e",
-		chroma.NameTag:      "#5fafd7",
-		chroma.Number:       "#d78700",
-		chroma.String:       "#afd787",
-		chroma.StringSymbol: "italic #D6FFB7",
-		chroma.Date:         "#af87af",
-		chroma.NumberHex:    "#ffd7d7",
-
-		// Used for HTTP
-		chroma.Name:          "#5fafd7",
-		chroma.NameFunction:  "#ff5f87",
-		chroma.NameNamespace: "#b2b2b2",
-
-		// Used for Markdown & diffs
-		chroma.GenericHeading:    "#5fafd7",
-		chroma.GenericSubheading: "#5fafd7",
-		chroma.GenericEmph:       "italic #ffd7d7",
-		chroma.GenericStrong:     "bold #af87af",
-		chroma.GenericDeleted:    "#ff5f87",
-		chroma.GenericInserted:   "#afd787",
-		chroma.NameAttribute:     "underline",
-
-		// Used for matching `{`, `}, `[`, and `]` characters.
-		IndentLevel1: "#d78700",
-		IndentLevel2: "#af87af",
-		IndentLevel3: "#5fafd7",
-	}))
-}
-
 func boolPtr(b bool) *bool       { return &b }
 func stringPtr(s string) *string { return &s }
 func uintPtr(u uint) *uint       { return &u }
@@ -383,7 +342,7 @@ func Highlight(lexer string, data []byte) ([]byte, error) {
 	indentLevel = 0
 
 	sb := &strings.Builder{}
-	if err := quick.Highlight(sb, string(data), lexer, "terminal256", "cli-dark"); err != nil {
+	if err := quick.Highlight(sb, string(data), lexer, "terminal256", themeStyle); err != nil {
 		return nil, err
 	}
 	return []byte(sb.String()), nil
@@ -412,9 +371,39 @@ func NewDefaultFormatter(tty, color bool) *DefaultFormatter {
 	}
 }
 
+// reHeaderPath matches header lookups in filters like `headers.etag` or
+// `headers."Etag"`, but not nested fields like `body.headers.etag`.
+var reHeaderPath = regexp.MustCompile(`(^|[^\w.])headers\.(?:"([^"]*)"|([\w-]+))`)
+
+// headerFilter rewrites header names in a filter to match the response
+// headers, so that lookups are case-insensitive like HTTP headers.
+func headerFilter(filter string, headers map[string]any) string {
+	return reHeaderPath.ReplaceAllStringFunc(filter, func(match string) string {
+		m := reHeaderPath.FindStringSubmatch(match)
+		name := m[2] + m[3]
+		if _, ok := headers[name]; ok {
+			return match
+		}
+
+		for k := range headers {
+			if strings.EqualFold(k, name) {
+				if m[2] != "" {
+					return m[1] + `headers."` + k + `"`
+				}
+				return m[1] + "headers." + k
+			}
+		}
+		return match
+	})
+}
+
 // filterData filters the current response using shorthand query and returns the
 // result.
 func (f *DefaultFormatter) filterData(filter string, data map[string]any) (any, error) {
+	if headers, ok := data["headers"].(map[string]any); ok {
+		filter = headerFilter(filter, headers)
+	}
+
 	keys := maps.Keys(data)
 	sort.Strings(keys)
 	found := strings.HasPrefix(filter, "*") || strings.HasPrefix(filter, "..") || strings.HasPrefix(filter, "{")
@@ -438,7 +427,7 @@ func (f *DefaultFormatter) filterData(filter string, data map[string]any) (any,
 	}
 
 	opts := shorthand.GetOptions{}
-	if enableVerbose {
+	if verbosity >= LevelInfo {
 		opts.DebugLogger = LogDebug
 	}
 
@@ -537,23 +526,11 @@ func (f *DefaultFormatter) formatAuto(format string, resp Response) ([]byte, err
 		encoded = []byte(text)
 	}
 
-	ct := resp.Headers["Content-Type"]
-	if resp.Body != nil && (ct == "image/png" || ct == "image/jpeg" || ct == "image/webp" || ct == "image/gif") {
-		if b, ok := resp.Body.([]byte); ok {
-			// This is likely an image. Let's display it if we can! Get the window
-			// size, read and scale the image, and display it using unicode.
-			w, h, err := term.GetSize(0)
-			if err != nil {
-				// Default to standard terminal size
-				w, h = 80, 24
-			}
-
-			image, err := ansimage.NewScaledFromReader(bytes.NewReader(b), h*2, w*1, color.Transparent, ansimage.ScaleModeFit, ansimage.NoDithering)
-			if err == nil {
-				return append(encoded, f.nl([]byte(image.Render()))...), nil
-			} else {
-				LogWarning("Unable to display image: %v", err)
-			}
+	if b, ok := resp.Body.([]byte); ok && len(b) > 0 {
+		if _, ok := printable(b); !ok {
+			// Binary data would garble the terminal, so show a summary or image
+			// preview instead.
+			return append(encoded, f.nl(f.formatBinary(resp.Headers["Content-Type"], b))...), nil
 		}
 	}
 
@@ -595,6 +572,25 @@ func (f *DefaultFormatter) Format(resp Response) error {
 	outFormat := viper.GetString("rsh-output-format")
 	filter := viper.GetString("rsh-filter")
 
+	// Custom templates bypass the formatting below entirely. They render the
+	// body or the filtered result.
+	tmpl, err := outputTemplate()
+	if err != nil {
+		return err
+	}
+	if tmpl != nil {
+		var data any = resp.Body
+		if filter == "@" {
+			data = resp.Map()
+		} else if filter != "" && filter != "body" {
+			data, err = f.filterData(filter, resp.Map())
+			if err != nil {
+				return err
+			}
+		}
+		return renderTemplate(Stdout, tmpl, data)
+	}
+
 	// Special case: raw response output mode. The response wasn't decoded so we
 	// have a bunch of bytes and the user asked for raw output, so just write it.
 	// This enables completely bypassing decoding and file downloads.
@@ -649,10 +645,23 @@ func (f *DefaultFormatter) Format(resp Response) error {
 		}
 	}
 
+	// Long arrays & deep nesting are only elided for display in a terminal so
+	// that redirected output always contains the full body.
+	truncation := Truncation{}
+	if f.tty {
+		truncation = displayTruncation()
+	}
+	var truncated []string
+
 	if !handled {
 		if (f.tty && filter == "") || (outFormat == "readable" && (filter == "" || filter == "@")) {
+			resp.Body, truncated = truncation.Apply("body", resp.Body)
 			encoded, err = f.formatAuto(outFormat, resp)
+		} else if sm, ok := contentTypes[outFormat].ct.(StreamMarshaller); ok {
+			// Write large outputs as they are generated.
+			return sm.MarshalStream(Stdout, data)
 		} else {
+			data, truncated = truncation.Apply(filter, data)
 			encoded, err = MarshalShort(outFormat, true, data)
 			lexer = outFormat
 		}
@@ -677,6 +686,7 @@ func (f *DefaultFormatter) Format(resp Response) error {
 	}
 
 	Stdout.Write(encoded)
+	logTruncation(truncated)
 
 	return nil
 }
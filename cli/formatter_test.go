@@ -64,7 +64,7 @@ var formatterTests = []struct {
 			"Content-Type": "image/png",
 		},
 		body:   img,
-		result: []byte{0x20, 0x30, 0x20, 0xa, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x2d, 0x54, 0x79, 0x70, 0x65, 0x3a, 0x20, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x2f, 0x70, 0x6e, 0x67, 0xa, 0xa},
+		result: " 0 \nContent-Type: image/png\n\n" + string(binarySummary("image/png", img)),
 	},
 	{
 		name: "image-empty",
@@ -124,6 +124,23 @@ var formatterTests = []struct {
 ╚════╧════════════╝
 `,
 	},
+	{
+		name:   "csv",
+		format: "csv",
+		filter: "body",
+		body: []any{
+			map[string]any{"id": 1, "owner": map[string]any{"name": "a"}},
+			map[string]any{"id": 2, "owner": map[string]any{"name": "b, c"}},
+		},
+		result: "id,owner.name\r\n1,a\r\n2,\"b, c\"\r\n",
+	},
+	{
+		name:   "csv-not-array",
+		format: "csv",
+		filter: "body",
+		body:   map[string]any{"items": []any{}},
+		err:    "use a filter like",
+	},
 	{
 		name:   "raw-bytes",
 		tty:    true,
@@ -185,6 +202,14 @@ var formatterTests = []struct {
 		body:   map[string]any{"example": true},
 		result: "example: true\n",
 	},
+	{
+		name:    "header-case-insensitive",
+		format:  "json",
+		filter:  `{etag: headers.etag, type: headers."content-type", id: body.id}`,
+		headers: map[string]string{"Etag": "abc", "Content-Type": "application/json"},
+		body:    map[string]any{"id": 123, "headers": map[string]any{"etag": "nested"}},
+		result:  "{\n  \"etag\": \"abc\",\n  \"id\": 123,\n  \"type\": \"application/json\"\n}\n",
+	},
 	{
 		name:   "error-prefix",
 		filter: "boby.id", // should be body.id
@@ -199,6 +224,16 @@ var formatterTests = []struct {
 	},
 }
 
+func TestHeaderFilter(t *testing.T) {
+	headers := map[string]any{"Etag": "abc", "Content-Type": "application/json"}
+
+	assert.Equal(t, "headers.Etag", headerFilter("headers.etag", headers))
+	assert.Equal(t, `headers."Content-Type"`, headerFilter(`headers."content-type"`, headers))
+	assert.Equal(t, "{e: headers.Etag, t: headers.Content-Type}", headerFilter("{e: headers.ETAG, t: headers.content-type}", headers))
+	assert.Equal(t, "body.headers.etag", headerFilter("body.headers.etag", headers))
+	assert.Equal(t, "headers.missing", headerFilter("headers.missing", headers))
+}
+
 func TestFormatter(t *testing.T) {
 	for _, input := range formatterTests {
 		t.Run(input.name, func(t *testing.T) {
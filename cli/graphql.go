@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/danielgtaylor/shorthand/v2"
+)
+
+// graphQLRequest is the standard envelope for a GraphQL request sent over
+// HTTP, see https://graphql.org/learn/serving-over-http/.
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// graphQLQuery returns the query text, loading it from a file if the value
+// starts with `@`. An empty value reads the query from stdin if available.
+func graphQLQuery(value string) (string, error) {
+	if strings.HasPrefix(value, "@") {
+		b, err := os.ReadFile(strings.TrimPrefix(value, "@"))
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	if value == "" {
+		if info, err := Stdin.Stat(); err == nil && (info.Mode()&os.ModeCharDevice) == 0 {
+			b, err := io.ReadAll(Stdin)
+			if err != nil {
+				return "", err
+			}
+			value = string(b)
+		}
+	}
+
+	if strings.TrimSpace(value) == "" {
+		return "", fmt.Errorf("a query is required, pass one via --query or stdin")
+	}
+
+	return value, nil
+}
+
+// graphQLVariables parses `name=value` pairs into query variables. Values
+// which are valid JSON are decoded, so `id=123` is a number while `name=foo`
+// is a string.
+func graphQLVariables(vars []string) (map[string]any, error) {
+	if len(vars) == 0 {
+		return nil, nil
+	}
+
+	variables := map[string]any{}
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid variable %q, expected name=value", v)
+		}
+
+		var decoded any
+		if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+			decoded = value
+		}
+		variables[name] = decoded
+	}
+
+	return variables, nil
+}
+
+// GraphQL sends a query to a GraphQL endpoint and prints the result. Unless
+// `envelope` is set only the `data` is printed, and any `errors` are logged
+// to stderr, causing a non-zero exit.
+func GraphQL(addr, query, operationName string, vars []string, envelope bool) error {
+	q, err := graphQLQuery(query)
+	if err != nil {
+		return err
+	}
+
+	variables, err := graphQLVariables(vars)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(graphQLRequest{
+		Query:         q,
+		OperationName: operationName,
+		Variables:     variables,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The content type defaults to JSON when the request is prepared.
+	req, _ := http.NewRequest(http.MethodPost, fixAddress(addr), bytes.NewReader(body))
+
+	parsed, err := GetParsedResponse(req)
+	if err != nil {
+		return err
+	}
+
+	result, ok := parsed.Body.(map[string]any)
+	if envelope || !ok {
+		return formatResponse(parsed)
+	}
+
+	errs, _ := result["errors"].([]any)
+
+	if data, ok := result["data"]; ok && data != nil {
+		parsed.Body = data
+		if err := formatResponse(parsed); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range errs {
+		m, _ := e.(map[string]any)
+		msg := fmt.Sprintf("%v", e)
+		if m != nil && m["message"] != nil {
+			msg = fmt.Sprintf("%v", m["message"])
+			if path, ok := m["path"].([]any); ok && len(path) > 0 {
+				parts := make([]string, len(path))
+				for i, p := range path {
+					parts[i] = fmt.Sprintf("%v", p)
+				}
+				msg = strings.Join(parts, ".") + ": " + msg
+			}
+		}
+		LogError("%s", msg)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("GraphQL response contained %d error(s)", len(errs))
+	}
+
+	return nil
+}
+
+// formatResponse prints a parsed response using the configured formatter.
+func formatResponse(parsed Response) error {
+	if err := Formatter.Format(parsed); err != nil {
+		if e, ok := err.(shorthand.Error); ok {
+			return fmt.Errorf("%s", e.Pretty())
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQLVariables(t *testing.T) {
+	vars, err := graphQLVariables([]string{"id=123", "name=foo", `filter={"x":1}`})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"id":     123.0,
+		"name":   "foo",
+		"filter": map[string]any{"x": 1.0},
+	}, vars)
+
+	_, err = graphQLVariables([]string{"invalid"})
+	assert.Error(t, err)
+}
+
+func TestGraphQL(t *testing.T) {
+	var received graphQLRequest
+	var contentType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"user": {"name": "Alice"}}}`))
+	}))
+	defer ts.Close()
+
+	query := filepath.Join(t.TempDir(), "query.graphql")
+	require.NoError(t, os.WriteFile(query, []byte("query Get($id: ID!) { user(id: $id) { name } }"), 0600))
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		out := run("graphql -o json -f body " + ts.URL + " --query @" + query + " --var id=123 --operation-name Get")
+		assert.JSONEq(t, `{"user": {"name": "Alice"}}`, out)
+	})
+	expectExitCode(t, 0)
+
+	assert.Contains(t, contentType, "application/json")
+	assert.Equal(t, graphQLRequest{
+		Query:         "query Get($id: ID!) { user(id: $id) { name } }",
+		OperationName: "Get",
+		Variables:     map[string]any{"id": 123.0},
+	}, received)
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": null, "errors": [{"message": "not found", "path": ["user", 0]}]}`))
+	}))
+	defer ts.Close()
+
+	WithFakeStdin([]byte("{ user { name } }"), 0, func() {
+		out := run("graphql -o json " + ts.URL)
+		assert.Contains(t, out, "user.0: not found")
+		assert.Contains(t, out, "GraphQL response contained 1 error(s)")
+	})
+}
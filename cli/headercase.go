@@ -0,0 +1,62 @@
+package cli
+
+import "net/http"
+
+// setHeaderName records the casing of a header name as given by the user,
+// when it differs from the canonical form Go uses.
+func (c *requestConfig) setHeaderName(name string) {
+	key := http.CanonicalHeaderKey(name)
+	if key == name {
+		return
+	}
+	if c.headerNames == nil {
+		c.headerNames = map[string]string{}
+	}
+	if _, ok := c.headerNames[key]; !ok {
+		c.headerNames[key] = name
+	}
+}
+
+// headerCasingTransport sends headers using the casing given by the user
+// rather than the canonical form, as some gateways are picky about it. Only
+// HTTP/1.x preserves the casing on the wire since HTTP/2 and HTTP/3 always
+// use lowercase names.
+type headerCasingTransport struct {
+	names map[string]string
+	next  http.RoundTripper
+}
+
+// RoundTrip sends a copy of the request with the header names re-cased.
+func (t *headerCasingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(withHeaderCasing(req, t.names))
+}
+
+// withHeaderCasing returns a shallow copy of the request with the canonical
+// header names replaced by the given ones.
+func withHeaderCasing(req *http.Request, names map[string]string) *http.Request {
+	cased := req.Clone(req.Context())
+	for key, name := range names {
+		if values, ok := cased.Header[key]; ok {
+			delete(cased.Header, key)
+			cased.Header[name] = values
+		}
+	}
+	return cased
+}
+
+// headerCasingClient wraps a client to send headers with custom casing, if
+// any is needed.
+func headerCasingClient(client *http.Client, names map[string]string) *http.Client {
+	if len(names) == 0 {
+		return client
+	}
+
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	wrapped := *client
+	wrapped.Transport = &headerCasingTransport{names: names, next: next}
+	return &wrapped
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetHeaderName(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		headers  []string
+		expected map[string]string
+	}{
+		{"canonical", []string{"Content-Type", "X-Tenant"}, nil},
+		{"lowercase", []string{"x-tenant"}, map[string]string{"X-Tenant": "x-tenant"}},
+		{"preserved", []string{"X-API-Key"}, map[string]string{"X-Api-Key": "X-API-Key"}},
+		{"first wins", []string{"x-tenant", "X-TENANT"}, map[string]string{"X-Tenant": "x-tenant"}},
+		{"mixed", []string{"Accept", "x-a", "X-B"}, map[string]string{"X-A": "x-a"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &requestConfig{}
+			for _, h := range tc.headers {
+				conf.setHeaderName(h)
+			}
+			assert.Equal(t, tc.expected, conf.headerNames)
+		})
+	}
+}
+
+func TestWithHeaderCasing(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Add("X-Api-Key", "secret")
+	req.Header.Add("X-Tenant", "a")
+	req.Header.Add("X-Tenant", "b")
+	req.Header.Set("Accept", "application/json")
+
+	cased := withHeaderCasing(req, map[string]string{
+		"X-Api-Key": "X-API-Key",
+		"X-Tenant":  "x-tenant",
+		"X-Missing": "x-missing",
+	})
+
+	assert.Equal(t, http.Header{
+		"X-API-Key": {"secret"},
+		"x-tenant":  {"a", "b"},
+		"Accept":    {"application/json"},
+	}, cased.Header)
+
+	// The original request keeps its canonical names.
+	assert.Equal(t, "a", req.Header.Get("X-Tenant"))
+	_, ok := req.Header["x-tenant"]
+	assert.False(t, ok)
+}
+
+func TestHeaderCasingClient(t *testing.T) {
+	client := &http.Client{}
+	assert.Same(t, client, headerCasingClient(client, nil))
+	assert.IsType(t, &headerCasingTransport{}, headerCasingClient(client, map[string]string{"X-A": "x-a"}).Transport)
+	assert.Nil(t, client.Transport)
+}
+
+func TestHeaderCasingWire(t *testing.T) {
+	// Use a raw listener since Go's server canonicalizes header names.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	raw := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		lines := []string{}
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil || line == "\r\n" {
+				break
+			}
+			lines = append(lines, strings.TrimRight(line, "\r\n"))
+		}
+		raw <- strings.Join(lines, "\n")
+		conn.Write([]byte("HTTP/1.1 204 No Content\r\n\r\n"))
+	}()
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+"/", nil)
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("X-Canonical", "yes")
+
+	client := headerCasingClient(&http.Client{}, map[string]string{
+		"X-Api-Key": "X-API-Key",
+		"X-Tenant":  "x-tenant",
+	})
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	request := <-raw
+	assert.Contains(t, request, "\nX-API-Key: secret")
+	assert.Contains(t, request, "\nx-tenant: acme")
+	assert.Contains(t, request, "\nX-Canonical: yes")
+	assert.NotContains(t, request, "X-Api-Key")
+	assert.NotContains(t, request, "X-Tenant")
+}
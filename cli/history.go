@@ -0,0 +1,497 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// historyMaxBody is the largest request body that is stored in the history.
+// Larger bodies are left out and those requests can't be replayed.
+const historyMaxBody = 64 * 1024
+
+// historyMaxBytes caps the size of the history file. The oldest entries are
+// pruned once it grows past this size, regardless of `--rsh-history-max`.
+const historyMaxBytes = 5 * 1024 * 1024
+
+// HistoryEntry is a single request recorded in the history file.
+type HistoryEntry struct {
+	ID          int         `json:"id"`
+	Time        time.Time   `json:"time"`
+	API         string      `json:"api,omitempty"`
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	Headers     http.Header `json:"headers,omitempty"`
+	Body        string      `json:"body,omitempty"`
+	Status      int         `json:"status,omitempty"`
+	DurationMS  float64     `json:"duration_ms"`
+	Error       string      `json:"error,omitempty"`
+	BodyOmitted bool        `json:"body_omitted,omitempty"`
+
+	// BodyEncoding is set to `base64` when the body is not valid UTF-8.
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// historyEnabled returns whether requests should be recorded in the history.
+func historyEnabled() bool {
+	return viper.GetBool("rsh-history")
+}
+
+// historyFile returns the path to the history file.
+func historyFile() string {
+	return filepath.Join(viper.GetString("config-directory"), "history.jsonl")
+}
+
+// newHistoryEntry starts a history entry for a prepared request. Secrets are
+// redacted before anything is stored. The body is only recorded if it can
+// be read again without consuming the request body.
+func newHistoryEntry(apiName string, req *http.Request) *HistoryEntry {
+	entry := &HistoryEntry{
+		Time:    time.Now().UTC(),
+		API:     apiName,
+		Method:  req.Method,
+		URL:     redactURL(req),
+		Headers: redactHeaders(req.Header),
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return entry
+	}
+
+	if req.GetBody == nil || req.ContentLength > historyMaxBody {
+		entry.BodyOmitted = true
+		return entry
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		entry.BodyOmitted = true
+		return entry
+	}
+	defer body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(body, historyMaxBody+1))
+	if err != nil || len(b) > historyMaxBody {
+		entry.BodyOmitted = true
+		return entry
+	}
+
+	if utf8.Valid(b) {
+		entry.Body = string(b)
+	} else {
+		entry.BodyEncoding = "base64"
+		entry.Body = base64.StdEncoding.EncodeToString(b)
+	}
+
+	return entry
+}
+
+// body returns the decoded request body.
+func (e *HistoryEntry) body() ([]byte, error) {
+	if e.BodyEncoding == "base64" {
+		return base64.StdEncoding.DecodeString(e.Body)
+	}
+	return []byte(e.Body), nil
+}
+
+// historyMu guards reads & writes of the history file, e.g. while sending
+// requests in parallel.
+var historyMu sync.Mutex
+
+// loadHistory reads all entries from the history file, oldest first. A
+// missing file results in an empty history.
+func loadHistory(filename string) ([]*HistoryEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []*HistoryEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, historyMaxBytes)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Skip partially written or corrupt lines.
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// pruneHistory removes the oldest entries until there are at most `max` and
+// the encoded lines fit in `maxBytes`.
+func pruneHistory(lines [][]byte, max, maxBytes int) [][]byte {
+	if max > 0 && len(lines) > max {
+		lines = lines[len(lines)-max:]
+	}
+
+	size := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		size += len(lines[i])
+		if size > maxBytes {
+			return lines[i+1:]
+		}
+	}
+
+	return lines
+}
+
+// historyLockStale is how old a history lock file must be before it is
+// assumed to be left over from a crashed process and broken.
+const historyLockStale = 10 * time.Second
+
+// lockHistory takes a lock file next to the history file so that concurrent
+// restish processes don't lose entries, returning a function to release it.
+func lockHistory(filename string) (func(), error) {
+	lock := filename + ".lock"
+	deadline := time.Now().Add(historyLockStale)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > historyLockStale {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for the history lock %s", lock)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// historyIDs returns the IDs of the first and last entries in the history
+// file, or zero if there are none or they can't be parsed. Only the start and
+// end of the file are read.
+func historyIDs(f *os.File, size int64) (int, int) {
+	if size == 0 {
+		return 0, 0
+	}
+
+	var first, last HistoryEntry
+	if line, err := bufio.NewReader(io.NewSectionReader(f, 0, size)).ReadBytes('\n'); err == nil || err == io.EOF {
+		json.Unmarshal(line, &first)
+	}
+
+	// Read ever larger chunks from the end until the last line is complete.
+	for window := int64(64 * 1024); ; window *= 2 {
+		start := size - window
+		if start < 0 {
+			start = 0
+		}
+		buf := make([]byte, size-start)
+		if _, err := f.ReadAt(buf, start); err != nil && err != io.EOF {
+			return first.ID, 0
+		}
+		buf = bytes.TrimRight(buf, "\n")
+		if i := bytes.LastIndexByte(buf, '\n'); i != -1 || start == 0 {
+			json.Unmarshal(buf[i+1:], &last)
+			return first.ID, last.ID
+		}
+	}
+}
+
+// encodeHistory returns the JSON line for an entry.
+func encodeHistory(entry *HistoryEntry) ([]byte, error) {
+	// Keep redacted placeholders readable rather than escaping `<` & `>`.
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(entry); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// appendHistory assigns the next ID to the entry and appends it to the
+// history file. The file is only rewritten once it grows past its limits.
+func appendHistory(entry *HistoryEntry) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	filename := historyFile()
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	unlock, err := lockHistory(filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	first, last := historyIDs(f, info.Size())
+	entry.ID = last + 1
+	if first == 0 {
+		first = entry.ID
+	}
+	line, err := encodeHistory(entry)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	max := viper.GetInt("rsh-history-max")
+	corrupt := info.Size() > 0 && last == 0
+	if corrupt || (max > 0 && entry.ID-first+1 > max) || info.Size()+int64(len(line)) > historyMaxBytes {
+		f.Close()
+		return rewriteHistory(filename, entry, max)
+	}
+
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rewriteHistory adds the entry to the history file while pruning the oldest
+// entries. It prunes a bit more than needed so that the file isn't rewritten
+// again for every following request.
+func rewriteHistory(filename string, entry *HistoryEntry, max int) error {
+	entries, err := loadHistory(filename)
+	if err != nil {
+		return err
+	}
+
+	entry.ID = 1
+	if len(entries) > 0 {
+		entry.ID = entries[len(entries)-1].ID + 1
+	}
+	entries = append(entries, entry)
+
+	lines := [][]byte{}
+	for _, e := range entries {
+		line, err := encodeHistory(e)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+	}
+	lines = pruneHistory(lines, max-max/10, historyMaxBytes-historyMaxBytes/10)
+
+	// Write to a temporary file first so the history is never left half
+	// written.
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, bytes.Join(lines, nil), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// recordHistory finishes a history entry with the outcome of the request and
+// saves it. Failing to save the history never fails the request itself.
+func recordHistory(entry *HistoryEntry, resp *http.Response, err error, duration time.Duration) {
+	entry.DurationMS = float64(duration.Microseconds()) / 1000
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Status = resp.StatusCode
+	}
+
+	if err := appendHistory(entry); err != nil {
+		LogWarning("Unable to save request history: %v", err)
+	}
+}
+
+// printHistory lists the last `n` entries, optionally only for one API.
+func printHistory(entries []*HistoryEntry, n int, api string) {
+	filtered := []*HistoryEntry{}
+	for _, e := range entries {
+		if api == "" || e.API == api {
+			filtered = append(filtered, e)
+		}
+	}
+	if n > 0 && len(filtered) > n {
+		filtered = filtered[len(filtered)-n:]
+	}
+
+	for _, e := range filtered {
+		status := strconv.Itoa(e.Status)
+		if e.Error != "" {
+			status = "ERR"
+		}
+		duration := roundLatency(time.Duration(e.DurationMS * float64(time.Millisecond)))
+		fmt.Fprintf(Stdout, "%4d  %s  %-7s %-3s %9s  %s\n", e.ID, e.Time.Local().Format("2006-01-02 15:04:05"), e.Method, status, duration, e.URL)
+	}
+}
+
+// replayRequest rebuilds a request from a history entry. Redacted values are
+// left out so that auth and profile settings are applied again when the
+// request is sent.
+func replayRequest(entry *HistoryEntry) (*http.Request, error) {
+	if entry.BodyOmitted {
+		return nil, fmt.Errorf("request %d can't be replayed because its body was not stored", entry.ID)
+	}
+
+	u, err := url.Parse(entry.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.User != nil && isRedacted(u.User.Username()) {
+		u.User = nil
+	}
+	query := u.Query()
+	for k, values := range query {
+		for _, v := range values {
+			if isRedacted(v) {
+				query.Del(k)
+				break
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	var body io.Reader
+	if entry.Body != "" {
+		b, err := entry.body()
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(entry.Method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, values := range entry.Headers {
+		for _, v := range values {
+			if !isRedacted(v) {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+
+	return req, nil
+}
+
+// findHistory returns the entry with the given ID.
+func findHistory(id string) (*HistoryEntry, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(id, "#"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid history ID %q", id)
+	}
+
+	historyMu.Lock()
+	entries, err := loadHistory(historyFile())
+	historyMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.ID == n {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("request %d not found in history", n)
+}
+
+// initHistoryCommands registers the `history` commands.
+func initHistoryCommands() {
+	var count *int
+	var api *string
+
+	history := &cobra.Command{
+		GroupID: "generic",
+		Use:     "history",
+		Short:   "List recently sent requests",
+		Long:    "List recently sent requests with their ID, time, method, status, duration, and URL. Requests are only recorded when enabled via `--rsh-history` or `rsh-history: true` in the config file. Secrets in headers & query params are redacted before being stored.",
+		Example: fmt.Sprintf(`  # Show the last 50 requests to an API
+  $ %s history -n 50 --api my-api
+
+  # Send request 42 again
+  $ %s history replay 42`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			historyMu.Lock()
+			entries, err := loadHistory(historyFile())
+			historyMu.Unlock()
+			if err != nil {
+				panic(err)
+			}
+
+			if len(entries) == 0 && !historyEnabled() {
+				LogInfo("History is disabled, enable it with --rsh-history or `rsh-history: true` in the config file")
+			}
+
+			printHistory(entries, *count, *api)
+		},
+	}
+	count = history.Flags().IntP("count", "n", 20, "Number of requests to show, or 0 for all")
+	api = history.Flags().String("api", "", "Only show requests to this API short name")
+	Root.AddCommand(history)
+
+	history.AddCommand(&cobra.Command{
+		Use:   "replay id",
+		Short: "Send a request from the history again",
+		Long:  "Rebuild a request from the history, including its body, and send it again. Auth and other redacted values are applied again from the current config.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			entry, err := findHistory(args[0])
+			if err != nil {
+				panic(err)
+			}
+
+			req, err := replayRequest(entry)
+			if err != nil {
+				panic(err)
+			}
+
+			MakeRequestAndFormat(req)
+		},
+	})
+
+	history.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Remove all requests from the history",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			historyMu.Lock()
+			defer historyMu.Unlock()
+			if err := os.Remove(historyFile()); err != nil && !os.IsNotExist(err) {
+				panic(err)
+			}
+		},
+	})
+}
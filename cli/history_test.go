@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryReplay(t *testing.T) {
+	t.Setenv("TEST_CONFIG_DIR", t.TempDir())
+
+	bodies := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		assert.Equal(t, "abc123", r.Header.Get("Authorization"))
+		assert.Equal(t, "abc", r.Header.Get("X-Test"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer ts.Close()
+
+	run("post --rsh-history " + ts.URL + "/items?api_key=secret -H X-Test:abc -H Authorization:abc123 name: foo")
+
+	entries, err := loadHistory(historyFile())
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	entry := entries[0]
+	assert.Equal(t, 1, entry.ID)
+	assert.Equal(t, http.MethodPost, entry.Method)
+	assert.Equal(t, http.StatusOK, entry.Status)
+	assert.Equal(t, ts.URL+"/items?api_key="+redact("secret"), entry.URL)
+	assert.Equal(t, redact("abc123"), entry.Headers.Get("Authorization"))
+	assert.Equal(t, "abc", entry.Headers.Get("X-Test"))
+	assert.JSONEq(t, `{"name": "foo"}`, entry.Body)
+
+	out := run("history")
+	assert.Contains(t, out, "   1  ")
+	assert.Contains(t, out, "POST    200")
+	assert.Contains(t, out, ts.URL+"/items?api_key="+redact("secret"))
+
+	// Redacted values are dropped, so auth must be passed again.
+	run("history replay 1 --rsh-history -H Authorization:abc123")
+	require.Len(t, bodies, 2)
+	assert.Equal(t, bodies[0], bodies[1])
+
+	entries, err = loadHistory(historyFile())
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, 2, entries[1].ID)
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	t.Setenv("TEST_CONFIG_DIR", t.TempDir())
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	run("get " + ts.URL)
+
+	entries, err := loadHistory(historyFile())
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestHistoryPrune(t *testing.T) {
+	reset(false)
+	viper.Set("config-directory", t.TempDir())
+	viper.Set("rsh-history-max", 3)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, appendHistory(&HistoryEntry{Method: http.MethodGet, URL: "https://example.com/"}))
+	}
+
+	entries, err := loadHistory(historyFile())
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, 3, entries[0].ID)
+	assert.Equal(t, 5, entries[2].ID)
+
+	big := []byte(strings.Repeat("a", historyMaxBytes/2))
+	lines := pruneHistory([][]byte{big, big, big}, 0, historyMaxBytes)
+	assert.Len(t, lines, 2)
+}
+
+func TestHistoryLock(t *testing.T) {
+	reset(false)
+	viper.Set("config-directory", t.TempDir())
+
+	// Another process holds the lock, e.g. while pruning.
+	unlock, err := lockHistory(historyFile())
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- appendHistory(&HistoryEntry{Method: http.MethodGet, URL: "https://example.com/"})
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("history was written while locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	require.NoError(t, <-done)
+
+	entries, err := loadHistory(historyFile())
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestHistoryList(t *testing.T) {
+	reset(false)
+	capture := &strings.Builder{}
+	Stdout = capture
+
+	printHistory([]*HistoryEntry{
+		{ID: 1, API: "one", Method: http.MethodGet, URL: "https://one.example.com/", Status: 200},
+		{ID: 2, API: "two", Method: http.MethodGet, URL: "https://two.example.com/", Error: "timeout"},
+		{ID: 3, API: "one", Method: http.MethodPut, URL: "https://one.example.com/", Status: 204},
+	}, 1, "one")
+
+	out := capture.String()
+	assert.NotContains(t, out, "two.example.com")
+	assert.Contains(t, out, "   3  ")
+	assert.NotContains(t, out, "   1  ")
+}
+
+func TestHistoryBodyOmitted(t *testing.T) {
+	_, err := replayRequest(&HistoryEntry{ID: 5, Method: http.MethodPost, URL: "https://example.com/", BodyOmitted: true})
+	assert.ErrorContains(t, err, "body was not stored")
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	htmlTitle   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlIgnored = regexp.MustCompile(`(?is)<(head|script|style)[^>]*>.*?</(head|script|style)>`)
+	htmlBlock   = regexp.MustCompile(`(?i)<(br|/?(p|div|h[1-6]|li|tr|form|label|button))\b[^>]*>`)
+	htmlTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlSpace   = regexp.MustCompile(`[ \t\r\f\v]+`)
+)
+
+// UnexpectedHTMLError is returned when an HTML page was received in place of
+// structured data, e.g. a login or error page from a misconfigured gateway.
+type UnexpectedHTMLError struct {
+	URL    string
+	Status int
+	Title  string
+}
+
+func (e *UnexpectedHTMLError) Error() string {
+	page := "an HTML page"
+	if e.Title != "" {
+		page = fmt.Sprintf("an HTML page %q", e.Title)
+	}
+	return fmt.Sprintf("expected structured data but got %s from %s (%d %s), use --rsh-accept-any to show it anyway", page, e.URL, e.Status, http.StatusText(e.Status))
+}
+
+// expectsStructured returns true if the `Accept` header asks for structured
+// data like JSON without explicitly accepting HTML.
+func expectsStructured(accept string) bool {
+	structured := false
+	for _, part := range strings.Split(accept, ",") {
+		mt := strings.ToLower(strings.TrimSpace(strings.Split(part, ";")[0]))
+		switch {
+		case mt == "text/html" || mt == "application/xhtml+xml":
+			return false
+		case mt != "" && mt != "*/*" && !strings.HasPrefix(mt, "text/"):
+			structured = true
+		}
+	}
+	return structured
+}
+
+// isHTML returns true if the response is an HTML page, either as declared by
+// its content type or sniffed from plain text or untyped content.
+func isHTML(contentType string, body []byte) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch mt {
+	case "text/html", "application/xhtml+xml":
+		return true
+	case "", "text/plain":
+		return strings.HasPrefix(http.DetectContentType(body), "text/html")
+	}
+	return false
+}
+
+// htmlSummary returns the title and the first few lines of text from an
+// HTML page.
+func htmlSummary(body []byte) (string, []string) {
+	title := ""
+	if match := htmlTitle.FindSubmatch(body); match != nil {
+		title = strings.TrimSpace(htmlSpace.ReplaceAllString(html.UnescapeString(string(match[1])), " "))
+	}
+
+	text := htmlIgnored.ReplaceAll(body, nil)
+	text = htmlBlock.ReplaceAll(text, []byte("\n"))
+	text = htmlTag.ReplaceAll(text, nil)
+
+	lines := []string{}
+	for _, line := range strings.Split(html.UnescapeString(string(text)), "\n") {
+		line = strings.TrimSpace(htmlSpace.ReplaceAllString(line, " "))
+		if line == "" || line == title {
+			continue
+		}
+		if len(line) > 100 {
+			line = line[:97] + "..."
+		}
+		lines = append(lines, line)
+		if len(lines) == 3 {
+			break
+		}
+	}
+
+	return title, lines
+}
+
+// checkHTMLResponse returns an error if structured data was requested but an
+// HTML page came back instead, which is usually a login or error page from
+// something in between the client and the API. A warning with the page
+// title and first lines of text is logged to help figure out what happened.
+// Use `--rsh-accept-any` to skip the check.
+func checkHTMLResponse(req *http.Request, resp Response) error {
+	if viper.GetBool("rsh-accept-any") || !expectsStructured(req.Header.Get("Accept")) {
+		return nil
+	}
+
+	var body []byte
+	switch b := resp.Body.(type) {
+	case string:
+		body = []byte(b)
+	case []byte:
+		body = b
+	default:
+		return nil
+	}
+
+	if !isHTML(resp.Headers["Content-Type"], body) {
+		return nil
+	}
+
+	title, lines := htmlSummary(body)
+	details := ""
+	if title != "" {
+		details += "\n  Title: " + title
+	}
+	for _, line := range lines {
+		details += "\n  " + line
+	}
+	LogWarning("Got an HTML page instead of structured data, possibly a login or error page from a proxy or gateway:%s", details)
+
+	return &UnexpectedHTMLError{
+		URL:    req.URL.String(),
+		Status: resp.Status,
+		Title:  title,
+	}
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const loginPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Sign in &middot; Example</title>
+  <style>body { color: red; }</style>
+</head>
+<body>
+  <h1>Sign in &middot; Example</h1>
+  <form><label>Username</label><input name="user"><button>Continue</button></form>
+  <script>console.log("hello")</script>
+</body>
+</html>`
+
+func TestHTMLSummary(t *testing.T) {
+	title, lines := htmlSummary([]byte(loginPage))
+	assert.Equal(t, "Sign in · Example", title)
+	assert.Equal(t, []string{"Username", "Continue"}, lines)
+}
+
+func TestExpectsStructured(t *testing.T) {
+	reset(false)
+	assert.True(t, expectsStructured(buildAcceptHeader()))
+	assert.True(t, expectsStructured("application/json"))
+	assert.False(t, expectsStructured("text/html,application/json"))
+	assert.False(t, expectsStructured("*/*"))
+	assert.False(t, expectsStructured(""))
+}
+
+func TestHTMLResponse(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").Times(2).Reply(200).SetHeader("Content-Type", "text/html; charset=utf-8").BodyString(loginPage)
+
+	out := run("http://example.com/items")
+	assert.Contains(t, out, "WARN: Got an HTML page instead of structured data")
+	assert.Contains(t, out, "  Title: Sign in · Example\n  Username\n  Continue\n")
+	assert.Contains(t, out, `expected structured data but got an HTML page "Sign in · Example" from http://example.com/items (200 OK)`)
+	assert.NotContains(t, out, "<form>")
+
+	out = run("http://example.com/items --rsh-accept-any")
+	assert.NotContains(t, out, "WARN:")
+	assert.Contains(t, out, "<form>")
+}
+
+func TestHTMLResponseSniffed(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").Reply(502).SetHeader("Content-Type", "text/plain").BodyString(loginPage)
+
+	out := run("http://example.com/items")
+	assert.Contains(t, out, "(502 Bad Gateway)")
+}
+
+func TestHTMLResponseAccepted(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/page").Reply(200).SetHeader("Content-Type", "text/html").BodyString(loginPage)
+	gock.New("http://example.com").Get("/text").Reply(200).SetHeader("Content-Type", "text/plain").BodyString("OK")
+
+	out := run("http://example.com/page -H Accept:text/html")
+	assert.NotContains(t, out, "WARN:")
+	assert.Contains(t, out, "<form>")
+
+	out = run("http://example.com/text")
+	assert.NotContains(t, out, "WARN:")
+	assert.Contains(t, out, "OK")
+}
@@ -1,14 +1,19 @@
 package cli
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/danielgtaylor/shorthand/v2"
+	"github.com/spf13/viper"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -18,49 +23,274 @@ var Stdin interface {
 	io.Reader
 } = os.Stdin
 
-// GetBody returns the request body if one was passed either as shorthand
-// arguments or via stdin.
-func GetBody(mediaType string, args []string) (string, error) {
-	var body string
+// envRef matches environment variable references like `${TOKEN}` in
+// shorthand input, where `$${TOKEN}` escapes a literal `${TOKEN}`.
+var envRef = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
 
-	if info, err := Stdin.Stat(); err == nil {
-		if len(args) == 0 && (info.Mode()&os.ModeCharDevice) == 0 {
-			// There are no args but there is data on stdin. Just read it and
-			// pass it through as it may not be structured data we can parse or
-			// could be binary (e.g. file uploads).
-			b, err := io.ReadAll(Stdin)
-			if err != nil {
-				return "", err
+// envPlaceholder matches the placeholders which stand in for environment
+// variable references while the shorthand is being parsed.
+var envPlaceholder = regexp.MustCompile("\uE000([0-9]+)\uE001")
+
+// arrayInsert and deleteOp match the `items[2]+:` insert and the `field-:` or
+// `items[1]-:` delete operators, which are shorthand for `items[^2]:` and
+// `field: undefined` respectively.
+var (
+	arrayInsert = regexp.MustCompile(`\[(-?[0-9]+)\]\+(\s*):`)
+	deleteOp    = regexp.MustCompile(`([^\s,:{}\[])-(\s*):`)
+)
+
+// rewriteOps rewrites the insert and delete operators into their native
+// shorthand equivalents, leaving quoted strings untouched.
+func rewriteOps(expr string) string {
+	sb := strings.Builder{}
+	rewrite := func(segment string) {
+		segment = arrayInsert.ReplaceAllString(segment, "[^$1]$2:")
+		segment = deleteOp.ReplaceAllString(segment, "$1$2: undefined")
+		sb.WriteString(segment)
+	}
+
+	start := 0
+	quoted := false
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case quoted && expr[i] == '\\':
+			i++
+		case quoted && expr[i] == '"':
+			sb.WriteString(expr[start : i+1])
+			start = i + 1
+			quoted = false
+		case expr[i] == '"':
+			rewrite(expr[start:i])
+			start = i
+			quoted = true
+		}
+	}
+	if quoted {
+		sb.WriteString(expr[start:])
+	} else {
+		rewrite(expr[start:])
+	}
+
+	return sb.String()
+}
+
+// placeholderIndex returns the index of the reference a placeholder stands in
+// for.
+func placeholderIndex(placeholder string) int {
+	i, _ := strconv.Atoi(envPlaceholder.FindStringSubmatch(placeholder)[1])
+	return i
+}
+
+// parseShorthand parses shorthand arguments and applies them to an existing
+// value, if any. Environment variable references are swapped out for
+// placeholders before parsing and expanded into string values afterward, so
+// their contents are never interpreted as shorthand syntax and the contents
+// of files loaded via `@filename` are left untouched.
+func parseShorthand(args []string, options shorthand.ParseOptions, existing interface{}) (interface{}, error) {
+	refs := []string{}
+	expr := envRef.ReplaceAllStringFunc(strings.Join(args, " "), func(ref string) string {
+		refs = append(refs, ref)
+		return fmt.Sprintf("\uE000%d\uE001", len(refs)-1)
+	})
+
+	d := shorthand.NewDocument(options)
+	if err := d.Parse(rewriteOps(expr)); err != nil {
+		return nil, err
+	}
+
+	if len(refs) > 0 {
+		literal := func(placeholder string) string {
+			ref := refs[placeholderIndex(placeholder)]
+			if strings.HasPrefix(ref, "$$") {
+				return ref[1:]
 			}
-			return string(b), nil
+			return ref
 		}
+		expand := func(placeholder string) string {
+			ref := refs[placeholderIndex(placeholder)]
+			if strings.HasPrefix(ref, "$$") {
+				return ref[1:]
+			}
+			return os.Getenv(ref[2 : len(ref)-1])
+		}
+
+		// Values get the variable contents, while keys keep the reference as-is.
+		for i, op := range d.Operations {
+			d.Operations[i].Path = envPlaceholder.ReplaceAllStringFunc(op.Path, literal)
+			if s, ok := op.Value.(string); ok {
+				d.Operations[i].Value = envPlaceholder.ReplaceAllStringFunc(s, expand)
+			}
+		}
+	}
+
+	result, err := d.Apply(existing)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readDocument reads a JSON or YAML document from stdin for use as the base
+// document with `--rsh-edit-stdin`.
+func readDocument() (interface{}, error) {
+	if info, err := Stdin.Stat(); err != nil || (info.Mode()&os.ModeCharDevice) != 0 {
+		return nil, fmt.Errorf("--rsh-edit-stdin requires a document on stdin")
+	}
+
+	b, err := io.ReadAll(Stdin)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, fmt.Errorf("--rsh-edit-stdin requires a document on stdin")
 	}
 
-	input, _, err := shorthand.GetInput(args, shorthand.ParseOptions{
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("unable to parse stdin as JSON or YAML: %w", err)
+		}
+	}
+	return makeJSONSafe(doc), nil
+}
+
+// getInput returns the parsed shorthand input from the arguments. If there
+// are no arguments but data is available on stdin, then that data is returned
+// as-is instead since it may not be structured data we can parse or could be
+// binary (e.g. file uploads). With `--rsh-edit-stdin` stdin must instead be a
+// JSON or YAML document, which the arguments are applied to as a patch.
+func getInput(args []string) (interface{}, []byte, error) {
+	options := shorthand.ParseOptions{
 		EnableFileInput:       true,
 		EnableObjectDetection: true,
-	})
+	}
+
+	if viper.GetBool("rsh-edit-stdin") {
+		doc, err := readDocument()
+		if err != nil || len(args) == 0 {
+			return doc, nil, err
+		}
+		input, err := parseShorthand(args, options, doc)
+		if err == nil && readsStdin(input) {
+			err = fmt.Errorf("stdin can't be uploaded as a file with --rsh-edit-stdin")
+		}
+		return input, nil, err
+	}
+
+	piped := false
+	if info, err := Stdin.Stat(); err == nil {
+		piped = (info.Mode() & os.ModeCharDevice) == 0
+		if len(args) == 0 && piped {
+			b, err := io.ReadAll(Stdin)
+			return nil, b, err
+		}
+	}
+
+	if joined := strings.Join(args, " "); strings.Contains(joined, "@:") {
+		// Stdin is normally used as a template for the shorthand input, but if
+		// it is being uploaded as a file via `field@: -` then it must be left
+		// alone so it can be streamed.
+		if input, err := parseShorthand(args, options, nil); err == nil && readsStdin(input) {
+			return input, nil, nil
+		}
+	}
+
+	var template interface{}
+	if piped {
+		b, err := io.ReadAll(Stdin)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !utf8.Valid(b) {
+			return nil, nil, shorthand.ErrInvalidFile
+		}
+		template, err = shorthand.Unmarshal(string(b), shorthand.ParseOptions{EnableFileInput: true}, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(args) == 0 {
+		return template, nil, nil
+	}
+
+	input, err := parseShorthand(args, options, template)
+	return input, nil, err
+}
+
+// GetBody returns the request body if one was passed either as shorthand
+// arguments or via stdin.
+func GetBody(mediaType string, args []string) (string, error) {
+	input, raw, err := getInput(args)
+	if err != nil || raw != nil {
+		return string(raw), err
+	}
+
+	return marshalBody(mediaType, input)
+}
+
+// GetRequestBody works like `GetBody` but returns a reader suitable for use
+// with `http.NewRequest`, or nil if there is no body. If the media type is
+// `multipart/form-data` or any field is a file upload like `file@: photo.jpg`
+// then a streaming multipart body is returned instead.
+func GetRequestBody(mediaType string, args []string) (io.Reader, error) {
+	input, raw, err := getInput(args)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if input != nil {
-		if strings.Contains(mediaType, "json") {
-			marshalled, err := json.Marshal(input)
-			if err != nil {
-				return "", err
-			}
-			body = string(marshalled)
-		} else if strings.Contains(mediaType, "yaml") {
-			marshalled, err := yaml.Marshal(input)
-			if err != nil {
-				return "", err
-			}
-			body = string(marshalled)
-		} else {
-			return "", fmt.Errorf("not sure how to marshal %s", mediaType)
+	if raw != nil {
+		if len(raw) == 0 {
+			return nil, nil
+		}
+		return bytes.NewReader(raw), nil
+	}
+
+	if m, ok := input.(map[string]interface{}); ok && (strings.Contains(mediaType, "multipart/form-data") || hasFileFields(m)) {
+		mb, err := newMultipartBody(m)
+		if err != nil {
+			return nil, err
+		}
+		return mb, nil
+	}
+
+	body, err := marshalBody(mediaType, input)
+	if err != nil || body == "" {
+		return nil, err
+	}
+	return strings.NewReader(body), nil
+}
+
+// marshalBody marshals parsed shorthand input into the given media type.
+func marshalBody(mediaType string, input interface{}) (string, error) {
+	if input == nil {
+		return "", nil
+	}
+
+	if strings.Contains(mediaType, "json") {
+		marshalled, err := json.Marshal(input)
+		if err != nil {
+			return "", err
+		}
+		return string(marshalled), nil
+	} else if strings.Contains(mediaType, "yaml") {
+		marshalled, err := yaml.Marshal(input)
+		if err != nil {
+			return "", err
+		}
+		return string(marshalled), nil
+	} else if strings.Contains(mediaType, "toml") {
+		marshalled, err := TOML{}.Marshal(input)
+		if err != nil {
+			return "", err
 		}
+		return string(marshalled), nil
 	}
 
-	return body, nil
+	// Fall back to any other registered content type, e.g. CBOR.
+	marshalled, err := Marshal(mediaType, input)
+	if err != nil {
+		return "", fmt.Errorf("not sure how to marshal %s", mediaType)
+	}
+	return string(marshalled), nil
 }
@@ -1,11 +1,18 @@
 package cli
 
 import (
+	"io"
 	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"testing/fstest"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,3 +59,187 @@ func TestInputInvalidType(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestInputEnv(t *testing.T) {
+	t.Setenv("RSH_TEST_TOKEN", "a, b}")
+	t.Setenv("RSH_TEST_ID", "123")
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		body, err := GetBody("application/json", []string{`token: ${RSH_TEST_TOKEN}, id: item-${RSH_TEST_ID}, quoted: "${RSH_TEST_ID}", literal: $${RSH_TEST_ID}, ${RSH_TEST_ID}: 1`})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"token": "a, b}", "id": "item-123", "quoted": "123", "literal": "${RSH_TEST_ID}", "${RSH_TEST_ID}": 1}`, body)
+	})
+}
+
+func TestInputEnvTemplate(t *testing.T) {
+	t.Setenv("RSH_TEST_ID", "123")
+
+	WithFakeStdin([]byte(`{"id": "old", "script": "echo ${HOME}"}`), 0, func() {
+		body, err := GetBody("application/json", []string{"id: ${RSH_TEST_ID}"})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"id": "123", "script": "echo ${HOME}"}`, body)
+	})
+}
+
+func TestInputFiles(t *testing.T) {
+	t.Setenv("RSH_TEST_ID", "123")
+
+	dir := t.TempDir()
+	text := filepath.Join(dir, "script.sh")
+	os.WriteFile(text, []byte("echo ${RSH_TEST_ID}"), 0600)
+	structured := filepath.Join(dir, "item.json")
+	os.WriteFile(structured, []byte(`{"tags": ["a", "b"]}`), 0600)
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		body, err := GetBody("application/json", []string{"script: @" + text + ", item: @" + structured + `, handle: "@user"`})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"script": "echo ${RSH_TEST_ID}", "item": {"tags": ["a", "b"]}, "handle": "@user"}`, body)
+	})
+}
+
+func TestInputArrayOps(t *testing.T) {
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		body, err := GetBody("application/json", []string{`items: [a, b, c], items[]: d, items[1]+: x, items[3]-:, items[0]: z, note: "items[0]-: kept"`})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"items": ["z", "x", "b", "d"], "note": "items[0]-: kept"}`, body)
+	})
+}
+
+func TestInputArrayOpsTemplate(t *testing.T) {
+	WithFakeStdin([]byte(`{"tags": ["a", "b", "c"]}`), 0, func() {
+		body, err := GetBody("application/json", []string{"tags[0]-:, tags[-1]+: y, tags[]: z"})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"tags": ["b", "y", "c", "z"]}`, body)
+	})
+}
+
+func TestInputEditStdin(t *testing.T) {
+	viper.Set("rsh-edit-stdin", true)
+	defer viper.Set("rsh-edit-stdin", false)
+
+	WithFakeStdin([]byte("id: 1\nname: old\ntags: [a, b]\nextra: true\n"), 0, func() {
+		body, err := GetBody("application/json", []string{"name: updated, extra-:, tags[0]-:"})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"id": 1, "name": "updated", "tags": ["b"]}`, body)
+	})
+
+	WithFakeStdin([]byte(`{"id": 1}`), 0, func() {
+		body, err := GetBody("application/yaml", []string{})
+		assert.NoError(t, err)
+		assert.Equal(t, "id: 1\n", body)
+	})
+}
+
+func TestInputEditStdinErrors(t *testing.T) {
+	viper.Set("rsh-edit-stdin", true)
+	defer viper.Set("rsh-edit-stdin", false)
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		_, err := GetBody("application/json", []string{"name: updated"})
+		assert.ErrorContains(t, err, "requires a document on stdin")
+	})
+
+	WithFakeStdin([]byte("{not valid"), 0, func() {
+		_, err := GetBody("application/json", []string{"name: updated"})
+		assert.ErrorContains(t, err, "unable to parse stdin")
+	})
+
+	WithFakeStdin([]byte(`{"id": 1}`), 0, func() {
+		_, err := GetBody("application/json", []string{"file@: -"})
+		assert.Error(t, err)
+	})
+}
+
+func TestInputMultipart(t *testing.T) {
+	dir := t.TempDir()
+	photo := filepath.Join(dir, "photo.png")
+	os.WriteFile(photo, []byte("fake image"), 0600)
+
+	WithFakeStdin([]byte("from stdin"), fs.ModeCharDevice, func() {
+		body, err := GetRequestBody("application/json", []string{"name: hello, tags: [a, b], file@: " + photo + ", other@: -"})
+		assert.NoError(t, err)
+
+		mb := body.(*multipartBody)
+		defer mb.Close()
+
+		// Stdin has an unknown size, so chunked encoding must be used.
+		assert.EqualValues(t, -1, mb.length)
+
+		_, params, err := mime.ParseMediaType(mb.contentType)
+		assert.NoError(t, err)
+
+		parts := map[string]string{}
+		types := map[string]string{}
+		r := multipart.NewReader(mb, params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+			b, _ := io.ReadAll(p)
+			parts[p.FormName()+"|"+p.FileName()] = string(b)
+			types[p.FormName()] = p.Header.Get("Content-Type")
+		}
+
+		assert.Equal(t, map[string]string{
+			"file|photo.png": "fake image",
+			"name|":          "hello",
+			"other|stdin":    "from stdin",
+			"tags|":          `["a","b"]`,
+		}, parts)
+		assert.Equal(t, "image/png", types["file"])
+		assert.Equal(t, "text/plain; charset=utf-8", types["other"])
+		assert.Equal(t, "application/json", types["tags"])
+	})
+}
+
+func TestInputMultipartLength(t *testing.T) {
+	photo := filepath.Join(t.TempDir(), "photo.jpg")
+	os.WriteFile(photo, []byte("fake image"), 0600)
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		body, err := GetRequestBody("application/json", []string{"name: hello, file@: " + photo})
+		assert.NoError(t, err)
+
+		mb := body.(*multipartBody)
+		defer mb.Close()
+
+		b, err := io.ReadAll(mb)
+		assert.NoError(t, err)
+		assert.EqualValues(t, len(b), mb.length)
+	})
+}
+
+func TestInputMultipartMissingFile(t *testing.T) {
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		_, err := GetRequestBody("application/json", []string{"file@: /does/not/exist"})
+		assert.Error(t, err)
+	})
+}
+
+func TestMultipartUpload(t *testing.T) {
+	photo := filepath.Join(t.TempDir(), "photo.jpg")
+	os.WriteFile(photo, []byte("fake image"), 0600)
+
+	var contentLength int64
+	var name, file string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		name = r.FormValue("name")
+		if f, _, err := r.FormFile("file"); err == nil {
+			b, _ := io.ReadAll(f)
+			file = string(b)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		run("post " + ts.URL + "/upload name: hello, file@: " + photo)
+	})
+
+	assert.Greater(t, contentLength, int64(0))
+	assert.Equal(t, "hello", name)
+	assert.Equal(t, "fake image", file)
+}
@@ -3,7 +3,9 @@ package cli
 import (
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/AlecAivazis/survey/v2/terminal"
@@ -274,7 +276,7 @@ func askEditProfile(a asker, name string, profile *APIProfile) {
 		case strings.HasPrefix(choice, "Edit query param"):
 			q := strings.SplitN(choice, " ", 4)[3]
 			key := a.askInput("Query param name", q, true, "")
-			profile.Headers[key] = a.askInput("Query param value", profile.Query[key], false, "")
+			profile.Query[key] = a.askInput("Query param value", profile.Query[key], false, "")
 		case strings.HasPrefix(choice, "Delete query param"):
 			q := strings.SplitN(choice, " ", 4)[3]
 			if a.askConfirm("Are you sure you want to delete the "+q+" query param?", false, "") {
@@ -370,6 +372,27 @@ func askTLSConfig(a asker, config *APIConfig) {
 	}
 }
 
+func askLoader(a asker, config *APIConfig) {
+	def := config.Loader
+	if def == "" {
+		def = "auto"
+	}
+
+	choice := a.askSelect("API loader", append([]string{"auto"}, LoaderNames()...), def, "How to load the API description. Auto-detection tries each loader in turn, so pick one explicitly if the description could be loaded by more than one.")
+	if choice == "auto" {
+		choice = ""
+	}
+
+	if choice != config.Loader {
+		config.Loader = choice
+
+		// Force the API description to be loaded again with the new loader.
+		Cache.Set(config.name+".expires", time.Time{})
+		Cache.Set(config.name+".etag", "")
+		Cache.WriteConfig()
+	}
+}
+
 func askInitAPI(a asker, cmd *cobra.Command, args []string) {
 	var config *APIConfig = configs[args[0]]
 
@@ -412,7 +435,15 @@ func askInitAPI(a asker, cmd *cobra.Command, args []string) {
 			options = append(options, "Edit profile "+k)
 		}
 
-		if (config.TLS != nil) && (*config.TLS != TLSConfig{}) {
+		if len(LoaderNames()) > 1 {
+			loader := config.Loader
+			if loader == "" {
+				loader = "auto"
+			}
+			options = append(options, "Change API loader ("+loader+")")
+		}
+
+		if (config.TLS != nil) && !reflect.DeepEqual(*config.TLS, TLSConfig{}) {
 			options = append(options, "Edit TLS configuration")
 		}
 
@@ -425,6 +456,8 @@ func askInitAPI(a asker, cmd *cobra.Command, args []string) {
 			askBaseURI(a, config)
 		case choice == "Add profile":
 			askAddProfile(a, config)
+		case strings.HasPrefix(choice, "Change API loader"):
+			askLoader(a, config)
 		case strings.HasPrefix(choice, "Edit profile"):
 			profile := strings.SplitN(choice, " ", 3)[2]
 			askEditProfile(a, profile, config.Profiles[profile])
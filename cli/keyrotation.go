@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingKeyAuth sends an API key which expires, e.g. every day, and gets a
+// new one from a refresh endpoint using a long-lived secret. Keys are cached
+// until shortly before they expire or until the server rejects them.
+type RotatingKeyAuth struct {
+	// mu single-flights refreshes so that concurrent requests, e.g. from a
+	// bulk push, wait for and then share one new key.
+	mu sync.Mutex
+}
+
+// Parameters defines the RotatingKeyAuth parameter names.
+func (a *RotatingKeyAuth) Parameters() []AuthParam {
+	return []AuthParam{
+		{Name: "refresh_url", Required: true, Help: "URL to POST to for a new key"},
+		{Name: "secret", Required: true, Help: "Long-lived secret to get new keys with, sent as a bearer token"},
+		{Name: "secret_header", Help: "Header to send the secret in, defaults to Authorization"},
+		{Name: "key_field", Help: "Dotted path to the key in the refresh response, defaults to key"},
+		{Name: "expires_field", Help: "Dotted path to the RFC 3339 or Unix expiration time in the refresh response, defaults to expires_at"},
+		{Name: "ttl", Help: "How long keys are valid if the response has no expiration, e.g. 24h"},
+		{Name: "header", Help: "Header to send the key in, defaults to Authorization"},
+		{Name: "prefix", Help: "Prefix for the key, defaults to Bearer for the Authorization header"},
+	}
+}
+
+// fieldAt returns the value at a dotted path like `data.key` in a decoded
+// JSON body, or nil if there is none.
+func fieldAt(body any, path string) any {
+	current := body
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+// refreshKey gets a new key from the refresh endpoint along with the time it
+// expires, which is zero if unknown.
+func refreshKey(params map[string]string) (string, time.Time, error) {
+	if params["refresh_url"] == "" || params["secret"] == "" {
+		return "", time.Time{}, errors.New("invalid profile, rotating-api-key requires a refresh_url and secret")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, params["refresh_url"], nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if header := params["secret_header"]; header != "" && !strings.EqualFold(header, "Authorization") {
+		req.Header.Set(header, params["secret"])
+	} else {
+		req.Header.Set("Authorization", "Bearer "+params["secret"])
+	}
+	req.Header.Set("Accept", "application/json")
+
+	AuthLog.Debug("Refreshing API key")
+	LogDebugRequest(req)
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	LogDebugResponse(start, resp)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", time.Time{}, fmt.Errorf("key refresh failed with %s:\n%s", resp.Status, body)
+	}
+
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return "", time.Time{}, fmt.Errorf("key refresh returned invalid JSON: %w", err)
+	}
+
+	keyField := params["key_field"]
+	if keyField == "" {
+		keyField = "key"
+	}
+	key, _ := fieldAt(decoded, keyField).(string)
+	if key == "" {
+		return "", time.Time{}, fmt.Errorf("key refresh response has no key at %s", keyField)
+	}
+
+	expiresField := params["expires_field"]
+	if expiresField == "" {
+		expiresField = "expires_at"
+	}
+	expires, err := parseExpiresAt(fieldAt(decoded, expiresField))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("key refresh response has %w", err)
+	}
+
+	if expires.IsZero() && params["ttl"] != "" {
+		ttl, err := time.ParseDuration(params["ttl"])
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("invalid ttl %s: %w", params["ttl"], err)
+		}
+		expires = time.Now().Add(ttl)
+	}
+
+	return key, expires, nil
+}
+
+// storeKey refreshes and caches the key. Call with the lock held.
+func (a *RotatingKeyAuth) storeKey(key string, params map[string]string) (string, error) {
+	apiKey, expires, err := refreshKey(params)
+	if err != nil {
+		return "", err
+	}
+
+	SetSecret(key+".token", apiKey)
+	Cache.Set(key+".expires", expires)
+	if err := Cache.WriteConfig(); err != nil {
+		return "", err
+	}
+	return apiKey, nil
+}
+
+// cachedKey returns the cached key unless it is about to expire. Keys without
+// a known expiration are used until the server rejects them. Call with the
+// lock held.
+func (a *RotatingKeyAuth) cachedKey(key string) string {
+	expires := Cache.GetTime(key + ".expires")
+	if !expires.IsZero() && !expires.After(time.Now().Add(tokenRefreshWindow)) {
+		return ""
+	}
+	return GetSecret(key + ".token")
+}
+
+// keyHeader returns the header to send the key in and its value.
+func keyHeader(apiKey string, params map[string]string) (string, string) {
+	header := params["header"]
+	if header == "" {
+		header = "Authorization"
+	}
+
+	prefix, hasPrefix := params["prefix"]
+	if !hasPrefix && strings.EqualFold(header, "Authorization") {
+		prefix = "Bearer"
+	}
+	if prefix != "" {
+		apiKey = prefix + " " + apiKey
+	}
+	return header, apiKey
+}
+
+// OnRequest gets run before the request goes out on the wire.
+func (a *RotatingKeyAuth) OnRequest(req *http.Request, key string, params map[string]string) error {
+	if header, _ := keyHeader("", params); req.Header.Get(header) != "" {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	apiKey := a.cachedKey(key)
+	if apiKey != "" {
+		AuthLog.Debug("Loading API key from cache.")
+	} else {
+		var err error
+		if apiKey, err = a.storeKey(key, params); err != nil {
+			return err
+		}
+	}
+
+	req.Header.Set(keyHeader(apiKey, params))
+	return nil
+}
+
+// OnUnauthorized refreshes a rejected key, unless another request already did
+// so while this one was in flight, and applies the new key.
+func (a *RotatingKeyAuth) OnUnauthorized(req *http.Request, key string, params map[string]string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	header, _ := keyHeader("", params)
+	sent := req.Header.Get(header)
+
+	apiKey := a.cachedKey(key)
+	if _, value := keyHeader(apiKey, params); apiKey == "" || value == sent {
+		var err error
+		if apiKey, err = a.storeKey(key, params); err != nil {
+			return false, err
+		}
+	}
+
+	req.Header.Set(keyHeader(apiKey, params))
+	return true, nil
+}
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestRotatingKeyAuth(t *testing.T) {
+	reset(false)
+	Cache.Set("rotate:default.token", "")
+	Cache.Set("rotate:default.expires", time.Time{})
+
+	var refreshes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer long-lived", r.Header.Get("Authorization"))
+		n := atomic.AddInt32(&refreshes, 1)
+		fmt.Fprintf(w, `{"data": {"api_key": "key%d"}}`, n)
+	}))
+	defer server.Close()
+
+	auth := &RotatingKeyAuth{}
+	params := map[string]string{
+		"refresh_url": server.URL,
+		"secret":      "long-lived",
+		"key_field":   "data.api_key",
+		"ttl":         "24h",
+		"header":      "X-API-Key",
+	}
+
+	// Concurrent requests share a single refresh.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+			assert.NoError(t, auth.OnRequest(req, "rotate:default", params))
+			assert.Equal(t, "key1", req.Header.Get("X-API-Key"))
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, refreshes)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), Cache.GetTime("rotate:default.expires"), time.Minute)
+
+	// Keys about to expire are refreshed lazily.
+	Cache.Set("rotate:default.expires", time.Now().Add(time.Second))
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	require.NoError(t, auth.OnRequest(req, "rotate:default", params))
+	assert.Equal(t, "key2", req.Header.Get("X-API-Key"))
+
+	// A rejected key is only refreshed once, even if several requests fail.
+	stale, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	stale.Header.Set("X-API-Key", "key2")
+	refreshed, err := auth.OnUnauthorized(stale, "rotate:default", params)
+	require.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.Equal(t, "key3", stale.Header.Get("X-API-Key"))
+
+	stale.Header.Set("X-API-Key", "key2")
+	_, err = auth.OnUnauthorized(stale, "rotate:default", params)
+	require.NoError(t, err)
+	assert.Equal(t, "key3", stale.Header.Get("X-API-Key"))
+	assert.EqualValues(t, 3, refreshes)
+}
+
+func TestRotatingKeyAuthExpiresField(t *testing.T) {
+	reset(false)
+	Cache.Set("rotate-exp:default.token", "")
+	Cache.Set("rotate-exp:default.expires", time.Time{})
+
+	defer gock.Off()
+	gock.New("http://auth.example.com").
+		Post("/refresh").
+		MatchHeader("X-Secret", "long-lived").
+		Reply(http.StatusOK).
+		JSON(map[string]any{"key": "abc", "expires_at": "2099-01-01T00:00:00Z"})
+
+	auth := &RotatingKeyAuth{}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	require.NoError(t, auth.OnRequest(req, "rotate-exp:default", map[string]string{
+		"refresh_url":   "http://auth.example.com/refresh",
+		"secret":        "long-lived",
+		"secret_header": "X-Secret",
+	}))
+	assert.Equal(t, "Bearer abc", req.Header.Get("Authorization"))
+	assert.Equal(t, 2099, Cache.GetTime("rotate-exp:default.expires").Year())
+	assert.True(t, gock.IsDone())
+
+	gock.New("http://auth.example.com").
+		Post("/refresh").
+		Reply(http.StatusForbidden).
+		BodyString("bad secret")
+
+	_, err := auth.OnUnauthorized(req, "rotate-exp:default", map[string]string{
+		"refresh_url": "http://auth.example.com/refresh",
+		"secret":      "wrong",
+	})
+	assert.ErrorContains(t, err, "key refresh failed with 403 Forbidden")
+}
+
+func TestRotatingKeyAuthRetry(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+	AddAuth("rotating-api-key", &RotatingKeyAuth{})
+	Cache.Set("rotate-api:default.token", "")
+	Cache.Set("rotate-api:default.expires", time.Time{})
+
+	configs["rotate-api"] = &APIConfig{
+		name: "rotate-api",
+		Base: "http://rotate.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {Auth: &APIAuth{Name: "rotating-api-key", Params: map[string]string{
+				"refresh_url": "http://auth.example.com/refresh",
+				"secret":      "long-lived",
+			}}},
+		},
+	}
+	defer delete(configs, "rotate-api")
+
+	gock.New("http://auth.example.com").
+		Post("/refresh").
+		Reply(http.StatusOK).
+		JSON(map[string]any{"key": "old"})
+
+	gock.New("http://auth.example.com").
+		Post("/refresh").
+		Reply(http.StatusOK).
+		JSON(map[string]any{"key": "new"})
+
+	gock.New("http://rotate.example.com").
+		Put("/items/1").
+		MatchHeader("Authorization", "Bearer old").
+		Reply(http.StatusUnauthorized)
+
+	gock.New("http://rotate.example.com").
+		Put("/items/1").
+		MatchHeader("Authorization", "Bearer new").
+		BodyString(`{"a":1}`).
+		Reply(http.StatusOK)
+
+	req, _ := http.NewRequest(http.MethodPut, "http://rotate.example.com/items/1", strings.NewReader(`{"a":1}`))
+	resp, err := MakeRequest(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, gock.IsDone())
+
+	// Only one attempt is made with the new key.
+	gock.New("http://rotate.example.com").
+		Get("/items/1").
+		Times(2).
+		Reply(http.StatusUnauthorized)
+
+	gock.New("http://auth.example.com").
+		Post("/refresh").
+		Reply(http.StatusOK).
+		JSON(map[string]any{"key": "newer"})
+
+	req, _ = http.NewRequest(http.MethodGet, "http://rotate.example.com/items/1", nil)
+	resp, err = MakeRequest(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.True(t, gock.IsDone())
+}
@@ -18,6 +18,16 @@ type Link struct {
 // Links represents a map of `rel` => list of linke relations.
 type Links map[string][]*Link
 
+// FirstLink returns the URI of the first link with the given relation. Links
+// are resolved against the response URL when the response is parsed, so the
+// URI is always absolute.
+func (r Response) FirstLink(rel string) (string, bool) {
+	if links := r.Links[rel]; len(links) > 0 {
+		return links[0].URI, true
+	}
+	return "", false
+}
+
 // LinkParser parses link relationships in a response.
 type LinkParser interface {
 	ParseLinks(resp *Response) error
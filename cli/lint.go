@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Lint issue severities. Only errors make `api lint` fail.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintIssue is a problem in an API description which degrades the generated
+// CLI, located via a JSON pointer into the description.
+type LintIssue struct {
+	Severity string `json:"severity" yaml:"severity"`
+	Pointer  string `json:"pointer" yaml:"pointer"`
+	Message  string `json:"message" yaml:"message"`
+}
+
+// Linter is a Loader which can check an API description for problems before
+// it gets registered.
+type Linter interface {
+	Loader
+	Lint(entrypoint, spec url.URL, resp *http.Response) ([]LintIssue, error)
+}
+
+// JSONPointer returns a JSON pointer built from the given unescaped reference
+// tokens, e.g. `/paths/~1items/get` for `paths`, `/items`, and `get`.
+func JSONPointer(tokens ...string) string {
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	pointer := ""
+	for _, token := range tokens {
+		pointer += "/" + escaper.Replace(token)
+	}
+	return pointer
+}
+
+// lintAPI loads the API description from a file or URL using the first loader
+// which detects it, just like at runtime, and returns the problems found.
+// Loaders which can't lint only get checked for load errors.
+func lintAPI(location string) ([]LintIssue, error) {
+	body, err := fromFileOrUrl(location)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := url.Parse(os.ExpandEnv(location))
+	if err != nil {
+		return nil, err
+	}
+	entrypoint := url.URL{Scheme: spec.Scheme, Host: spec.Host, Path: "/"}
+
+	for _, l := range loaders {
+		resp := &http.Response{
+			Proto:      "HTTP/1.1",
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}
+		if !l.Detect(resp) {
+			continue
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		var issues []LintIssue
+		if linter, ok := l.(Linter); ok {
+			if issues, err = linter.Lint(entrypoint, *spec, resp); err != nil {
+				return nil, err
+			}
+		} else if _, err := l.Load(entrypoint, *spec, resp); err != nil {
+			issues = append(issues, LintIssue{Severity: LintError, Message: err.Error()})
+		}
+
+		sort.SliceStable(issues, func(i, j int) bool {
+			return issues[i].Pointer < issues[j].Pointer
+		})
+		return issues, nil
+	}
+
+	return nil, fmt.Errorf("no loader found for %s", location)
+}
+
+// lintCommand returns the `api lint` command.
+func lintCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "lint url-or-file",
+		Short: "Check an API description for problems",
+		Long:  "Load an API description with the same loader used at runtime and report problems which degrade the generated commands, like operations without IDs, colliding command names, parameters without schemas, unresolvable references, and successful responses without schemas. Each problem points into the description via a JSON pointer. Exits with a non-zero status if there are any errors, while warnings are only reported.",
+		Example: fmt.Sprintf(`  $ %s api lint ./openapi.yaml
+  $ %s api lint https://api.rest.sh/openapi.json`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			issues, err := lintAPI(args[0])
+			if err != nil {
+				panic(err)
+			}
+
+			errors := 0
+			for _, issue := range issues {
+				label := colorize(themeWarn, issue.Severity)
+				if issue.Severity == LintError {
+					errors++
+					label = colorize(themeError, issue.Severity)
+				}
+				fmt.Fprintf(Stdout, "%s #%s: %s\n", label, issue.Pointer, issue.Message)
+			}
+
+			if len(issues) == 0 {
+				fmt.Fprintln(Stdout, "No problems found")
+				return
+			}
+
+			fmt.Fprintf(Stdout, "\n%s, %s\n", pluralize(errors, "error"), pluralize(len(issues)-errors, "warning"))
+			if errors > 0 {
+				panic(fmt.Errorf("%s has %s", args[0], pluralize(errors, "error")))
+			}
+		},
+	}
+}
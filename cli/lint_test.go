@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type lintLoader struct {
+	overrideLoader
+	issues []LintIssue
+}
+
+func (l *lintLoader) Lint(entrypoint, spec url.URL, resp *http.Response) ([]LintIssue, error) {
+	return l.issues, nil
+}
+
+func TestJSONPointer(t *testing.T) {
+	assert.Equal(t, "", JSONPointer())
+	assert.Equal(t, "/paths/~1items~0{id}/get", JSONPointer("paths", "/items~{id}", "get"))
+}
+
+func TestLintCommand(t *testing.T) {
+	defer func(orig []Loader) { loaders = orig }(loaders)
+
+	reset(false)
+	loaders = []Loader{&lintLoader{issues: []LintIssue{
+		{Severity: LintWarning, Pointer: "/paths/~1items/post", Message: "no operationId"},
+		{Severity: LintError, Pointer: "/paths/~1items/get", Message: "name collision"},
+	}}}
+
+	out := runNoReset("api lint testdata/petstore.json")
+	assert.Contains(t, out, "error #/paths/~1items/get: name collision\nwarning #/paths/~1items/post: no operationId\n")
+	assert.Contains(t, out, "1 error, 1 warning")
+	assert.Contains(t, out, "testdata/petstore.json has 1 error")
+
+	loaders = []Loader{&lintLoader{}}
+	out = runNoReset("api lint testdata/petstore.json")
+	assert.Contains(t, out, "No problems found")
+	assert.NotContains(t, out, "error")
+
+	// Loaders which can't lint only report load errors.
+	loaders = []Loader{&overrideLoader{
+		load: func(entrypoint, spec url.URL, resp *http.Response) (API, error) {
+			return API{}, errors.New("bad spec")
+		},
+	}}
+	out = runNoReset("api lint testdata/petstore.json")
+	assert.Contains(t, out, "error #: bad spec")
+}
@@ -1,74 +1,390 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alecthomas/chroma/quick"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
-var enableVerbose bool
+// Verbosity levels of log output, see `--rsh-verbose`.
+const (
+	// LevelInfo logs what is going on, e.g. retries, failures and decisions.
+	LevelInfo = 1
 
-// LogDebug logs a debug message if --rsh-verbose (-v) was passed.
-func LogDebug(format string, values ...interface{}) {
-	if enableVerbose {
-		fmt.Fprintf(Stderr, "%s %s\n", au.Index(243, "DEBUG:"), fmt.Sprintf(format, values...))
+	// LevelRequest also logs each request & response with its headers, and
+	// timings. This is what a single `-v` enables.
+	LevelRequest = 2
+
+	// LevelWire also logs request & response bodies.
+	LevelWire = 3
+)
+
+// verbosity is the current log level, or zero for no debug output.
+var verbosity int
+
+// debugSubsystems limits debug output to these subsystems if not empty, see
+// `--rsh-debug`.
+var debugSubsystems map[string]bool
+
+// Logger writes debug messages for a subsystem, which can be selected via
+// `--rsh-debug`. Messages without a subsystem are only shown if no subsystems
+// are selected.
+type Logger struct {
+	subsystem string
+}
+
+// Loggers for the subsystems which `--rsh-debug` can select.
+var (
+	HTTPLog  = Logger{"http"}
+	BulkLog  = Logger{"bulk"}
+	AuthLog  = Logger{"auth"}
+	CacheLog = Logger{"cache"}
+)
+
+// logSubsystems are the names of the subsystems, sorted.
+var logSubsystems = []string{"auth", "bulk", "cache", "http"}
+
+// Enabled returns whether debug messages of a level are shown.
+func (l Logger) Enabled(level int) bool {
+	if verbosity < level {
+		return false
+	}
+	return len(debugSubsystems) == 0 || debugSubsystems[l.subsystem]
+}
+
+// Log logs a debug message if its level is enabled.
+func (l Logger) Log(level int, format string, values ...interface{}) {
+	if l.Enabled(level) {
+		logMessage("debug", colorize(themeDebug, "DEBUG:"), format, values...)
+	}
+}
+
+// Debug logs a debug message at the info level, e.g. about a retry.
+func (l Logger) Debug(format string, values ...interface{}) {
+	l.Log(LevelInfo, format, values...)
+}
+
+// verbosityFlag is the value of `--rsh-verbose`. Each `-v` increases the
+// count, where one means `LevelRequest` and two or more `LevelWire`. Numbers
+// set the level directly, and `true` means `LevelRequest` like it did before
+// there were levels.
+type verbosityFlag struct {
+	count int
+	level int
+}
+
+func (v *verbosityFlag) String() string {
+	return strconv.Itoa(v.level)
+}
+
+func (v *verbosityFlag) Set(s string) error {
+	if s == "+1" {
+		v.count++
+		v.level = LevelRequest
+		if v.count > 1 {
+			v.level = LevelWire
+		}
+		return nil
+	}
+	level, err := parseVerbosity(s)
+	if err != nil {
+		return err
+	}
+	v.count, v.level = level, level
+	return nil
+}
+
+func (v *verbosityFlag) Type() string {
+	return "count"
+}
+
+// parseVerbosity parses a verbosity level from a flag, config or environment
+// value, which may also be a boolean.
+func parseVerbosity(value any) (int, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case bool:
+		if v {
+			return LevelRequest, nil
+		}
+		return 0, nil
+	case int:
+		return v, nil
+	case float64:
+		return int(v), nil
+	}
+
+	s := fmt.Sprintf("%v", value)
+	if b, err := strconv.ParseBool(s); err == nil && !strings.ContainsAny(s, "0123456789") {
+		return parseVerbosity(b)
 	}
+	level, err := strconv.Atoi(s)
+	if err != nil || level < 0 || level > LevelWire {
+		return 0, fmt.Errorf("invalid verbosity %s, expected a level from 0 to %d or true", s, LevelWire)
+	}
+	return level, nil
+}
+
+// addVerbosityFlag adds `--rsh-verbose` to a flag set.
+func addVerbosityFlag(flags *pflag.FlagSet) {
+	flags.VarP(&verbosityFlag{}, "rsh-verbose", "v", "Enable verbose log output of requests & responses, use -vv to also include their bodies or --rsh-verbose=1 for just info")
+	flags.Lookup("rsh-verbose").NoOptDefVal = "+1"
+}
+
+// setLogLevel sets the verbosity and debug subsystems from the parsed global
+// flags, falling back to the config or environment. The flags aren't stored
+// in viper so they don't stick around for later runs in the same process.
+func setLogLevel(flags *pflag.FlagSet) error {
+	var value any = viper.Get("rsh-verbose")
+	if flags.Changed("rsh-verbose") {
+		value = flags.Lookup("rsh-verbose").Value.String()
+	}
+	level, err := parseVerbosity(value)
+	if err != nil {
+		return err
+	}
+
+	subsystems := viper.GetStringSlice("rsh-debug")
+	if debug, _ := flags.GetStringArray("rsh-debug"); len(debug) > 0 {
+		subsystems = debug
+	}
+
+	debugSubsystems = map[string]bool{}
+	for _, value := range subsystems {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if i := sort.SearchStrings(logSubsystems, name); i == len(logSubsystems) || logSubsystems[i] != name {
+				return fmt.Errorf("unknown debug subsystem %s, expected one of %s", name, strings.Join(logSubsystems, ", "))
+			}
+			debugSubsystems[name] = true
+		}
+	}
+	if len(debugSubsystems) > 0 && level == 0 {
+		level = LevelRequest
+	}
+
+	verbosity = level
+	return nil
+}
+
+// logJSON returns whether log messages are written as one JSON object per
+// line for machine consumption, via `--rsh-log-format json`.
+func logJSON() bool {
+	return viper.GetString("rsh-log-format") == "json"
+}
+
+// logEvent is a single JSON log line. Request & response events also
+// describe the request.
+type logEvent struct {
+	Time       time.Time     `json:"time"`
+	Level      string        `json:"level"`
+	Event      string        `json:"event,omitempty"`
+	Message    string        `json:"message,omitempty"`
+	Method     string        `json:"method,omitempty"`
+	URL        string        `json:"url,omitempty"`
+	Headers    http.Header   `json:"headers,omitempty"`
+	Status     int           `json:"status,omitempty"`
+	DurationMS float64       `json:"duration_ms,omitempty"`
+	Bytes      *int64        `json:"bytes,omitempty"`
+	Timings    *timingsEvent `json:"timings,omitempty"`
+	Retry      *int          `json:"retry,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+func writeLogEvent(event logEvent) {
+	event.Time = time.Now().UTC()
+	enc := json.NewEncoder(Stderr)
+	enc.SetEscapeHTML(false)
+	enc.Encode(event)
+}
+
+// logMessage writes a log message either as text with a colored label or as
+// JSON.
+func logMessage(level, label string, format string, values ...interface{}) {
+	message := fmt.Sprintf(format, values...)
+	if logJSON() {
+		writeLogEvent(logEvent{Level: level, Message: message})
+		return
+	}
+	fmt.Fprintf(Stderr, "%s %s\n", label, message)
+}
+
+// visibleURL returns the request URL for log output, with secrets redacted
+// unless `--rsh-show-secrets` was passed.
+func visibleURL(req *http.Request) string {
+	if showSecrets() {
+		return req.URL.String()
+	}
+	return redactURL(req)
+}
+
+// visibleHeaders returns headers for log output, with secrets redacted unless
+// `--rsh-show-secrets` was passed.
+func visibleHeaders(headers http.Header) http.Header {
+	if showSecrets() {
+		return headers
+	}
+	return redactHeaders(headers)
+}
+
+// visibleValue returns a header or query param value for log output, redacted
+// if the name looks like a secret unless `--rsh-show-secrets` was passed.
+func visibleValue(name, value string) string {
+	if showSecrets() || !isSecret(name) {
+		return value
+	}
+	return redact(value)
+}
+
+// visibleRequest returns a shallow copy of the request for log output, with
+// secrets redacted unless `--rsh-show-secrets` was passed.
+func visibleRequest(req *http.Request) *http.Request {
+	shown := *req
+	if !showSecrets() {
+		shown.Header = redactHeaders(req.Header)
+		if u, err := url.Parse(redactURL(req)); err == nil {
+			shown.URL = u
+		}
+	}
+	return &shown
+}
+
+// visibleResponse returns a shallow copy of the response for log output,
+// with secrets redacted unless `--rsh-show-secrets` was passed.
+func visibleResponse(resp *http.Response) *http.Response {
+	shown := *resp
+	shown.Header = visibleHeaders(resp.Header)
+	return &shown
+}
+
+// logRequestEvent logs a request as JSON if requests are logged, with secrets
+// redacted.
+func logRequestEvent(req *http.Request, retry int) {
+	if !HTTPLog.Enabled(LevelRequest) || !logJSON() {
+		return
+	}
+
+	writeLogEvent(logEvent{
+		Level:   "debug",
+		Event:   "request",
+		Method:  req.Method,
+		URL:     visibleURL(req),
+		Headers: visibleHeaders(req.Header),
+		Retry:   &retry,
+	})
+}
+
+// logResponseEvent logs a response or request error as JSON if requests are
+// logged, with secrets redacted.
+func logResponseEvent(req *http.Request, resp *http.Response, err error, duration time.Duration, retry int) {
+	if !HTTPLog.Enabled(LevelRequest) || !logJSON() {
+		return
+	}
+
+	event := logEvent{
+		Level:      "debug",
+		Event:      "response",
+		Method:     req.Method,
+		URL:        visibleURL(req),
+		DurationMS: float64(duration.Microseconds()) / 1000,
+		Retry:      &retry,
+	}
+
+	if err != nil {
+		event.Level = "error"
+		event.Event = "error"
+		event.Error = err.Error()
+	} else {
+		event.Status = resp.StatusCode
+		event.Headers = visibleHeaders(resp.Header)
+		if resp.ContentLength >= 0 {
+			event.Bytes = &resp.ContentLength
+		}
+	}
+
+	writeLogEvent(event)
+}
+
+// LogDebug logs a debug message without a subsystem if --rsh-verbose (-v) was
+// passed.
+func LogDebug(format string, values ...interface{}) {
+	Logger{}.Debug(format, values...)
 }
 
-// LogDebugRequest logs the request in a debug message if verbose output
-// is enabled.
+// LogDebugRequest logs the request in a debug message if requests are logged,
+// including its body at the wire level.
 func LogDebugRequest(req *http.Request) {
-	if enableVerbose {
-		dumped, err := httputil.DumpRequest(req, true)
+	// JSON logs use request events without the body instead.
+	if HTTPLog.Enabled(LevelRequest) && !logJSON() {
+		// Dumping reads the body and replaces it on the copy, so hand it back.
+		shown := visibleRequest(req)
+		dumped, err := httputil.DumpRequest(shown, HTTPLog.Enabled(LevelWire))
+		req.Body = shown.Body
 		if err != nil {
 			return
 		}
 
 		if useColor {
 			sb := &strings.Builder{}
-			quick.Highlight(sb, string(dumped), "http", "terminal256", "cli-dark")
+			quick.Highlight(sb, string(dumped), "http", "terminal256", themeStyle)
 			dumped = []byte(sb.String())
 		}
 
-		LogDebug("Making request:\n%s", string(dumped))
+		HTTPLog.Log(LevelRequest, "Making request:\n%s", string(dumped))
 	}
 }
 
-// LogDebugResponse logs the response in a debug message if verbose output
-// is enabled.
+// LogDebugResponse logs the response in a debug message if requests are
+// logged, including its body at the wire level.
 func LogDebugResponse(start time.Time, resp *http.Response) {
-	if enableVerbose {
-		dumped, err := httputil.DumpResponse(resp, true)
+	if HTTPLog.Enabled(LevelRequest) && !logJSON() {
+		// Event streams may never end and huge bodies shouldn't be loaded into
+		// memory, so don't try to read the body of either.
+		shown := visibleResponse(resp)
+		limit, _, _ := maxResponseSize()
+		body := HTTPLog.Enabled(LevelWire) && !isEventStream(resp) && (limit == 0 || resp.ContentLength <= limit)
+		dumped, err := httputil.DumpResponse(shown, body)
+		resp.Body = shown.Body
 		if err != nil {
 			return
 		}
 
 		if useColor {
 			sb := &strings.Builder{}
-			quick.Highlight(sb, string(dumped), "http", "terminal256", "cli-dark")
+			quick.Highlight(sb, string(dumped), "http", "terminal256", themeStyle)
 			dumped = []byte(sb.String())
 		}
 
-		LogDebug("Got response from server in %s:\n%s", time.Since(start), string(dumped))
+		HTTPLog.Log(LevelRequest, "Got response from server in %s:\n%s", time.Since(start), string(dumped))
 	}
 }
 
 // LogInfo logs an info message.
 func LogInfo(format string, values ...interface{}) {
-	fmt.Fprintf(Stderr, "%s %s\n", au.Index(74, "INFO:"), fmt.Sprintf(format, values...))
+	logMessage("info", colorize(themeInfo, "INFO:"), format, values...)
 }
 
 // LogWarning logs a warning message.
 func LogWarning(format string, values ...interface{}) {
-	fmt.Fprintf(Stderr, "%s %s\n", au.Index(222, "WARN:"), fmt.Sprintf(format, values...))
+	logMessage("warn", colorize(themeWarn, "WARN:"), format, values...)
 }
 
 // LogError logs an error message.
 func LogError(format string, values ...interface{}) {
 	// TODO: stack traces?
-	fmt.Fprintf(Stderr, "%s %s\n", au.BgIndex(204, "ERROR:").White().Bold(), fmt.Sprintf(format, values...))
+	logMessage("error", colorize(themeError, "ERROR:"), format, values...)
 }
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVerbosity(t *testing.T) {
+	for value, expected := range map[any]int{
+		nil:     0,
+		false:   0,
+		true:    LevelRequest,
+		"true":  LevelRequest,
+		"false": 0,
+		"0":     0,
+		"1":     LevelInfo,
+		"3":     LevelWire,
+		2:       LevelRequest,
+	} {
+		level, err := parseVerbosity(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, level, value)
+	}
+
+	_, err := parseVerbosity("loud")
+	assert.Error(t, err)
+
+	_, err = parseVerbosity("4")
+	assert.Error(t, err)
+}
+
+func TestVerbosityLevels(t *testing.T) {
+	defer func() { verbosity = 0 }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	// Just info messages.
+	out := run("--rsh-verbose=1 post " + ts.URL + "/items marker: 1")
+	assert.NotContains(t, out, "Making request")
+
+	// A single `-v` logs each request with its headers, but not its body.
+	out = run("-v post " + ts.URL + "/items marker: 1")
+	assert.Contains(t, out, "Making request")
+	assert.Contains(t, out, "Content-Type: application/json")
+	assert.Contains(t, out, "Timings for POST")
+	assert.NotContains(t, out, "marker")
+
+	out = run("-vv post " + ts.URL + "/items marker: 1")
+	assert.Contains(t, out, `"marker"`)
+
+	// There is no level above the bodies, so more flags just show them too.
+	out = run("-vvv post " + ts.URL + "/items marker: 1")
+	assert.Contains(t, out, "Making request")
+	assert.Contains(t, out, `"marker"`)
+	assert.Contains(t, out, "204 No Content")
+	assert.Equal(t, LevelWire, verbosity)
+
+	// Selecting subsystems hides the others and enables request logging.
+	out = run("-v --rsh-debug bulk post " + ts.URL + "/items marker: 1")
+	assert.NotContains(t, out, "DEBUG:")
+
+	out = run("--rsh-debug http post " + ts.URL + "/items marker: 1")
+	assert.Contains(t, out, "Making request")
+
+	out = run("--rsh-debug nope post " + ts.URL + "/items marker: 1")
+	assert.Contains(t, out, "unknown debug subsystem nope, expected one of auth, bulk, cache, http")
+}
@@ -0,0 +1,587 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/danielgtaylor/mexpr"
+	"golang.org/x/text/cases"
+)
+
+// matchFunc is a function which can be called from a match expression, e.g.
+// `len(labels) >= 2` or `round(price * 1.1) < 100`.
+type matchFunc struct {
+	// minArgs and maxArgs limit the number of arguments, where a zero maxArgs
+	// means any number of arguments is allowed.
+	minArgs int
+	maxArgs int
+	call    func(args []any) (any, error)
+}
+
+// matchFuncs are the functions available in match expressions.
+var matchFuncs = map[string]matchFunc{
+	"len": {minArgs: 1, maxArgs: 1, call: func(args []any) (any, error) {
+		switch v := args[0].(type) {
+		case string:
+			return utf8.RuneCountInString(v), nil
+		case []any:
+			return len(v), nil
+		case map[string]any:
+			return len(v), nil
+		case map[any]any:
+			return len(v), nil
+		}
+		return nil, fmt.Errorf("cannot get length of %s", matchTypeName(args[0]))
+	}},
+	"min": {minArgs: 1, call: func(args []any) (any, error) {
+		return numberExtreme(args, func(a, b float64) bool { return a < b })
+	}},
+	"max": {minArgs: 1, call: func(args []any) (any, error) {
+		return numberExtreme(args, func(a, b float64) bool { return a > b })
+	}},
+	"abs": {minArgs: 1, maxArgs: 1, call: func(args []any) (any, error) {
+		n, err := matchNumber(args[0])
+		return math.Abs(n), err
+	}},
+	"round": {minArgs: 1, maxArgs: 1, call: func(args []any) (any, error) {
+		n, err := matchNumber(args[0])
+		return math.Round(n), err
+	}},
+}
+
+// matchTypeName returns the expression type name of a value for errors.
+func matchTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any, map[any]any:
+		return "object"
+	}
+	if _, err := matchNumber(v); err == nil {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// matchNumber converts a numeric value into a float, like the interpreter
+// does for arithmetic.
+func matchNumber(v any) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int8:
+		return float64(n), nil
+	case int16:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint:
+		return float64(n), nil
+	case uint8:
+		return float64(n), nil
+	case uint16:
+		return float64(n), nil
+	case uint32:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	}
+	return 0, fmt.Errorf("expected number but found %s", matchTypeName(v))
+}
+
+// numberExtreme returns the number which wins the comparison, where arrays
+// of numbers are expanded so that both `max(a, b)` and `max(items)` work.
+func numberExtreme(args []any, better func(a, b float64) bool) (any, error) {
+	values := []any{}
+	for _, arg := range args {
+		if a, ok := arg.([]any); ok {
+			values = append(values, a...)
+			continue
+		}
+		values = append(values, arg)
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("expected at least one number")
+	}
+
+	var result float64
+	for i, v := range values {
+		n, err := matchNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 || better(n, result) {
+			result = n
+		}
+	}
+	return result, nil
+}
+
+// isDate returns whether a string is a date or time which the `before` and
+// `after` operators understand.
+func isDate(s string) bool {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// foldCase returns a copy of a value with all strings and object keys case
+// folded using Unicode full case folding, e.g. `Straße` becomes `strasse`.
+// Dates are left alone so they can still be compared.
+func foldCase(caser cases.Caser, v any) any {
+	switch t := v.(type) {
+	case string:
+		if isDate(t) {
+			return t
+		}
+		return caser.String(t)
+	case []any:
+		folded := make([]any, len(t))
+		for i, item := range t {
+			folded[i] = foldCase(caser, item)
+		}
+		return folded
+	case map[string]any:
+		folded := make(map[string]any, len(t))
+		for k, item := range t {
+			folded[caser.String(k)] = foldCase(caser, item)
+		}
+		return folded
+	}
+	return v
+}
+
+// foldNode case folds the identifiers and string literals of an expression,
+// so that property names and unquoted strings both match folded documents.
+func foldNode(caser cases.Caser, node *mexpr.Node) {
+	if node == nil {
+		return
+	}
+	if node.Type == mexpr.NodeIdentifier || node.Type == mexpr.NodeLiteral {
+		if s, ok := node.Value.(string); ok && s != "@" {
+			node.Value = foldCase(caser, s)
+		}
+	}
+	foldNode(caser, node.Left)
+	foldNode(caser, node.Right)
+}
+
+// matchCall is a function call within a match expression. Calls are swapped
+// out for a placeholder property before the expression is parsed and their
+// results are added to the input document under that name when run.
+type matchCall struct {
+	name   string
+	fn     string
+	args   []*matchInterpreter
+	offset int
+	length int
+}
+
+// err returns an error located at the function call.
+func (c *matchCall) err(format string, a ...any) mexpr.Error {
+	length := c.length
+	if length > math.MaxUint8 {
+		length = math.MaxUint8
+	}
+	return mexpr.NewError(uint16(c.offset), uint8(length), format, a...)
+}
+
+// run evaluates the arguments against the input and calls the function.
+func (c *matchCall) run(value any) (any, mexpr.Error) {
+	args := make([]any, len(c.args))
+	for i, arg := range c.args {
+		result, err := arg.eval(value)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = result
+	}
+
+	result, err := matchFuncs[c.fn].call(args)
+	if err != nil {
+		return nil, c.err("%s: %s", c.fn, err)
+	}
+	return result, nil
+}
+
+// matchInterpreter runs a match expression, including any function calls.
+type matchInterpreter struct {
+	expression  string
+	offset      int
+	ignoreCase  bool
+	ast         *mexpr.Node
+	interpreter mexpr.Interpreter
+	calls       []*matchCall
+}
+
+// shift moves an error by the offset of the expression within its parent.
+func (i *matchInterpreter) shift(err mexpr.Error) mexpr.Error {
+	if err == nil || i.offset == 0 {
+		return err
+	}
+	return mexpr.NewError(err.Offset()+uint16(i.offset), err.Length(), "%s", err.Error())
+}
+
+// eval runs the expression, first computing any function calls.
+func (i *matchInterpreter) eval(value any) (any, mexpr.Error) {
+	if len(i.calls) > 0 {
+		doc, ok := value.(map[string]any)
+		if !ok {
+			return nil, i.shift(i.calls[0].err("functions require an object as input but found %s", matchTypeName(value)))
+		}
+
+		withCalls := make(map[string]any, len(doc)+len(i.calls))
+		for k, v := range doc {
+			withCalls[k] = v
+		}
+		for _, c := range i.calls {
+			result, err := c.run(doc)
+			if err != nil {
+				return nil, i.shift(err)
+			}
+			withCalls[c.name] = result
+		}
+		value = withCalls
+	}
+
+	result, err := i.interpreter.Run(value)
+	return result, i.shift(err)
+}
+
+// Run implements mexpr.Interpreter. Dividing by zero makes the expression
+// falsey with a warning rather than failing.
+func (i *matchInterpreter) Run(value any) (any, mexpr.Error) {
+	if i.ignoreCase {
+		value = foldCase(cases.Fold(), value)
+	}
+	result, err := i.eval(value)
+	if err != nil && err.Error() == "cannot divide by zero" {
+		LogWarning("%s", err.Pretty(i.expression))
+		return false, nil
+	}
+	return result, err
+}
+
+// isIdentByte returns whether a byte can be part of an identifier.
+func isIdentByte(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// closeParen returns the index of the parenthesis closing the one at `start`
+// along with the offsets of any top-level commas between them, or -1 if the
+// parenthesis is never closed.
+func closeParen(expression string, start int) (int, []int) {
+	depth := 0
+	commas := []int{}
+	quoted := false
+	for i := start; i < len(expression); i++ {
+		switch c := expression[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+			if depth == 0 {
+				return i, commas
+			}
+		case c == ',' && depth == 1:
+			commas = append(commas, i)
+		}
+	}
+	return -1, nil
+}
+
+// parseMatch parses an expression which starts at `offset` within its parent
+// expression. Function calls are parsed first, with each argument being an
+// expression of its own, then the remaining expression is parsed by mexpr.
+// When ignoring case the example must already be case folded.
+func parseMatch(expression string, offset int, example map[string]any, ignoreCase bool) (*matchInterpreter, mexpr.Error) {
+	i := &matchInterpreter{expression: expression, offset: offset, ignoreCase: ignoreCase}
+	sb := strings.Builder{}
+	where := false
+	quoted := false
+
+	for pos := 0; pos < len(expression); pos++ {
+		c := expression[pos]
+		if quoted || c == '"' {
+			if quoted && c == '\\' && pos+1 < len(expression) {
+				sb.WriteByte(c)
+				pos++
+				c = expression[pos]
+			} else if c == '"' {
+				quoted = !quoted
+			}
+			sb.WriteByte(c)
+			continue
+		}
+
+		if !isIdentByte(c) || (pos > 0 && (isIdentByte(expression[pos-1]) || expression[pos-1] == '.')) {
+			sb.WriteByte(c)
+			continue
+		}
+
+		end := pos
+		for end < len(expression) && isIdentByte(expression[end]) {
+			end++
+		}
+		ident := expression[pos:end]
+		if ident == "where" {
+			where = true
+		}
+
+		if _, ok := matchFuncs[ident]; !ok || end >= len(expression) || expression[end] != '(' {
+			sb.WriteString(ident)
+			pos = end - 1
+			continue
+		}
+
+		closing, commas := closeParen(expression, end)
+		call := &matchCall{
+			name:   fmt.Sprintf("__fn%d", len(i.calls)),
+			fn:     ident,
+			offset: pos,
+			length: closing + 1 - pos,
+		}
+		if closing == -1 {
+			call.length = len(expression) - pos
+			return nil, i.shift(call.err("missing closing parenthesis for %s", ident))
+		}
+		if where {
+			return nil, i.shift(call.err("functions can't be used in where clauses"))
+		}
+
+		bounds := append(append([]int{end}, commas...), closing)
+		for j := 0; j < len(bounds)-1; j++ {
+			arg := expression[bounds[j]+1 : bounds[j+1]]
+			if strings.TrimSpace(arg) == "" {
+				if len(bounds) == 2 {
+					// A call without arguments like `len()`.
+					break
+				}
+				return nil, i.shift(call.err("%s: missing argument", ident))
+			}
+			argInterpreter, err := parseMatch(arg, bounds[j]+1, example, ignoreCase)
+			if err != nil {
+				return nil, i.shift(err)
+			}
+			call.args = append(call.args, argInterpreter)
+		}
+
+		fn := matchFuncs[ident]
+		if len(call.args) < fn.minArgs || (fn.maxArgs > 0 && len(call.args) > fn.maxArgs) {
+			expected := fmt.Sprintf("at least %s", pluralize(fn.minArgs, "argument"))
+			if fn.maxArgs == fn.minArgs {
+				expected = pluralize(fn.minArgs, "argument")
+			}
+			return nil, i.shift(call.err("%s expects %s but got %d", ident, expected, len(call.args)))
+		}
+
+		i.calls = append(i.calls, call)
+
+		// Pad the placeholder so that error offsets still line up with the
+		// original expression.
+		sb.WriteString(call.name)
+		if padding := call.length - len(call.name); padding > 0 {
+			sb.WriteString(strings.Repeat(" ", padding))
+		}
+		pos = closing
+	}
+
+	var types any
+	if example != nil {
+		// A nil map would fail type checking for any nested property. Function
+		// results are type checked by calling them with the example values.
+		withCalls := make(map[string]any, len(example)+len(i.calls))
+		for k, v := range example {
+			withCalls[k] = v
+		}
+		for _, c := range i.calls {
+			result, err := c.run(example)
+			if err != nil {
+				return nil, i.shift(err)
+			}
+			withCalls[c.name] = result
+		}
+		types = withCalls
+	}
+
+	ast, err := mexpr.Parse(sb.String(), nil)
+	if err != nil {
+		return nil, i.shift(err)
+	}
+	if ignoreCase {
+		foldNode(cases.Fold(), ast)
+	}
+	if types != nil {
+		if err := mexpr.TypeCheck(ast, types, mexpr.UnquotedStrings); err != nil {
+			return nil, i.shift(err)
+		}
+	}
+	i.ast = ast
+	i.interpreter = mexpr.NewInterpreter(ast, mexpr.UnquotedStrings)
+	return i, nil
+}
+
+// ParseMatch parses a match expression like `body.price > 0`, which is used
+// by both bulk `--match` and `--rsh-assert` so that operators and functions
+// behave the same everywhere. If an example document is given then the
+// expression is also type checked against it. When ignoring case, strings and
+// property names in both the expression and the input are case folded before
+// being compared.
+func ParseMatch(expression string, example map[string]any, ignoreCase bool) (mexpr.Interpreter, mexpr.Error) {
+	if ignoreCase && example != nil {
+		example = foldCase(cases.Fold(), example).(map[string]any)
+	}
+
+	i, err := parseMatch(expression, 0, example, ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// matchPath returns the dotted property path selected by a node, e.g.
+// `author.role` for `author.role` or `items.status` for `items[0].status`,
+// or an empty string if the node isn't a property selection.
+func matchPath(node *mexpr.Node) string {
+	switch node.Type {
+	case mexpr.NodeIdentifier:
+		if name, ok := node.Value.(string); ok && name != "@" && !strings.HasPrefix(name, "__fn") {
+			return name
+		}
+	case mexpr.NodeFieldSelect:
+		if left, right := matchPath(node.Left), matchPath(node.Right); left != "" && right != "" {
+			return left + "." + right
+		}
+	case mexpr.NodeArrayIndex:
+		return matchPath(node.Left)
+	}
+	return ""
+}
+
+// hasMatchPath returns whether a dotted property path exists in a document,
+// where arrays are looked into like in `matchPath`.
+func hasMatchPath(doc any, path string) bool {
+	for _, part := range strings.Split(path, ".") {
+		if a, ok := doc.([]any); ok && len(a) > 0 {
+			doc = a[0]
+		}
+		m, ok := doc.(map[string]any)
+		if !ok {
+			return false
+		}
+		if doc, ok = m[part]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchEnumErrors returns an error for each comparison via `==` or `!=` of a
+// property against a literal which isn't one of the property's allowed
+// values, e.g. `status == activ` when status is one of `active` or
+// `inactive`. The allowed values are given by dotted property path. Unquoted
+// strings which are properties of the example document aren't literals.
+func MatchEnumErrors(expression string, example map[string]any, enums map[string][]any, ignoreCase bool) []mexpr.Error {
+	i, err := parseMatch(expression, 0, nil, false)
+	if err != nil || len(enums) == 0 {
+		return nil
+	}
+
+	caser := cases.Fold()
+	errs := []mexpr.Error{}
+
+	// Function arguments are parsed on their own, so their offsets must be
+	// moved to line up with the full expression.
+	offset := 0
+
+	// check compares the literal side of a comparison against the enum of the
+	// property on the other side, if any.
+	check := func(prefix string, property, literal *mexpr.Node) {
+		path := matchPath(property)
+		allowed := enums[prefix+path]
+		if path == "" || len(allowed) == 0 {
+			return
+		}
+
+		var value any
+		switch literal.Type {
+		case mexpr.NodeLiteral:
+			value = literal.Value
+		case mexpr.NodeIdentifier:
+			if name := matchPath(literal); name != "" && enums[prefix+name] == nil && !hasMatchPath(example, prefix+name) {
+				value = name
+			}
+		}
+		if value == nil {
+			return
+		}
+
+		values := make([]string, len(allowed))
+		for j, v := range allowed {
+			values[j] = fmt.Sprintf("%v", v)
+			if values[j] == fmt.Sprintf("%v", value) || (ignoreCase && caser.String(values[j]) == caser.String(fmt.Sprintf("%v", value))) {
+				return
+			}
+		}
+		errs = append(errs, mexpr.NewError(literal.Offset+uint16(offset), literal.Length, "'%v' is not one of [%s]", value, strings.Join(values, ", ")))
+	}
+
+	var walk func(node *mexpr.Node, prefix string)
+	walk = func(node *mexpr.Node, prefix string) {
+		if node == nil {
+			return
+		}
+		switch node.Type {
+		case mexpr.NodeEqual, mexpr.NodeNotEqual:
+			check(prefix, node.Left, node.Right)
+			check(prefix, node.Right, node.Left)
+		case mexpr.NodeWhere:
+			// Conditions on the right apply to each item of the left side.
+			walk(node.Left, prefix)
+			if path := matchPath(node.Left); path != "" {
+				walk(node.Right, prefix+path+".")
+			}
+			return
+		}
+		walk(node.Left, prefix)
+		walk(node.Right, prefix)
+	}
+
+	var walkCalls func(i *matchInterpreter, base int)
+	walkCalls = func(i *matchInterpreter, base int) {
+		offset = base + i.offset
+		walk(i.ast, "")
+		for _, c := range i.calls {
+			for _, arg := range c.args {
+				walkCalls(arg, base+i.offset)
+			}
+		}
+	}
+	walkCalls(i, 0)
+
+	return errs
+}
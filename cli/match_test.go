@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchFunctions(t *testing.T) {
+	doc := map[string]any{
+		"price":    12.5,
+		"quantity": 100,
+		"zero":     0,
+		"name":     "héllo",
+		"labels":   []any{"a", "b"},
+		"scores":   []any{3.0, -7.0, 5.0},
+		"items": []any{
+			map[string]any{"price": 1.0},
+			map[string]any{"price": 0.0},
+		},
+	}
+
+	for _, expression := range []string{
+		"price * quantity > 1000",
+		"price + quantity * 2 == 212.5",
+		"quantity % 3 == 1",
+		"(price - 2.5) / 2 == 5",
+		"len(labels) >= 2",
+		"len(name) == 5",
+		"len(items where price > 0) == 1",
+		"min(price, quantity) == 12.5",
+		"max(scores) == 5 and min(scores) == -7",
+		"abs(min(scores)) == 7",
+		"round(price) == 13",
+		"max(len(labels), len(name)) == 5",
+		"len(\"a, b)\") == 5",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			i, err := ParseMatch(expression, nil, false)
+			require.NoError(t, err)
+			result, err := i.Run(doc)
+			require.NoError(t, err)
+			assert.Equal(t, true, result)
+		})
+	}
+}
+
+func TestMatchFunctionErrors(t *testing.T) {
+	doc := map[string]any{"price": 12.5, "name": "a", "zero": 0}
+
+	for expression, message := range map[string]string{
+		"len(":                        "missing closing parenthesis for len",
+		"len()":                       "len expects 1 argument but got 0",
+		"round(price, 2)":             "round expects 1 argument but got 2",
+		"max()":                       "max expects at least 1 argument but got 0",
+		"max(price, )":                "max: missing argument",
+		"items where len(name) > 0":   "functions can't be used in where clauses",
+		"price > 1 and len(price ==)": "incomplete expression",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := ParseMatch(expression, nil, false)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), message)
+		})
+	}
+
+	// Runtime errors point at the function call.
+	i, err := ParseMatch("price > 1 and abs(name) > 1", nil, false)
+	require.NoError(t, err)
+	_, err = i.Run(doc)
+	require.Error(t, err)
+	assert.Equal(t, "abs: expected number but found string", err.Error())
+	assert.Equal(t, uint16(14), err.Offset())
+	assert.Equal(t, uint8(9), err.Length())
+
+	// Division by zero is falsey rather than an error.
+	for _, expression := range []string{"price / zero > 1", "price % zero == 0", "round(price / zero) > 1"} {
+		i, err := ParseMatch(expression, nil, false)
+		require.NoError(t, err)
+		result, err := i.Run(doc)
+		require.NoError(t, err)
+		assert.Equal(t, false, result)
+	}
+}
+
+func TestMatchTypeCheck(t *testing.T) {
+	example := map[string]any{
+		"price":  1.0,
+		"name":   "a",
+		"labels": []any{"a"},
+	}
+
+	for _, expression := range []string{
+		"price * 2 > 1",
+		"len(labels) > 1",
+		"len(name) + round(price) > 1",
+	} {
+		_, err := ParseMatch(expression, example, false)
+		assert.NoError(t, err, expression)
+	}
+
+	for expression, message := range map[string]string{
+		"price * name > 1": "cannot operate on incompatible types number and string",
+		"len(price) > 1":   "len: cannot get length of number",
+		"abs(name) > 1":    "abs: expected number but found string",
+		"max(labels) > 1":  "max: expected number but found string",
+	} {
+		_, err := ParseMatch(expression, example, false)
+		require.Error(t, err, expression)
+		assert.Equal(t, message, err.Error())
+	}
+}
+
+func TestMatchIgnoreCase(t *testing.T) {
+	doc := map[string]any{
+		"id":      "A1-Straße",
+		"City":    "İstanbul",
+		"name":    "ılık",
+		"tags":    []any{"Alpha", "BETA"},
+		"created": "2023-05-01T10:00:00Z",
+	}
+
+	// Matching is case-sensitive by default.
+	i, err := ParseMatch("id contains a1", nil, false)
+	require.NoError(t, err)
+	result, err := i.Run(doc)
+	require.NoError(t, err)
+	assert.Equal(t, false, result)
+
+	for expression, expected := range map[string]bool{
+		"id contains a1":                           true,
+		"id startsWith \"A1-stras\"":               true,
+		"id endsWith SSE":                          true,
+		"id == \"a1-STRASSE\"":                     true,
+		"tags contains beta":                       true,
+		"BETA in tags":                             true,
+		"len(TAGS) == 2":                           true,
+		"(tags where @ startsWith al).length == 1": true,
+		"created after \"2023-01-01T00:00:00Z\"":   true,
+		"created before \"2023-01-01\"":            false,
+		// Dotted capital I folds to i with a combining dot above.
+		"city == \"İSTANBUL\"":     true,
+		"city startsWith istanbul": false,
+		// Dotless i has no simple fold and stays distinct from i.
+		"name == \"ılık\"": true,
+		"name == \"ILIK\"": false,
+	} {
+		t.Run(expression, func(t *testing.T) {
+			i, err := ParseMatch(expression, nil, true)
+			require.NoError(t, err)
+			result, err := i.Run(doc)
+			require.NoError(t, err)
+			assert.Equal(t, expected, result)
+		})
+	}
+
+	// Type checking works the same as without ignoring case.
+	example := map[string]any{"Price": 1.0, "Name": "a"}
+	_, err = ParseMatch("PRICE > 1 and name contains A", example, true)
+	assert.NoError(t, err)
+	_, err = ParseMatch("price > NAME", example, true)
+	assert.Error(t, err)
+	_, err = ParseMatch("Price > Name", example, false)
+	assert.Error(t, err)
+}
+
+func TestMatchEnumErrors(t *testing.T) {
+	example := map[string]any{"status": "active", "other": "active", "items": []any{map[string]any{"state": "open"}}}
+	enums := map[string][]any{
+		"status":      {"active", "inactive"},
+		"items.state": {"open", "closed"},
+	}
+
+	for expression, expected := range map[string][]string{
+		"status == active":                       nil,
+		"status != \"inactive\"":                 nil,
+		"status == other":                        nil,
+		"status contains activ":                  nil,
+		"status == activ":                        {"'activ' is not one of [active, inactive]"},
+		"\"Active\" == status":                   {"'Active' is not one of [active, inactive]"},
+		"items where state == opn":               {"'opn' is not one of [open, closed]"},
+		"items[0].state == closed":               nil,
+		"len(items where state != clsed) > 0":    {"'clsed' is not one of [open, closed]"},
+		"status == actve or items.state == opne": {"'actve' is not one of [active, inactive]", "'opne' is not one of [open, closed]"},
+	} {
+		t.Run(expression, func(t *testing.T) {
+			messages := []string(nil)
+			for _, err := range MatchEnumErrors(expression, example, enums, false) {
+				messages = append(messages, err.Error())
+			}
+			assert.Equal(t, expected, messages)
+		})
+	}
+
+	// Errors point at the literal, including within function arguments.
+	expression := "len(items where state != clsed) > 0"
+	errs := MatchEnumErrors(expression, example, enums, false)
+	require.Len(t, errs, 1)
+	assert.Equal(t, uint16(25), errs[0].Offset())
+	assert.Equal(t, uint8(5), errs[0].Length())
+
+	// Case is ignored when matching case-insensitively.
+	assert.Empty(t, MatchEnumErrors("status == ACTIVE", example, enums, true))
+	assert.Len(t, MatchEnumErrors("status == ACTIVE", example, enums, false), 1)
+}
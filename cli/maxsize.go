@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// defaultMaxSize is the largest response body loaded into memory unless
+// changed via `--rsh-max-size`.
+const defaultMaxSize = "100MB"
+
+// sizeUnits are the multipliers of size suffixes, longest first so that `MB`
+// isn't matched as `B`.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+	{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// parseSize parses a number of bytes with an optional unit like `500MB` or
+// `1GiB`.
+func parseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected bytes or a number with a unit like 100MB", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// maxResponseSize returns the configured limit for response bodies loaded
+// into memory along with how it was written, or zero for no limit.
+func maxResponseSize() (int64, string, error) {
+	value := viper.GetString("rsh-max-size")
+	if value == "" {
+		value = defaultMaxSize
+	}
+	limit, err := parseSize(value)
+	return limit, value, err
+}
+
+// ResponseTooLargeError is returned when a response body is larger than the
+// limit for loading it into memory.
+type ResponseTooLargeError struct {
+	// Limit as given by the user, e.g. `100MB`.
+	Limit string
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body is larger than the %s limit for loading it into memory, use --rsh-output-file or --rsh-raw-body to stream it, or raise the limit with --rsh-max-size", e.Limit)
+}
+
+// readLimited reads a response body into memory up to the configured limit.
+// Bodies which are too large fail early when the server sends their length,
+// otherwise once the limit is reached, returning what was read so far.
+func readLimited(resp *http.Response) ([]byte, error) {
+	limit, value, err := maxResponseSize()
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		return io.ReadAll(resp.Body)
+	}
+
+	if resp.ContentLength > limit {
+		return nil, &ResponseTooLargeError{Limit: value}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return data, err
+	}
+	if int64(len(data)) > limit {
+		return data, &ResponseTooLargeError{Limit: value}
+	}
+	return data, nil
+}
+
+// ReadBody reads a response body into memory, failing with a
+// `ResponseTooLargeError` if it is larger than the `--rsh-max-size` limit.
+// Callers still need to close the body.
+func ReadBody(resp *http.Response) ([]byte, error) {
+	data, err := readLimited(resp)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// spoolBody writes a body which is too large for memory to a temporary file,
+// starting with the part which was already read, and returns its name.
+func spoolBody(read []byte, rest io.Reader) (string, error) {
+	f, err := os.CreateTemp("", "restish-*.body")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(read); err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, rest); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSize(t *testing.T) {
+	for input, expected := range map[string]int64{
+		"0":      0,
+		"512":    512,
+		"10B":    10,
+		"100MB":  100 * 1000 * 1000,
+		"1.5kb":  1500,
+		"2GiB":   2 << 30,
+		"64 KiB": 64 << 10,
+	} {
+		size, err := parseSize(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, size, input)
+	}
+
+	_, err := parseSize("lots")
+	assert.EqualError(t, err, `invalid size "lots", expected bytes or a number with a unit like 100MB`)
+}
+
+func TestMaxSize(t *testing.T) {
+	body := `{"items": "` + strings.Repeat("x", 200) + `"}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/chunked" {
+			// No content length, so the limit is hit while reading.
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+	defer viper.Set("rsh-max-size", "")
+	defer viper.Set("rsh-raw", false)
+
+	out := run(ts.URL + " --rsh-no-cache --rsh-max-size 100B")
+	assert.Contains(t, out, "response body is larger than the 100B limit for loading it into memory")
+
+	out = run(ts.URL + "/chunked --rsh-no-cache --rsh-max-size 100B")
+	assert.Contains(t, out, "--rsh-max-size")
+
+	out = run(ts.URL + " --rsh-no-cache --rsh-max-size 1KB -f body.items")
+	assert.Contains(t, out, strings.Repeat("x", 200))
+
+	// Raw output is saved to a file instead of failing.
+	out = run(ts.URL + "/chunked --rsh-no-cache --rsh-max-size 100B -r")
+	match := regexp.MustCompile(`saved it to (\S+)`).FindStringSubmatch(out)
+	require.Len(t, match, 2, out)
+	defer os.Remove(match[1])
+	saved, err := os.ReadFile(match[1])
+	require.NoError(t, err)
+	assert.Equal(t, body, string(saved))
+}
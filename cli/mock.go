@@ -0,0 +1,311 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// mockRoute is an operation along with its split URL path template.
+type mockRoute struct {
+	op       *Operation
+	segments []string
+	literals int
+}
+
+// match returns the path parameters if the given path segments match the
+// route's template.
+func (r mockRoute) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, s := range r.segments {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			params[s[1:len(s)-1]] = segments[i]
+			continue
+		}
+		if s != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// splitPath splits a URL path into its unescaped segments.
+func splitPath(path string) []string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, p := range parts {
+		if u, err := url.PathUnescape(p); err == nil {
+			parts[i] = u
+		}
+	}
+	return parts
+}
+
+// mockProblem is an RFC 7807 problem details response.
+type mockProblem struct {
+	Type   string   `json:"type,omitempty"`
+	Title  string   `json:"title"`
+	Status int      `json:"status"`
+	Detail string   `json:"detail,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, status int, detail string, errs []string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(mockProblem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Errors: errs,
+	})
+}
+
+// validateParamValue checks a single string value against a parameter's type
+// and allowed values.
+func validateParamValue(p *Param, value string) string {
+	typ := strings.TrimSuffix(strings.TrimPrefix(p.Type, "array["), "]")
+
+	var parsed any = value
+	var err error
+	switch typ {
+	case "integer":
+		parsed, err = strconv.ParseInt(value, 10, 64)
+	case "number":
+		parsed, err = strconv.ParseFloat(value, 64)
+	case "boolean":
+		parsed, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return fmt.Sprintf("expected %s but got %q", typ, value)
+	}
+
+	if len(p.Enum) > 0 {
+		for _, e := range p.Enum {
+			if fmt.Sprintf("%v", e) == fmt.Sprintf("%v", parsed) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("value %q must be one of %v", value, p.Enum)
+	}
+
+	return ""
+}
+
+// validateMockRequest returns validation errors for the request's path, query,
+// header, and body inputs.
+func validateMockRequest(op *Operation, r *http.Request, pathParams map[string]string) []string {
+	errs := []string{}
+
+	for _, p := range op.PathParams {
+		if msg := validateParamValue(p, pathParams[p.Name]); msg != "" {
+			errs = append(errs, "path."+p.Name+": "+msg)
+		}
+	}
+
+	query := r.URL.Query()
+	for _, p := range op.QueryParams {
+		values := query[p.Name]
+		if len(values) == 0 {
+			if p.Required {
+				errs = append(errs, "query."+p.Name+": required parameter is missing")
+			}
+			continue
+		}
+		for _, v := range values {
+			if strings.HasPrefix(p.Type, "array[") && !p.Explode {
+				for _, item := range strings.Split(v, ",") {
+					if msg := validateParamValue(p, item); msg != "" {
+						errs = append(errs, "query."+p.Name+": "+msg)
+					}
+				}
+				continue
+			}
+			if msg := validateParamValue(p, v); msg != "" {
+				errs = append(errs, "query."+p.Name+": "+msg)
+			}
+		}
+	}
+
+	for _, p := range op.HeaderParams {
+		v := r.Header.Get(p.Name)
+		if v == "" {
+			if p.Required {
+				errs = append(errs, "header."+p.Name+": required parameter is missing")
+			}
+			continue
+		}
+		if msg := validateParamValue(p, v); msg != "" {
+			errs = append(errs, "header."+p.Name+": "+msg)
+		}
+	}
+
+	if op.BodySchema != nil {
+		b, _ := io.ReadAll(r.Body)
+		if len(b) == 0 {
+			errs = append(errs, "body: request body is missing")
+		} else {
+			mediaType := op.BodyMediaType
+			if mediaType == "" {
+				mediaType = "application/json"
+			}
+			var body any
+			if err := Unmarshal(mediaType, b, &body); err != nil {
+				errs = append(errs, "body: "+err.Error())
+			} else {
+				for _, e := range op.BodySchema.Validate(body) {
+					errs = append(errs, "body"+e)
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// mockResponse returns the response to serve for an operation, preferring the
+// first documented success response.
+func mockResponse(op *Operation) *OperationResponse {
+	for _, resp := range op.Responses {
+		if resp.Status >= 200 && resp.Status < 300 {
+			return resp
+		}
+	}
+	if op.Method == http.MethodPost {
+		return &OperationResponse{Status: http.StatusCreated}
+	}
+	if op.Method == http.MethodDelete {
+		return &OperationResponse{Status: http.StatusNoContent}
+	}
+	return &OperationResponse{Status: http.StatusOK}
+}
+
+// newMockServer returns a handler which validates incoming requests against
+// the API's operations and serves their example responses.
+func newMockServer(api API) http.Handler {
+	routes := []mockRoute{}
+	for i := range api.Operations {
+		op := &api.Operations[i]
+		u, err := url.Parse(op.URITemplate)
+		if err != nil {
+			continue
+		}
+		segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+		literals := 0
+		for _, s := range segments {
+			if !strings.HasPrefix(s, "{") {
+				literals++
+			}
+		}
+		routes = append(routes, mockRoute{op: op, segments: segments, literals: literals})
+	}
+
+	// Prefer literal matches, e.g. `/items/new` over `/items/{id}`.
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].literals > routes[j].literals
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		segments := splitPath(r.URL.Path)
+
+		methodMismatch := false
+		for _, route := range routes {
+			params, ok := route.match(segments)
+			if !ok {
+				continue
+			}
+			if !strings.EqualFold(route.op.Method, r.Method) {
+				methodMismatch = true
+				continue
+			}
+
+			op := route.op
+			if errs := validateMockRequest(op, r, params); len(errs) > 0 {
+				LogInfo("%s %s -> %d (%s)", r.Method, r.URL, http.StatusUnprocessableEntity, op.Name)
+				writeProblem(w, http.StatusUnprocessableEntity, "Validation failed", errs)
+				return
+			}
+
+			resp := mockResponse(op)
+			LogInfo("%s %s -> %d (%s)", r.Method, r.URL, resp.Status, op.Name)
+
+			if resp.Example == nil {
+				w.WriteHeader(resp.Status)
+				return
+			}
+
+			mediaType := resp.MediaType
+			if mediaType == "" {
+				mediaType = "application/json"
+			}
+			var b []byte
+			var err error
+			if (JSON{}).Detect(mediaType) {
+				// Avoid other output formats like gron which also detect JSON.
+				b, err = JSON{}.Marshal(resp.Example)
+			} else {
+				b, err = Marshal(mediaType, makeJSONSafe(resp.Example))
+			}
+			if err != nil {
+				// Fall back to JSON for media types we can't encode.
+				mediaType = "application/json"
+				b, _ = JSON{}.Marshal(resp.Example)
+			}
+			w.Header().Set("Content-Type", mediaType)
+			w.WriteHeader(resp.Status)
+			w.Write(b)
+			return
+		}
+
+		if methodMismatch {
+			LogInfo("%s %s -> %d", r.Method, r.URL, http.StatusMethodNotAllowed)
+			writeProblem(w, http.StatusMethodNotAllowed, fmt.Sprintf("Method %s is not allowed for %s", r.Method, r.URL.Path), nil)
+			return
+		}
+
+		LogInfo("%s %s -> %d", r.Method, r.URL, http.StatusNotFound)
+		writeProblem(w, http.StatusNotFound, fmt.Sprintf("No operation matches %s", r.URL.Path), nil)
+	})
+}
+
+func initMockCommand() {
+	var port *int
+
+	mock := &cobra.Command{
+		GroupID: "generic",
+		Use:     "mock short-name",
+		Short:   "Serve example responses for an API",
+		Long:    "Start a local HTTP server which serves example responses for each operation of an API, using the documented response examples or generating them from the response schemas. Path, query, and header parameters as well as request bodies are validated, with invalid requests getting a `422` problem response. Point an API at the mock server with `-s`.",
+		Example: fmt.Sprintf(`  $ %s mock my-api --port 8080
+  $ %s my-api list-items -s http://localhost:8080`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			api, err := Load(fixAddress(args[0]), &cobra.Command{})
+			if err != nil {
+				panic(err)
+			}
+			if len(api.Operations) == 0 {
+				panic(fmt.Errorf("no operations found for API %s", args[0]))
+			}
+
+			addr := fmt.Sprintf("localhost:%d", *port)
+			LogInfo("Serving %s for %s at http://%s", pluralize(len(api.Operations), "operation"), args[0], addr)
+			if err := http.ListenAndServe(addr, newMockServer(api)); err != nil {
+				panic(err)
+			}
+		},
+	}
+	port = mock.Flags().Int("port", 8080, "Port to listen on")
+
+	Root.AddCommand(mock)
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mockAPI() API {
+	return API{
+		Operations: []Operation{
+			{
+				Name:        "get-item",
+				Method:      http.MethodGet,
+				URITemplate: "https://api.example.com/v1/items/{item-id}",
+				PathParams:  []*Param{{Type: "integer", Name: "item-id"}},
+				QueryParams: []*Param{
+					{Type: "string", Name: "view", Enum: []any{"full", "short"}},
+					{Type: "array[string]", Name: "fields"},
+				},
+				Responses: []*OperationResponse{
+					{Status: 200, MediaType: "application/json", Example: map[any]any{"id": 1, "name": "Kari"}},
+					{Status: 404, MediaType: "application/json", Example: map[string]any{"message": "string"}},
+				},
+			},
+			{
+				Name:        "get-new-item",
+				Method:      http.MethodGet,
+				URITemplate: "https://api.example.com/v1/items/new",
+				Responses: []*OperationResponse{
+					{Status: 200, MediaType: "application/yaml", Example: map[string]any{"new": true}},
+				},
+			},
+			{
+				Name:        "create-item",
+				Method:      http.MethodPost,
+				URITemplate: "https://api.example.com/v1/items",
+				QueryParams: []*Param{{Type: "boolean", Name: "dry-run", Required: true}},
+				BodySchema: &Schema{
+					Type:       []string{"object"},
+					Required:   []string{"name"},
+					Properties: map[string]*Schema{"name": {Type: []string{"string"}}},
+				},
+				Responses: []*OperationResponse{{Status: 201}},
+			},
+		},
+	}
+}
+
+func mockRequest(t *testing.T, ts *httptest.Server, method, path, body string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, b
+}
+
+func TestMockServer(t *testing.T) {
+	reset(false)
+	ts := httptest.NewServer(newMockServer(mockAPI()))
+	defer ts.Close()
+
+	resp, body := mockRequest(t, ts, http.MethodGet, "/v1/items/5?view=full&fields=a,b", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"id": 1, "name": "Kari"}`, string(body))
+
+	// Literal path segments win over parameters.
+	resp, body = mockRequest(t, ts, http.MethodGet, "/v1/items/new", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/yaml", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "new: true\n", string(body))
+
+	resp, body = mockRequest(t, ts, http.MethodPost, "/v1/items?dry-run=true", `{"name": "foo"}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Empty(t, body)
+}
+
+func TestMockServerErrors(t *testing.T) {
+	reset(false)
+	ts := httptest.NewServer(newMockServer(mockAPI()))
+	defer ts.Close()
+
+	resp, body := mockRequest(t, ts, http.MethodGet, "/v1/items/abc?view=wide", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+	var problem mockProblem
+	require.NoError(t, json.Unmarshal(body, &problem))
+	assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+	assert.Equal(t, []string{
+		`path.item-id: expected integer but got "abc"`,
+		`query.view: value "wide" must be one of [full short]`,
+	}, problem.Errors)
+
+	resp, body = mockRequest(t, ts, http.MethodPost, "/v1/items", `{"other": 1}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	require.NoError(t, json.Unmarshal(body, &problem))
+	assert.Len(t, problem.Errors, 2)
+	assert.Equal(t, "query.dry-run: required parameter is missing", problem.Errors[0])
+	assert.Contains(t, problem.Errors[1], "body")
+
+	resp, _ = mockRequest(t, ts, http.MethodDelete, "/v1/items/5", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, _ = mockRequest(t, ts, http.MethodGet, "/v2/other", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// isFileField returns true if a shorthand field name like `file@` refers to
+// a file to upload rather than a value.
+func isFileField(name string) bool {
+	return len(name) > 1 && strings.HasSuffix(name, "@")
+}
+
+// hasFileFields returns true if any top-level field is a file upload.
+func hasFileFields(input map[string]interface{}) bool {
+	for k := range input {
+		if isFileField(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// readsStdin returns true if any file upload field reads from stdin.
+func readsStdin(input interface{}) bool {
+	if m, ok := input.(map[string]interface{}); ok {
+		for k, v := range m {
+			if isFileField(k) && v == "-" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// multipartBody is a streaming `multipart/form-data` request body. Files are
+// read as the body is sent rather than loaded into memory up front.
+type multipartBody struct {
+	io.Reader
+	contentType string
+
+	// length is the total size of the body in bytes, or -1 if unknown, e.g.
+	// when a part is read from stdin.
+	length  int64
+	closers []io.Closer
+}
+
+// Close closes any files opened for the body.
+func (b *multipartBody) Close() error {
+	var err error
+	for _, c := range b.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// fileContentType returns the content type of a file based on its extension,
+// falling back to sniffing the first bytes. The returned reader must be used
+// in place of `r` since some of it may have been consumed.
+func fileContentType(filename string, r io.Reader) (string, io.Reader, error) {
+	if ct := mime.TypeByExtension(filepath.Ext(filename)); ct != "" {
+		return ct, r, nil
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	head = head[:n]
+
+	return http.DetectContentType(head), io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// newMultipartBody creates a multipart body from parsed shorthand input. Fields
+// named like `file@` are file uploads where the value is a path or `-` to
+// read from stdin. Other fields are sent as form values, with structured
+// values encoded as JSON.
+func newMultipartBody(input map[string]interface{}) (*multipartBody, error) {
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	body := &multipartBody{contentType: mw.FormDataContentType()}
+	readers := []io.Reader{}
+
+	// flush moves the multipart headers and values written so far into the
+	// list of readers so that file contents can be streamed in between.
+	flush := func() {
+		if buf.Len() > 0 {
+			readers = append(readers, bytes.NewReader(append([]byte{}, buf.Bytes()...)))
+			if body.length >= 0 {
+				body.length += int64(buf.Len())
+			}
+			buf.Reset()
+		}
+	}
+
+	for _, k := range keys {
+		v := input[k]
+
+		if isFileField(k) {
+			path, ok := v.(string)
+			if !ok || path == "" {
+				body.Close()
+				return nil, fmt.Errorf("file field %s must be a path or - for stdin", k)
+			}
+
+			var r io.Reader
+			filename := filepath.Base(path)
+			size := int64(-1)
+			if path == "-" {
+				r = Stdin
+				filename = "stdin"
+			} else {
+				f, err := os.Open(path)
+				if err != nil {
+					body.Close()
+					return nil, err
+				}
+				body.closers = append(body.closers, f)
+				if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+					size = info.Size()
+				}
+				r = f
+			}
+
+			ct, r, err := fileContentType(filename, r)
+			if err != nil {
+				body.Close()
+				return nil, err
+			}
+
+			h := textproto.MIMEHeader{}
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(strings.TrimSuffix(k, "@")), escapeQuotes(filename)))
+			h.Set("Content-Type", ct)
+			if _, err := mw.CreatePart(h); err != nil {
+				body.Close()
+				return nil, err
+			}
+			flush()
+			readers = append(readers, r)
+			if size < 0 || body.length < 0 {
+				body.length = -1
+			} else {
+				body.length += size
+			}
+			continue
+		}
+
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				body.Close()
+				return nil, err
+			}
+			h := textproto.MIMEHeader{}
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(k)))
+			h.Set("Content-Type", "application/json")
+			w, err := mw.CreatePart(h)
+			if err != nil {
+				body.Close()
+				return nil, err
+			}
+			w.Write(encoded)
+		default:
+			value := ""
+			if v != nil {
+				value = fmt.Sprintf("%v", v)
+			}
+			mw.WriteField(k, value)
+		}
+	}
+
+	mw.Close()
+	flush()
+
+	body.Reader = io.MultiReader(readers...)
+	return body, nil
+}
+
+// escapeQuotes escapes a multipart header parameter value, in the same way as
+// the standard library does for form fields.
+func escapeQuotes(s string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(s)
+}
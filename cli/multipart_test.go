@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// multipartPart is a single decoded part of a multipart body.
+type multipartPart struct {
+	FileName    string
+	ContentType string
+	Body        string
+}
+
+// readMultipart decodes a multipart body into its parts by form name.
+func readMultipart(t *testing.T, body *multipartBody) map[string]multipartPart {
+	mt, params, err := mime.ParseMediaType(body.contentType)
+	require.NoError(t, err)
+	require.Equal(t, "multipart/form-data", mt)
+
+	parts := map[string]multipartPart{}
+	r := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		b, err := io.ReadAll(p)
+		require.NoError(t, err)
+		parts[p.FormName()] = multipartPart{
+			FileName:    p.FileName(),
+			ContentType: p.Header.Get("Content-Type"),
+			Body:        string(b),
+		}
+	}
+	return parts
+}
+
+func TestIsFileField(t *testing.T) {
+	assert.True(t, isFileField("file@"))
+	assert.False(t, isFileField("file"))
+	assert.False(t, isFileField("@"))
+
+	assert.True(t, hasFileFields(map[string]interface{}{"name": "a", "file@": "a.txt"}))
+	assert.False(t, hasFileFields(map[string]interface{}{"name": "a"}))
+
+	assert.True(t, readsStdin(map[string]interface{}{"file@": "-"}))
+	assert.False(t, readsStdin(map[string]interface{}{"file@": "a.txt", "name": "-"}))
+	assert.False(t, readsStdin("-"))
+}
+
+func TestMultipartBody(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "photo.png"), []byte("fake image"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data"), []byte("%PDF-1.4 fake"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "report.json"), []byte(`{"hi": true}`), 0600))
+
+	body, err := newMultipartBody(map[string]interface{}{
+		"name":         "hello",
+		"count":        5,
+		"empty":        nil,
+		"meta":         map[string]interface{}{"a": 1},
+		"photo@":       filepath.Join(dir, "photo.png"),
+		"doc@":         filepath.Join(dir, "data"),
+		`my "report"@`: filepath.Join(dir, "report.json"),
+	})
+	require.NoError(t, err)
+	defer body.Close()
+
+	assert.Equal(t, map[string]multipartPart{
+		"count": {Body: "5"},
+		"empty": {Body: ""},
+		"name":  {Body: "hello"},
+		"meta":  {ContentType: "application/json", Body: `{"a":1}`},
+		// The type comes from the extension if possible, otherwise it is
+		// sniffed from the contents.
+		"photo": {FileName: "photo.png", ContentType: "image/png", Body: "fake image"},
+		"doc":   {FileName: "data", ContentType: "application/pdf", Body: "%PDF-1.4 fake"},
+		// Quotes in names are escaped.
+		`my "report"`: {FileName: "report.json", ContentType: "application/json", Body: `{"hi": true}`},
+	}, readMultipart(t, body))
+}
+
+func TestMultipartBodyStdin(t *testing.T) {
+	WithFakeStdin([]byte("from stdin"), fs.ModeCharDevice, func() {
+		body, err := newMultipartBody(map[string]interface{}{"file@": "-"})
+		require.NoError(t, err)
+		defer body.Close()
+
+		assert.EqualValues(t, -1, body.length)
+		assert.Equal(t, map[string]multipartPart{
+			"file": {FileName: "stdin", ContentType: "text/plain; charset=utf-8", Body: "from stdin"},
+		}, readMultipart(t, body))
+	})
+}
+
+func TestMultipartBodyErrors(t *testing.T) {
+	_, err := newMultipartBody(map[string]interface{}{
+		"file@": filepath.Join(t.TempDir(), "missing.txt"),
+	})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = newMultipartBody(map[string]interface{}{"file@": ""})
+	assert.EqualError(t, err, "file field file@ must be a path or - for stdin")
+
+	_, err = newMultipartBody(map[string]interface{}{"file@": 5})
+	assert.EqualError(t, err, "file field file@ must be a path or - for stdin")
+}
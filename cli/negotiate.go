@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// acceptOverride returns the `Accept` header to send in place of the default
+// one, from `--rsh-accept`, the request's override, or the API's `accept`
+// config.
+func acceptOverride(config *APIConfig, override string) string {
+	if accept := viper.GetString("rsh-accept"); accept != "" {
+		return accept
+	}
+	if override != "" {
+		return override
+	}
+	if config != nil {
+		return config.Accept
+	}
+	return ""
+}
+
+// requestMediaType returns the media type to marshal shorthand input into
+// for a request to the given URL, which is the forced content type if any
+// or the fallback otherwise.
+func requestMediaType(uri, fallback string) string {
+	if ct := viper.GetString("rsh-content-type"); ct != "" {
+		return ct
+	}
+	if _, config := findAPI(uri); config != nil && config.ContentType != "" {
+		return config.ContentType
+	}
+	return fallback
+}
+
+// sameMediaType returns whether two content types have the same media type,
+// ignoring parameters like the charset.
+func sameMediaType(a, b string) bool {
+	ma, _, errA := mime.ParseMediaType(a)
+	mb, _, errB := mime.ParseMediaType(b)
+	return errA == nil && errB == nil && ma == mb
+}
+
+// convertBody re-encodes a structured request body into the given media type,
+// e.g. a JSON document from a file or standard input into YAML. The body is
+// decoded using its current content type if known, otherwise as JSON or YAML.
+// Bodies which are not objects or arrays, like binary uploads, are sent as-is.
+func convertBody(req *http.Request, from, to string) {
+	if req.Body == nil || sameMediaType(from, to) {
+		return
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	var value any
+	if from != "" {
+		err = Unmarshal(from, data, &value)
+	} else if err = json.Unmarshal(data, &value); err != nil {
+		err = yaml.Unmarshal(data, &value)
+	}
+	if err != nil {
+		HTTPLog.Log(LevelRequest, "Sending body as-is: %v", err)
+		return
+	}
+
+	value = makeJSONSafe(value)
+	switch value.(type) {
+	case map[string]any, []any:
+	default:
+		return
+	}
+
+	converted, err := Marshal(to, value)
+	if err != nil {
+		LogWarning("Sending body as-is: %v", err)
+		return
+	}
+
+	HTTPLog.Log(LevelRequest, "Converted request body to %s", to)
+	req.Body = io.NopCloser(bytes.NewReader(converted))
+	req.ContentLength = int64(len(converted))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(converted)), nil
+	}
+}
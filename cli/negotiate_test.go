@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildAcceptHeader(t *testing.T) {
+	reset(false)
+
+	// A q of zero tells the server the type is not acceptable at all.
+	AddContentType("never", "application/x-never", 0, &JSON{})
+	defer delete(contentTypes, "never")
+
+	entries := strings.Split(buildAcceptHeader(), ",")
+	require.NotEmpty(t, entries)
+
+	// Anything else is accepted as a last resort.
+	assert.Equal(t, "*/*", entries[len(entries)-1])
+
+	q := map[string]string{}
+	for _, entry := range entries[:len(entries)-1] {
+		mt, params, err := mime.ParseMediaType(entry)
+		require.NoError(t, err, entry)
+		q[mt] = params["q"]
+	}
+
+	assert.Equal(t, "0.9", q["application/cbor"])
+	assert.Equal(t, "0.2", q["text/*"])
+	assert.Equal(t, "0", q["application/x-never"])
+
+	// Types with equal q are all listed and the server picks between them.
+	assert.Equal(t, "0.5", q["application/json"])
+	assert.Equal(t, "0.5", q["application/yaml"])
+
+	// Output-only formats are never requested.
+	assert.NotContains(t, q, "")
+	assert.NotContains(t, buildAcceptHeader(), "q=-1")
+}
+
+func TestAcceptOverridePrecedence(t *testing.T) {
+	defer viper.Set("rsh-accept", "")
+
+	for _, tc := range []struct {
+		name     string
+		flag     string
+		override string
+		config   *APIConfig
+		expected string
+	}{
+		{"none", "", "", nil, ""},
+		{"empty config", "", "", &APIConfig{}, ""},
+		{"config", "", "", &APIConfig{Accept: "application/json"}, "application/json"},
+		{"override", "", "application/yaml", &APIConfig{Accept: "application/json"}, "application/yaml"},
+		{"flag", "text/plain", "application/yaml", &APIConfig{Accept: "application/json"}, "text/plain"},
+		{"wildcard", "*/*", "", nil, "*/*"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Set("rsh-accept", tc.flag)
+			assert.Equal(t, tc.expected, acceptOverride(tc.config, tc.override))
+		})
+	}
+}
+
+func TestRequestMediaType(t *testing.T) {
+	reset(false)
+	defer viper.Set("rsh-content-type", "")
+
+	configs["media-type-test"] = &APIConfig{
+		name:        "media-type-test",
+		Base:        "https://media-type.example.com",
+		ContentType: "application/yaml",
+	}
+	defer delete(configs, "media-type-test")
+
+	assert.Equal(t, "application/json", requestMediaType("https://other.example.com/items", "application/json"))
+	assert.Equal(t, "application/yaml", requestMediaType("https://media-type.example.com/items", "application/json"))
+
+	viper.Set("rsh-content-type", "application/cbor")
+	assert.Equal(t, "application/cbor", requestMediaType("https://media-type.example.com/items", "application/json"))
+}
+
+func TestSameMediaType(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		expected bool
+	}{
+		{"application/json", "application/json", true},
+		{"application/json; charset=utf-8", "application/json", true},
+		{"Application/JSON", "application/json", true},
+		{"application/json", "application/yaml", false},
+		// Wildcards are not expanded.
+		{"*/*", "application/json", false},
+		{"application/*", "application/json", false},
+		{"", "application/json", false},
+		{"", "", false},
+	} {
+		assert.Equal(t, tc.expected, sameMediaType(tc.a, tc.b), "%s / %s", tc.a, tc.b)
+	}
+}
+
+func TestConvertBody(t *testing.T) {
+	reset(false)
+
+	for _, tc := range []struct {
+		name     string
+		body     string
+		from     string
+		to       string
+		expected string
+	}{
+		{"json to yaml", `{"name": "foo"}`, "application/json", "application/yaml", "name: foo\n"},
+		{"detected json", `{"name": "foo"}`, "", "application/yaml", "name: foo\n"},
+		{"detected yaml", "name: foo\n", "", "application/json", "{\"name\":\"foo\"}\n"},
+		{"same type", `{"name": "foo"}`, "application/json; charset=utf-8", "application/json", `{"name": "foo"}`},
+		{"scalar", `"just a string"`, "application/json", "application/yaml", `"just a string"`},
+		{"invalid", `{not valid`, "application/json", "application/yaml", `{not valid`},
+		{"unsupported type", `{"name": "foo"}`, "application/json", "application/x-unknown", `{"name": "foo"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPut, "https://example.com/items", strings.NewReader(tc.body))
+			convertBody(req, tc.from, tc.to)
+
+			b, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(b))
+		})
+	}
+
+	// Requests without a body are left alone.
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/items", nil)
+	convertBody(req, "application/json", "application/yaml")
+	assert.Nil(t, req.Body)
+}
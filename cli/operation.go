@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
@@ -15,34 +16,56 @@ import (
 
 // Operation represents an API action, e.g. list-things or create-user
 type Operation struct {
-	Name          string   `json:"name" yaml:"name"`
-	Group         string   `json:"group,omitempty" yaml:"group,omitempty"`
-	Aliases       []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
-	Short         string   `json:"short,omitempty" yaml:"short,omitempty"`
-	Long          string   `json:"long,omitempty" yaml:"long,omitempty"`
-	Method        string   `json:"method,omitempty" yaml:"method,omitempty"`
-	URITemplate   string   `json:"uri_template" yaml:"uri_template"`
-	PathParams    []*Param `json:"path_params,omitempty" yaml:"path_params,omitempty"`
-	QueryParams   []*Param `json:"query_params,omitempty" yaml:"query_params,omitempty"`
-	HeaderParams  []*Param `json:"header_params,omitempty" yaml:"header_params,omitempty"`
-	BodyMediaType string   `json:"body_media_type,omitempty" yaml:"body_media_type,omitempty"`
-	Examples      []string `json:"examples,omitempty" yaml:"examples,omitempty"`
-	Hidden        bool     `json:"hidden,omitempty" yaml:"hidden,omitempty"`
-	Deprecated    string   `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Name          string               `json:"name" yaml:"name"`
+	Group         string               `json:"group,omitempty" yaml:"group,omitempty"`
+	Aliases       []string             `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Short         string               `json:"short,omitempty" yaml:"short,omitempty"`
+	Long          string               `json:"long,omitempty" yaml:"long,omitempty"`
+	Method        string               `json:"method,omitempty" yaml:"method,omitempty"`
+	URITemplate   string               `json:"uri_template" yaml:"uri_template"`
+	PathParams    []*Param             `json:"path_params,omitempty" yaml:"path_params,omitempty"`
+	QueryParams   []*Param             `json:"query_params,omitempty" yaml:"query_params,omitempty"`
+	HeaderParams  []*Param             `json:"header_params,omitempty" yaml:"header_params,omitempty"`
+	BodyMediaType string               `json:"body_media_type,omitempty" yaml:"body_media_type,omitempty"`
+	BodySchema    *Schema              `json:"body_schema,omitempty" yaml:"body_schema,omitempty"`
+	Examples      []string             `json:"examples,omitempty" yaml:"examples,omitempty"`
+	Responses     []*OperationResponse `json:"responses,omitempty" yaml:"responses,omitempty"`
+	Hidden        bool                 `json:"hidden,omitempty" yaml:"hidden,omitempty"`
+	Deprecated    string               `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+}
+
+// OperationResponse describes a documented response of an operation with an
+// example body, which is used e.g. by the mock server.
+type OperationResponse struct {
+	Status    int    `json:"status" yaml:"status"`
+	MediaType string `json:"media_type,omitempty" yaml:"media_type,omitempty"`
+	Example   any    `json:"example,omitempty" yaml:"example,omitempty"`
 }
 
 // command returns a Cobra command instance for this operation.
 func (o Operation) command() *cobra.Command {
 	flags := map[string]interface{}{}
+	pathFlags := map[string]interface{}{}
+
+	// Path params with a default in the API config become optional flags
+	// instead of positional arguments.
+	var defaults *ParamDefaults
+	if currentConfig != nil {
+		defaults = currentConfig.Defaults
+	}
 
 	use := slug.Make(o.Name)
+	positional := 0
 	for _, p := range o.PathParams {
-		use += " " + slug.Make(p.Name)
+		if _, ok := defaults.path(p.Name); !ok {
+			use += " " + slug.Make(p.Name)
+			positional++
+		}
 	}
 
-	argSpec := cobra.ExactArgs(len(o.PathParams))
+	argSpec := cobra.ExactArgs(positional)
 	if o.BodyMediaType != "" {
-		argSpec = cobra.MinimumNArgs(len(o.PathParams))
+		argSpec = cobra.MinimumNArgs(positional)
 	}
 
 	long := o.Long
@@ -65,10 +88,19 @@ func (o Operation) command() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			uri := o.URITemplate
 
-			for i, param := range o.PathParams {
-				value, err := param.Parse(args[i])
+			i := 0
+			for _, param := range o.PathParams {
+				input, ok := defaults.path(param.Name)
+				if !ok {
+					input = args[i]
+					i++
+				} else if flag := pathFlags[param.Name]; flag != nil && cmd.Flags().Changed(param.OptionName()) {
+					input = param.Serialize(flag)[0]
+				}
+
+				value, err := param.Parse(input)
 				if err != nil {
-					value := param.Serialize(args[i])[0]
+					value := param.Serialize(input)[0]
 					log.Fatalf("could not parse param %s with input %s: %v", param.Name, value, err)
 				}
 				// Replaces URL-encoded `{`+name+`}` in the template.
@@ -98,20 +130,10 @@ func (o Operation) command() *cobra.Command {
 				uri += queryEncoded
 			}
 
-			customServer := viper.GetString("rsh-server")
-			if customServer != "" {
-				// Adjust the server based on the customized input.
-				orig, _ := url.Parse(uri)
-				custom, _ := url.Parse(customServer)
-
-				orig.Scheme = custom.Scheme
-				orig.Host = custom.Host
-
-				if custom.Path != "" && custom.Path != "/" {
-					orig.Path = strings.TrimSuffix(custom.Path, "/") + orig.Path
-				}
-
-				uri = orig.String()
+			// Adjust the server based on the customized input.
+			uri, err := applyServer(uri, currentAPI, currentProfile())
+			if err != nil {
+				panic(err)
 			}
 
 			headers := http.Header{}
@@ -130,11 +152,16 @@ func (o Operation) command() *cobra.Command {
 			var body io.Reader
 
 			if o.BodyMediaType != "" {
-				b, err := GetBody(o.BodyMediaType, args[len(o.PathParams):])
+				mediaType := requestMediaType(uri, o.BodyMediaType)
+				b, err := GetRequestBody(mediaType, args[positional:])
 				if err != nil {
 					panic(err)
 				}
-				body = strings.NewReader(b)
+				body = b
+
+				if o.BodySchema != nil && b != nil && !viper.GetBool("rsh-no-validate") {
+					body = o.validateBody(mediaType, b)
+				}
 			}
 
 			req, _ := http.NewRequest(o.Method, uri, body)
@@ -143,13 +170,85 @@ func (o Operation) command() *cobra.Command {
 		},
 	}
 
-	for _, p := range o.QueryParams {
-		flags[p.Name] = p.AddFlag(sub.Flags())
+	sub.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) < positional {
+			i := 0
+			for _, p := range o.PathParams {
+				if _, ok := defaults.path(p.Name); ok {
+					continue
+				}
+				if i == len(args) {
+					return p.complete(toComplete)
+				}
+				i++
+			}
+		}
+
+		// Anything else is the request body, which may load files.
+		return nil, cobra.ShellCompDirectiveDefault
 	}
 
-	for _, p := range o.HeaderParams {
-		flags[p.Name] = p.AddFlag(sub.Flags())
+	for i, params := range [][]*Param{o.QueryParams, o.HeaderParams} {
+		for _, p := range params {
+			if value, ok := defaults.query(p.Name); ok && i == 0 {
+				// Unless passed, the value from the API config is sent instead.
+				withDefault := *p
+				withDefault.Default = nil
+				withDefault.Description = defaultUsage(p, value)
+				flags[p.Name] = withDefault.AddFlag(sub.Flags())
+			} else {
+				flags[p.Name] = p.AddFlag(sub.Flags())
+			}
+
+			if len(p.Enum) > 0 {
+				p := p
+				sub.RegisterFlagCompletionFunc(p.OptionName(), func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					return p.complete(toComplete)
+				})
+			}
+		}
+	}
+
+	for _, p := range o.PathParams {
+		value, ok := defaults.path(p.Name)
+		if !ok || sub.Flags().Lookup(p.OptionName()) != nil {
+			continue
+		}
+		withDefault := *p
+		withDefault.Default = nil
+		withDefault.Description = defaultUsage(p, value)
+		pathFlags[p.Name] = withDefault.AddFlag(sub.Flags())
 	}
 
 	return sub
 }
+
+// validateBody checks the request body against the operation's body schema
+// and refuses to continue if it is invalid. Bodies which can't be decoded,
+// e.g. file uploads or non-structured media types, are sent as-is. Returns a
+// reader for the body to send.
+func (o Operation) validateBody(mediaType string, body io.Reader) io.Reader {
+	if _, ok := body.(*multipartBody); ok {
+		return body
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		panic(err)
+	}
+
+	var value any
+	if err := Unmarshal(mediaType, data, &value); err != nil {
+		LogDebug("Skipping body validation: %v", err)
+		return bytes.NewReader(data)
+	}
+
+	if errs := o.BodySchema.Validate(value); len(errs) > 0 {
+		for _, e := range errs {
+			LogError("%s", e)
+		}
+		panic(fmt.Errorf("request body does not match the schema, use --rsh-no-validate to send it anyway"))
+	}
+
+	return bytes.NewReader(data)
+}
@@ -101,3 +101,113 @@ func TestOperation(t *testing.T) {
 
 	assert.Equal(t, "HTTP/1.1 200 OK\nContent-Type: application/json\n\n{\n  hello: \"world\"\n}\n", capture.String())
 }
+
+func TestOperationValidateBody(t *testing.T) {
+	defer gock.Off()
+
+	gock.
+		New("http://example.com").
+		Post("/items").
+		Reply(204)
+
+	op := Operation{
+		Name:          "create-item",
+		Method:        http.MethodPost,
+		URITemplate:   "http://example.com/items",
+		BodyMediaType: "application/json",
+		BodySchema: &Schema{
+			Type: []string{"object"},
+			Properties: map[string]*Schema{
+				"price": {Type: []string{"number"}},
+			},
+		},
+	}
+
+	cmd := op.command()
+
+	viper.Reset()
+	viper.Set("nocolor", true)
+	viper.Set("tty", true)
+	Init("test", "1.0.0")
+	Defaults()
+	capture := &strings.Builder{}
+	Stdout = capture
+	Stderr = capture
+	cmd.SetOutput(Stdout)
+
+	assert.Panics(t, func() {
+		cmd.Run(cmd, []string{"price: abc"})
+	})
+	assert.Contains(t, capture.String(), "/price: expected number, got string")
+	assert.True(t, gock.IsPending())
+
+	viper.Set("rsh-no-validate", true)
+	cmd.Run(cmd, []string{"price: abc"})
+	assert.True(t, gock.IsDone())
+}
+
+func TestOperationParamDefaults(t *testing.T) {
+	defer gock.Off()
+
+	gock.
+		New("http://example.com").
+		Get("/orgs/acme/items/id1").
+		MatchParam("version", "2").
+		Reply(204)
+
+	gock.
+		New("http://example.com").
+		Get("/orgs/other/items/id1").
+		MatchParam("version", "3").
+		Reply(204)
+
+	config := &APIConfig{
+		name: "defaults-test",
+		Base: "http://example.com",
+		Defaults: &ParamDefaults{
+			Query: map[string]string{"version": "2"},
+			Path:  map[string]string{"org": "acme"},
+		},
+	}
+	currentConfig = config
+	defer func() { currentConfig = nil }()
+
+	op := Operation{
+		Name:        "get-item",
+		Method:      http.MethodGet,
+		URITemplate: "http://example.com/orgs/{org}/items/{id}",
+		PathParams: []*Param{
+			{Type: "string", Name: "org", Description: "Org name"},
+			{Type: "string", Name: "id"},
+		},
+		QueryParams: []*Param{
+			{Type: "string", Name: "version", Default: "1"},
+		},
+	}
+
+	cmd := op.command()
+	assert.Equal(t, "get-item id", cmd.Use)
+	assert.Equal(t, `Org name (default "acme" from API config)`, cmd.Flags().Lookup("org").Usage)
+	assert.Equal(t, `(default "2" from API config)`, cmd.Flags().Lookup("version").Usage)
+	assert.Equal(t, "", cmd.Flags().Lookup("version").DefValue)
+
+	viper.Reset()
+	viper.Set("nocolor", true)
+	viper.Set("tty", true)
+	Init("test", "1.0.0")
+	Defaults()
+	configs["defaults-test"] = config
+	defer delete(configs, "defaults-test")
+	capture := &strings.Builder{}
+	Stdout = capture
+	Stderr = capture
+	cmd.SetOutput(Stdout)
+
+	cmd.Run(cmd, []string{"id1"})
+	assert.Contains(t, capture.String(), "204 No Content")
+
+	// Explicit values override the defaults.
+	cmd.Flags().Parse([]string{"--org=other", "--version=3"})
+	cmd.Run(cmd, []string{"id1"})
+	assert.True(t, gock.IsDone())
+}
@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// nextFields are body fields which commonly hold the URL of the next page.
+var nextFields = []string{"next", "next_url", "nextUrl", "next_page_url", "nextPageUrl"}
+
+// itemFields are body fields which commonly hold the items of a page, in the
+// order they are tried when auto-detecting.
+var itemFields = []string{"items", "data", "results", "values", "records", "entries", "content"}
+
+// NextFieldParser parses `next` links from a field in the response body like
+// `{"items": [...], "next": "/items?cursor=abc123"}`.
+type NextFieldParser struct{}
+
+// ParseLinks processes the links in a parsed response.
+func (n NextFieldParser) ParseLinks(resp *Response) error {
+	if len(resp.Links["next"]) > 0 {
+		// Already found via e.g. a link header.
+		return nil
+	}
+
+	b, ok := resp.Body.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	for _, field := range nextFields {
+		uri, ok := b[field].(string)
+		if !ok || uri == "" {
+			continue
+		}
+
+		// Skip opaque cursors, which need API-specific handling.
+		if !strings.HasPrefix(uri, "/") && !strings.HasPrefix(uri, "?") && !strings.HasPrefix(uri, "http://") && !strings.HasPrefix(uri, "https://") {
+			continue
+		}
+
+		if _, err := url.Parse(uri); err == nil {
+			resp.Links["next"] = append(resp.Links["next"], &Link{
+				Rel: "next",
+				URI: uri,
+			})
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// pageItems returns the items of a page of results along with the dotted
+// path to them in the body, which is empty if the body itself is a list. The
+// items are found via the given path or auto-detected from common field
+// names or the only list in the body.
+func pageItems(body any, path string) ([]any, string, bool) {
+	if l, ok := body.([]any); ok {
+		return l, "", true
+	}
+
+	b, ok := body.(map[string]any)
+	if !ok {
+		return nil, "", false
+	}
+
+	if path != "" {
+		var current any = b
+		for _, part := range strings.Split(path, ".") {
+			m, ok := current.(map[string]any)
+			if !ok {
+				return nil, "", false
+			}
+			current = m[part]
+		}
+		l, ok := current.([]any)
+		return l, path, ok
+	}
+
+	for _, field := range itemFields {
+		if l, ok := b[field].([]any); ok {
+			return l, field, true
+		}
+	}
+
+	found := ""
+	for k, v := range b {
+		if _, ok := v.([]any); ok {
+			if found != "" {
+				// Ambiguous, the user needs to pick via `--rsh-items`.
+				return nil, "", false
+			}
+			found = k
+		}
+	}
+	if found != "" {
+		return b[found].([]any), found, true
+	}
+
+	return nil, "", false
+}
+
+// setPageItems replaces the items at a dotted path in the body, returning the
+// updated body.
+func setPageItems(body any, path string, items []any) any {
+	if path == "" {
+		return items
+	}
+
+	parts := strings.Split(path, ".")
+	current := body.(map[string]any)
+	for _, part := range parts[:len(parts)-1] {
+		current = current[part].(map[string]any)
+	}
+	current[parts[len(parts)-1]] = items
+	return body
+}
+
+// paginate follows `next` links from a parsed response and merges the items
+// of each page into a single response. Bodies which are a list are always
+// merged, while pages that are objects wrapping a list of items are only
+// merged when enabled via `--rsh-paginate`, as this changes the shape of the
+// response. The last page's proto, status, headers, and other body fields are
+// returned along with all of the items and links.
+func paginate(req *http.Request, parsed Response, options ...requestOption) (Response, error) {
+	computedSize := int64(0)
+	if s, err := strconv.ParseInt(parsed.Headers["Content-Length"], 10, 64); err == nil {
+		computedSize = s
+	}
+
+	maxPages := viper.GetInt("rsh-max-pages")
+	maxResults := viper.GetInt("rsh-max-results")
+
+	base := req.URL
+	allLinks := parsed.Links
+
+	// Track fetched pages so a server linking back to one of them doesn't
+	// make us go around in circles until hitting a limit, if any.
+	first := *base
+	if first.Path == "" {
+		first.Path = "/"
+	}
+	visited := map[string]bool{first.String(): true}
+
+	items, path, isList := pageItems(parsed.Body, viper.GetString("rsh-items"))
+	pages := 1
+	for {
+		links := parsed.Links
+		if len(links["next"]) == 0 || viper.GetBool("rsh-no-paginate") {
+			break
+		}
+
+		HTTPLog.Debug("Found pagination via rel=next link: %s", links["next"][0].URI)
+
+		if !isList {
+			// TODO: support non-list formats like JSON:API
+			LogWarning("Skipping auto-pagination: response body not a list, not sure how to merge")
+			break
+		}
+
+		if path != "" && !viper.GetBool("rsh-paginate") {
+			LogInfo("More results are available, use --rsh-paginate to fetch all pages")
+			break
+		}
+
+		if maxPages > 0 && pages >= maxPages {
+			LogWarning("Stopping pagination after %s", pluralize(pages, "page"))
+			break
+		}
+
+		if maxResults > 0 && len(items) >= maxResults {
+			LogWarning("Stopping pagination after %s", pluralize(maxResults, "result"))
+			break
+		}
+
+		// Make the next request
+		next, _ := url.Parse(links["next"][0].URI)
+		next = base.ResolveReference(next)
+		if visited[next.String()] {
+			LogWarning("Stopping pagination, next page %s was already fetched", next)
+			break
+		}
+		visited[next.String()] = true
+		req, _ = http.NewRequest(http.MethodGet, next.String(), nil)
+
+		resp, err := MakeRequest(req, options...)
+		if err != nil {
+			return Response{}, err
+		}
+
+		// Merge the responses
+		parsedNext, err := ParseResponse(resp)
+		if err != nil {
+			return Response{}, err
+		}
+
+		if parsedNext.Status >= http.StatusBadRequest {
+			LogWarning("Auto-pagination next page %s returned %d, aborting", next, parsedNext.Status)
+			break
+		}
+
+		l, _, ok := pageItems(parsedNext.Body, path)
+		if !ok || (path == "") != isListBody(parsedNext.Body) {
+			LogWarning("Auto-pagination next page is not a list, aborting")
+			break
+		}
+		pages++
+
+		// The last request in the chain will be the one that gets displayed
+		// for the proto/status/headers, plus the merged body/links.
+		items = append(items, l...)
+		parsed.Proto = parsedNext.Proto
+		parsed.Status = parsedNext.Status
+		parsed.Headers = parsedNext.Headers
+		parsed.HeaderValues = parsedNext.HeaderValues
+		parsed.Links = parsedNext.Links
+		parsed.Body = parsedNext.Body
+
+		for name, links := range parsedNext.Links {
+			allLinks[name] = append(allLinks[name], links...)
+		}
+
+		// Update the total computed size to include the size of each individual
+		// request if the content size is available.
+		if s, err := strconv.ParseInt(parsedNext.Headers["Content-Length"], 10, 64); err == nil {
+			computedSize += s
+		}
+	}
+
+	if isList {
+		if maxResults > 0 && len(items) > maxResults {
+			items = items[:maxResults]
+		}
+		parsed.Body = setPageItems(parsed.Body, path, items)
+	}
+
+	// Set the final response links as a combination of all.
+	parsed.Links = allLinks
+
+	if pages > 1 {
+		HTTPLog.Debug("Merged %s from %s", pluralize(len(items), "item"), pluralize(pages, "page"))
+		if computedSize > 0 {
+			parsed.Headers["Content-Length"] = fmt.Sprintf("%d", computedSize)
+		}
+	}
+
+	return parsed, nil
+}
+
+// isListBody returns whether a body is itself a list of items.
+func isListBody(body any) bool {
+	_, ok := body.([]any)
+	return ok
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageItems(t *testing.T) {
+	items, path, ok := pageItems([]any{1.0}, "")
+	assert.True(t, ok)
+	assert.Equal(t, "", path)
+	assert.Equal(t, []any{1.0}, items)
+
+	items, path, ok = pageItems(map[string]any{"results": []any{1.0}, "tags": []any{"a"}}, "")
+	assert.True(t, ok)
+	assert.Equal(t, "results", path)
+	assert.Equal(t, []any{1.0}, items)
+
+	items, path, ok = pageItems(map[string]any{"things": []any{1.0}, "total": 1.0}, "")
+	assert.True(t, ok)
+	assert.Equal(t, "things", path)
+	assert.Equal(t, []any{1.0}, items)
+
+	// Ambiguous, so the path must be given.
+	_, _, ok = pageItems(map[string]any{"a": []any{1.0}, "b": []any{2.0}}, "")
+	assert.False(t, ok)
+
+	body := map[string]any{"page": map[string]any{"b": []any{2.0}}, "a": []any{1.0}}
+	items, path, ok = pageItems(body, "page.b")
+	assert.True(t, ok)
+	assert.Equal(t, "page.b", path)
+	assert.Equal(t, []any{2.0}, items)
+
+	setPageItems(body, path, []any{2.0, 3.0})
+	assert.Equal(t, []any{2.0, 3.0}, body["page"].(map[string]any)["b"])
+}
+
+func pagedServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Write([]byte(`{"items": [1, 2], "next": "/?page=2", "total": 5}`))
+		case "2":
+			w.Write([]byte(`{"items": [3, 4], "next": "/?page=3", "total": 5}`))
+		case "3":
+			w.Write([]byte(`{"items": [5], "next": null, "total": 5}`))
+		}
+	}))
+}
+
+// expectPagedJSON runs a command and checks the logged messages and the JSON
+// body separately.
+func expectPagedJSON(t *testing.T, cmd, log, expected string) {
+	out := run("-o json -f body " + cmd)
+	i := strings.IndexAny(out, "[{")
+	require.GreaterOrEqual(t, i, 0, out)
+	assert.Equal(t, log, strings.TrimSpace(out[:i]))
+	assert.JSONEq(t, expected, out[i:])
+}
+
+func TestPaginateObject(t *testing.T) {
+	ts := pagedServer(t)
+	defer ts.Close()
+
+	expectPagedJSON(t, "--rsh-paginate "+ts.URL, "", `{
+		"items": [1, 2, 3, 4, 5],
+		"next": null,
+		"total": 5
+	}`)
+
+	// Filters run on the merged result.
+	expectPagedJSON(t, "--rsh-paginate -f body.items "+ts.URL, "", `[1, 2, 3, 4, 5]`)
+
+	// Objects are only merged when asked to.
+	expectPagedJSON(t, ts.URL, "INFO: More results are available, use --rsh-paginate to fetch all pages", `{
+		"items": [1, 2],
+		"next": "/?page=2",
+		"total": 5
+	}`)
+}
+
+func TestPaginateLimits(t *testing.T) {
+	ts := pagedServer(t)
+	defer ts.Close()
+
+	expectPagedJSON(t, "--rsh-paginate --rsh-max-pages 2 "+ts.URL, "WARN: Stopping pagination after 2 pages", `{
+		"items": [1, 2, 3, 4],
+		"next": "/?page=3",
+		"total": 5
+	}`)
+
+	expectPagedJSON(t, "--rsh-paginate --rsh-max-results 3 "+ts.URL, "WARN: Stopping pagination after 3 results", `{
+		"items": [1, 2, 3],
+		"next": "/?page=3",
+		"total": 5
+	}`)
+}
+
+func TestPaginateLoop(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		next string
+	}{
+		{"self", "/?page=2"},
+		{"first page", "/"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if r.URL.Query().Get("page") == "" {
+					w.Header().Set("Link", `</?page=2>; rel="next"`)
+					w.Write([]byte(`[1]`))
+					return
+				}
+				w.Header().Set("Link", `<`+tc.next+`>; rel="next"`)
+				w.Write([]byte(`[2]`))
+			}))
+			defer ts.Close()
+
+			expectPagedJSON(t, ts.URL, "WARN: Stopping pagination, next page "+ts.URL+tc.next+" was already fetched", `[1, 2]`)
+		})
+	}
+}
+
+func TestPaginateItemsPath(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `</?page=2>; rel="next"`)
+			w.Write([]byte(`{"a": [], "page": {"values": [1]}}`))
+			return
+		}
+		w.Write([]byte(`{"a": [], "page": {"values": [2]}}`))
+	}))
+	defer ts.Close()
+
+	reset(false)
+	viper.Set("rsh-paginate", true)
+	viper.Set("rsh-items", "page.values")
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	resp, err := GetParsedResponse(req)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": []any{}, "page": map[string]any{"values": []any{1.0, 2.0}}}, resp.Body)
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"strings"
 
 	"github.com/iancoleman/strcase"
+	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
 
@@ -32,6 +34,8 @@ type Param struct {
 	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
 	Style       Style       `json:"style,omitempty" yaml:"style,omitempty"`
 	Explode     bool        `json:"explode,omitempty" yaml:"explide,omitempty"`
+	Required    bool        `json:"required,omitempty" yaml:"required,omitempty"`
+	Enum        []any       `json:"enum,omitempty" yaml:"enum,omitempty"`
 	Default     interface{} `json:"default,omitempty" yaml:"default,omitempty"`
 	Example     interface{} `json:"example,omitempty" yaml:"example,omitempty"`
 }
@@ -104,6 +108,32 @@ func (p Param) OptionName() string {
 	return strcase.ToDelimited(name, '-')
 }
 
+// complete returns shell completions for the parameter's allowed values.
+// Array values are comma-separated, so the values already typed are kept as
+// a prefix.
+func (p Param) complete(toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(p.Enum) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	prefix := ""
+	if strings.HasPrefix(p.Type, "array[") {
+		if i := strings.LastIndex(toComplete, ","); i >= 0 {
+			prefix = toComplete[:i+1]
+		}
+	}
+
+	values := []string{}
+	for _, v := range p.Enum {
+		value := prefix + fmt.Sprintf("%v", v)
+		if strings.HasPrefix(value, toComplete) {
+			values = append(values, value)
+		}
+	}
+
+	return values, cobra.ShellCompDirectiveNoFileComp
+}
+
 // AddFlag adds a new option flag to a command's flag set for this parameter.
 func (p Param) AddFlag(flags *pflag.FlagSet) interface{} {
 	name := p.OptionName()
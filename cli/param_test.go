@@ -67,3 +67,17 @@ func TestParamFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestParamComplete(t *testing.T) {
+	p := Param{Type: "array[string]", Enum: []any{"red", "green", "blue"}}
+
+	values, _ := p.complete("")
+	assert.Equal(t, []string{"red", "green", "blue"}, values)
+
+	// Array values are comma-separated, so complete the last item.
+	values, _ = p.complete("red,g")
+	assert.Equal(t, []string{"red,green"}, values)
+
+	values, _ = Param{Type: "string"}.complete("")
+	assert.Empty(t, values)
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"context"
+	"sync"
+)
+
+// RunParallel calls `fn` for each index from `0` to `n-1` with at most
+// `parallel` calls running at once. If a call returns an error, no new calls
+// are started, the context passed to running calls is canceled, and the first
+// error is returned once they finish.
+func RunParallel(ctx context.Context, n, parallel int, fn func(ctx context.Context, i int) error) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	inner, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := 0; i < n; i++ {
+			select {
+			case indexes <- i:
+			case <-inner.Done():
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	var firstErr error
+	wg := sync.WaitGroup{}
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if inner.Err() != nil {
+					// Canceled while this index was being handed out.
+					continue
+				}
+				if err := fn(inner, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		// The parent context may have been canceled.
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
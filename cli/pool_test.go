@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunParallel(t *testing.T) {
+	var running, maxRunning int64
+	seen := make([]int64, 20)
+
+	err := RunParallel(context.Background(), len(seen), 3, func(ctx context.Context, i int) error {
+		n := atomic.AddInt64(&running, 1)
+		for {
+			m := atomic.LoadInt64(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt64(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt64(&seen[i], 1)
+		atomic.AddInt64(&running, -1)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, atomic.LoadInt64(&maxRunning), int64(3))
+	for i := range seen {
+		assert.Equal(t, int64(1), seen[i], "index %d", i)
+	}
+}
+
+func TestRunParallelError(t *testing.T) {
+	var calls int64
+
+	err := RunParallel(context.Background(), 100, 2, func(ctx context.Context, i int) error {
+		atomic.AddInt64(&calls, 1)
+		if i == 3 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	assert.EqualError(t, err, "boom")
+	assert.Less(t, atomic.LoadInt64(&calls), int64(100))
+}
+
+func TestRunParallelCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := RunParallel(ctx, 10, 2, func(ctx context.Context, i int) error {
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// newHTTP3Transport creates an HTTP/3 transport. It is only set when built
+// with the `http3` build tag, since QUIC support adds significantly to the
+// size of the binary.
+var newHTTP3Transport func(conf *tls.Config) http.RoundTripper
+
+// checkHTTPVersion returns an error if the HTTP version is not supported.
+func checkHTTPVersion(version string) error {
+	switch version {
+	case "", "1.1", "2", "3":
+		return nil
+	}
+	return fmt.Errorf("unsupported HTTP version %q, expected one of 1.1, 2, 3", version)
+}
+
+// nextProtos returns the TLS ALPN protocols to offer for an HTTP version.
+// HTTP/2 is always offered by default with a fallback to HTTP/1.1.
+func nextProtos(version string) []string {
+	if version == "1.1" {
+		return []string{"http/1.1"}
+	}
+	return []string{"h2", "http/1.1"}
+}
+
+// protoMatches returns true if a response used the requested HTTP version.
+func protoMatches(resp *http.Response, version string) bool {
+	switch version {
+	case "1.1":
+		return resp.ProtoMajor == 1
+	case "2":
+		return resp.ProtoMajor == 2
+	case "3":
+		return resp.ProtoMajor == 3
+	}
+	return true
+}
+
+// http3Transports caches HTTP/3 transports by TLS config so that QUIC
+// connections get re-used across requests.
+var http3Transports = map[*tls.Config]*http3FallbackTransport{}
+var http3Mu sync.Mutex
+
+// http3FallbackTransport tries requests over HTTP/3 first, falling back to
+// the default transport (HTTP/2 or HTTP/1.1) if that fails and strict mode
+// is not enabled. Hosts which fail are remembered so each one is only tried
+// once per process.
+type http3FallbackTransport struct {
+	h3       http.RoundTripper
+	fallback http.RoundTripper
+	strict   bool
+	failed   map[string]bool
+}
+
+// getHTTP3Transport returns an HTTP/3 transport for the TLS config. If this
+// binary does not include HTTP/3 support, then either an error is returned
+// in strict mode or the fallback transport is used.
+func getHTTP3Transport(conf *tls.Config, fallback http.RoundTripper, strict bool) (http.RoundTripper, error) {
+	if newHTTP3Transport == nil {
+		if strict {
+			return nil, fmt.Errorf("HTTP/3 support is not included in this build, rebuild with `-tags http3`")
+		}
+		HTTPLog.Debug("HTTP/3 support is not included in this build, falling back")
+		return fallback, nil
+	}
+
+	http3Mu.Lock()
+	defer http3Mu.Unlock()
+
+	t := http3Transports[conf]
+	if t == nil {
+		t = &http3FallbackTransport{
+			h3:     newHTTP3Transport(conf),
+			failed: map[string]bool{},
+		}
+		http3Transports[conf] = t
+	}
+
+	// Copy so the strict setting doesn't leak into other requests.
+	return &http3FallbackTransport{h3: t.h3, fallback: fallback, strict: strict, failed: t.failed}, nil
+}
+
+func (t *http3FallbackTransport) hasFailed(host string) bool {
+	http3Mu.Lock()
+	defer http3Mu.Unlock()
+	return t.failed[host]
+}
+
+func (t *http3FallbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		if t.strict {
+			return nil, fmt.Errorf("HTTP/3 requires https, got %s", req.URL)
+		}
+		return t.fallback.RoundTrip(req)
+	}
+
+	if t.hasFailed(req.URL.Host) {
+		return t.fallback.RoundTrip(req)
+	}
+
+	// Keep a copy of the request in case it needs to be sent again.
+	retry := req.Clone(req.Context())
+
+	resp, h3Err := t.h3.RoundTrip(req)
+	if h3Err == nil || t.strict {
+		return resp, h3Err
+	}
+
+	if retry.Body != nil {
+		if retry.GetBody == nil {
+			// The body has been consumed and can't be sent again.
+			return nil, h3Err
+		}
+		body, err := retry.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+
+	HTTPLog.Debug("HTTP/3 request to %s failed, falling back: %v", req.URL.Host, h3Err)
+	http3Mu.Lock()
+	t.failed[req.URL.Host] = true
+	http3Mu.Unlock()
+
+	return t.fallback.RoundTrip(retry)
+}
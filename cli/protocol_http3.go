@@ -0,0 +1,16 @@
+//go:build http3
+
+package cli
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func init() {
+	newHTTP3Transport = func(conf *tls.Config) http.RoundTripper {
+		return &http3.RoundTripper{TLSClientConfig: conf}
+	}
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPVersion(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	h1 := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer h1.Close()
+
+	defer viper.Set("rsh-http-version", "")
+	defer viper.Set("rsh-http-strict", false)
+
+	request := func(server *httptest.Server, version string, strict bool) (*http.Response, error) {
+		reset(false)
+		viper.Set("rsh-no-cache", true)
+		viper.Set("rsh-http-version", version)
+		viper.Set("rsh-http-strict", strict)
+		configs["versioned-api"] = &APIConfig{
+			name: "versioned-api",
+			Base: server.URL,
+			TLS: &TLSConfig{
+				CABundle: writeTestFile(t, "ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+			},
+		}
+		defer delete(configs, "versioned-api")
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		return MakeRequest(req)
+	}
+
+	resp, err := request(ts, "", false)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/2.0", resp.Proto)
+
+	resp, err = request(ts, "1.1", true)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1", resp.Proto)
+
+	// Falls back to HTTP/1.1 unless strict mode is enabled.
+	resp, err = request(h1, "2", false)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1", resp.Proto)
+
+	_, err = request(h1, "2", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "responded with HTTP/1.1 but HTTP/2 was required")
+
+	_, err = request(ts, "4", false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported HTTP version "4"`)
+}
+
+func TestHTTP3Fallback(t *testing.T) {
+	if newHTTP3Transport != nil {
+		t.Skip("built with HTTP/3 support")
+	}
+
+	reset(false)
+	viper.Set("rsh-http-version", "3")
+	viper.Set("rsh-http-strict", true)
+	defer viper.Set("rsh-http-version", "")
+	defer viper.Set("rsh-http-strict", false)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	_, err := MakeRequest(req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HTTP/3 support is not included in this build")
+}
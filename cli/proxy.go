@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// proxyFuncs maps `host:port` addresses to the proxy function which should be
+// used for requests to them. Addresses without an entry use the standard
+// `HTTP_PROXY`, `HTTPS_PROXY`, and `NO_PROXY` environment variables.
+var proxyFuncs = map[string]func(*url.URL) (*url.URL, error){}
+var proxyMu sync.Mutex
+
+func init() {
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.Proxy = proxyForRequest
+	}
+}
+
+// proxyForRequest returns the proxy URL to use for a request, if any.
+func proxyForRequest(req *http.Request) (*url.URL, error) {
+	proxyMu.Lock()
+	proxy := proxyFuncs[hostPort(req.URL)]
+	proxyMu.Unlock()
+
+	if proxy == nil {
+		return http.ProxyFromEnvironment(req)
+	}
+
+	u, err := proxy(req.URL)
+	if u != nil {
+		HTTPLog.Log(LevelRequest, "Using proxy %s for %s", u.Redacted(), req.URL.Host)
+	}
+	return u, err
+}
+
+// registerProxy sets the proxy settings to use for the URL's host, or resets
+// it to use the environment if `proxy` is nil.
+func registerProxy(u *url.URL, proxy func(*url.URL) (*url.URL, error)) {
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+
+	if proxy == nil {
+		delete(proxyFuncs, hostPort(u))
+		return
+	}
+	proxyFuncs[hostPort(u)] = proxy
+}
+
+// checkProxyURL expands environment variables in a proxy URL, so credentials
+// can be kept out of the config file, and makes sure the result is valid.
+func checkProxyURL(value string) (string, error) {
+	value = os.ExpandEnv(value)
+	if value == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return "", fmt.Errorf("unsupported proxy scheme %q in %s, expected one of http, https, socks5", u.Scheme, u.Redacted())
+	}
+
+	return value, nil
+}
+
+// getProxyFunc returns the proxy function for an API's proxy config, with the
+// `--rsh-proxy` argument taking precedence. It returns nil if no proxy has
+// been configured.
+func getProxyFunc(c *ProxyConfig) (func(*url.URL) (*url.URL, error), error) {
+	conf := httpproxy.Config{}
+	if c != nil {
+		conf.HTTPProxy = c.HTTP
+		conf.HTTPSProxy = c.HTTPS
+		conf.NoProxy = strings.Join(c.NoProxy, ",")
+	}
+
+	if proxy := viper.GetString("rsh-proxy"); proxy != "" {
+		conf.HTTPProxy = proxy
+		conf.HTTPSProxy = proxy
+	}
+
+	if conf.HTTPProxy == "" && conf.HTTPSProxy == "" {
+		return nil, nil
+	}
+
+	var err error
+	if conf.HTTPProxy, err = checkProxyURL(conf.HTTPProxy); err != nil {
+		return nil, err
+	}
+	if conf.HTTPSProxy, err = checkProxyURL(conf.HTTPSProxy); err != nil {
+		return nil, err
+	}
+
+	return conf.ProxyFunc(), nil
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// redactedPrefix starts the placeholder used in place of secret values.
+const redactedPrefix = "<redacted:"
+
+// secretHeaders are header names which always contain credentials.
+var secretHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+}
+
+// secretHints are substrings of header or query param names which likely
+// contain credentials, e.g. `X-API-Key` or `access_token`.
+var secretHints = []string{"token", "secret", "password", "api-key", "apikey", "api_key"}
+
+// isSecret returns true if a header or query param name looks like it holds
+// a credential that should not be shared. Additional names can be set via
+// `--rsh-secret-headers`.
+func isSecret(name string) bool {
+	name = strings.ToLower(name)
+	if secretHeaders[name] {
+		return true
+	}
+
+	for _, extra := range viper.GetStringSlice("rsh-secret-headers") {
+		if strings.ToLower(extra) == name {
+			return true
+		}
+	}
+
+	for _, hint := range secretHints {
+		if strings.Contains(name, hint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// showSecrets returns whether redaction of terminal output is disabled via
+// `--rsh-show-secrets`.
+func showSecrets() bool {
+	return viper.GetBool("rsh-show-secrets")
+}
+
+// redact returns a placeholder for a secret value. It includes a prefix of
+// the value's SHA-256 hash so that output from two runs can be compared
+// without revealing the secret itself.
+func redact(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return redactedPrefix + hex.EncodeToString(sum[:])[:8] + ">"
+}
+
+// isRedacted returns true if the value is a placeholder from `redact`.
+func isRedacted(value string) bool {
+	return strings.HasPrefix(value, redactedPrefix) && strings.HasSuffix(value, ">")
+}
+
+// redactHeaders returns a copy of the headers with secret values replaced.
+func redactHeaders(headers http.Header) http.Header {
+	redactedHeaders := http.Header{}
+	for k, values := range headers {
+		for _, v := range values {
+			if isSecret(k) {
+				v = redact(v)
+			}
+			redactedHeaders.Add(k, v)
+		}
+	}
+	return redactedHeaders
+}
+
+// redactURL returns the URL with the values of secret query params and any
+// user info replaced.
+func redactURL(req *http.Request) string {
+	u := *req.URL
+	placeholders := []string{}
+
+	query := u.Query()
+	changed := false
+	for k := range query {
+		if isSecret(k) {
+			for i := range query[k] {
+				query[k][i] = redact(query[k][i])
+				placeholders = append(placeholders, query[k][i])
+			}
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = query.Encode()
+	}
+	if u.User != nil {
+		placeholder := redact(u.User.String())
+		placeholders = append(placeholders, placeholder)
+		u.User = url.User(placeholder)
+	}
+
+	// Keep placeholders readable rather than percent-encoded. They parse back
+	// to the same value, so redacted history entries can still be replayed.
+	s := u.String()
+	for _, p := range placeholders {
+		s = strings.ReplaceAll(s, url.QueryEscape(p), p)
+	}
+	return s
+}
+
+// redactSettings replaces secret values of `-H` headers and `-q` query params
+// in the loaded settings, which are logged in verbose mode.
+func redactSettings(settings map[string]interface{}) {
+	for key, sep := range map[string]string{"rsh-header": ":", "rsh-query": "="} {
+		values, ok := settings[key].([]string)
+		if !ok {
+			continue
+		}
+
+		redactedValues := make([]string, len(values))
+		for i, v := range values {
+			if name, value, found := strings.Cut(v, sep); found && isSecret(name) {
+				v = name + sep + redact(value)
+			}
+			redactedValues[i] = v
+		}
+		settings[key] = redactedValues
+	}
+}
@@ -8,11 +8,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
@@ -22,13 +24,40 @@ import (
 	"github.com/spf13/viper"
 )
 
-// lastStatus is the last HTTP status code returned by a request.
-var lastStatus int
+// lastStatus is the last HTTP status code returned by a request. Bulk
+// commands make requests in parallel, so it is only accessed atomically via
+// `GetLastStatus` and `setLastStatus`.
+var lastStatus int64
+
+// UserAgent overrides the default User-Agent header for requests, e.g. so
+// that bulk commands identify themselves. Only a `--rsh-user-agent` passed on
+// the commandline takes precedence over it.
+var UserAgent string
+
+// userAgent returns the User-Agent header to send when the request doesn't
+// set one itself.
+func userAgent() string {
+	if GlobalFlags != nil && GlobalFlags.Changed("rsh-user-agent") {
+		return viper.GetString("rsh-user-agent")
+	}
+	if UserAgent != "" {
+		return UserAgent
+	}
+	if ua := viper.GetString("rsh-user-agent"); ua != "" {
+		return ua
+	}
+	return "restish-" + Root.Version
+}
 
 // GetLastStatus returns the last HTTP status code returned by a request. A
 // request can opt out of this via the IgnoreStatus option.
 func GetLastStatus() int {
-	return lastStatus
+	return int(atomic.LoadInt64(&lastStatus))
+}
+
+// setLastStatus records the last HTTP status code returned by a request.
+func setLastStatus(status int) {
+	atomic.StoreInt64(&lastStatus, int64(status))
 }
 
 // FixAddress can convert `:8000` or `example.com` to a full URL.
@@ -38,6 +67,10 @@ func FixAddress(addr string) string {
 
 // fixAddress can convert `:8000` or `example.com` to a full URL.
 func fixAddress(addr string) string {
+	if strings.HasPrefix(addr, unixScheme) {
+		return fixUnixAddress(addr)
+	}
+
 	if strings.HasPrefix(addr, ":") {
 		addr = "http://localhost" + addr
 	}
@@ -54,12 +87,13 @@ func fixAddress(addr string) string {
 					panic("invalid profile " + viper.GetString("rsh-profile"))
 				}
 			}
+			name := parts[0]
 			if p != nil && p.Base != "" {
 				parts[0] = p.Base
-				return strings.Join(parts, "/")
+				return serverAddress(name, strings.Join(parts, "/"), p)
 			} else if c.Base != "" {
 				parts[0] = c.Base
-				return strings.Join(parts, "/")
+				return serverAddress(name, strings.Join(parts, "/"), p)
 			}
 		}
 
@@ -74,11 +108,57 @@ func fixAddress(addr string) string {
 	return addr
 }
 
+// requestAuth returns the auth of the profile used for a request along with
+// its handler and cache key, or nil if it has none.
+func requestAuth(req *http.Request) (*APIAuth, AuthHandler, string) {
+	name, config := findAPI(req.URL.String())
+	if config == nil {
+		return nil, nil, ""
+	}
+
+	profile := config.Profiles[viper.GetString("rsh-profile")]
+	if profile == nil || profile.Auth == nil {
+		return nil, nil, ""
+	}
+
+	return profile.Auth, authHandlers[profile.Auth.Name], name + ":" + viper.GetString("rsh-profile")
+}
+
+// reapplyAuth lets auth handlers which sign the whole request sign it again
+// before it is retried.
+func reapplyAuth(req *http.Request) error {
+	config, auth, key := requestAuth(req)
+	if auth, ok := auth.(RetryAuthHandler); ok {
+		params, err := resolveAuthParams(config.Params)
+		if err == nil {
+			err = auth.OnRetry(req, key, params)
+		}
+		if err != nil {
+			return &ErrAuth{Name: config.Name, Err: err}
+		}
+	}
+
+	return nil
+}
+
 type requestConfig struct {
 	client          *http.Client
 	disableLog      bool
+	disableHistory  bool
 	ignoreStatus    bool
 	ignoreCLIParams bool
+	noCache         bool
+	noRedirects     bool
+	statusError     bool
+
+	// accept and contentType override the API config, but not the
+	// commandline, see `WithMediaTypes`.
+	accept      string
+	contentType string
+
+	// headerNames maps canonical header names to the casing used by the user
+	// in the profile or on the commandline.
+	headerNames map[string]string
 }
 
 type requestOption func(*requestConfig)
@@ -90,6 +170,22 @@ func WithClient(c *http.Client) requestOption {
 	}
 }
 
+// WithoutRedirects returns redirect responses instead of following them,
+// e.g. for callers which handle redirected writes themselves.
+func WithoutRedirects() requestOption {
+	return func(conf *requestConfig) {
+		conf.noRedirects = true
+	}
+}
+
+// WithoutCache does not use a cached response for the request, but still
+// caches the new one, like `--rsh-no-cache`.
+func WithoutCache() requestOption {
+	return func(conf *requestConfig) {
+		conf.noCache = true
+	}
+}
+
 // WithoutLog disabled debug logging for the given request/response.
 func WithoutLog() requestOption {
 	return func(conf *requestConfig) {
@@ -97,6 +193,14 @@ func WithoutLog() requestOption {
 	}
 }
 
+// WithoutHistory does not record the request in the history, e.g. for
+// requests made internally like loading API descriptions.
+func WithoutHistory() requestOption {
+	return func(conf *requestConfig) {
+		conf.disableHistory = true
+	}
+}
+
 // IgnoreStatus ignores the response status code.
 func IgnoreStatus() requestOption {
 	return func(conf *requestConfig) {
@@ -104,6 +208,24 @@ func IgnoreStatus() requestOption {
 	}
 }
 
+// WithStatusError makes `GetParsedResponse` return an `*ErrHTTP` along with
+// the parsed response when the server responds with an error status.
+func WithStatusError() requestOption {
+	return func(conf *requestConfig) {
+		conf.statusError = true
+	}
+}
+
+// WithMediaTypes sends the request with the given `Accept` and `Content-Type`
+// overrides, e.g. those saved in a bulk checkout, unless others were passed
+// via `--rsh-accept` or `--rsh-content-type`. Empty values are ignored.
+func WithMediaTypes(accept, contentType string) requestOption {
+	return func(conf *requestConfig) {
+		conf.accept = accept
+		conf.contentType = contentType
+	}
+}
+
 // IgnoreCLIParams only applies the profile, but ignores commandline and env params
 func IgnoreCLIParams() requestOption {
 	return func(conf *requestConfig) {
@@ -121,6 +243,163 @@ func MakeRequest(req *http.Request, options ...requestOption) (*http.Response, e
 		opt(requestConf)
 	}
 
+	config, err := prepareRequest(req, requestConf)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Socket != "" {
+		registerSocket(req.URL, os.ExpandEnv(config.Socket))
+	}
+	if strings.HasSuffix(req.URL.Hostname(), unixHostSuffix) {
+		req.Host = "localhost"
+	}
+
+	proxy, err := getProxyFunc(config.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	registerProxy(req.URL, proxy)
+
+	httpVersion := config.HTTPVersion
+	if v := viper.GetString("rsh-http-version"); v != "" {
+		httpVersion = v
+	}
+	if err := checkHTTPVersion(httpVersion); err != nil {
+		return nil, err
+	}
+	strict := viper.GetBool("rsh-http-strict")
+
+	// Each TLS config gets its own copy of the default transport, so that
+	// connections are never re-used with another API's certificates or pins.
+	// The proxy and dialer settings are shared, as they are looked up per host.
+	HTTPLog.Log(LevelRequest, "Adding TLS configuration")
+	conf, err := requestTLSConfig(config, httpVersion)
+	if err != nil {
+		return nil, err
+	}
+	base := tlsTransport(conf, httpVersion)
+	if httpVersion == "3" {
+		if base, err = getHTTP3Transport(conf, base, strict); err != nil {
+			return nil, err
+		}
+	}
+
+	cached := CachedTransport()
+	cached.Transport = base
+	client := cached.Client()
+	if viper.GetBool("rsh-no-cache") || requestConf.noCache {
+		client = &http.Client{Transport: &invalidateCachedTransport{transport: cached}}
+	}
+
+	if config.Cookies && config.name != "" && !viper.GetBool("rsh-no-cookies") {
+		jar, err := loadCookieJar(config.name, viper.GetString("rsh-profile"))
+		if err != nil {
+			return nil, err
+		}
+		client.Jar = jar
+	}
+
+	if requestConf.client != nil {
+		client = requestConf.client
+	}
+
+	client, err = transcriptClient(client)
+	if err != nil {
+		return nil, err
+	}
+	client = headerCasingClient(client, requestConf.headerNames)
+
+	if requestConf.noRedirects {
+		unfollowed := *client
+		unfollowed.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		client = &unfollowed
+	}
+
+	var entry *HistoryEntry
+	if historyEnabled() && !requestConf.disableHistory {
+		entry = newHistoryEntry(config.name, req)
+	}
+
+	start := time.Now()
+	resp, err := doRequestWithRetry(!requestConf.disableLog, client, req)
+	if entry != nil {
+		recordHistory(entry, resp, err, time.Since(start))
+	}
+	if err != nil {
+		var authErr *ErrAuth
+		if !errors.As(err, &authErr) {
+			err = &ErrNetwork{Err: err}
+		}
+		return nil, err
+	}
+
+	if !requestConf.disableLog {
+		HTTPLog.Log(LevelRequest, "Negotiated %s with %s", resp.Proto, req.URL.Host)
+	}
+	if strict && !protoMatches(resp, httpVersion) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("server %s responded with %s but HTTP/%s was required", req.URL.Host, resp.Proto, httpVersion)
+	}
+
+	if !requestConf.ignoreStatus {
+		setLastStatus(resp.StatusCode)
+	}
+
+	NoteDeprecation(req.URL.String(), ParseDeprecation(resp.Header))
+
+	return resp, nil
+}
+
+// requestTLSConfig returns the TLS client config for an API, with any
+// commandline flags taking precedence over the API's own settings.
+func requestTLSConfig(config *APIConfig, httpVersion string) (*tls.Config, error) {
+	// Copy so that CLI flags don't modify the loaded API config.
+	tlsConfig := TLSConfig{}
+	if config.TLS != nil {
+		tlsConfig = *config.TLS
+	}
+
+	// CLI flags overwrite profile options
+	if viper.GetBool("rsh-insecure") {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if viper.GetBool("rsh-insecure-disable-pinning") && len(tlsConfig.Pins) > 0 {
+		LogWarning("Disabling certificate pinning")
+		tlsConfig.Pins = nil
+	}
+	if cert := viper.GetString("rsh-client-cert"); cert != "" {
+		tlsConfig.Cert = cert
+	}
+	if key := viper.GetString("rsh-client-key"); key != "" {
+		tlsConfig.Key = key
+	}
+	if caCert := viper.GetString("rsh-ca-cert"); caCert != "" {
+		tlsConfig.CACert = caCert
+	}
+
+	return getTLSConfig(tlsConfig, httpVersion)
+}
+
+// PrepareRequest applies the profile, commandline and env params, auth, and
+// default headers to a request without sending it. This is useful to see
+// exactly what would go out on the wire. Auth failures are returned as an
+// `*ErrAuth`.
+func PrepareRequest(req *http.Request, options ...requestOption) error {
+	requestConf := &requestConfig{}
+	for _, opt := range options {
+		opt(requestConf)
+	}
+
+	_, err := prepareRequest(req, requestConf)
+	return err
+}
+
+// prepareRequest sets up the headers, query params, and auth for a request
+// and returns the matched API config (or an empty one if nothing matched).
+func prepareRequest(req *http.Request, requestConf *requestConfig) (*APIConfig, error) {
 	name, config := findAPI(req.URL.String())
 
 	if config == nil {
@@ -139,21 +418,44 @@ func MakeRequest(req *http.Request, options ...requestOption) (*http.Response, e
 	}
 
 	// Now that we have the profile, set up profile-based headers/params.
+	// Values may reference environment variables like `${TENANT}`, which are
+	// resolved for each request. Track which were set so that commandline
+	// arguments can override them.
+	profileName := viper.GetString("rsh-profile")
+	profileHeaders := map[string]bool{}
+	profileQuery := map[string]bool{}
 	query := req.URL.Query()
 	for k, v := range profile.Headers {
 		if req.Header.Get(k) == "" {
-			req.Header.Add(k, os.ExpandEnv(v))
+			value := os.ExpandEnv(v)
+			HTTPLog.Log(LevelRequest, "Header %s: %s (from profile %s)", k, visibleValue(k, value), profileName)
+			req.Header.Add(k, value)
+			profileHeaders[http.CanonicalHeaderKey(k)] = true
+			requestConf.setHeaderName(k)
 		}
 	}
 
 	for k, v := range profile.Query {
 		if query.Get(k) == "" {
-			query.Add(k, v)
+			value := os.ExpandEnv(v)
+			HTTPLog.Log(LevelRequest, "Query param %s=%s (from profile %s)", k, visibleValue(k, value), profileName)
+			query.Add(k, value)
+			profileQuery[k] = true
 		}
 	}
 
+	// API-wide defaults apply after the profile, which may override them.
+	for _, k := range applyQueryDefaults(config, query) {
+		profileQuery[k] = true
+	}
+
+	// Headers passed as `Name:` with no value are removed, even if they would
+	// be set by default.
+	removeHeaders := []string{}
+
 	if !requestConf.ignoreCLIParams {
-		// Allow env vars and commandline arguments to override config.
+		// Allow env vars and commandline arguments to override config. Repeated
+		// headers are all sent.
 		for _, h := range viper.GetStringSlice("rsh-header") {
 			parts := strings.SplitN(h, ":", 2)
 			value := ""
@@ -161,7 +463,22 @@ func MakeRequest(req *http.Request, options ...requestOption) (*http.Response, e
 				value = parts[1]
 			}
 
+			key := http.CanonicalHeaderKey(parts[0])
+			if profileHeaders[key] {
+				HTTPLog.Log(LevelRequest, "Header %s: %s (overrides profile %s)", parts[0], visibleValue(parts[0], value), profileName)
+				req.Header.Del(key)
+				delete(profileHeaders, key)
+			}
+
+			if len(parts) > 1 && value == "" {
+				HTTPLog.Log(LevelRequest, "Removing header %s", parts[0])
+				req.Header.Del(key)
+				removeHeaders = append(removeHeaders, key)
+				continue
+			}
+
 			req.Header.Add(parts[0], value)
+			requestConf.setHeaderName(parts[0])
 		}
 
 		for _, q := range viper.GetStringSlice("rsh-query") {
@@ -171,6 +488,12 @@ func MakeRequest(req *http.Request, options ...requestOption) (*http.Response, e
 				value = parts[1]
 			}
 
+			if profileQuery[parts[0]] {
+				HTTPLog.Log(LevelRequest, "Query param %s=%s (overrides profile %s)", parts[0], visibleValue(parts[0], value), profileName)
+				query.Del(parts[0])
+				delete(profileQuery, parts[0])
+			}
+
 			query.Add(parts[0], value)
 		}
 	}
@@ -178,60 +501,12 @@ func MakeRequest(req *http.Request, options ...requestOption) (*http.Response, e
 	// Save modified query string arguments.
 	req.URL.RawQuery = query.Encode()
 
-	// The assumption is that all Transport implementations eventually use the
-	// default HTTP transport.
-	// We can therefore inject the TLS config once here, along with all the other
-	// config options, instead of modifying all the places where Transports are
-	// created
-	LogDebug("Adding TLS configuration")
-	if t, ok := http.DefaultTransport.(*http.Transport); ok {
-		if t.TLSClientConfig == nil {
-			t.TLSClientConfig = &tls.Config{}
-		}
-		if config.TLS == nil {
-			config.TLS = &TLSConfig{}
-		}
-
-		// CLI flags overwrite profile options
-		if viper.GetBool("rsh-insecure") {
-			config.TLS.InsecureSkipVerify = true
-		}
-		if cert := viper.GetString("rsh-client-cert"); cert != "" {
-			config.TLS.Cert = cert
-		}
-		if key := viper.GetString("rsh-client-key"); key != "" {
-			config.TLS.Key = key
-		}
-		if caCert := viper.GetString("rsh-ca-cert"); caCert != "" {
-			config.TLS.CACert = caCert
-		}
-
-		if config.TLS.InsecureSkipVerify {
-			LogWarning("Disabling TLS security checks")
-			t.TLSClientConfig.InsecureSkipVerify = config.TLS.InsecureSkipVerify
-		}
-
-		if config.TLS.PKCS11 != nil {
-			t.TLSClientConfig.GetClientCertificate = getCertFromPkcs11(config.TLS.PKCS11)
-		}
-
-		if config.TLS.Cert != "" {
-			cert, err := tls.LoadX509KeyPair(config.TLS.Cert, config.TLS.Key)
-			if err != nil {
-				return nil, err
-			}
-			t.TLSClientConfig.Certificates = append(t.TLSClientConfig.Certificates, cert)
-		}
-		if config.TLS.CACert != "" {
-			caCert, err := os.ReadFile(config.TLS.CACert)
-			if err != nil {
-				return nil, err
-			}
-			systemCerts := BestEffortSystemCertPool()
-			if !systemCerts.AppendCertsFromPEM(caCert) {
-				return nil, fmt.Errorf("failed to append CACert %s RootCA list", config.TLS.CACert)
-			}
-			t.TLSClientConfig.RootCAs = systemCerts
+	if body, ok := req.Body.(*multipartBody); ok {
+		// Streamed multipart bodies know their own size and boundary. An
+		// unknown size results in chunked transfer encoding.
+		req.ContentLength = body.length
+		if req.Header.Get("content-type") == "" {
+			req.Header.Set("content-type", body.contentType)
 		}
 	}
 
@@ -239,49 +514,68 @@ func MakeRequest(req *http.Request, options ...requestOption) (*http.Response, e
 	if profile.Auth != nil && profile.Auth.Name != "" {
 		auth, ok := authHandlers[profile.Auth.Name]
 		if ok {
-			err := auth.OnRequest(req, name+":"+viper.GetString("rsh-profile"), profile.Auth.Params)
+			params, err := resolveAuthParams(profile.Auth.Params)
+			if err != nil {
+				return nil, &ErrAuth{Name: profile.Auth.Name, Err: err}
+			}
+
+			err = auth.OnRequest(req, name+":"+viper.GetString("rsh-profile"), params)
 			if err != nil {
-				panic(err)
+				return nil, &ErrAuth{Name: profile.Auth.Name, Err: err}
 			}
 		}
 	}
 
 	if req.Header.Get("user-agent") == "" {
-		req.Header.Set("user-agent", "restish-"+Root.Version)
+		req.Header.Set("user-agent", userAgent())
 	}
 
 	if req.Header.Get("accept") == "" {
-		req.Header.Set("accept", buildAcceptHeader())
+		accept := buildAcceptHeader()
+		if override := acceptOverride(config, requestConf.accept); override != "" && !requestConf.ignoreCLIParams {
+			accept = override
+		}
+		req.Header.Set("accept", accept)
 	}
 
 	if req.Header.Get("accept-encoding") == "" {
 		req.Header.Set("accept-encoding", buildAcceptEncodingHeader())
 	}
 
-	if req.Header.Get("content-type") == "" && req.Body != nil {
-		// We have a body but no content-type; default to JSON.
-		req.Header.Set("content-type", "application/json; charset=utf-8")
+	// The `--rsh-content-type` flag and `WithMediaTypes` win over any header
+	// while the API config only applies when no content type was passed. Either way the body is
+	// converted to match, however it was provided.
+	override := viper.GetString("rsh-content-type")
+	if override == "" {
+		override = requestConf.contentType
 	}
-
-	client := CachedTransport().Client()
-	if viper.GetBool("rsh-no-cache") {
-		client = &http.Client{Transport: InvalidateCachedTransport()}
+	if override == "" && req.Header.Get("content-type") == "" {
+		override = config.ContentType
 	}
-
-	if requestConf.client != nil {
-		client = requestConf.client
+	if override != "" && req.Body != nil && !requestConf.ignoreCLIParams {
+		if _, ok := req.Body.(*multipartBody); ok {
+			// The multipart boundary must match the body.
+			LogWarning("Ignoring content type %s for a multipart file upload", override)
+		} else {
+			convertBody(req, req.Header.Get("content-type"), override)
+			req.Header.Set("content-type", override)
+		}
 	}
 
-	resp, err := doRequestWithRetry(!requestConf.disableLog, client, req)
-	if err != nil {
-		return nil, err
+	if req.Header.Get("content-type") == "" && req.Body != nil {
+		// We have a body but no content-type; default to JSON.
+		req.Header.Set("content-type", "application/json; charset=utf-8")
 	}
 
-	if !requestConf.ignoreStatus {
-		lastStatus = resp.StatusCode
+	for _, key := range removeHeaders {
+		req.Header.Del(key)
+		if key == "User-Agent" {
+			// Go sends its own user agent unless the header is present but empty.
+			req.Header[key] = nil
+		}
 	}
 
-	return resp, nil
+	return config, nil
 }
 
 func getCertFromPkcs11(config *PKCS11Config) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
@@ -356,13 +650,237 @@ func isRetryable(code int) bool {
 	return false
 }
 
+// retryBaseDelay is the delay before the first retry when the server does
+// not say how long to wait. It doubles with each subsequent attempt.
+var retryBaseDelay = 1 * time.Second
+
+// retryMaxDelay caps the exponential backoff between retries.
+const retryMaxDelay = 30 * time.Second
+
+// isIdempotent returns true if a request with the method can safely be sent
+// more than once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isRetryableError returns true if the error was likely caused by a transient
+// network issue, like a refused or reset connection.
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoff returns the exponential backoff delay with jitter for a retry
+// attempt, where the first retry is attempt 1.
+func backoff(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+
+	// Add jitter so that many clients don't all retry at the same time.
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// sleepContext waits for the delay, returning early with the context's error
+// if it is cancelled first.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryAfter returns how long the server asked us to wait before retrying,
+// if it said at all.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if v := resp.Header.Get("X-Retry-In"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d, true
+		}
+	}
+
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		// Could be either an integer number of seconds, or an HTTP date.
+		if d, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Duration(d) * time.Second, true
+		}
+
+		if d, err := http.ParseTime(v); err == nil {
+			return time.Until(d), true
+		}
+	}
+
+	return 0, false
+}
+
+// sendRequest makes a single attempt of a request. Every request goes out on
+// the wire through here, so the logged request & response events are the
+// same for all commands.
+func sendRequest(log bool, client *http.Client, req *http.Request, retry int) (*http.Response, error) {
+	if log {
+		LogDebugRequest(req)
+	}
+	logRequestEvent(req, retry)
+
+	var timings *requestTimings
+	if timingsEnabled() || OnRequestDone != nil {
+		timings = &requestTimings{}
+		req = timings.withTrace(req)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	logResponseEvent(req, resp, err, time.Since(start), retry)
+	if err != nil {
+		if OnRequestDone != nil {
+			OnRequestDone(RequestMetric{
+				Method:   req.Method,
+				URL:      visibleURL(req),
+				Retry:    retry,
+				Duration: time.Since(start),
+				Error:    err,
+			})
+		}
+		return resp, err
+	}
+
+	if timings != nil {
+		withTimings(req, resp, timings, retry)
+	}
+
+	if log {
+		LogDebugResponse(start, resp)
+	}
+
+	return resp, nil
+}
+
+// sendAttempt sends the request once, limiting it to `--rsh-timeout` if set.
+// The timeout covers reading the response body, so it is only released once
+// the body is closed.
+func sendAttempt(log bool, client *http.Client, req *http.Request, retry int) (*http.Response, error) {
+	timeout := viper.GetDuration("rsh-timeout")
+	if timeout <= 0 {
+		return sendRequest(log, client, req, retry)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := sendRequest(log, client, req.WithContext(ctx), retry)
+	if err != nil {
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			// Add a human-friendly error before the original (context deadline
+			// exceeded).
+			err = fmt.Errorf("Request timed out after %s: %w", timeout, err)
+		}
+		return resp, err
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases a request's context once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 // doRequestWithRetry logs and makes a request, retrying as needed (if
-// configured) and returning the last response.
+// configured) and returning the last response. If the server rejects the
+// credentials of an auth handler which can refresh them, the request is sent
+// once more with new ones.
 func doRequestWithRetry(log bool, client *http.Client, req *http.Request) (*http.Response, error) {
+	config, auth, key := requestAuth(req)
+	refresher, ok := auth.(RefreshAuthHandler)
+	if !ok {
+		return sendWithRetries(log, client, req)
+	}
+
+	// Keep the body so the request can be sent again with new credentials.
+	var bodyContents []byte
+	var err error
+	if req.Body != nil && req.Body != http.NoBody {
+		if bodyContents, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(bodyContents))
+	}
+
+	resp, err := sendWithRetries(log, client, req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	refreshed := false
+	params, err := resolveAuthParams(config.Params)
+	if err == nil {
+		refreshed, err = refresher.OnUnauthorized(req, key, params)
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, &ErrAuth{Name: config.Name, Err: err}
+	}
+	if !refreshed {
+		return resp, nil
+	}
+
+	// Drain the body so the connection can be re-used.
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if bodyContents != nil {
+		req.Body = io.NopCloser(bytes.NewReader(bodyContents))
+	}
+	AuthLog.Debug("Credentials were rejected, sending the request again with new ones")
+	return sendWithRetries(log, client, req)
+}
+
+// sendWithRetries sends the request, retrying it on network errors and
+// retryable status codes as configured via `--rsh-retry`.
+func sendWithRetries(log bool, client *http.Client, req *http.Request) (*http.Response, error) {
 	retries := viper.GetInt("rsh-retry")
 
 	if retries == 0 {
-		return client.Do(req)
+		return sendAttempt(log, client, req, 0)
+	}
+
+	if !isIdempotent(req.Method) && !viper.GetBool("rsh-retry-unsafe") {
+		HTTPLog.Debug("Not retrying %s request without --rsh-retry-unsafe", req.Method)
+		retries = 0
 	}
 
 	var bodyContents []byte
@@ -370,71 +888,61 @@ func doRequestWithRetry(log bool, client *http.Client, req *http.Request) (*http
 		bodyContents, _ = io.ReadAll(req.Body)
 	}
 
+	// Each attempt gets its own timeout, so keep the caller's context around to
+	// derive them from and to know when to stop retrying.
+	ctx := req.Context()
+
 	var resp *http.Response
 	var err error
-	triesLeft := 1 + retries
-	for triesLeft > 0 {
-		triesLeft--
-
+	attempts := 1 + retries
+	for attempt := 1; attempt <= attempts; attempt++ {
 		if len(bodyContents) > 0 {
 			// Reset the body reader for each retry.
 			req.Body = io.NopCloser(bytes.NewReader(bodyContents))
 		}
 
-		if log {
-			LogDebugRequest(req)
+		if attempts > 1 {
+			HTTPLog.Debug("Attempt %d of %d", attempt, attempts)
 		}
 
-		if timeout := viper.GetDuration("rsh-timeout"); timeout > 0 {
-			ctx, cancel := context.WithTimeout(req.Context(), timeout)
-			defer cancel()
-			req = req.WithContext(ctx)
+		if attempt > 1 {
+			if err := reapplyAuth(req); err != nil {
+				return nil, err
+			}
 		}
 
-		start := time.Now()
-		resp, err = client.Do(req)
+		resp, err = sendAttempt(log, client, req.WithContext(ctx), attempt-1)
 		if err != nil {
-			if errors.Is(err, context.DeadlineExceeded) {
-				if triesLeft > 0 {
-					// Try again after letting the user know.
-					LogWarning("Got request timeout after %s, retrying", viper.GetDuration("rsh-timeout").Truncate(time.Millisecond))
-					continue
-				} else {
-					// Add a human-friendly error before the original (context deadline
-					// exceeded).
-					err = fmt.Errorf("Request timed out after %s: %w", viper.GetDuration("rsh-timeout"), err)
+			if attempt < attempts && isRetryableError(err) && ctx.Err() == nil {
+				// Try again after letting the user know.
+				delay := backoff(attempt)
+				LogWarning("%s, retrying in %s", err, delay.Truncate(time.Millisecond))
+				if err := sleepContext(ctx, delay); err != nil {
+					return nil, err
 				}
+				continue
 			}
-			return resp, err
-		}
 
-		if log {
-			LogDebugResponse(start, resp)
+			if attempt > 1 {
+				err = fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return resp, err
 		}
 
-		if triesLeft > 0 && isRetryable(resp.StatusCode) {
-			// Attempt to parse when to retry! Default is 1 second.
-			retryAfter := 1 * time.Second
-
-			if v := resp.Header.Get("Retry-After"); v != "" {
-				// Could be either an integer number of seconds, or an HTTP date.
-				if d, err := strconv.ParseInt(v, 10, 64); err == nil {
-					retryAfter = time.Duration(d) * time.Second
-				}
-
-				if d, err := http.ParseTime(v); err == nil {
-					retryAfter = time.Until(d)
-				}
+		if attempt < attempts && isRetryable(resp.StatusCode) {
+			delay, ok := retryAfter(resp)
+			if !ok {
+				delay = backoff(attempt)
 			}
 
-			if v := resp.Header.Get("X-Retry-In"); v != "" {
-				if d, err := time.ParseDuration(v); err == nil {
-					retryAfter = d
-				}
-			}
+			// Drain the body so the connection can be re-used.
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
 
-			LogWarning("Got %s, retrying in %s", resp.Status, retryAfter.Truncate(time.Millisecond))
-			time.Sleep(retryAfter)
+			LogWarning("Got %s, retrying in %s", resp.Status, delay.Truncate(time.Millisecond))
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, err
+			}
 
 			continue
 		}
@@ -452,6 +960,10 @@ type Response struct {
 	Headers map[string]string `json:"headers"`
 	Links   Links             `json:"links"`
 	Body    interface{}       `json:"body"`
+
+	// HeaderValues holds every value of repeated headers, which are joined
+	// together in `Headers`.
+	HeaderValues http.Header `json:"-"`
 }
 
 // Map returns a map representing this response matching the encoded JSON.
@@ -477,6 +989,14 @@ func (r Response) Map() map[string]any {
 	headers := map[string]any{}
 	for k, v := range r.Headers {
 		headers[k] = v
+		if values := r.HeaderValues[k]; len(values) > 1 {
+			// Expose repeated headers as a list so that each value is usable.
+			list := make([]any, len(values))
+			for i, value := range values {
+				list[i] = value
+			}
+			headers[k] = list
+		}
 	}
 
 	return map[string]any{
@@ -496,10 +1016,24 @@ func ParseResponse(resp *http.Response) (Response, error) {
 	// Handle content encodings
 	defer resp.Body.Close()
 	if err := DecodeResponse(resp); err != nil {
-		return Response{}, err
+		return Response{}, &ErrDecode{Err: err}
 	}
 
-	data, _ := io.ReadAll(resp.Body)
+	data, err := readLimited(resp)
+	var tooLarge *ResponseTooLargeError
+	if errors.As(err, &tooLarge) {
+		if !viper.GetBool("rsh-raw") || viper.GetString("rsh-filter") != "" {
+			return Response{}, err
+		}
+
+		// Raw output doesn't need to be parsed, so save it to a file instead.
+		filename, err := spoolBody(data, resp.Body)
+		if err != nil {
+			return Response{}, err
+		}
+		LogWarning("Response body is larger than the %s limit, saved it to %s", tooLarge.Limit, filename)
+		data = nil
+	}
 
 	if len(data) > 0 {
 		if viper.GetBool("rsh-raw") && viper.GetString("rsh-filter") == "" {
@@ -516,11 +1050,12 @@ func ParseResponse(resp *http.Response) (Response, error) {
 	// Wrap the body to describe the entire response
 	headers := map[string]string{}
 	output := Response{
-		Proto:   resp.Proto,
-		Status:  resp.StatusCode,
-		Headers: headers,
-		Links:   Links{},
-		Body:    parsed,
+		Proto:        resp.Proto,
+		Status:       resp.StatusCode,
+		Headers:      headers,
+		Links:        Links{},
+		Body:         parsed,
+		HeaderValues: resp.Header,
 	}
 
 	for k, v := range resp.Header {
@@ -548,98 +1083,143 @@ func GetParsedResponse(req *http.Request, options ...requestOption) (Response, e
 		return Response{}, err
 	}
 
+	return getParsedResponse(req, resp, options...)
+}
+
+// ParseResponseFor parses the response of a request made via `MakeRequest`
+// like `GetParsedResponse` does, e.g. after reading the raw body to verify
+// it. The body must still be readable.
+func ParseResponseFor(req *http.Request, resp *http.Response, options ...requestOption) (Response, error) {
+	return getParsedResponse(req, resp, options...)
+}
+
+// getParsedResponse parses an already made request's response, following any
+// pagination links.
+func getParsedResponse(req *http.Request, resp *http.Response, options ...requestOption) (Response, error) {
 	parsed, err := ParseResponse(resp)
 	if err != nil {
 		LogError("Parse response error")
 		return Response{}, err
 	}
 
-	computedSize := int64(0)
-	if s, err := strconv.ParseInt(parsed.Headers["Content-Length"], 10, 64); err == nil {
-		computedSize = s
+	if err := checkHTMLResponse(req, parsed); err != nil {
+		return Response{}, &ErrDecode{Err: err}
 	}
 
-	base := req.URL
-	allLinks := parsed.Links
-	for {
-		links := parsed.Links
-		if len(links["next"]) == 0 || viper.GetBool("rsh-no-paginate") {
-			break
-		}
+	parsed, err = paginate(req, parsed, options...)
+	if err != nil {
+		return parsed, err
+	}
 
-		LogDebug("Found pagination via rel=next link: %s", links["next"][0].URI)
+	conf := &requestConfig{}
+	for _, opt := range options {
+		opt(conf)
+	}
+	if conf.statusError {
+		return parsed, StatusError(parsed, req.URL.String())
+	}
+	return parsed, nil
+}
 
-		if _, ok := parsed.Body.([]interface{}); !ok {
-			// TODO: support non-list formats like JSON:API
-			LogWarning("Skipping auto-pagination: response body not a list, not sure how to merge")
-			break
+// MakeRequestAndFormat is a convenience function for calling `GetParsedResponse`
+// and then calling the default formatter's `Format` function with the parsed
+// response. Panics on error. If curl output is enabled, the request is
+// printed as a curl command instead of being sent.
+func MakeRequestAndFormat(req *http.Request) {
+	if viper.GetBool("rsh-curl") {
+		if err := PrepareRequest(req); err != nil {
+			panic(err)
 		}
-
-		// Make the next request
-		next, _ := url.Parse(links["next"][0].URI)
-		next = base.ResolveReference(next)
-		req, _ = http.NewRequest(http.MethodGet, next.String(), nil)
-
-		resp, err = MakeRequest(req, options...)
+		cmd, err := CurlCommand(req, !viper.GetBool("rsh-show-secrets"))
 		if err != nil {
-			return Response{}, err
+			panic(err)
 		}
+		fmt.Fprintln(Stdout, cmd)
+		return
+	}
 
-		// Merge the responses
-		parsedNext, err := ParseResponse(resp)
-		if err != nil {
-			return Response{}, err
+	if benchmarkEnabled() {
+		if err := benchmark(req); err != nil {
+			panic(err)
 		}
+		return
+	}
 
-		if l, ok := parsedNext.Body.([]interface{}); ok {
-			// The last request in the chain will be the one that gets displayed
-			// for the proto/status/headers, plus the merged body/links.
-			parsed.Proto = parsedNext.Proto
-			parsed.Status = parsedNext.Status
-			parsed.Headers = parsedNext.Headers
-			parsed.Links = parsedNext.Links
-			parsed.Body = append(parsed.Body.([]interface{}), l...)
+	if filename := viper.GetString("rsh-output-file"); filename != "" {
+		if err := DownloadToFile(req, filename, viper.GetString("rsh-continue-at")); err != nil {
+			panic(err)
+		}
+		return
+	}
 
-			for name, links := range parsedNext.Links {
-				allLinks[name] = append(allLinks[name], links...)
-			}
+	if viper.GetBool("rsh-raw-body") {
+		if viper.GetString("rsh-filter") != "" {
+			panic(errors.New("--rsh-raw-body outputs the unparsed body and cannot be combined with a filter"))
+		}
+		if err := WriteRawBody(req, Stdout); err != nil {
+			panic(err)
+		}
+		return
+	}
 
-			// Update the total computed size to include the size of each individual
-			// request if the content size is available.
-			if s, err := strconv.ParseInt(parsedNext.Headers["Content-Length"], 10, 64); err == nil {
-				computedSize += s
-			}
-		} else {
-			LogWarning("Auto-pagination next page is not a list, aborting")
-			break
+	if viper.GetBool("rsh-status-only") {
+		// The caller is handling the status, so only set the exit code from it
+		// when explicitly asked to.
+		options := []requestOption{}
+		if !viper.GetBool("rsh-fail") {
+			options = append(options, IgnoreStatus())
+		}
+		resp, err := MakeRequest(req, options...)
+		if err != nil {
+			panic(err)
 		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		fmt.Fprintln(Stdout, resp.StatusCode)
+		return
 	}
 
-	// Set the final response links as a combination of all.
-	parsed.Links = allLinks
+	sse := viper.GetBool("rsh-sse")
+	if sse && req.Header.Get("accept") == "" {
+		req.Header.Set("accept", "text/event-stream")
+	}
 
-	if computedSize > 0 {
-		parsed.Headers["Content-Length"] = fmt.Sprintf("%d", computedSize)
+	resp, err := MakeRequest(req)
+	if err != nil {
+		panic(err)
 	}
 
-	return parsed, nil
-}
+	if resp.StatusCode < 300 && (sse || isEventStream(resp)) {
+		// Event streams may never end, so print each event as it arrives
+		// instead of waiting for the full body.
+		if err := StreamEvents(req, resp); err != nil {
+			panic(err)
+		}
+		return
+	}
 
-// MakeRequestAndFormat is a convenience function for calling `GetParsedResponse`
-// and then calling the default formatter's `Format` function with the parsed
-// response. Panics on error.
-func MakeRequestAndFormat(req *http.Request) {
-	parsed, err := GetParsedResponse(req)
+	parsed, err := getParsedResponse(req, resp)
 	if err != nil {
 		panic(err)
 	}
 
+	if rels := viper.GetString("rsh-follow"); rels != "" {
+		parsed, err = followLinks(resp.Request.URL, parsed, strings.Split(rels, ","), viper.GetInt("rsh-max-hops"))
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	if err := Formatter.Format(parsed); err != nil {
 		if e, ok := err.(shorthand.Error); ok {
 			panic(e.Pretty())
 		}
 		panic(err)
 	}
+
+	if err := checkAssertions(parsed, viper.GetStringSlice("rsh-assert")); err != nil {
+		panic(err)
+	}
 }
 
 // BestEffortSystemCertPool returns system cert pool as best effort, otherwise an empty cert pool
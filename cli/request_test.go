@@ -2,13 +2,21 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/h2non/gock.v1"
 )
 
@@ -71,6 +79,7 @@ func (a *authHookFailure) OnRequest(req *http.Request, key string, params map[st
 }
 
 func TestAuthHookFailure(t *testing.T) {
+	reset(false)
 	configs["auth-hook-fail"] = &APIConfig{
 		Profiles: map[string]*APIProfile{
 			"default": {
@@ -84,16 +93,225 @@ func TestAuthHookFailure(t *testing.T) {
 	authHandlers["hook-fail"] = &authHookFailure{}
 
 	r, _ := http.NewRequest(http.MethodGet, "/test", nil)
-	assert.PanicsWithError(t, "some-error", func() {
-		MakeRequest(r)
-	})
+	_, err := MakeRequest(r)
+	assert.EqualError(t, err, "some-error")
+
+	var authErr *ErrAuth
+	require.ErrorAs(t, err, &authErr)
+	assert.Equal(t, "hook-fail", authErr.Name)
+
+	// Printing the request as curl fails the same way.
+	r, _ = http.NewRequest(http.MethodGet, "/test", nil)
+	err = PrepareRequest(r)
+	require.ErrorAs(t, err, &authErr)
+}
+
+func TestProfileDefaults(t *testing.T) {
+	reset(false)
+	t.Setenv("TENANT", "acme")
+
+	configs["profile-defaults"] = &APIConfig{
+		name: "profile-defaults",
+		Base: "https://profile-defaults.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {},
+			"stage": {
+				Headers: map[string]string{
+					"X-Env":    "stage",
+					"X-Tenant": "${TENANT}",
+				},
+				Query: map[string]string{
+					"tenant": "${TENANT}-stage",
+				},
+			},
+		},
+	}
+
+	capture := &strings.Builder{}
+	Stderr = capture
+	verbosity = LevelRequest
+	defer func() { verbosity = 0 }()
+
+	defer viper.Set("rsh-profile", "default")
+	defer viper.Set("rsh-header", []string{})
+	defer viper.Set("rsh-query", []string{})
+
+	viper.Set("rsh-profile", "stage")
+	viper.Set("rsh-header", []string{"x-env:override", "X-Other:1"})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://profile-defaults.example.com/items", nil)
+	PrepareRequest(req)
+
+	assert.Equal(t, []string{"override"}, req.Header.Values("X-Env"))
+	assert.Equal(t, "acme", req.Header.Get("X-Tenant"))
+	assert.Equal(t, "1", req.Header.Get("X-Other"))
+	assert.Equal(t, "acme-stage", req.URL.Query().Get("tenant"))
+
+	assert.Contains(t, capture.String(), "Header X-Tenant: acme (from profile stage)")
+	assert.Contains(t, capture.String(), "Query param tenant=acme-stage (from profile stage)")
+	assert.Contains(t, capture.String(), "Header x-env: override (overrides profile stage)")
+
+	// Query params from the commandline also override the profile.
+	viper.Set("rsh-header", []string{})
+	viper.Set("rsh-query", []string{"tenant=other"})
+	req, _ = http.NewRequest(http.MethodGet, "https://profile-defaults.example.com/items", nil)
+	PrepareRequest(req)
+
+	assert.Equal(t, "stage", req.Header.Get("X-Env"))
+	assert.Equal(t, []string{"other"}, req.URL.Query()["tenant"])
+}
+
+func TestProfileSecretsRedacted(t *testing.T) {
+	reset(false)
+
+	configs["profile-secrets"] = &APIConfig{
+		name: "profile-secrets",
+		Base: "https://profile-secrets.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Headers: map[string]string{
+					"Authorization": "Bearer abc123",
+				},
+				Query: map[string]string{
+					"api_key": "secret",
+				},
+			},
+		},
+	}
+
+	capture := &strings.Builder{}
+	Stderr = capture
+	verbosity = LevelRequest
+	defer func() { verbosity = 0 }()
+
+	defer viper.Set("rsh-header", []string{})
+	defer viper.Set("rsh-show-secrets", false)
+
+	viper.Set("rsh-header", []string{"authorization:Bearer other"})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://profile-secrets.example.com/items", nil)
+	PrepareRequest(req)
+
+	assert.Equal(t, "Bearer other", req.Header.Get("Authorization"))
+	assert.Contains(t, capture.String(), "Header Authorization: "+redact("Bearer abc123")+" (from profile default)")
+	assert.Contains(t, capture.String(), "Query param api_key="+redact("secret")+" (from profile default)")
+	assert.Contains(t, capture.String(), "Header authorization: "+redact("Bearer other")+" (overrides profile default)")
+	assert.NotContains(t, capture.String(), "abc123")
+	assert.NotContains(t, capture.String(), "=secret")
+
+	// Secrets are only shown when explicitly requested.
+	capture.Reset()
+	viper.Set("rsh-show-secrets", true)
+	req, _ = http.NewRequest(http.MethodGet, "https://profile-secrets.example.com/items", nil)
+	PrepareRequest(req)
+
+	assert.Contains(t, capture.String(), "Header Authorization: Bearer abc123 (from profile default)")
+}
+
+func TestHeaderArgs(t *testing.T) {
+	// Use a raw listener since Go's server canonicalizes header names.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	raw := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		n, _ := conn.Read(buf)
+		raw <- string(buf[:n])
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nX-Filter: a\r\nX-Filter: b\r\nContent-Length: 2\r\n\r\n{}"))
+	}()
+
+	out := run("-o json -f headers.X-Filter http://" + ln.Addr().String() + "/ -H x-filter:a -H x-filter:b -H User-Agent:")
+	assert.JSONEq(t, `["a", "b"]`, out)
+
+	request := <-raw
+	assert.Contains(t, request, "\r\nx-filter: a\r\nx-filter: b\r\n")
+	assert.NotContains(t, request, "X-Filter")
+	assert.NotContains(t, request, "User-Agent")
+}
+
+func TestHeaderRemoveProfile(t *testing.T) {
+	reset(false)
+	configs["header-remove"] = &APIConfig{
+		name: "header-remove",
+		Base: "https://header-remove.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Headers: map[string]string{"X-Tenant": "acme"},
+			},
+		},
+	}
+	defer delete(configs, "header-remove")
+	defer viper.Set("rsh-header", []string{})
+
+	viper.Set("rsh-header", []string{"X-Tenant:", "Accept:"})
+	req, _ := http.NewRequest(http.MethodGet, "https://header-remove.example.com/items", nil)
+	PrepareRequest(req)
+
+	assert.NotContains(t, req.Header, "X-Tenant")
+	assert.NotContains(t, req.Header, "Accept")
+	assert.NotEmpty(t, req.Header.Get("User-Agent"))
+}
+
+func TestWithMediaTypes(t *testing.T) {
+	reset(false)
+	defer viper.Set("rsh-accept", "")
+
+	req, _ := http.NewRequest(http.MethodPut, "http://example.com/", strings.NewReader(`{"id": "a1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	PrepareRequest(req, WithMediaTypes("application/yaml", "application/yaml"))
+	assert.Equal(t, "application/yaml", req.Header.Get("Accept"))
+	assert.Equal(t, "application/yaml", req.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(req.Body)
+	assert.Equal(t, "id: a1\n", string(body))
+
+	// The commandline still wins.
+	viper.Set("rsh-accept", "application/json")
+	req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	PrepareRequest(req, WithMediaTypes("application/yaml", ""))
+	assert.Equal(t, "application/json", req.Header.Get("Accept"))
+}
+
+func TestUserAgent(t *testing.T) {
+	reset(false)
+	defer func() { UserAgent = "" }()
+
+	userAgentFor := func() string {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+		PrepareRequest(req)
+		return req.Header.Get("User-Agent")
+	}
+
+	assert.Equal(t, "restish-"+Root.Version, userAgentFor())
+
+	viper.Set("rsh-user-agent", "from-config")
+	assert.Equal(t, "from-config", userAgentFor())
+
+	UserAgent = "restish/1.0.0 bulk/pull"
+	assert.Equal(t, "restish/1.0.0 bulk/pull", userAgentFor())
+
+	// Only the commandline takes precedence over the override.
+	defer gock.Off()
+	gock.New("http://example.com").
+		Get("/ua").
+		MatchHeader("User-Agent", "^from-flag$").
+		Reply(http.StatusNoContent)
+
+	run("http://example.com/ua --rsh-user-agent from-flag")
+	assert.True(t, gock.IsDone())
 }
 
 func TestGetStatus(t *testing.T) {
 	defer gock.Off()
 
 	reset(false)
-	lastStatus = 0
+	setLastStatus(0)
 
 	gock.New("http://example.com").
 		Get("/").
@@ -112,7 +330,7 @@ func TestIgnoreStatus(t *testing.T) {
 	defer gock.Off()
 
 	reset(false)
-	lastStatus = 0
+	setLastStatus(0)
 
 	gock.New("http://example.com").
 		Get("/").
@@ -127,6 +345,25 @@ func TestIgnoreStatus(t *testing.T) {
 	assert.Equal(t, 0, GetLastStatus())
 }
 
+func TestRequestWithoutRedirects(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+
+	gock.New("http://example.com").
+		Put("/old").
+		Reply(http.StatusPermanentRedirect).
+		SetHeader("Location", "/new")
+
+	req, _ := http.NewRequest(http.MethodPut, "http://example.com/old", nil)
+	resp, err := MakeRequest(req, WithoutRedirects())
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+	assert.Equal(t, "/new", resp.Header.Get("Location"))
+	assert.True(t, gock.IsDone())
+}
+
 func TestRequestRetryIn(t *testing.T) {
 	defer gock.Off()
 
@@ -184,6 +421,84 @@ func TestRequestRetryAfter(t *testing.T) {
 	assert.Equal(t, resp.StatusCode, http.StatusOK)
 }
 
+func TestRequestRetryCancelled(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+	viper.Set("rsh-retry", 1)
+
+	gock.New("http://example.com").
+		Get("/").
+		Times(1).
+		Reply(http.StatusTooManyRequests).
+		SetHeader("X-Retry-In", "1h")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/", nil)
+	_, err := MakeRequest(req)
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+type signingAuth struct {
+	signed int
+}
+
+func (a *signingAuth) Parameters() []AuthParam {
+	return nil
+}
+
+func (a *signingAuth) OnRequest(req *http.Request, key string, params map[string]string) error {
+	a.signed++
+	req.Header.Set("X-Signature", fmt.Sprintf("sig%d", a.signed))
+	return nil
+}
+
+func (a *signingAuth) OnRetry(req *http.Request, key string, params map[string]string) error {
+	return a.OnRequest(req, key, params)
+}
+
+func TestRequestRetryResign(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+	viper.Set("rsh-retry", 1)
+
+	auth := &signingAuth{}
+	AddAuth("test-signing", auth)
+	defer delete(authHandlers, "test-signing")
+
+	configs["signing-test"] = &APIConfig{
+		name: "signing-test",
+		Base: "http://signing.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {Auth: &APIAuth{Name: "test-signing"}},
+		},
+	}
+
+	gock.New("http://signing.example.com").
+		Get("/").
+		MatchHeader("X-Signature", "sig1").
+		Times(1).
+		Reply(http.StatusTooManyRequests).
+		SetHeader("X-Retry-In", "1ms")
+
+	gock.New("http://signing.example.com").
+		Get("/").
+		MatchHeader("X-Signature", "sig2").
+		Times(1).
+		Reply(http.StatusOK)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://signing.example.com/", nil)
+	resp, err := MakeRequest(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, auth.signed)
+}
+
 func TestRequestRetryTimeout(t *testing.T) {
 	defer gock.Off()
 
@@ -196,7 +511,7 @@ func TestRequestRetryTimeout(t *testing.T) {
 		Get("/").
 		Times(2).
 		Reply(http.StatusOK).
-		Delay(2 * time.Millisecond)
+		Delay(50 * time.Millisecond)
 		// Note: delay seems to have a bug where subsequent requests without the
 		// delay are still delayed... For now just have it reply twice.
 
@@ -205,4 +520,284 @@ func TestRequestRetryTimeout(t *testing.T) {
 
 	assert.Error(t, err)
 	assert.ErrorContains(t, err, "timed out")
+	assert.ErrorContains(t, err, "giving up after 2 attempts")
+
+	// The timeout applies to each attempt, so the retry is actually sent.
+	assert.True(t, gock.IsDone(), "pending mocks: %d", len(gock.Pending()))
+}
+
+func TestRequestRetryConnectionError(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+	viper.Set("rsh-retry", 2)
+	retryBaseDelay = time.Millisecond
+	defer func() { retryBaseDelay = time.Second }()
+
+	gock.New("http://example.com").
+		Get("/").
+		Times(3).
+		ReplyError(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	_, err := MakeRequest(req)
+
+	assert.ErrorContains(t, err, "giving up after 3 attempts")
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestRequestRetryUnsafe(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+	viper.Set("rsh-retry", 1)
+	retryBaseDelay = time.Millisecond
+	defer func() { retryBaseDelay = time.Second }()
+
+	gock.New("http://example.com").
+		Post("/").
+		Times(1).
+		Reply(http.StatusServiceUnavailable)
+
+	gock.New("http://example.com").
+		Post("/").
+		Times(1).
+		Reply(http.StatusOK)
+
+	// POST is not idempotent, so it isn't retried by default.
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/", bytes.NewReader([]byte("hello")))
+	resp, err := MakeRequest(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	gock.New("http://example.com").
+		Post("/").
+		Times(1).
+		Reply(http.StatusServiceUnavailable)
+
+	viper.Set("rsh-retry-unsafe", true)
+	defer viper.Set("rsh-retry-unsafe", false)
+
+	req, _ = http.NewRequest(http.MethodPost, "http://example.com/", bytes.NewReader([]byte("hello")))
+	resp, err = MakeRequest(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	for attempt, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: retryMaxDelay} {
+		delay := backoff(attempt)
+		assert.GreaterOrEqual(t, delay, max/2)
+		assert.LessOrEqual(t, delay, max)
+	}
+}
+
+func TestLogFormatJSON(t *testing.T) {
+	reset(false)
+	defer viper.Set("rsh-log-format", "text")
+	defer func() { verbosity = 0 }()
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("X-Retry-In", "1ms")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer ts.Close()
+
+	out := run("-v --rsh-log-format json --rsh-retry 1 -o json " + ts.URL + "/items?api_key=secret -H Authorization:abc123")
+
+	events := []map[string]any{}
+	for _, line := range strings.Split(out, "\n") {
+		var event map[string]any
+		if json.Unmarshal([]byte(line), &event) == nil && event["event"] != nil {
+			events = append(events, event)
+		}
+	}
+
+	require.Len(t, events, 6)
+	assert.Equal(t, "request", events[0]["event"])
+	assert.Equal(t, http.MethodGet, events[0]["method"])
+	assert.Equal(t, ts.URL+"/items?api_key="+redact("secret"), events[0]["url"])
+	assert.Equal(t, []any{redact("abc123")}, events[0]["headers"].(map[string]any)["Authorization"])
+	assert.Equal(t, 0.0, events[0]["retry"])
+
+	assert.Equal(t, "response", events[1]["event"])
+	assert.Equal(t, 503.0, events[1]["status"])
+
+	assert.Equal(t, "timings", events[2]["event"])
+	assert.Equal(t, false, events[2]["timings"].(map[string]any)["reused"])
+
+	assert.Equal(t, "response", events[4]["event"])
+	assert.Equal(t, 200.0, events[4]["status"])
+	assert.Equal(t, 12.0, events[4]["bytes"])
+	assert.Equal(t, 1.0, events[4]["retry"])
+	assert.Contains(t, events[4], "duration_ms")
+
+	// The retry reuses the connection, so there is no connect phase.
+	timings := events[5]["timings"].(map[string]any)
+	assert.Equal(t, "timings", events[5]["event"])
+	assert.Equal(t, true, timings["reused"])
+	assert.NotContains(t, timings, "connect_ms")
+	assert.Contains(t, timings, "total_ms")
+
+	// Other log messages are JSON too.
+	assert.Contains(t, out, `"level":"warn","message":"Got 503 Service Unavailable, retrying in`)
+	assert.NotContains(t, out, "DEBUG:")
+}
+
+func TestVerboseRedactsSecrets(t *testing.T) {
+	reset(false)
+	defer viper.Set("rsh-show-secrets", false)
+	defer func() { verbosity = 0 }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "abc123", r.Header.Get("Authorization"))
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "xyz789"})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	out := run("-v " + ts.URL + "/items?api_key=secret -H Authorization:abc123")
+	assert.Contains(t, out, "Authorization: "+redact("abc123"))
+	assert.Contains(t, out, "api_key="+redact("secret"))
+	assert.Contains(t, out, "Set-Cookie: "+redact("session=xyz789"))
+	assert.NotContains(t, out, "abc123")
+
+	out = run("-v --rsh-show-secrets " + ts.URL + "/items -H Authorization:abc123")
+	assert.Contains(t, out, "Authorization: abc123")
+	assert.Contains(t, out, "session=xyz789")
+}
+
+func TestTimings(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer ts.Close()
+
+	out := run("--rsh-timings " + ts.URL + "/items")
+	assert.Contains(t, out, "INFO: Timings for GET "+ts.URL+"/items:")
+	assert.Contains(t, out, "  TCP connect:")
+	assert.Contains(t, out, "  Total:")
+	assert.Contains(t, out, "  Connection:         new")
+	assert.NotContains(t, out, "TLS handshake:")
+	assert.Contains(t, out, "ok: true")
+}
+
+func TestAcceptOverride(t *testing.T) {
+	defer viper.Set("rsh-accept", "")
+
+	accept := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	run(ts.URL + "/items")
+	assert.Contains(t, accept, "application/cbor")
+
+	run("--rsh-accept application/json " + ts.URL + "/items")
+	assert.Equal(t, "application/json", accept)
+
+	reset(false)
+	configs["accept-test"] = &APIConfig{
+		name:   "accept-test",
+		Base:   ts.URL,
+		Accept: "application/vnd.test+json",
+	}
+	defer delete(configs, "accept-test")
+
+	runNoReset(ts.URL + "/items")
+	assert.Equal(t, "application/vnd.test+json", accept)
+
+	// An explicit header still wins.
+	runNoReset(ts.URL + "/items -H Accept:text/plain")
+	assert.Equal(t, "text/plain", accept)
+}
+
+func TestContentTypeOverride(t *testing.T) {
+	defer viper.Set("rsh-content-type", "")
+
+	contentType := ""
+	body := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	// Shorthand input is marshalled into the forced type.
+	run("--rsh-content-type application/yaml put " + ts.URL + "/items name: foo")
+	assert.Equal(t, "application/yaml", contentType)
+	assert.Equal(t, "name: foo\n", body)
+
+	// Standard input is converted from JSON.
+	WithFakeStdin([]byte(`{"name": "foo"}`), 0, func() {
+		run("--rsh-content-type application/yaml put " + ts.URL + "/items")
+	})
+	assert.Equal(t, "application/yaml", contentType)
+	assert.Equal(t, "name: foo\n", body)
+
+	// The API config applies unless a header is passed.
+	reset(false)
+	viper.Set("rsh-content-type", "")
+	configs["content-type-test"] = &APIConfig{
+		name:        "content-type-test",
+		Base:        ts.URL,
+		ContentType: "application/yaml",
+	}
+	defer delete(configs, "content-type-test")
+
+	runNoReset("put " + ts.URL + "/items name: foo")
+	assert.Equal(t, "application/yaml", contentType)
+	assert.Equal(t, "name: foo\n", body)
+
+	runNoReset("put " + ts.URL + "/items -H Content-Type:application/json name: foo")
+	assert.Equal(t, "application/json", contentType)
+	assert.Equal(t, `{"name":"foo"}`, body)
+}
+
+func TestQueryDefaults(t *testing.T) {
+	query := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	reset(false)
+	configs["query-defaults-test"] = &APIConfig{
+		name: "query-defaults-test",
+		Base: ts.URL,
+		Defaults: &ParamDefaults{
+			Query: map[string]string{"version": "2"},
+			Path:  map[string]string{"org": "acme"},
+		},
+	}
+	defer delete(configs, "query-defaults-test")
+
+	runNoReset(ts.URL + "/items")
+	assert.Equal(t, "version=2", query)
+
+	runNoReset(ts.URL + "/items?version=5")
+	assert.Equal(t, "version=5", query)
+
+	runNoReset(ts.URL + "/items -q version=7")
+	assert.Equal(t, "version=7", query)
+
+	org, ok := PathDefault(ts.URL+"/items", "org")
+	assert.True(t, ok)
+	assert.Equal(t, "acme", org)
+
+	_, ok = PathDefault(ts.URL+"/items", "missing")
+	assert.False(t, ok)
 }
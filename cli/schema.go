@@ -0,0 +1,493 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema is a subset of JSON Schema describing an operation's request body,
+// which is used to validate input before sending it. Loaders convert their
+// API description formats into this so it can be cached with the API.
+// Recursive schemas are cut off, and a nil schema allows any value.
+type Schema struct {
+	Type                 []string           `json:"type,omitempty" yaml:"type,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty" yaml:"nullable,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Default              any                `json:"default,omitempty" yaml:"default,omitempty"`
+	Example              any                `json:"example,omitempty" yaml:"example,omitempty"`
+	Enum                 []any              `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusive_minimum,omitempty" yaml:"exclusive_minimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusive_maximum,omitempty" yaml:"exclusive_maximum,omitempty"`
+	MultipleOf           *float64           `json:"multiple_of,omitempty" yaml:"multiple_of,omitempty"`
+	MinLength            *int64             `json:"min_length,omitempty" yaml:"min_length,omitempty"`
+	MaxLength            *int64             `json:"max_length,omitempty" yaml:"max_length,omitempty"`
+	Pattern              string             `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	PrefixItems          []*Schema          `json:"prefix_items,omitempty" yaml:"prefix_items,omitempty"`
+	Items                *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	MinItems             *int64             `json:"min_items,omitempty" yaml:"min_items,omitempty"`
+	MaxItems             *int64             `json:"max_items,omitempty" yaml:"max_items,omitempty"`
+	UniqueItems          bool               `json:"unique_items,omitempty" yaml:"unique_items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty" yaml:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additional_properties,omitempty" yaml:"additional_properties,omitempty"`
+	NoAdditional         bool               `json:"no_additional,omitempty" yaml:"no_additional,omitempty"`
+	MinProperties        *int64             `json:"min_properties,omitempty" yaml:"min_properties,omitempty"`
+	MaxProperties        *int64             `json:"max_properties,omitempty" yaml:"max_properties,omitempty"`
+	AllOf                []*Schema          `json:"all_of,omitempty" yaml:"all_of,omitempty"`
+	AnyOf                []*Schema          `json:"any_of,omitempty" yaml:"any_of,omitempty"`
+	OneOf                []*Schema          `json:"one_of,omitempty" yaml:"one_of,omitempty"`
+	Not                  *Schema            `json:"not,omitempty" yaml:"not,omitempty"`
+	ReadOnly             bool               `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+}
+
+// normalizeValue converts decoded values into the same types that the JSON
+// decoder produces so they can be compared, e.g. enum values loaded from the
+// API cache or YAML input.
+func normalizeValue(v any) any {
+	switch t := v.(type) {
+	case int:
+		return float64(t)
+	case int8:
+		return float64(t)
+	case int16:
+		return float64(t)
+	case int32:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case uint:
+		return float64(t)
+	case uint8:
+		return float64(t)
+	case uint16:
+		return float64(t)
+	case uint32:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	case float32:
+		return float64(t)
+	case []any:
+		n := make([]any, len(t))
+		for i, item := range t {
+			n[i] = normalizeValue(item)
+		}
+		return n
+	case map[any]any:
+		n := make(map[string]any, len(t))
+		for k, item := range t {
+			n[fmt.Sprintf("%v", k)] = normalizeValue(item)
+		}
+		return n
+	case map[string]any:
+		n := make(map[string]any, len(t))
+		for k, item := range t {
+			n[k] = normalizeValue(item)
+		}
+		return n
+	}
+	return v
+}
+
+// jsonType returns the JSON Schema type name of a normalized value.
+func jsonType(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if t == math.Trunc(t) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// typeMatches returns true if the value's type is allowed by the schema.
+func (s *Schema) typeMatches(v any) bool {
+	if len(s.Type) == 0 {
+		return true
+	}
+
+	actual := jsonType(v)
+	if actual == "null" && s.Nullable {
+		return true
+	}
+	for _, t := range s.Type {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// pointerEscape escapes a JSON pointer path segment per RFC 6901.
+func pointerEscape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// Validate checks a value decoded from JSON or another structured format
+// against the schema and returns a list of errors, each prefixed with a JSON
+// pointer to the invalid part of the value, e.g.
+// `/items/0/price: expected number, got string`.
+func (s *Schema) Validate(value any) []string {
+	errs := s.validate("", normalizeValue(value))
+	sort.Strings(errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, v any) []string {
+	if s == nil {
+		return nil
+	}
+
+	loc := path
+	if loc == "" {
+		loc = "/"
+	}
+
+	errs := []string{}
+	fail := func(format string, args ...any) {
+		errs = append(errs, loc+": "+fmt.Sprintf(format, args...))
+	}
+
+	for _, sub := range s.AllOf {
+		errs = append(errs, sub.validate(path, v)...)
+	}
+
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, sub := range s.AnyOf {
+			if len(sub.validate(path, v)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("does not match any of the allowed schemas")
+		}
+	}
+
+	if len(s.OneOf) > 0 {
+		matches := 0
+		var firstErrs []string
+		for _, sub := range s.OneOf {
+			subErrs := sub.validate(path, v)
+			if len(subErrs) == 0 {
+				matches++
+			} else if firstErrs == nil {
+				firstErrs = subErrs
+			}
+		}
+		if matches == 0 {
+			if len(s.OneOf) == 1 {
+				errs = append(errs, firstErrs...)
+			} else {
+				fail("does not match any of the allowed schemas")
+			}
+		} else if matches > 1 {
+			fail("matches more than one of the allowed schemas")
+		}
+	}
+
+	if s.Not != nil && len(s.Not.validate(path, v)) == 0 {
+		fail("matches a schema which is not allowed")
+	}
+
+	if !s.typeMatches(v) {
+		fail("expected %s, got %s", strings.Join(s.Type, " or "), jsonType(v))
+		return errs
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(normalizeValue(e), v) {
+				found = true
+				break
+			}
+		}
+		if !found && len(s.Enum) == 1 {
+			fail("expected %v, got %v", s.Enum[0], v)
+		} else if !found {
+			allowed := make([]string, len(s.Enum))
+			for i, e := range s.Enum {
+				allowed[i] = fmt.Sprintf("%v", e)
+			}
+			fail("expected one of [%s], got %v", strings.Join(allowed, ", "), v)
+		}
+	}
+
+	switch t := v.(type) {
+	case float64:
+		if s.Minimum != nil && t < *s.Minimum {
+			fail("expected number >= %v, got %v", *s.Minimum, t)
+		}
+		if s.ExclusiveMinimum != nil && t <= *s.ExclusiveMinimum {
+			fail("expected number > %v, got %v", *s.ExclusiveMinimum, t)
+		}
+		if s.Maximum != nil && t > *s.Maximum {
+			fail("expected number <= %v, got %v", *s.Maximum, t)
+		}
+		if s.ExclusiveMaximum != nil && t >= *s.ExclusiveMaximum {
+			fail("expected number < %v, got %v", *s.ExclusiveMaximum, t)
+		}
+		if s.MultipleOf != nil && *s.MultipleOf != 0 {
+			if q := t / *s.MultipleOf; q != math.Trunc(q) {
+				fail("expected multiple of %v, got %v", *s.MultipleOf, t)
+			}
+		}
+	case string:
+		length := int64(len([]rune(t)))
+		if s.MinLength != nil && length < *s.MinLength {
+			fail("expected length >= %d, got %d", *s.MinLength, length)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail("expected length <= %d, got %d", *s.MaxLength, length)
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(t) {
+				fail("expected string matching pattern %s", s.Pattern)
+			}
+		}
+		if msg := checkFormat(s.Format, t); msg != "" {
+			fail("%s", msg)
+		}
+	case []any:
+		count := int64(len(t))
+		if s.MinItems != nil && count < *s.MinItems {
+			fail("expected at least %d items, got %d", *s.MinItems, count)
+		}
+		if s.MaxItems != nil && count > *s.MaxItems {
+			fail("expected at most %d items, got %d", *s.MaxItems, count)
+		}
+		if s.UniqueItems {
+			for i := range t {
+				for j := i + 1; j < len(t); j++ {
+					if reflect.DeepEqual(t[i], t[j]) {
+						fail("expected unique items, but %d and %d are equal", i, j)
+					}
+				}
+			}
+		}
+		for i, item := range t {
+			itemSchema := s.Items
+			if i < len(s.PrefixItems) {
+				itemSchema = s.PrefixItems[i]
+			}
+			errs = append(errs, itemSchema.validate(fmt.Sprintf("%s/%d", path, i), item)...)
+		}
+	case map[string]any:
+		count := int64(len(t))
+		if s.MinProperties != nil && count < *s.MinProperties {
+			fail("expected at least %d properties, got %d", *s.MinProperties, count)
+		}
+		if s.MaxProperties != nil && count > *s.MaxProperties {
+			fail("expected at most %d properties, got %d", *s.MaxProperties, count)
+		}
+		for _, name := range s.Required {
+			if _, ok := t[name]; !ok {
+				if prop := s.Properties[name]; prop != nil && prop.ReadOnly {
+					// Read-only properties are set by the server.
+					continue
+				}
+				errs = append(errs, path+"/"+pointerEscape(name)+": missing required property")
+			}
+		}
+		for name, item := range t {
+			propPath := path + "/" + pointerEscape(name)
+			if prop, ok := s.Properties[name]; ok {
+				errs = append(errs, prop.validate(propPath, item)...)
+			} else if s.AdditionalProperties != nil {
+				errs = append(errs, s.AdditionalProperties.validate(propPath, item)...)
+			} else if s.NoAdditional {
+				errs = append(errs, propPath+": unexpected property")
+			}
+		}
+	}
+
+	return errs
+}
+
+// GenExample generates an example value for the schema, preferring examples,
+// defaults, and enum values over placeholders for the type. Objects only
+// include required properties unless none are required, and read-only
+// properties are skipped since they are set by the server.
+func (s *Schema) GenExample() any {
+	if s == nil {
+		return nil
+	}
+
+	if len(s.AllOf) > 0 {
+		merged := map[string]any{}
+		for _, sub := range s.AllOf {
+			if m, ok := sub.GenExample().(map[string]any); ok {
+				for k, v := range m {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+
+	for _, choices := range [][]*Schema{s.OneOf, s.AnyOf} {
+		if len(choices) > 0 {
+			return choices[0].GenExample()
+		}
+	}
+
+	if s.Example != nil {
+		return normalizeValue(s.Example)
+	}
+
+	if s.Default != nil {
+		return normalizeValue(s.Default)
+	}
+
+	if len(s.Enum) > 0 {
+		return normalizeValue(s.Enum[0])
+	}
+
+	switch s.Format {
+	case "date":
+		return "2020-05-14"
+	case "date-time":
+		return "2020-05-14T23:44:51-07:00"
+	case "email", "idn-email":
+		return "user@example.com"
+	case "ipv4":
+		return "192.0.2.1"
+	case "ipv6":
+		return "2001:db8::1"
+	case "uri", "iri":
+		return "https://example.com/"
+	case "uuid":
+		return "3e4666bf-d5e5-4aa7-b8ce-cefe41c7568a"
+	}
+
+	typ := ""
+	for _, t := range s.Type {
+		// Use the first non-null type.
+		if t != "null" {
+			typ = t
+			break
+		}
+	}
+
+	switch typ {
+	case "boolean":
+		return true
+	case "integer", "number":
+		n := 1.0
+		if s.Minimum != nil {
+			n = *s.Minimum
+		} else if s.ExclusiveMinimum != nil {
+			n = *s.ExclusiveMinimum + 1
+		} else if s.Maximum != nil && *s.Maximum < n {
+			n = *s.Maximum
+		} else if s.ExclusiveMaximum != nil && *s.ExclusiveMaximum <= n {
+			n = *s.ExclusiveMaximum - 1
+		}
+		if typ == "integer" {
+			return math.Ceil(n)
+		}
+		return n
+	case "string":
+		length := 6
+		if s.MinLength != nil && *s.MinLength > 6 {
+			length = int(*s.MinLength)
+		} else if s.MaxLength != nil && *s.MaxLength < 6 {
+			length = int(*s.MaxLength)
+		}
+		if length == 6 {
+			return "string"
+		}
+		return strings.Repeat("s", length)
+	case "array":
+		count := 1
+		if s.MinItems != nil && *s.MinItems > 1 {
+			count = int(*s.MinItems)
+		}
+		if len(s.PrefixItems) > count {
+			count = len(s.PrefixItems)
+		}
+		items := make([]any, count)
+		for i := range items {
+			if i < len(s.PrefixItems) {
+				items[i] = s.PrefixItems[i].GenExample()
+			} else {
+				items[i] = s.Items.GenExample()
+			}
+		}
+		return items
+	case "object":
+		value := map[string]any{}
+		names := s.Required
+		if len(names) == 0 {
+			for name := range s.Properties {
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			prop := s.Properties[name]
+			if prop != nil && prop.ReadOnly {
+				continue
+			}
+			value[name] = prop.GenExample()
+		}
+		return value
+	}
+
+	return nil
+}
+
+// checkFormat returns an error message if a string does not match one of the
+// common JSON Schema formats. Unknown formats are ignored.
+func checkFormat(format, value string) string {
+	valid := true
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		valid = err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		valid = err == nil
+	case "email", "idn-email":
+		valid = strings.Contains(strings.TrimPrefix(value, "@"), "@")
+	case "ipv4":
+		ip := net.ParseIP(value)
+		valid = ip != nil && ip.To4() != nil && !strings.Contains(value, ":")
+	case "ipv6":
+		ip := net.ParseIP(value)
+		valid = ip != nil && strings.Contains(value, ":")
+	case "uri", "iri":
+		u, err := url.Parse(value)
+		valid = err == nil && u.Scheme != ""
+	case "uuid":
+		valid = uuidPattern.MatchString(value)
+	}
+
+	if !valid {
+		return fmt.Sprintf("expected %s format, got %q", format, value)
+	}
+	return ""
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
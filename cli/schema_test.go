@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestSchemaValidate(t *testing.T) {
+	item := &Schema{
+		Type: []string{"object"},
+		Properties: map[string]*Schema{
+			"id":    {Type: []string{"string"}, ReadOnly: true},
+			"name":  {Type: []string{"string"}, MinLength: ptr[int64](1)},
+			"price": {Type: []string{"number"}, ExclusiveMinimum: ptr(0.0)},
+			"count": {Type: []string{"integer"}},
+			"kind":  {Type: []string{"string"}, Enum: []any{"a", "b"}},
+			"date":  {Type: []string{"string"}, Format: "date"},
+			"note":  {Type: []string{"string"}, Nullable: true},
+		},
+		Required:     []string{"id", "name", "price"},
+		NoAdditional: true,
+	}
+
+	schema := &Schema{
+		Type: []string{"object"},
+		Properties: map[string]*Schema{
+			"items": {Type: []string{"array"}, Items: item, MinItems: ptr[int64](1)},
+			"tags":  {Type: []string{"object"}, AdditionalProperties: &Schema{Type: []string{"string"}}},
+		},
+	}
+
+	cases := []struct {
+		name  string
+		input string
+		errs  []string
+	}{
+		{
+			name:  "valid",
+			input: `{"items": [{"name": "foo", "price": 1.5, "count": 2, "kind": "a", "date": "2023-01-02", "note": null}], "tags": {"a": "b"}}`,
+			errs:  []string{},
+		},
+		{
+			name:  "wrong type",
+			input: `{"items": [{"name": "foo", "price": "1.5"}]}`,
+			errs:  []string{"/items/0/price: expected number, got string"},
+		},
+		{
+			name:  "constraints",
+			input: `{"items": [{"name": "", "price": 0, "count": 1.5, "kind": "c", "date": "nope", "extra": true}]}`,
+			errs: []string{
+				"/items/0/count: expected integer, got number",
+				`/items/0/date: expected date format, got "nope"`,
+				"/items/0/extra: unexpected property",
+				"/items/0/kind: expected one of [a, b], got c",
+				"/items/0/name: expected length >= 1, got 0",
+				"/items/0/price: expected number > 0, got 0",
+			},
+		},
+		{
+			name:  "missing",
+			input: `{"items": [{}], "tags": {"a": 1}}`,
+			errs: []string{
+				"/items/0/name: missing required property",
+				"/items/0/price: missing required property",
+				"/tags/a: expected string, got integer",
+			},
+		},
+		{
+			name:  "root",
+			input: `[]`,
+			errs:  []string{"/: expected object, got array"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var value any
+			assert.NoError(t, json.Unmarshal([]byte(tc.input), &value))
+			assert.Equal(t, tc.errs, schema.Validate(value))
+		})
+	}
+}
+
+func TestSchemaValidateComposition(t *testing.T) {
+	str := &Schema{Type: []string{"string"}}
+	num := &Schema{Type: []string{"number"}}
+
+	anyOf := &Schema{AnyOf: []*Schema{str, num}}
+	assert.Empty(t, anyOf.Validate("foo"))
+	assert.Empty(t, anyOf.Validate(uint64(5)))
+	assert.NotEmpty(t, anyOf.Validate(true))
+
+	oneOf := &Schema{OneOf: []*Schema{num, {Type: []string{"integer"}}}}
+	assert.Empty(t, oneOf.Validate(1.5))
+	assert.Equal(t, []string{"/: matches more than one of the allowed schemas"}, oneOf.Validate(1))
+
+	allOf := &Schema{AllOf: []*Schema{num, {Maximum: ptr(10.0)}}}
+	assert.Equal(t, []string{"/: expected number <= 10, got 11"}, allOf.Validate(11))
+
+	not := &Schema{Not: str}
+	assert.NotEmpty(t, not.Validate("foo"))
+
+	// Enum values loaded from the API cache may not be `float64`.
+	enum := &Schema{Enum: []any{uint64(1), int64(2)}}
+	assert.Empty(t, enum.Validate(2.0))
+}
@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/zalando/go-keyring"
+)
+
+// SecretStore saves secrets like auth tokens and client secrets outside of
+// the plaintext config & cache files.
+type SecretStore interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Delete(key string) error
+}
+
+// keychainPrefix marks config & cache values which are kept in the secret
+// store. The rest of the value is the key to look up.
+const keychainPrefix = "keychain:"
+
+// Secrets is the OS credential store, i.e. the macOS Keychain, Windows
+// Credential Manager, or Secret Service on Linux. It is only accessed when a
+// secret is actually needed so commands which don't use auth never trigger
+// keychain prompts.
+var Secrets SecretStore
+
+// secretParams are the auth params which get moved into the secret store.
+var secretParams = []string{"client_secret", "password", "secret"}
+
+// keyringStore saves secrets in the OS credential store.
+type keyringStore struct {
+	service string
+}
+
+func (s keyringStore) Get(key string) (string, error) {
+	return keyring.Get(s.service, key)
+}
+
+func (s keyringStore) Set(key, value string) error {
+	return keyring.Set(s.service, key, value)
+}
+
+func (s keyringStore) Delete(key string) error {
+	if err := keyring.Delete(s.service, key); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// secretStore returns the secret store to use, or nil if it is disabled.
+func secretStore() SecretStore {
+	if viper.GetBool("rsh-no-keychain") {
+		return nil
+	}
+	return Secrets
+}
+
+// resolveSecret loads a value from the secret store if it is a reference to
+// one, otherwise the value is returned as-is.
+func resolveSecret(value string) (string, error) {
+	if !strings.HasPrefix(value, keychainPrefix) {
+		return value, nil
+	}
+
+	store := secretStore()
+	if store == nil {
+		return "", errors.New("keychain is disabled")
+	}
+
+	return store.Get(strings.TrimPrefix(value, keychainPrefix))
+}
+
+// resolveAuthParams returns the auth params with any secrets loaded from the
+// secret store.
+func resolveAuthParams(params map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(params))
+	for k, v := range params {
+		secret, err := resolveSecret(v)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load auth param %s: %w", k, err)
+		}
+		resolved[k] = secret
+	}
+	return resolved, nil
+}
+
+// GetSecret returns a value from the cache, loading it from the secret store
+// if needed. Values which can't be loaded are treated as missing so that the
+// user gets authenticated again.
+func GetSecret(key string) string {
+	secret, err := resolveSecret(Cache.GetString(key))
+	if err != nil {
+		LogWarning("Unable to load %s from keychain: %v", key, err)
+		return ""
+	}
+	return secret
+}
+
+// SetSecret sets a value in the secret store, falling back to the plaintext
+// cache if the store is disabled or unavailable. Call `Cache.WriteConfig()`
+// afterward to save the cache.
+func SetSecret(key, value string) {
+	if store := secretStore(); store != nil && value != "" {
+		account := strings.ToLower(key)
+		err := store.Set(account, value)
+		if err == nil {
+			Cache.Set(key, keychainPrefix+account)
+			return
+		}
+		LogWarning("Unable to save to keychain, using the cache file instead (disable with --rsh-no-keychain): %v", err)
+	}
+
+	Cache.Set(key, value)
+}
+
+// DeleteSecret removes a value from the cache and the secret store.
+func DeleteSecret(key string) {
+	value := Cache.GetString(key)
+	if strings.HasPrefix(value, keychainPrefix) {
+		if store := secretStore(); store != nil {
+			if err := store.Delete(strings.TrimPrefix(value, keychainPrefix)); err != nil {
+				LogWarning("Unable to remove %s from keychain: %v", key, err)
+			}
+		}
+	}
+	Cache.Set(key, "")
+}
+
+// migrateSecrets moves secret auth params from the API config and cached
+// tokens from the cache into the secret store, returning how many were moved.
+func migrateSecrets() (int, error) {
+	store := secretStore()
+	if store == nil {
+		return 0, errors.New("keychain is disabled")
+	}
+
+	moved := 0
+	for apiName, config := range configs {
+		changed := false
+		for profileName, profile := range config.Profiles {
+			if profile == nil || profile.Auth == nil {
+				continue
+			}
+
+			for _, param := range secretParams {
+				value := profile.Auth.Params[param]
+				if value == "" || strings.HasPrefix(value, keychainPrefix) {
+					continue
+				}
+
+				account := strings.ToLower(apiName + ":" + profileName + "." + param)
+				if err := store.Set(account, value); err != nil {
+					return moved, err
+				}
+				profile.Auth.Params[param] = keychainPrefix + account
+				changed = true
+				moved++
+			}
+		}
+
+		if changed {
+			if err := config.Save(); err != nil {
+				return moved, err
+			}
+		}
+	}
+
+	// Auth tokens are cached per API & profile like `api:profile.token`.
+	cacheChanged := false
+	for _, key := range Cache.AllKeys() {
+		if !strings.Contains(key, ":") || !(strings.HasSuffix(key, ".token") || strings.HasSuffix(key, ".refresh")) {
+			continue
+		}
+
+		value := Cache.GetString(key)
+		if value == "" || strings.HasPrefix(value, keychainPrefix) {
+			continue
+		}
+
+		if err := store.Set(key, value); err != nil {
+			return moved, err
+		}
+		Cache.Set(key, keychainPrefix+key)
+		cacheChanged = true
+		moved++
+	}
+
+	if cacheChanged {
+		if err := Cache.WriteConfig(); err != nil {
+			return moved, err
+		}
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory secret store used instead of the OS keychain.
+type memoryStore struct {
+	secrets map[string]string
+	err     error
+}
+
+func (s *memoryStore) Get(key string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	if v, ok := s.secrets[key]; ok {
+		return v, nil
+	}
+	return "", errors.New("not found")
+}
+
+func (s *memoryStore) Set(key, value string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.secrets[key] = value
+	return nil
+}
+
+func (s *memoryStore) Delete(key string) error {
+	delete(s.secrets, key)
+	return nil
+}
+
+func useMemoryStore() *memoryStore {
+	store := &memoryStore{secrets: map[string]string{}}
+	Secrets = store
+	viper.Set("rsh-no-keychain", false)
+	return store
+}
+
+func TestSecretStore(t *testing.T) {
+	reset(false)
+	store := useMemoryStore()
+
+	SetSecret("Secrets:default.token", "abc123")
+	assert.Equal(t, "keychain:secrets:default.token", Cache.GetString("Secrets:default.token"))
+	assert.Equal(t, "abc123", store.secrets["secrets:default.token"])
+	assert.Equal(t, "abc123", GetSecret("Secrets:default.token"))
+
+	// Disabling the keychain means stored secrets can't be loaded.
+	viper.Set("rsh-no-keychain", true)
+	assert.Equal(t, "", GetSecret("Secrets:default.token"))
+
+	SetSecret("Secrets:default.token", "plain")
+	assert.Equal(t, "plain", Cache.GetString("Secrets:default.token"))
+
+	// Unavailable stores fall back to the plaintext cache.
+	viper.Set("rsh-no-keychain", false)
+	store.err = errors.New("no keychain")
+	SetSecret("Secrets:default.token", "fallback")
+	assert.Equal(t, "fallback", Cache.GetString("Secrets:default.token"))
+	assert.Equal(t, "fallback", GetSecret("Secrets:default.token"))
+}
+
+func TestSecretClearCache(t *testing.T) {
+	reset(false)
+	store := useMemoryStore()
+
+	configs["secrets-clear"] = &APIConfig{
+		name: "secrets-clear",
+		Base: "https://secrets-clear.example.com",
+	}
+	SetSecret("secrets-clear:default.token", "abc123")
+	require.NotEmpty(t, store.secrets)
+
+	runNoReset("api clear-auth-cache secrets-clear")
+
+	assert.Empty(t, store.secrets)
+	assert.Equal(t, "", GetSecret("secrets-clear:default.token"))
+}
+
+func TestMigrateSecrets(t *testing.T) {
+	reset(false)
+	store := useMemoryStore()
+
+	// Use a separate cache so tokens from other tests aren't migrated.
+	Cache = viper.New()
+	Cache.SetConfigFile(filepath.Join(t.TempDir(), "cache.json"))
+
+	configs = apiConfigs{
+		"secrets-migrate": &APIConfig{
+			name: "secrets-migrate",
+			Base: "https://secrets-migrate.example.com",
+			Profiles: map[string]*APIProfile{
+				"default": {
+					Auth: &APIAuth{
+						Name: "http-basic",
+						Params: map[string]string{
+							"username": "user",
+							"password": "hunter2",
+						},
+					},
+				},
+			},
+		},
+	}
+	Cache.Set("secrets-migrate:default.token", "tok")
+	Cache.Set("secrets-migrate:default.expires", "2100-01-01T00:00:00Z")
+
+	moved, err := migrateSecrets()
+	require.NoError(t, err)
+	assert.Equal(t, 2, moved)
+
+	params := configs["secrets-migrate"].Profiles["default"].Auth.Params
+	assert.Equal(t, "user", params["username"])
+	assert.Equal(t, "keychain:secrets-migrate:default.password", params["password"])
+	assert.Equal(t, "hunter2", store.secrets["secrets-migrate:default.password"])
+	assert.Equal(t, "tok", GetSecret("secrets-migrate:default.token"))
+	assert.Equal(t, "tok", store.secrets["secrets-migrate:default.token"])
+
+	// Secrets are loaded when making requests.
+	resolved, err := resolveAuthParams(params)
+	require.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodGet, "https://secrets-migrate.example.com/", nil)
+	require.NoError(t, authHandlers["http-basic"].OnRequest(req, "secrets-migrate:default", resolved))
+	username, password, _ := req.BasicAuth()
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "hunter2", password)
+
+	// Running again has nothing left to move.
+	moved, err = migrateSecrets()
+	require.NoError(t, err)
+	assert.Equal(t, 0, moved)
+
+	// Migrating requires the keychain.
+	viper.Set("rsh-no-keychain", true)
+	_, err = migrateSecrets()
+	assert.EqualError(t, err, "keychain is disabled")
+}
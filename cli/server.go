@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ServerVariable is a variable in the URL template of a server, like the
+// region in `https://{region}.api.example.com`.
+type ServerVariable struct {
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Default     string   `json:"default" yaml:"default"`
+	Enum        []string `json:"enum,omitempty" yaml:"enum,omitempty"`
+}
+
+// Server is one of the servers an API is available on, e.g. for production
+// and staging environments. It can be selected by name via `--rsh-server` or
+// the `server` of a profile.
+type Server struct {
+	Name        string                    `json:"name" yaml:"name"`
+	URL         string                    `json:"url" yaml:"url"`
+	Description string                    `json:"description,omitempty" yaml:"description,omitempty"`
+	Variables   map[string]ServerVariable `json:"variables,omitempty" yaml:"variables,omitempty"`
+}
+
+// nonSlug matches runs of characters which aren't allowed in server names.
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ServerName returns a name to select a server by from its description, e.g.
+// `staging-eu` for `Staging (EU)`, or the fallback if there is none.
+func ServerName(description, fallback string) string {
+	if name := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(description), "-"), "-"); name != "" {
+		return name
+	}
+	return fallback
+}
+
+// variableNames returns the sorted names of the server's variables.
+func (s Server) variableNames() []string {
+	names := make([]string, 0, len(s.Variables))
+	for name := range s.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expand returns the URL of the server with its variables replaced by the
+// given values or their defaults. Values must be one of the variable's enum
+// if it has one.
+func (s Server) expand(values map[string]string) (string, error) {
+	for name := range values {
+		if _, ok := s.Variables[name]; !ok {
+			return "", fmt.Errorf("unknown variable %s for server %s, expected one of [%s]", name, s.Name, strings.Join(s.variableNames(), ", "))
+		}
+	}
+
+	u := s.URL
+	for _, name := range s.variableNames() {
+		v := s.Variables[name]
+		value, ok := values[name]
+		if !ok {
+			value = v.Default
+		}
+		if len(v.Enum) > 0 {
+			found := false
+			for _, allowed := range v.Enum {
+				if value == allowed {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return "", fmt.Errorf("invalid value %s for server variable %s, expected one of [%s]", value, name, strings.Join(v.Enum, ", "))
+			}
+		}
+		u = strings.ReplaceAll(u, "{"+name+"}", value)
+	}
+
+	return strings.TrimSuffix(u, "/"), nil
+}
+
+// serverSelection returns the server name or URL and variables selected via
+// the commandline, falling back to the given profile.
+func serverSelection(profile *APIProfile) (string, map[string]string, error) {
+	server := viper.GetString("rsh-server")
+	values := map[string]string{}
+	if profile != nil {
+		if server == "" {
+			server = profile.Server
+		}
+		for k, v := range profile.ServerVariables {
+			values[k] = v
+		}
+	}
+
+	for _, pair := range viper.GetStringSlice("rsh-server-var") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return "", nil, fmt.Errorf("invalid server variable %s, expected name=value", pair)
+		}
+		values[k] = v
+	}
+
+	return server, values, nil
+}
+
+// replaceOrigin swaps the scheme and host of a URL for the server's. A path
+// on the server is prepended to the URL's path.
+func replaceOrigin(uri, server string) string {
+	orig, _ := url.Parse(uri)
+	custom, _ := url.Parse(server)
+
+	orig.Scheme = custom.Scheme
+	orig.Host = custom.Host
+
+	if custom.Path != "" && custom.Path != "/" {
+		orig.Path = strings.TrimSuffix(custom.Path, "/") + orig.Path
+	}
+
+	return orig.String()
+}
+
+// applyServer rewrites a URL of the API to use the server selected via
+// `--rsh-server` and `--rsh-server-var` or the profile. A server URL replaces
+// the scheme and host. A server name is looked up in the API's servers and
+// its expanded URL replaces the base that operations were loaded from, or
+// just the scheme and host for URLs outside of it.
+func applyServer(uri string, api *API, profile *APIProfile) (string, error) {
+	selected, values, err := serverSelection(profile)
+	if err != nil {
+		return "", err
+	}
+	if selected == "" && len(values) == 0 {
+		return uri, nil
+	}
+
+	if strings.Contains(selected, "://") {
+		return replaceOrigin(uri, selected), nil
+	}
+
+	if api == nil || len(api.Servers) == 0 {
+		if selected == "" {
+			return uri, nil
+		}
+		return "", fmt.Errorf("unknown server %s, the API doesn't describe any servers so a URL is required", selected)
+	}
+
+	var server *Server
+	if selected == "" {
+		// Variables alone apply to the first, i.e. default, server.
+		server = &api.Servers[0]
+	}
+	names := []string{}
+	for i := range api.Servers {
+		names = append(names, api.Servers[i].Name)
+		if strings.EqualFold(api.Servers[i].Name, selected) {
+			server = &api.Servers[i]
+		}
+	}
+	if server == nil {
+		return "", fmt.Errorf("unknown server %s, expected a URL or one of [%s]", selected, strings.Join(names, ", "))
+	}
+
+	to, err := server.expand(values)
+	if err != nil {
+		return "", err
+	}
+
+	base, _ := url.Parse(api.Base)
+	if strings.HasPrefix(to, "/") && base != nil {
+		// Relative servers are on the same host as the API.
+		to = base.Scheme + "://" + base.Host + to
+	}
+
+	if api.Base != "" && strings.HasPrefix(uri, api.Base) {
+		return to + uri[len(api.Base):], nil
+	}
+
+	parsed, err := url.Parse(to)
+	if err != nil {
+		return "", err
+	}
+	return replaceOrigin(uri, parsed.Scheme+"://"+parsed.Host), nil
+}
+
+// serverAddress applies a server selected by name to the URL of an address
+// like `api-name/items` using the API's cached description, e.g. for bulk
+// checkouts. Server URLs only apply to operations.
+func serverAddress(name, uri string, profile *APIProfile) string {
+	selected, values, err := serverSelection(profile)
+	if err != nil {
+		panic(err)
+	}
+	if (selected == "" && len(values) == 0) || strings.Contains(selected, "://") {
+		return uri
+	}
+
+	api := currentAPI
+	if api == nil || currentConfig == nil || currentConfig.name != name {
+		for _, cmd := range Root.Commands() {
+			if cmd.Use == name {
+				cached, _ := readCachedAPI(name, cmd.Version)
+				api = &cached
+				break
+			}
+		}
+	}
+
+	uri, err = applyServer(uri, api, profile)
+	if err != nil {
+		panic(err)
+	}
+	return uri
+}
+
+// serversHelp lists the servers of an API and their variables for the help
+// output of its command.
+func serversHelp(servers []Server) string {
+	if len(servers) == 0 {
+		return ""
+	}
+
+	width := 0
+	for _, s := range servers {
+		if len(s.Name) > width {
+			width = len(s.Name)
+		}
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("\n\n## Servers\n\nSelect a server with `--rsh-server name` and set its variables with `--rsh-server-var name=value`.\n\n")
+	for _, s := range servers {
+		sb.WriteString(fmt.Sprintf("    %-*s  %s", width, s.Name, s.URL))
+		if s.Description != "" {
+			sb.WriteString("  " + s.Description)
+		}
+		sb.WriteString("\n")
+		for _, name := range s.variableNames() {
+			v := s.Variables[name]
+			sb.WriteString(fmt.Sprintf("    %-*s    {%s} default %s", width, "", name, v.Default))
+			if len(v.Enum) > 0 {
+				sb.WriteString(", one of " + strings.Join(v.Enum, ", "))
+			}
+			if v.Description != "" {
+				sb.WriteString(": " + v.Description)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
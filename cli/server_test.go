@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var serversAPI = &API{
+	Base: "https://api.example.com/v1",
+	Servers: []Server{
+		{Name: "production", URL: "https://api.example.com/v1", Description: "Production"},
+		{
+			Name: "staging",
+			URL:  "https://{region}.staging.example.com/{version}",
+			Variables: map[string]ServerVariable{
+				"region":  {Default: "us", Enum: []string{"us", "eu"}},
+				"version": {Default: "v1"},
+			},
+		},
+		{Name: "next", URL: "/v2"},
+	},
+}
+
+func TestApplyServer(t *testing.T) {
+	defer viper.Set("rsh-server", "")
+	defer viper.Set("rsh-server-var", []string{})
+
+	for _, tc := range []struct {
+		server  string
+		vars    []string
+		profile *APIProfile
+		uri     string
+		result  string
+		err     string
+	}{
+		{uri: "https://api.example.com/v1/items", result: "https://api.example.com/v1/items"},
+		{server: "http://localhost:8000", uri: "https://api.example.com/v1/items", result: "http://localhost:8000/v1/items"},
+		{server: "staging", uri: "https://api.example.com/v1/items?q=1", result: "https://us.staging.example.com/v1/items?q=1"},
+		{server: "Staging", vars: []string{"region=eu", "version=beta"}, uri: "https://api.example.com/v1/items", result: "https://eu.staging.example.com/beta/items"},
+		{server: "next", uri: "https://api.example.com/v1/items", result: "https://api.example.com/v2/items"},
+		{server: "staging", uri: "https://api.example.com/other", result: "https://us.staging.example.com/other"},
+		{profile: &APIProfile{Server: "staging", ServerVariables: map[string]string{"region": "eu"}}, uri: "https://api.example.com/v1/items", result: "https://eu.staging.example.com/v1/items"},
+		{server: "production", profile: &APIProfile{Server: "staging"}, uri: "https://api.example.com/v1/items", result: "https://api.example.com/v1/items"},
+		{server: "staging", vars: []string{"region=ap"}, uri: "https://api.example.com/v1/items", err: "invalid value ap for server variable region, expected one of [us, eu]"},
+		{server: "staging", vars: []string{"zone=a"}, uri: "https://api.example.com/v1/items", err: "unknown variable zone for server staging, expected one of [region, version]"},
+		{server: "staging", vars: []string{"region"}, uri: "https://api.example.com/v1/items", err: "invalid server variable region, expected name=value"},
+		{server: "dev", uri: "https://api.example.com/v1/items", err: "unknown server dev, expected a URL or one of [production, staging, next]"},
+	} {
+		viper.Set("rsh-server", tc.server)
+		viper.Set("rsh-server-var", tc.vars)
+
+		result, err := applyServer(tc.uri, serversAPI, tc.profile)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.server)
+			continue
+		}
+		require.NoError(t, err, tc.server)
+		assert.Equal(t, tc.result, result, tc.server)
+	}
+}
+
+func TestServerName(t *testing.T) {
+	assert.Equal(t, "staging-eu", ServerName("Staging (EU)", "server1"))
+	assert.Equal(t, "server1", ServerName("", "server1"))
+}
+
+func TestServersHelp(t *testing.T) {
+	help := serversHelp(serversAPI.Servers)
+	assert.Contains(t, help, "## Servers")
+	assert.Contains(t, help, "production  https://api.example.com/v1  Production\n")
+	assert.Contains(t, help, "{region} default us, one of us, eu\n")
+	assert.Empty(t, serversHelp(nil))
+}
+
+func TestServerAddress(t *testing.T) {
+	reset(false)
+	defer viper.Set("rsh-server", "")
+	defer func() { currentConfig, currentAPI = nil, nil }()
+
+	config := &APIConfig{name: "servers-test", Base: "https://api.example.com"}
+	configs["servers-test"] = config
+	defer delete(configs, "servers-test")
+	currentConfig, currentAPI = config, serversAPI
+
+	// Addresses of the API follow the selected server, e.g. for bulk checkouts.
+	viper.Set("rsh-server", "staging")
+	assert.Equal(t, "https://us.staging.example.com/v1/items", fixAddress("servers-test/v1/items"))
+
+	// Server URLs only apply to operations.
+	viper.Set("rsh-server", "http://localhost:8000")
+	assert.Equal(t, "https://api.example.com/v1/items", fixAddress("servers-test/v1/items"))
+}
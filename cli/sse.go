@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// sseDefaultRetry is how long to wait before reconnecting to an event stream
+// if the server has not sent a `retry` field.
+var sseDefaultRetry = 3 * time.Second
+
+// sseEvent is a single dispatched server-sent event.
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// isEventStream returns true if the response is a server-sent event stream.
+func isEventStream(resp *http.Response) bool {
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("content-type"))
+	return mt == "text/event-stream"
+}
+
+// sseReader parses the `text/event-stream` format as described in
+// https://html.spec.whatwg.org/multipage/server-sent-events.html.
+type sseReader struct {
+	r *bufio.Reader
+
+	// lastID and retry persist across events and are used when reconnecting.
+	lastID string
+	retry  time.Duration
+}
+
+// Next reads and returns the next event. It returns `io.EOF` when the stream
+// ends cleanly between events.
+func (s *sseReader) Next() (*sseEvent, error) {
+	ev := &sseEvent{}
+	data := []string{}
+	seenData := false
+
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line != "" {
+				// The stream ended part way through an event, which must be
+				// discarded as incomplete.
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if !seenData {
+				// Nothing to dispatch, reset the event type and keep reading.
+				ev.Event = ""
+				continue
+			}
+			ev.ID = s.lastID
+			ev.Data = strings.Join(data, "\n")
+			return ev, nil
+		}
+
+		if strings.HasPrefix(line, ":") {
+			// Comment, commonly used as a keep-alive.
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			ev.Event = value
+		case "data":
+			data = append(data, value)
+			seenData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				s.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil && ms >= 0 {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// formatEvent prints a single event's data, pretty-printed if it is JSON.
+// The `rsh-filter` option is applied to each event, where the data is
+// available as `body` along with the event's `id` and `event` type.
+func formatEvent(ev *sseEvent) error {
+	var body any = ev.Data
+	var parsed any
+	if err := json.Unmarshal([]byte(ev.Data), &parsed); err == nil {
+		body = parsed
+	}
+
+	data := body
+	if filter := viper.GetString("rsh-filter"); filter != "" && filter != "body" {
+		var err error
+		data, err = (&DefaultFormatter{}).filterData(filter, map[string]any{
+			"id":    ev.ID,
+			"event": ev.Event,
+			"body":  body,
+		})
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return nil
+		}
+	}
+
+	return formatStreamValue(data)
+}
+
+// formatStreamValue prints a single value from a stream of events or
+// messages. Strings are written as-is while structured data is pretty-printed
+// using the current output format.
+func formatStreamValue(data any) error {
+	if s, ok := data.(string); ok {
+		// Plain text events are written as-is.
+		if !strings.HasSuffix(s, "\n") {
+			s += "\n"
+		}
+		_, err := io.WriteString(Stdout, s)
+		return err
+	}
+
+	format := viper.GetString("rsh-output-format")
+	if format == "auto" {
+		format = "json"
+		if viper.GetBool("tty") {
+			format = "readable"
+		}
+	}
+
+	encoded, err := MarshalShort(format, true, data)
+	if err != nil {
+		return err
+	}
+
+	if useColor {
+		if encoded, err = Highlight(format, encoded); err != nil {
+			return err
+		}
+	}
+
+	_, err = Stdout.Write(encoded)
+	return err
+}
+
+// StreamEvents prints each server-sent event from the response as it arrives
+// until the stream ends or the user interrupts it. If reconnection is
+// enabled via `rsh-sse-retry`, dropped connections are resumed by sending the
+// request again with the `Last-Event-ID` header.
+func StreamEvents(req *http.Request, resp *http.Response) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s := &sseReader{retry: sseDefaultRetry}
+
+	for {
+		if err := streamEvents(ctx, s, resp); err == nil || ctx.Err() != nil {
+			return nil
+		} else if !viper.GetBool("rsh-sse-retry") {
+			return err
+		} else {
+			LogWarning("Event stream interrupted: %v, reconnecting in %s", err, s.retry)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.retry):
+		}
+
+		next, err := reconnectRequest(req, s.lastID)
+		if err != nil {
+			return err
+		}
+
+		// The original request already has the commandline headers and query
+		// params applied, so don't add them a second time.
+		resp, err = MakeRequest(next, IgnoreCLIParams())
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusNoContent || !isEventStream(resp) {
+			// The server is telling us to stop reconnecting.
+			resp.Body.Close()
+			LogInfo("Event stream closed by server with %s", resp.Status)
+			return nil
+		}
+	}
+}
+
+// streamEvents prints events from a single response. A nil error means the
+// stream ended cleanly.
+func streamEvents(ctx context.Context, s *sseReader, resp *http.Response) error {
+	raw := resp.Body
+	defer raw.Close()
+
+	// Closing the body unblocks any pending read when interrupted.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			raw.Close()
+		case <-done:
+		}
+	}()
+
+	if err := DecodeResponse(resp); err != nil {
+		return err
+	}
+
+	s.r = bufio.NewReader(resp.Body)
+	for {
+		ev, err := s.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if err := formatEvent(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// reconnectRequest creates a copy of the request to resume an event stream
+// after the given event ID.
+func reconnectRequest(req *http.Request, lastID string) (*http.Request, error) {
+	next := req.Clone(context.Background())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, errors.New("unable to reconnect event stream: request body cannot be re-sent")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+
+	if lastID != "" {
+		next.Header.Set("Last-Event-ID", lastID)
+	}
+
+	return next, nil
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSEReader(t *testing.T) {
+	s := &sseReader{r: bufio.NewReader(strings.NewReader(": keep-alive\n\nevent: update\nid: 1\ndata: {\"a\": 1}\n\ndata: line1\r\ndata:line2\nretry: 500\n\ndata: partial"))}
+
+	ev, err := s.Next()
+	require.NoError(t, err)
+	assert.Equal(t, &sseEvent{ID: "1", Event: "update", Data: `{"a": 1}`}, ev)
+
+	ev, err = s.Next()
+	require.NoError(t, err)
+	assert.Equal(t, &sseEvent{ID: "1", Data: "line1\nline2"}, ev)
+	assert.Equal(t, 500*time.Millisecond, s.retry)
+
+	_, err = s.Next()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestSSEStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"id\": 1, \"name\": \"one\"}\n\n"))
+		w.Write([]byte("event: log\ndata: plain text\n\n"))
+	}))
+	defer ts.Close()
+
+	out := run("-o json " + ts.URL)
+	assert.Equal(t, "{\n  \"id\": 1,\n  \"name\": \"one\"\n}\nplain text\n", out)
+
+	defer viper.Set("rsh-filter", "")
+	out = run("-f body.name " + ts.URL)
+	assert.Equal(t, "one\n", out)
+}
+
+func TestSSEReconnect(t *testing.T) {
+	lastIDs := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		if len(lastIDs) > 2 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "retry: 1\nid: %d\ndata: event %d\n\n", len(lastIDs), len(lastIDs))
+		w.(http.Flusher).Flush()
+
+		// Simulate a dropped connection part way through the next event.
+		w.Write([]byte("data: incomplete"))
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer ts.Close()
+
+	defer viper.Set("rsh-sse-retry", false)
+	out := run("--rsh-sse-retry " + ts.URL)
+	assert.Contains(t, out, "event 1\n")
+	assert.Contains(t, out, "event 2\n")
+	assert.NotContains(t, out, "incomplete")
+	assert.Equal(t, []string{"", "1", "2"}, lastIDs)
+}
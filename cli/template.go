@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/viper"
+)
+
+// reTemplateError matches the location in template parse & execution errors,
+// e.g. `template: name:2:14: executing ...` or `template: name:3: ...`.
+var reTemplateError = regexp.MustCompile(`^template: [^:]*:(\d+)(?::(\d+))?: (?:executing "[^"]*" at (<[^>]*>): )?(.*)$`)
+
+// templateEscapes turns escape sequences typed on the commandline into the
+// characters they represent, since shells don't do this in quoted strings.
+var templateEscapes = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\\`, `\`)
+
+// templateFuncs are helpers available in output templates, named like their
+// equivalents in the Sprig library used by many other Go tools.
+var templateFuncs = template.FuncMap{
+	"default": func(def, value any) any {
+		if value == nil {
+			return def
+		}
+		v := reflect.ValueOf(value)
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map:
+			if v.Len() == 0 {
+				return def
+			}
+		case reflect.Bool:
+			if !v.Bool() {
+				return def
+			}
+		}
+		return value
+	},
+	"join": func(sep string, items any) string {
+		v := reflect.ValueOf(items)
+		if v.Kind() != reflect.Slice {
+			return fmt.Sprintf("%v", items)
+		}
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = fmt.Sprintf("%v", v.Index(i).Interface())
+		}
+		return strings.Join(parts, sep)
+	},
+	"toJson": func(value any) (string, error) {
+		b, err := json.Marshal(makeJSONSafe(value))
+		return string(b), err
+	},
+	"toPrettyJson": func(value any) (string, error) {
+		b, err := json.MarshalIndent(makeJSONSafe(value), "", "  ")
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"quote": func(value any) string {
+		return fmt.Sprintf("%q", fmt.Sprintf("%v", value))
+	},
+}
+
+// templateError rewrites template errors to show where in the template the
+// problem is.
+func templateError(err error) error {
+	m := reTemplateError.FindStringSubmatch(err.Error())
+	if m == nil {
+		return fmt.Errorf("template error: %w", err)
+	}
+
+	msg := m[4]
+	if m[3] != "" {
+		msg = m[3] + ": " + msg
+	}
+
+	if m[2] != "" {
+		return fmt.Errorf("template error at line %s, column %s: %s", m[1], m[2], msg)
+	}
+	return fmt.Errorf("template error at line %s: %s", m[1], msg)
+}
+
+// outputTemplate returns the template given via `--rsh-template` or
+// `--rsh-template-file`, or nil if neither is set.
+func outputTemplate() (*template.Template, error) {
+	text := viper.GetString("rsh-template")
+	name := "template"
+	if filename := viper.GetString("rsh-template-file"); filename != "" {
+		if text != "" {
+			return nil, fmt.Errorf("only one of --rsh-template and --rsh-template-file can be used")
+		}
+
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+		name = filepath.Base(filename)
+	} else {
+		text = templateEscapes.Replace(text)
+	}
+
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, templateError(err)
+	}
+	return tmpl, nil
+}
+
+// renderTemplate writes the data rendered through the template. Nothing is
+// written if rendering fails part way through.
+func renderTemplate(w io.Writer, tmpl *template.Template, data any) error {
+	if b, ok := data.([]byte); ok {
+		// Unparsed bodies can still be used as text.
+		data = string(b)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, makeJSONSafe(data)); err != nil {
+		return templateError(err)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
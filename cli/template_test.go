@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renderTestTemplate(t *testing.T, text string, data any) (string, error) {
+	viper.Set("rsh-template", text)
+	defer viper.Set("rsh-template", "")
+
+	tmpl, err := outputTemplate()
+	if err != nil {
+		return "", err
+	}
+	require.NotNil(t, tmpl)
+
+	buf := &bytes.Buffer{}
+	err = renderTemplate(buf, tmpl, data)
+	return buf.String(), err
+}
+
+func TestTemplate(t *testing.T) {
+	out, err := renderTestTemplate(t, `{{range .items}}{{.id}} {{.name}}\n{{end}}`, map[string]any{
+		"items": []any{
+			map[string]any{"id": 1, "name": "one"},
+			map[string]any{"id": 2, "name": "two"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1 one\n2 two\n", out)
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	out, err := renderTestTemplate(t, `{{.missing | default "none"}} {{join "," .tags}} {{toJson .owner}} {{upper .name}} {{quote .name}}`, map[string]any{
+		"name":  "a",
+		"tags":  []any{"x", "y"},
+		"owner": map[string]any{"id": 1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `none x,y {"id":1} A "a"`, out)
+}
+
+func TestTemplateErrors(t *testing.T) {
+	_, err := renderTestTemplate(t, "ok\n{{.foo", nil)
+	assert.ErrorContains(t, err, "template error at line 2:")
+
+	out, err := renderTestTemplate(t, `before {{index .items 5}}`, map[string]any{"items": []any{}})
+	assert.ErrorContains(t, err, "template error at line 1, column 9: <index .items 5>:")
+	assert.Empty(t, out)
+}
+
+func TestTemplateFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "list.tmpl")
+	require.NoError(t, os.WriteFile(filename, []byte("{{.name}}\n"), 0o600))
+
+	viper.Set("rsh-template-file", filename)
+	defer viper.Set("rsh-template-file", "")
+
+	tmpl, err := outputTemplate()
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, renderTemplate(buf, tmpl, map[string]any{"name": "file"}))
+	assert.Equal(t, "file\n", buf.String())
+
+	viper.Set("rsh-template", "{{.}}")
+	defer viper.Set("rsh-template", "")
+	_, err = outputTemplate()
+	assert.Error(t, err)
+}
+
+func TestTemplateFormatter(t *testing.T) {
+	reset(false)
+	viper.Set("rsh-template", `{{range .}}{{.}}\n{{end}}`)
+	defer viper.Set("rsh-template", "")
+	viper.Set("rsh-filter", "body.tags")
+	defer viper.Set("rsh-filter", "")
+
+	buf := &bytes.Buffer{}
+	Stdout = buf
+	err := NewDefaultFormatter(false, false).Format(Response{
+		Body: map[string]any{"tags": []any{"a", "b"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\n", buf.String())
+}
@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/spf13/viper"
+)
+
+// themeStyle is the name of the registered chroma style used for all
+// highlighted output, built from the selected theme.
+const themeStyle = "cli-theme"
+
+// Token types for theme elements which aren't part of a lexer, like the log
+// labels. They are outside of the indent level category so they don't
+// inherit its styles.
+const (
+	themeChanged chroma.TokenType = 10000 + iota
+	themeDebug
+	themeInfo
+	themeWarn
+	themeError
+)
+
+// themes are the built-in color themes selectable via `--rsh-theme`.
+var themes = map[string]chroma.StyleEntries{
+	// Simple 256-color theme for dark terminal backgrounds.
+	"dark": {
+		// Used for JSON/YAML/Readable
+		chroma.Comment:      "#9e9e9e",
+		chroma.Keyword:      "#ff5f87",
+		chroma.Punctuation:  "#9e9e9e",
+		chroma.NameTag:      "#5fafd7",
+		chroma.Number:       "#d78700",
+		chroma.String:       "#afd787",
+		chroma.StringSymbol: "italic #D6FFB7",
+		chroma.Date:         "#af87af",
+		chroma.NumberHex:    "#ffd7d7",
+
+		// Used for HTTP
+		chroma.Name:          "#5fafd7",
+		chroma.NameFunction:  "#ff5f87",
+		chroma.NameNamespace: "#b2b2b2",
+
+		// Used for Markdown & diffs
+		chroma.GenericHeading:    "#5fafd7",
+		chroma.GenericSubheading: "#5fafd7",
+		chroma.GenericEmph:       "italic #ffd7d7",
+		chroma.GenericStrong:     "bold #af87af",
+		chroma.GenericDeleted:    "#ff5f87",
+		chroma.GenericInserted:   "#afd787",
+		chroma.NameAttribute:     "underline",
+
+		// Used for matching `{`, `}, `[`, and `]` characters.
+		IndentLevel1: "#d78700",
+		IndentLevel2: "#af87af",
+		IndentLevel3: "#5fafd7",
+
+		// Used for bulk status & log labels.
+		themeChanged: "#d78700",
+		themeDebug:   "#767676",
+		themeInfo:    "#5fafd7",
+		themeWarn:    "#ffd787",
+		themeError:   "bold #ffffff bg:#ff5f87",
+	},
+
+	// Darker colors which stay readable on light terminal backgrounds.
+	"light": {
+		chroma.Comment:      "#6c6c6c",
+		chroma.Keyword:      "#af005f",
+		chroma.Punctuation:  "#6c6c6c",
+		chroma.NameTag:      "#005f87",
+		chroma.Number:       "#af5f00",
+		chroma.String:       "#5f8700",
+		chroma.StringSymbol: "italic #5f8700",
+		chroma.Date:         "#5f5faf",
+		chroma.NumberHex:    "#870000",
+
+		chroma.Name:          "#005f87",
+		chroma.NameFunction:  "#af005f",
+		chroma.NameNamespace: "#585858",
+
+		chroma.GenericHeading:    "#005f87",
+		chroma.GenericSubheading: "#005f87",
+		chroma.GenericEmph:       "italic #870000",
+		chroma.GenericStrong:     "bold #5f5faf",
+		chroma.GenericDeleted:    "#af0000",
+		chroma.GenericInserted:   "#008700",
+		chroma.NameAttribute:     "underline",
+
+		IndentLevel1: "#af5f00",
+		IndentLevel2: "#5f5faf",
+		IndentLevel3: "#005f87",
+
+		themeChanged: "#af5f00",
+		themeDebug:   "#6c6c6c",
+		themeInfo:    "#005f87",
+		themeWarn:    "#af5f00",
+		themeError:   "bold #ffffff bg:#af0000",
+	},
+
+	// No colors, only text attributes like bold & underline.
+	"mono": {
+		chroma.Keyword:      "bold",
+		chroma.NameTag:      "bold",
+		chroma.StringSymbol: "italic",
+
+		chroma.Name:         "bold",
+		chroma.NameFunction: "bold",
+
+		chroma.GenericHeading:    "bold",
+		chroma.GenericSubheading: "bold",
+		chroma.GenericEmph:       "italic",
+		chroma.GenericStrong:     "bold",
+		chroma.GenericDeleted:    "underline",
+		chroma.GenericInserted:   "bold",
+		chroma.NameAttribute:     "underline",
+
+		themeChanged: "italic",
+		themeWarn:    "bold",
+		themeError:   "bold underline",
+	},
+}
+
+// themeElements maps the names of elements which can have their colors
+// overridden via the `rsh-theme-colors` config to their token types.
+var themeElements = map[string][]chroma.TokenType{
+	"key":         {chroma.NameTag, chroma.Name},
+	"string":      {chroma.String, chroma.StringSymbol},
+	"number":      {chroma.Number, chroma.NumberHex},
+	"keyword":     {chroma.Keyword},
+	"diff-add":    {chroma.GenericInserted},
+	"diff-remove": {chroma.GenericDeleted},
+	"diff-change": {themeChanged},
+}
+
+func init() {
+	styles.Register(chroma.MustNewStyle(themeStyle, themes["dark"]))
+}
+
+// themeNames returns the sorted names of the built-in themes.
+func themeNames() []string {
+	names := []string{}
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// initTheme resolves the theme selected via `rsh-theme` along with any
+// per-element overrides from `rsh-theme-colors` and registers it as the style
+// used by all highlighted output.
+func initTheme() {
+	name := viper.GetString("rsh-theme")
+	base, ok := themes[name]
+	if !ok {
+		LogWarning("Unknown theme %s, expected one of %s", name, strings.Join(themeNames(), ", "))
+		base = themes["dark"]
+	}
+
+	entries := chroma.StyleEntries{}
+	for token, value := range base {
+		entries[token] = value
+	}
+
+	for element, value := range viper.GetStringMapString("rsh-theme-colors") {
+		tokens, ok := themeElements[element]
+		if !ok {
+			LogWarning("Unknown theme color element %s", element)
+			continue
+		}
+		for _, token := range tokens {
+			entries[token] = value
+		}
+	}
+
+	style, err := chroma.NewStyle(themeStyle, entries)
+	if err != nil {
+		LogWarning("Invalid theme colors: %v", err)
+		style = chroma.MustNewStyle(themeStyle, base)
+	}
+	styles.Register(style)
+}
+
+// colorize returns the text styled like the given token type in the current
+// theme, or unchanged if colored output is disabled.
+func colorize(token chroma.TokenType, text string) string {
+	if !useColor {
+		return text
+	}
+
+	sb := &strings.Builder{}
+	if err := formatters.TTY256.Format(sb, styles.Get(themeStyle), chroma.Literator(chroma.Token{Type: token, Value: text})); err != nil {
+		return text
+	}
+	return sb.String()
+}
+
+// Colorize returns the text styled like a theme element, e.g. `diff-add`, or
+// unchanged if colored output is disabled.
+func Colorize(element, text string) string {
+	tokens := themeElements[element]
+	if len(tokens) == 0 {
+		return text
+	}
+	return colorize(tokens[0], text)
+}
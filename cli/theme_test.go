@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestThemeLight(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/foo").Reply(200).JSON(map[string]interface{}{
+		"hello": "world",
+	})
+
+	captured := run("http://example.com/foo --rsh-theme light", true)
+	assert.Contains(t, captured, "\x1b[38;5;24mhello\x1b[0m")
+	assert.Contains(t, captured, "\x1b[38;5;64m\"world\"\x1b[0m")
+}
+
+func TestThemeMono(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/foo").Reply(200).JSON(map[string]interface{}{
+		"hello": "world",
+	})
+
+	captured := run("http://example.com/foo --rsh-theme mono", true)
+	assert.Contains(t, captured, "\x1b[1mhello\x1b[0m")
+	assert.NotContains(t, captured, "38;5;")
+}
+
+func TestThemeColors(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/foo").Reply(200).JSON(map[string]interface{}{
+		"hello": "world",
+	})
+
+	reset(true)
+	viper.Set("rsh-theme-colors", map[string]string{
+		"key":    "#ff0000",
+		"string": "bold",
+	})
+	captured := runNoReset("http://example.com/foo")
+	assert.Contains(t, captured, "\x1b[38;5;196mhello\x1b[0m")
+	assert.Contains(t, captured, "\x1b[1m\"world\"\x1b[0m")
+
+	assert.Equal(t, "\x1b[38;5;196mfoo\x1b[0m", Colorize("key", "foo"))
+	assert.Equal(t, "foo", Colorize("unknown", "foo"))
+}
+
+func TestNoColorEnv(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	viper.Reset()
+	viper.Set("tty", true)
+	Init("test", "1.0.0")
+	Defaults()
+	assert.False(t, useColor)
+	assert.Equal(t, "added", Colorize("diff-add", "added"))
+
+	// Explicitly forcing color still wins.
+	viper.Reset()
+	viper.Set("color", true)
+	Init("test", "1.0.0")
+	Defaults()
+	assert.True(t, useColor)
+
+	reset(false)
+}
@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// timingsEnabled returns whether a timing breakdown is logged for each
+// request, via `-v` or `--rsh-timings`.
+func timingsEnabled() bool {
+	return HTTPLog.Enabled(LevelRequest) || viper.GetBool("rsh-timings")
+}
+
+// RequestMetric describes a single attempt of a request which went out on the
+// wire, using the same timings as `--rsh-timings`.
+type RequestMetric struct {
+	Method string
+	URL    string
+	// Status is zero if the request failed without a response.
+	Status int
+	// Retry is zero for the first attempt.
+	Retry int
+	// Bytes is the size of the response body which was read.
+	Bytes int64
+	// Duration is the total time until the response body was read or closed.
+	Duration time.Duration
+	// Header holds the response headers, or nil without a response.
+	Header http.Header
+	Error  error
+}
+
+// OnRequestDone is called, if set, once each request attempt has completed,
+// e.g. to collect metrics for bulk operations.
+var OnRequestDone func(RequestMetric)
+
+// durationMS returns the duration in fractional milliseconds for JSON output.
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// requestTimings records when each phase of a request happened using an HTTP
+// client trace.
+type requestTimings struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	done         time.Time
+	reused       bool
+	bytes        int64
+}
+
+// withTrace returns a shallow copy of the request which records its timings.
+func (t *requestTimings) withTrace(req *http.Request) *http.Request {
+	set := func(field *time.Time, keepFirst bool) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if keepFirst && !field.IsZero() {
+			return
+		}
+		*field = time.Now()
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { set(&t.dnsStart, true) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { set(&t.dnsDone, false) },
+		// Several addresses may be tried, so keep the first start & last done.
+		ConnectStart:      func(string, string) { set(&t.connectStart, true) },
+		ConnectDone:       func(string, string, error) { set(&t.connectDone, false) },
+		TLSHandshakeStart: func() { set(&t.tlsStart, true) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { set(&t.tlsDone, false) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+		GotFirstResponseByte: func() { set(&t.firstByte, true) },
+	}
+
+	t.start = time.Now()
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// between returns the time between two events, or zero if either did not
+// happen.
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// timingsEvent is the timing breakdown in JSON logs.
+type timingsEvent struct {
+	DNSMS      float64 `json:"dns_ms,omitempty"`
+	ConnectMS  float64 `json:"connect_ms,omitempty"`
+	TLSMS      float64 `json:"tls_ms,omitempty"`
+	TTFBMS     float64 `json:"ttfb_ms,omitempty"`
+	TransferMS float64 `json:"transfer_ms,omitempty"`
+	TotalMS    float64 `json:"total_ms"`
+	Reused     bool    `json:"reused"`
+}
+
+// event returns the timing breakdown for JSON logs.
+func (t *requestTimings) event() *timingsEvent {
+	return &timingsEvent{
+		DNSMS:      durationMS(between(t.dnsStart, t.dnsDone)),
+		ConnectMS:  durationMS(between(t.connectStart, t.connectDone)),
+		TLSMS:      durationMS(between(t.tlsStart, t.tlsDone)),
+		TTFBMS:     durationMS(between(t.start, t.firstByte)),
+		TransferMS: durationMS(between(t.firstByte, t.done)),
+		TotalMS:    durationMS(between(t.start, t.done)),
+		Reused:     t.reused,
+	}
+}
+
+// String returns a human-readable timing breakdown. Phases which did not
+// happen, e.g. TLS for plain HTTP or DNS for a reused connection, are left
+// out.
+func (t *requestTimings) String() string {
+	sb := &strings.Builder{}
+	phase := func(name string, start, end time.Time) {
+		if d := between(start, end); d > 0 {
+			fmt.Fprintf(sb, "\n  %-19s %s", name+":", roundLatency(d))
+		}
+	}
+
+	phase("DNS lookup", t.dnsStart, t.dnsDone)
+	phase("TCP connect", t.connectStart, t.connectDone)
+	phase("TLS handshake", t.tlsStart, t.tlsDone)
+	phase("Time to first byte", t.start, t.firstByte)
+	phase("Content transfer", t.firstByte, t.done)
+	phase("Total", t.start, t.done)
+
+	connection := "new"
+	if t.reused {
+		connection = "reused"
+	}
+	fmt.Fprintf(sb, "\n  %-19s %s", "Connection:", connection)
+
+	return sb.String()
+}
+
+// logTimings logs the timing breakdown of a request once its body has been
+// read.
+func logTimings(req *http.Request, t *requestTimings, retry int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if logJSON() {
+		writeLogEvent(logEvent{
+			Level:      "info",
+			Event:      "timings",
+			Method:     req.Method,
+			URL:        visibleURL(req),
+			DurationMS: durationMS(between(t.start, t.done)),
+			Timings:    t.event(),
+			Retry:      &retry,
+		})
+		return
+	}
+
+	LogInfo("Timings for %s %s:%s", req.Method, visibleURL(req), t.String())
+}
+
+// timedBody wraps a response body to log the request timings once the body
+// has been fully read or closed, so that the content transfer is included.
+type timedBody struct {
+	io.ReadCloser
+	once  sync.Once
+	bytes *int64
+	done  func()
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	*b.bytes += int64(n)
+	if err == io.EOF {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// withTimings wraps the response body to log the timings once it is read and
+// report the request as done.
+func withTimings(req *http.Request, resp *http.Response, t *requestTimings, retry int) {
+	resp.Body = &timedBody{
+		ReadCloser: resp.Body,
+		bytes:      &t.bytes,
+		done: func() {
+			t.mu.Lock()
+			t.done = time.Now()
+			t.mu.Unlock()
+			if timingsEnabled() {
+				logTimings(req, t, retry)
+			}
+			if OnRequestDone != nil {
+				OnRequestDone(RequestMetric{
+					Method:   req.Method,
+					URL:      visibleURL(req),
+					Status:   resp.StatusCode,
+					Retry:    retry,
+					Bytes:    t.bytes,
+					Duration: between(t.start, t.done),
+					Header:   resp.Header,
+				})
+			}
+		},
+	}
+}
@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"golang.org/x/crypto/pkcs12"
+)
+
+// certExpiryWarning is how far ahead of a client certificate's expiration
+// date a warning is shown in verbose output.
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// tlsConfigs caches built TLS configs by their settings so that certificates
+// are only loaded (and passphrases only prompted for) once per process.
+var tlsConfigs = map[string]*tls.Config{}
+var tlsMu sync.Mutex
+
+// tlsTransports caches an HTTP transport for each TLS config, so that requests
+// to different APIs never share connections made with other certificates.
+var tlsTransports = map[*tls.Config]*http.Transport{}
+
+// cacheKey returns a unique key for the TLS settings.
+func (c TLSConfig) cacheKey() string {
+	pkcs11 := ""
+	if c.PKCS11 != nil {
+		pkcs11 = c.PKCS11.Path + "|" + c.PKCS11.Label
+	}
+	return fmt.Sprintf("%t|%s|%s|%s|%s|%s|%s", c.InsecureSkipVerify, c.Cert, c.Key, c.CACert, c.CABundle, strings.Join(c.Pins, ","), pkcs11)
+}
+
+// getTLSConfig returns the TLS client config for the given settings and HTTP
+// version, loading any certificates from disk the first time it is called.
+func getTLSConfig(c TLSConfig, httpVersion string) (*tls.Config, error) {
+	tlsMu.Lock()
+	defer tlsMu.Unlock()
+
+	key := c.cacheKey() + "|" + httpVersion
+	if cached := tlsConfigs[key]; cached != nil {
+		return cached, nil
+	}
+
+	conf := &tls.Config{
+		// Set explicitly, as the transport would otherwise add HTTP/2 to this
+		// shared config the first time it is used.
+		NextProtos: nextProtos(httpVersion),
+	}
+
+	if c.InsecureSkipVerify {
+		LogWarning("Disabling TLS security checks")
+		conf.InsecureSkipVerify = true
+	}
+
+	if c.PKCS11 != nil {
+		conf.GetClientCertificate = getCertFromPkcs11(c.PKCS11)
+	}
+
+	if c.Cert != "" {
+		cert, err := loadClientCert(c.Cert, c.Key)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.CACert != "" {
+		caCert, err := os.ReadFile(c.CACert)
+		if err != nil {
+			return nil, err
+		}
+		roots := BestEffortSystemCertPool()
+		if c.CABundle != "" {
+			// The bundle replaces the system roots, see below.
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to append CACert %s RootCA list", c.CACert)
+		}
+		conf.RootCAs = roots
+	}
+
+	if c.CABundle != "" {
+		bundle, err := os.ReadFile(c.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		// Unlike `ca_cert` the bundle replaces the system roots entirely, so
+		// only it and any `ca_cert` are trusted.
+		if conf.RootCAs == nil {
+			conf.RootCAs = x509.NewCertPool()
+		}
+		if !conf.RootCAs.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", c.CABundle)
+		}
+	}
+
+	if len(c.Pins) > 0 {
+		conf.VerifyConnection = verifyPins(c.Pins)
+	}
+
+	tlsConfigs[key] = conf
+	return conf, nil
+}
+
+// tlsTransport returns the HTTP transport to use for a TLS config, which is a
+// copy of the default transport. If the default transport has been replaced,
+// e.g. by a mock in tests, then it is returned as-is.
+func tlsTransport(conf *tls.Config, httpVersion string) http.RoundTripper {
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return http.DefaultTransport
+	}
+
+	tlsMu.Lock()
+	defer tlsMu.Unlock()
+
+	t := tlsTransports[conf]
+	if t == nil {
+		t = base.Clone()
+		t.TLSClientConfig = conf
+		// Otherwise the transport adds `h2` to the config's protocols.
+		t.ForceAttemptHTTP2 = httpVersion != "1.1"
+		tlsTransports[conf] = t
+	}
+	return t
+}
+
+// closeIdleConnections closes idle connections of the default transport and
+// of all transports for TLS configs.
+func closeIdleConnections() {
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
+
+	tlsMu.Lock()
+	defer tlsMu.Unlock()
+	for _, t := range tlsTransports {
+		t.CloseIdleConnections()
+	}
+}
+
+// spkiPin returns the base64 encoded SHA-256 hash of a certificate's subject
+// public key info, as used by HPKP and `openssl` pinning recipes.
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// verifyPins returns a TLS connection verification callback which requires
+// one of the certificates to match one of the given SPKI pins. It runs even
+// when `InsecureSkipVerify` is set, so `--rsh-insecure` does not bypass it.
+func verifyPins(pins []string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("certificate pin mismatch (got no certificate, want %s)", strings.Join(pins, ", "))
+		}
+
+		// Only the leaf is known to be legitimate if the chain was not verified,
+		// otherwise pinning an intermediate or root is also allowed.
+		candidates := []*x509.Certificate{cs.PeerCertificates[0]}
+		for _, chain := range cs.VerifiedChains {
+			candidates = append(candidates, chain...)
+		}
+
+		for _, cert := range candidates {
+			got := spkiPin(cert)
+			for _, pin := range pins {
+				if got == pin {
+					return nil
+				}
+			}
+		}
+
+		return fmt.Errorf("certificate pin mismatch (got %s, want %s)", spkiPin(cs.PeerCertificates[0]), strings.Join(pins, ", "))
+	}
+}
+
+// isPKCS12 returns true if the filename looks like a PKCS#12 bundle.
+func isPKCS12(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".p12" || ext == ".pfx"
+}
+
+// askPassphrase gets the passphrase for an encrypted key or bundle, first
+// from the environment and then by prompting the user.
+func askPassphrase(filename string) (string, error) {
+	if passphrase := os.Getenv("RSH_CLIENT_KEY_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+
+	passphrase := ""
+	err := survey.AskOne(&survey.Password{Message: "Passphrase for " + filename + ":"}, &passphrase)
+	return passphrase, err
+}
+
+// loadClientCert loads a client certificate and private key from either PEM
+// files or a single PKCS#12 bundle, prompting for a passphrase if needed.
+func loadClientCert(certFile, keyFile string) (tls.Certificate, error) {
+	var cert tls.Certificate
+	var err error
+
+	if isPKCS12(certFile) {
+		cert, err = loadPKCS12(certFile)
+	} else {
+		cert, err = loadPEM(certFile, keyFile)
+	}
+	if err != nil {
+		return cert, err
+	}
+
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return cert, err
+		}
+	}
+
+	if remaining := time.Until(cert.Leaf.NotAfter); remaining < 0 {
+		LogWarning("Client certificate %s expired on %s", certFile, cert.Leaf.NotAfter.Format(time.RFC3339))
+	} else if verbosity >= LevelInfo && remaining < certExpiryWarning {
+		LogWarning("Client certificate %s expires in %d days on %s", certFile, int(remaining.Hours()/24), cert.Leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	return cert, nil
+}
+
+// loadPEM loads a PEM encoded certificate and key, decrypting the key with a
+// passphrase if it is encrypted.
+func loadPEM(certFile, keyFile string) (tls.Certificate, error) {
+	if keyFile == "" {
+		// Allow the key to be bundled into the same file as the certificate.
+		keyFile = certFile
+	}
+
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if keyPEM, err = decryptPEMKey(keyFile, keyPEM); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return cert, fmt.Errorf("unable to load client certificate %s with key %s: %w", certFile, keyFile, err)
+	}
+
+	return cert, nil
+}
+
+// decryptPEMKey finds the private key block in PEM data and returns it
+// decrypted. Unencrypted keys are returned as-is.
+func decryptPEMKey(filename string, data []byte) ([]byte, error) {
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return data, nil
+		}
+
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+
+		if block.Type == "ENCRYPTED PRIVATE KEY" {
+			return nil, fmt.Errorf("unable to load %s: encrypted PKCS#8 keys are not supported, please use a PKCS#12 bundle or a traditional encrypted PEM key", filename)
+		}
+
+		//lint:ignore SA1019 legacy PEM encryption is what tools like openssl produce with `-des3` or `-aes256`.
+		if !x509.IsEncryptedPEMBlock(block) {
+			return data, nil
+		}
+
+		passphrase, err := askPassphrase(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		//lint:ignore SA1019 see above.
+		decrypted, err := x509.DecryptPEMBlock(block, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt key %s: %w", filename, err)
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: decrypted}), nil
+	}
+}
+
+// loadPKCS12 loads a certificate and private key from a PKCS#12 bundle. An
+// empty passphrase is tried first before prompting for one.
+func loadPKCS12(filename string) (tls.Certificate, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	blocks, err := pkcs12.ToPEM(data, "")
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		var passphrase string
+		if passphrase, err = askPassphrase(filename); err != nil {
+			return tls.Certificate{}, err
+		}
+		blocks, err = pkcs12.ToPEM(data, passphrase)
+	}
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to load client certificate bundle %s: %w", filename, err)
+	}
+
+	// Convert to PEM so the key pair gets the same validation as PEM files.
+	var certPEM, keyPEM []byte
+	for _, block := range blocks {
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			keyPEM = append(keyPEM, pem.EncodeToMemory(block)...)
+		} else {
+			certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return cert, fmt.Errorf("unable to load client certificate bundle %s: %w", filename, err)
+	}
+
+	return cert, nil
+}
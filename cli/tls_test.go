@@ -0,0 +1,303 @@
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate signed by `parent`, or a self-signed CA
+// if `parent` is nil.
+func newTestCert(t *testing.T, name string, parent *testCert, expires time.Duration) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(expires),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	filename := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(filename, data, 0600))
+	return filename
+}
+
+// serveMTLS starts a server which requires a client certificate signed by
+// the given CA and returns the CA filename and server URL.
+func serveMTLS(t *testing.T, ca *testCert) (string, string) {
+	server := newTestCert(t, "localhost", ca, 24*time.Hour)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	keyPair, err := tls.X509KeyPair(server.certPEM(), server.keyPEM(t))
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"client": "` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	return writeTestFile(t, "ca.pem", ca.certPEM()), strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+}
+
+func TestClientCertPerAPI(t *testing.T) {
+	ca := newTestCert(t, "Test CA", nil, 24*time.Hour)
+	caFile, base := serveMTLS(t, ca)
+	client := newTestCert(t, "gateway-client", ca, 365*24*time.Hour)
+
+	reset(false)
+	configs["mtls-api"] = &APIConfig{
+		name: "mtls-api",
+		Base: base,
+		TLS: &TLSConfig{
+			Cert:   writeTestFile(t, "client.pem", client.certPEM()),
+			Key:    writeTestFile(t, "client.key", client.keyPEM(t)),
+			CACert: caFile,
+		},
+	}
+	defer delete(configs, "mtls-api")
+
+	req, _ := http.NewRequest(http.MethodGet, base+"/items", nil)
+	resp, err := GetParsedResponse(req, WithoutLog())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"client": "gateway-client"}, resp.Body)
+}
+
+func TestClientCertConcurrent(t *testing.T) {
+	ca := newTestCert(t, "Test CA", nil, 24*time.Hour)
+
+	reset(false)
+	for _, name := range []string{"client-a", "client-b"} {
+		caFile, base := serveMTLS(t, ca)
+		client := newTestCert(t, name, ca, 365*24*time.Hour)
+		configs[name] = &APIConfig{
+			name: name,
+			Base: base,
+			TLS: &TLSConfig{
+				Cert:   writeTestFile(t, "client.pem", client.certPEM()),
+				Key:    writeTestFile(t, "client.key", client.keyPEM(t)),
+				CACert: caFile,
+			},
+		}
+		defer delete(configs, name)
+	}
+
+	// Switching between APIs must not send another API's certificate.
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		name := []string{"client-a", "client-b"}[i%2]
+		go func() {
+			req, _ := http.NewRequest(http.MethodGet, configs[name].Base+"/items", nil)
+			resp, err := GetParsedResponse(req, WithoutLog())
+			if err == nil && resp.Body.(map[string]any)["client"] != name {
+				err = fmt.Errorf("expected client %s, got %v", name, resp.Body)
+			}
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < cap(errs); i++ {
+		assert.NoError(t, <-errs)
+	}
+}
+
+func TestClientCertEncryptedKey(t *testing.T) {
+	client := newTestCert(t, "client", nil, 365*24*time.Hour)
+
+	der, err := x509.MarshalECPrivateKey(client.key)
+	require.NoError(t, err)
+	//lint:ignore SA1019 testing support for legacy encrypted keys.
+	block, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", der, []byte("hunter2"), x509.PEMCipherAES256)
+	require.NoError(t, err)
+
+	t.Setenv("RSH_CLIENT_KEY_PASSPHRASE", "hunter2")
+	cert, err := loadClientCert(
+		writeTestFile(t, "client.pem", client.certPEM()),
+		writeTestFile(t, "client.key", pem.EncodeToMemory(block)),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "client", cert.Leaf.Subject.CommonName)
+}
+
+func TestClientCertMismatch(t *testing.T) {
+	client := newTestCert(t, "client", nil, 365*24*time.Hour)
+	other := newTestCert(t, "other", nil, 365*24*time.Hour)
+
+	_, err := loadClientCert(
+		writeTestFile(t, "client.pem", client.certPEM()),
+		writeTestFile(t, "other.key", other.keyPEM(t)),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "private key does not match public key")
+}
+
+func TestClientCertExpiryWarning(t *testing.T) {
+	client := newTestCert(t, "client", nil, 10*24*time.Hour)
+	certFile := writeTestFile(t, "client.pem", client.certPEM())
+	keyFile := writeTestFile(t, "client.key", client.keyPEM(t))
+
+	reset(false)
+	capture := &strings.Builder{}
+	Stderr = capture
+
+	verbosity = LevelRequest
+	defer func() { verbosity = 0 }()
+
+	_, err := loadClientCert(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Contains(t, capture.String(), "Client certificate "+certFile+" expires in 9 days")
+}
+
+func TestCertificatePinning(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	pin := spkiPin(ts.Certificate())
+	bundle := writeTestFile(t, "bundle.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}))
+
+	defer viper.Set("rsh-insecure", false)
+	defer viper.Set("rsh-insecure-disable-pinning", false)
+
+	request := func(pins []string, flags map[string]bool) error {
+		reset(false)
+		for k, v := range flags {
+			viper.Set(k, v)
+		}
+		configs["pinned-api"] = &APIConfig{
+			name: "pinned-api",
+			Base: ts.URL,
+			TLS:  &TLSConfig{CABundle: bundle, Pins: pins},
+		}
+		defer delete(configs, "pinned-api")
+
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		_, err := MakeRequest(req)
+		return err
+	}
+
+	assert.NoError(t, request([]string{pin}, nil))
+
+	err := request([]string{"bad-pin"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate pin mismatch (got "+pin+", want bad-pin)")
+
+	err = request([]string{"bad-pin"}, map[string]bool{"rsh-insecure": true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate pin mismatch")
+
+	assert.NoError(t, request([]string{"bad-pin"}, map[string]bool{"rsh-insecure-disable-pinning": true}))
+}
+
+func TestCABundleReplacesSystemRoots(t *testing.T) {
+	bundle := writeTestFile(t, "bundle.pem", newTestCert(t, "bundle", nil, time.Hour).certPEM())
+	ca := writeTestFile(t, "ca.pem", newTestCert(t, "ca", nil, time.Hour).certPEM())
+
+	conf, err := getTLSConfig(TLSConfig{CABundle: bundle, CACert: ca}, "")
+	require.NoError(t, err)
+	assert.Len(t, conf.RootCAs.Subjects(), 2)
+}
+
+func TestCertificatePinningConcurrent(t *testing.T) {
+	reset(false)
+
+	// Each API only trusts and pins its own server, so a request must never
+	// use a connection or TLS config set up for the other one.
+	for _, name := range []string{"pinned-a", "pinned-b"} {
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+
+		configs[name] = &APIConfig{
+			name: name,
+			Base: ts.URL,
+			TLS: &TLSConfig{
+				CABundle: writeTestFile(t, "bundle.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})),
+				Pins:     []string{spkiPin(ts.Certificate())},
+			},
+		}
+		defer delete(configs, name)
+	}
+
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		config := configs[[]string{"pinned-a", "pinned-b"}[i%2]]
+		go func() {
+			req, _ := http.NewRequest(http.MethodGet, config.Base, nil)
+			resp, err := MakeRequest(req, WithoutLog())
+			if err == nil {
+				resp.Body.Close()
+			}
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < cap(errs); i++ {
+		assert.NoError(t, <-errs)
+	}
+}
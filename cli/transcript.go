@@ -0,0 +1,397 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+// TranscriptEntry is a single recorded request/response exchange. Each entry
+// is written to its own numbered file in the transcript directory.
+type TranscriptEntry struct {
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	RequestHeaders  http.Header `json:"request_headers,omitempty"`
+	RequestBody     string      `json:"request_body,omitempty"`
+	Proto           string      `json:"proto"`
+	Status          int         `json:"status"`
+	ResponseHeaders http.Header `json:"response_headers,omitempty"`
+	ResponseBody    string      `json:"response_body,omitempty"`
+
+	// BodyEncoding is set to `base64` when the bodies are not valid UTF-8.
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// encodeBodies sets the request & response bodies, falling back to base64 if
+// either one is binary.
+func (e *TranscriptEntry) encodeBodies(req, resp []byte) {
+	if utf8.Valid(req) && utf8.Valid(resp) {
+		e.RequestBody = string(req)
+		e.ResponseBody = string(resp)
+		return
+	}
+
+	e.BodyEncoding = "base64"
+	e.RequestBody = base64.StdEncoding.EncodeToString(req)
+	e.ResponseBody = base64.StdEncoding.EncodeToString(resp)
+}
+
+// responseBody returns the decoded response body bytes.
+func (e *TranscriptEntry) responseBody() ([]byte, error) {
+	if e.BodyEncoding == "base64" {
+		return base64.StdEncoding.DecodeString(e.ResponseBody)
+	}
+	return []byte(e.ResponseBody), nil
+}
+
+// transcriptFiles returns the sorted list of numbered transcript files in a
+// directory along with the highest number found.
+func transcriptFiles(dir string) ([]string, int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	files := []string{}
+	max := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		if n > max {
+			max = n
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+
+	return files, max, nil
+}
+
+// recordTransport writes each request and response it sees to a transcript
+// directory before returning the response to the caller.
+type recordTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+// transcriptMu guards the transcript counters.
+var transcriptMu sync.Mutex
+
+// transcriptCounters holds the number of the last recorded transcript file
+// for each directory that has been scanned.
+var transcriptCounters = map[string]int{}
+
+func (t *recordTransport) nextFilename() (string, error) {
+	transcriptMu.Lock()
+	defer transcriptMu.Unlock()
+
+	counter, ok := transcriptCounters[t.dir]
+	if !ok {
+		if err := os.MkdirAll(t.dir, 0700); err != nil {
+			return "", err
+		}
+		_, max, err := transcriptFiles(t.dir)
+		if err != nil {
+			return "", err
+		}
+		counter = max
+	}
+	counter++
+	transcriptCounters[t.dir] = counter
+
+	return filepath.Join(t.dir, fmt.Sprintf("%04d.json", counter)), nil
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &TranscriptEntry{
+		Method:          req.Method,
+		URL:             redactURL(req),
+		RequestHeaders:  redactHeaders(req.Header),
+		Proto:           resp.Proto,
+		Status:          resp.StatusCode,
+		ResponseHeaders: redactHeaders(resp.Header),
+	}
+
+	filename, err := t.nextFilename()
+	if err != nil {
+		return nil, err
+	}
+	HTTPLog.Log(LevelRequest, "Recording %s %s to %s", req.Method, visibleURL(req), filename)
+
+	if isEventStream(resp) {
+		// Event streams may never end, so record whatever was read once the
+		// caller closes the body instead of waiting for all of it.
+		resp.Body = &recordBody{
+			ReadCloser: resp.Body,
+			write: func(respBody []byte) error {
+				entry.encodeBodies(reqBody, respBody)
+				return writeTranscript(filename, entry)
+			},
+		}
+		return resp, nil
+	}
+
+	// The response body is recorded as-is, without decoding any content
+	// encoding, so that replays behave exactly like the original.
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	entry.encodeBodies(reqBody, respBody)
+	if err := writeTranscript(filename, entry); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// writeTranscript writes a single transcript entry to a file.
+func writeTranscript(filename string, entry *TranscriptEntry) error {
+	// Keep redacted placeholders readable rather than escaping `<` & `>`.
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(entry); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, buf.Bytes(), 0600)
+}
+
+// recordBody copies a streamed response body as it is read and records it
+// when closed.
+type recordBody struct {
+	io.ReadCloser
+	buf   bytes.Buffer
+	once  sync.Once
+	write func([]byte) error
+}
+
+func (b *recordBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *recordBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		if werr := b.write(b.buf.Bytes()); werr != nil {
+			LogWarning("Unable to record transcript: %v", werr)
+		}
+	})
+	return err
+}
+
+// replayTransport serves responses from a recorded transcript directory
+// rather than going out to the network.
+type replayTransport struct {
+	dir     string
+	entries []*TranscriptEntry
+	used    []bool
+	mu      sync.Mutex
+}
+
+// loadReplay loads all transcript entries in a directory.
+func loadReplay(dir string) (*replayTransport, error) {
+	files, _, err := transcriptFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &replayTransport{dir: dir}
+	for _, filename := range files {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		var entry TranscriptEntry
+		if err := json.Unmarshal(b, &entry); err != nil {
+			return nil, fmt.Errorf("unable to load transcript %s: %w", filename, err)
+		}
+		t.entries = append(t.entries, &entry)
+	}
+	t.used = make([]bool, len(t.entries))
+
+	return t, nil
+}
+
+// commonPrefixLen returns the length of the shared prefix of two strings.
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// find returns the next recorded entry for a method and URL. Entries are
+// used in the order they were recorded so that repeated requests to the same
+// resource return each recorded response in turn, with the last one being
+// re-used once all have been served.
+func (t *replayTransport) find(method, url string) *TranscriptEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var last *TranscriptEntry
+	for i, entry := range t.entries {
+		if entry.Method != method || entry.URL != url {
+			continue
+		}
+		if !t.used[i] {
+			t.used[i] = true
+			return entry
+		}
+		last = entry
+	}
+
+	return last
+}
+
+// nearest returns the recorded request which most closely matches a method
+// and URL for use in error messages.
+func (t *replayTransport) nearest(method, url string) *TranscriptEntry {
+	var best *TranscriptEntry
+	bestScore := -1
+	for _, entry := range t.entries {
+		score := commonPrefixLen(entry.URL, url)
+		if entry.Method == method {
+			// Prefer matching methods over slightly longer URL matches.
+			score += len(url)
+		}
+		if score > bestScore {
+			best = entry
+			bestScore = score
+		}
+	}
+	return best
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	// Recorded URLs have their secrets redacted, so compare them the same way.
+	u := redactURL(req)
+	entry := t.find(req.Method, u)
+	if entry == nil {
+		msg := fmt.Sprintf("no recorded response in %s for %s %s", t.dir, req.Method, visibleURL(req))
+		if nearest := t.nearest(req.Method, u); nearest != nil {
+			msg += fmt.Sprintf(", nearest recorded request is %s %s", nearest.Method, nearest.URL)
+		}
+		return nil, fmt.Errorf("%s", msg)
+	}
+
+	body, err := entry.responseBody()
+	if err != nil {
+		return nil, err
+	}
+
+	HTTPLog.Log(LevelRequest, "Replaying %s %s from %s", req.Method, visibleURL(req), t.dir)
+
+	proto := entry.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	major, minor, _ := http.ParseHTTPVersion(proto)
+
+	headers := entry.ResponseHeaders.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status)),
+		StatusCode:    entry.Status,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        headers,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// replaysMu guards the loaded transcripts, which may be used by parallel
+// requests.
+var replaysMu sync.Mutex
+
+// replays caches loaded transcripts by directory so that the order in which
+// entries are used is tracked across requests.
+var replays = map[string]*replayTransport{}
+
+// transcriptClient wraps a client to record or replay requests if either
+// has been requested via `--rsh-record` or `--rsh-replay`.
+func transcriptClient(client *http.Client) (*http.Client, error) {
+	if dir := viper.GetString("rsh-replay"); dir != "" {
+		replaysMu.Lock()
+		replay := replays[dir]
+		if replay == nil {
+			var err error
+			if replay, err = loadReplay(dir); err != nil {
+				replaysMu.Unlock()
+				return nil, err
+			}
+			replays[dir] = replay
+		}
+		replaysMu.Unlock()
+
+		// Replays never hit the network or the local cache.
+		wrapped := *client
+		wrapped.Transport = replay
+		return &wrapped, nil
+	}
+
+	if dir := viper.GetString("rsh-record"); dir != "" {
+		next := client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+
+		wrapped := *client
+		wrapped.Transport = &recordTransport{dir: dir, next: next}
+		return &wrapped, nil
+	}
+
+	return client, nil
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestRecordReplay(t *testing.T) {
+	defer gock.Off()
+
+	dir := t.TempDir()
+
+	gock.New("http://example.com").Post("/items").MatchParam("api_key", "secret").Reply(201).JSON(map[string]any{
+		"id": 1,
+	})
+
+	out := run("post http://example.com/items?api_key=secret -H Authorization:abc123 --rsh-no-cache --rsh-record " + dir + " name: foo")
+	assert.Contains(t, out, "201 Created")
+	assert.True(t, gock.IsDone())
+
+	b, err := os.ReadFile(filepath.Join(dir, "0001.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"request_body": "{\"name\":\"foo\"}"`)
+	assert.Contains(t, string(b), redact("abc123"))
+	assert.NotContains(t, string(b), "abc123")
+	assert.Contains(t, string(b), `"url": "http://example.com/items?api_key=`+redact("secret")+`"`)
+	assert.NotContains(t, string(b), "=secret")
+
+	// Replay doesn't need the network.
+	gock.Off()
+	replays = map[string]*replayTransport{}
+	out = run("post http://example.com/items?api_key=secret --rsh-replay " + dir + " name: foo")
+	assert.Contains(t, out, "201 Created")
+	assert.Contains(t, out, "id: 1")
+
+	// Misses fail and list the nearest request.
+	out = run("get http://example.com/item --rsh-replay " + dir)
+	assert.Contains(t, out, "no recorded response")
+	assert.Contains(t, out, "nearest recorded request is POST http://example.com/items")
+}
+
+func TestRecordStream(t *testing.T) {
+	dir := t.TempDir()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: one\n\n"))
+		w.(http.Flusher).Flush()
+
+		// Keep the stream open until the client goes away.
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	transport := &recordTransport{dir: dir, next: http.DefaultTransport}
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/events", nil)
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "data: one\n", line)
+
+	// Nothing is written until the stream is closed.
+	_, err = os.Stat(filepath.Join(dir, "0001.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	resp.Body.Close()
+
+	b, err := os.ReadFile(filepath.Join(dir, "0001.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"response_body": "data: one\n\n"`)
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/exp/maps"
+)
+
+// maxTruncationNotes is how many truncated locations are listed before the
+// rest are summarized.
+const maxTruncationNotes = 5
+
+// Truncation limits how much of a value is displayed, eliding long arrays and
+// deeply nested objects. Zero values mean no limit.
+type Truncation struct {
+	MaxItems int
+	MaxDepth int
+}
+
+// displayTruncation returns the truncation limits from `--rsh-max-items` and
+// `--rsh-max-depth`, or no limits if `--rsh-full` is set.
+func displayTruncation() Truncation {
+	if viper.GetBool("rsh-full") {
+		return Truncation{}
+	}
+	return Truncation{
+		MaxItems: viper.GetInt("rsh-max-items"),
+		MaxDepth: viper.GetInt("rsh-max-depth"),
+	}
+}
+
+// Enabled returns whether any limits are set.
+func (t Truncation) Enabled() bool {
+	return t.MaxItems > 0 || t.MaxDepth > 0
+}
+
+// Apply returns a copy of the value with long arrays and deep nesting elided,
+// along with a note describing each location which was truncated. The
+// original value is left as-is.
+func (t Truncation) Apply(path string, value any) (any, []string) {
+	if !t.Enabled() {
+		return value, nil
+	}
+
+	notes := []string{}
+	result := t.truncate(path, makeJSONSafe(value), 0, &notes)
+	return result, notes
+}
+
+func (t Truncation) truncate(path string, value any, depth int, notes *[]string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) > 0 && t.MaxDepth > 0 && depth >= t.MaxDepth {
+			*notes = append(*notes, fmt.Sprintf("%s: %s nested more than %d levels deep", path, pluralize(len(v), "key"), t.MaxDepth))
+			return fmt.Sprintf("{… %s}", pluralize(len(v), "key"))
+		}
+
+		keys := maps.Keys(v)
+		sort.Strings(keys)
+		result := make(map[string]any, len(v))
+		for _, k := range keys {
+			result[k] = t.truncate(path+"."+k, v[k], depth+1, notes)
+		}
+		return result
+	case []any:
+		if len(v) > 0 && t.MaxDepth > 0 && depth >= t.MaxDepth {
+			*notes = append(*notes, fmt.Sprintf("%s: %s nested more than %d levels deep", path, pluralize(len(v), "item"), t.MaxDepth))
+			return fmt.Sprintf("[… %s]", pluralize(len(v), "item"))
+		}
+
+		items := v
+		if t.MaxItems > 0 && len(v) > t.MaxItems {
+			items = v[:t.MaxItems]
+		}
+
+		result := make([]any, 0, len(items)+1)
+		for i, item := range items {
+			result = append(result, t.truncate(fmt.Sprintf("%s[%d]", path, i), item, depth+1, notes))
+		}
+
+		if more := len(v) - len(items); more > 0 {
+			*notes = append(*notes, fmt.Sprintf("%s: showing %s of %s items", path, formatCount(len(items)), formatCount(len(v))))
+			result = append(result, elided(more, "item"))
+		}
+		return result
+	}
+
+	return value
+}
+
+// logTruncation tells the user what was left out of the displayed output.
+func logTruncation(notes []string) {
+	if len(notes) == 0 {
+		return
+	}
+
+	shown := notes
+	if len(shown) > maxTruncationNotes {
+		shown = shown[:maxTruncationNotes]
+	}
+
+	msg := "Output truncated, use --rsh-full to show everything:\n  " + strings.Join(shown, "\n  ")
+	if more := len(notes) - len(shown); more > 0 {
+		msg += fmt.Sprintf("\n  and %d more", more)
+	}
+	LogInfo("%s", msg)
+}
+
+// formatCount formats a number with thousands separators, e.g. `9,950`.
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// pluralize formats a count of things, e.g. `1 item` or `9,950 items`.
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return formatCount(n) + " " + noun + "s"
+}
+
+// elided describes left out things, e.g. `… 9,950 more items`.
+func elided(n int, noun string) string {
+	if n != 1 {
+		noun += "s"
+	}
+	return fmt.Sprintf("… %s more %s", formatCount(n), noun)
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncation(t *testing.T) {
+	items := []any{}
+	for i := 0; i < 10000; i++ {
+		items = append(items, i)
+	}
+
+	value := map[string]any{
+		"items": items,
+		"a":     map[string]any{"b": map[string]any{"c": true}},
+		"empty": map[string]any{},
+	}
+
+	result, notes := Truncation{MaxItems: 2, MaxDepth: 2}.Apply("body", value)
+	assert.Equal(t, map[string]any{
+		"items": []any{0, 1, "… 9,998 more items"},
+		"a":     map[string]any{"b": "{… 1 key}"},
+		"empty": map[string]any{},
+	}, result)
+	assert.Equal(t, []string{
+		"body.a.b: 1 key nested more than 2 levels deep",
+		"body.items: showing 2 of 10,000 items",
+	}, notes)
+
+	// The original value is unchanged.
+	assert.Len(t, items, 10000)
+
+	result, notes = Truncation{}.Apply("body", value)
+	assert.Equal(t, value, result)
+	assert.Empty(t, notes)
+}
+
+func TestFormatCount(t *testing.T) {
+	assert.Equal(t, "0", formatCount(0))
+	assert.Equal(t, "999", formatCount(999))
+	assert.Equal(t, "9,950", formatCount(9950))
+	assert.Equal(t, "1,234,567", formatCount(1234567))
+	assert.Equal(t, "-1,000", formatCount(-1000))
+}
+
+func TestFormatterTruncation(t *testing.T) {
+	reset(false)
+	viper.Set("rsh-output-format", "json")
+	viper.Set("rsh-filter", "body")
+	viper.Set("rsh-max-items", 1)
+	defer func() {
+		viper.Set("rsh-output-format", "auto")
+		viper.Set("rsh-filter", "")
+		viper.Set("rsh-max-items", 0)
+		viper.Set("rsh-full", false)
+	}()
+
+	resp := Response{Body: []any{1, 2, 3}}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	Stdout = stdout
+	Stderr = stderr
+
+	// Terminal output is truncated with a note about what was left out.
+	require.NoError(t, NewDefaultFormatter(true, false).Format(resp))
+	assert.Equal(t, "[\n  1,\n  \"… 2 more items\"\n]\n", stdout.String())
+	assert.Contains(t, stderr.String(), "body: showing 1 of 3 items")
+
+	// Redirected output always has the full body.
+	stdout.Reset()
+	stderr.Reset()
+	require.NoError(t, NewDefaultFormatter(false, false).Format(resp))
+	assert.Equal(t, "[\n  1,\n  2,\n  3\n]\n", stdout.String())
+	assert.Empty(t, stderr.String())
+
+	// The limits can be overridden.
+	stdout.Reset()
+	viper.Set("rsh-full", true)
+	require.NoError(t, NewDefaultFormatter(true, false).Format(resp))
+	assert.Equal(t, "[\n  1,\n  2,\n  3\n]\n", stdout.String())
+}
@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsPingInterval is how often to send keep-alive pings to the server.
+var wsPingInterval = 30 * time.Second
+
+// wsCloseTimeout is how long to wait for the server to acknowledge a close.
+var wsCloseTimeout = 5 * time.Second
+
+// webSocketAddress converts `ws://` and `wss://` URLs into their HTTP
+// equivalents so that API short names, profiles, and auth can be applied.
+func webSocketAddress(addr string) string {
+	if strings.HasPrefix(addr, "wss://") {
+		return "https://" + strings.TrimPrefix(addr, "wss://")
+	}
+	if strings.HasPrefix(addr, "ws://") {
+		return "http://" + strings.TrimPrefix(addr, "ws://")
+	}
+	return fixAddress(addr)
+}
+
+// formatMessage prints a text or binary message received from the server.
+// JSON text messages are pretty-printed and binary messages are hex-dumped
+// if `dump` is set, otherwise they are written as-is.
+func formatMessage(messageType int, data []byte, dump bool) error {
+	if messageType == websocket.BinaryMessage {
+		if dump {
+			_, err := fmt.Fprint(Stdout, hex.Dump(data))
+			return err
+		}
+		_, err := Stdout.Write(data)
+		return err
+	}
+
+	var parsed any
+	if err := json.Unmarshal(data, &parsed); err == nil {
+		return formatStreamValue(parsed)
+	}
+	return formatStreamValue(string(data))
+}
+
+// WebSocket connects to the given address and upgrades the connection to a
+// WebSocket, using the same headers, auth, TLS, and proxy settings as a
+// normal request. Each line from stdin is sent as a text message and each
+// received message is printed until either side closes the connection. An
+// error is returned if the server closes the connection abnormally.
+func WebSocket(addr string, dumpBinary bool) error {
+	req, err := http.NewRequest(http.MethodGet, webSocketAddress(addr), nil)
+	if err != nil {
+		return err
+	}
+
+	config, err := prepareRequest(req, &requestConfig{})
+	if err != nil {
+		return err
+	}
+
+	if config.Socket != "" {
+		registerSocket(req.URL, os.ExpandEnv(config.Socket))
+	}
+
+	proxy, err := getProxyFunc(config.Proxy)
+	if err != nil {
+		return err
+	}
+	registerProxy(req.URL, proxy)
+
+	// The upgrade handshake requires HTTP/1.1.
+	tlsConfig, err := requestTLSConfig(config, "1.1")
+	if err != nil {
+		return err
+	}
+
+	dialer := &websocket.Dialer{
+		NetDialContext:   dialContext,
+		Proxy:            proxyForRequest,
+		TLSClientConfig:  tlsConfig,
+		HandshakeTimeout: 45 * time.Second,
+	}
+
+	// The dialer sets its own upgrade headers, and the response body is not
+	// compressed since it is a stream of frames.
+	header := req.Header.Clone()
+	header.Del("Accept-Encoding")
+
+	u := *req.URL
+	u.Scheme = "ws"
+	if req.URL.Scheme == "https" {
+		u.Scheme = "wss"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	LogDebugRequest(req)
+	conn, resp, err := dialer.DialContext(ctx, u.String(), header)
+	if resp != nil {
+		setLastStatus(resp.StatusCode)
+	}
+	if err != nil {
+		if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+			return fmt.Errorf("websocket upgrade failed: %s", resp.Status)
+		}
+		return err
+	}
+	defer conn.Close()
+	HTTPLog.Debug("Connected to %s", u.String())
+
+	var closing int32
+	closeConn := func(code int) {
+		if !atomic.CompareAndSwapInt32(&closing, 0, 1) {
+			return
+		}
+		deadline := time.Now().Add(wsCloseTimeout)
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), deadline)
+
+		// Wait for the server to acknowledge the close, but not forever.
+		conn.SetReadDeadline(deadline)
+	}
+
+	// Background goroutines using the connection are stopped before it is
+	// closed.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	defer wg.Wait()
+	defer close(done)
+
+	// Read lines from stdin separately, since a blocked read can't be
+	// cancelled. Only the reader captured here is used, so it is safe for
+	// callers to replace `Stdin` once this returns.
+	in := Stdin
+	lines := make(chan []byte)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			select {
+			case lines <- append([]byte(nil), scanner.Bytes()...):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// Send each line from stdin as a text message, then close the connection
+	// when there is no more input.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					closeConn(websocket.CloseNormalClosure)
+					return
+				}
+				if err := conn.WriteMessage(websocket.TextMessage, line); err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// Keep the connection alive and close it cleanly if interrupted. Pings
+	// from the server are answered automatically.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsCloseTimeout))
+			case <-ctx.Done():
+				closeConn(websocket.CloseNormalClosure)
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				switch closeErr.Code {
+				case websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived:
+					return nil
+				}
+				if closeErr.Text != "" {
+					return fmt.Errorf("websocket closed with code %d: %s", closeErr.Code, closeErr.Text)
+				}
+				return fmt.Errorf("websocket closed with code %d", closeErr.Code)
+			}
+			if atomic.LoadInt32(&closing) == 1 {
+				// We closed the connection, so errors after that are expected.
+				return nil
+			}
+			return err
+		}
+
+		if err := formatMessage(messageType, data, dumpBinary); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocketAddress(t *testing.T) {
+	assert.Equal(t, "https://example.com/chat", webSocketAddress("wss://example.com/chat"))
+	assert.Equal(t, "http://localhost:8000/chat", webSocketAddress("ws://localhost:8000/chat"))
+	assert.Equal(t, "http://localhost:8000/chat", webSocketAddress(":8000/chat"))
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.BinaryMessage, []byte{0xde, 0xad})
+		for {
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(mt, data)
+		}
+	}))
+	defer ts.Close()
+
+	addr := "ws" + strings.TrimPrefix(ts.URL, "http")
+	WithFakeStdin([]byte("hello\n{\"a\": 1}\n"), fs.ModeNamedPipe, func() {
+		out := run("ws -o json -H Authorization:abc123 -b " + addr)
+		assert.Equal(t, "00000000  de ad                                             |..|\nhello\n{\n  \"a\": 1\n}\n", out)
+	})
+	assert.Equal(t, "abc123", auth)
+	expectExitCode(t, 0)
+}
+
+func TestWebSocketAbnormalClose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "boom"))
+		conn.ReadMessage()
+	}))
+	defer ts.Close()
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		out := run("ws " + ts.URL)
+		assert.Contains(t, out, "websocket closed with code 1011: boom")
+	})
+}
+
+func TestWebSocketUpgradeFailed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		out := run("ws " + ts.URL)
+		assert.Contains(t, out, "websocket upgrade failed: 401 Unauthorized")
+	})
+}
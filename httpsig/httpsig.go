@@ -0,0 +1,322 @@
+// Package httpsig signs requests using HTTP Message Signatures as described
+// in RFC 9421.
+package httpsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+// ErrInvalidProfile is returned when the key file or key ID is missing.
+var ErrInvalidProfile = errors.New("invalid profile, http-signature requires a key_file and key_id")
+
+// Supported signature algorithms, named as in the HTTP Signature Algorithms
+// registry.
+const (
+	AlgorithmEd25519      = "ed25519"
+	AlgorithmRSAPSSSHA512 = "rsa-pss-sha512"
+)
+
+// defaultComponents are signed when the profile doesn't list any.
+const defaultComponents = "@method @target-uri content-digest created"
+
+// now returns the signing time. It can be replaced in tests.
+var now = time.Now
+
+// sigParams describe what a signature covers and how it was created. They are
+// both the last line of the signature base and the `Signature-Input` value.
+type sigParams struct {
+	components []string
+	created    int64
+	keyID      string
+	alg        string
+}
+
+// String serializes the parameters as a structured field inner list.
+func (p sigParams) String() string {
+	quoted := make([]string, len(p.components))
+	for i, c := range p.components {
+		quoted[i] = strconv.Quote(c)
+	}
+
+	s := "(" + strings.Join(quoted, " ") + ")"
+	if p.created != 0 {
+		s += ";created=" + strconv.FormatInt(p.created, 10)
+	}
+	if p.keyID != "" {
+		s += ";keyid=" + strconv.Quote(p.keyID)
+	}
+	if p.alg != "" {
+		s += ";alg=" + strconv.Quote(p.alg)
+	}
+	return s
+}
+
+// componentValue returns the value of a derived component like `@method` or
+// a header field of the request.
+func componentValue(req *http.Request, name string) (string, error) {
+	switch name {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		return req.URL.String(), nil
+	case "@authority":
+		host := req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		return strings.ToLower(host), nil
+	case "@scheme":
+		return strings.ToLower(req.URL.Scheme), nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		if p := req.URL.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	}
+
+	if strings.HasPrefix(name, "@") {
+		return "", fmt.Errorf("unsupported derived component %s", name)
+	}
+
+	values := req.Header.Values(name)
+	if len(values) == 0 {
+		if name == "content-length" && req.ContentLength > 0 {
+			// Go sends the length from the request rather than its headers.
+			return strconv.FormatInt(req.ContentLength, 10), nil
+		}
+		return "", fmt.Errorf("header %s to sign is missing from the request", name)
+	}
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(values, ", "), nil
+}
+
+// signatureBase builds the string which gets signed, one line per covered
+// component followed by the signature parameters.
+func signatureBase(req *http.Request, params sigParams) (string, error) {
+	sb := strings.Builder{}
+	for _, name := range params.components {
+		value, err := componentValue(req, name)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(strconv.Quote(name) + ": " + value + "\n")
+	}
+	sb.WriteString(`"@signature-params": ` + params.String())
+	return sb.String(), nil
+}
+
+// contentDigest returns a `Content-Digest` value for the body, see RFC 9530.
+func contentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// parseKey loads an ed25519 or RSA private key from PEM data.
+func parseKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+
+	var key interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return k, nil
+	case *rsa.PrivateKey:
+		return k, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T, expected ed25519 or RSA", key)
+}
+
+// keyAlgorithm returns the algorithm to sign with, which must match the key.
+func keyAlgorithm(key crypto.Signer, alg string) (string, error) {
+	expected := AlgorithmEd25519
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		expected = AlgorithmRSAPSSSHA512
+	}
+	if alg != "" && alg != expected {
+		return "", fmt.Errorf("algorithm %s doesn't match the key, expected %s", alg, expected)
+	}
+	return expected, nil
+}
+
+// sign signs the signature base with the key.
+func sign(key crypto.Signer, base string) ([]byte, error) {
+	if k, ok := key.(*rsa.PrivateKey); ok {
+		digest := sha512.Sum512([]byte(base))
+		return rsa.SignPSS(rand.Reader, k, crypto.SHA512, digest[:], &rsa.PSSOptions{SaltLength: 64})
+	}
+	return key.(ed25519.PrivateKey).Sign(nil, []byte(base), crypto.Hash(0))
+}
+
+// SignatureHandler signs requests with HTTP Message Signatures using an
+// ed25519 or RSA-PSS private key. Requests with a body get a `Content-Digest`
+// header so the signature can cover it.
+type SignatureHandler struct {
+	mu   sync.Mutex
+	keys map[string]crypto.Signer
+}
+
+// Parameters returns a list of HTTP Message Signature inputs.
+func (h *SignatureHandler) Parameters() []cli.AuthParam {
+	return []cli.AuthParam{
+		{Name: "key_file", Required: true, Help: "Path to a PEM encoded ed25519 or RSA private key"},
+		{Name: "key_id", Required: true, Help: "Key ID the server knows the public key by"},
+		{Name: "algorithm", Help: "Optional ed25519 or rsa-pss-sha512, defaults to the key's type"},
+		{Name: "components", Help: "Optional space separated components to sign, defaults to " + defaultComponents},
+		{Name: "label", Help: "Optional signature label, defaults to sig1"},
+	}
+}
+
+// key returns the cached private key loaded from the given file.
+func (h *SignatureHandler) key(filename string) (crypto.Signer, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.keys == nil {
+		h.keys = map[string]crypto.Signer{}
+	}
+
+	filename = os.ExpandEnv(filename)
+	if k := h.keys[filename]; k != nil {
+		return k, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := parseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %s: %w", filename, err)
+	}
+
+	h.keys[filename] = k
+	return k, nil
+}
+
+// sign adds the content digest and signs the request, replacing any previous
+// signature.
+func (h *SignatureHandler) sign(req *http.Request, params map[string]string) error {
+	if params["key_file"] == "" || params["key_id"] == "" {
+		return ErrInvalidProfile
+	}
+
+	key, err := h.key(params["key_file"])
+	if err != nil {
+		return err
+	}
+
+	alg, err := keyAlgorithm(key, params["algorithm"])
+	if err != nil {
+		return err
+	}
+
+	// The digest is computed from the body, so read it and then reset it to
+	// be sent.
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if hasBody {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.Header.Set("Content-Digest", contentDigest(body))
+	}
+
+	components := params["components"]
+	if components == "" {
+		components = defaultComponents
+	}
+
+	sp := sigParams{keyID: params["key_id"], alg: alg}
+	for _, c := range strings.Fields(strings.ToLower(components)) {
+		switch {
+		case c == "created":
+			sp.created = now().Unix()
+		case c == "content-digest" && !hasBody:
+			// Only requests with a body have a digest to cover.
+		default:
+			sp.components = append(sp.components, c)
+		}
+	}
+
+	base, err := signatureBase(req, sp)
+	if err != nil {
+		return err
+	}
+	if viper.GetBool("rsh-debug-auth") {
+		cli.AuthLog.Debug("HTTP signature base:\n%s", base)
+	}
+
+	sig, err := sign(key, base)
+	if err != nil {
+		return err
+	}
+
+	label := params["label"]
+	if label == "" {
+		label = "sig1"
+	}
+	req.Header.Set("Signature-Input", label+"="+sp.String())
+	req.Header.Set("Signature", label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// OnRequest gets run before the request goes out on the wire.
+func (h *SignatureHandler) OnRequest(req *http.Request, key string, params map[string]string) error {
+	if req.Header.Get("Signature") != "" {
+		return nil
+	}
+
+	return h.sign(req, params)
+}
+
+// OnRetry signs the request again as the signature includes the time.
+func (h *SignatureHandler) OnRetry(req *http.Request, key string, params map[string]string) error {
+	if req.Header.Get("Signature-Input") == "" {
+		// Signed some other way, e.g. via headers.
+		return nil
+	}
+
+	return h.sign(req, params)
+}
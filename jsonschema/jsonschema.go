@@ -0,0 +1,312 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/danielgtaylor/casing"
+	"github.com/danielgtaylor/shorthand/v2"
+	"github.com/tarunKoyalwar/restish/cli"
+	"gopkg.in/yaml.v3"
+)
+
+// rePathParam matches path parameters in an operation's path template.
+var rePathParam = regexp.MustCompile(`{([^}]+)}`)
+
+// catalog is the index file of a JSON Schema catalog. It maps each operation
+// to its method, path, and the JSON Schemas describing its inputs. Schemas
+// are given inline or as references relative to the index file.
+type catalog struct {
+	Title       string      `yaml:"title"`
+	Description string      `yaml:"description"`
+	Operations  []operation `yaml:"operations"`
+}
+
+type operation struct {
+	Name        string   `yaml:"name"`
+	Aliases     []string `yaml:"aliases"`
+	Group       string   `yaml:"group"`
+	Summary     string   `yaml:"summary"`
+	Description string   `yaml:"description"`
+	Method      string   `yaml:"method"`
+	Path        string   `yaml:"path"`
+	Params      struct {
+		Path    any `yaml:"path"`
+		Query   any `yaml:"query"`
+		Headers any `yaml:"headers"`
+	} `yaml:"params"`
+	ContentType string `yaml:"content_type"`
+	Body        any    `yaml:"body"`
+	Hidden      bool   `yaml:"hidden"`
+	Deprecated  bool   `yaml:"deprecated"`
+}
+
+// ref converts a schema given as a reference string into a `$ref` schema so
+// both forms can be handled the same way.
+func ref(node any) any {
+	if s, ok := node.(string); ok {
+		return map[string]any{"$ref": s}
+	}
+	return node
+}
+
+// params converts the properties of an object schema into parameters, in
+// alphabetical order.
+func (r *resolver) params(location *url.URL, node any, style cli.Style) ([]*cli.Param, error) {
+	if node == nil {
+		return nil, nil
+	}
+
+	base, resolved, err := r.deref(location, ref(node))
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := resolved.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("parameters in %s must be an object schema", location)
+	}
+
+	required := map[string]bool{}
+	for _, name := range strs(obj["required"]) {
+		required[name] = true
+	}
+
+	props, _ := obj["properties"].(map[string]any)
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := []*cli.Param{}
+	for _, name := range names {
+		propBase, prop, err := r.deref(base, props[name])
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := r.schema(propBase, prop, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			s = &cli.Schema{}
+		}
+
+		param := &cli.Param{
+			Type:     "string",
+			Name:     name,
+			Style:    style,
+			Explode:  style == cli.StyleForm,
+			Required: required[name],
+			Enum:     s.Enum,
+			Default:  s.Default,
+			Example:  s.Example,
+		}
+
+		if m, ok := prop.(map[string]any); ok {
+			param.Description, _ = m["description"].(string)
+		}
+
+		for _, t := range s.Type {
+			if t != "null" {
+				param.Type = t
+				break
+			}
+		}
+
+		if param.Type == "array" && s.Items != nil {
+			for _, t := range s.Items.Type {
+				if t != "null" {
+					param.Type += "[" + t + "]"
+					break
+				}
+			}
+			param.Enum = s.Items.Enum
+		}
+
+		params = append(params, param)
+	}
+
+	return params, nil
+}
+
+// catalogOperation converts a catalog entry into a CLI operation.
+func (r *resolver) catalogOperation(entrypoint, location *url.URL, op operation) (cli.Operation, error) {
+	method := strings.ToUpper(op.Method)
+
+	name := op.Name
+	if name == "" {
+		name = casing.Kebab(method + "-" + strings.Trim(op.Path, "/"))
+	}
+
+	// Path params are always required and ordered as they are in the path.
+	declared, err := r.params(location, op.Params.Path, cli.StyleSimple)
+	if err != nil {
+		return cli.Operation{}, err
+	}
+
+	var pathParams []*cli.Param
+	for _, match := range rePathParam.FindAllStringSubmatch(op.Path, -1) {
+		param := &cli.Param{Type: "string", Name: match[1]}
+		for _, p := range declared {
+			if p.Name == match[1] {
+				param = p
+			}
+		}
+		param.Required = true
+		pathParams = append(pathParams, param)
+	}
+
+	queryParams, err := r.params(location, op.Params.Query, cli.StyleForm)
+	if err != nil {
+		return cli.Operation{}, err
+	}
+	if len(queryParams) == 0 {
+		queryParams = nil
+	}
+
+	headerParams, err := r.params(location, op.Params.Headers, cli.StyleSimple)
+	if err != nil {
+		return cli.Operation{}, err
+	}
+	if len(headerParams) == 0 {
+		headerParams = nil
+	}
+
+	desc := op.Description
+	mediaType := ""
+	var bodySchema *cli.Schema
+	var examples []string
+	if op.Body != nil {
+		mediaType = op.ContentType
+		if mediaType == "" {
+			mediaType = "application/json"
+		}
+
+		bodySchema, err = r.schema(location, ref(op.Body), map[string]bool{})
+		if err != nil {
+			return cli.Operation{}, err
+		}
+
+		if ex := bodySchema.GenExample(); ex != nil {
+			if m, ok := ex.(map[string]any); ok {
+				if exs := shorthand.MarshalCLI(m); len(exs) < 150 {
+					examples = append(examples, exs)
+				}
+			}
+
+			b, err := json.MarshalIndent(ex, "", "  ")
+			if err != nil {
+				return cli.Operation{}, err
+			}
+			desc += "\n\n## Input Example\n\n```json\n" + string(b) + "\n```\n"
+		}
+	}
+
+	dep := ""
+	if op.Deprecated {
+		dep = "do not use"
+	}
+
+	return cli.Operation{
+		Name:          name,
+		Group:         op.Group,
+		Aliases:       op.Aliases,
+		Short:         op.Summary,
+		Long:          strings.Trim(desc, "\n") + "\n",
+		Method:        method,
+		URITemplate:   strings.TrimSuffix(entrypoint.String(), "/") + op.Path,
+		PathParams:    pathParams,
+		QueryParams:   queryParams,
+		HeaderParams:  headerParams,
+		BodyMediaType: mediaType,
+		BodySchema:    bodySchema,
+		Examples:      examples,
+		Hidden:        op.Hidden,
+		Deprecated:    dep,
+	}, nil
+}
+
+type loader struct{}
+
+func (l *loader) Name() string {
+	return "jsonschema"
+}
+
+func (l *loader) LocationHints() []string {
+	return []string{"/schemas/index.json", "/schemas/index.yaml"}
+}
+
+func (l *loader) Detect(resp *http.Response) bool {
+	body, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+
+	var doc map[string]any
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return false
+	}
+
+	if doc["openapi"] != nil || doc["swagger"] != nil {
+		return false
+	}
+
+	// Look for an operation list where entries have a method and path.
+	ops, _ := doc["operations"].([]any)
+	if len(ops) == 0 {
+		return false
+	}
+	op, _ := ops[0].(map[string]any)
+	return op["method"] != nil && op["path"] != nil
+}
+
+func (l *loader) Load(entrypoint, spec url.URL, resp *http.Response) (cli.API, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cli.API{}, err
+	}
+	defer resp.Body.Close()
+
+	var doc any
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return cli.API{}, err
+	}
+
+	var index catalog
+	if err := yaml.Unmarshal(body, &index); err != nil {
+		return cli.API{}, err
+	}
+
+	// The index is already loaded, so register it for local references.
+	r := &resolver{docs: map[string]any{}}
+	location := spec
+	location.Fragment = ""
+	r.docs[location.String()] = doc
+
+	api := cli.API{
+		Short: index.Title,
+		Long:  index.Description,
+	}
+
+	for _, op := range index.Operations {
+		converted, err := r.catalogOperation(&entrypoint, &location, op)
+		if err != nil {
+			return cli.API{}, fmt.Errorf("operation %s %s: %w", op.Method, op.Path, err)
+		}
+		api.Operations = append(api.Operations, converted)
+	}
+
+	return api, nil
+}
+
+// New creates a new JSON Schema catalog loader.
+func New() cli.Loader {
+	return &loader{}
+}
@@ -0,0 +1,130 @@
+package jsonschema
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+func TestDetect(t *testing.T) {
+	index, err := os.ReadFile("testdata/catalog/index.yaml")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		body     string
+		expected bool
+	}{
+		{string(index), true},
+		{`{"operations": [{"method": "GET", "path": "/"}]}`, true},
+		{`{"openapi": "3.1.0", "operations": [{"method": "GET", "path": "/"}]}`, false},
+		{`{"type": "object"}`, false},
+		{`not: [valid`, false},
+	} {
+		resp := &http.Response{Body: io.NopCloser(strings.NewReader(tc.body))}
+		assert.Equal(t, tc.expected, New().Detect(resp), tc.body)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	body, err := os.ReadFile("testdata/catalog/index.yaml")
+	require.NoError(t, err)
+
+	base, _ := url.Parse("http://api.example.com/")
+	spec, _ := url.Parse("testdata/catalog/index.yaml")
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(string(body)))}
+
+	api, err := New().Load(*base, *spec, resp)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Items API", api.Short)
+	assert.Equal(t, "Manage items.", api.Long)
+	require.Len(t, api.Operations, 2)
+
+	list := api.Operations[0]
+	assert.Equal(t, "list-items", list.Name)
+	assert.Equal(t, http.MethodGet, list.Method)
+	assert.Equal(t, "http://api.example.com/items", list.URITemplate)
+	assert.Nil(t, list.PathParams)
+	assert.Equal(t, []*cli.Param{
+		{Type: "integer", Name: "limit", Style: cli.StyleForm, Explode: true, Default: 20},
+		{Type: "string", Name: "sort", Description: "Sort order", Style: cli.StyleForm, Explode: true, Enum: []any{"name", "price"}},
+	}, list.QueryParams)
+	assert.Empty(t, list.BodyMediaType)
+
+	put := api.Operations[1]
+	assert.Equal(t, "put-items-item-id", put.Name)
+	assert.Equal(t, "items", put.Group)
+	assert.Equal(t, http.MethodPut, put.Method)
+	assert.Equal(t, "http://api.example.com/items/{item-id}", put.URITemplate)
+	assert.Equal(t, []*cli.Param{
+		{Type: "string", Name: "item-id", Required: true, Example: "abc"},
+	}, put.PathParams)
+	assert.Equal(t, []*cli.Param{
+		{Type: "string", Name: "X-Request-Id", Required: true},
+	}, put.HeaderParams)
+	assert.Equal(t, "application/json", put.BodyMediaType)
+	assert.Equal(t, []string{"name: Sprocket, price: 1"}, put.Examples)
+	assert.Contains(t, put.Long, "## Input Example")
+
+	s := put.BodySchema
+	require.NotNil(t, s)
+	assert.True(t, s.NoAdditional)
+	assert.Equal(t, 0.0, *s.Properties["price"].ExclusiveMinimum)
+
+	// The recursive reference is cut off and accepts anything.
+	assert.Nil(t, s.Properties["parent"])
+
+	assert.Empty(t, s.Validate(map[string]any{
+		"name":   "Sprocket",
+		"price":  1.5,
+		"tags":   []any{"a"},
+		"parent": map[string]any{"anything": true},
+	}))
+
+	assert.Equal(t, []string{
+		"/extra: unexpected property",
+		"/name: expected length >= 1, got 0",
+		"/price: expected number > 0, got 0",
+		"/tags/0: expected string, got integer",
+	}, s.Validate(map[string]any{
+		"name":  "",
+		"price": 0,
+		"tags":  []any{1},
+		"extra": true,
+	}))
+}
+
+func TestLoadBadReference(t *testing.T) {
+	base, _ := url.Parse("http://api.example.com/")
+	spec, _ := url.Parse("testdata/catalog/index.yaml")
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(`
+operations:
+  - method: POST
+    path: /items
+    body: missing.json#/$defs/item
+`))}
+
+	_, err := New().Load(*base, *spec, resp)
+	assert.ErrorContains(t, err, "operation POST /items")
+}
+
+func TestLoadRemoteFileReference(t *testing.T) {
+	base, _ := url.Parse("http://api.example.com/")
+	spec, _ := url.Parse("http://api.example.com/catalog/index.yaml")
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(`
+operations:
+  - method: POST
+    path: /items
+    body: file:///etc/passwd
+`))}
+
+	_, err := New().Load(*base, *spec, resp)
+	assert.ErrorContains(t, err, "refusing to load local file file:///etc/passwd")
+}
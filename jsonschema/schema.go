@@ -0,0 +1,348 @@
+package jsonschema
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/tarunKoyalwar/restish/cli"
+	"gopkg.in/yaml.v3"
+)
+
+// resolver loads schema documents relative to the catalog index and follows
+// `$ref` references between them. Documents are only loaded once.
+type resolver struct {
+	docs map[string]any
+}
+
+// resolve a URI reference against the location of the document containing
+// it. Relative file paths stay relative, unlike `url.ResolveReference`.
+func resolve(base *url.URL, ref string) (*url.URL, error) {
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if base.Scheme == "" && parsed.Scheme == "" && !path.IsAbs(parsed.Path) {
+		resolved := *parsed
+		resolved.Path = base.Path
+		if parsed.Path != "" {
+			resolved.Path = path.Join(path.Dir(base.Path), parsed.Path)
+		}
+		return &resolved, nil
+	}
+
+	return base.ResolveReference(parsed), nil
+}
+
+// remote reports whether a location is loaded over HTTP rather than from a
+// local file.
+func remote(location *url.URL) bool {
+	return location.Scheme == "http" || location.Scheme == "https"
+}
+
+// fetch reads a document from a URL or a local file.
+func fetch(location *url.URL) ([]byte, error) {
+	if !remote(location) {
+		return os.ReadFile(os.ExpandEnv(location.Path))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, location.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cli.MakeRequest(req, cli.IgnoreCLIParams(), cli.WithoutHistory())
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.DecodeResponse(resp); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("could not load schema %s: %s", location, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// document returns the parsed JSON or YAML document at a location.
+func (r *resolver) document(location url.URL) (any, error) {
+	location.Fragment = ""
+	key := location.String()
+
+	if doc, ok := r.docs[key]; ok {
+		return doc, nil
+	}
+
+	body, err := fetch(&location)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("could not parse schema %s: %w", key, err)
+	}
+
+	r.docs[key] = doc
+	return doc, nil
+}
+
+// lookup returns the node referenced by `ref` along with its location, which
+// is used to resolve any further references within it.
+func (r *resolver) lookup(base *url.URL, ref string) (*url.URL, any, error) {
+	target, err := resolve(base, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A remote document must not be able to read arbitrary local files.
+	if remote(base) && !remote(target) {
+		return nil, nil, fmt.Errorf("refusing to load local file %s referenced by remote schema %s", target, base)
+	}
+
+	doc, err := r.document(*target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if target.Fragment == "" {
+		return target, doc, nil
+	}
+
+	if !strings.HasPrefix(target.Fragment, "/") {
+		return nil, nil, fmt.Errorf("unsupported reference %s, only JSON pointers are supported", ref)
+	}
+
+	node := doc
+	for _, part := range strings.Split(target.Fragment[1:], "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := node.(map[string]any)
+		if !ok || m[part] == nil {
+			return nil, nil, fmt.Errorf("could not resolve reference %s", target)
+		}
+		node = m[part]
+	}
+
+	return target, node, nil
+}
+
+// deref follows `$ref` references until it reaches an actual schema.
+func (r *resolver) deref(base *url.URL, node any) (*url.URL, any, error) {
+	for i := 0; i < 32; i++ {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return base, node, nil
+		}
+
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return base, node, nil
+		}
+
+		var err error
+		base, node, err = r.lookup(base, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return nil, nil, fmt.Errorf("too many nested references in %s", base)
+}
+
+// number converts a decoded YAML/JSON number.
+func number(v any) *float64 {
+	var f float64
+	switch n := v.(type) {
+	case int:
+		f = float64(n)
+	case int64:
+		f = float64(n)
+	case uint64:
+		f = float64(n)
+	case float64:
+		f = n
+	default:
+		return nil
+	}
+	return &f
+}
+
+// integer converts a decoded YAML/JSON whole number.
+func integer(v any) *int64 {
+	f := number(v)
+	if f == nil {
+		return nil
+	}
+	i := int64(*f)
+	return &i
+}
+
+// strs converts a decoded list of strings, ignoring other values.
+func strs(v any) []string {
+	items, _ := v.([]any)
+	var result []string
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// schema converts a JSON Schema into the serializable subset used by the CLI
+// to validate request bodies and generate examples. Recursive references are
+// left as `nil`, which accepts any value.
+func (r *resolver) schema(base *url.URL, node any, visiting map[string]bool) (*cli.Schema, error) {
+	switch n := node.(type) {
+	case bool:
+		if !n {
+			// Nothing is valid, which is the same as not allowing anything.
+			return &cli.Schema{Not: &cli.Schema{}}, nil
+		}
+		return &cli.Schema{}, nil
+	case map[string]any:
+		if ref, ok := n["$ref"].(string); ok {
+			target, resolved, err := r.lookup(base, ref)
+			if err != nil {
+				return nil, err
+			}
+
+			key := target.String()
+			if visiting[key] {
+				return nil, nil
+			}
+			visiting[key] = true
+			defer delete(visiting, key)
+
+			return r.schema(target, resolved, visiting)
+		}
+
+		var err error
+		sub := func(v any) *cli.Schema {
+			if v == nil || err != nil {
+				return nil
+			}
+			var s *cli.Schema
+			s, err = r.schema(base, v, visiting)
+			return s
+		}
+		list := func(v any) []*cli.Schema {
+			items, _ := v.([]any)
+			var schemas []*cli.Schema
+			for _, item := range items {
+				schemas = append(schemas, sub(item))
+			}
+			return schemas
+		}
+
+		s := &cli.Schema{
+			Minimum:       number(n["minimum"]),
+			Maximum:       number(n["maximum"]),
+			MultipleOf:    number(n["multipleOf"]),
+			MinLength:     integer(n["minLength"]),
+			MaxLength:     integer(n["maxLength"]),
+			MinItems:      integer(n["minItems"]),
+			MaxItems:      integer(n["maxItems"]),
+			MinProperties: integer(n["minProperties"]),
+			MaxProperties: integer(n["maxProperties"]),
+			Required:      strs(n["required"]),
+			Default:       n["default"],
+			Example:       n["example"],
+			AllOf:         list(n["allOf"]),
+			AnyOf:         list(n["anyOf"]),
+			OneOf:         list(n["oneOf"]),
+			Not:           sub(n["not"]),
+		}
+
+		switch t := n["type"].(type) {
+		case string:
+			s.Type = []string{t}
+		case []any:
+			s.Type = strs(t)
+		}
+
+		s.Format, _ = n["format"].(string)
+		s.Pattern, _ = n["pattern"].(string)
+		s.Nullable, _ = n["nullable"].(bool)
+		s.UniqueItems, _ = n["uniqueItems"].(bool)
+		s.ReadOnly, _ = n["readOnly"].(bool)
+		s.Enum, _ = n["enum"].([]any)
+
+		if c, ok := n["const"]; ok {
+			// A constant is equivalent to an enum with a single value.
+			s.Enum = []any{c}
+		}
+
+		if examples, ok := n["examples"].([]any); ok && s.Example == nil && len(examples) > 0 {
+			s.Example = examples[0]
+		}
+
+		// Older drafts use booleans to make the minimum/maximum exclusive.
+		switch e := n["exclusiveMinimum"].(type) {
+		case bool:
+			if e {
+				s.ExclusiveMinimum, s.Minimum = s.Minimum, nil
+			}
+		default:
+			s.ExclusiveMinimum = number(e)
+		}
+		switch e := n["exclusiveMaximum"].(type) {
+		case bool:
+			if e {
+				s.ExclusiveMaximum, s.Maximum = s.Maximum, nil
+			}
+		default:
+			s.ExclusiveMaximum = number(e)
+		}
+
+		// Tuples are `prefixItems` in 2020-12 and an `items` list before that.
+		tuple, additional := n["prefixItems"], n["items"]
+		if items, ok := n["items"].([]any); ok {
+			tuple, additional = items, n["additionalItems"]
+		}
+		s.PrefixItems = list(tuple)
+		if allowed, ok := additional.(bool); ok {
+			if !allowed && len(s.PrefixItems) > 0 && s.MaxItems == nil {
+				count := int64(len(s.PrefixItems))
+				s.MaxItems = &count
+			}
+		} else {
+			s.Items = sub(additional)
+		}
+
+		if props, ok := n["properties"].(map[string]any); ok && len(props) > 0 {
+			s.Properties = map[string]*cli.Schema{}
+			for name, prop := range props {
+				s.Properties[name] = sub(prop)
+			}
+		}
+
+		switch ap := n["additionalProperties"].(type) {
+		case bool:
+			s.NoAdditional = !ap
+		case map[string]any:
+			s.AdditionalProperties = sub(ap)
+		}
+
+		if len(s.Type) == 0 {
+			if s.Items != nil || len(s.PrefixItems) > 0 {
+				s.Type = []string{"array"}
+			}
+			if s.Properties != nil || s.AdditionalProperties != nil {
+				s.Type = []string{"object"}
+			}
+		}
+
+		return s, err
+	}
+
+	return nil, fmt.Errorf("invalid schema in %s", base)
+}
@@ -3,8 +3,11 @@ package main
 import (
 	"os"
 
+	"github.com/tarunKoyalwar/restish/awsauth"
 	"github.com/tarunKoyalwar/restish/bulk"
 	"github.com/tarunKoyalwar/restish/cli"
+	"github.com/tarunKoyalwar/restish/httpsig"
+	"github.com/tarunKoyalwar/restish/jsonschema"
 	"github.com/tarunKoyalwar/restish/oauth"
 	"github.com/tarunKoyalwar/restish/openapi"
 )
@@ -31,10 +34,14 @@ func main() {
 
 	// Register format loaders to auto-discover API descriptions
 	cli.AddLoader(openapi.New())
+	cli.AddLoader(jsonschema.New())
 
 	// Register auth schemes
 	cli.AddAuth("oauth-client-credentials", &oauth.ClientCredentialsHandler{})
 	cli.AddAuth("oauth-authorization-code", &oauth.AuthorizationCodeHandler{})
+	cli.AddAuth("oauth-device-code", &oauth.DeviceCodeHandler{})
+	cli.AddAuth("aws-sigv4", &awsauth.SigV4Handler{})
+	cli.AddAuth("http-signature", &httpsig.SignatureHandler{})
 
 	// Run the CLI, parsing arguments, making requests, and printing responses.
 	if err := cli.Run(); err != nil {
@@ -374,7 +374,7 @@ func (h *AuthorizationCodeHandler) OnRequest(request *http.Request, key string,
 			ClientID:       params["client_id"],
 			TokenURL:       params["token_url"],
 			EndpointParams: &endpointParams,
-			RefreshToken:   cli.Cache.GetString(refreshKey),
+			RefreshToken:   cli.GetSecret(refreshKey),
 			TokenSource:    source,
 		}
 
@@ -0,0 +1,220 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tarunKoyalwar/restish/cli"
+	"golang.org/x/oauth2"
+)
+
+// sleep waits between device code token polls and openBrowser shows the
+// verification page. Both can be replaced in tests.
+var (
+	sleep       = time.Sleep
+	openBrowser = open
+)
+
+// deviceCodeResponse is the device authorization response, see
+// https://www.rfc-editor.org/rfc/rfc8628#section-3.2. Some providers use
+// `verification_url` instead of `verification_uri`.
+type deviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURL         string `json:"verification_url"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceCodeTokenSource implements the OAuth 2.0 device authorization grant
+// as described in https://www.rfc-editor.org/rfc/rfc8628. It requests a
+// device code, has the user enter a short code on another device or in their
+// browser, then polls the token endpoint until the user has logged in.
+type DeviceCodeTokenSource struct {
+	ClientID       string
+	ClientSecret   string
+	DeviceURL      string
+	TokenURL       string
+	EndpointParams *url.Values
+	Scopes         []string
+}
+
+// requestDeviceCode starts the flow by getting a device and user code.
+func (dc *DeviceCodeTokenSource) requestDeviceCode() (*deviceCodeResponse, error) {
+	payload := url.Values{}
+	payload.Set("client_id", dc.ClientID)
+	if scopes := strings.Join(dc.Scopes, " "); strings.TrimSpace(scopes) != "" {
+		payload.Set("scope", scopes)
+	}
+	if dc.EndpointParams != nil {
+		for k, v := range *dc.EndpointParams {
+			payload.Set(k, v[0])
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, dc.DeviceURL, strings.NewReader(payload.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("content-type", "application/x-www-form-urlencoded")
+
+	cli.LogDebugRequest(req)
+
+	start := time.Now()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	cli.LogDebugResponse(start, res)
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+
+	if res.StatusCode > 200 {
+		return nil, fmt.Errorf("bad response from device authorization endpoint:\n%s", body)
+	}
+
+	decoded := &deviceCodeResponse{}
+	if err := json.Unmarshal(body, decoded); err != nil {
+		return nil, err
+	}
+
+	if decoded.VerificationURI == "" {
+		decoded.VerificationURI = decoded.VerificationURL
+	}
+
+	return decoded, nil
+}
+
+// Token generates a new token by having the user authorize this device.
+func (dc *DeviceCodeTokenSource) Token() (*oauth2.Token, error) {
+	code, err := dc.requestDeviceCode()
+	if err != nil {
+		return nil, err
+	}
+
+	// Show the code and open the browser, preferring the URL which already
+	// includes the code so the user does not have to type it.
+	fmt.Fprintln(os.Stderr, "To log in, open your browser to the URL:")
+	fmt.Fprintln(os.Stderr, code.VerificationURI)
+	fmt.Fprintf(os.Stderr, "and enter the code: %s\n", code.UserCode)
+	if code.VerificationURIComplete != "" {
+		openBrowser(code.VerificationURIComplete)
+	} else {
+		openBrowser(code.VerificationURI)
+	}
+
+	// Default to polling every 5 seconds if the server does not say.
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	var deadline time.Time
+	if code.ExpiresIn > 0 {
+		deadline = time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	}
+
+	payload := url.Values{}
+	payload.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+	payload.Set("device_code", code.DeviceCode)
+	payload.Set("client_id", dc.ClientID)
+	if dc.ClientSecret != "" {
+		payload.Set("client_secret", dc.ClientSecret)
+	}
+
+	for {
+		sleep(interval)
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, errors.New("device code expired before login completed")
+		}
+
+		token, err := requestToken(dc.TokenURL, payload.Encode())
+		if err == nil {
+			return token, nil
+		}
+
+		var tokenErr *tokenError
+		if !errors.As(err, &tokenErr) {
+			return nil, err
+		}
+
+		switch tokenErr.Code {
+		case "authorization_pending":
+			cli.AuthLog.Debug("Waiting for device authorization")
+		case "slow_down":
+			// The server asks us to increase the interval by 5 seconds.
+			interval += 5 * time.Second
+			cli.AuthLog.Debug("Slowing down device code polling to every %s", interval)
+		default:
+			return nil, err
+		}
+	}
+}
+
+// DeviceCodeHandler sets up the OAuth 2.0 device authorization grant, used
+// by providers which don't allow the authorization code flow for CLIs.
+type DeviceCodeHandler struct{}
+
+// Parameters returns a list of OAuth2 Device Code inputs.
+func (h *DeviceCodeHandler) Parameters() []cli.AuthParam {
+	return []cli.AuthParam{
+		{Name: "client_id", Required: true, Help: "OAuth 2.0 Client ID"},
+		{Name: "client_secret", Required: false, Help: "OAuth 2.0 Client Secret if exists"},
+		{Name: "device_authorization_url", Required: true, Help: "OAuth 2.0 device authorization URL, e.g. https://api.example.com/oauth/device/code"},
+		{Name: "token_url", Required: true, Help: "OAuth 2.0 token URL, e.g. https://api.example.com/oauth/token"},
+		{Name: "scopes", Help: "Optional scopes to request in the token"},
+	}
+}
+
+// OnRequest gets run before the request goes out on the wire.
+func (h *DeviceCodeHandler) OnRequest(request *http.Request, key string, params map[string]string) error {
+	if request.Header.Get("Authorization") == "" {
+		if params["client_id"] == "" || params["device_authorization_url"] == "" || params["token_url"] == "" {
+			return ErrInvalidProfile
+		}
+
+		endpointParams := url.Values{}
+		for k, v := range params {
+			if k == "client_id" || k == "client_secret" || k == "scopes" || k == "device_authorization_url" || k == "token_url" {
+				// Not a custom param...
+				continue
+			}
+
+			endpointParams.Add(k, v)
+		}
+
+		source := &DeviceCodeTokenSource{
+			ClientID:       params["client_id"],
+			ClientSecret:   params["client_secret"],
+			DeviceURL:      params["device_authorization_url"],
+			TokenURL:       params["token_url"],
+			EndpointParams: &endpointParams,
+			Scopes:         strings.Split(params["scopes"], ","),
+		}
+
+		// Use a cached refresh token when possible so expired tokens are renewed
+		// without the user having to log in again, e.g. during long bulk pushes.
+		refreshSource := RefreshTokenSource{
+			ClientID:       params["client_id"],
+			ClientSecret:   params["client_secret"],
+			TokenURL:       params["token_url"],
+			EndpointParams: &endpointParams,
+			RefreshToken:   cli.GetSecret(key + ".refresh"),
+			TokenSource:    source,
+		}
+
+		return TokenHandler(&refreshSource, key, request)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deviceCodeServer(t *testing.T, responses []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/device":
+			assert.Equal(t, "my-client", r.Form.Get("client_id"))
+			assert.Equal(t, "read write", r.Form.Get("scope"))
+			json.NewEncoder(w).Encode(map[string]any{
+				"device_code":               "dev123",
+				"user_code":                 "ABCD-EFGH",
+				"verification_uri":          "https://example.com/device",
+				"verification_uri_complete": "https://example.com/device?code=ABCD-EFGH",
+				"expires_in":                600,
+				"interval":                  2,
+			})
+		case "/token":
+			assert.Equal(t, "urn:ietf:params:oauth:grant-type:device_code", r.Form.Get("grant_type"))
+			assert.Equal(t, "dev123", r.Form.Get("device_code"))
+
+			next := responses[0]
+			responses = responses[1:]
+			if next == "" {
+				json.NewEncoder(w).Encode(map[string]any{
+					"access_token":  "abc",
+					"refresh_token": "def",
+					"token_type":    "Bearer",
+					"expires_in":    3600,
+				})
+				return
+			}
+
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": next})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestDeviceCodeTokenSource(t *testing.T) {
+	var slept []time.Duration
+	var opened string
+	defer func(s func(time.Duration), o func(string) error) {
+		sleep, openBrowser = s, o
+	}(sleep, openBrowser)
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	openBrowser = func(u string) error { opened = u; return nil }
+
+	ts := deviceCodeServer(t, []string{"authorization_pending", "slow_down", "authorization_pending", ""})
+	defer ts.Close()
+
+	source := &DeviceCodeTokenSource{
+		ClientID:  "my-client",
+		DeviceURL: ts.URL + "/device",
+		TokenURL:  ts.URL + "/token",
+		Scopes:    []string{"read", "write"},
+	}
+
+	token, err := source.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "abc", token.AccessToken)
+	assert.Equal(t, "def", token.RefreshToken)
+	assert.True(t, token.Expiry.After(time.Now()))
+
+	assert.Equal(t, "https://example.com/device?code=ABCD-EFGH", opened)
+
+	// The interval grows by 5 seconds after a slow down response.
+	assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second, 7 * time.Second}, slept)
+}
+
+func TestDeviceCodeDenied(t *testing.T) {
+	defer func(s func(time.Duration), o func(string) error) {
+		sleep, openBrowser = s, o
+	}(sleep, openBrowser)
+	sleep = func(d time.Duration) {}
+	openBrowser = func(u string) error { return nil }
+
+	ts := deviceCodeServer(t, []string{"authorization_pending", "access_denied"})
+	defer ts.Close()
+
+	source := &DeviceCodeTokenSource{
+		ClientID:  "my-client",
+		DeviceURL: ts.URL + "/device",
+		TokenURL:  ts.URL + "/token",
+		Scopes:    []string{"read", "write"},
+	}
+
+	_, err := source.Token()
+	assert.ErrorContains(t, err, "access_denied")
+}
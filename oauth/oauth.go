@@ -25,10 +25,10 @@ func TokenHandler(source oauth2.TokenSource, key string, request *http.Request)
 
 	expiry := cli.Cache.GetTime(expiresKey)
 	if !expiry.IsZero() {
-		cli.LogDebug("Loading OAuth2 token from cache.")
+		cli.AuthLog.Debug("Loading OAuth2 token from cache.")
 		cached = &oauth2.Token{
-			AccessToken:  cli.Cache.GetString(tokenKey),
-			RefreshToken: cli.Cache.GetString(refreshKey),
+			AccessToken:  cli.GetSecret(tokenKey),
+			RefreshToken: cli.GetSecret(refreshKey),
 			TokenType:    cli.Cache.GetString(typeKey),
 			Expiry:       expiry,
 		}
@@ -48,17 +48,17 @@ func TokenHandler(source oauth2.TokenSource, key string, request *http.Request)
 	if cached == nil || (token.AccessToken != cached.AccessToken) {
 		// Token either didn't exist in the cache or has changed, so let's write
 		// the new values to the CLI cache.
-		cli.LogDebug("Token refreshed. Updating cache.")
+		cli.AuthLog.Debug("Token refreshed. Updating cache.")
 
 		cli.Cache.Set(expiresKey, token.Expiry)
 		cli.Cache.Set(typeKey, token.Type())
-		cli.Cache.Set(tokenKey, token.AccessToken)
+		cli.SetSecret(tokenKey, token.AccessToken)
 
 		if token.RefreshToken != "" {
 			// Only set the refresh token if present. This prevents overwriting it
 			// after using a refresh token, because the newly returned token won't
 			// have another refresh token set on it (you keep using the same one).
-			cli.Cache.Set(refreshKey, token.RefreshToken)
+			cli.SetSecret(refreshKey, token.RefreshToken)
 		}
 
 		// Save the cache to disk.
@@ -14,6 +14,9 @@ type RefreshTokenSource struct {
 	// ClientID of the application
 	ClientID string
 
+	// ClientSecret of the application, if it has one
+	ClientSecret string
+
 	// TokenURL is used to fetch new tokens
 	TokenURL string
 
@@ -34,9 +37,13 @@ type RefreshTokenSource struct {
 // back to the original source.
 func (ts *RefreshTokenSource) Token() (*oauth2.Token, error) {
 	if ts.RefreshToken != "" {
-		cli.LogDebug("Trying refresh token to get a new access token")
+		cli.AuthLog.Debug("Trying refresh token to get a new access token")
 		payload := fmt.Sprintf("grant_type=refresh_token&client_id=%s&refresh_token=%s", ts.ClientID, ts.RefreshToken)
 
+		if ts.ClientSecret != "" {
+			payload += "&client_secret=" + url.QueryEscape(ts.ClientSecret)
+		}
+
 		params := ts.EndpointParams.Encode()
 		if len(params) > 0 {
 			payload += "&" + params
@@ -23,6 +23,18 @@ type tokenResponse struct {
 	Expiry       time.Time     `json:"expiry,omitempty"`
 }
 
+// tokenError is an error response from a token endpoint, see
+// https://www.rfc-editor.org/rfc/rfc6749#section-5.2.
+type tokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	body        []byte
+}
+
+func (e *tokenError) Error() string {
+	return fmt.Sprintf("bad response from token endpoint:\n%s", e.body)
+}
+
 // requestToken from the given URL with the given payload. This can be used
 // for many different grant types and will return a parsed token.
 func requestToken(tokenURL, payload string) (*oauth2.Token, error) {
@@ -45,7 +57,10 @@ func requestToken(tokenURL, payload string) (*oauth2.Token, error) {
 	body, _ := io.ReadAll(res.Body)
 
 	if res.StatusCode > 200 {
-		return nil, fmt.Errorf("bad response from token endpoint:\n%s", body)
+		decoded := &tokenError{}
+		json.Unmarshal(body, decoded)
+		decoded.body = body
+		return nil, decoded
 	}
 
 	decoded := tokenResponse{}
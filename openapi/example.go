@@ -36,6 +36,10 @@ func genExampleInternal(s *base.Schema, mode schemaMode, known map[[32]byte]bool
 		return result
 	}
 
+	if s.Const != nil {
+		return s.Const
+	}
+
 	if s.Example != nil {
 		return s.Example
 	}
@@ -117,16 +121,7 @@ func genExampleInternal(s *base.Schema, mode schemaMode, known map[[32]byte]bool
 		return "********"
 	}
 
-	typ := ""
-	for _, t := range s.Type {
-		// Find the first non-null type and use that for now.
-		if t != "null" {
-			typ = t
-			break
-		}
-	}
-
-	switch typ {
+	switch firstType(s) {
 	case "boolean":
 		return true
 	case "integer":
@@ -152,6 +147,23 @@ func genExampleInternal(s *base.Schema, mode schemaMode, known map[[32]byte]bool
 
 		return "string"
 	case "array":
+		if len(s.PrefixItems) > 0 {
+			value := []any{}
+			for _, proxy := range s.PrefixItems {
+				item := proxy.Schema()
+				simple := isSimpleSchema(item)
+				hash := item.GoLow().Hash()
+				if simple || !known[hash] {
+					known[hash] = true
+					value = append(value, genExampleInternal(item, mode, known))
+					known[hash] = false
+				} else {
+					value = append(value, nil)
+				}
+			}
+			return value
+		}
+
 		if s.Items != nil && s.Items.IsA() {
 			items := s.Items.A.Schema()
 			simple := isSimpleSchema(items)
@@ -0,0 +1,264 @@
+package openapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/resolver"
+	"github.com/pb33f/libopenapi/utils"
+	"github.com/tarunKoyalwar/restish/cli"
+	"golang.org/x/exp/maps"
+	"gopkg.in/yaml.v3"
+)
+
+// children returns the entries of a decoded object. YAML allows non-string
+// keys like unquoted response status codes, so those are converted.
+func children(node any) (map[string]any, bool) {
+	switch v := node.(type) {
+	case map[string]any:
+		return v, true
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[fmt.Sprintf("%v", k)] = item
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+// hasPointer returns whether a JSON pointer like `/components/schemas/Item`
+// resolves to something within the document.
+func hasPointer(root any, pointer string) bool {
+	if unescaped, err := url.PathUnescape(pointer); err == nil {
+		pointer = unescaped
+	}
+	if pointer == "" {
+		return true
+	}
+
+	node := root
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		if m, ok := children(node); ok {
+			child, found := m[token]
+			if !found {
+				return false
+			}
+			node = child
+			continue
+		}
+		if items, ok := node.([]any); ok {
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(items) {
+				return false
+			}
+			node = items[i]
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// lintRefs reports local `$ref` values which don't point to anything in the
+// document.
+func lintRefs(root any) []cli.LintIssue {
+	issues := []cli.LintIssue{}
+
+	var walk func(node any, tokens []string)
+	walk = func(node any, tokens []string) {
+		if m, ok := children(node); ok {
+			if ref, ok := m["$ref"].(string); ok && strings.HasPrefix(ref, "#") && !hasPointer(root, ref[1:]) {
+				issues = append(issues, cli.LintIssue{
+					Severity: cli.LintError,
+					Pointer:  cli.JSONPointer(tokens...),
+					Message:  fmt.Sprintf("unresolvable reference %q", ref),
+				})
+			}
+
+			keys := maps.Keys(m)
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(m[k], append(tokens[:len(tokens):len(tokens)], k))
+			}
+		}
+		if items, ok := node.([]any); ok {
+			for i, item := range items {
+				walk(item, append(tokens[:len(tokens):len(tokens)], strconv.Itoa(i)))
+			}
+		}
+	}
+	walk(root, nil)
+
+	return issues
+}
+
+// lintParams reports parameters without a schema, which are treated as
+// strings without any validation or completion.
+func lintParams(params []*v3.Parameter, pointer ...string) []cli.LintIssue {
+	issues := []cli.LintIssue{}
+	for i, p := range params {
+		if getExt(p.Extensions, ExtIgnore, false) || p.Schema != nil || len(p.Content) > 0 {
+			continue
+		}
+		issues = append(issues, cli.LintIssue{
+			Severity: cli.LintWarning,
+			Pointer:  cli.JSONPointer(append(pointer, "parameters", strconv.Itoa(i))...),
+			Message:  fmt.Sprintf("parameter %q has no schema, so it is treated as a string", p.Name),
+		})
+	}
+	return issues
+}
+
+// lintResponses reports successful responses without a schema, which can't be
+// used for response examples or the mock server.
+func lintResponses(op *v3.Operation, pointer ...string) []cli.LintIssue {
+	issues := []cli.LintIssue{}
+	if op.Responses == nil {
+		return issues
+	}
+
+	codes := maps.Keys(op.Responses.Codes)
+	sort.Strings(codes)
+	for _, code := range codes {
+		if !strings.HasPrefix(code, "2") || code == "204" || code == "205" {
+			continue
+		}
+
+		resp := op.Responses.Codes[code]
+		respPointer := append(pointer[:len(pointer):len(pointer)], "responses", code)
+		if len(resp.Content) == 0 {
+			issues = append(issues, cli.LintIssue{
+				Severity: cli.LintWarning,
+				Pointer:  cli.JSONPointer(respPointer...),
+				Message:  fmt.Sprintf("%s response has no content schema", code),
+			})
+			continue
+		}
+
+		mts := maps.Keys(resp.Content)
+		sort.Strings(mts)
+		for _, mt := range mts {
+			if resp.Content[mt].Schema == nil {
+				issues = append(issues, cli.LintIssue{
+					Severity: cli.LintWarning,
+					Pointer:  cli.JSONPointer(append(respPointer, "content", mt)...),
+					Message:  fmt.Sprintf("%s response has no schema for %s", code, mt),
+				})
+			}
+		}
+	}
+
+	return issues
+}
+
+// Lint checks an OpenAPI 3 document for problems which degrade the generated
+// commands.
+func (l *loader) Lint(entrypoint, spec url.URL, resp *http.Response) ([]cli.LintIssue, error) {
+	l.location = &spec
+	l.base = &entrypoint
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	issues := lintRefs(raw)
+	refIssues := len(issues)
+
+	doc, err := newDocument(data, &spec)
+	if err != nil {
+		return nil, err
+	}
+	if doc.GetSpecInfo().SpecType != utils.OpenApi3 {
+		return nil, fmt.Errorf("unsupported OpenAPI document")
+	}
+
+	result, errs := doc.BuildV3Model()
+	for _, err := range errs {
+		if refErr, ok := err.(*resolver.ResolvingError); ok && refErr.CircularReference != nil {
+			// Circular references are supported.
+			continue
+		}
+		if refIssues == 0 {
+			// Only report errors which weren't already found above.
+			issues = append(issues, cli.LintIssue{Severity: cli.LintError, Message: err.Error()})
+		}
+	}
+	if result == nil || result.Model.Paths == nil {
+		return issues, nil
+	}
+	model := result.Model
+
+	basePath, err := getBasePath(l.GetBase(), model.Servers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Command names & aliases to the operation which uses them first.
+	names := map[string]string{}
+
+	uris := maps.Keys(model.Paths.PathItems)
+	sort.Strings(uris)
+	for _, uri := range uris {
+		path := model.Paths.PathItems[uri]
+		if getExt(path.Extensions, ExtIgnore, false) {
+			continue
+		}
+
+		resolved, err := l.Resolve(strings.TrimSuffix(basePath, "/") + uri)
+		if err != nil {
+			return nil, err
+		}
+
+		issues = append(issues, lintParams(path.Parameters, "paths", uri)...)
+
+		operations := path.GetOperations()
+		methods := maps.Keys(operations)
+		sort.Strings(methods)
+		for _, method := range methods {
+			op := operations[method]
+			if op == nil || getExt(op.Extensions, ExtIgnore, false) {
+				continue
+			}
+			pointer := cli.JSONPointer("paths", uri, method)
+
+			name, aliases := operationName(strings.ToUpper(method), resolved.Path, op)
+			if op.OperationId == "" && getExt(op.Extensions, ExtName, "") == "" {
+				issues = append(issues, cli.LintIssue{
+					Severity: cli.LintWarning,
+					Pointer:  pointer,
+					Message:  fmt.Sprintf("operation has no operationId, so its command is named %q", name),
+				})
+			}
+
+			for _, n := range append([]string{name}, aliases...) {
+				if other, ok := names[n]; ok && other != pointer {
+					issues = append(issues, cli.LintIssue{
+						Severity: cli.LintError,
+						Pointer:  pointer,
+						Message:  fmt.Sprintf("command name %q collides with #%s", n, other),
+					})
+					continue
+				}
+				names[n] = pointer
+			}
+
+			issues = append(issues, lintParams(op.Parameters, "paths", uri, method)...)
+			issues = append(issues, lintResponses(op, "paths", uri, method)...)
+		}
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,103 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tarunKoyalwar/restish/cli"
+)
+
+const lintSpec = `openapi: 3.0.0
+info:
+  title: Lint test
+  version: 1.0.0
+paths:
+  /items:
+    get:
+      operationId: listItems
+      parameters:
+        - name: q
+          in: query
+      responses:
+        200:
+          description: OK
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Missing'
+    post:
+      responses:
+        '201':
+          description: Created
+        '204':
+          description: No content
+  /things:
+    get:
+      operationId: list-items
+      responses:
+        '200':
+          description: OK
+          content:
+            application/json:
+              schema:
+                type: array
+components:
+  schemas:
+    Item:
+      type: object
+`
+
+func TestLint(t *testing.T) {
+	l := &loader{}
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(lintSpec))}
+
+	issues, err := l.Lint(*parseURL("https://api.example.com/"), *parseURL("https://api.example.com/openapi.yaml"), resp)
+	require.NoError(t, err)
+
+	assert.Contains(t, issues, cli.LintIssue{
+		Severity: cli.LintError,
+		Pointer:  "/paths/~1items/get/responses/200/content/application~1json/schema",
+		Message:  `unresolvable reference "#/components/schemas/Missing"`,
+	})
+	assert.NotContains(t, issues, cli.LintIssue{
+		Severity: cli.LintError,
+		Pointer:  "/paths/~1items/get/responses/200/content/application~1json/schema",
+		Message:  `unresolvable reference "#/components/schemas/Item"`,
+	})
+}
+
+func TestLintOperations(t *testing.T) {
+	l := &loader{}
+	spec := strings.Replace(lintSpec, "Missing", "Item", 1)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(spec))}
+
+	issues, err := l.Lint(*parseURL("https://api.example.com/"), *parseURL("https://api.example.com/openapi.yaml"), resp)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []cli.LintIssue{
+		{
+			Severity: cli.LintWarning,
+			Pointer:  "/paths/~1items/get/parameters/0",
+			Message:  `parameter "q" has no schema, so it is treated as a string`,
+		},
+		{
+			Severity: cli.LintWarning,
+			Pointer:  "/paths/~1items/post",
+			Message:  `operation has no operationId, so its command is named "post-items"`,
+		},
+		{
+			Severity: cli.LintWarning,
+			Pointer:  "/paths/~1items/post/responses/201",
+			Message:  "201 response has no content schema",
+		},
+		{
+			Severity: cli.LintError,
+			Pointer:  "/paths/~1things/get",
+			Message:  `command name "list-items" collides with #/paths/~1items/get`,
+		},
+	}, issues)
+}
@@ -11,6 +11,7 @@ import (
 	"path"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/danielgtaylor/casing"
@@ -201,6 +202,76 @@ func getRequestInfo(op *v3.Operation) (string, *base.Schema, []interface{}) {
 	return "", nil, nil
 }
 
+// getResponses returns the documented responses of an operation with an
+// example body for each, taken from the response examples if present or
+// otherwise generated from the schema. Ranges like `2XX` use the first status
+// code of the range and the `default` response is only used as a success
+// response if no others are documented.
+func getResponses(op *v3.Operation) []*cli.OperationResponse {
+	responses := []*cli.OperationResponse{}
+	if op.Responses == nil {
+		return responses
+	}
+
+	codes := maps.Keys(op.Responses.Codes)
+	sort.Strings(codes)
+	for _, code := range codes {
+		status, err := strconv.Atoi(strings.NewReplacer("X", "0", "x", "0").Replace(code))
+		if err != nil {
+			continue
+		}
+		responses = append(responses, getResponse(status, op.Responses.Codes[code]))
+	}
+
+	if len(responses) == 0 && op.Responses.Default != nil {
+		responses = append(responses, getResponse(http.StatusOK, op.Responses.Default))
+	}
+
+	return responses
+}
+
+// getResponse returns a single response with its preferred media type and
+// example body.
+func getResponse(status int, resp *v3.Response) *cli.OperationResponse {
+	result := &cli.OperationResponse{Status: status}
+	if resp == nil || len(resp.Content) == 0 {
+		return result
+	}
+
+	// Prefer JSON, fall back to YAML next, otherwise use the first one.
+	mts := maps.Keys(resp.Content)
+	sort.Strings(mts)
+	mt := mts[0]
+	for _, short := range []string{"yaml", "json"} {
+		for _, m := range mts {
+			if strings.Contains(m, short) {
+				mt = m
+			}
+		}
+	}
+	result.MediaType = mt
+
+	item := resp.Content[mt]
+	if item.Example != nil {
+		result.Example = item.Example
+	} else if len(item.Examples) > 0 {
+		keys := maps.Keys(item.Examples)
+		sort.Strings(keys)
+		for _, key := range keys {
+			if ex := item.Examples[key]; ex.Value != nil {
+				result.Example = ex.Value
+				break
+			}
+		}
+	}
+
+	if result.Example == nil && item.Schema != nil && item.Schema.Schema() != nil {
+		result.Example = GenExample(item.Schema.Schema(), modeRead)
+	}
+
+	return result
+}
+
 // paramSchema returns a rendered schema line for a given parameter, falling
 // back to the param type info if no schema is available.
 func paramSchema(p *cli.Param, s *base.Schema) string {
@@ -211,6 +282,27 @@ func paramSchema(p *cli.Param, s *base.Schema) string {
 	return schemaDesc
 }
 
+// operationName returns the command name and aliases for an operation, which
+// is generated from the method and path if there is no operation ID.
+func operationName(method, path string, op *v3.Operation) (string, []string) {
+	aliases := getExtSlice(op.Extensions, ExtAliases, []string{})
+
+	name := casing.Kebab(op.OperationId)
+	if name == "" {
+		name = casing.Kebab(method + "-" + strings.Trim(path, "/"))
+	}
+	if override := getExt(op.Extensions, ExtName, ""); override != "" {
+		name = override
+	} else if oldName := slug.Make(op.OperationId); oldName != "" && oldName != name {
+		// For backward-compatibility, add the old naming scheme as an alias
+		// if it is different. See https://github.com/tarunKoyalwar/restish/issues/29
+		// for additional context; we prefer kebab casing for readability.
+		aliases = append(aliases, oldName)
+	}
+
+	return name, aliases
+}
+
 func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, path *v3.PathItem, op *v3.Operation) cli.Operation {
 	var pathParams, queryParams, headerParams []*cli.Param
 	var pathSchemas, querySchemas, headerSchemas []*base.Schema = []*base.Schema{}, []*base.Schema{}, []*base.Schema{}
@@ -236,28 +328,39 @@ func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, p
 
 		var def interface{}
 		var example interface{}
+		var enum []interface{}
 
 		typ := "string"
 		var schema *base.Schema
 		if p.Schema != nil && p.Schema.Schema() != nil {
 			s := p.Schema.Schema()
 			schema = s
-			if len(s.Type) > 0 {
+			if t := firstType(s); t != "" {
 				// TODO: support params of multiple types?
-				typ = s.Type[0]
+				typ = t
+			}
+
+			enum = s.Enum
+			if s.Const != nil {
+				enum = []interface{}{s.Const}
 			}
 
 			if typ == "array" {
 				if s.Items != nil && s.Items.IsA() {
 					items := s.Items.A.Schema()
-					if len(items.Type) > 0 {
-						typ += "[" + items.Type[0] + "]"
+					if t := firstType(items); t != "" {
+						typ += "[" + t + "]"
 					}
+					enum = items.Enum
 				}
 			}
 
 			def = s.Default
 			example = s.Example
+			if example == nil && len(s.Examples) > 0 {
+				// OpenAPI 3.1 uses the JSON Schema `examples` array instead.
+				example = s.Examples[0]
+			}
 		}
 
 		if p.Example != nil {
@@ -278,6 +381,8 @@ func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, p
 			DisplayName: displayName,
 			Description: description,
 			Style:       style,
+			Required:    p.Required,
+			Enum:        enum,
 			Default:     def,
 			Example:     example,
 		}
@@ -308,20 +413,7 @@ func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, p
 		}
 	}
 
-	aliases := getExtSlice(op.Extensions, ExtAliases, []string{})
-
-	name := casing.Kebab(op.OperationId)
-	if name == "" {
-		name = casing.Kebab(method + "-" + strings.Trim(uriTemplate.Path, "/"))
-	}
-	if override := getExt(op.Extensions, ExtName, ""); override != "" {
-		name = override
-	} else if oldName := slug.Make(op.OperationId); oldName != "" && oldName != name {
-		// For backward-compatibility, add the old naming scheme as an alias
-		// if it is different. See https://github.com/tarunKoyalwar/restish/issues/29
-		// for additional context; we prefer kebab casing for readability.
-		aliases = append(aliases, oldName)
-	}
+	name, aliases := operationName(method, uriTemplate.Path, op)
 
 	desc := getExt(op.Extensions, ExtDescription, op.Description)
 	hidden := getExt(op.Extensions, ExtHidden, false)
@@ -347,6 +439,7 @@ func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, p
 
 	mediaType := ""
 	var examples []string
+	var bodySchema *cli.Schema
 	if op.RequestBody != nil {
 		mt, reqSchema, reqExamples := getRequestInfo(op)
 		mediaType = mt
@@ -407,6 +500,7 @@ func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, p
 		}
 
 		if reqSchema != nil {
+			bodySchema = toValidationSchema(reqSchema, map[[32]byte]bool{})
 			desc += "\n## Request Schema (" + mt + ")\n\n```schema\n" + renderSchema(reqSchema, "", modeWrite) + "\n```\n"
 		}
 	}
@@ -546,18 +640,17 @@ func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, p
 		QueryParams:   queryParams,
 		HeaderParams:  headerParams,
 		BodyMediaType: mediaType,
+		BodySchema:    bodySchema,
 		Examples:      examples,
+		Responses:     getResponses(op),
 		Hidden:        hidden,
 		Deprecated:    dep,
 	}
 }
 
-func loadOpenAPI3(cfg Resolver, cmd *cobra.Command, location *url.URL, resp *http.Response) (cli.API, error) {
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return cli.API{}, err
-	}
-
+// newDocument parses an OpenAPI document, resolving relative references
+// against its location.
+func newDocument(data []byte, location *url.URL) (libopenapi.Document, error) {
 	config := datamodel.NewOpenDocumentConfiguration()
 	schemeLower := strings.ToLower(location.Scheme)
 	if schemeLower == "http" || schemeLower == "https" {
@@ -568,7 +661,16 @@ func loadOpenAPI3(cfg Resolver, cmd *cobra.Command, location *url.URL, resp *htt
 		config.BasePath = path.Dir(location.Path)
 	}
 
-	doc, err := libopenapi.NewDocumentWithConfiguration(data, config)
+	return libopenapi.NewDocumentWithConfiguration(data, config)
+}
+
+func loadOpenAPI3(cfg Resolver, cmd *cobra.Command, location *url.URL, resp *http.Response) (cli.API, error) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cli.API{}, err
+	}
+
+	doc, err := newDocument(data, location)
 	if err != nil {
 		return cli.API{}, err
 	}
@@ -685,6 +787,11 @@ func loadOpenAPI3(cfg Resolver, cmd *cobra.Command, location *url.URL, resp *htt
 		Short:      short,
 		Long:       long,
 		Operations: operations,
+		Servers:    loadServers(model.Servers),
+	}
+
+	if base, err := cfg.Resolve(strings.TrimSuffix(basePath, "/")); err == nil {
+		api.Base = strings.TrimSuffix(base.String(), "/")
 	}
 
 	if len(authSchemes) > 0 {
@@ -696,6 +803,31 @@ func loadOpenAPI3(cfg Resolver, cmd *cobra.Command, location *url.URL, resp *htt
 	return api, nil
 }
 
+// loadServers converts the servers of an API description, named via
+// `x-cli-name` or after their description.
+func loadServers(servers []*v3.Server) []cli.Server {
+	var result []cli.Server
+	for i, s := range servers {
+		server := cli.Server{
+			Name:        getExt(s.Extensions, ExtName, cli.ServerName(s.Description, fmt.Sprintf("server%d", i+1))),
+			URL:         s.URL,
+			Description: s.Description,
+		}
+		if len(s.Variables) > 0 {
+			server.Variables = map[string]cli.ServerVariable{}
+			for name, v := range s.Variables {
+				server.Variables[name] = cli.ServerVariable{
+					Description: v.Description,
+					Default:     v.Default,
+					Enum:        v.Enum,
+				}
+			}
+		}
+		result = append(result, server)
+	}
+	return result
+}
+
 func loadAutoConfig(api *cli.API, model *v3.Document) {
 	var config *autoConfig
 
@@ -780,6 +912,10 @@ func (l *loader) Resolve(relURI string) (*url.URL, error) {
 	return l.base.ResolveReference(parsed), nil
 }
 
+func (l *loader) Name() string {
+	return "openapi"
+}
+
 func (l *loader) LocationHints() []string {
 	return []string{"/openapi.json", "/openapi.yaml", "openapi.json", "openapi.yaml"}
 }
@@ -237,7 +237,119 @@ func TestLoader(t *testing.T) {
 				return strings.Compare(api.Operations[i].Name, api.Operations[j].Name) < 0
 			})
 
+			// Round trip response examples through YAML so that free-form
+			// values use the same map types on both sides.
+			for _, op := range api.Operations {
+				for _, r := range op.Responses {
+					b, err := yaml.Marshal(r.Example)
+					require.NoError(t, err)
+					r.Example = nil
+					require.NoError(t, yaml.Unmarshal(b, &r.Example))
+				}
+			}
+
 			assert.Equal(t, output, api)
 		})
 	}
 }
+
+func TestBodySchema(t *testing.T) {
+	spec := `openapi: "3.0.3"
+info:
+  version: 1.0.0
+  title: Test API
+paths:
+  /nodes:
+    post:
+      operationId: create-node
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Node"
+      responses:
+        "204":
+          description: ""
+components:
+  schemas:
+    Node:
+      type: object
+      required: [price]
+      additionalProperties: false
+      properties:
+        price:
+          type: number
+          minimum: 0
+          exclusiveMinimum: true
+        children:
+          type: array
+          items:
+            $ref: "#/components/schemas/Node"
+`
+
+	base, _ := url.Parse("http://api.example.com")
+	location, _ := url.Parse("http://api.example.com/openapi.yaml")
+	resp := &http.Response{
+		Body: io.NopCloser(strings.NewReader(spec)),
+	}
+
+	api, err := New().Load(*base, *location, resp)
+	require.NoError(t, err)
+	require.Len(t, api.Operations, 1)
+
+	s := api.Operations[0].BodySchema
+	require.NotNil(t, s)
+	assert.True(t, s.NoAdditional)
+	assert.Nil(t, s.Properties["price"].Minimum)
+	assert.Equal(t, 0.0, *s.Properties["price"].ExclusiveMinimum)
+
+	// The recursive reference is cut off and accepts anything.
+	assert.Nil(t, s.Properties["children"].Items)
+
+	assert.Equal(t, []string{
+		"/price: expected number > 0, got 0",
+	}, s.Validate(map[string]any{
+		"price":    0,
+		"children": []any{map[string]any{"price": "1"}},
+	}))
+}
+
+func TestOpenAPI31Validation(t *testing.T) {
+	input, err := os.ReadFile("testdata/openapi31/openapi.yaml")
+	require.NoError(t, err)
+
+	base, _ := url.Parse("http://api.example.com")
+	location, _ := url.Parse("http://api.example.com/openapi.yaml")
+	resp := &http.Response{
+		Body: io.NopCloser(bytes.NewReader(input)),
+	}
+
+	api, err := New().Load(*base, *location, resp)
+	require.NoError(t, err)
+
+	// Webhooks aren't callable so only the path operation is loaded.
+	require.Len(t, api.Operations, 1)
+
+	s := api.Operations[0].BodySchema
+	require.NotNil(t, s)
+
+	assert.Empty(t, s.Validate(map[string]any{
+		"kind":     "widget",
+		"name":     nil,
+		"price":    1.5,
+		"position": []any{1, 2},
+	}))
+
+	assert.Equal(t, []string{
+		"/kind: expected widget, got gadget",
+		"/name: expected string or null, got integer",
+		"/position/1: expected number, got string",
+		"/position: expected at most 2 items, got 3",
+		"/price: expected number > 0, got 0",
+	}, s.Validate(map[string]any{
+		"kind":     "gadget",
+		"name":     5,
+		"price":    0,
+		"position": []any{1, "2", 3},
+	}))
+}
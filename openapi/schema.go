@@ -3,10 +3,12 @@ package openapi
 import (
 	"encoding/base64"
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 
 	"github.com/pb33f/libopenapi/datamodel/high/base"
+	"github.com/tarunKoyalwar/restish/cli"
 )
 
 type schemaMode int
@@ -19,13 +21,24 @@ const (
 // inferType fixes missing type if it is missing & can be inferred
 func inferType(s *base.Schema) {
 	if len(s.Type) == 0 {
-		if s.Items != nil {
+		if s.Items != nil || len(s.PrefixItems) > 0 {
 			s.Type = []string{"array"}
 		}
 
 		if len(s.Properties) > 0 || s.AdditionalProperties != nil {
 			s.Type = []string{"object"}
 		}
+
+		switch s.Const.(type) {
+		case string:
+			s.Type = []string{"string"}
+		case bool:
+			s.Type = []string{"boolean"}
+		case int, int64, uint64:
+			s.Type = []string{"integer"}
+		case float64:
+			s.Type = []string{"number"}
+		}
 	}
 }
 
@@ -33,11 +46,24 @@ func inferType(s *base.Schema) {
 // can't be circular references. Objects result in `false` and that triggers
 // circular ref checks.
 func isSimpleSchema(s *base.Schema) bool {
-	if len(s.Type) == 0 {
-		return true
+	for _, t := range s.Type {
+		// OpenAPI 3.1 allows multiple types, e.g. `[object, "null"]`.
+		if t == "object" {
+			return false
+		}
 	}
 
-	return s.Type[0] != "object"
+	return true
+}
+
+// firstType returns the first non-null type of a schema, if any.
+func firstType(s *base.Schema) string {
+	for _, t := range s.Type {
+		if t != "null" {
+			return t
+		}
+	}
+	return ""
 }
 
 // sortedSchemas is a hack to provide stable outputs from the schema and example
@@ -83,14 +109,7 @@ func renderSchemaInternal(s *base.Schema, indent string, mode schemaMode, known
 	}
 
 	// TODO: list type alternatives somehow?
-	typ := ""
-	for _, t := range s.Type {
-		// Find the first non-null type and use that for now.
-		if t != "null" {
-			typ = t
-			break
-		}
-	}
+	typ := firstType(s)
 
 	switch typ {
 	case "boolean", "integer", "number", "string":
@@ -145,6 +164,10 @@ func renderSchemaInternal(s *base.Schema, indent string, mode schemaMode, known
 			tags = append(tags, fmt.Sprintf("maxLen:%d", *s.MaxLength))
 		}
 
+		if s.Const != nil {
+			tags = append(tags, fmt.Sprintf("const:%v", s.Const))
+		}
+
 		if len(s.Enum) > 0 {
 			enums := []string{}
 			for _, e := range s.Enum {
@@ -164,6 +187,19 @@ func renderSchemaInternal(s *base.Schema, indent string, mode schemaMode, known
 		}
 		return fmt.Sprintf("(%s%s)%s", strings.Join(s.Type, "|"), tagStr, doc)
 	case "array":
+		if len(s.PrefixItems) > 0 {
+			// OpenAPI 3.1 tuples list the schema for each position, and `items`
+			// then applies to any remaining items.
+			arr := "[\n"
+			for _, proxy := range s.PrefixItems {
+				arr += indent + "  " + renderSchemaItem(proxy.Schema(), indent+"  ", mode, known) + "\n"
+			}
+			if s.Items != nil && s.Items.IsA() {
+				arr += indent + "  ..." + renderSchemaItem(s.Items.A.Schema(), indent+"  ", mode, known) + "\n"
+			}
+			return arr + indent + "]"
+		}
+
 		if s.Items != nil && s.Items.IsA() {
 			items := s.Items.A.Schema()
 			simple := isSimpleSchema(items)
@@ -244,5 +280,197 @@ func renderSchemaInternal(s *base.Schema, indent string, mode schemaMode, known
 		return obj
 	}
 
+	if len(s.Type) == 1 && s.Type[0] == "null" {
+		return "(null)"
+	}
+
 	return "<any>"
 }
+
+// renderSchemaItem renders a nested schema, guarding against recursion.
+func renderSchemaItem(s *base.Schema, indent string, mode schemaMode, known map[[32]byte]bool) string {
+	simple := isSimpleSchema(s)
+	hash := s.GoLow().Hash()
+	if simple || !known[hash] {
+		known[hash] = true
+		out := renderSchemaInternal(s, indent, mode, known)
+		known[hash] = false
+		return out
+	}
+	return "<recursive ref>"
+}
+
+// toValidationSchema converts a schema into the serializable subset used by
+// the CLI to validate request bodies. Recursive references are left as `nil`,
+// which accepts any value.
+func toValidationSchema(s *base.Schema, known map[[32]byte]bool) *cli.Schema {
+	if s == nil {
+		return nil
+	}
+
+	hash := s.GoLow().Hash()
+	if known[hash] {
+		return nil
+	}
+	known[hash] = true
+	defer func() { known[hash] = false }()
+
+	inferType(s)
+
+	proxy := func(p *base.SchemaProxy) *cli.Schema {
+		if p == nil {
+			return nil
+		}
+		return toValidationSchema(p.Schema(), known)
+	}
+
+	list := func(proxies []*base.SchemaProxy) []*cli.Schema {
+		var schemas []*cli.Schema
+		for _, p := range sortedSchemas(proxies) {
+			schemas = append(schemas, proxy(p))
+		}
+		return schemas
+	}
+
+	v := &cli.Schema{
+		Type:          s.Type,
+		Format:        s.Format,
+		Default:       s.Default,
+		Example:       s.Example,
+		Enum:          s.Enum,
+		Minimum:       s.Minimum,
+		Maximum:       s.Maximum,
+		MultipleOf:    s.MultipleOf,
+		MinLength:     s.MinLength,
+		MaxLength:     s.MaxLength,
+		Pattern:       s.Pattern,
+		MinItems:      s.MinItems,
+		MaxItems:      s.MaxItems,
+		Required:      s.Required,
+		MinProperties: s.MinProperties,
+		MaxProperties: s.MaxProperties,
+		AllOf:         list(s.AllOf),
+		AnyOf:         list(s.AnyOf),
+		OneOf:         list(s.OneOf),
+		Not:           proxy(s.Not),
+		ReadOnly:      s.ReadOnly,
+	}
+
+	if v.Example == nil && len(s.Examples) > 0 {
+		v.Example = s.Examples[0]
+	}
+
+	if s.Const != nil {
+		// A constant is equivalent to an enum with a single value.
+		v.Enum = []any{s.Const}
+	}
+
+	if s.Nullable != nil {
+		v.Nullable = *s.Nullable
+	}
+
+	if s.UniqueItems != nil {
+		v.UniqueItems = *s.UniqueItems
+	}
+
+	// OpenAPI 3.0 uses booleans to make the minimum/maximum exclusive, while
+	// 3.1 uses numbers like JSON Schema.
+	if e := s.ExclusiveMinimum; e != nil {
+		if e.IsA() {
+			if e.A {
+				v.ExclusiveMinimum, v.Minimum = v.Minimum, nil
+			}
+		} else {
+			v.ExclusiveMinimum = &e.B
+		}
+	}
+	if e := s.ExclusiveMaximum; e != nil {
+		if e.IsA() {
+			if e.A {
+				v.ExclusiveMaximum, v.Maximum = v.Maximum, nil
+			}
+		} else {
+			v.ExclusiveMaximum = &e.B
+		}
+	}
+
+	// Tuple positions matter, so unlike the lists above these aren't sorted.
+	for _, p := range s.PrefixItems {
+		v.PrefixItems = append(v.PrefixItems, proxy(p))
+	}
+	if s.Items != nil && s.Items.IsA() {
+		v.Items = proxy(s.Items.A)
+	} else if s.Items != nil && !s.Items.B && len(s.PrefixItems) > 0 && v.MaxItems == nil {
+		// With `items: false` nothing is allowed after the tuple items.
+		count := int64(len(s.PrefixItems))
+		v.MaxItems = &count
+	}
+
+	if len(s.Properties) > 0 {
+		v.Properties = map[string]*cli.Schema{}
+		for name, p := range s.Properties {
+			v.Properties[name] = proxy(p)
+		}
+	}
+
+	switch ap := s.AdditionalProperties.(type) {
+	case *base.SchemaProxy:
+		v.AdditionalProperties = proxy(ap)
+	case bool:
+		v.NoAdditional = !ap
+	}
+
+	return v
+}
+
+// SchemaEnums returns the allowed values of every property with an `enum` by
+// its dotted path within the schema, e.g. `author.role`. Array items share the
+// path of their array and the values of any sub-schemas are merged.
+func SchemaEnums(s *base.Schema) map[string][]any {
+	enums := map[string][]any{}
+	schemaEnums(s, "", enums, map[[32]byte]bool{})
+	return enums
+}
+
+func schemaEnums(s *base.Schema, path string, enums map[string][]any, known map[[32]byte]bool) {
+	if s == nil {
+		return
+	}
+
+	// Recursive schemas are only followed once per path through the schema.
+	hash := s.GoLow().Hash()
+	if known[hash] {
+		return
+	}
+	known[hash] = true
+	defer delete(known, hash)
+
+	if path != "" {
+	outer:
+		for _, value := range s.Enum {
+			for _, existing := range enums[path] {
+				if reflect.DeepEqual(existing, value) {
+					continue outer
+				}
+			}
+			enums[path] = append(enums[path], value)
+		}
+	}
+
+	for _, proxies := range [][]*base.SchemaProxy{s.AllOf, s.OneOf, s.AnyOf} {
+		for _, p := range proxies {
+			schemaEnums(p.Schema(), path, enums, known)
+		}
+	}
+
+	if s.Items != nil && s.Items.IsA() {
+		schemaEnums(s.Items.A.Schema(), path, enums, known)
+	}
+
+	for name, p := range s.Properties {
+		if path != "" {
+			name = path + "." + name
+		}
+		schemaEnums(p.Schema(), name, enums, known)
+	}
+}
@@ -199,3 +199,28 @@ func TestSchema(t *testing.T) {
 		})
 	}
 }
+
+func TestSchemaEnums(t *testing.T) {
+	var rootNode yaml.Node
+	var ls lowbase.Schema
+
+	in := `{type: object, properties: {
+		status: {type: string, enum: [active, inactive]},
+		author: {type: object, properties: {role: {enum: [editor, writer]}}},
+		tags: {type: array, items: {type: string, enum: [a, b]}},
+		kind: {oneOf: [{enum: [x, y]}, {enum: [y, z]}]},
+		person: {type: object, properties: {friend: {$ref: "#/properties/person"}, mood: {enum: [happy]}}}
+	}}`
+
+	require.NoError(t, yaml.Unmarshal([]byte(in), &rootNode))
+	require.NoError(t, low.BuildModel(rootNode.Content[0], &ls))
+	require.NoError(t, ls.Build(rootNode.Content[0], index.NewSpecIndex(&rootNode)))
+
+	assert.Equal(t, map[string][]any{
+		"status":      {"active", "inactive"},
+		"author.role": {"editor", "writer"},
+		"tags":        {"a", "b"},
+		"kind":        {"x", "y", "z"},
+		"person.mood": {"happy"},
+	}, SchemaEnums(base.NewSchema(&ls)))
+}